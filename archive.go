@@ -0,0 +1,202 @@
+package qrFile
+
+import (
+    "archive/tar"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// A set may encode a tar archive of many files. Its manifest then lists the regular files of the archive along with the
+// elements holding their data, so single files can be restored from a few codes (see Manifest.Select & Extract).
+
+// ManifestFile describes a regular file inside a tar archive encoded by a set
+type ManifestFile struct {
+    Name   string `json:"name"`   // path of the file inside the archive
+    Size   uint64 `json:"size"`   // size of the file in bytes
+    First  uint64 `json:"first"`  // index of the element holding the first byte of the file
+    Last   uint64 `json:"last"`   // index of the element holding the last byte of the file
+    Offset uint64 `json:"offset"` // offset of the first byte of the file inside the data of element First
+}
+
+// archiveFiles lists the regular files of the tar archive encoded by a complete set, nil if the data is no tar archive
+func (elem *QrElements) archiveFiles() []ManifestFile {
+    if elem.Len() == 0 || uint64(elem.Len()) != elem.Elements[0].MaxIndex+1 {
+        return nil
+    }
+    elements := make([]*QrElement, elem.Len())
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        if v.Index >= uint64(len(elements)) || elements[v.Index] != nil {
+            return nil
+        }
+        elements[v.Index] = v
+    }
+    // starts[i] is the offset of the data of element i in the data of the set
+    starts := make([]uint64, len(elements)+1)
+    var data bytes.Buffer
+    for i, v := range elements {
+        buffer := v.Payload
+        data.Write(buffer)
+        starts[i+1] = starts[i] + uint64(len(buffer))
+    }
+    // the tar reader does not tell the position of an entry; counting the bytes consumed does
+    counter := &countingReader{r: bytes.NewReader(data.Bytes())}
+    archive := tar.NewReader(counter)
+    files := make([]ManifestFile, 0)
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil
+        }
+        if header.Typeflag != tar.TypeReg || header.Size == 0 {
+            continue
+        }
+        file := ManifestFile{Name: header.Name, Size: uint64(header.Size)}
+        file.First = chunkOf(starts, counter.n)
+        file.Last = chunkOf(starts, counter.n+file.Size-1)
+        file.Offset = counter.n - starts[file.First]
+        files = append(files, file)
+    }
+    if len(files) == 0 {
+        return nil
+    }
+    return files
+}
+
+// chunkOf returns the index of the element holding the byte at offset pos, given the offsets of all elements
+func chunkOf(starts []uint64, pos uint64) uint64 {
+    i := uint64(0)
+    for i+2 < uint64(len(starts)) && starts[i+1] <= pos {
+        i++
+    }
+    return i
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+    r io.Reader
+    n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    c.n += uint64(n)
+    return n, err
+}
+
+// Select returns the files of the archive at or below path, e.g. "docs/readme.txt" or "docs"
+func (m *Manifest) Select(name string) []ManifestFile {
+    name = strings.Trim(path.Clean("/"+name), "/")
+    selected := make([]ManifestFile, 0)
+    for _, file := range m.Files {
+        entry := strings.Trim(path.Clean("/"+file.Name), "/")
+        if name == "" || entry == name || strings.HasPrefix(entry, name+"/") {
+            selected = append(selected, file)
+        }
+    }
+    return selected
+}
+
+// Indices returns the indices of the elements needed to restore the files, in ascending order
+func (m *Manifest) Indices(files []ManifestFile) []uint64 {
+    needed := make([]bool, m.Count)
+    for _, file := range files {
+        for i := file.First; i <= file.Last && i < m.Count; i++ {
+            needed[i] = true
+        }
+    }
+    indices := make([]uint64, 0)
+    for i, ok := range needed {
+        if ok {
+            indices = append(indices, uint64(i))
+        }
+    }
+    return indices
+}
+
+// ExtractFile writes a single file of the archive described by the manifest to w. Only the elements holding its data
+// (see ManifestFile) are needed; the set does not have to be complete.
+func (elem *QrElements) ExtractFile(m *Manifest, file ManifestFile, w io.Writer) error {
+    elements := make(map[uint64]*QrElement)
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        if v.SetID == m.SetID && v.MaxIndex+1 == m.Count {
+            elements[v.Index] = v
+        }
+    }
+    missing := make([]string, 0)
+    for i := file.First; i <= file.Last; i++ {
+        if _, ok := elements[i]; !ok {
+            missing = append(missing, fmt.Sprint(i))
+        }
+    }
+    if len(missing) > 0 {
+        return errors.New(fmt.Sprintf("Missing elements %s for %s.", strings.Join(missing, ", "), file.Name))
+    }
+    skip, remaining := file.Offset, file.Size
+    for i := file.First; i <= file.Last && remaining > 0; i++ {
+        buffer := elements[i].Payload
+        if skip > uint64(len(buffer)) {
+            return errors.New(fmt.Sprintf("Element %d is too short for %s.", i, file.Name))
+        }
+        buffer = buffer[skip:]
+        skip = 0
+        if uint64(len(buffer)) > remaining {
+            buffer = buffer[:remaining]
+        }
+        _, err := w.Write(buffer)
+        if err != nil {
+            return err
+        }
+        remaining -= uint64(len(buffer))
+    }
+    if remaining > 0 {
+        return errors.New(fmt.Sprintf("Data of %s ends %d bytes early.", file.Name, remaining))
+    }
+    return nil
+}
+
+// Extract restores the files of the archive at or below name (see Manifest.Select) into the directory dir, keeping
+// their path inside the archive. Returns the paths of the files written.
+func (elem *QrElements) Extract(m *Manifest, name string, dir string) ([]string, error) {
+    files := m.Select(name)
+    if len(files) == 0 {
+        return nil, errors.New(fmt.Sprintf("%s not found in the archive.", name))
+    }
+    written := make([]string, 0, len(files))
+    for _, file := range files {
+        // entries must not escape the target directory
+        fname, err := archivePath(dir, file.Name)
+        if err != nil {
+            return written, err
+        }
+        err = os.MkdirAll(filepath.Dir(fname), 0755)
+        if err != nil {
+            return written, err
+        }
+        out, err := os.Create(fname)
+        if err != nil {
+            return written, err
+        }
+        err = elem.ExtractFile(m, file, out)
+        if err != nil {
+            out.Close()
+            return written, err
+        }
+        err = out.Close()
+        if err != nil {
+            return written, err
+        }
+        written = append(written, fname)
+    }
+    return written, nil
+}
@@ -0,0 +1,139 @@
+package qrFile
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log/slog"
+    "os"
+    "path"
+    "strings"
+)
+
+// The tar archive restored from a set in archive mode can be written as a stream instead of being unpacked, e.g. to
+// stdout for "qrFileApp ... | tar x", either unchanged or converted to zip for systems without tar. Nothing is written
+// to disk on the way.
+
+// Formats of ConvertArchive
+const (
+    ArchiveTar = "tar"
+    ArchiveZip = "zip"
+)
+
+// ArchiveFormats lists the formats of ConvertArchive
+var ArchiveFormats = []string{ArchiveTar, ArchiveZip}
+
+// ConvertArchive reads a tar archive (as stored by FromDirectory) from r & writes it to w in the given format while it
+// is read. A tar archive is copied unchanged, but checked on the way; for zip, directories, regular files & symlinks
+// are converted with their permissions & modification times, other entries are skipped.
+func ConvertArchive(w io.Writer, r io.Reader, format string) error {
+    switch format {
+    case ArchiveTar:
+        archive := tar.NewReader(io.TeeReader(r, w))
+        for {
+            _, err := archive.Next()
+            if err == io.EOF {
+                break
+            }
+            if err != nil {
+                return err
+            }
+        }
+        // the padding after the end of the archive
+        _, err := io.Copy(w, r)
+        return err
+    case ArchiveZip:
+        return tarToZip(w, tar.NewReader(r))
+    }
+    return errors.New(fmt.Sprintf("Unknown archive format %s, expected %s", format, strings.Join(ArchiveFormats, " or ")))
+}
+
+// tarToZip writes the entries of a tar archive as zip archive to w
+func tarToZip(w io.Writer, archive *tar.Reader) error {
+    out := zip.NewWriter(w)
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        name := path.Clean(header.Name)
+        if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+            return errors.New(fmt.Sprintf("Refusing to convert %s, it points outside of the archive.", header.Name))
+        }
+        entry := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: header.ModTime}
+        var content io.Reader = archive
+        switch header.Typeflag {
+        case tar.TypeDir:
+            entry.Name += "/"
+            entry.Method = zip.Store
+            entry.SetMode(os.ModeDir | os.FileMode(header.Mode).Perm())
+            content = nil
+        case tar.TypeReg:
+            entry.SetMode(os.FileMode(header.Mode).Perm())
+        case tar.TypeSymlink:
+            // zip stores the target as content of the link, as Info-ZIP does
+            entry.Method = zip.Store
+            entry.SetMode(os.ModeSymlink | 0777)
+            content = strings.NewReader(header.Linkname)
+        default:
+            logf(slog.LevelWarn, "Skipping %s, unsupported entry type %c", header.Name, header.Typeflag)
+            continue
+        }
+        file, err := out.CreateHeader(entry)
+        if err != nil {
+            return err
+        }
+        if content != nil {
+            _, err = io.Copy(file, content)
+            if err != nil {
+                return err
+            }
+        }
+    }
+    return out.Close()
+}
+
+// WriteArchive writes the tar archive held by the QrFile instance (see FromDirectory) to w in the given format (see
+// ConvertArchive)
+func (qrf *QrFile) WriteArchive(w io.Writer, format string) error {
+    return ConvertArchive(w, bytes.NewReader(qrf.Data), format)
+}
+
+// archiveWriter converts the data written to it in a goroutine, see ArchiveWriter
+type archiveWriter struct {
+    *io.PipeWriter
+    done chan error
+}
+
+// ArchiveWriter returns a writer converting the tar archive written to it to the given format on w while it is written
+// (see ConvertArchive), e.g. as target of a Restorer. Close waits for the conversion to finish & returns its error.
+func ArchiveWriter(w io.Writer, format string) (io.WriteCloser, error) {
+    if format != ArchiveTar && format != ArchiveZip {
+        return nil, errors.New(fmt.Sprintf("Unknown archive format %s, expected %s", format, strings.Join(ArchiveFormats, " or ")))
+    }
+    r, pw := io.Pipe()
+    out := &archiveWriter{PipeWriter: pw, done: make(chan error, 1)}
+    go func() {
+        err := ConvertArchive(w, r, format)
+        if err == nil {
+            // a writer should not block on data after the end of the archive
+            _, err = io.Copy(ioutil.Discard, r)
+        }
+        r.CloseWithError(err)
+        out.done <- err
+    }()
+    return out, nil
+}
+
+// Close ends the archive & waits for the conversion to finish
+func (a *archiveWriter) Close() error {
+    a.PipeWriter.Close()
+    return <-a.done
+}
@@ -0,0 +1,293 @@
+package qrFile
+
+import (
+    "bufio"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// An armored set holds all elements of a set in a single ASCII text file, in the style of OpenPGP armor, for channels
+// where images are impossible (a mail body, a ticket, a terminal):
+//
+//     -----BEGIN QRFILE SET-----
+//     Version: 2
+//     Set: 5d41402a
+//     Count: 2
+//     Chunks-Hash: 3a7bd3e2...
+//     Codec: base64 <encoding of the payload in the codes, see codec.go; only if not hex>
+//
+//     Chunk: 1/2
+//     Fields: <custom fields of the element in TLV encoding, hex; only if there are any>
+//     <payload, base64 encoded in lines of 64 characters>
+//     =<crc32 of the payload>
+//
+//     Chunk: 2/2
+//     ...
+//     -----END QRFILE SET-----
+//
+// The headers describe the set like a manifest (see Manifest.ChunksHash); each chunk carries its own checksum, so a
+// damaged chunk is named on import. Text before the first line & after the last one is ignored, e.g. in a mail.
+
+// Lines enclosing an armored set
+const (
+    ArmorBegin = "-----BEGIN QRFILE SET-----"
+    ArmorEnd   = "-----END QRFILE SET-----"
+)
+
+// armorLineLength is the number of base64 characters per line of an armored chunk
+const armorLineLength = 64
+
+// WriteArmor writes all elements as armored set to w
+func (elem *QrElements) WriteArmor(w io.Writer) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to armor")
+    }
+    manifest := elem.Manifest()
+    out := bufio.NewWriter(w)
+    fmt.Fprintf(out, "%s\nVersion: %d\n", ArmorBegin, manifest.Version)
+    if len(manifest.SetID) > 0 {
+        fmt.Fprintf(out, "Set: %s\n", manifest.SetID)
+    }
+    fmt.Fprintf(out, "Count: %d\nChunks-Hash: %s\n", manifest.Count, manifest.ChunksHash())
+    if len(manifest.Codec) > 0 {
+        fmt.Fprintf(out, "Codec: %s\n", manifest.Codec)
+    }
+    for _, header := range []struct {
+        name  string
+        value interface{}
+        set   bool
+    }{{"Transforms", manifest.Transforms, len(manifest.Transforms) > 0}, {"PGP", manifest.PGP, manifest.PGP != nil},
+        {"Fields", manifest.Fields, len(manifest.Fields) > 0}, {"File", manifest.File, manifest.File != nil},
+        {"Metadata", manifest.Metadata, manifest.Metadata != nil}, {"Signature", manifest.Signature, len(manifest.Signature) > 0}} {
+        if !header.set {
+            continue
+        }
+        value, err := json.Marshal(header.value)
+        if err != nil {
+            return err
+        }
+        fmt.Fprintf(out, "%s: %s\n", header.name, value)
+    }
+    for _, v := range elem.Elements {
+        data := v.Payload
+        fmt.Fprintf(out, "\nChunk: %d/%d\n", v.Index+1, v.MaxIndex+1)
+        if len(v.Fields) > 0 {
+            fmt.Fprintf(out, "Fields: %s\n", hex.EncodeToString(v.Fields.Marshal()))
+        }
+        encoded := base64.StdEncoding.EncodeToString(data)
+        for len(encoded) > armorLineLength {
+            fmt.Fprintln(out, encoded[:armorLineLength])
+            encoded = encoded[armorLineLength:]
+        }
+        if len(encoded) > 0 {
+            fmt.Fprintln(out, encoded)
+        }
+        fmt.Fprintf(out, "=%08x\n", crc32.ChecksumIEEE(data))
+    }
+    fmt.Fprintln(out, ArmorEnd)
+    return out.Flush()
+}
+
+// armorChunk collects a chunk while an armored set is read
+type armorChunk struct {
+    number  uint64
+    count   uint64
+    fields  HeaderFields
+    encoded strings.Builder
+}
+
+// ReadArmor reads the elements of an armored set (see WriteArmor). Each chunk is checked against its checksum & the
+// complete set against the headers.
+func ReadArmor(r io.Reader) (*QrElements, error) {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    line := 0
+    next := func() (string, bool) {
+        if !scanner.Scan() {
+            return "", false
+        }
+        line++
+        return strings.TrimSpace(scanner.Text()), true
+    }
+    malformed := func(format string, args ...interface{}) error {
+        return errors.New(fmt.Sprintf("Line %d: %s", line, fmt.Sprintf(format, args...)))
+    }
+    // skip everything before the armor
+    for {
+        text, ok := next()
+        if !ok {
+            if err := scanner.Err(); err != nil {
+                return nil, err
+            }
+            return nil, errors.New(fmt.Sprintf("No armored set found (missing %s)", ArmorBegin))
+        }
+        if text == ArmorBegin {
+            break
+        }
+    }
+    headers := make(map[string]string)
+    var chunk *armorChunk
+    elements := MakeQrElements(0)
+    version := VersionLegacy
+    codec := CodecHex
+    for {
+        text, ok := next()
+        if !ok {
+            if err := scanner.Err(); err != nil {
+                return nil, err
+            }
+            return nil, errors.New(fmt.Sprintf("Armored set is truncated (missing %s)", ArmorEnd))
+        }
+        if text == ArmorEnd {
+            if chunk != nil {
+                return nil, malformed("chunk %d ends without checksum", chunk.number)
+            }
+            break
+        }
+        switch {
+        case len(text) == 0:
+        case strings.HasPrefix(text, "Chunk:"):
+            if chunk != nil {
+                return nil, malformed("chunk %d ends without checksum", chunk.number)
+            }
+            chunk = new(armorChunk)
+            _, err := fmt.Sscanf(strings.TrimSpace(text[len("Chunk:"):]), "%d/%d", &chunk.number, &chunk.count)
+            if err != nil || chunk.number == 0 || chunk.number > chunk.count {
+                return nil, malformed("invalid chunk line %q", text)
+            }
+        case chunk == nil:
+            // a header; the chunks start with the first "Chunk:" line
+            colon := strings.Index(text, ":")
+            if colon <= 0 || elements.Len() > 0 {
+                return nil, malformed("unexpected text %q", text)
+            }
+            headers[text[:colon]] = strings.TrimSpace(text[colon+1:])
+            if text[:colon] == "Version" {
+                var err error
+                version, err = strconv.Atoi(headers["Version"])
+                if err != nil || version < VersionLegacy || version > VersionCompact {
+                    return nil, malformed("unsupported version %s", headers["Version"])
+                }
+            }
+            if text[:colon] == "Codec" {
+                var err error
+                codec, err = ParsePayloadCodec(headers["Codec"])
+                if err != nil {
+                    return nil, malformed("%s", err)
+                }
+            }
+        case strings.HasPrefix(text, "Fields:") && chunk.encoded.Len() == 0:
+            data, err := hex.DecodeString(strings.TrimSpace(text[len("Fields:"):]))
+            if err == nil {
+                chunk.fields, err = ParseHeaderFields(data)
+            }
+            if err != nil {
+                return nil, malformed("chunk %d: invalid fields: %s", chunk.number, err)
+            }
+        case strings.HasPrefix(text, "="):
+            data, err := base64.StdEncoding.DecodeString(chunk.encoded.String())
+            if err != nil {
+                return nil, malformed("chunk %d: %s", chunk.number, err)
+            }
+            if fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) != strings.ToLower(text[1:]) {
+                return nil, malformed("chunk %d is damaged (checksum mismatch)", chunk.number)
+            }
+            element, err := armoredElement(version, headers["Set"], chunk.number-1, chunk.count-1, data, codec)
+            if err != nil {
+                return nil, malformed("chunk %d: %s", chunk.number, err)
+            }
+            element.Fields = chunk.fields
+            elements.Elements = append(elements.Elements, element)
+            chunk = nil
+        default:
+            chunk.encoded.WriteString(text)
+        }
+    }
+    err := checkArmorHeaders(elements, headers)
+    if err != nil {
+        return nil, err
+    }
+    return elements, nil
+}
+
+// armoredElement creates the element of an armored chunk
+func armoredElement(version int, setID string, index uint64, maxIndex uint64, data []byte, codec PayloadCodec) (QrElement, error) {
+    switch version {
+    case VersionLegacy:
+        return legacyElement(index, maxIndex, data)
+    case VersionCompact:
+        return compactElement(index, maxIndex, data)
+    }
+    if 2*uint64(len(data)) > plainMaxChunkSize(LevelL, codec) {
+        return QrElement{}, sizeError(2*uint64(len(data)), plainMaxChunkSize(LevelL, codec), "Payload size exceeds maximum data size")
+    }
+    return QrElement{Version: VersionPlain, SetID: setID, Index: index, MaxIndex: maxIndex, PayloadLength: 2 * uint64(len(data)), Payload: data, Codec: codec}, nil
+}
+
+// checkArmorHeaders validates the elements read from an armored set & compares them with the headers
+func checkArmorHeaders(elements *QrElements, headers map[string]string) error {
+    err := elements.Validate()
+    if err != nil {
+        return err
+    }
+    manifest := elements.Manifest()
+    if count := headers["Count"]; len(count) > 0 && count != strconv.FormatUint(manifest.Count, 10) {
+        return errors.New(fmt.Sprintf("The armored set holds %d chunks, the header announces %s", manifest.Count, count))
+    }
+    if hash := headers["Chunks-Hash"]; len(hash) > 0 && !strings.EqualFold(hash, manifest.ChunksHash()) {
+        return checksumError("The chunks do not match the Chunks-Hash header")
+    }
+    if transforms := headers["Transforms"]; len(transforms) > 0 {
+        err = json.Unmarshal([]byte(transforms), &elements.Transforms)
+        if err != nil {
+            return fmt.Errorf("Invalid Transforms header: %w", err)
+        }
+    }
+    if fields := headers["Fields"]; len(fields) > 0 {
+        err = json.Unmarshal([]byte(fields), &elements.Fields)
+        if err != nil {
+            return fmt.Errorf("Invalid Fields header: %w", err)
+        }
+    }
+    if file := headers["File"]; len(file) > 0 {
+        elements.File = new(FileInfo)
+        err = json.Unmarshal([]byte(file), elements.File)
+        if err == nil {
+            _, err = cleanFileName(elements.File.Name)
+        }
+        if err != nil {
+            return fmt.Errorf("Invalid File header: %w", err)
+        }
+    }
+    if metadata := headers["Metadata"]; len(metadata) > 0 {
+        elements.Meta = new(Metadata)
+        err = json.Unmarshal([]byte(metadata), elements.Meta)
+        if err == nil {
+            err = elements.Meta.check()
+        }
+        if err != nil {
+            return fmt.Errorf("Invalid Metadata header: %w", err)
+        }
+    }
+    if signature := headers["Signature"]; len(signature) > 0 {
+        err = json.Unmarshal([]byte(signature), &elements.Signature)
+        if err != nil {
+            return fmt.Errorf("Invalid Signature header: %w", err)
+        }
+    }
+    if pgp := headers["PGP"]; len(pgp) > 0 {
+        elements.PGP = new(PGPInfo)
+        err = json.Unmarshal([]byte(pgp), elements.PGP)
+        if err != nil {
+            return fmt.Errorf("Invalid PGP header: %w", err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,183 @@
+package qrFile
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+)
+
+// Assembler collects QrElement entries one at a time, e.g. while codes are scanned with a camera. Repeated scans of an
+// element which was already added are ignored, so callers may feed every symbol they read without keeping track themselves.
+type Assembler struct {
+    elements map[uint64]QrElement
+    maxIndex uint64
+    setKey   string
+    setID    string
+    sync     *Manifest // set parameters announced by a sync frame, if any
+    started  time.Time // time the first code was added, for Stats
+    scans    int       // codes added, including repeated ones
+    bytes    uint64    // data bytes of the elements collected
+}
+
+// NewAssembler creates an empty Assembler
+func NewAssembler() *Assembler {
+    a := new(Assembler)
+    a.elements = make(map[uint64]QrElement)
+    return a
+}
+
+// Add stores a single element. The result is true if the element was not known before. An error is returned if the
+// element does not belong to the set collected so far (different set ID or MaxIndex) or conflicts with an element
+// already stored.
+func (a *Assembler) Add(newElement QrElement) (bool, error) {
+    a.count()
+    if newElement.Index > newElement.MaxIndex {
+        return false, errors.New(fmt.Sprintf("Element index %d exceeds maximum index %d", newElement.Index, newElement.MaxIndex))
+    }
+    if len(a.elements) > 0 && newElement.MaxIndex != a.maxIndex {
+        return false, errors.New(fmt.Sprintf("Element %d belongs to a different set (maximum index %d, expected %d)", newElement.Index, newElement.MaxIndex, a.maxIndex))
+    }
+    if len(a.elements) > 0 && newElement.setKey() != a.setKey {
+        return false, errors.New(fmt.Sprintf("Element %d belongs to a different set (%s%s)", newElement.Index, setIDPrefix, newElement.SetID))
+    }
+    if known, ok := a.elements[newElement.Index]; ok {
+        if known.Hash() != newElement.Hash() {
+            return false, &ConflictError{Index: newElement.Index, Sources: []string{known.source, newElement.source}}
+        }
+        return false, nil
+    }
+    a.maxIndex = newElement.MaxIndex
+    a.setKey = newElement.setKey()
+    a.setID = newElement.SetID
+    a.elements[newElement.Index] = newElement
+    a.bytes += newElement.PayloadLength / 2
+    return true, nil
+}
+
+// AddString parses a string as produced by QrElement.AsString and adds the resulting element. Texts of calibration &
+// sync frames (see StreamOptions) are accepted as well; the set parameters announced by a sync frame are used by Total
+// until the first element was added.
+func (a *Assembler) AddString(str string) (bool, error) {
+    if IsControlText(str) {
+        a.count()
+        if manifest, err := ParseSync(str); err == nil {
+            a.sync = manifest
+        }
+        return false, nil
+    }
+    newElement := new(QrElement)
+    err := newElement.ParseString(str)
+    if err != nil {
+        return false, err
+    }
+    return a.Add(*newElement)
+}
+
+// count records a code being added
+func (a *Assembler) count() {
+    if a.scans == 0 {
+        a.started = time.Now()
+    }
+    a.scans++
+}
+
+// Len returns the number of distinct elements collected so far
+func (a *Assembler) Len() int {
+    return len(a.elements)
+}
+
+// Total returns the number of elements of the complete set, or 0 if neither an element nor a sync frame was added yet
+func (a *Assembler) Total() uint64 {
+    if len(a.elements) == 0 {
+        if a.sync != nil {
+            return a.sync.Count
+        }
+        return 0
+    }
+    return a.maxIndex + 1
+}
+
+// Missing returns the indices of the elements not collected yet, in ascending order; empty if the size of the set is
+// not known yet
+func (a *Assembler) Missing() []uint64 {
+    found := make(map[uint64]bool, len(a.elements))
+    for index := range a.elements {
+        found[index] = true
+    }
+    return missingIndices(found, a.Total())
+}
+
+// sorted returns the collected elements, sorted by index
+func (a *Assembler) sorted() []QrElement {
+    elements := make([]QrElement, 0, len(a.elements))
+    for _, v := range a.elements {
+        elements = append(elements, v)
+    }
+    sort.Slice(elements, func(i, j int) bool { return elements[i].Index < elements[j].Index })
+    return elements
+}
+
+// Complete reports whether all elements of the set have been collected
+func (a *Assembler) Complete() bool {
+    return len(a.elements) > 0 && uint64(len(a.elements)) == a.Total()
+}
+
+// Elements returns the collected elements as a sorted, validated QrElements set
+func (a *Assembler) Elements() (*QrElements, error) {
+    if !a.Complete() {
+        return nil, errors.New(fmt.Sprintf("Incomplete set: %d of %d elements collected.", a.Len(), a.Total()))
+    }
+    elements := MakeQrElements(0)
+    for _, v := range a.elements {
+        elements.Elements = append(elements.Elements, v)
+    }
+    err := elements.Validate()
+    if err != nil {
+        return nil, err
+    }
+    return elements, nil
+}
+
+// ReceiveStats describes the progress of a transfer, e.g. while the codes of a stream are captured with a camera
+type ReceiveStats struct {
+    Received        int           // distinct elements collected
+    Total           uint64        // elements of the complete set; 0 if not known yet
+    Scans           int           // codes added, including repeated ones & control frames
+    Bytes           uint64        // data bytes collected
+    Elapsed         time.Duration // time since the first code was added
+    BytesPerSecond  float64
+    ChunksPerSecond float64       // rate of new elements
+    Remaining       time.Duration // estimated time until the set is complete; 0 if complete or unknown
+}
+
+// Stats returns the progress of the transfer: the rates are averaged since the first code was added, the remaining
+// time is estimated from the rate of new elements.
+func (a *Assembler) Stats() ReceiveStats {
+    stats := ReceiveStats{Received: a.Len(), Total: a.Total(), Scans: a.scans, Bytes: a.bytes}
+    if a.scans == 0 {
+        return stats
+    }
+    stats.Elapsed = time.Since(a.started)
+    if seconds := stats.Elapsed.Seconds(); seconds > 0 {
+        stats.BytesPerSecond = float64(stats.Bytes) / seconds
+        stats.ChunksPerSecond = float64(stats.Received) / seconds
+    }
+    if stats.ChunksPerSecond > 0 && stats.Total > uint64(stats.Received) {
+        stats.Remaining = time.Duration(float64(stats.Total-uint64(stats.Received)) / stats.ChunksPerSecond * float64(time.Second))
+    }
+    return stats
+}
+
+// String formats the statistics for a progress display, e.g. "12/40 codes, 1.4 KB/s, 2.1 codes/s, 13s remaining"
+func (s ReceiveStats) String() string {
+    total := "?"
+    if s.Total > 0 {
+        total = fmt.Sprintf("%d", s.Total)
+    }
+    result := fmt.Sprintf("%d/%s codes, %.1f KB/s, %.1f codes/s", s.Received, total, s.BytesPerSecond/1000, s.ChunksPerSecond)
+    if s.Remaining > 0 {
+        result += fmt.Sprintf(", %s remaining", s.Remaining.Round(time.Second))
+    }
+    return result
+}
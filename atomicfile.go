@@ -0,0 +1,105 @@
+package qrFile
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "os"
+    "path/filepath"
+)
+
+// Restored files are written under a temporary name next to their destination & renamed once complete, so a decode
+// which fails halfway (or a crash) never leaves a truncated file behind, and an existing file is only replaced by a
+// complete one. Existing files are not replaced unless this is asked for.
+
+// AtomicFile is a file written under a temporary name in the directory of its destination; Commit moves it into place,
+// Abort removes it. The temporary file is created like os.Create does (permissions 0666 before the umask).
+type AtomicFile struct {
+    *os.File
+    name      string // destination of the file
+    overwrite bool
+    done      bool
+}
+
+// CreateAtomic creates a temporary file for fname. Unless overwrite is set, an error satisfying os.IsExist is returned
+// if fname exists already, before anything is written.
+func CreateAtomic(fname string, overwrite bool) (*AtomicFile, error) {
+    if !overwrite {
+        if err := checkNotExists(fname); err != nil {
+            return nil, err
+        }
+    }
+    for attempt := 0; ; attempt++ {
+        suffix := make([]byte, 6)
+        _, err := rand.Read(suffix)
+        if err != nil {
+            return nil, err
+        }
+        temp := filepath.Join(filepath.Dir(fname), fmt.Sprintf(".%s.%s.tmp", filepath.Base(fname), hex.EncodeToString(suffix)))
+        file, err := os.OpenFile(temp, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+        if os.IsExist(err) && attempt < 10 {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        return &AtomicFile{File: file, name: fname, overwrite: overwrite}, nil
+    }
+}
+
+// checkNotExists returns an error satisfying os.IsExist if fname exists
+func checkNotExists(fname string) error {
+    if _, err := os.Lstat(fname); err == nil {
+        return &os.PathError{Op: "write", Path: fname, Err: os.ErrExist}
+    }
+    return nil
+}
+
+// Name returns the destination of the file
+func (f *AtomicFile) Name() string {
+    return f.name
+}
+
+// Commit flushes the file to disk, closes it & renames it to its destination. If the destination was created since
+// the file was created & overwrite is not set, the file is removed & an error satisfying os.IsExist is returned. Once a
+// file is committed or aborted, further calls do nothing.
+func (f *AtomicFile) Commit() error {
+    if f.done {
+        return nil
+    }
+    err := f.File.Sync()
+    if closeErr := f.File.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil && !f.overwrite {
+        err = checkNotExists(f.name)
+    }
+    if err == nil {
+        err = os.Rename(f.File.Name(), f.name)
+    }
+    f.done = true
+    if err != nil {
+        os.Remove(f.File.Name())
+    }
+    return err
+}
+
+// Abort closes & removes the temporary file, leaving the destination untouched. It does nothing after Commit, so it
+// can be deferred.
+func (f *AtomicFile) Abort() {
+    if f.done {
+        return
+    }
+    f.done = true
+    f.File.Close()
+    os.Remove(f.File.Name())
+}
+
+// existingMode returns the permissions of fname, if it is an existing regular file
+func existingMode(fname string) (os.FileMode, bool) {
+    info, err := os.Stat(fname)
+    if err != nil || !info.Mode().IsRegular() {
+        return 0, false
+    }
+    return info.Mode().Perm(), true
+}
@@ -0,0 +1,205 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "image/png"
+    "io/ioutil"
+    "math/rand"
+    "os"
+    "sync"
+    "time"
+)
+
+// BenchmarkOptions describes the matrix of settings measured by Benchmark. Each combination of chunk size, error
+// correction level & worker count encodes (and decodes) the same synthetic data.
+type BenchmarkOptions struct {
+    Size       int           // bytes of synthetic data per run
+    ChunkSizes []uint64      // payload characters per element (plain format); the default of the format if 0
+    Levels     []Level       // error correction levels
+    Workers    []int         // number of images rendered & decoded concurrently
+    Encoder    SymbolEncoder // renders the images; DefaultEncoder if nil
+    Decoder    Decoder       // reads the images; the default decoder if nil (see ZbarFallback)
+    EncodeOnly bool          // only measure encoding, e.g. if no decoder is available
+}
+
+// BenchmarkResult holds the measurements of one combination of settings. Encoding covers splitting the data, rendering
+// the codes & writing them as png; decoding covers reading the png images, decoding the codes & validating the set.
+type BenchmarkResult struct {
+    ChunkSize uint64
+    Level     Level
+    Workers   int
+    Codes     int
+    Size      int // bytes of data encoded
+    Encode    time.Duration
+    Decode    time.Duration // 0 if decoding was not measured
+    Err       error         // the combination could not be measured, e.g. the chunk size exceeds the capacity of a code
+}
+
+// EncodeThroughput returns the bytes of data encoded per second
+func (r BenchmarkResult) EncodeThroughput() float64 {
+    return throughput(r.Size, r.Encode)
+}
+
+// DecodeThroughput returns the bytes of data decoded per second; 0 if decoding was not measured
+func (r BenchmarkResult) DecodeThroughput() float64 {
+    return throughput(r.Size, r.Decode)
+}
+
+func throughput(size int, duration time.Duration) float64 {
+    if duration <= 0 {
+        return 0
+    }
+    return float64(size) / duration.Seconds()
+}
+
+// benchmarkSeed makes the synthetic data of all runs (& program invocations) the same, so results can be compared
+const benchmarkSeed = 1
+
+// Benchmark encodes & decodes synthetic data with every combination of the settings in options on the local machine.
+// Combinations which can not be used are reported in BenchmarkResult.Err; an error is only returned if the options
+// are invalid or the decoder is not available.
+func Benchmark(options BenchmarkOptions) ([]BenchmarkResult, error) {
+    if options.Size <= 0 {
+        return nil, errors.New(fmt.Sprintf("Invalid benchmark size %d", options.Size))
+    }
+    if len(options.ChunkSizes) == 0 || len(options.Levels) == 0 || len(options.Workers) == 0 {
+        return nil, errors.New("A benchmark needs at least one chunk size, level and worker count")
+    }
+    for _, workers := range options.Workers {
+        if workers < 1 {
+            return nil, errors.New(fmt.Sprintf("Invalid worker count %d", workers))
+        }
+    }
+    if !options.EncodeOnly {
+        if err := CheckDecoder(options.Decoder); err != nil {
+            return nil, err
+        }
+    }
+    data := make([]byte, options.Size)
+    rand.New(rand.NewSource(benchmarkSeed)).Read(data)
+    payload := hex.EncodeToString(data)
+
+    results := make([]BenchmarkResult, 0, len(options.ChunkSizes)*len(options.Levels)*len(options.Workers))
+    for _, chunkSize := range options.ChunkSizes {
+        for _, level := range options.Levels {
+            for _, workers := range options.Workers {
+                result := BenchmarkResult{ChunkSize: chunkSize, Level: level, Workers: workers, Size: options.Size}
+                result.Err = benchmarkRun(payload, options, &result)
+                results = append(results, result)
+            }
+        }
+    }
+    return results, nil
+}
+
+// benchmarkRun measures a single combination of settings & stores the measurements in result
+func benchmarkRun(payload string, options BenchmarkOptions, result *BenchmarkResult) error {
+    start := time.Now()
+    elements, err := GetElementsWithOptions(payload, EncodeOptions{Version: VersionPlain, ChunkSize: result.ChunkSize, Level: result.Level, Encoder: options.Encoder})
+    if err != nil {
+        return err
+    }
+    images := make([][]byte, elements.Len())
+    err = runWorkers(result.Workers, len(images), func(i int) error {
+        img, err := elements.render(&elements.Elements[i])
+        if err != nil {
+            return err
+        }
+        var buf bytes.Buffer
+        if err := png.Encode(&buf, img); err != nil {
+            return err
+        }
+        images[i] = buf.Bytes()
+        return nil
+    })
+    result.Encode = time.Since(start)
+    result.Codes = len(images)
+    if err != nil || options.EncodeOnly {
+        return err
+    }
+
+    start = time.Now()
+    texts := make([][]string, len(images))
+    err = runWorkers(result.Workers, len(images), func(i int) error {
+        var err error
+        texts[i], err = benchmarkDecode(images[i], options.Decoder)
+        return err
+    })
+    if err != nil {
+        return err
+    }
+    all := make([]string, 0, len(texts))
+    for _, t := range texts {
+        all = append(all, t...)
+    }
+    decoded := &QrElements{}
+    if err := decoded.ImportStrings(all); err != nil {
+        return err
+    }
+    result.Decode = time.Since(start)
+    if decoded.Len() != elements.Len() {
+        return errors.New(fmt.Sprintf("Decoded %d of %d codes", decoded.Len(), elements.Len()))
+    }
+    return nil
+}
+
+// benchmarkDecode reads the codes of a png image like FromPNGs does: zbarimg reads a file, other decoders the image
+func benchmarkDecode(data []byte, decoder Decoder) ([]string, error) {
+    if _, zbar := decoder.(ZbarDecoder); !zbar {
+        img, err := png.Decode(bytes.NewReader(data))
+        if err != nil {
+            return nil, err
+        }
+        return decoderOrDefault(decoder).DecodeImage(img)
+    }
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileBench*.png")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    _, err = tempfile.Write(data)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+    symbols, err := scanPNG(context.Background(), tempfile.Name())
+    return symbolTexts(symbols), err
+}
+
+// runWorkers calls work for 0..n-1 in at most workers goroutines & returns the first error
+func runWorkers(workers int, n int, work func(i int) error) error {
+    indices := make(chan int)
+    errs := make(chan error, workers)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var failed error
+            for i := range indices {
+                if failed == nil {
+                    failed = work(i)
+                }
+            }
+            errs <- failed
+        }()
+    }
+    for i := 0; i < n; i++ {
+        indices <- i
+    }
+    close(indices)
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
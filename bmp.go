@@ -0,0 +1,204 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "io"
+    "io/ioutil"
+    "math/bits"
+    "os"
+)
+
+// Minimal BMP support for reading: scanner software on Windows often saves pages as BMP. Supported are the images such
+// software produces: 1, 4 & 8 bit palette images, 16 & 32 bit images with bit fields and 24 & 32 bit RGB images, stored
+// bottom-up or top-down, without compression.
+
+// BMP compression methods supported
+const (
+    bmpRGB       = 0
+    bmpBitFields = 3
+)
+
+// bmpCoreHeaderSize is the size of the OS/2 header, which stores the image size in 16 bits & the palette in 3 bytes per
+// color; all later headers start like the Windows header of bmpInfoHeaderSize bytes
+const (
+    bmpCoreHeaderSize = 12
+    bmpInfoHeaderSize = 40
+)
+
+// DecodeBMP reads an uncompressed BMP image
+func DecodeBMP(r io.Reader) (image.Image, error) {
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < 14+bmpCoreHeaderSize || string(data[:2]) != "BM" {
+        return nil, errors.New("Not a BMP image")
+    }
+    offset := binary.LittleEndian.Uint32(data[10:14])
+    headerSize := binary.LittleEndian.Uint32(data[14:18])
+    if headerSize < bmpCoreHeaderSize || uint64(14+headerSize) > uint64(len(data)) {
+        return nil, errors.New("Truncated BMP header")
+    }
+    header := data[14 : 14+headerSize]
+    var width, height int
+    var bpp, compression, colors uint32
+    paletteEntry := 4
+    if headerSize == bmpCoreHeaderSize {
+        width = int(binary.LittleEndian.Uint16(header[4:6]))
+        height = int(binary.LittleEndian.Uint16(header[6:8]))
+        bpp = uint32(binary.LittleEndian.Uint16(header[10:12]))
+        paletteEntry = 3
+    } else if headerSize >= bmpInfoHeaderSize {
+        width = int(int32(binary.LittleEndian.Uint32(header[4:8])))
+        height = int(int32(binary.LittleEndian.Uint32(header[8:12])))
+        bpp = uint32(binary.LittleEndian.Uint16(header[14:16]))
+        compression = binary.LittleEndian.Uint32(header[16:20])
+        colors = binary.LittleEndian.Uint32(header[32:36])
+    } else {
+        return nil, errors.New(fmt.Sprintf("Unsupported BMP header size %d", headerSize))
+    }
+    // a negative height marks rows stored top-down
+    topDown := height < 0
+    if topDown {
+        height = -height
+    }
+    if width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 {
+        return nil, errors.New(fmt.Sprintf("Invalid image size %dx%d", width, height))
+    }
+    rest := data[14+headerSize:]
+    var masks [3]uint32
+    switch {
+    case compression == bmpBitFields && (bpp == 16 || bpp == 32):
+        // the masks follow the header of bmpInfoHeaderSize bytes; later headers include them
+        fields := rest
+        if headerSize >= bmpInfoHeaderSize+12 {
+            fields = header[bmpInfoHeaderSize:]
+        }
+        if len(fields) < 12 {
+            return nil, errors.New("Truncated BMP bit fields")
+        }
+        for i := range masks {
+            masks[i] = binary.LittleEndian.Uint32(fields[4*i:])
+        }
+    case compression != bmpRGB:
+        return nil, errors.New(fmt.Sprintf("Compressed BMP images (method %d) are not supported", compression))
+    case bpp == 16:
+        // 5 bits per channel
+        masks = [3]uint32{0x7C00, 0x03E0, 0x001F}
+    case bpp == 32:
+        masks = [3]uint32{0xFF0000, 0xFF00, 0xFF}
+    }
+    var palette color.Palette
+    if bpp <= 8 {
+        if bpp != 1 && bpp != 4 && bpp != 8 {
+            return nil, errors.New(fmt.Sprintf("Unsupported BMP depth of %d bits", bpp))
+        }
+        if colors == 0 || colors > 1<<bpp {
+            colors = 1 << bpp
+        }
+        if len(rest) < int(colors)*paletteEntry {
+            return nil, errors.New("Truncated BMP palette")
+        }
+        palette = make(color.Palette, colors)
+        for i := range palette {
+            entry := rest[i*paletteEntry:]
+            palette[i] = color.RGBA{R: entry[2], G: entry[1], B: entry[0], A: 0xFF}
+        }
+    } else if bpp != 16 && bpp != 24 && bpp != 32 {
+        return nil, errors.New(fmt.Sprintf("Unsupported BMP depth of %d bits", bpp))
+    }
+    // rows are padded to a multiple of 4 bytes
+    stride := (width*int(bpp) + 31) / 32 * 4
+    if uint64(offset)+uint64(stride)*uint64(height) > uint64(len(data)) {
+        return nil, errors.New("Truncated BMP image data")
+    }
+    pixels := data[offset:]
+    bounds := image.Rect(0, 0, width, height)
+    var img image.Image
+    if palette != nil {
+        paletted := image.NewPaletted(bounds, palette)
+        for y := 0; y < height; y++ {
+            row := pixels[bmpRow(y, height, topDown)*stride:]
+            for x := 0; x < width; x++ {
+                bit := x * int(bpp)
+                index := (row[bit/8] >> (8 - int(bpp) - bit%8)) & (1<<bpp - 1)
+                if int(index) >= len(palette) {
+                    index = 0
+                }
+                paletted.Pix[y*paletted.Stride+x] = index
+            }
+        }
+        img = paletted
+    } else {
+        rgba := image.NewRGBA(bounds)
+        size := int(bpp) / 8
+        for y := 0; y < height; y++ {
+            row := pixels[bmpRow(y, height, topDown)*stride:]
+            for x := 0; x < width; x++ {
+                pixel := row[x*size:]
+                var c color.RGBA
+                switch size {
+                case 2:
+                    c = bmpMasked(uint32(binary.LittleEndian.Uint16(pixel)), masks)
+                case 3:
+                    c = color.RGBA{R: pixel[2], G: pixel[1], B: pixel[0], A: 0xFF}
+                default:
+                    c = bmpMasked(binary.LittleEndian.Uint32(pixel), masks)
+                }
+                rgba.SetRGBA(x, y, c)
+            }
+        }
+        img = rgba
+    }
+    return img, nil
+}
+
+// bmpRow returns the row of the image data holding the line y of the image
+func bmpRow(y int, height int, topDown bool) int {
+    if topDown {
+        return y
+    }
+    return height - 1 - y
+}
+
+// bmpMasked extracts the channels selected by the masks (red, green & blue) from a pixel value; alpha is ignored, codes
+// are read from opaque scans
+func bmpMasked(value uint32, masks [3]uint32) color.RGBA {
+    var channels [3]uint8
+    for i, mask := range masks {
+        if mask == 0 {
+            continue
+        }
+        shift := uint(bits.TrailingZeros32(mask))
+        channels[i] = uint8(uint64((value&mask)>>shift) * 255 / uint64(mask>>shift))
+    }
+    return color.RGBA{R: channels[0], G: channels[1], B: channels[2], A: 0xFF}
+}
+
+// readBMPFile reads a BMP file
+func readBMPFile(fname string) ([]image.Image, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    img, err := DecodeBMP(file)
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
+
+// decodeBMP reads BMP data
+func decodeBMP(data []byte) ([]image.Image, error) {
+    img, err := DecodeBMP(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
@@ -0,0 +1,106 @@
+// Command capi exports the qrFile functionality with a plain C ABI so that non-Go programs (C, C++, Python via ctypes, ...)
+// can embed it without calling the command line tool. Build it as a shared library:
+//
+//     go build -buildmode=c-shared -o libqrfile.so ./capi
+//
+// All functions return NULL on success or an error message otherwise. Error messages and data buffers returned by the
+// library are allocated with malloc and have to be released using QrFileFree.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+    "errors"
+    "github.com/Schokomuesl1/qrFile"
+    "strings"
+    "unsafe"
+)
+
+// cError converts an error to a C string (NULL for nil)
+func cError(err error) *C.char {
+    if err == nil {
+        return nil
+    }
+    return C.CString(err.Error())
+}
+
+// QrFileEncode converts length bytes starting at data to a set of png images, written to workPath using the file name
+// prefix fnamePrefix.
+//
+//export QrFileEncode
+func QrFileEncode(data *C.char, length C.int, workPath *C.char, fnamePrefix *C.char) *C.char {
+    if data == nil || length < 0 {
+        return cError(errors.New("Invalid input buffer"))
+    }
+    qrf := qrFile.FromBytes(C.GoBytes(unsafe.Pointer(data), length))
+    elements, err := qrFile.GetElements(qrf.ToHexString())
+    if err != nil {
+        return cError(err)
+    }
+    return cError(elements.WritePNGs(C.GoString(workPath), C.GoString(fnamePrefix)))
+}
+
+// QrFileDecode restores data from a set of png images. files contains one file name or glob pattern per line. On
+// success *result points to the restored data and *resultLength holds its size.
+//
+//export QrFileDecode
+func QrFileDecode(files *C.char, result **C.char, resultLength *C.int) *C.char {
+    elements := new(qrFile.QrElements)
+    err := elements.FromPNGs(splitLines(C.GoString(files)))
+    if err != nil {
+        return cError(err)
+    }
+    return storeResult(elements, result, resultLength)
+}
+
+// QrFileDecodeStrings restores data from the text content of already scanned codes, one code per line. On success
+// *result points to the restored data and *resultLength holds its size.
+//
+//export QrFileDecodeStrings
+func QrFileDecodeStrings(chunks *C.char, result **C.char, resultLength *C.int) *C.char {
+    elements := new(qrFile.QrElements)
+    err := elements.ImportStrings(splitLines(C.GoString(chunks)))
+    if err != nil {
+        return cError(err)
+    }
+    return storeResult(elements, result, resultLength)
+}
+
+// QrFileFree releases memory returned by any of the QrFile functions
+//
+//export QrFileFree
+func QrFileFree(p unsafe.Pointer) {
+    C.free(p)
+}
+
+// storeResult restores the data of a set of elements into a malloc'ed buffer
+func storeResult(elements *qrFile.QrElements, result **C.char, resultLength *C.int) *C.char {
+    if result == nil || resultLength == nil {
+        return cError(errors.New("Invalid result pointer"))
+    }
+    qrf := qrFile.New()
+    err := elements.StoreData(qrf)
+    if err != nil {
+        return cError(err)
+    }
+    *result = (*C.char)(C.CBytes(qrf.Data))
+    *resultLength = C.int(len(qrf.Data))
+    return nil
+}
+
+// splitLines splits a newline-separated list, ignoring empty lines and carriage returns
+func splitLines(str string) []string {
+    lines := make([]string, 0)
+    for _, line := range strings.Split(str, "\n") {
+        line = strings.TrimSuffix(line, "\r")
+        if len(line) > 0 {
+            lines = append(lines, line)
+        }
+    }
+    return lines
+}
+
+func main() {}
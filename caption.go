@@ -0,0 +1,71 @@
+package qrFile
+
+import (
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "time"
+)
+
+// A caption strip below each code tells the printed codes of a stack apart without scanning them: the name of the
+// file on the first line, the number of the element, the date & the start of its hash (as in the manifest) on the
+// second, e.g. "chunk 12/87  2026-10-15  1a2b3c4d". It is drawn with the built-in font (see font.go), so names are
+// limited to printable ASCII; other characters are drawn as '?'.
+
+// Caption describes the strip printed below each code if QrElements.Caption is set
+type Caption struct {
+    Text string    // first line, e.g. the name of the file; the name of the original file (see FileInfo) if empty
+    Date time.Time // printed as YYYY-MM-DD, e.g. the day the set was created; not printed if zero
+}
+
+// captionDateFormat is the layout of the date of a caption
+const captionDateFormat = "2006-01-02"
+
+// captionText returns the first line of the caption; empty if there is neither a text nor a file name
+func (elem *QrElements) captionText() string {
+    if len(elem.Caption.Text) > 0 {
+        return elem.Caption.Text
+    }
+    if info := elem.FileInfo(); info != nil {
+        return info.Name
+    }
+    return ""
+}
+
+// captionDetails returns the second line of the caption of an element: its number & count, the date & its digest
+func (elem *QrElements) captionDetails(v *QrElement) string {
+    details := fmt.Sprintf("chunk %d/%d", v.Index+1, v.MaxIndex+1)
+    if !elem.Caption.Date.IsZero() {
+        details += "  " + elem.Caption.Date.Format(captionDateFormat)
+    }
+    return details + "  " + v.Hash()[:digestLength]
+}
+
+// addCaption returns a copy of the image of the element's code with its caption printed below, scaled to the width of
+// the code; a text too long for the code is shortened
+func (elem *QrElements) addCaption(v *QrElement, img image.Image) image.Image {
+    lines := []string{elem.captionDetails(v)}
+    if text := elem.captionText(); len(text) > 0 {
+        lines = []string{text, lines[0]}
+    }
+    bounds := img.Bounds()
+    // the details fill up to the width of the code, leaving a margin of two font pixels on each side
+    scale := bounds.Dx() / (textWidth(lines[len(lines)-1], 1) + 4)
+    if scale < 1 {
+        scale = 1
+    }
+    margin := 2 * scale
+    out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+len(lines)*glyphAdvanceY*scale+margin))
+    draw.Draw(out, out.Bounds(), image.White, image.ZP, draw.Src)
+    draw.Draw(out, image.Rect(0, 0, bounds.Dx(), bounds.Dy()), img, bounds.Min, draw.Src)
+    for i, line := range lines {
+        if max := (bounds.Dx() - 2*margin + scale) / (glyphAdvanceX * scale); len(line) > max && max > 3 {
+            line = line[:max-3] + "..."
+        } else if len(line) > max {
+            line = ""
+        }
+        drawText(out, (bounds.Dx()-textWidth(line, scale))/2, bounds.Dy()+i*glyphAdvanceY*scale, line, scale, color.Black)
+    }
+    return out
+}
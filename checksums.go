@@ -0,0 +1,152 @@
+package qrFile
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// With QrElements.Checksums, WritePNGsTo lists the SHA-256 of every file it writes (the images & the manifest) in a
+// checksums file next to them, in the format of sha256sum, so copies of a set (on a USB stick, in a cloud folder) are
+// checked with "sha256sum -c img_SHA256SUMS" or VerifyChecksums before they are relied on.
+
+// ChecksumsName is the name of the checksums file written by WritePNGsTo (preceded by the file name prefix)
+const ChecksumsName = "SHA256SUMS"
+
+// checksumStorage wraps a Storage & records the SHA-256 of every file written
+type checksumStorage struct {
+    storage Storage
+    mutex   sync.Mutex
+    sums    map[string]string
+}
+
+// checksumWriter hashes the data written to a file of a checksumStorage
+type checksumWriter struct {
+    io.WriteCloser
+    storage *checksumStorage
+    name    string
+    hash    hash.Hash
+}
+
+func newChecksumStorage(storage Storage) *checksumStorage {
+    return &checksumStorage{storage: storage, sums: make(map[string]string)}
+}
+
+// Create implements Storage
+func (s *checksumStorage) Create(name string) (io.WriteCloser, error) {
+    out, err := s.storage.Create(name)
+    if err != nil {
+        return nil, err
+    }
+    return &checksumWriter{WriteCloser: out, storage: s, name: name, hash: sha256.New()}, nil
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+    n, err := w.WriteCloser.Write(p)
+    w.hash.Write(p[:n])
+    return n, err
+}
+
+// Close closes the file & records its checksum once it is complete
+func (w *checksumWriter) Close() error {
+    err := w.WriteCloser.Close()
+    if err != nil {
+        return err
+    }
+    w.storage.mutex.Lock()
+    defer w.storage.mutex.Unlock()
+    w.storage.sums[w.name] = hex.EncodeToString(w.hash.Sum(nil))
+    return nil
+}
+
+// write writes the checksums of all files written so far to the file name, sorted by file name
+func (s *checksumStorage) write(name string) error {
+    s.mutex.Lock()
+    names := make([]string, 0, len(s.sums))
+    for fname := range s.sums {
+        names = append(names, fname)
+    }
+    sort.Strings(names)
+    var lines strings.Builder
+    for _, fname := range names {
+        // the format of sha256sum: the hash, a space, the mode (a space for text, * for binary) & the name
+        fmt.Fprintf(&lines, "%s  %s\n", s.sums[fname], fname)
+    }
+    s.mutex.Unlock()
+    out, err := s.storage.Create(name)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(out, lines.String())
+    if err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// ChecksumResult describes the check of a single file listed in a checksums file
+type ChecksumResult struct {
+    File string // path of the file
+    Err  error  // nil if the file is intact
+}
+
+// VerifyChecksums checks the files listed in the checksums file fname (as written by WritePNGsTo or sha256sum; paths
+// are relative to its directory) & returns the result of every file. An error is only returned if the checksums file
+// can not be read.
+func VerifyChecksums(fname string) ([]ChecksumResult, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    dir := filepath.Dir(fname)
+    results := make([]ChecksumResult, 0)
+    scanner := bufio.NewScanner(file)
+    for line := 1; scanner.Scan(); line++ {
+        text := strings.TrimRight(scanner.Text(), "\r")
+        if len(text) == 0 {
+            continue
+        }
+        fields := strings.SplitN(text, " ", 2)
+        if len(fields) != 2 || len(fields[0]) != 2*sha256.Size || len(fields[1]) < 2 {
+            return nil, errors.New(fmt.Sprintf("%s, line %d: malformed checksum line", fname, line))
+        }
+        // the name is preceded by a space (text mode) or an asterisk (binary mode)
+        name := fields[1][1:]
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        result := ChecksumResult{File: path}
+        sum, err := fileChecksum(path)
+        if err != nil {
+            result.Err = err
+        } else if sum != strings.ToLower(fields[0]) {
+            result.Err = checksumError("checksum mismatch")
+        }
+        results = append(results, result)
+    }
+    return results, scanner.Err()
+}
+
+// fileChecksum returns the hex encoded SHA-256 of a file
+func fileChecksum(fname string) (string, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+    digest := sha256.New()
+    _, err = io.Copy(digest, file)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(digest.Sum(nil)), nil
+}
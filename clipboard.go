@@ -0,0 +1,106 @@
+package qrFile
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "runtime"
+    "time"
+)
+
+// Codes scanned with a phone often end up in the clipboard of a computer, pasted by hand or synced by a companion app.
+// A ClipboardWatcher polls the clipboard & hands every new text to the caller, e.g. to add it to an Assembler, so a set
+// is received by scanning its codes one after another without any file in between. The clipboard is read with the
+// tools of the platform: pbpaste on macOS, PowerShell on Windows and wl-paste, xclip or xsel elsewhere.
+
+// ClipboardCommand is the command printing the text of the clipboard; if empty, the tool of the platform is used
+var ClipboardCommand []string
+
+// DefaultClipboardInterval is the time between two reads of a ClipboardWatcher
+const DefaultClipboardInterval = 300 * time.Millisecond
+
+// clipboardCommand returns ClipboardCommand or the command reading the clipboard on this platform
+func clipboardCommand() ([]string, error) {
+    if len(ClipboardCommand) > 0 {
+        return ClipboardCommand, nil
+    }
+    switch runtime.GOOS {
+    case "darwin":
+        return []string{"pbpaste"}, nil
+    case "windows":
+        return []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}, nil
+    }
+    candidates := [][]string{{"xclip", "-selection", "clipboard", "-out"}, {"xsel", "--clipboard", "--output"}}
+    if len(os.Getenv("WAYLAND_DISPLAY")) > 0 {
+        candidates = append([][]string{{"wl-paste", "--no-newline"}}, candidates...)
+    }
+    for _, candidate := range candidates {
+        if _, err := exec.LookPath(candidate[0]); err == nil {
+            return candidate, nil
+        }
+    }
+    return nil, errors.New("No clipboard tool found, install wl-paste, xclip or xsel (or set ClipboardCommand)")
+}
+
+// ReadClipboard returns the text of the system clipboard; an empty clipboard (or one holding no text) reads as ""
+func ReadClipboard() (string, error) {
+    command, err := clipboardCommand()
+    if err != nil {
+        return "", err
+    }
+    var stdout, stderr bytes.Buffer
+    cmd := exec.Command(command[0], command[1:]...)
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    err = cmd.Run()
+    if _, ok := err.(*exec.ExitError); ok {
+        // the tools fail if the clipboard holds no text
+        return "", nil
+    }
+    if err != nil {
+        return "", fmt.Errorf("Reading the clipboard with %s failed: %w", command[0], err)
+    }
+    return stdout.String(), nil
+}
+
+// ClipboardWatcher reports the texts copied to the clipboard
+type ClipboardWatcher struct {
+    Interval time.Duration          // time between two reads; DefaultClipboardInterval if 0
+    Read     func() (string, error) // reads the clipboard; ReadClipboard if nil
+}
+
+// Watch reads the clipboard every Interval & calls found with its text whenever it changed (including the text found
+// at the start), until found returns false or stop is closed. Copying the same text twice in a row is only reported
+// once. An error is returned if the clipboard can not be read.
+func (w ClipboardWatcher) Watch(stop <-chan struct{}, found func(text string) bool) error {
+    interval := w.Interval
+    if interval <= 0 {
+        interval = DefaultClipboardInterval
+    }
+    read := w.Read
+    if read == nil {
+        read = ReadClipboard
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    previous := ""
+    for {
+        text, err := read()
+        if err != nil {
+            return err
+        }
+        if text != previous {
+            previous = text
+            if len(text) > 0 && !found(text) {
+                return nil
+            }
+        }
+        select {
+        case <-stop:
+            return nil
+        case <-ticker.C:
+        }
+    }
+}
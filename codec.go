@@ -0,0 +1,189 @@
+package qrFile
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// The payload of an element is hex encoded by default, which doubles the size of the data in a code. Elements in plain
+// format may carry it base64 encoded instead (URL-safe alphabet, no padding), marked by a leading "~":
+//
+//     QRF v2 3/17 #1a2b3c4d *9c2e ~SGVsbG8sIFdvcmxkIQ
+//
+// so the same data needs about a third fewer codes. The codec only affects the text of the codes: elements keep their
+// payload as raw bytes in memory (QrElement.Payload) & their hash does not depend on the codec (see QrElement.Hash).
+// Raw bytes in the QR byte mode would be denser still, but decoders return the text of a code & mangle bytes which are
+// no valid UTF-8, so they are not offered. Sets in hex, including all legacy sets, are read as before.
+//
+// Base45 (RFC 9285, as in the QR codes of the EU digital COVID certificates) only uses the 45 characters of the QR
+// alphanumeric mode, which stores a character in 5.5 bits instead of 8. The payload is enclosed in "$", since its
+// alphabet includes the space (which separates the fields of the header & is trimmed at the end of a text):
+//
+//     QRF v2 3/17 #1a2b3c4d *9c2e $%69 VD92EX0.$
+//
+// The internal encoder (RscEncoder) stores the header in byte mode & the payload in alphanumeric mode, so a code of
+// the same size holds about a quarter more data than with base64 & almost twice as much as with hex. Other encoders &
+// symbologies have no such mode, so the codec requires QR codes rendered by the internal encoder.
+
+// PayloadCodec selects how the payload of an element in plain format is encoded in its code
+type PayloadCodec int
+
+// Payload codecs
+const (
+    CodecHex    PayloadCodec = iota // two hex digits per byte, readable by hand
+    CodecBase64                     // four base64 characters per three bytes, preceded by base64Prefix
+    CodecBase45                     // three base45 characters per two bytes in alphanumeric mode, enclosed in base45Delimiter
+)
+
+// base64Prefix marks a base64 encoded payload in the text of an element in plain format
+const base64Prefix = "~"
+
+// base45Delimiter encloses a base45 encoded payload in the text of an element in plain format
+const base45Delimiter = "$"
+
+// base45Alphabet holds the characters of base45, which are the ones of the QR alphanumeric mode
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// alphanumericOverhead is the amount of bytes taken by the mode indicator & the length of the alphanumeric segment of
+// a payload in base45
+const alphanumericOverhead = 3
+
+var codecNames = [...]string{"hex", "base64", "base45"}
+
+// String returns the name of the codec (hex, base64 or base45)
+func (c PayloadCodec) String() string {
+    if c < CodecHex || c > CodecBase45 {
+        return "PayloadCodec(" + strconv.Itoa(int(c)) + ")"
+    }
+    return codecNames[c]
+}
+
+// ParsePayloadCodec returns the codec for its name (hex, base64 or base45); an empty name selects CodecHex
+func ParsePayloadCodec(name string) (PayloadCodec, error) {
+    if len(name) == 0 {
+        return CodecHex, nil
+    }
+    for c := CodecHex; c <= CodecBase45; c++ {
+        if strings.EqualFold(name, c.String()) {
+            return c, nil
+        }
+    }
+    return CodecHex, errors.New(fmt.Sprintf("Unknown payload codec %s (hex, base64 or base45)", name))
+}
+
+// encode returns the text of a payload in the codec
+func (c PayloadCodec) encode(data []byte) string {
+    if len(data) == 0 {
+        return ""
+    }
+    if c == CodecHex {
+        return hex.EncodeToString(data)
+    }
+    if c == CodecBase45 {
+        return base45Delimiter + encodeBase45(data) + base45Delimiter
+    }
+    return base64Prefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+// encodeBase45 returns data base45 encoded as defined by RFC 9285
+func encodeBase45(data []byte) string {
+    text := make([]byte, 0, (len(data)+1)/2*3)
+    for i := 0; i < len(data); i += 2 {
+        if i+1 == len(data) {
+            n := int(data[i])
+            text = append(text, base45Alphabet[n%45], base45Alphabet[n/45])
+            break
+        }
+        n := int(data[i])<<8 | int(data[i+1])
+        text = append(text, base45Alphabet[n%45], base45Alphabet[n/45%45], base45Alphabet[n/(45*45)])
+    }
+    return string(text)
+}
+
+// decodeBase45 returns the data of a base45 encoded text
+func decodeBase45(text string) ([]byte, error) {
+    if len(text)%3 == 1 {
+        return nil, errors.New(fmt.Sprintf("invalid length %d", len(text)))
+    }
+    data := make([]byte, 0, len(text)/3*2+1)
+    for i := 0; i < len(text); i += 3 {
+        n, factor := 0, 1
+        for j := i; j < i+3 && j < len(text); j++ {
+            digit := strings.IndexByte(base45Alphabet, text[j])
+            if digit < 0 {
+                return nil, errors.New(fmt.Sprintf("invalid character %q at position %d", text[j], j))
+            }
+            n += digit * factor
+            factor *= 45
+        }
+        if i+2 == len(text) {
+            if n > 0xff {
+                return nil, errors.New(fmt.Sprintf("invalid group %q", text[i:]))
+            }
+            data = append(data, byte(n))
+        } else {
+            if n > 0xffff {
+                return nil, errors.New(fmt.Sprintf("invalid group %q", text[i:i+3]))
+            }
+            data = append(data, byte(n>>8), byte(n))
+        }
+    }
+    return data, nil
+}
+
+// decodePayload returns the data of the text of a payload & the codec it was encoded with
+func decodePayload(text string) ([]byte, PayloadCodec, error) {
+    if strings.HasPrefix(text, base45Delimiter) {
+        if len(text) < 2*len(base45Delimiter) || !strings.HasSuffix(text, base45Delimiter) {
+            return nil, CodecHex, parseError("payload", "%q lacks the closing %s", text, base45Delimiter)
+        }
+        data, err := decodeBase45(text[len(base45Delimiter) : len(text)-len(base45Delimiter)])
+        if err != nil || len(data) == 0 {
+            return nil, CodecHex, parseError("payload", "%q is not base45 encoded", text)
+        }
+        return data, CodecBase45, nil
+    }
+    if !strings.HasPrefix(text, base64Prefix) {
+        if err := checkPayload(text); err != nil {
+            return nil, CodecHex, err
+        }
+        data, _ := hex.DecodeString(text)
+        return data, CodecHex, nil
+    }
+    data, err := base64.RawURLEncoding.DecodeString(text[len(base64Prefix):])
+    if err != nil || len(data) == 0 {
+        return nil, CodecHex, parseError("payload", "%q is not base64 encoded", text)
+    }
+    return data, CodecBase64, nil
+}
+
+// maxPayload returns the length of the largest hex encoded payload whose text fits into the given amount of characters;
+// for CodecBase45, characters counts bytes of the capacity of a code, holding 16 characters in 11 bytes
+func (c PayloadCodec) maxPayload(characters uint64) uint64 {
+    if c == CodecHex {
+        return characters &^ 1
+    }
+    if c == CodecBase45 {
+        if characters <= alphanumericOverhead {
+            return 0
+        }
+        alphanumeric := (characters - alphanumericOverhead) * 16 / 11
+        if alphanumeric <= uint64(2*len(base45Delimiter)) {
+            return 0
+        }
+        groups := alphanumeric - uint64(2*len(base45Delimiter))
+        size := groups / 3 * 2
+        if groups%3 == 2 {
+            size++
+        }
+        return 2 * size
+    }
+    if characters <= uint64(len(base64Prefix)) {
+        return 0
+    }
+    return 2 * ((characters - uint64(len(base64Prefix))) * 3 / 4)
+}
@@ -0,0 +1,125 @@
+package qrFile
+
+import (
+    "encoding/base64"
+    "encoding/binary"
+    "strings"
+)
+
+// The compact format (VersionCompact) spends as little of each code as possible on the header: the text of a code is
+// compactPrefix followed by a binary record, base64 encoded (URL-safe alphabet, no padding):
+//
+//     uvarint version (3) | uvarint index | uvarint maximum index | uvarint payload length (bytes) | payload
+//
+// The header takes 4 to 9 bytes (up to 13 in sets of more than 2^21 elements) instead of the 60 characters of the legacy format, & the payload is stored as bytes
+// instead of hex, so a code of the same size carries more than half as much data again. Like the legacy format, it
+// carries no set ID; the plain format is the one to use for codes read by scanner apps.
+
+// compactPrefix starts the text of every element in compact format
+const compactPrefix = "QRF:"
+
+// compactHeaderReserve is the amount of bytes reserved for the binary header of an element in compact format; the
+// varints of the largest header of a set of up to 2^21 elements (a payload filling a code) take 9 bytes. Larger sets
+// take the bytes their index & count need beyond it from the payload, see compactSetMaxChunkSize.
+const compactHeaderReserve = 10
+
+// compactEncoding encodes the binary record of an element in compact format
+var compactEncoding = base64.RawURLEncoding
+
+// compactDataSize is the default chunk size (hex characters) of an element in compact format: as much as fits a text of
+// qrSize characters, so the codes are as large as the ones of the legacy format
+var compactDataSize = compactChunkSize(qrSize)
+
+// compactChunkSize returns the largest chunk size (hex characters) of an element in compact format whose text fits into
+// the given amount of characters
+func compactChunkSize(characters uint64) uint64 {
+    size := compactEncoding.DecodedLen(int(characters)-len(compactPrefix)) - compactHeaderReserve
+    if characters < uint64(len(compactPrefix)) || size <= 0 {
+        // codes of the smallest versions do not hold an element
+        return 0
+    }
+    return 2 * uint64(size)
+}
+
+// compactMaxChunkSize returns the largest chunk size of an element in compact format fitting a single code
+func compactMaxChunkSize(level Level) uint64 {
+    return compactChunkSize(uint64(SymbolCapacity(level)))
+}
+
+// compactSetMaxChunkSize returns the maximum chunk size max of a single code for a set of count elements, whose header
+// may take more bytes than reserved (see compactHeaderReserve)
+func compactSetMaxChunkSize(max uint64, count uint64) uint64 {
+    var buf [binary.MaxVarintLen64]byte
+    header := uint64(1 + 2*binary.PutUvarint(buf[:], count-1) + binary.PutUvarint(buf[:], max/2))
+    if header <= compactHeaderReserve {
+        return max
+    }
+    if max < 2*(header-compactHeaderReserve) {
+        return 0
+    }
+    return max - 2*(header-compactHeaderReserve)
+}
+
+// compactElement creates an element in compact format holding data
+func compactElement(index uint64, maxIndex uint64, data []byte) (QrElement, error) {
+    if 2*uint64(len(data)) > compactMaxChunkSize(LevelL) {
+        return QrElement{}, sizeError(2*uint64(len(data)), compactMaxChunkSize(LevelL), "Payload size exceeds maximum data size")
+    }
+    return QrElement{Version: VersionCompact, Index: index, MaxIndex: maxIndex, PayloadLength: 2 * uint64(len(data)), Payload: data}, nil
+}
+
+// compactString returns the text of an element in compact format
+func (elem *QrElement) compactString() string {
+    payload := elem.Payload
+    data := make([]byte, 0, 4*binary.MaxVarintLen64+len(payload))
+    var buf [binary.MaxVarintLen64]byte
+    for _, value := range []uint64{VersionCompact, elem.Index, elem.MaxIndex, uint64(len(payload))} {
+        data = append(data, buf[:binary.PutUvarint(buf[:], value)]...)
+    }
+    return compactPrefix + compactEncoding.EncodeToString(append(data, payload...))
+}
+
+// parseCompact parses the text of an element in compact format
+func (elem *QrElement) parseCompact(str string) error {
+    data, err := compactEncoding.DecodeString(strings.TrimPrefix(str, compactPrefix))
+    if err != nil {
+        return parseError("text", "not base64 encoded: %s", err)
+    }
+    header, rest, err := compactHeader(data)
+    if err != nil {
+        return err
+    }
+    if uint64(len(rest)) != header.PayloadLength/2 {
+        return parseError("payload", "expected %d bytes, got %d", header.PayloadLength/2, len(rest))
+    }
+    header.Payload = rest
+    *elem = header
+    return nil
+}
+
+// compactHeader reads the binary header of an element in compact format; it returns the element without payload & the
+// data following the header
+func compactHeader(data []byte) (QrElement, []byte, error) {
+    var values [4]uint64
+    names := [...]string{"version", "index", "count", "length"}
+    for i := range values {
+        value, n := binary.Uvarint(data)
+        if n <= 0 {
+            return QrElement{}, nil, parseError(names[i], "malformed varint")
+        }
+        values[i], data = value, data[n:]
+    }
+    if values[0] != VersionCompact {
+        return QrElement{}, nil, parseError("version", "unsupported version %d", values[0])
+    }
+    if values[2] >= maxElementCount {
+        return QrElement{}, nil, parseError("count", "%d out of range (1 to %d)", values[2]+1, maxElementCount)
+    }
+    if values[1] > values[2] {
+        return QrElement{}, nil, parseError("index", "%d exceeds the maximum index %d", values[1], values[2])
+    }
+    if values[3] > compactMaxChunkSize(LevelL)/2 {
+        return QrElement{}, nil, parseError("length", "%d exceeds the maximum of %d", values[3], compactMaxChunkSize(LevelL)/2)
+    }
+    return QrElement{Version: VersionCompact, Index: values[1], MaxIndex: values[2], PayloadLength: 2 * values[3]}, data, nil
+}
@@ -0,0 +1,212 @@
+package qrFile
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strings"
+)
+
+// Comparison is the result of comparing the data of a set with reference data (see Compare), e.g. the original file
+// in a backup validation drill
+type Comparison struct {
+    Identical       bool   `json:"identical"`
+    Length          uint64 `json:"length"` // length of the data of the set in bytes
+    ReferenceLength uint64 `json:"referenceLength"`
+    // Offset is the first differing byte if the data is not identical; if one is a prefix of the other, it is the
+    // length of the shorter one. Index is the element holding this byte (the last element if the set is too short).
+    Offset        uint64 `json:"offset,omitempty"`
+    Index         uint64 `json:"index,omitempty"`
+    Hash          string `json:"sha256"` // hex encoded SHA-256 of the data of the set
+    ReferenceHash string `json:"referenceSha256"`
+    hashOnly      bool   // compared by hash only, see CompareHash
+}
+
+// String describes the result of the comparison
+func (c *Comparison) String() string {
+    if c.Identical {
+        return fmt.Sprintf("identical: %d bytes, sha256 %s", c.Length, c.Hash)
+    }
+    if c.hashOnly {
+        return fmt.Sprintf("different: %d bytes, sha256 %s, expected sha256 %s", c.Length, c.Hash, c.ReferenceHash)
+    }
+    return fmt.Sprintf("different: first difference at offset %d (element %d); %d bytes (sha256 %s), reference %d bytes (sha256 %s)",
+        c.Offset, c.Index, c.Length, c.Hash, c.ReferenceLength, c.ReferenceHash)
+}
+
+// Compare restores the data of the set & compares it byte by byte with the reference data. The set is validated first.
+func (elem *QrElements) Compare(reference io.Reader) (*Comparison, error) {
+    err := elem.Validate()
+    if err != nil {
+        return nil, err
+    }
+    result := &Comparison{Identical: true}
+    hash, referenceHash := sha256.New(), sha256.New()
+    reference = io.TeeReader(reference, referenceHash)
+    for _, v := range elem.Elements {
+        data := v.Payload
+        hash.Write(data)
+        buffer := make([]byte, len(data))
+        n, err := io.ReadFull(reference, buffer)
+        if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+            return nil, err
+        }
+        if result.Identical && !bytes.Equal(data[:n], buffer[:n]) || result.Identical && n < len(data) {
+            result.Identical = false
+            result.Index = v.Index
+            result.Offset = result.Length
+            for i := 0; i < n && data[i] == buffer[i]; i++ {
+                result.Offset++
+            }
+        }
+        result.Length += uint64(len(data))
+        result.ReferenceLength += uint64(n)
+    }
+    rest, err := io.Copy(ioutil.Discard, reference)
+    if err != nil {
+        return nil, err
+    }
+    if rest > 0 && result.Identical {
+        result.Identical = false
+        result.Offset = result.Length
+        result.Index = elem.Elements[elem.Len()-1].Index
+    }
+    result.ReferenceLength += uint64(rest)
+    result.Hash = hex.EncodeToString(hash.Sum(nil))
+    result.ReferenceHash = hex.EncodeToString(referenceHash.Sum(nil))
+    return result, nil
+}
+
+// CompareHash restores the data of the set & compares its SHA-256 with the given hash (hex encoded), for references
+// which are not at hand anymore. Offset & Index of the result are not set.
+func (elem *QrElements) CompareHash(referenceHash string) (*Comparison, error) {
+    err := elem.Validate()
+    if err != nil {
+        return nil, err
+    }
+    data := New()
+    err = elem.StoreRawData(data)
+    if err != nil {
+        return nil, err
+    }
+    sum := sha256.Sum256(data.Data)
+    result := &Comparison{Length: uint64(len(data.Data)), Hash: hex.EncodeToString(sum[:]), ReferenceHash: strings.ToLower(referenceHash), hashOnly: true}
+    result.Identical = result.Hash == result.ReferenceHash
+    result.ReferenceLength = result.Length
+    return result, nil
+}
+
+// OriginalCheck is the result of checking the codes of a set against the original file (see Verify), e.g. before the
+// original is destroyed: every element read is compared with the bytes of the original it has to hold. Indices count
+// from 0, as in the manifest.
+type OriginalCheck struct {
+    SetID     string   `json:"set,omitempty"`
+    Total     uint64   `json:"total"`     // number of elements of the complete set
+    Matching  []uint64 `json:"matching"`  // elements read holding the bytes of the original
+    Differing []uint64 `json:"differing"` // elements read holding other bytes, e.g. a misread which passed all checks
+    // Missing lists the elements which were not read, including the ones reconstructed from parity elements
+    Missing []uint64 `json:"missing"`
+    // Unchecked lists the elements read which can not be compared on their own: all elements of a set whose data was
+    // transformed (see ApplyTransforms) & elements following a missing one in a set of elements of differing size
+    Unchecked []uint64          `json:"unchecked"`
+    Files     map[uint64]string `json:"files,omitempty"` // image each element was read from
+    // Unreadable describes the images no element could be read from (see DecodeReport.Failures)
+    Unreadable []string `json:"unreadable,omitempty"`
+    // Comparison compares the data restored from the set with the original, nil if the set can not be restored
+    Comparison *Comparison `json:"comparison,omitempty"`
+}
+
+// Passed reports whether every element was read, none differs from the original & the data restored is identical
+func (c *OriginalCheck) Passed() bool {
+    return len(c.Missing) == 0 && len(c.Differing) == 0 && c.Comparison != nil && c.Comparison.Identical
+}
+
+// checkOriginal compares the payload of each element read with the bytes of the original at its offset; the payload of
+// a parity element is computed from the original (see parity.go). Reconstructed elements count as missing.
+func (elem *QrElements) checkOriginal(original []byte) *OriginalCheck {
+    first := elem.Elements[0]
+    result := &OriginalCheck{SetID: first.SetID, Total: first.MaxIndex + 1, Matching: make([]uint64, 0),
+        Differing: make([]uint64, 0), Unchecked: make([]uint64, 0), Files: make(map[uint64]string)}
+    read := make(map[uint64]bool, elem.Len())
+    for _, v := range elem.Elements {
+        read[v.Index] = true
+    }
+    if elem.Report != nil {
+        for _, index := range elem.Report.Reconstructed {
+            read[index] = false
+        }
+        for _, symbol := range elem.Report.Symbols {
+            result.Files[symbol.Index] = symbol.File
+        }
+        for _, failure := range elem.Report.Failures {
+            message := failure.Err.Error()
+            if !strings.HasPrefix(message, failure.File) {
+                message = failure.File + ": " + message
+            }
+            result.Unreadable = append(result.Unreadable, message)
+        }
+    }
+    result.Missing = missingIndices(read, result.Total)
+    parity, hasParity := elem.parity()
+    // elements of the same size are at multiples of the chunk size, even behind a missing one
+    chunk, uniform := uint64(0), true
+    for _, v := range elem.Elements {
+        if size := uint64(len(v.Payload)); v.Index < first.MaxIndex && read[v.Index] {
+            uniform = uniform && (chunk == 0 || chunk == size)
+            chunk = size
+        }
+    }
+    var chunks [][]byte
+    if hasParity {
+        chunks = make([][]byte, parity.data)
+        for k := range chunks {
+            chunks[k] = originalRange(original, uint64(k)*parity.chunk, parity.length(uint64(k)))
+        }
+    }
+    transformed := len(elem.AppliedTransforms()) > 0
+    // the offset of an element is exact as long as no element before it is missing (the elements are sorted)
+    offset, contiguous := uint64(0), true
+    for i, v := range elem.Elements {
+        contiguous = contiguous && v.Index == uint64(i)
+        data := v.Payload
+        start := offset
+        offset += uint64(len(data))
+        if !read[v.Index] {
+            continue
+        }
+        var expected []byte
+        switch {
+        case transformed:
+            result.Unchecked = append(result.Unchecked, v.Index)
+            continue
+        case hasParity && v.Index >= parity.data:
+            expected = gfCombine(parity.row(v.Index), chunks, parity.chunk)
+        case hasParity:
+            expected = originalRange(original, v.Index*parity.chunk, parity.length(v.Index))
+        case contiguous:
+            expected = originalRange(original, start, uint64(len(data)))
+        case uniform && chunk > 0:
+            expected = originalRange(original, v.Index*chunk, uint64(len(data)))
+        default:
+            result.Unchecked = append(result.Unchecked, v.Index)
+            continue
+        }
+        if bytes.Equal(data, expected) {
+            result.Matching = append(result.Matching, v.Index)
+        } else {
+            result.Differing = append(result.Differing, v.Index)
+        }
+    }
+    return result
+}
+
+// originalRange returns length bytes of the original from offset, fewer if the original is shorter
+func originalRange(original []byte, offset uint64, length uint64) []byte {
+    if offset >= uint64(len(original)) {
+        return nil
+    }
+    return original[offset:min64(offset+length, uint64(len(original)))]
+}
@@ -0,0 +1,204 @@
+package qrFile
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+)
+
+// A .qrf container bundles a complete set in a single file. It is a zip archive holding
+//
+//     manifest.json       the set Manifest, including the file names below
+//     chunks/<index>.txt  the text of each element (see QrElement.AsString)
+//     images/<index>.png  optionally, the rendered image of each element
+//
+// Containers are a convenient intermediate artifact for mailing or archiving a set; images can always be rendered again
+// from the chunks.
+
+// containerManifest is the name of the manifest inside a container
+const containerManifest = ManifestName
+
+// Pack writes all elements to a .qrf container. If withImages is set, the rendered images are included as well (using
+// the SymbolEncoder set in Encoder).
+func (elem *QrElements) Pack(w io.Writer, withImages bool) error {
+    return elem.PackWithPassword(w, withImages, "")
+}
+
+// PackWithPassword works like Pack, but encrypts all files of the container with the password (see zipaes.go), so the
+// container can be mailed or stored in the cloud even if the data itself is not encrypted. An empty password writes
+// an unencrypted container.
+func (elem *QrElements) PackWithPassword(w io.Writer, withImages bool, password string) error {
+    archive := zip.NewWriter(w)
+    manifest := elem.Manifest()
+    for i, v := range elem.Elements {
+        manifest.Chunks[i].File = fmt.Sprintf("chunks/%d.txt", v.Index)
+        err := writeZipFile(archive, manifest.Chunks[i].File, zip.Deflate, []byte(v.AsString()), password)
+        if err != nil {
+            return err
+        }
+        if withImages {
+            manifest.Chunks[i].Image = fmt.Sprintf("images/%d.png", v.Index)
+            img, err := elem.render(&v)
+            if err != nil {
+                return err
+            }
+            var data bytes.Buffer
+            err = encodePNG(&data, img, elem.Rendering.dpi())
+            if err != nil {
+                return err
+            }
+            // png data is compressed already
+            err = writeZipFile(archive, manifest.Chunks[i].Image, zip.Store, data.Bytes(), password)
+            if err != nil {
+                return err
+            }
+        }
+    }
+    var data bytes.Buffer
+    err := manifest.Write(&data)
+    if err != nil {
+        return err
+    }
+    err = writeZipFile(archive, containerManifest, zip.Deflate, data.Bytes(), password)
+    if err != nil {
+        return err
+    }
+    return archive.Close()
+}
+
+// PackFile writes all elements to the .qrf container fname
+func (elem *QrElements) PackFile(fname string, withImages bool) error {
+    return elem.PackFileWithPassword(fname, withImages, "")
+}
+
+// PackFileWithPassword writes all elements to the .qrf container fname, encrypted with the password (see
+// PackWithPassword)
+func (elem *QrElements) PackFileWithPassword(fname string, withImages bool, password string) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.PackWithPassword(file, withImages, password)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// Unpack reads the elements stored in a .qrf container. Each element is checked against the hash stored in the manifest
+// and the set is checked for completeness. Encrypted containers return ErrPasswordRequired, see UnpackWithPassword.
+func Unpack(r io.ReaderAt, size int64) (*QrElements, error) {
+    return UnpackWithPassword(r, size, "")
+}
+
+// UnpackWithPassword works like Unpack, but decrypts the files of a container written by PackWithPassword
+func UnpackWithPassword(r io.ReaderAt, size int64, password string) (*QrElements, error) {
+    archive, err := zip.NewReader(r, size)
+    if err != nil {
+        return nil, err
+    }
+    files := make(map[string]*zip.File)
+    for _, f := range archive.File {
+        files[f.Name] = f
+    }
+    manifest := new(Manifest)
+    err = readZipJSON(files[containerManifest], manifest, password)
+    if err != nil {
+        return nil, err
+    }
+    elements := MakeQrElements(0)
+    for _, chunk := range manifest.Chunks {
+        text, err := readZipFileWithPassword(files[chunk.File], password)
+        if err != nil {
+            return nil, err
+        }
+        newElement := new(QrElement)
+        err = newElement.ParseString(string(text))
+        if err != nil {
+            return nil, fmt.Errorf("Unable to parse %s: %w", chunk.File, err)
+        }
+        if newElement.Index != chunk.Index || newElement.Hash() != chunk.Hash {
+            return nil, errors.New(fmt.Sprintf("%s does not match the manifest", chunk.File))
+        }
+        elements.Elements = append(elements.Elements, *newElement)
+        if len(chunk.Level) > 0 {
+            // restore the levels, so images rendered again are identical to the original ones
+            level, err := ParseLevel(chunk.Level)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %w", chunk.File, err)
+            }
+            if elements.Levels == nil {
+                elements.Levels = make(map[uint64]Level)
+            }
+            elements.Levels[chunk.Index] = level
+        }
+    }
+    elements.PGP = manifest.PGP
+    elements.Transforms = manifest.Transforms
+    elements.Fields = manifest.Fields
+    if manifest.File != nil {
+        if _, err := cleanFileName(manifest.File.Name); err != nil {
+            return nil, fmt.Errorf("Invalid file description in manifest: %w", err)
+        }
+    }
+    elements.File = manifest.File
+    elements.Meta = manifest.Metadata
+    elements.Signature = manifest.Signature
+    elements.SymbolVersion = manifest.SymbolVersion
+    elements.PadVersion = manifest.PadVersion
+    if uint64(elements.Len()) != manifest.Count {
+        return nil, errors.New(fmt.Sprintf("Container holds %d of %d elements.", elements.Len(), manifest.Count))
+    }
+    err = elements.Validate()
+    if err != nil {
+        return nil, err
+    }
+    return elements, nil
+}
+
+// UnpackFile reads the elements stored in the .qrf container fname
+func UnpackFile(fname string) (*QrElements, error) {
+    return UnpackFileWithPassword(fname, "")
+}
+
+// UnpackFileWithPassword reads the elements stored in the encrypted .qrf container fname, see UnpackWithPassword
+func UnpackFileWithPassword(fname string, password string) (*QrElements, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil {
+        return nil, err
+    }
+    return UnpackWithPassword(file, info.Size(), password)
+}
+
+// readZipFile returns the contents of a file inside a zip archive
+func readZipFile(f *zip.File) ([]byte, error) {
+    if f == nil {
+        return nil, errors.New("File missing in container.")
+    }
+    in, err := f.Open()
+    if err != nil {
+        return nil, err
+    }
+    defer in.Close()
+    return ioutil.ReadAll(in)
+}
+
+// readZipJSON decodes a json file inside a zip archive, decrypting it if needed
+func readZipJSON(f *zip.File, v interface{}, password string) error {
+    data, err := readZipFileWithPassword(f, password)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, v)
+}
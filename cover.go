@@ -0,0 +1,234 @@
+package qrFile
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "html"
+    "image"
+    "image/png"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// A cover sheet makes a paper backup self-auditing: it describes the set (set ID, format version, number of codes,
+// size, transforms) & lists the index & hash of every element, along with a code holding the manifest itself. Any
+// page can be checked against it by scanning its code & comparing the hash of the text (see QrElement.Hash); with
+// QrElements.Digest, the start of the hash printed above each code is compared by eye. Cover sheets are written as PDF
+// or HTML.
+
+// coverLevel is the error correction level of the code on a cover sheet
+const coverLevel = LevelM
+
+// coverSummaryPrefix starts the text of the code on a cover sheet if the manifest is too large for a single code
+const coverSummaryPrefix = "QRF MANIFEST "
+
+// CoverSheet holds the contents of a cover sheet
+type CoverSheet struct {
+    Title    string
+    Created  time.Time
+    Manifest *Manifest
+    CodeText string      // text of the code on the sheet, see CoverText
+    Code     image.Image // the rendered code
+}
+
+// CoverSheet prepares the cover sheet of a set; the code is rendered by the SymbolEncoder set in Encoder
+func (elem *QrElements) CoverSheet(title string) (*CoverSheet, error) {
+    if elem.Len() == 0 {
+        return nil, errors.New("No elements for a cover sheet")
+    }
+    return NewCoverSheet(elem.Manifest(), title, elem.encoder())
+}
+
+// NewCoverSheet prepares the cover sheet of the set described by manifest; the code is rendered by encoder
+// (DefaultEncoder if nil)
+func NewCoverSheet(manifest *Manifest, title string, encoder SymbolEncoder) (*CoverSheet, error) {
+    if encoder == nil {
+        encoder = DefaultEncoder
+    }
+    text, err := CoverText(manifest)
+    if err != nil {
+        return nil, err
+    }
+    code, err := encoder.Encode(text, coverLevel)
+    if err != nil {
+        return nil, err
+    }
+    return &CoverSheet{Title: title, Created: time.Now(), Manifest: manifest, CodeText: text, Code: code}, nil
+}
+
+// CoverText returns the text of the code on a cover sheet: the manifest as compact JSON, without file & image names
+// and levels. If that does not fit a single code, the summary "QRF MANIFEST <set ID> <version> <count> <length>
+// <chunks hash>" is used instead, so the table printed on the sheet can still be checked against it (see ChunksHash).
+func CoverText(manifest *Manifest) (string, error) {
+    compact := *manifest
+    compact.Chunks = make([]ManifestChunk, len(manifest.Chunks))
+    for i, chunk := range manifest.Chunks {
+        compact.Chunks[i] = ManifestChunk{Index: chunk.Index, Hash: chunk.Hash}
+    }
+    data, err := json.Marshal(&compact)
+    if err != nil {
+        return "", err
+    }
+    if len(data) <= SymbolCapacity(coverLevel) {
+        return string(data), nil
+    }
+    setID := manifest.SetID
+    if len(setID) == 0 {
+        setID = "-"
+    }
+    return fmt.Sprintf("%s%s %d %d %d %s", coverSummaryPrefix, setID, manifest.Version, manifest.Count, manifest.Length, manifest.ChunksHash()), nil
+}
+
+// ChunksHash returns the hex encoded SHA-256 of the hashes of all chunks, one line "<index> <hash>" each in the order
+// of the manifest
+func (m *Manifest) ChunksHash() string {
+    digest := sha256.New()
+    for _, chunk := range m.Chunks {
+        fmt.Fprintf(digest, "%d %s\n", chunk.Index, chunk.Hash)
+    }
+    return hex.EncodeToString(digest.Sum(nil))
+}
+
+// details returns the lines describing the set, printed above the table along with the chunks hash
+func (c *CoverSheet) details() []string {
+    m := c.Manifest
+    lines := make([]string, 0)
+    if len(m.SetID) > 0 {
+        lines = append(lines, "Set ID: "+m.SetID)
+    }
+    lines = append(lines, fmt.Sprintf("Format version: %d", m.Version), fmt.Sprintf("Codes: %d", m.Count),
+        fmt.Sprintf("Data: %d bytes", m.Length/2))
+    if len(m.Files) > 0 {
+        lines = append(lines, fmt.Sprintf("Files: %d (tar archive)", len(m.Files)))
+    }
+    if len(m.Transforms) > 0 {
+        names := make([]string, len(m.Transforms))
+        for i, transform := range m.Transforms {
+            names[i] = transform.Name
+        }
+        lines = append(lines, "Transforms: "+strings.Join(names, ", "))
+    } else if m.PGP != nil {
+        lines = append(lines, "Transforms: pgp")
+    }
+    return append(lines, "Created: "+c.Created.Format("2006-01-02 15:04"))
+}
+
+// WriteHTML writes the cover sheet as a standalone HTML page (the code is embedded as data URI)
+func (c *CoverSheet) WriteHTML(w io.Writer) error {
+    var code bytes.Buffer
+    err := png.Encode(&code, c.Code)
+    if err != nil {
+        return err
+    }
+    var page bytes.Buffer
+    title := html.EscapeString(c.Title)
+    fmt.Fprintf(&page, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+    page.WriteString("<style>\nbody { font-family: sans-serif; }\nimg { float: right; width: 6cm; image-rendering: pixelated; }\n" +
+        "table { border-collapse: collapse; font-family: monospace; }\ntd, th { border: 1px solid #888; padding: 1px 6px; }\n</style>\n</head>\n<body>\n")
+    fmt.Fprintf(&page, "<img src=\"data:image/png;base64,%s\" alt=\"manifest\">\n<h1>%s</h1>\n<p>\n", base64.StdEncoding.EncodeToString(code.Bytes()), title)
+    for _, line := range c.details() {
+        fmt.Fprintf(&page, "%s<br>\n", html.EscapeString(line))
+    }
+    fmt.Fprintf(&page, "Chunks hash: <code>%s</code>\n", c.Manifest.ChunksHash())
+    page.WriteString("</p>\n<table>\n<tr><th>Index</th><th>SHA-256</th><th>Image</th><th>Checked</th></tr>\n")
+    for _, chunk := range c.Manifest.Chunks {
+        // the start of the hash is printed above the codes with QrElements.Digest
+        fmt.Fprintf(&page, "<tr><td>%d</td><td><b>%s</b>%s</td><td>%s</td><td></td></tr>\n", chunk.Index, coverDigest(chunk.Hash),
+            chunk.Hash[len(coverDigest(chunk.Hash)):], html.EscapeString(chunk.Image))
+    }
+    page.WriteString("</table>\n</body>\n</html>\n")
+    _, err = w.Write(page.Bytes())
+    return err
+}
+
+// coverDigest returns the start of a hash as printed above the codes (see QrElement.Digest)
+func coverDigest(hash string) string {
+    if len(hash) < digestLength {
+        return hash
+    }
+    return hash[:digestLength]
+}
+
+// Layout of the PDF cover sheet, in points
+const (
+    coverMargin      = 50
+    coverCodeSize    = 170
+    coverTitleSize   = 16
+    coverTextSize    = 10
+    coverTableSize   = 9
+    coverLineSpacing = 1.3
+)
+
+// WritePDF writes the cover sheet as PDF (A4); the table continues on further pages as needed
+func (c *CoverSheet) WritePDF(w io.Writer) error {
+    doc := new(pdfDocument)
+    page := doc.newPage()
+    top := float64(pdfPageHeight - coverMargin)
+    page.image(pdfPageWidth-coverMargin-coverCodeSize, top-coverCodeSize, coverCodeSize, c.Code)
+    y := top - coverTitleSize
+    page.text(coverMargin, y, pdfFontBold, coverTitleSize, c.Title)
+    y -= coverTitleSize * coverLineSpacing
+    for _, line := range c.details() {
+        y -= coverTextSize * coverLineSpacing
+        page.text(coverMargin, y, pdfFontRegular, coverTextSize, line)
+    }
+    // the hash is too wide for a line next to the code with its label
+    y -= coverTextSize * coverLineSpacing
+    page.text(coverMargin, y, pdfFontRegular, coverTextSize, "Chunks hash:")
+    y -= coverTableSize * coverLineSpacing
+    page.text(coverMargin, y, pdfFontMono, coverTableSize-1, c.Manifest.ChunksHash())
+    if bottom := top - coverCodeSize; y > bottom {
+        y = bottom
+    }
+    y -= coverTextSize * coverLineSpacing
+
+    // the table: index, hash & a box to tick off each page
+    lineHeight := coverTableSize * coverLineSpacing
+    header := fmt.Sprintf("%6s  %-64s", "Index", "SHA-256")
+    boxX := coverMargin + float64(len(header)+2)*pdfMonoAdvance*coverTableSize
+    pages := 1
+    for i := 0; i < len(c.Manifest.Chunks); i++ {
+        if i == 0 || y < coverMargin+lineHeight {
+            if i > 0 {
+                page = doc.newPage()
+                pages++
+                y = top - coverTextSize
+                page.text(coverMargin, y, pdfFontRegular, coverTextSize, fmt.Sprintf("%s (continued, page %d)", c.Title, pages))
+                y -= coverTextSize * coverLineSpacing
+            }
+            y -= lineHeight
+            page.text(coverMargin, y, pdfFontBold, coverTableSize, header)
+        }
+        chunk := c.Manifest.Chunks[i]
+        y -= lineHeight
+        page.text(coverMargin, y, pdfFontMono, coverTableSize, fmt.Sprintf("%6d  %s", chunk.Index, chunk.Hash))
+        page.rect(boxX, y-1, coverTableSize-1, coverTableSize-1)
+    }
+    return doc.write(w)
+}
+
+// WriteFile writes the cover sheet to fname, as PDF if the name ends with .pdf & as HTML otherwise
+func (c *CoverSheet) WriteFile(fname string) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    if strings.EqualFold(filepath.Ext(fname), ".pdf") {
+        err = c.WritePDF(file)
+    } else {
+        err = c.WriteHTML(file)
+    }
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,75 @@
+package qrFile
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "log/slog"
+    "sync/atomic"
+)
+
+// The messages of the package go to a log/slog logger & the steps of writing & reading a set to a trace: those of a
+// set to its Logger & Trace (see QrElements), the others & those of sets without their own to the defaults set by
+// SetLogger & SetTrace.
+
+// defaultLogger & defaultTrace hold the defaults set by SetLogger & SetTrace
+var defaultLogger atomic.Pointer[slog.Logger]
+var defaultTrace atomic.Pointer[log.Logger]
+
+// SetLogger sets the default logger receiving the messages of the package: problems which do not stop the operation
+// (e.g. images without elements, elements outvoted, archive entries skipped) at slog.LevelWarn, notes (e.g. images read
+// after a retry, waiting for a lock) at slog.LevelInfo & the steps traced (see SetTrace) at slog.LevelDebug unless a
+// trace is set. The package is silent if nil (the default); the level logged is controlled by the handler, e.g.
+// slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})). Safe for concurrent use.
+func SetLogger(logger *slog.Logger) {
+    defaultLogger.Store(logger)
+}
+
+// SetTrace sets the default trace receiving a line for each step of writing & reading a set, e.g.
+// log.New(os.Stderr, "trace: ", 0); nil (the default) disables it. Safe for concurrent use.
+func SetTrace(trace *log.Logger) {
+    defaultTrace.Store(trace)
+}
+
+// trace logs a step to the default trace, if set, or to the default logger at slog.LevelDebug
+func trace(format string, args ...interface{}) {
+    traceTo(nil, nil, format, args...)
+}
+
+// logf logs a message to the default logger, if set & enabled for level
+func logf(level slog.Level, format string, args ...interface{}) {
+    logTo(nil, level, format, args...)
+}
+
+// trace logs a step of the set like the function trace, to its Trace & Logger if set
+func (elem *QrElements) trace(format string, args ...interface{}) {
+    traceTo(elem.Trace, elem.Logger, format, args...)
+}
+
+// logf logs a message of the set like the function logf, to its Logger if set
+func (elem *QrElements) logf(level slog.Level, format string, args ...interface{}) {
+    logTo(elem.Logger, level, format, args...)
+}
+
+// traceTo logs a step to t, if set, or to logger at slog.LevelDebug, using the defaults in place of nil
+func traceTo(t *log.Logger, logger *slog.Logger, format string, args ...interface{}) {
+    if t == nil {
+        t = defaultTrace.Load()
+    }
+    if t != nil {
+        t.Printf(format, args...)
+        return
+    }
+    logTo(logger, slog.LevelDebug, format, args...)
+}
+
+// logTo logs a message to logger (the default logger if nil), if set & enabled for level
+func logTo(logger *slog.Logger, level slog.Level, format string, args ...interface{}) {
+    if logger == nil {
+        logger = defaultLogger.Load()
+    }
+    if logger == nil || !logger.Enabled(context.Background(), level) {
+        return
+    }
+    logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
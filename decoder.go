@@ -0,0 +1,139 @@
+package qrFile
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// Decoder reads the text of all codes found in an image. FromPNGs uses the decoder set in QrElements.Decoder; if none is
+// set, the native decoder with zbarimg as fallback (see ZbarFallback).
+type Decoder interface {
+    DecodeImage(img image.Image) ([]string, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface, so a decoder backend (e.g. a call of a cloud service) is
+// registered without declaring a type for it
+type DecoderFunc func(img image.Image) ([]string, error)
+
+func (f DecoderFunc) DecodeImage(img image.Image) ([]string, error) {
+    return f(img)
+}
+
+// Symbol is a code found in an image, with the details reported by the decoder
+type Symbol struct {
+    Text        string
+    Quality     int       // confidence of the decoder, higher is better (zbar: the quality of the symbol); 0 if not reported
+    Version     int       // QR version of the code (1-40, i.e. its size); 0 if not reported
+    Orientation string    // orientation of the code in the image (zbar: UP, RIGHT, DOWN or LEFT); empty if not reported
+    Symbology   Symbology // kind of the code; SymbologyQR if not reported
+}
+
+// DetailedDecoder is implemented by decoders reporting details of the codes found (e.g. a confidence); they are
+// recorded in the DecodeReport, so borderline prints can be found before they fail
+type DetailedDecoder interface {
+    Decoder
+    DecodeSymbols(img image.Image) ([]Symbol, error)
+}
+
+// ContextDecoder is implemented by decoders running an external process (e.g. zbarimg), so decoding an image can be
+// aborted (see FromPNGsContext)
+type ContextDecoder interface {
+    DetailedDecoder
+    DecodeSymbolsContext(ctx context.Context, img image.Image) ([]Symbol, error)
+}
+
+// decodeSymbols reads the codes of an image with a decoder, with details if it reports them (see DetailedDecoder).
+// Returns ctx.Err() if ctx is canceled before or, for a ContextDecoder, while the image is decoded.
+func decodeSymbols(ctx context.Context, decoder Decoder, img image.Image) ([]Symbol, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if cancelable, ok := decoder.(ContextDecoder); ok {
+        return cancelable.DecodeSymbolsContext(ctx, img)
+    }
+    if detailed, ok := decoder.(DetailedDecoder); ok {
+        return detailed.DecodeSymbols(img)
+    }
+    texts, err := decoder.DecodeImage(img)
+    if err != nil {
+        return nil, err
+    }
+    symbols := make([]Symbol, len(texts))
+    for i, text := range texts {
+        symbols[i].Text = text
+    }
+    return symbols, nil
+}
+
+// symbolTexts returns the texts of the symbols
+func symbolTexts(symbols []Symbol) []string {
+    texts := make([]string, len(symbols))
+    for i, symbol := range symbols {
+        texts[i] = symbol.Text
+    }
+    return texts
+}
+
+// DecoderProbe is implemented by decoders depending on something outside of the program (a binary, a system framework),
+// so their availability can be checked before any image is read
+type DecoderProbe interface {
+    Probe() error
+}
+
+// CheckDecoder checks that a decoder is available: decoders are asked if they implement DecoderProbe (e.g. ZbarDecoder,
+// which needs zbarimg installed & reading QR codes); the default decoder (nil) is always available. The error explains
+// what is missing & how to fix it. FromPNGs checks the decoder before reading any image.
+func CheckDecoder(decoder Decoder) error {
+    if probe, ok := decoder.(DecoderProbe); ok {
+        return probe.Probe()
+    }
+    return nil
+}
+
+// registered decoders, see RegisterDecoder
+var decoders = make(map[string]Decoder)
+var decodersMutex sync.Mutex
+
+// RegisterDecoder makes a decoder available under the given name, so it can be selected by name (e.g. from a command
+// line flag). Decoders only available in some builds register themselves (e.g. "vision" on macOS).
+func RegisterDecoder(name string, decoder Decoder) {
+    decodersMutex.Lock()
+    defer decodersMutex.Unlock()
+    decoders[name] = decoder
+}
+
+// GetDecoder returns the decoder registered under the given name. Names starting with "exec:" stand for a
+// CommandDecoder running the command following the prefix, e.g. "exec:my-reader --all".
+func GetDecoder(name string) (Decoder, error) {
+    if strings.HasPrefix(name, commandDecoderPrefix) {
+        decoder, err := NewCommandDecoder(strings.TrimPrefix(name, commandDecoderPrefix))
+        if err != nil {
+            return nil, err
+        }
+        return decoder, nil
+    }
+    decodersMutex.Lock()
+    defer decodersMutex.Unlock()
+    decoder, ok := decoders[name]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unknown decoder %s", name))
+    }
+    return decoder, nil
+}
+
+// DecoderNames returns the names of all registered decoders in alphabetical order
+func DecoderNames() []string {
+    decodersMutex.Lock()
+    defer decodersMutex.Unlock()
+    names := make([]string, 0, len(decoders))
+    for name := range decoders {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
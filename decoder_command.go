@@ -0,0 +1,173 @@
+package qrFile
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// Any program reading codes can serve as decoder without changing qrFile, e.g. a wrapper around a cloud OCR service or a
+// build of libzbar with other settings: CommandDecoder stores each image in a temporary png file, calls the program for
+// it & takes every line it prints as the text of a code. Such decoders are selected by name as "exec:" followed by the
+// command (see GetDecoder), e.g. --decoder "exec:my-reader --all".
+
+// commandDecoderPrefix starts the names of decoders running a command, see GetDecoder
+const commandDecoderPrefix = "exec:"
+
+// commandImagePlaceholder is replaced by the name of the image file in the arguments of a CommandDecoder
+const commandImagePlaceholder = "{}"
+
+// CommandDecoder reads codes by running an external program for each image. The program gets the name of a png file
+// (in place of the argument {}, or appended to Args) & prints the text of each code found on a line of its own; texts
+// holding line breaks are printed as quoted Go string literals ("QRF...\n..."). Empty lines are ignored; no output means
+// no code was found. A program exiting with an error fails the image, unless it printed codes.
+type CommandDecoder struct {
+    Command string   // program to run, looked up like the external tools (see ToolDirectory)
+    Args    []string // arguments given to the program
+}
+
+// NewCommandDecoder creates a CommandDecoder from a command line; the words are split at white space, except inside
+// double quotes, e.g. "C:\Program Files\Reader\reader.exe" --all (backslashes are kept, they separate paths on Windows)
+func NewCommandDecoder(commandLine string) (CommandDecoder, error) {
+    words, err := splitCommandLine(commandLine)
+    if err != nil {
+        return CommandDecoder{}, err
+    }
+    if len(words) == 0 {
+        return CommandDecoder{}, errors.New("No decoder command given")
+    }
+    return CommandDecoder{Command: words[0], Args: words[1:]}, nil
+}
+
+// splitCommandLine splits a command line into words at white space outside of double quotes; the quotes are removed
+func splitCommandLine(commandLine string) ([]string, error) {
+    words := make([]string, 0)
+    var word strings.Builder
+    inWord, quoted := false, false
+    for _, c := range commandLine {
+        switch {
+        case c == '"':
+            quoted, inWord = !quoted, true
+        case unicode.IsSpace(c) && !quoted:
+            if inWord {
+                words = append(words, word.String())
+                word.Reset()
+            }
+            inWord = false
+        default:
+            word.WriteRune(c)
+            inWord = true
+        }
+    }
+    if quoted {
+        return nil, errors.New(fmt.Sprintf("Unbalanced quotes in decoder command %s", commandLine))
+    }
+    if inWord {
+        words = append(words, word.String())
+    }
+    return words, nil
+}
+
+func (d CommandDecoder) DecodeImage(img image.Image) ([]string, error) {
+    symbols, err := d.DecodeSymbolsContext(context.Background(), img)
+    return symbolTexts(symbols), err
+}
+
+func (d CommandDecoder) DecodeSymbolsContext(parent context.Context, img image.Image) ([]Symbol, error) {
+    err := d.Probe()
+    if err != nil {
+        return nil, err
+    }
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileImage*.png")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    err = png.Encode(tempfile, img)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var result, stderr bytes.Buffer
+    ctx, cancel := decodeContext(parent)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, toolPath(d.Command), d.arguments(tempfile.Name())...)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err = cmd.Run()
+    if parent.Err() != nil {
+        return nil, parent.Err()
+    }
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, &TimeoutError{Command: d.Command, File: tempfile.Name(), Timeout: DecodeTimeout}
+    }
+    symbols, parseErr := parseCommandOutput(&result)
+    if err != nil && len(symbols) == 0 {
+        if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+            return nil, errors.New(fmt.Sprintf("%s: %s (%s)", d.Command, err, message))
+        }
+        return nil, fmt.Errorf("%s: %w", d.Command, err)
+    }
+    return symbols, parseErr
+}
+
+// Probe checks that the program is installed
+func (d CommandDecoder) Probe() error {
+    if _, err := lookTool(d.Command); err != nil {
+        return fmt.Errorf("Decoder command %s not found: %w", d.Command, err)
+    }
+    return nil
+}
+
+// arguments returns the arguments of the program for an image file
+func (d CommandDecoder) arguments(fname string) []string {
+    args := make([]string, 0, len(d.Args)+1)
+    replaced := false
+    for _, arg := range d.Args {
+        if arg == commandImagePlaceholder {
+            arg, replaced = fname, true
+        }
+        args = append(args, arg)
+    }
+    if !replaced {
+        args = append(args, fname)
+    }
+    return args
+}
+
+// parseCommandOutput returns the codes printed by the program of a CommandDecoder, one per line
+func parseCommandOutput(r io.Reader) ([]Symbol, error) {
+    symbols := make([]Symbol, 0)
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(nil, 1<<20)
+    for scanner.Scan() {
+        line := strings.TrimRight(scanner.Text(), "\r")
+        if len(strings.TrimSpace(line)) == 0 {
+            continue
+        }
+        if strings.HasPrefix(line, "\"") {
+            if unquoted, err := strconv.Unquote(line); err == nil {
+                line = unquoted
+            }
+        }
+        symbols = append(symbols, Symbol{Text: line})
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("Unable to parse the output of the decoder command: %w", err)
+    }
+    return symbols, nil
+}
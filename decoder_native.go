@@ -0,0 +1,135 @@
+package qrFile
+
+import (
+    "context"
+    "github.com/makiuchi-d/gozxing"
+    "github.com/makiuchi-d/gozxing/aztec"
+    "github.com/makiuchi-d/gozxing/datamatrix"
+    "github.com/makiuchi-d/gozxing/multi/qrcode/detector"
+    "github.com/makiuchi-d/gozxing/qrcode"
+    "image"
+)
+
+// Images are read in pure Go by default, using gozxing (a port of ZXing), so the package works without any external
+// tool. zbarimg is only an optional fallback: if the native decoder finds no code in an image & zbar is installed, the
+// image is handed to zbarimg (see ZbarFallback), which copes better with some photos. Both decoders can be selected
+// explicitly as well, under the names "native" & "zbar" (see RegisterDecoder); "zxing" reads all symbologies, including
+// PDF417 (see symbology.go).
+
+// ZbarFallback hands images the native decoder finds no code in to zbarimg, if it is installed; it only applies if no
+// Decoder is set
+var ZbarFallback = true
+
+func init() {
+    RegisterDecoder("native", NativeDecoder{})
+    RegisterDecoder("zbar", ZbarDecoder{})
+    RegisterDecoder("zxing", ZXingDecoder{})
+}
+
+// NativeDecoder reads QR codes in pure Go, without any external tool. Images without a QR code are searched for a Data
+// Matrix or an Aztec code (one per image, see symbology.go).
+type NativeDecoder struct{}
+
+func (d NativeDecoder) DecodeImage(img image.Image) ([]string, error) {
+    symbols, err := d.DecodeSymbols(img)
+    return symbolTexts(symbols), err
+}
+
+// DecodeSymbols reads all codes of the image; an image without any code results in an empty list
+func (NativeDecoder) DecodeSymbols(img image.Image) ([]Symbol, error) {
+    bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+    if err != nil {
+        return nil, err
+    }
+    hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+    texts, err := decodeQRCodes(bitmap, hints)
+    if _, ok := err.(gozxing.ReaderException); err != nil && !ok {
+        return nil, err
+    }
+    if len(texts) == 0 {
+        // the detector for several codes misses some codes the detector for a single code finds
+        result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+        if _, ok := err.(gozxing.ReaderException); err != nil && !ok {
+            return nil, err
+        }
+        if err == nil {
+            texts = append(texts, result.GetText())
+        }
+    }
+    if len(texts) == 0 {
+        for symbology, reader := range otherReaders() {
+            result, err := reader.Decode(bitmap, hints)
+            if _, ok := err.(gozxing.ReaderException); err != nil && !ok {
+                return nil, err
+            }
+            if err == nil {
+                return []Symbol{{Text: result.GetText(), Symbology: symbology}}, nil
+            }
+        }
+    }
+    symbols := make([]Symbol, len(texts))
+    for i, text := range texts {
+        symbols[i].Text = text
+    }
+    return symbols, nil
+}
+
+// decodeQRCodes reads all QR codes of the bitmap like the multi reader of gozxing, but keeps the codes of a Structured
+// Append sequence apart instead of joining their texts, since each of them holds an element of its own (see
+// structured.go)
+func decodeQRCodes(bitmap *gozxing.BinaryBitmap, hints map[gozxing.DecodeHintType]interface{}) ([]string, error) {
+    matrix, err := bitmap.GetBlackMatrix()
+    if err != nil {
+        return nil, err
+    }
+    detected, err := detector.NewMultiDetector(matrix).DetectMulti(hints)
+    if err != nil {
+        return nil, err
+    }
+    reader := qrcode.NewQRCodeReader().(*qrcode.QRCodeReader)
+    texts := make([]string, 0, len(detected))
+    for _, result := range detected {
+        decoded, err := reader.GetDecoder().Decode(result.GetBits(), hints)
+        if _, ok := err.(gozxing.ReaderException); err != nil && !ok {
+            return nil, err
+        }
+        if err == nil {
+            texts = append(texts, decoded.GetText())
+        }
+    }
+    return texts, nil
+}
+
+// otherReaders returns the readers of the symbologies other than QR read by NativeDecoder
+func otherReaders() map[Symbology]gozxing.Reader {
+    return map[Symbology]gozxing.Reader{SymbologyDataMatrix: datamatrix.NewDataMatrixReader(), SymbologyAztec: aztec.NewAztecReader()}
+}
+
+// defaultDecoder reads images if no Decoder is set: using NativeDecoder & zbarimg as fallback (see ZbarFallback)
+type defaultDecoder struct{}
+
+func (d defaultDecoder) DecodeImage(img image.Image) ([]string, error) {
+    symbols, err := d.DecodeSymbols(img)
+    return symbolTexts(symbols), err
+}
+
+func (d defaultDecoder) DecodeSymbols(img image.Image) ([]Symbol, error) {
+    return d.DecodeSymbolsContext(context.Background(), img)
+}
+
+func (defaultDecoder) DecodeSymbolsContext(ctx context.Context, img image.Image) ([]Symbol, error) {
+    symbols, err := NativeDecoder{}.DecodeSymbols(img)
+    if (err != nil || len(symbols) == 0) && ZbarFallback && ctx.Err() == nil && probeZbar() == nil {
+        trace("native decoder found no code (%v), trying zbarimg", err)
+        return scanImage(ctx, img)
+    }
+    return symbols, err
+}
+
+// decoderOrDefault returns decoder, or the default decoder if it is nil
+func decoderOrDefault(decoder Decoder) Decoder {
+    if decoder == nil {
+        return defaultDecoder{}
+    }
+    return decoder
+}
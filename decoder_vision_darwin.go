@@ -0,0 +1,104 @@
+//go:build darwin && cgo && vision
+// +build darwin,cgo,vision
+
+package qrFile
+
+/*
+#cgo CFLAGS: -x objective-c -fobjc-arc
+#cgo LDFLAGS: -framework Foundation -framework CoreGraphics -framework Vision
+#include <stdlib.h>
+#include <string.h>
+#import <Foundation/Foundation.h>
+#import <CoreGraphics/CoreGraphics.h>
+#import <Vision/Vision.h>
+
+// qrf_vision_decode detects QR codes in an RGBA buffer. The payloads are returned in a malloc'ed buffer, each prefixed
+// by its length (uint32, native byte order); *length holds the buffer size. On failure, NULL is returned and *err points
+// to a malloc'ed message.
+static char* qrf_vision_decode(const unsigned char* pixels, int width, int height, int stride, int* length, char** err) {
+    @autoreleasepool {
+        CGColorSpaceRef colorSpace = CGColorSpaceCreateDeviceRGB();
+        CGContextRef context = CGBitmapContextCreate((void*)pixels, width, height, 8, stride, colorSpace,
+            kCGImageAlphaPremultipliedLast | kCGBitmapByteOrder32Big);
+        CGColorSpaceRelease(colorSpace);
+        if (context == NULL) {
+            *err = strdup("Unable to create bitmap context");
+            return NULL;
+        }
+        CGImageRef image = CGBitmapContextCreateImage(context);
+        CGContextRelease(context);
+        VNDetectBarcodesRequest* request = [[VNDetectBarcodesRequest alloc] init];
+        request.symbologies = @[VNBarcodeSymbologyQR];
+        VNImageRequestHandler* handler = [[VNImageRequestHandler alloc] initWithCGImage:image options:@{}];
+        NSError* error = nil;
+        BOOL ok = [handler performRequests:@[request] error:&error];
+        CGImageRelease(image);
+        if (!ok) {
+            *err = strdup(error.localizedDescription.UTF8String);
+            return NULL;
+        }
+        NSMutableData* result = [NSMutableData data];
+        for (VNBarcodeObservation* observation in request.results) {
+            NSString* payload = observation.payloadStringValue;
+            if (payload == nil) {
+                continue;
+            }
+            NSData* data = [payload dataUsingEncoding:NSUTF8StringEncoding];
+            uint32_t size = (uint32_t)data.length;
+            [result appendBytes:&size length:sizeof(size)];
+            [result appendData:data];
+        }
+        char* buffer = malloc(result.length + 1);
+        memcpy(buffer, result.bytes, result.length);
+        *length = (int)result.length;
+        return buffer;
+    }
+}
+*/
+import "C"
+
+import (
+    "encoding/binary"
+    "errors"
+    "image"
+    "image/draw"
+    "unsafe"
+)
+
+// VisionDecoder detects codes using the barcode detection of Apple's Vision framework, which copes much better with poor
+// photos than zbar and needs no external binary. Only available on macOS in builds with the tag vision (and cgo
+// enabled); registered as decoder "vision".
+type VisionDecoder struct{}
+
+func init() {
+    RegisterDecoder("vision", VisionDecoder{})
+}
+
+// DecodeImage implements Decoder
+func (VisionDecoder) DecodeImage(img image.Image) ([]string, error) {
+    bounds := img.Bounds()
+    if bounds.Empty() {
+        return nil, errors.New("Empty image")
+    }
+    rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+    draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+    var length C.int
+    var cerr *C.char
+    buffer := C.qrf_vision_decode((*C.uchar)(unsafe.Pointer(&rgba.Pix[0])), C.int(bounds.Dx()), C.int(bounds.Dy()), C.int(rgba.Stride), &length, &cerr)
+    if buffer == nil {
+        defer C.free(unsafe.Pointer(cerr))
+        return nil, errors.New("Vision: " + C.GoString(cerr))
+    }
+    defer C.free(unsafe.Pointer(buffer))
+    data := C.GoBytes(unsafe.Pointer(buffer), length)
+    result := make([]string, 0)
+    for len(data) >= 4 {
+        size := int(binary.LittleEndian.Uint32(data))
+        if 4+size > len(data) {
+            return nil, errors.New("Vision: malformed result")
+        }
+        result = append(result, string(data[4:4+size]))
+        data = data[4+size:]
+    }
+    return result, nil
+}
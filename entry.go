@@ -0,0 +1,243 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "image"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+// Entry points for scripts: each of the functions below does in one call what a subcommand of the example application
+// does (encode, decode, verify & info), so a program using the library does not have to combine the steps itself.
+
+// DecodeOptions selects how DecodeFiles & InspectFiles read the images
+type DecodeOptions struct {
+    Decoder  Decoder     // reads the codes; the default decoder if nil (see ZbarFallback)
+    Mode     DecodeMode  // whether images which can not be read are skipped (the default) or abort reading
+    Retry    RetryPolicy // further attempts for images which could not be read (see RetryPolicy); none by default
+    Observer Observer    // notified of the progress of reading the images & restoring the data, if set
+    // Manifest is the manifest of the set, if known: elements read which do not match it are dropped as misreads (see
+    // QrElements.Expected)
+    Manifest *Manifest
+    // Transforms are used to reverse the transforms applied to the data (see RestoreData), e.g. an EncryptTransform
+    // holding the passphrase
+    Transforms []Transform
+    // MaxFileSize limits the size of the data restored (see QrElements.MaxFileSize); DefaultMaxFileSize if 0,
+    // unlimited if negative
+    MaxFileSize int64
+}
+
+// elements returns an empty set reading images with the options
+func (options DecodeOptions) elements() *QrElements {
+    return &QrElements{Decoder: options.Decoder, Mode: options.Mode, Retry: options.Retry, Observer: options.Observer, Expected: options.Manifest, MaxFileSize: options.MaxFileSize}
+}
+
+// EncodeFile reads the file fname & splits its data into elements (see GetElementsWithOptions). Unless options.File is
+// set, the file is described in the set, so it is restored under its name (plain format only, see FileInfo). The size of
+// the file is limited by options.MaxFileSize.
+func EncodeFile(fname string, options EncodeOptions) (*QrElements, error) {
+    qrf := &QrFile{Fname: fname, MaxSize: options.MaxFileSize}
+    err := qrf.ReadFile()
+    if err != nil {
+        return nil, err
+    }
+    if options.File == nil && options.Version == VersionPlain {
+        options.File = qrf.Info()
+    }
+    return GetElementsWithOptions(qrf.ToHexString(), options)
+}
+
+// DecodeFiles reads the set held by the image files (see FromPNGs) & restores its data (see RestoreData). The name, mode
+// & modification time of the result are the ones of the original file if the set describes it (see FileInfo); the
+// name is empty otherwise. The elements are returned along with the data, e.g. for their Report.
+func DecodeFiles(files []string, options DecodeOptions) (*QrFile, *QrElements, error) {
+    return DecodeFilesContext(context.Background(), files, options)
+}
+
+// DecodeFilesContext works like DecodeFiles, but can be aborted while the images are read (see FromPNGsContext)
+func DecodeFilesContext(ctx context.Context, files []string, options DecodeOptions) (*QrFile, *QrElements, error) {
+    elements := options.elements()
+    err := elements.FromPNGsContext(ctx, files)
+    if err != nil {
+        return nil, elements, err
+    }
+    return options.restore(elements)
+}
+
+// DecodeImages works like DecodeFiles for images which were read already, e.g. uploads read with ReadImages (see
+// FromImages; Retry does not apply)
+func DecodeImages(images []image.Image, options DecodeOptions) (*QrFile, *QrElements, error) {
+    elements := options.elements()
+    err := elements.FromImages(images)
+    if err != nil {
+        return nil, elements, err
+    }
+    return options.restore(elements)
+}
+
+// restore restores the data of the elements read (see RestoreData); the transforms recorded in the manifest, if any, are
+// reversed even if the elements do not name them (formats other than plain)
+func (options DecodeOptions) restore(elements *QrElements) (*QrFile, *QrElements, error) {
+    if len(elements.Transforms) == 0 && options.Manifest != nil {
+        elements.Transforms = options.Manifest.Transforms
+    }
+    result := New()
+    err := elements.RestoreData(result, options.Transforms)
+    if err != nil {
+        return nil, elements, err
+    }
+    return result, elements, nil
+}
+
+// VerifyFiles checks the codes of the image files (wildcards & directories are expanded like in FromPNGs) against
+// manifest (see Verifier; without manifest if nil) & returns the state of every element, so the images of missing &
+// corrupt elements can be captured again. Images which can not be read are listed in Unreadable.
+func VerifyFiles(files []string, manifest *Manifest, decoder Decoder) (*Verification, error) {
+    fileList, err := expandInputs(files)
+    if err != nil {
+        return nil, err
+    }
+    err = CheckDecoder(decoder)
+    if err != nil {
+        return nil, err
+    }
+    verifier := NewVerifier(manifest)
+    for _, fname := range fileList {
+        if isInputFile(fname) {
+            verifier.AddFile(fname, fname, decoder)
+        }
+    }
+    return verifier.Result(), nil
+}
+
+// Verify reads the set held by the image files (see FromPNGs) & checks it against the original file read from original,
+// element by element (see OriginalCheck), so the codes are known to restore the file before it is deleted. Unlike
+// DecodeFiles, an incomplete set is no error, its missing elements are listed. The data restored (reversing transforms
+// with options.Transforms) is compared with the original as a whole as well.
+func Verify(original io.Reader, files []string, options DecodeOptions) (*OriginalCheck, error) {
+    reference, err := ioutil.ReadAll(original)
+    if err != nil {
+        return nil, err
+    }
+    elements := options.elements()
+    err = elements.FromPNGs(files)
+    if err != nil && !errors.Is(err, ErrIncompleteSet) {
+        return nil, err
+    }
+    result := elements.checkOriginal(reference)
+    if err != nil {
+        return result, nil
+    }
+    restored, _, err := options.restore(elements)
+    if err != nil {
+        return nil, err
+    }
+    hash, referenceHash := sha256.Sum256(restored.Data), sha256.Sum256(reference)
+    result.Comparison = &Comparison{Identical: bytes.Equal(restored.Data, reference), Length: uint64(len(restored.Data)),
+        ReferenceLength: uint64(len(reference)), Hash: hex.EncodeToString(hash[:]), ReferenceHash: hex.EncodeToString(referenceHash[:]), hashOnly: true}
+    return result, nil
+}
+
+// SetInfo describes a set read from images without restoring its data, see InspectFiles
+type SetInfo struct {
+    SetID      string          `json:"set,omitempty"`
+    Version    int             `json:"version"`          // format version of the elements
+    Codec      string          `json:"codec"`            // encoding of the payload in the codes (see codec.go)
+    Total      uint64          `json:"total"`            // number of elements of the complete set
+    Parity     uint64          `json:"parity,omitempty"` // number of parity elements among them (see parity.go)
+    Found      int             `json:"found"`            // number of distinct elements found in the images
+    Missing    []uint64        `json:"missing"`          // indices of the elements neither found nor reconstructed
+    Complete   bool            `json:"complete"`         // whether the data can be restored
+    Length     uint64          `json:"length"`           // payload characters of the elements, including reconstructed ones
+    File       *FileInfo       `json:"file,omitempty"`   // original file of the set, if recorded
+    Transforms []TransformInfo `json:"transforms,omitempty"`
+    Integrity  bool            `json:"integrity"` // whether the elements carry integrity fields (see integrity.go)
+    Signed     bool            `json:"signed"`    // whether the data is signed (see signature.go)
+    // creator, creation time, comment & content type of the set, if recorded (see Metadata)
+    Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// MissingRanges formats the missing indices as ranges, e.g. "3-5, 9"
+func (info *SetInfo) MissingRanges() string {
+    return formatRanges(info.Missing)
+}
+
+// InspectFiles reads the set held by the image files (see FromPNGs) & describes it; unlike DecodeFiles, an incomplete
+// set is no error, its missing elements are listed instead. The elements are returned along with the description.
+func InspectFiles(files []string, options DecodeOptions) (*SetInfo, *QrElements, error) {
+    elements := options.elements()
+    err := elements.FromPNGs(files)
+    if err != nil && !errors.Is(err, ErrIncompleteSet) {
+        return nil, elements, err
+    }
+    info := elements.Info()
+    if elements.Report != nil {
+        // elements reconstructed from the parity elements were not found
+        info.Found -= len(elements.Report.Reconstructed)
+    }
+    return info, elements, nil
+}
+
+// Info describes the elements read (see Validate) without restoring their data: the set they belong to, the elements
+// missing & what the header of the first element (or the fields of QrElements) records about the set, e.g. its
+// original file & its metadata
+func (elem *QrElements) Info() *SetInfo {
+    info := &SetInfo{Missing: []uint64{}}
+    if elem.Len() == 0 {
+        return info
+    }
+    first := elem.Elements[0]
+    info.SetID, info.Version, info.Codec, info.Total = first.SetID, first.Version, first.Codec.String(), first.MaxIndex+1
+    info.Found, info.Missing = elem.Len(), elem.Missing()
+    info.Complete = len(info.Missing) == 0
+    info.File, info.Metadata, info.Transforms = elem.FileInfo(), elem.Metadata(), elem.AppliedTransforms()
+    info.Signed = elem.signature() != nil
+    if parity, ok := elem.parity(); ok {
+        info.Parity = info.Total - parity.data
+    }
+    for _, v := range elem.Elements {
+        info.Length += v.PayloadLength
+        if _, ok := v.Fields.Get(FieldTypeCRC32); ok {
+            info.Integrity = true
+        }
+    }
+    return info
+}
+
+// InspectManifest describes the set of the manifest fname without decoding any image: the elements whose image is
+// next to the manifest count as found (like in ListSets), the rest as missing
+func InspectManifest(fname string) (*SetInfo, error) {
+    manifest, err := ReadManifestFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    info := &SetInfo{SetID: manifest.SetID, Version: manifest.Version, Codec: manifest.Codec, Total: manifest.Count,
+        Parity: manifest.Parity, Length: manifest.Length, File: manifest.File, Metadata: manifest.Metadata,
+        Transforms: manifest.Transforms, Signed: len(manifest.Signature) > 0}
+    if len(info.Codec) == 0 {
+        info.Codec = CodecHex.String()
+    }
+    if info.File != nil {
+        if _, err := cleanFileName(info.File.Name); err != nil {
+            info.File = nil
+        }
+    }
+    found := make(map[uint64]bool)
+    for _, chunk := range manifest.Chunks {
+        if len(chunk.Image) == 0 {
+            continue
+        }
+        if _, err := os.Stat(filepath.Join(filepath.Dir(fname), chunk.Image)); err == nil {
+            found[chunk.Index] = true
+        }
+    }
+    info.Found, info.Missing = len(found), missingIndices(found, info.Total)
+    info.Complete = uint64(len(info.Missing)) <= info.Parity
+    return info, nil
+}
@@ -0,0 +1,84 @@
+package qrFile
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Errors are checked with errors.Is & errors.As: the sentinel errors below stand for the kinds of failures callers
+// react to, the error types carry the details & match their sentinel, e.g. errors.Is(err, ErrIncompleteSet) for an
+// *IncompleteError listing the missing elements, which errors.As extracts. Errors are wrapped (fmt.Errorf with %w)
+// where context is added, & several errors are joined with errors.Join, so the kinds survive both.
+
+var (
+    // ErrIncompleteSet is matched by an *IncompleteError: elements of the set were not found
+    ErrIncompleteSet = errors.New("Incomplete set")
+    // ErrDuplicateChunk is matched by a *ConflictError: an element was read twice with different content
+    ErrDuplicateChunk = errors.New("Element read twice with different content")
+    // ErrPayloadTooLarge is matched by a *SizeError: data exceeds a limit
+    ErrPayloadTooLarge = errors.New("Payload too large")
+    // ErrNoElements is returned if no element was found at all, e.g. in images without codes
+    ErrNoElements = errors.New("No elements extracted.")
+)
+
+// ErrChecksumMismatch is returned if data read by FromReader does not have the expected SHA-256; it is matched as well by
+// the errors of data failing any other check: codes whose header checksum or payload CRC does not match (a
+// *ParseError), restored data not matching the hash announced by the set, damaged chunks of an armored set, paper keys,
+// transcriptions & session files, & files not matching the checksums file of the set
+var ErrChecksumMismatch = errors.New("Checksum mismatch")
+
+// Is reports whether target is ErrIncompleteSet
+func (e *IncompleteError) Is(target error) bool {
+    return target == ErrIncompleteSet
+}
+
+// Is reports whether target is ErrDuplicateChunk
+func (e *ConflictError) Is(target error) bool {
+    return target == ErrDuplicateChunk
+}
+
+// Is reports whether target is ErrChecksumMismatch & the text of the code failed a checksum
+func (e *ParseError) Is(target error) bool {
+    return target == ErrChecksumMismatch && e.checksum
+}
+
+// SizeError is returned if data exceeds a limit: the size of files read (see DefaultMaxFileSize), the number of codes of a set
+// (see EncodeOptions.MaxCount), the capacity of a code or the space of the header fields
+type SizeError struct {
+    Size    uint64 // size of the data, in bytes or codes like Limit; 0 if not known, e.g. if reading stopped at the limit
+    Limit   uint64 // the limit exceeded
+    message string
+}
+
+func (e *SizeError) Error() string {
+    return e.message
+}
+
+// Is reports whether target is ErrPayloadTooLarge
+func (e *SizeError) Is(target error) bool {
+    return target == ErrPayloadTooLarge
+}
+
+// sizeError creates a SizeError with the given message
+func sizeError(size uint64, limit uint64, format string, args ...interface{}) error {
+    return &SizeError{Size: size, Limit: limit, message: fmt.Sprintf(format, args...)}
+}
+
+// mismatchError is an error matched by ErrChecksumMismatch, with a message describing the mismatch
+type mismatchError struct {
+    message string
+}
+
+func (e *mismatchError) Error() string {
+    return e.message
+}
+
+// Is reports whether target is ErrChecksumMismatch
+func (e *mismatchError) Is(target error) bool {
+    return target == ErrChecksumMismatch
+}
+
+// checksumError creates an error matched by ErrChecksumMismatch
+func checksumError(format string, args ...interface{}) error {
+    return &mismatchError{message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,139 @@
+package qrFile
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// Before a large file is printed, Estimate tells what it costs: the number of codes, their size (QR version) & the
+// pages needed, computed from the size of the data & the options alone. The codes are sized by rendering the text of
+// a sample element of full length, so the headers & fields of the format are accounted for exactly.
+
+// versionCapacity holds the amount of bytes a QR code of each version (1-40, byte mode) holds for each level
+var versionCapacity = [40][4]int{
+    {17, 14, 11, 7}, {32, 26, 20, 14}, {53, 42, 32, 24}, {78, 62, 46, 34}, {106, 84, 60, 44},
+    {134, 106, 74, 58}, {154, 122, 86, 64}, {192, 152, 108, 84}, {230, 180, 130, 98}, {271, 213, 151, 119},
+    {321, 251, 177, 137}, {367, 287, 203, 155}, {425, 331, 241, 177}, {458, 362, 258, 194}, {520, 412, 292, 220},
+    {586, 450, 322, 250}, {644, 504, 364, 280}, {718, 560, 394, 310}, {792, 624, 442, 338}, {858, 666, 482, 382},
+    {929, 711, 509, 403}, {1003, 779, 565, 439}, {1091, 857, 611, 461}, {1171, 911, 661, 511}, {1273, 997, 715, 535},
+    {1367, 1059, 751, 593}, {1465, 1125, 805, 625}, {1528, 1190, 868, 658}, {1628, 1264, 908, 698}, {1732, 1370, 982, 742},
+    {1840, 1452, 1030, 790}, {1952, 1538, 1112, 842}, {2068, 1628, 1168, 898}, {2188, 1722, 1228, 958}, {2303, 1809, 1283, 983},
+    {2431, 1911, 1351, 1051}, {2563, 1989, 1423, 1093}, {2699, 2099, 1499, 1139}, {2809, 2213, 1579, 1219}, {2953, 2331, 1663, 1273},
+}
+
+// SymbolVersion returns the smallest QR version (1-40) holding length bytes at the given level, or 0 if no code holds
+// them
+func SymbolVersion(length int, level Level) int {
+    if level < LevelL || level > LevelH {
+        return 0
+    }
+    for i, capacity := range versionCapacity {
+        if length <= capacity[level] {
+            return i + 1
+        }
+    }
+    return 0
+}
+
+// SymbolVersionCapacity returns the amount of bytes a QR code of the given version (1-40, byte mode) holds at the given
+// level, or 0 if the version or the level is invalid
+func SymbolVersionCapacity(version int, level Level) int {
+    if version < 1 || version > len(versionCapacity) || level < LevelL || level > LevelH {
+        return 0
+    }
+    return versionCapacity[version-1][level]
+}
+
+// SetEstimate describes the set a file of a given size is split into, see Estimate
+type SetEstimate struct {
+    Codes        uint64  // number of codes, including parity codes
+    PayloadBytes uint64  // data bytes held by a full code
+    TextLength   int     // characters of the text of a full code, including its header (& the fields of the first code)
+    Version      int     // QR version of a full code at the highest error correction level used (1-40)
+    Modules      int     // modules per side of such a code (17 + 4*Version), without the quiet zone
+    CodesPerPage int     // codes printed on a page of the sheet layout (see SheetOptions)
+    Pages        uint64  // pages printed by WritePDF
+    ModuleSize   float64 // printed size of a module in millimeters, at the sheet layout
+}
+
+// String summarizes the estimate, e.g. "40 codes (version 25, 117x117 modules), 1000 bytes each, 7 pages of 6 codes,
+// modules of 1.14 mm"
+func (e *SetEstimate) String() string {
+    return fmt.Sprintf("%d codes (version %d, %dx%d modules), %d bytes each, %d pages of %d codes, modules of %.2f mm", e.Codes, e.Version, e.Modules, e.Modules, e.PayloadBytes, e.Pages, e.CodesPerPage, e.ModuleSize)
+}
+
+// Estimate returns the cost of printing data of the given size (in bytes) split with the options: the number of codes
+// (see EstimateCount), their size & the pages of the sheet layout. No data is split; Align is not considered. The
+// version is the one of the largest code, i.e. of the first code holding the fields of the set & a full chunk.
+func Estimate(size uint64, options EncodeOptions, sheet SheetOptions) (*SetEstimate, error) {
+    if options.Symbology != SymbologyQR {
+        return nil, errors.New(fmt.Sprintf("Estimates are only available for QR codes, not for %s codes", options.Symbology))
+    }
+    version, chunkSize, err := options.check()
+    if err != nil {
+        return nil, err
+    }
+    count, err := EstimateCount(size, options)
+    if err != nil {
+        return nil, err
+    }
+    if options.Count > 0 {
+        err = options.checkCount(size)
+        if err != nil {
+            return nil, err
+        }
+        // the data is spread evenly, each element holds at most one byte more than the others
+        chunkSize = 2 * ((size + options.Count - 1) / options.Count)
+        if max := options.plainMaxChunkSize(options.maxLevel()); chunkSize > max {
+            return nil, sizeError(chunkSize, max, "Data too large for %d codes at level %s", options.Count, options.maxLevel())
+        }
+    } else {
+        count += options.Parity
+    }
+    if options.MaxCount > 0 && count > options.MaxCount {
+        return nil, tooManyElementsError(count, version, options)
+    }
+    err = options.checkSetSize(count, version, chunkSize)
+    if err != nil {
+        return nil, err
+    }
+    // a sample of a full chunk, split like the data itself
+    sampleLength := chunkSize
+    if 2*size < sampleLength {
+        sampleLength = 2 * size
+    }
+    if sampleLength == 0 {
+        sampleLength = 2
+    }
+    sampleOptions := options
+    sampleOptions.Version, sampleOptions.ChunkSize = version, chunkSize
+    sampleOptions.Count, sampleOptions.MaxCount, sampleOptions.Align, sampleOptions.Observer = 0, 0, nil, nil
+    sample, err := splitPayload(strings.Repeat("0", int(sampleLength)), sampleOptions)
+    if err != nil {
+        return nil, err
+    }
+    estimate := &SetEstimate{Codes: count, PayloadBytes: chunkSize / 2}
+    for _, v := range sample.Elements {
+        // the header holds the index & the count of the set
+        v.Index, v.MaxIndex = count-1, count-1
+        if length := len(v.AsString()); length > estimate.TextLength {
+            estimate.TextLength = length
+        }
+    }
+    estimate.Version = SymbolVersion(estimate.TextLength, options.maxLevel())
+    if estimate.Version == 0 {
+        return nil, sizeError(uint64(estimate.TextLength), uint64(SymbolCapacity(options.maxLevel())), "A code of %d characters exceeds the capacity of a QR code at level %s", estimate.TextLength, options.maxLevel())
+    }
+    estimate.Modules = 17 + 4*estimate.Version
+    sheet = sheet.resolve()
+    _, _, codeSize, err := sheet.grid()
+    if err != nil {
+        return nil, err
+    }
+    estimate.CodesPerPage = sheet.Columns * sheet.Rows
+    estimate.Pages = (count + uint64(estimate.CodesPerPage) - 1) / uint64(estimate.CodesPerPage)
+    // the code is printed with a quiet zone of 4 modules on each side; a point is 1/72 inch
+    estimate.ModuleSize = codeSize / float64(estimate.Modules+8) * 25.4 / 72
+    return estimate, nil
+}
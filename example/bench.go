@@ -0,0 +1,103 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+    "runtime"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+)
+
+// benchOptions are the flags of the bench command
+type benchOptions struct {
+    coderOptions
+    size       int
+    chunkSizes string
+    levels     string
+    workers    string
+    encodeOnly bool
+}
+
+// benchCommand implements "qrFileApp bench": synthetic data is encoded & decoded with a matrix of settings & the
+// throughput of each combination is printed in a table
+func benchCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "bench [flags]",
+        Short: "Measure the encoding and decoding throughput of chunk sizes, error correction levels and worker counts",
+        Long: `bench encodes synthetic data into codes of the plain format (rendering them as png) and decodes them again with
+every combination of the given chunk sizes, error correction levels and worker counts, and prints the throughput of each
+combination, so the settings fastest on this machine can be picked. Combinations which can not be used (e.g. a chunk
+size exceeding the capacity of a code at a level) are listed with the reason. If the decoder is not available, only
+encoding is measured.`,
+        Args: cobra.NoArgs,
+    }
+    var options benchOptions
+    flags := cmd.Flags()
+    flags.IntVar(&options.size, "size", 256<<10, "Bytes of synthetic data encoded by each combination.")
+    flags.StringVar(&options.chunkSizes, "chunkSizes", "500,1000,2000", "Comma separated chunk sizes (payload characters per code) to measure.")
+    flags.StringVar(&options.levels, "levels", "L,M,Q,H", "Comma separated error correction levels to measure.")
+    flags.StringVar(&options.workers, "workers", fmt.Sprintf("1,%d", runtime.NumCPU()), "Comma separated numbers of images rendered and decoded concurrently.")
+    flags.BoolVar(&options.encodeOnly, "encodeOnly", false, "Only measure encoding.")
+    flags.StringVar(&options.encoderName, "encoder", "internal", "QR encoder used to create the images: internal, qrencode (requires qrencode in $PATH) or another encoder registered in this build.")
+    flags.StringVar(&options.decoderName, "decoder", "", "Decoder used to read the images: native (pure Go), zbar (requires zbarimg in $PATH), zxing (requires ZXingReader in $PATH, reads all symbologies), another decoder registered in this build (e.g. vision on macOS) or exec: followed by a command printing the codes of the png file named by its last argument (or {}), one per line. By default, the native decoder is used and zbarimg (if installed) reads the images it finds no code in.")
+    cmd.RegisterFlagCompletionFunc("encoder", completeValues(qrFile.EncoderNames()...))
+    cmd.RegisterFlagCompletionFunc("decoder", completeValues(qrFile.DecoderNames()...))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.selectCoders()
+        bench := qrFile.BenchmarkOptions{Size: options.size, Encoder: symbolEncoder, Decoder: symbolDecoder, EncodeOnly: options.encodeOnly}
+        for _, value := range splitList(options.chunkSizes) {
+            chunk, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+            if err != nil {
+                log.Fatalf("Invalid chunk size %s", value)
+            }
+            bench.ChunkSizes = append(bench.ChunkSizes, chunk)
+        }
+        for _, value := range splitList(options.levels) {
+            level, err := qrFile.ParseLevel(strings.TrimSpace(value))
+            if err != nil {
+                log.Fatal(err)
+            }
+            bench.Levels = append(bench.Levels, level)
+        }
+        for _, value := range splitList(options.workers) {
+            count, err := strconv.Atoi(strings.TrimSpace(value))
+            if err != nil {
+                log.Fatalf("Invalid worker count %s", value)
+            }
+            bench.Workers = append(bench.Workers, count)
+        }
+        if !bench.EncodeOnly {
+            if err := qrFile.CheckDecoder(symbolDecoder); err != nil {
+                log.Printf("Warning: only measuring encoding: %s", err)
+                bench.EncodeOnly = true
+            }
+        }
+        results, err := qrFile.Benchmark(bench)
+        if err != nil {
+            log.Fatal(err)
+        }
+        out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(out, "CHUNK\tLEVEL\tWORKERS\tCODES\tENCODE KiB/s\tDECODE KiB/s")
+        for _, result := range results {
+            chunk := strconv.FormatUint(result.ChunkSize, 10)
+            if result.ChunkSize == 0 {
+                chunk = "default"
+            }
+            if result.Err != nil {
+                fmt.Fprintf(out, "%s\t%s\t%d\t-\t-\t%s\n", chunk, result.Level, result.Workers, result.Err)
+                continue
+            }
+            decode := "-"
+            if result.Decode > 0 {
+                decode = fmt.Sprintf("%.0f", result.DecodeThroughput()/1024)
+            }
+            fmt.Fprintf(out, "%s\t%s\t%d\t%d\t%.0f\t%s\n", chunk, result.Level, result.Workers, result.Codes, result.EncodeThroughput()/1024, decode)
+        }
+        out.Flush()
+    }
+    return cmd
+}
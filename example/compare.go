@@ -0,0 +1,67 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+)
+
+// compareOptions are the flags of the compare command
+type compareOptions struct {
+    inputOptions
+    sha256 string
+}
+
+// compareCommand implements "qrFileApp compare": a set is restored & compared with the original file (or its SHA-256)
+func compareCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "compare [flags] original images...",
+        Short: "Restore a set and compare it with the original file, e.g. to validate a backup",
+        Long: `compare restores a set (images, text with --text or a .qrf container) and compares the result byte by byte
+with the original file, reporting the first differing offset and the code holding it. With --sha256, the result is
+compared with the SHA-256 of the original instead and all arguments are taken as input. The exit status is 1 if the data
+differs.`,
+        Args: cobra.MinimumNArgs(1),
+    }
+    var options compareOptions
+    flags := cmd.Flags()
+    flags.StringVar(&options.sha256, "sha256", "", "Compare with this SHA-256 (hex) of the original instead of the original file.")
+    flags.BoolVar(&options.textInput, "text", false, "Read the text of scanned codes (one code per line) from the given files or stdin instead of images.")
+    flags.StringVar(&options.selectedSet, "set", "", "Restore only this set if the images contain several sets (set ID or number).")
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        inputs := args
+        if len(options.sha256) == 0 {
+            inputs = args[1:]
+        }
+        if len(inputs) == 0 && !options.textInput {
+            cmd.Usage()
+            os.Exit(2)
+        }
+        elements, err := options.readElements(inputs)
+        if err != nil {
+            log.Fatalf("Error while reading %s: %s", inputs, err)
+        }
+        var comparison *qrFile.Comparison
+        if len(options.sha256) > 0 {
+            comparison, err = elements.CompareHash(options.sha256)
+        } else {
+            var original *os.File
+            original, err = os.Open(args[0])
+            if err != nil {
+                log.Fatal(err)
+            }
+            defer original.Close()
+            comparison, err = elements.Compare(original)
+        }
+        if err != nil {
+            log.Fatalf("Error while comparing: %s", err)
+        }
+        fmt.Println(comparison)
+        if !comparison.Identical {
+            os.Exit(1)
+        }
+    }
+    return cmd
+}
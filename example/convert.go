@@ -0,0 +1,65 @@
+package main
+
+import (
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+)
+
+// convertOptions are the flags of the convert command
+type convertOptions struct {
+    inputOptions
+    imageDirectory string
+    imagePrefix    string
+    container      string
+    level          string
+    chunkSize      uint64
+}
+
+// convertCommand implements "qrFileApp convert": a set in any supported format version is converted to the current one
+func convertCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "convert [flags] images...",
+        Short: "Convert a set (images, text or a .qrf container) to the current format version",
+    }
+    var options convertOptions
+    flags := cmd.Flags()
+    flags.StringVar(&options.imageDirectory, "imageDirectory", "./img_dir", "Directory where the converted images are stored.")
+    flags.StringVar(&options.imagePrefix, "convertedPrefix", "converted_", "Prefix of the converted images.")
+    flags.StringVar(&options.container, "container", "", "Additionally store the converted set in this .qrf container file.")
+    flags.StringVar(&options.level, "level", "L", "Error correction level of the converted codes: L, M, Q or H.")
+    flags.Uint64Var(&options.chunkSize, "chunkSize", 0, "Payload characters per converted code; the default of the format if 0.")
+    flags.BoolVar(&options.textInput, "text", false, "Read the text of scanned codes (one code per line) from the given files or stdin instead of images.")
+    cmd.RegisterFlagCompletionFunc("level", completeValues("L", "M", "Q", "H"))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        if len(args) == 0 && !options.textInput {
+            cmd.Usage()
+            os.Exit(2)
+        }
+        level, err := qrFile.ParseLevel(options.level)
+        if err != nil {
+            log.Fatal(err)
+        }
+        elements, err := options.readElements(args)
+        if err != nil {
+            log.Fatalf("Error while reading %s: %s", args, err)
+        }
+        converted, report, err := elements.Convert(qrFile.EncodeOptions{ChunkSize: options.chunkSize, Level: level, Encoder: symbolEncoder, Workers: root.workerCount, Sequential: root.debugMode, MaxFileSize: root.maxSize()})
+        if err != nil {
+            log.Fatalf("Error while converting: %s", err)
+        }
+        err = converted.WritePNGs(options.imageDirectory, options.imagePrefix)
+        if err != nil {
+            log.Fatalf("Error while writing images: %s", err)
+        }
+        if len(options.container) > 0 {
+            err = options.packContainer(converted, options.container)
+            if err != nil {
+                log.Fatalf("Error while writing container %s: %s", options.container, err)
+            }
+        }
+        log.Printf("Converted set written to %s:\n%s", options.imageDirectory, report)
+    }
+    return cmd
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "golang.org/x/term"
+    "image"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// decodeOptions are the flags of the decode command
+type decodeOptions struct {
+    coderOptions
+    inputOptions
+    out             string
+    outputDirectory string
+    overwrite       bool
+    manifest        string
+}
+
+// decodeCommand implements "qrFileApp decode": a file is restored from a set of images, like output mode but with only
+// the flags of this command (see qrFile.DecodeFiles)
+func decodeCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "decode [flags] images...|-",
+        Short: "Restore a file from a set of QR code images",
+        Long: `decode restores a file from the images of a set (files, directories or screen recordings of the codes) and writes
+it under the name recorded in the set, or --out. Compression and encryption applied when the set was written are
+reversed; the passphrase is never asked for, but taken from --passphraseFile or $QRFILE_PASSPHRASE (or the key from
+--keyFile or $QRFILE_KEY), so decode can run unattended. With --manifest, every code is checked against the hash listed
+in the manifest of the set, so a misread code is dropped in favor of an intact copy and reported.
+
+With - as only input, the images are read from stdin (an image, an animated GIF or a multipage TIFF or PDF). If stdout
+is redirected and --out is not given, the restored data is written to stdout instead of a file.`,
+        Example: `  qrFileApp decode --out test.txt img_dir
+  qrFileApp decode img_dir/*.png > out.tgz
+  qrFileApp decode --manifest img_dir/img_manifest.json scans/*.png
+  qrFileApp decode --videoRate 10 recording.mp4`,
+        Args: cobra.MinimumNArgs(1),
+    }
+    var options decodeOptions
+    flags := cmd.Flags()
+    flags.StringVar(&options.out, "out", "", "File to store the restored data to (- for stdout); by default stdout if it is redirected, else the name recorded in the set, or result.")
+    flags.StringVar(&options.outputDirectory, "outputDirectory", ".", "Directory where the restored file is stored unless --out names a path.")
+    flags.BoolVar(&options.overwrite, "overwrite", false, "Replace an existing output file.")
+    flags.StringVar(&options.decoderName, "decoder", "", "Decoder used to read the images: native, zbar, another decoder registered in this build or exec:<command>.")
+    flags.BoolVar(&options.strictDecode, "strict", false, "Abort if any image can not be read, instead of skipping it.")
+    flags.BoolVar(&options.retryDecode, "retry", false, "Decode images which can not be read again after preprocessing them.")
+    flags.Float64Var(&qrFile.VideoFrameRate, "videoRate", 0, "Frames per second read from video files (read with ffmpeg); 0 reads every frame.")
+    flags.StringVar(&options.passphraseFile, "passphraseFile", "", "Read the passphrase of encrypted data from this file.")
+    flags.StringVar(&options.keyFile, "keyFile", "", "Decrypt with a raw 32 byte key read from this file instead of a passphrase.")
+    flags.StringVar(&options.identityFile, "identity", "", "Decrypt data encrypted for recipients with the X25519 private key in this file.")
+    flags.StringVar(&options.manifest, "manifest", "", "Check every code against this manifest of the set and drop codes which do not match it as misreads.")
+    cmd.RegisterFlagCompletionFunc("decoder", completeValues(qrFile.DecoderNames()...))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.selectCoders()
+        decoding := options.decodeSettings()
+        if len(options.manifest) > 0 {
+            manifest, err := qrFile.ReadManifestFile(options.manifest)
+            if err != nil {
+                log.Fatal(err)
+            }
+            decoding.Manifest = manifest
+        }
+        key, err := options.getKey()
+        if err != nil {
+            log.Fatal(err)
+        }
+        passphrase, err := options.getPassphrase(false, false)
+        if err != nil {
+            log.Fatal(err)
+        }
+        identities, err := options.getIdentities()
+        if err != nil {
+            log.Fatal(err)
+        }
+        decoding.Transforms = []qrFile.Transform{qrFile.EncryptTransform{Passphrase: passphrase, Key: key}, identities}
+        var result *qrFile.QrFile
+        var elements *qrFile.QrElements
+        if len(args) == 1 && args[0] == "-" {
+            var images []image.Image
+            images, err = qrFile.ReadImages(os.Stdin)
+            if err != nil {
+                log.Fatalf("Error while reading images from stdin: %s", err)
+            }
+            result, elements, err = qrFile.DecodeImages(images, decoding)
+        } else {
+            result, elements, err = qrFile.DecodeFiles(args, decoding)
+        }
+        if elements != nil && elements.Report != nil && len(elements.Report.Failures) > 0 {
+            log.Printf("Some images could not be read:\n%s", elements.Report)
+        } else if elements != nil && elements.Report != nil && len(elements.Report.Mismatched) > 0 {
+            log.Printf("Some codes do not match the manifest:\n%s", elements.Report)
+        }
+        if elements != nil && elements.Report != nil && elements.Report.Duplicates > 0 {
+            log.Printf("%d codes were read more than once; the copies were dropped.", elements.Report.Duplicates)
+        }
+        if errors.Is(err, qrFile.ErrPassphraseRequired) {
+            log.Fatal("The data is encrypted; give the passphrase with --passphraseFile or $QRFILE_PASSPHRASE")
+        }
+        if errors.Is(err, qrFile.ErrIdentityRequired) {
+            log.Fatal("The data is encrypted for recipients; give a private key with --identity")
+        }
+        if errors.Is(err, qrFile.ErrDuplicateChunk) && len(options.manifest) == 0 {
+            log.Fatalf("Error while decoding: %s One of the images holds a misread code; remove it or give the manifest of the set with --manifest to drop it.", err)
+        }
+        if err != nil {
+            log.Fatalf("Error while decoding: %s", err)
+        }
+        // without --out, the data goes to stdout if it is redirected, e.g. decode img_dir > out.tgz
+        toStdout := options.out == "-" || (len(options.out) == 0 && !term.IsTerminal(int(os.Stdout.Fd())))
+        switch {
+        case toStdout:
+            result.Fname = "stdout"
+            _, err = result.WriteTo(os.Stdout)
+        case len(options.out) > 0:
+            result.Fname = options.out
+        case len(result.Fname) == 0:
+            result.Fname = "result"
+        }
+        if !toStdout {
+            if !filepath.IsAbs(result.Fname) && !strings.ContainsRune(result.Fname, filepath.Separator) {
+                result.Fname = filepath.Join(options.outputDirectory, result.Fname)
+            }
+            result.Overwrite = options.overwrite
+            err = overwriteHint(result.ToFile())
+        }
+        if err != nil {
+            log.Fatalf("Error while writing %s: %s", result.Fname, err)
+        }
+        if toStdout {
+            log.Printf("%d bytes restored to stdout", len(result.Data))
+        } else {
+            fmt.Printf("%s: %d bytes restored\n", result.Fname, len(result.Data))
+        }
+    }
+    return cmd
+}
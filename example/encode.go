@@ -0,0 +1,122 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+)
+
+// encodeOptions are the flags of the encode command
+type encodeOptions struct {
+    coderOptions
+    renderOptions
+    metadataOptions
+    imageDirectory   string
+    imagePrefix      string
+    format           string
+    level            string
+    chunkSize        uint64
+    symbolVersion    int
+    codec            string
+    parity           uint64
+    integrity        bool
+    structuredAppend bool
+}
+
+// encodeCommand implements "qrFileApp encode": a file is converted to a set of images, like input mode but with only the
+// flags of this command (see qrFile.EncodeFile)
+func encodeCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "encode [flags] file|-",
+        Short: "Convert a file to a set of QR code images",
+        Long: `encode converts a file to a set of QR code images and a manifest, in plain format by default, recording the name
+of the file so decode restores it under this name. It does what --in does, with only the flags needed for it. With -
+as file, the data is read from stdin; no name is recorded then.`,
+        Example: `  qrFileApp encode --imageDirectory scans --parity 2 ~/test.txt
+  tar cz docs | qrFileApp encode -`,
+        Args: cobra.ExactArgs(1),
+    }
+    var options encodeOptions
+    flags := cmd.Flags()
+    flags.StringVar(&options.imageDirectory, "imageDirectory", "./img_dir", "Directory where the images are stored; an http(s) URL uploads them with PUT requests instead.")
+    flags.StringVar(&options.imagePrefix, "imagePrefix", "img_", "Prefix of the images.")
+    flags.StringVar(&options.format, "format", "plain", "Format of the codes: plain, compact or legacy.")
+    flags.StringVar(&options.level, "level", "L", "Error correction level of the codes: L, M, Q or H.")
+    flags.Uint64Var(&options.chunkSize, "chunkSize", 0, "Payload characters per code; the default of the format if 0.")
+    flags.IntVar(&options.symbolVersion, "symbolVersion", 0, "Largest version of the QR codes (1 to 40); unless --chunkSize is given, the codes are filled up to this version at the error correction level.")
+    flags.StringVar(&options.codec, "codec", "hex", "Encoding of the payload in the codes (plain format): hex, base64 or base45.")
+    flags.Uint64Var(&options.parity, "parity", 0, "Add this many parity codes (plain format), so as many lost codes do not matter.")
+    flags.BoolVar(&options.integrity, "integrity", false, "Add checksums of the payload and the data to the codes (plain format).")
+    flags.StringVar(&options.encoderName, "encoder", "internal", "QR encoder used to create the images: internal, qrencode or another encoder registered in this build.")
+    flags.StringVar(&options.symbologyName, "symbology", "qr", "Kind of code the images hold: qr, datamatrix, aztec or pdf417 (aztec and pdf417 require zint in $PATH).")
+    cmd.RegisterFlagCompletionFunc("format", completeValues("plain", "compact", "legacy"))
+    cmd.RegisterFlagCompletionFunc("level", completeValues("L", "M", "Q", "H"))
+    cmd.RegisterFlagCompletionFunc("codec", completeValues("hex", "base64", "base45"))
+    cmd.RegisterFlagCompletionFunc("encoder", completeValues(qrFile.EncoderNames()...))
+    cmd.RegisterFlagCompletionFunc("symbology", completeValues("qr", "datamatrix", "aztec", "pdf417"))
+    options.addRenderFlags(flags)
+    flags.StringVar(&options.metaCreator, "creator", "", "Record who created the set in its metadata, along with the time.")
+    flags.StringVar(&options.metaComment, "comment", "", "Record a comment on the set in its metadata.")
+    flags.StringVar(&options.metaContentType, "contentType", "", "Record the media type of the data (e.g. text/plain) in the metadata of the set.")
+    flags.BoolVar(&options.structuredAppend, "structuredAppend", false, "Also link the codes as a QR Structured Append sequence (at most 16 codes, internal encoder), so standard QR readers recognize them as one message.")
+    flags.BoolVar(&options.printCaption, "caption", false, "Print a caption below each code: the file name, \"chunk <number>/<count>\", the date and the first 8 characters of its SHA-256, to tell printed codes apart.")
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.selectCoders()
+        encoding := qrFile.EncodeOptions{ChunkSize: options.chunkSize, SymbolVersion: options.symbolVersion, Parity: options.parity, Integrity: options.integrity, Encoder: symbolEncoder, Symbology: symbology, StructuredAppend: options.structuredAppend, Workers: root.workerCount, Sequential: root.debugMode, MaxFileSize: root.maxSize()}
+        encoding.Metadata = options.setMetadata()
+        switch options.format {
+        case "plain":
+            encoding.Version = qrFile.VersionPlain
+        case "compact":
+            encoding.Version = qrFile.VersionCompact
+        case "legacy":
+            encoding.Version = qrFile.VersionLegacy
+        default:
+            log.Fatalf("Invalid format %s, expected plain, compact or legacy", options.format)
+        }
+        var err error
+        encoding.Level, err = qrFile.ParseLevel(options.level)
+        if err != nil {
+            log.Fatal(err)
+        }
+        encoding.Codec, err = qrFile.ParsePayloadCodec(options.codec)
+        if err != nil {
+            log.Fatal(err)
+        }
+        var elements *qrFile.QrElements
+        if args[0] == "-" {
+            elements, err = qrFile.EncodeFromReader(os.Stdin, "stdin", qrFile.SourceOptions{MaxSize: root.maxSize()}, encoding)
+        } else {
+            elements, err = qrFile.EncodeFile(args[0], encoding)
+        }
+        if err != nil {
+            log.Fatalf("Error while encoding %s: %s", args[0], err)
+        }
+        elements.Rendering, err = options.parseRendering()
+        if err != nil {
+            log.Fatal(err)
+        }
+        elements.Caption = options.captionFor(args[0])
+        var storage qrFile.Storage
+        if !qrFile.IsStorageURL(options.imageDirectory) {
+            err = os.MkdirAll(options.imageDirectory, 0755)
+        }
+        if err == nil {
+            storage, err = imageStorage(options.imageDirectory)
+        }
+        if err == nil {
+            err = elements.WritePNGsTo(storage, options.imagePrefix)
+        }
+        if err != nil {
+            log.Fatalf("Error while writing images to %s: %s", options.imageDirectory, err)
+        }
+        source := args[0]
+        if source == "-" {
+            source = "stdin"
+        }
+        fmt.Printf("%s: %d codes written to %s\n", source, elements.Len(), options.imageDirectory)
+    }
+    return cmd
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+// infoOptions are the flags of the info command
+type infoOptions struct {
+    coderOptions
+    inputOptions
+    json bool
+}
+
+// infoCommand implements "qrFileApp info": a set of images is described without restoring the file (see
+// qrFile.InspectFiles)
+func infoCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "info [flags] images...",
+        Short: "Describe the set held by QR code images",
+        Long: `info reads the images of a set (files or directories) and describes it: set ID, format, codes found and missing,
+parity codes, the file and the metadata (creator, creation time, comment, content type) recorded in the set and the
+transforms applied to the data. Unlike decode, an incomplete set is described as well. Given the manifest of a set, no
+image is decoded: the set is described from the manifest and the images next to it.`,
+        Example: `  qrFileApp info --json img_dir
+  qrFileApp info img_dir/img_manifest.json`,
+        Args: cobra.MinimumNArgs(1),
+    }
+    var options infoOptions
+    flags := cmd.Flags()
+    flags.BoolVar(&options.json, "json", false, "Print the description as JSON.")
+    flags.StringVar(&options.decoderName, "decoder", "", "Decoder used to read the images: native, zbar, another decoder registered in this build or exec:<command>.")
+    cmd.RegisterFlagCompletionFunc("decoder", completeValues(qrFile.DecoderNames()...))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.selectCoders()
+        var info *qrFile.SetInfo
+        var err error
+        if len(args) == 1 && strings.HasSuffix(args[0], qrFile.ManifestName) {
+            info, err = qrFile.InspectManifest(args[0])
+        } else {
+            info, _, err = qrFile.InspectFiles(args, options.decodeSettings())
+        }
+        if err != nil {
+            log.Fatalf("Error while reading the set: %s", err)
+        }
+        if options.json {
+            printJSON(info)
+            return
+        }
+        out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        if len(info.SetID) > 0 {
+            fmt.Fprintf(out, "Set:\t%s\n", info.SetID)
+        }
+        fmt.Fprintf(out, "Format:\t%s (version %d), %s payload\n", qrFile.FormatName(info.Version), info.Version, info.Codec)
+        fmt.Fprintf(out, "Codes:\t%d of %d found\n", info.Found, info.Total)
+        if len(info.Missing) > 0 {
+            fmt.Fprintf(out, "Missing:\t%s\n", info.MissingRanges())
+        }
+        if info.Parity > 0 {
+            fmt.Fprintf(out, "Parity codes:\t%d\n", info.Parity)
+        }
+        if info.File != nil {
+            fmt.Fprintf(out, "File:\t%s, %d bytes\n", info.File.Name, info.File.Size)
+        }
+        if m := info.Metadata; m != nil {
+            if len(m.Creator) > 0 {
+                fmt.Fprintf(out, "Creator:\t%s\n", m.Creator)
+            }
+            if !m.Created.IsZero() {
+                fmt.Fprintf(out, "Created:\t%s\n", m.Created.Local().Format(time.RFC3339))
+            }
+            if len(m.ContentType) > 0 {
+                fmt.Fprintf(out, "Content type:\t%s\n", m.ContentType)
+            }
+            if len(m.Comment) > 0 {
+                fmt.Fprintf(out, "Comment:\t%s\n", strings.Replace(m.Comment, "\n", "\n\t", -1))
+            }
+        }
+        if len(info.Transforms) > 0 {
+            fmt.Fprintf(out, "Transforms:\t%s\n", transformNames(info.Transforms))
+        }
+        fmt.Fprintf(out, "Integrity:\t%t\nSigned:\t%t\nRestorable:\t%t\n", info.Integrity, info.Signed, info.Complete)
+        out.Flush()
+    }
+    return cmd
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) {
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        log.Fatal(err)
+    }
+    fmt.Println(string(data))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+)
+
+// keygenCommand implements "qrFileApp keygen": a key pair for encryption for recipients is created (see --recipients)
+func keygenCommand() *cobra.Command {
+    return &cobra.Command{
+        Use:   "keygen file",
+        Short: "Create a key pair for encryption for recipients",
+        Long: `keygen writes a new X25519 private key to the file (PEM, readable by the owner only) and prints its public key.
+Whoever encodes a set for you gives the public key with --recipients; you restore the set with --identity file. Keep
+the private key safe: without it, the sets encrypted for it can not be restored.`,
+        Example: `  qrFileApp keygen ~/.qrfile/identity.pem
+  qrFileApp --in secrets.tar --recipients "$(cat friend.pub)"`,
+        Args: cobra.ExactArgs(1),
+        Run: func(cmd *cobra.Command, args []string) {
+            identity, err := qrFile.GenerateIdentity()
+            if err != nil {
+                log.Fatal(err)
+            }
+            encoded, err := qrFile.MarshalIdentity(identity)
+            if err != nil {
+                log.Fatal(err)
+            }
+            file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+            if err != nil {
+                log.Fatalf("Error while writing the private key: %s", err)
+            }
+            _, err = file.Write(encoded)
+            if closeErr := file.Close(); err == nil {
+                err = closeErr
+            }
+            if err != nil {
+                log.Fatalf("Error while writing the private key: %s", err)
+            }
+            log.Printf("Private key written to %s; the public key is:", args[0])
+            fmt.Println(qrFile.FormatRecipient(identity.PublicKey()))
+        },
+    }
+}
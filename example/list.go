@@ -0,0 +1,54 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+    "strings"
+    "text/tabwriter"
+)
+
+// listCommand implements "qrFileApp list": the sets found in directories are summarized in a table
+func listCommand() *cobra.Command {
+    return &cobra.Command{
+        Use:   "list [directories...]",
+        Short: "Summarize the sets found in directories of images",
+        Long: `list prints a table of the sets found in each directory (the current directory by default): set ID, source,
+codes present and total, missing codes and the estimated size of the restorable data. Sets described by a manifest are
+summarized without decoding any image; other images are decoded. If a checksums file (see --checksums) is next to the
+manifest, the files of the set are checked against it.`,
+        Run: func(cmd *cobra.Command, args []string) {
+            if len(args) == 0 {
+                args = []string{"."}
+            }
+            out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+            fmt.Fprintln(out, "DIRECTORY\tSET\tSOURCE\tCODES\tMISSING\tSIZE\tCHECKSUMS")
+            for _, dir := range args {
+                summaries, err := qrFile.ListSets(dir, symbolDecoder)
+                if err != nil {
+                    log.Fatalf("Error while listing %s: %s", dir, err)
+                }
+                for _, summary := range summaries {
+                    setID := summary.SetID
+                    if len(setID) == 0 {
+                        setID = "-"
+                    }
+                    missing := summary.MissingRanges()
+                    if len(missing) == 0 {
+                        missing = "-"
+                    }
+                    checksums := "-"
+                    if summary.Verified && len(summary.Damaged) == 0 {
+                        checksums = "ok"
+                    } else if summary.Verified {
+                        checksums = fmt.Sprintf("%d damaged: %s", len(summary.Damaged), strings.Join(summary.Damaged, ", "))
+                    }
+                    fmt.Fprintf(out, "%s\t%s\t%s\t%d/%d\t%s\t%d\t%s\n", dir, setID, summary.Source, summary.Present, summary.Total, missing, summary.Size, checksums)
+                }
+            }
+            out.Flush()
+        },
+    }
+}
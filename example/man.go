@@ -0,0 +1,25 @@
+package main
+
+import (
+    "github.com/spf13/cobra"
+    "github.com/spf13/cobra/doc"
+)
+
+// manCommand implements "qrFileApp man": man pages of all commands are written to a directory
+func manCommand() *cobra.Command {
+    return &cobra.Command{
+        Use:   "man [directory]",
+        Short: "Write the man pages of qrFileApp to a directory (default: the current directory)",
+        Args:  cobra.MaximumNArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            dir := "."
+            if len(args) > 0 {
+                dir = args[0]
+            }
+            return doc.GenManTree(cmd.Root(), &doc.GenManHeader{Title: "QRFILEAPP", Section: "1"}, dir)
+        },
+        ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+            return nil, cobra.ShellCompDirectiveFilterDirs
+        },
+    }
+}
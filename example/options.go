@@ -0,0 +1,492 @@
+package main
+
+import (
+    "crypto/ecdh"
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/pflag"
+    "github.com/zalando/go-keyring"
+    "golang.org/x/term"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// coderOptions are the flags selecting the encoder, the symbology & the decoder (see selectCoders)
+type coderOptions struct {
+    encoderName   string
+    symbologyName string
+    decoderName   string
+}
+
+// selectCoders sets symbolEncoder & symbolDecoder as selected by --encoder & --decoder; commands without --encoder or
+// --symbology use the internal encoder & QR codes
+func (o coderOptions) selectCoders() {
+    var err error
+    symbology = qrFile.SymbologyQR
+    if len(o.symbologyName) > 0 {
+        symbology, err = qrFile.ParseSymbology(o.symbologyName)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    switch o.encoderName {
+    case "", "internal":
+        symbolEncoder, err = qrFile.SymbologyEncoder(symbology)
+        if err != nil {
+            log.Fatal(err)
+        }
+    default:
+        if o.encoderName == "qrencode" && symbology != qrFile.SymbologyQR {
+            log.Fatalf("qrencode only creates QR codes, use the internal encoder for %s codes", symbology)
+        }
+        symbolEncoder, err = qrFile.GetEncoder(o.encoderName)
+        if err != nil {
+            log.Fatalf("%s (available: %s)", err, strings.Join(qrFile.EncoderNames(), " "))
+        }
+    }
+    if len(o.decoderName) > 0 {
+        decoder, err := qrFile.GetDecoder(o.decoderName)
+        if err != nil {
+            log.Fatalf("%s (available: %s)", err, strings.Join(qrFile.DecoderNames(), " "))
+        }
+        symbolDecoder = decoder
+    }
+}
+
+// renderOptions are the flags selecting how the images are drawn & captioned
+type renderOptions struct {
+    moduleSize      int
+    quietZone       int
+    foregroundColor string
+    backgroundColor string
+    maxImageSize    int
+    imageDPI        int
+    printSize       float64
+    printCaption    bool
+}
+
+// addRenderFlags adds the flags selecting how the images are drawn (see parseRendering)
+func (o *renderOptions) addRenderFlags(flags *pflag.FlagSet) {
+    flags.IntVar(&o.moduleSize, "moduleSize", 0, "Size of a module of the codes in pixels (8 if 0); reduced to fit --maxImageSize.")
+    flags.IntVar(&o.quietZone, "quietZone", 0, "Width of the blank margin around the codes in modules (4 if 0, none if negative).")
+    flags.StringVar(&o.foregroundColor, "foreground", "", "Color of the dark modules as hex value, e.g. #1a1a1a (black if empty).")
+    flags.StringVar(&o.backgroundColor, "background", "", "Color of the light modules and the margin as hex value, e.g. #fffff0 (white if empty); has to be lighter than --foreground.")
+    flags.IntVar(&o.maxImageSize, "maxImageSize", 0, "If set, the images are at most this many pixels wide and high; the module size is reduced to fit.")
+    flags.IntVar(&o.imageDPI, "dpi", 0, "Record this resolution in the images (pixels per inch), so they print at their intended size; 300 with --printSize if 0.")
+    flags.Float64Var(&o.printSize, "printSize", 0, "Printed width and height of the codes with their margin in millimeters, e.g. 50; the module size is computed from it and --dpi, and codes too dense to scan at this size are refused.")
+}
+
+// captionFor returns the caption selected by --caption for the set of a file (see QrElements.Caption): the name of the
+// file & the current date; nil if no caption is printed. Without a file name, the one recorded in the set is used.
+func (o renderOptions) captionFor(fname string) *qrFile.Caption {
+    if !o.printCaption {
+        return nil
+    }
+    caption := &qrFile.Caption{Date: time.Now()}
+    if len(fname) > 0 && fname != "-" {
+        caption.Text = filepath.Base(fname)
+    }
+    return caption
+}
+
+// parseRendering returns the render options selected by the flags of addRenderFlags; the zero value if none is set,
+// which leaves the images to the encoder
+func (o renderOptions) parseRendering() (qrFile.RenderOptions, error) {
+    options := qrFile.RenderOptions{Scale: o.moduleSize, QuietZone: o.quietZone, MaxSize: o.maxImageSize, DPI: o.imageDPI, PrintSize: o.printSize}
+    var err error
+    if len(o.foregroundColor) > 0 {
+        options.Foreground, err = qrFile.ParseColor(o.foregroundColor)
+        if err != nil {
+            return options, err
+        }
+    }
+    if len(o.backgroundColor) > 0 {
+        options.Background, err = qrFile.ParseColor(o.backgroundColor)
+    }
+    return options, err
+}
+
+// inputOptions are the flags selecting how a set is read (see readElements & decodeSettings)
+type inputOptions struct {
+    keyOptions
+    textInput          bool
+    transcriptionInput bool
+    selectedSet        string
+    strictDecode       bool
+    retryDecode        bool
+    retryBudget        time.Duration
+    quarantineDir      string
+    salvageMode        string
+    showSymbols        bool
+    showProgress       bool
+}
+
+// readElements reads a complete set from images, text, transcriptions or a container, as selected on the command line
+func (o inputOptions) readElements(fileList []string) (*qrFile.QrElements, error) {
+    var newElem = new(qrFile.QrElements)
+    var err error
+    if o.textInput {
+        err = importTextFiles(newElem.ImportText, fileList)
+    } else if o.transcriptionInput {
+        err = importTextFiles(newElem.ImportTranscriptions, fileList)
+    } else if len(fileList) == 1 && strings.HasSuffix(strings.ToLower(fileList[0]), ".qrf") {
+        newElem, err = o.unpackContainer(fileList[0])
+    } else if len(fileList) == 1 && isArmored(fileList[0]) {
+        newElem, err = readArmor(fileList[0])
+    } else if isTextExport(fileList) {
+        err = newElem.FromTextFiles(fileList)
+    } else if len(o.selectedSet) > 0 {
+        newElem, err = selectSet(fileList, o.selectedSet)
+    } else {
+        newElem.Decoder = symbolDecoder
+        newElem.Observer = progressObserver(o.showProgress)
+        newElem.Workers = root.workerCount
+        newElem.Sequential = root.debugMode
+        newElem.MaxFileSize = root.maxSize()
+        if o.strictDecode {
+            newElem.Mode = qrFile.DecodeStrict
+        }
+        if o.retryDecode {
+            newElem.Retry = qrFile.DefaultRetryPolicy
+            newElem.Retry.FallbackZbar = symbolDecoder != nil
+            newElem.Retry.Budget = o.retryBudget
+        }
+        if len(fileList) == 1 && strings.HasSuffix(strings.ToLower(fileList[0]), ".zip") {
+            err = readZip(fileList[0], newElem)
+        } else {
+            err = newElem.FromPNGs(fileList)
+        }
+        if newElem.Report != nil && (len(newElem.Report.Failures) > 0 || len(newElem.Report.Outvoted) > 0 || len(newElem.Report.Reconstructed) > 0) {
+            log.Printf("Some images could not be read or were corrected:\n%s", newElem.Report)
+            if len(o.quarantineDir) > 0 && len(newElem.Report.Failures) > 0 {
+                moved, qerr := newElem.Report.Quarantine(o.quarantineDir, true)
+                if qerr != nil {
+                    log.Printf("Error while moving unreadable images to %s: %s", o.quarantineDir, qerr)
+                }
+                log.Printf("Moved %d unreadable images to %s (see %s there); scan these pages again.", len(moved), o.quarantineDir, qrFile.QuarantineList)
+            }
+        }
+        if newElem.Report != nil && o.showSymbols {
+            log.Printf("Codes read:\n%s", newElem.Report.SymbolTable())
+        }
+        if newElem.Report != nil && len(newElem.Report.Borderline()) > 0 {
+            log.Printf("%d codes were read only after retries; consider printing them again (details with --symbols).", len(newElem.Report.Borderline()))
+        }
+        if newElem.Report != nil && newElem.Report.Duplicates > 0 {
+            log.Printf("%d codes were read more than once; the copies were dropped.", newElem.Report.Duplicates)
+        }
+        if params := newElem.Parameters; params != nil {
+            transforms := "none"
+            if len(params.Transforms) > 0 {
+                transforms = transformNames(params.Transforms)
+            }
+            log.Printf("Read the parameter code of the set: %s format, %d codes of %d bytes, transforms: %s", qrFile.FormatName(params.Version), params.Count, params.ChunkSize, transforms)
+        }
+    }
+    if errors.Is(err, qrFile.ErrDuplicateChunk) {
+        log.Print("One of the images holds a misread code; remove it or decode with the decode command and --manifest to drop it.")
+    }
+    var incomplete *qrFile.IncompleteError
+    if errors.As(err, &incomplete) {
+        log.Printf("Scan the missing codes again; --in <original file> --only %s renders their images anew.", indexList(incomplete.Missing))
+        if mode, _ := o.salvage(); mode != qrFile.SalvageOff && newElem != nil && newElem.Len() > 0 {
+            log.Printf("%s Restoring the data of the codes found (--salvage %s).", err, o.salvageMode)
+            err = nil
+        }
+    }
+    if err != nil {
+        return nil, err
+    }
+    newElem.MaxFileSize = root.maxSize()
+    return newElem, nil
+}
+
+// salvage returns the mode selected with --salvage
+func (o inputOptions) salvage() (qrFile.SalvageMode, error) {
+    if len(o.salvageMode) == 0 {
+        return qrFile.SalvageOff, nil
+    }
+    return qrFile.ParseSalvageMode(o.salvageMode)
+}
+
+// decodeSettings returns the settings of the decode, verify & info commands for qrFile.DecodeFiles & qrFile.InspectFiles;
+// the decoder has to be selected before (see selectCoders)
+func (o inputOptions) decodeSettings() qrFile.DecodeOptions {
+    options := qrFile.DecodeOptions{Decoder: symbolDecoder, Observer: progressObserver(o.showProgress), MaxFileSize: root.maxSize()}
+    if o.strictDecode {
+        options.Mode = qrFile.DecodeStrict
+    }
+    if o.retryDecode {
+        options.Retry = qrFile.DefaultRetryPolicy
+        options.Retry.FallbackZbar = symbolDecoder != nil
+    }
+    return options
+}
+
+// metadataOptions are the flags giving the metadata of a new set
+type metadataOptions struct {
+    metaCreator     string
+    metaComment     string
+    metaContentType string
+}
+
+// setMetadata returns the metadata of a new set given with --creator, --comment & --contentType, created now; nil if
+// none is given
+func (o metadataOptions) setMetadata() *qrFile.Metadata {
+    if len(o.metaCreator) == 0 && len(o.metaComment) == 0 && len(o.metaContentType) == 0 {
+        return nil
+    }
+    return &qrFile.Metadata{Creator: o.metaCreator, Created: time.Now().Truncate(time.Second), Comment: o.metaComment, ContentType: o.metaContentType}
+}
+
+// keyOptions are the flags giving the passphrase or key of encrypted data & containers
+type keyOptions struct {
+    passphraseFile   string
+    keyFile          string
+    identityFile     string
+    keyringName      string
+    storeKeyring     bool
+    encryptContainer bool
+}
+
+// getKey returns the raw key given with --keyFile or, if no key file is set, $QRFILE_KEY; nil if neither is set
+func (o keyOptions) getKey() ([]byte, error) {
+    if len(o.keyFile) > 0 {
+        return qrFile.ReadKeyFile(o.keyFile)
+    }
+    if text := os.Getenv("QRFILE_KEY"); len(text) > 0 {
+        key, err := qrFile.ParseKey(text)
+        if err != nil {
+            return nil, errors.New(fmt.Sprintf("QRFILE_KEY: %s", err))
+        }
+        return key, nil
+    }
+    return nil, nil
+}
+
+// getIdentities returns the transform decrypting data encrypted for recipients with the private key given with
+// --identity; it holds no identity if none is given
+func (o keyOptions) getIdentities() (qrFile.RecipientTransform, error) {
+    if len(o.identityFile) == 0 {
+        return qrFile.RecipientTransform{}, nil
+    }
+    identity, err := qrFile.ReadIdentity(o.identityFile)
+    if err != nil {
+        return qrFile.RecipientTransform{}, err
+    }
+    return qrFile.RecipientTransform{Identities: []*ecdh.PrivateKey{identity}}, nil
+}
+
+// getPassphrase returns the passphrase of an encrypted set, taken from (in this order) the file given with
+// --passphraseFile, $QRFILE_PASSPHRASE or the OS keyring (--keyring). If none of them provides one & prompt is set, the
+// passphrase is read from the terminal without echo, twice if confirm is set; otherwise it is empty.
+func (o keyOptions) getPassphrase(prompt bool, confirm bool) (string, error) {
+    if len(o.passphraseFile) > 0 {
+        data, err := ioutil.ReadFile(o.passphraseFile)
+        if err != nil {
+            return "", err
+        }
+        return strings.TrimRight(string(data), "\r\n"), nil
+    }
+    if passphrase := os.Getenv("QRFILE_PASSPHRASE"); len(passphrase) > 0 {
+        return passphrase, nil
+    }
+    if len(o.keyringName) > 0 && !o.storeKeyring {
+        passphrase, err := keyring.Get(keyringService, o.keyringName)
+        if err == nil {
+            return passphrase, nil
+        }
+        if err != keyring.ErrNotFound {
+            return "", errors.New(fmt.Sprintf("Unable to read the passphrase from the keyring: %s", err))
+        }
+    }
+    if !prompt {
+        return "", nil
+    }
+    passphrase, err := readPassword("Passphrase: ")
+    if err != nil {
+        return "", err
+    }
+    if len(passphrase) == 0 {
+        return "", errors.New("Empty passphrase")
+    }
+    if confirm {
+        repeated, err := readPassword("Repeat passphrase: ")
+        if err != nil {
+            return "", err
+        }
+        if repeated != passphrase {
+            return "", errors.New("The passphrases do not match")
+        }
+    }
+    return passphrase, nil
+}
+
+// readPassword reads a line from the terminal without echo. The terminal is used even if stdin is redirected (e.g.
+// with --text).
+func readPassword(prompt string) (string, error) {
+    tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+    if err != nil {
+        tty = os.Stdin
+    } else {
+        defer tty.Close()
+    }
+    if !term.IsTerminal(int(tty.Fd())) {
+        return "", errors.New("A passphrase is required, but there is no terminal to ask for it; use --passphraseFile, $QRFILE_PASSPHRASE or --keyring")
+    }
+    fmt.Fprint(os.Stderr, prompt)
+    passphrase, err := term.ReadPassword(int(tty.Fd()))
+    fmt.Fprintln(os.Stderr)
+    return string(passphrase), err
+}
+
+// keyringService is the service name under which passphrases are stored in the OS keyring
+const keyringService = "qrFileApp"
+
+// storePassphrase stores the passphrase in the OS keyring if --storeKeyring is set
+func (o keyOptions) storePassphrase(passphrase string) error {
+    if !o.storeKeyring || len(passphrase) == 0 {
+        return nil
+    }
+    if len(o.keyringName) == 0 {
+        return errors.New("--storeKeyring requires a key name (--keyring)")
+    }
+    err := keyring.Set(keyringService, o.keyringName, passphrase)
+    if err != nil {
+        return errors.New(fmt.Sprintf("Unable to store the passphrase in the keyring: %s", err))
+    }
+    log.Printf("Stored the passphrase in the keyring as %s.", o.keyringName)
+    return nil
+}
+
+// packContainer stores elements with their images in the .qrf container fname, protected with a password if
+// --encryptContainer is set
+func (o keyOptions) packContainer(elements *qrFile.QrElements, fname string) error {
+    password := ""
+    if o.encryptContainer {
+        var err error
+        password, err = o.getPassphrase(true, true)
+        if err != nil {
+            return err
+        }
+    }
+    return elements.PackFileWithPassword(fname, true, password)
+}
+
+// unpackContainer reads the .qrf container fname, asking for the password if it is encrypted
+func (o keyOptions) unpackContainer(fname string) (*qrFile.QrElements, error) {
+    password, err := o.getPassphrase(false, false)
+    if err != nil {
+        return nil, err
+    }
+    elements, err := qrFile.UnpackFileWithPassword(fname, password)
+    if errors.Is(err, qrFile.ErrPasswordRequired) {
+        password, err = o.getPassphrase(true, false)
+        if err != nil {
+            return nil, err
+        }
+        elements, err = qrFile.UnpackFileWithPassword(fname, password)
+    }
+    return elements, err
+}
+
+// outputOptions are the flags selecting where & how restored data is written
+type outputOptions struct {
+    outDir          string
+    outFile         string
+    outFileGiven    bool // --out was given, so the name recorded in the set is not used
+    overwriteOutput bool
+    archiveFormat   string
+    verifyKeyFile   string
+}
+
+// resultFile prepares the QrFile the restored data is written to: the file given with --out or, if none is given & the
+// set describes its original file, that file in the output directory, with its mode & modification time
+func (o outputOptions) resultFile(elements *qrFile.QrElements, outputFilename string) *qrFile.QrFile {
+    newFile := new(qrFile.QrFile)
+    newFile.Fname = outputFilename
+    if info := elements.FileInfo(); info != nil && !o.outFileGiven {
+        newFile.Fname = filepath.Join(o.outDir, info.Name)
+        newFile.Mode, newFile.ModTime = info.Mode, info.ModTime
+    }
+    return newFile
+}
+
+// outputPath returns the file the restored data is written to: --out in the output directory, or - for stdout
+func (o outputOptions) outputPath() string {
+    if o.outFile == "-" {
+        return o.outFile
+    }
+    return filepath.Join(o.outDir, o.outFile)
+}
+
+// writeResult writes the restored data to its file or, if the name is -, to stdout. With --archiveFormat, the data is
+// written as tar or zip archive (see qrFile.ConvertArchive). An existing file is only replaced with --overwrite.
+func (o outputOptions) writeResult(newFile *qrFile.QrFile) error {
+    newFile.Overwrite = o.overwriteOutput
+    if newFile.Fname != "-" && len(o.archiveFormat) == 0 {
+        return overwriteHint(newFile.ToFile())
+    }
+    if newFile.Fname == "-" {
+        if len(o.archiveFormat) > 0 {
+            return newFile.WriteArchive(os.Stdout, o.archiveFormat)
+        }
+        _, err := os.Stdout.Write(newFile.Data)
+        return err
+    }
+    out, err := qrFile.CreateAtomic(newFile.Fname, o.overwriteOutput)
+    if err != nil {
+        return overwriteHint(err)
+    }
+    defer out.Abort()
+    err = newFile.WriteArchive(out, o.archiveFormat)
+    if err != nil {
+        return err
+    }
+    return overwriteHint(out.Commit())
+}
+
+// storeReceived restores the file from the codes collected by receiveFromStream or receiveFromClipboard
+func (o outputOptions) storeReceived(assembler *qrFile.Assembler, outputFilename string) error {
+    elements, err := assembler.Elements()
+    if err != nil {
+        return err
+    }
+    newFile := o.resultFile(elements, outputFilename)
+    err = elements.StoreRawData(newFile)
+    if err != nil {
+        return err
+    }
+    err = o.verifySignature(elements)
+    if err != nil {
+        return err
+    }
+    err = o.writeResult(newFile)
+    if err != nil {
+        return err
+    }
+    log.Printf("Done! Received %d codes in %s, wrote %s", elements.Len(), assembler.Stats().Elapsed.Round(time.Second), newFile.Fname)
+    return nil
+}
+
+// verifySignature checks the signature of a complete set with the public key given with --verifyKey, if any
+func (o outputOptions) verifySignature(elements *qrFile.QrElements) error {
+    if len(o.verifyKeyFile) == 0 {
+        return nil
+    }
+    key, err := qrFile.ReadVerifyKey(o.verifyKeyFile)
+    if err != nil {
+        return err
+    }
+    err = elements.VerifySignature(key)
+    if err != nil {
+        return err
+    }
+    log.Printf("The signature of the set is valid.")
+    return nil
+}
@@ -1,72 +1,482 @@
 package main
 
 import (
-    "flag"
+    "archive/zip"
+    "bufio"
+    "context"
+    "crypto/ecdh"
+    "errors"
     "fmt"
     "github.com/Schokomuesl1/qrFile"
-    "html/template"
+    "github.com/spf13/cobra"
+    "image/png"
     "io"
     "io/ioutil"
     "log"
+    "log/slog"
+    "math"
+    "net"
     "net/http"
     "os"
     "path/filepath"
     "strconv"
     "strings"
+    "time"
 )
 
 func main() {
-    var outDir string
-    var imageDir string
-    var inFile string
-    var imagePrefix string
-    var outFile string
-    flag.StringVar(&outDir, "outputDirectory", "./output_dir", "Directory where result files are stored.")
-    flag.StringVar(&imageDir, "imageDirectory", "./img_dir", "Directory where resulting image files")
-    flag.StringVar(&imagePrefix, "imagePrefix", "img_", "Prefix of the resulting images in input mode.")
-    flag.StringVar(&inFile, "in", "", "File to be converted in input mode. Providing an input file selects input mode.")
-    flag.StringVar(&outFile, "out", "result", "File to store the extracted data to.")
-
-    interactive := flag.Bool("interactive", false, "If this is set, a small http server is started; the site provides a rudimentary interface to convert a file to QR images and display them.")
-    port := flag.Int("port", 8080, "Http port for the web server.")
-
-    flag.Parse()
-
-    if *interactive {
+    cmd := rootCommand()
+    cmd.AddCommand(encodeCommand(), decodeCommand(), verifyCommand(), infoCommand(), convertCommand(), compareCommand(), listCommand(), keygenCommand(), transmitCommand(), transferCommand(), benchCommand(), manCommand())
+    if cmd.Execute() != nil {
+        os.Exit(1)
+    }
+}
+
+// rootCommand defines the command line of qrFileApp & its flags. Shell completion (qrFileApp completion bash|zsh|fish)
+// & man pages (qrFileApp man) are generated from these definitions.
+func rootCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "qrFileApp [flags] [images...]",
+        Short: "Convert a file to a set of QR code images and restore it from them",
+        Long: `qrFileApp converts a file to a set of QR code images (input mode, selected by --in) and restores the file from
+the images given as arguments (output mode). With --interactive, a web interface is started instead.`,
+        Example: `  qrFileApp --in ~/test.txt --plain
+  qrFileApp img_dir/*`,
+        Args: cobra.ArbitraryArgs,
+        Run: func(cmd *cobra.Command, args []string) {
+            root.outFileGiven = cmd.Flags().Changed("out")
+            run(args)
+        },
+        PersistentPreRun: func(cmd *cobra.Command, args []string) {
+            qrFile.ToolDirectory = root.toolDirectory
+            if len(root.toolDirectory) == 0 {
+                qrFile.ToolDirectory = os.Getenv("QRFILE_TOOLS")
+            }
+            level, err := parseLogLevel(root.logLevel)
+            if err != nil {
+                log.Fatal(err)
+            }
+            if level != nil {
+                qrFile.SetLogger(slog.New(logHandler{level: *level}))
+            }
+            if root.debugMode {
+                qrFile.SetTrace(log.New(os.Stderr, "trace: ", log.Lmicroseconds))
+            }
+        },
+    }
+    cmd.PersistentFlags().IntVar(&root.workerCount, "jobs", 0, "Number of images rendered or read at the same time; the number of CPUs if 0.")
+    cmd.PersistentFlags().StringVar(&root.toolDirectory, "toolDirectory", "", "Directory holding external tools (zbarimg, ZXingReader, qrencode, zint, heif-convert, ffmpeg, gpg), searched before the usual installation directories and $PATH; $QRFILE_TOOLS may name it instead.")
+    cmd.PersistentFlags().StringVar(&root.logLevel, "logLevel", "info", "Messages of the library shown: debug (every step), info (e.g. files skipped, codes read after a retry), warn (e.g. images without codes), error or off.")
+    cmd.PersistentFlags().BoolVar(&root.debugMode, "debug", false, "Process codes and images strictly one after the other, in order, and trace every step on stderr, to reproduce problems with a set.")
+    flags := cmd.Flags()
+    flags.StringVar(&root.outDir, "outputDirectory", "./output_dir", "Directory where result files are stored.")
+    flags.StringVar(&root.imageDir, "imageDirectory", "./img_dir", "Directory where resulting image files are stored in input mode; an http(s) URL uploads them with PUT requests instead (e.g. to a WebDAV share, password in $QRFILE_STORAGE_PASSWORD).")
+    flags.StringVar(&root.imagePrefix, "imagePrefix", "img_", "Prefix of the resulting images in input mode.")
+    flags.StringVar(&root.inFile, "in", "", "File to be converted in input mode, - for stdin. Providing an input file selects input mode. A directory is stored as tar archive (see --unpack).")
+    flags.BoolVar(&root.archiveMode, "archive", false, "In input mode, store --in and the files and directories given as arguments together in a single tar archive, each under its name (restored with --unpack).")
+    flags.StringVar(&root.sourceURL, "url", "", "Fetch the data to be converted in input mode from this http(s) URL instead of a file (limited by --maxSize); selects input mode like --in.")
+    flags.StringVar(&root.sourceSHA256, "sha256", "", "With --url, refuse the data unless its SHA-256 (hex) matches, e.g. the checksum published along with a release.")
+    flags.StringVar(&root.outFile, "out", "result", "File to store the extracted data to; - writes it to stdout.")
+    flags.BoolVar(&root.overwriteOutput, "overwrite", false, "In output mode, replace an existing output file (or, with --unpack, existing files of the archive); by default, restoring refuses to touch them. A file is replaced only once its data is complete.")
+    flags.StringVar(&root.encoderName, "encoder", "internal", "QR encoder used to create the images: internal, qrencode (requires qrencode in $PATH) or another encoder registered in this build.")
+    flags.StringVar(&root.symbologyName, "symbology", "qr", "Kind of code the images hold: qr, datamatrix, aztec or pdf417 (aztec and pdf417 require zint in $PATH). Data Matrix and Aztec codes are read by the native decoder, PDF417 codes by --decoder zxing.")
+    flags.StringVar(&root.decoderName, "decoder", "", "Decoder used to read the images: native (pure Go), zbar (requires zbarimg in $PATH), zxing (requires ZXingReader in $PATH, reads all symbologies), another decoder registered in this build (e.g. vision on macOS) or exec: followed by a command printing the codes of the png file named by its last argument (or {}), one per line. By default, the native decoder is used and zbarimg (if installed) reads the images it finds no code in.")
+    flags.StringVar(&root.containerFile, "container", "", "In input mode, additionally store the set (chunks and images) in this .qrf container file.")
+    flags.StringVar(&root.armorFile, "armor", "", "In input mode, additionally write the set as ASCII armored text to this file (- for stdout), for channels where images are impossible. Output mode reads such files like images.")
+    flags.BoolVar(&root.estimateOnly, "estimate", false, "In input mode, only print what the set costs (codes, code size, pages at --sheetLayout and --pageSize) instead of writing images.")
+    flags.BoolVar(&root.encryptContainer, "encryptContainer", false, "Protect .qrf containers written with a password (AES-256 zip encryption, readable by 7-Zip and most zip tools); the password is taken like the passphrase of --encrypt.")
+    flags.StringVar(&root.zipFile, "zip", "", "In input mode, write the images and the manifest into this zip archive instead of the image directory.")
+    flags.StringVar(&root.textExport, "textExport", "", "In input mode, write the text of the codes instead of images, for media and channels holding only text: all codes into this file, one per line (- for stdout), or, if it names a directory (an existing one or ending with /), each code into a numbered .txt file (named like the images) with the manifest. Output mode reads .txt files as such text.")
+    flags.StringVar(&root.tiffFile, "tiff", "", "In input mode, additionally store all images as pages of this multipage TIFF file.")
+    flags.StringVar(&root.pdfFile, "pdf", "", "In input mode, additionally write all codes to this printable PDF file, several per page with their number and the file name below each (see --sheetLayout and --pageSize).")
+    flags.StringVar(&root.htmlFile, "html", "", "In input mode, additionally write all codes to this self-contained HTML page (images embedded), laid out for printing like --pdf.")
+    flags.StringVar(&root.sheetLayout, "sheetLayout", "2x3", "Codes per page of the --pdf output, as columns x rows.")
+    flags.StringVar(&root.pageSize, "pageSize", "a4", "Page size of the --pdf output: a4 or letter.")
+    flags.StringVar(&root.gifFile, "gif", "", "In input mode, additionally store all images as frames of this animated GIF, e.g. for transfer to a phone camera.")
+    flags.StringVar(&root.coverFile, "cover", "", "In input mode, additionally write a printable cover sheet listing the hash of every code and holding the manifest in a code, to check printed pages against; PDF if the name ends with .pdf, HTML otherwise.")
+    flags.StringVar(&root.framesDir, "frames", "", "In input mode, additionally write the frames of the animated GIF as numbered png images (frame_00000.png, ...) to this directory, e.g. for a slide show or to make a video.")
+    flags.IntVar(&root.streamOptions.FrameDelay, "frameDelay", qrFile.DefaultFrameDelay, "Time each frame of the animated GIF is shown, in 1/100 s.")
+    flags.Float64Var(&root.frameRate, "fps", 0, "Frames shown per second in the animated GIF and the frame sequence; overrides --frameDelay (at most 100).")
+    flags.IntVar(&root.streamOptions.Interleave, "interleave", 0, "Distance between consecutive frames of the animated GIF (in codes), so a capture glitch does not lose adjacent codes; 1 keeps the order, 0 selects it automatically.")
+    flags.IntVar(&root.streamOptions.Repeat, "repeat", 1, "Show each code in this many consecutive frames of the animated GIF, to compensate for camera artifacts.")
+    flags.BoolVar(&root.streamOptions.RepeatSpread, "repeatSpread", false, "With --repeat, repeat the whole sequence within the loop instead of showing the frames of a code in a row.")
+    flags.DurationVar(&root.streamOptions.Duration, "duration", 0, "Desired length of the loop of the animated GIF (e.g. 90s); frame delay and repetitions are derived from it.")
+    flags.BoolVar(&root.streamOptions.Calibration, "calibration", false, "Start the animated GIF with a calibration frame (focus target, black and white levels) for camera receivers.")
+    flags.IntVar(&root.fountainFrames, "fountain", 0, "Fill the animated GIF with this many fountain frames instead of the codes: each frame combines some blocks of the data, and any frames slightly more than the blocks restore it, regardless of order and of frames missed (read with --receive). 0 shows the codes.")
+    flags.IntVar(&root.streamOptions.SyncInterval, "syncInterval", 0, "Insert a sync frame announcing the set parameters before every this many codes of the animated GIF (0 disables sync frames).")
+    flags.BoolVar(&root.showProgress, "progress", false, "Show the progress of writing images, reading input files and restoring the data on stderr.")
+    flags.BoolVar(&root.textInput, "text", false, "In output mode, read the text of scanned codes (one code per line) from the given files or stdin instead of images.")
+    flags.BoolVar(&root.receiveCodes, "receive", false, "In output mode, read the text of scanned codes from stdin as they arrive (e.g. from zbarcam --raw), report the progress and restore the file once all codes were seen.")
+    flags.BoolVar(&root.watchInput, "watch", false, "In output mode, watch the directory given (e.g. the folder a network scanner saves to), read the images as they appear, report the missing codes and restore the file once the set is complete.")
+    flags.BoolVar(&root.watchClipboard, "clipboard", false, "In output mode, watch the clipboard for the text of scanned codes (e.g. pasted from a phone scanner app), add every new code and restore the file once all codes were seen.")
+    flags.BoolVar(&root.transcriptionInput, "transcription", false, "In output mode, read transcriptions printed below the codes (e.g. OCR output) from the given files or stdin instead of images.")
+    flags.BoolVar(&root.writeChecksums, "checksums", false, "In input mode, write a SHA256SUMS file (img_SHA256SUMS with the default prefix) listing the images and the manifest, to verify copies of the set with sha256sum -c or the list command.")
+    flags.BoolVar(&root.parameterChunk, "parameterChunk", false, "In input mode, write the parameter code of the set (img_params.png with the default prefix): format, codec, chunk size and transforms, so decoding configures itself from the images alone.")
+    flags.BoolVar(&root.contentNames, "contentNames", false, "In input mode, name the images after a short hash of their code (img_3_1a2b3c4d.png), so duplicates and images of different sets are obvious from the names.")
+    flags.BoolVar(&root.transcribe, "transcribe", false, "In input mode, print a checksummed transcription of the data below each code, for recovery by OCR or typing.")
+    flags.BoolVar(&root.printDigest, "digest", false, "In input mode, print the number of each code and the first 8 characters of its SHA-256 (as listed in the manifest) above it, to sort and check printed pages by eye.")
+    root.addRenderFlags(flags)
+    flags.BoolVar(&root.structuredAppend, "structuredAppend", false, "In input mode, also link the codes as a QR Structured Append sequence (at most 16 codes, internal encoder), so standard QR readers recognize them as one message.")
+    flags.BoolVar(&root.printCaption, "caption", false, "In input mode, print a caption below each code: the file name, \"chunk <number>/<count>\", the date and the first 8 characters of its SHA-256, to tell printed codes apart.")
+    flags.BoolVar(&root.paperKey, "paperkey", false, "Store a small secret (up to 1024 bytes) in a single code on a printable page, or restore it from such a page (or its text with --text). The secret is encrypted if a passphrase is given (see --encrypt).")
+    flags.BoolVar(&root.compressData, "compress", false, "In input mode, compress the data before encoding (see --compression); the manifest and, in plain format, the first code record it for decoding.")
+    flags.StringVar(&root.compression, "compression", "gzip", "Compression of --compress: gzip or zstd (faster, usually smaller).")
+    flags.BoolVar(&root.encrypt, "encrypt", false, "In input mode, encrypt the data (AES-256-GCM, key derived from the passphrase with argon2id) before encoding; with --paperkey, encrypt the secret. The passphrase is taken from --passphraseFile, $QRFILE_PASSPHRASE or --keyring, or asked for on the terminal; --keyFile selects a raw key instead.")
+    flags.StringVar(&root.passphraseFile, "passphraseFile", "", "Read the passphrase of encrypted data from this file instead of asking for it.")
+    flags.StringVar(&root.keyFile, "keyFile", "", "Encrypt or decrypt with a raw 32 byte key read from this file (binary, hex or base64) instead of a passphrase; $QRFILE_KEY may hold the key as hex or base64 instead.")
+    flags.StringVar(&root.keyringName, "keyring", "", "Name of the passphrase in the OS keyring (Keychain, Secret Service or Windows Credential Manager).")
+    flags.BoolVar(&root.storeKeyring, "storeKeyring", false, "Store the passphrase used in the OS keyring under the name given with --keyring.")
+    flags.StringVar(&root.recipients, "recipients", "", "In input mode, encrypt the data for these X25519 public keys (comma separated files or keys as base64; see the keygen command) before encoding, so only the holders of the private keys restore it.")
+    flags.StringVar(&root.identityFile, "identity", "", "In output mode, decrypt data encrypted for recipients with the X25519 private key in this file (PEM as written by keygen or openssl genpkey -algorithm x25519).")
+    flags.StringVar(&root.pgpRecipients, "pgpRecipients", "", "In input mode, encrypt the data with gpg for these recipients (comma separated key or user IDs) before encoding; the manifest records it for decoding.")
+    flags.StringVar(&root.pgpSigner, "pgpSign", "", "In input mode, sign the data with gpg using this key (key or user ID) before encoding.")
+    flags.BoolVar(&root.pgpDecode, "pgp", false, "In output mode, pass the restored data to gpg for decryption even if no manifest or container says it is protected.")
+    flags.StringVar(&root.selectedSet, "set", "", "In output mode, restore only this set if the images contain several sets: its set ID (e.g. 1a2b3c4d) or its number as listed in the error message.")
+    flags.StringVar(&root.onlyIndices, "only", "", "In input mode, only render the images with these numbers again (e.g. 3,7,12 for img_3.png, img_7.png and img_12.png). The input may be the original file or a .qrf container of the set.")
+    flags.StringVar(&root.levelName, "level", "L", "Error correction level of the codes: L, M, Q or H.")
+    flags.StringVar(&root.levelList, "levels", "", "Error correction levels of single codes, overriding --level, e.g. 0:H to protect the first code.")
+    flags.Uint64Var(&root.chunkSize, "chunkSize", 0, "Payload characters per code (plain and compact format only); the default of the format if 0.")
+    flags.IntVar(&root.symbolVersion, "symbolVersion", 0, "Largest version of the QR codes (1 to 40, a code of version v has 17+4v modules per side); unless --chunkSize is given, the codes are filled up to this version at the error correction level (implies --plain unless --compact is given).")
+    flags.StringVar(&root.codecName, "codec", "hex", "Encoding of the payload in the codes: hex, base64 or base45 (implies --plain). base64 needs about a third fewer codes, base45 (QR alphanumeric mode, internal encoder only) about half as many as hex.")
+    flags.BoolVar(&root.padCodes, "pad", false, "Render all codes in the QR version of the largest one, so they have the same size, e.g. to fill equal slots on paper (internal encoder only).")
+    flags.Uint64Var(&root.codeCount, "count", 0, "Split the data into exactly this many codes, e.g. to fit a fixed number of slots on paper (implies --plain).")
+    flags.Uint64Var(&root.maxCodes, "maxCodes", 1000, "Fail if the data needs more codes than this (0 disables the check).")
+    flags.BoolVar(&root.strictDecode, "strict", false, "In output mode, abort if any image can not be read or holds a code which is not part of a set, instead of skipping it.")
+    flags.BoolVar(&root.retryDecode, "retry", false, "In output mode, decode images which can not be read again after preprocessing (black and white at several thresholds and adaptively, a sweep of gamma and contrast corrections, rotation, and for photos perspective correction, deskewing and sharpening) and, with another --decoder, using zbar.")
+    flags.DurationVar(&root.retryBudget, "retryBudget", qrFile.DefaultRetryPolicy.Budget, "With --retry, time spent on the further attempts for a single image before giving up (0 does not limit the time).")
+    flags.BoolVar(&root.showSymbols, "symbols", false, "In output mode, list the codes read with the details reported by the decoder (quality, symbol version, orientation) and the attempts needed, to find borderline prints.")
+    flags.BoolVar(&root.streamRestore, "stream", false, "In output mode, decode the images one after another and write the data while they are read, so large sets are restored without holding the data in memory. Transforms, --retry, --set and the other output options do not apply.")
+    flags.StringVar(&root.sessionPath, "session", "", "In output mode, collect the codes of several batches of images in this session file; the file is restored (and the session file removed) once the set is complete.")
+    flags.StringVar(&root.salvageMode, "salvage", "", "In output mode, restore an incomplete or damaged set as far as possible instead of failing: zeros fills the place of missing codes with zero bytes, skip leaves it out. The holes are listed, and the exit status still reports the damage.")
+    flags.StringVar(&root.quarantineDir, "quarantine", "", "In output mode, move images which can not be read to this directory, along with a list of the reasons (quarantine.txt).")
+    flags.DurationVar(&qrFile.DecodeTimeout, "decodeTimeout", qrFile.DecodeTimeout, "Time zbarimg (or heif-convert) may take for a single image before it is stopped and the image counted as failed (0 disables the limit).")
+    flags.Float64Var(&qrFile.VideoFrameRate, "videoRate", 0, "In output mode, frames per second read from video files (screen recordings of the codes, read with ffmpeg); 0 reads every frame.")
+    flags.Int64Var(&root.maxFileSize, "maxSize", root.maxFileSize, "Refuse input files and restored data larger than this many bytes (0 disables the check).")
+    flags.StringVar(&root.archiveFormat, "archiveFormat", "", "In output mode, write the restored tar archive (e.g. of a directory given with --in) as tar or zip stream to --out, e.g. --out - | tar x, instead of the raw data.")
+    flags.BoolVar(&root.unpackArchive, "unpack", false, "In output mode, unpack the restored tar archive (e.g. of a directory given with --in) into the output directory instead of writing it to a file.")
+    flags.BoolVar(&root.archiveOptions.Ownership, "preserveOwner", false, "Record owner and group of the files of a directory in input mode; apply them when unpacking in output mode (usually requires root).")
+    flags.BoolVar(&root.archiveOptions.Xattrs, "preserveXattrs", false, "Record the extended attributes of the files of a directory in input mode; apply them when unpacking in output mode (Linux only).")
+    flags.BoolVar(&root.alignFiles, "align", false, "In input mode, start a new code at each file of a directory, so a lost code only damages the files it holds and --extract needs fewer codes. Needs more codes.")
+    flags.StringVar(&root.hiddenPolicy, "hidden", "include", "In input mode, include or exclude the hidden files and directories (dotfiles) of a directory; excluded ones are still stored if an --include pattern names them.")
+    flags.StringVar(&root.includePatterns, "include", "", "In input mode, store only the files of a directory matching these gitignore-style patterns (comma separated, e.g. src/**,*.md).")
+    flags.StringVar(&root.excludePatterns, "exclude", "", "In input mode, skip the entries of a directory matching these gitignore-style patterns (comma separated, e.g. node_modules,*.o,/build).")
+    flags.BoolVar(&root.archiveOptions.Symlinks, "preserveLinks", false, "Store symlinks of a directory with their target instead of the file they point to in input mode; restore them when unpacking in output mode.")
+    flags.StringVar(&root.extractPath, "extract", "", "In output mode, restore only this file (or directory) of a tar archive encoded by the set into the output directory. With the manifest of the set among the inputs, only the images holding it are decoded.")
+    flags.BoolVar(&root.transcodeSet, "transcode", false, "In output mode, write the restored set as new images (using --plain, --compact, --chunkSize, --level and --encoder) instead of restoring the file.")
+    flags.BoolVar(&root.plainFormat, "plain", false, "Create short codes with a plain \"QRF v2 <number>/<count>\" header which can be read by any scanner app.")
+    flags.BoolVar(&root.recordFileInfo, "fileInfo", false, "In input mode, record the name, size, modification time and mode of the input file in the set (in the first code in plain format, and in the manifest); output mode then restores the file under this name unless --out is given.")
+    flags.StringVar(&root.metaCreator, "creator", "", "In input mode, record who created the set in its metadata (in the first code in plain format, and in the manifest), along with the time; shown by the info command.")
+    flags.StringVar(&root.metaComment, "comment", "", "In input mode, record a comment on the set in its metadata, e.g. what the data is good for.")
+    flags.StringVar(&root.metaContentType, "contentType", "", "In input mode, record the media type of the data (e.g. text/plain) in the metadata of the set.")
+    flags.BoolVar(&root.integrityFields, "integrity", false, "Add the CRC-32 of its payload to the header of each code and the SHA-256 of the data to the first one, so misread codes and corrupted data are detected (implies --plain).")
+    flags.StringVar(&root.signKeyFile, "signKey", "", "In input mode, sign the data with this Ed25519 private key (PEM as written by openssl genpkey -algorithm ed25519, or the 32 byte seed as binary, hex or base64); the signature is recorded in the first code in plain format, and in the manifest.")
+    flags.StringVar(&root.verifyKeyFile, "verifyKey", "", "In output mode, refuse to write the data unless the signature of the set is valid for this Ed25519 public key (PEM or the 32 bytes as binary, hex or base64).")
+    flags.Uint64Var(&root.parityCodes, "parity", 0, "In input mode, add this many parity codes (Reed-Solomon), so the file is restored even if as many codes are lost (implies --plain; at most 256 codes in total).")
+    flags.BoolVar(&root.compactFormat, "compact", false, "Create codes with a binary header of a few bytes and the payload in base64 instead of hex, for the fewest codes; only read by qrFile.")
+
+    flags.BoolVar(&root.interactive, "interactive", false, "If this is set, a small http server is started; the site provides a rudimentary interface to convert a file to QR images and display them.")
+    flags.IntVar(&root.port, "port", 8080, "Http port for the web server.")
+    flags.StringVar(&root.tlsCert, "tlsCert", "", "Certificate of the web server and the gRPC service (PEM); with --tlsKey, both use TLS (HTTPS).")
+    flags.StringVar(&root.tlsKey, "tlsKey", "", "Private key (PEM) of the certificate given with --tlsCert.")
+    flags.StringVar(&root.autocertHosts, "autocert", "", "Obtain the certificate of the web server and the gRPC service from Let's Encrypt for these host names (comma separated), so both use TLS (HTTPS); port 443 (--port) has to be reachable, port 80 is used for the challenges if possible.")
+    flags.StringVar(&root.autocertCache, "autocertCache", "autocert", "Directory caching the certificates obtained with --autocert.")
+    flags.StringVar(&root.authUser, "authUser", "", "Require this user name and the password in $QRFILE_WEB_PASSWORD (basic authentication) for every request to the web server and every call of the gRPC service.")
+    flags.StringVar(&root.authToken, "authToken", "", "Require this token (Authorization: Bearer <token>, for gRPC in the authorization metadata) for every request to the web server and every call of the gRPC service, e.g. for API clients; $QRFILE_WEB_TOKEN may give it instead. With --authUser, either is accepted.")
+    flags.Int64Var(&root.maxUpload, "maxUpload", root.maxUpload, "Refuse uploads to the web server larger than this many bytes in total (all files of a request; each file is limited by --maxSize as well, 0 disables the check).")
+    flags.DurationVar(&root.retention, "retention", 24*time.Hour, "Time after which the web server deletes generated sets, received codes and restored files (0 keeps them until the server is stopped).")
+    flags.DurationVar(&root.sessionTimeout, "sessionTimeout", 24*time.Hour, "Time without requests after which the web server deletes the session of a client and its temporary files; the sets it created are no longer shown to it.")
+    flags.IntVar(&root.grpcPort, "grpcPort", 0, "If set, a gRPC service (see grpcserver/qrfile.proto) is started on this port, secured like the web server (--tlsCert or --autocert, --authUser or --authToken).")
+
+    cmd.RegisterFlagCompletionFunc("level", completeValues("L", "M", "Q", "H"))
+    cmd.RegisterFlagCompletionFunc("encoder", completeValues(qrFile.EncoderNames()...))
+    cmd.RegisterFlagCompletionFunc("symbology", completeValues("qr", "datamatrix", "aztec", "pdf417"))
+    cmd.RegisterFlagCompletionFunc("codec", completeValues("hex", "base64", "base45"))
+    cmd.RegisterFlagCompletionFunc("compression", completeValues("gzip", "zstd"))
+    cmd.RegisterFlagCompletionFunc("salvage", completeValues("zeros", "skip"))
+    cmd.RegisterFlagCompletionFunc("pageSize", completeValues("a4", "letter"))
+    cmd.RegisterFlagCompletionFunc("hidden", completeValues("include", "exclude"))
+    cmd.RegisterFlagCompletionFunc("decoder", completeValues(qrFile.DecoderNames()...))
+    cmd.RegisterFlagCompletionFunc("archiveFormat", completeValues(qrFile.ArchiveFormats...))
+    return cmd
+}
+
+// completeValues returns a shell completion function offering a fixed list of flag values
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+    return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+        return values, cobra.ShellCompDirectiveNoFileComp
+    }
+}
+
+// run executes the mode selected by the flags; args are the input files of output mode
+func run(args []string) {
+
+    root.selectCoders()
+    level, err := qrFile.ParseLevel(root.levelName)
+    if err != nil {
+        log.Fatal(err)
+    }
+    root.rendering, err = root.parseRendering()
+    if err != nil {
+        log.Fatal(err)
+    }
+    root.encodeOptions = qrFile.EncodeOptions{Version: qrFile.VersionLegacy, ChunkSize: root.chunkSize, Count: root.codeCount, MaxCount: root.maxCodes, Level: level, Encoder: symbolEncoder, Symbology: symbology, Workers: root.workerCount, Sequential: root.debugMode, MaxFileSize: root.maxSize()}
+    root.encodeOptions.Levels, err = parseLevels(root.levelList)
+    if err != nil {
+        log.Fatal(err)
+    }
+    root.encodeOptions.Codec, err = qrFile.ParsePayloadCodec(root.codecName)
+    if err != nil {
+        log.Fatal(err)
+    }
+    root.encodeOptions.Integrity = root.integrityFields
+    root.encodeOptions.SymbolVersion = root.symbolVersion
+    root.encodeOptions.StructuredAppend = root.structuredAppend
+    root.encodeOptions.Pad = root.padCodes
+    root.encodeOptions.Observer = progressObserver(root.showProgress)
+    root.encodeOptions.Parity = root.parityCodes
+    root.encodeOptions.Metadata = root.setMetadata()
+    if len(root.signKeyFile) > 0 {
+        root.encodeOptions.Signer, err = qrFile.ReadSigningKey(root.signKeyFile)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    if root.plainFormat || root.codeCount > 0 || root.encodeOptions.Codec != qrFile.CodecHex || root.integrityFields || root.parityCodes > 0 {
+        root.encodeOptions.Version = qrFile.VersionPlain
+        if root.compactFormat {
+            log.Fatal("--compact can not be combined with --plain, --count, --codec, --integrity or --parity")
+        }
+    } else if root.compactFormat {
+        root.encodeOptions.Version = qrFile.VersionCompact
+    } else if root.symbolVersion > 0 {
+        // the legacy format has a fixed width, which needs a large version
+        root.encodeOptions.Version = qrFile.VersionPlain
+    }
+    if root.archiveMode {
+        if len(root.inFile) == 0 || len(root.sourceURL) > 0 {
+            log.Fatal("--archive requires a file or directory given with --in")
+        }
+        root.archiveInputs = args
+    }
+    if len(root.sourceURL) > 0 {
+        if len(root.inFile) > 0 {
+            log.Fatal("--url and --in can not be combined")
+        }
+        root.inFile = root.sourceURL
+    } else if len(root.sourceSHA256) > 0 {
+        log.Fatal("--sha256 requires --url")
+    }
+    if root.frameRate > 0 {
+        if root.frameRate > 100 {
+            log.Fatalf("--fps %g is too high, animated GIFs show at most 100 frames per second", root.frameRate)
+        }
+        root.streamOptions.FrameDelay = int(math.Round(100 / root.frameRate))
+    }
+
+    if root.grpcPort != 0 || root.interactive {
+        err := setupServerSecurity()
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    if root.grpcPort != 0 {
+        listener, err := net.Listen("tcp", ":"+strconv.Itoa(root.grpcPort))
+        if err != nil {
+            log.Fatalf("Unable to listen on port %d: %s", root.grpcPort, err)
+        }
+        log.Printf("Starting gRPC service on port %d", root.grpcPort)
+        if !root.interactive {
+            log.Fatal(newGRPCServer().Serve(listener))
+        }
+        go func() {
+            log.Fatal(newGRPCServer().Serve(listener))
+        }()
+    }
+
+    if root.interactive {
         // start web server instance.
-        log.Printf("Starting web server on port %d", *port)
+        log.Printf("Starting web server on port %d", root.port)
+        if err := qrFile.CheckDecoder(symbolDecoder); err != nil {
+            log.Printf("Warning: uploaded images can not be read: %s", err)
+        }
         http.HandleFunc("/", httpHandler)
         http.HandleFunc("/receive/", handleUploadedFile)
-        // create a temporary directory for the images:
-        tempDir, err := ioutil.TempDir(os.TempDir(), "qrFileTempDir")
-        if err != nil {
-            log.Fatal("Unable to create temporary directory...")
-            return
+        http.HandleFunc("/text/", handleTextPage)
+        http.HandleFunc("/sets/", handleSets)
+        http.HandleFunc("/api/v1/sets/", handleAPISets)
+        http.HandleFunc("/api/v1/estimate", handleEstimate)
+        http.HandleFunc("/api/v1/verify", handleAPIVerify)
+        http.HandleFunc("/api/v1/encode", handleAPIEncode)
+        http.HandleFunc("/api/v1/decode", handleAPIDecode)
+        http.HandleFunc("/api/v1/status", handleAPIStatus)
+        http.HandleFunc("/api/v1/status/", handleAPIStatus)
+        http.HandleFunc("/metrics", handleMetrics)
+        qrFile.SetMetrics(serverMetrics)
+        http.HandleFunc("/api/v1/uploads", handleAPIUploads)
+        http.HandleFunc("/api/v1/uploads/", handleAPIUploads)
+        http.HandleFunc("/decodetext/", handleTextDecode)
+        http.HandleFunc("/decode/", handleDecodePage)
+        http.HandleFunc("/decoded/", handleDecodedFile)
+        http.HandleFunc("/scan/", handleScanPage)
+        http.HandleFunc("/scancode/", handleScanCode)
+        http.HandleFunc("/scanresult/", handleScanResult)
+        if root.retention > 0 {
+            go purgeExpired()
         }
-        globTempDir = tempDir
-        log.Print("Created temporary image directory: ", tempDir)
-
-        // make sure we remove the temoporary directory when we are finished
-        defer os.RemoveAll(tempDir)
+        go expireSessions()
 
-        // serve the temporary folders contents as static data...
-        http.Handle("/img/", http.StripPrefix("/img/", http.FileServer(http.Dir(tempDir))))
-        // and start the web server on the defined port
-        http.ListenAndServe(":"+strconv.Itoa(*port), nil)
+        // images are rendered on demand (see handleAPISets), no files are kept; start the web server on the defined port
+        log.Fatal(serveWeb())
+    } else if root.paperKey {
+        if len(root.inFile) > 0 {
+            err := createPaperKey(root.inFile, root.imageDir, root.imagePrefix)
+            if err != nil {
+                log.Fatalf("Error while creating paper key for %s: %s", root.inFile, err)
+            }
+        } else {
+            err := restorePaperKey(args, filepath.Join(root.outDir, root.outFile))
+            if err != nil {
+                log.Fatalf("Error while restoring paper key: %s", err)
+            }
+        }
+    } else if len(root.onlyIndices) > 0 {
+        if len(root.inFile) == 0 {
+            log.Fatal("--only requires an input file (--in).")
+        }
+        err := rewriteQRFiles(root.inFile, root.imageDir, root.imagePrefix, root.onlyIndices)
+        if err != nil {
+            log.Fatalf("Error while rendering images of %s: %s", root.inFile, err)
+        }
     } else {
-        if len(inFile) > 0 {
-            err := createQRFilesFromFile(inFile, imageDir, imagePrefix)
+        if len(root.inFile) > 0 && root.estimateOnly {
+            err := estimateFile(root.inFile, root.encodeOptions)
+            if err != nil {
+                log.Fatalf("Error while estimating %s: %s", root.inFile, err)
+            }
+            return
+        }
+        if len(root.inFile) > 0 {
+            elements, err := createQRFilesFromFile(root.inFile, root.imageDir, root.imagePrefix)
             if err != nil {
-                log.Fatalf("Error while handling input file %s: %s", inFile, err)
+                log.Fatalf("Error while handling input file %s: %s", root.inFile, err)
+            }
+            if len(root.containerFile) > 0 {
+                err = root.packContainer(elements, root.containerFile)
+                if err != nil {
+                    log.Fatalf("Error while writing container %s: %s", root.containerFile, err)
+                }
+                log.Printf("Successfully wrote container %s.", root.containerFile)
+            }
+            if len(root.armorFile) > 0 {
+                err = writeArmor(elements, root.armorFile)
+                if err != nil {
+                    log.Fatalf("Error while writing armored set %s: %s", root.armorFile, err)
+                }
+                log.Printf("Successfully wrote armored set %s.", root.armorFile)
+            }
+            if len(root.tiffFile) > 0 {
+                err = elements.WriteTIFFFile(root.tiffFile)
+                if err != nil {
+                    log.Fatalf("Error while writing TIFF file %s: %s", root.tiffFile, err)
+                }
+                log.Printf("Successfully wrote multipage TIFF %s.", root.tiffFile)
+            }
+            if len(root.pdfFile) > 0 {
+                err = writeSheets(elements, root.pdfFile, inputName(root.inFile))
+                if err != nil {
+                    log.Fatalf("Error while writing PDF file %s: %s", root.pdfFile, err)
+                }
+                log.Printf("Successfully wrote PDF %s.", root.pdfFile)
+            }
+            if len(root.htmlFile) > 0 {
+                err = writeGallery(elements, root.htmlFile, inputName(root.inFile))
+                if err != nil {
+                    log.Fatalf("Error while writing HTML file %s: %s", root.htmlFile, err)
+                }
+                log.Printf("Successfully wrote HTML page %s.", root.htmlFile)
+            }
+            if len(root.gifFile) > 0 && root.fountainFrames > 0 {
+                err = writeFountainGIF(elements, root.gifFile)
+                if err != nil {
+                    log.Fatalf("Error while writing GIF file %s: %s", root.gifFile, err)
+                }
+            } else if len(root.gifFile) > 0 {
+                err = elements.WriteGIFFile(root.gifFile, root.streamOptions)
+                if err != nil {
+                    log.Fatalf("Error while writing GIF file %s: %s", root.gifFile, err)
+                }
+                log.Printf("Successfully wrote animated GIF %s (loop of %s).", root.gifFile, elements.LoopDuration(root.streamOptions))
+            }
+            if len(root.framesDir) > 0 {
+                err = os.MkdirAll(root.framesDir, 0755)
+                if err == nil {
+                    err = elements.WriteFrames(root.framesDir, root.framePrefix, root.streamOptions)
+                }
+                if err != nil {
+                    log.Fatalf("Error while writing frames to %s: %s", root.framesDir, err)
+                }
+                rate := elements.FrameRate(root.streamOptions)
+                log.Printf("Successfully wrote the frames to %s; play them at %.4g frames per second, e.g. ffmpeg -framerate %.4g -i %s frames.mp4", root.framesDir, rate, rate, filepath.Join(root.framesDir, root.framePrefix+"%05d.png"))
+            }
+            if len(root.coverFile) > 0 {
+                sheet, err := elements.CoverSheet(inputName(root.inFile))
+                if err == nil {
+                    err = sheet.WriteFile(root.coverFile)
+                }
+                if err != nil {
+                    log.Fatalf("Error while writing cover sheet %s: %s", root.coverFile, err)
+                }
+                log.Printf("Successfully wrote cover sheet %s.", root.coverFile)
             }
         } else {
             // default to output mode
-            if len(flag.Args()) == 0 {
+            if root.receiveCodes {
+                err := receiveFromStream(os.Stdin, root.outputPath())
+                if err != nil {
+                    log.Fatalf("Error while receiving codes: %s", err)
+                }
+                return
+            }
+            if root.watchClipboard {
+                err := receiveFromClipboard(root.outputPath())
+                if err != nil {
+                    log.Fatalf("Error while receiving codes: %s", err)
+                }
+                return
+            }
+            if len(args) == 0 && !root.textInput && !root.transcriptionInput {
                 log.Fatal("Output mode requires at least one input file.")
             }
-            err := restoreFileFromQRImages(flag.Args(), fmt.Sprintf("%s/%s", outDir, outFile))
+            if len(root.extractPath) > 0 {
+                err := extractFromQRImages(args, root.extractPath, root.outDir)
+                if err != nil {
+                    log.Fatalf("Error while extracting %s: %s", root.extractPath, err)
+                }
+                return
+            }
+            if root.transcodeSet {
+                err := transcodeQRImages(args, root.imageDir, root.imagePrefix)
+                if err != nil {
+                    log.Fatalf("Error while transcoding %s: %s", args, err)
+                }
+                return
+            }
+            if root.streamRestore {
+                if root.watchInput {
+                    log.Fatal("--watch can not be combined with --stream, the set has to be complete before the data is written")
+                }
+                if len(root.verifyKeyFile) > 0 {
+                    log.Fatal("--verifyKey can not be combined with --stream, the data is written before the signature can be checked")
+                }
+                err := streamFileFromQRImages(args, root.outputPath())
+                if err != nil {
+                    log.Fatalf("Error while handling output files %s: %s", args, err)
+                }
+                return
+            }
+            err := restoreFileFromQRImages(args, root.outputPath())
             if err != nil {
-                log.Fatalf("Error while handling output files %s: %s", flag.Args(), err)
+                log.Fatalf("Error while handling output files %s: %s", args, err)
             }
         }
     }
@@ -74,43 +484,858 @@ func main() {
 
 // methods encapsulating qrFile both directions (file -> qr, qr -> file)
 
-func createQRFilesFromFile(inFile string, imgDir string, imgPrefix string) error {
+func createQRFilesFromFile(inFile string, imgDir string, imgPrefix string) (*qrFile.QrElements, error) {
     log.Printf("Creating QR codes for file %s into folder %s using image prefix %s.", inFile, imgDir, imgPrefix)
-    qrf, err := qrFile.FromFile(inFile)
+    elements, err := elementsFromFile(inFile, root.encodeOptions)
+    if err != nil {
+        return nil, err
+    }
+    log.Printf("Successfully converted file to %d QR codes", len(elements.Elements))
+    if len(root.zipFile) > 0 {
+        err = elements.WriteZipFile(root.zipFile)
+        if err != nil {
+            return nil, err
+        }
+        log.Printf("Successfully wrote %d png files to %s.", len(elements.Elements), root.zipFile)
+        return elements, nil
+    }
+    if len(root.textExport) > 0 {
+        err = writeTextExport(elements, root.textExport, imgPrefix)
+        if err != nil {
+            return nil, err
+        }
+        log.Printf("Successfully wrote the text of %d codes to %s.", len(elements.Elements), root.textExport)
+        return elements, nil
+    }
+    storage, err := imageStorage(imgDir)
+    if err != nil {
+        return nil, err
+    }
+    err = elements.WritePNGsTo(storage, imgPrefix)
+    if err != nil {
+        return nil, err
+    }
+    log.Printf("Successfully wrote %d png files in %s.", len(elements.Elements), imgDir)
+    return elements, nil
+}
+
+// imageStorage returns where images are written: the local directory dir or, if dir is an http(s) URL, a remote target
+// accepting PUT requests (e.g. WebDAV). Like passphrases, its password is not taken on the command line but from
+// $QRFILE_STORAGE_PASSWORD.
+func imageStorage(dir string) (qrFile.Storage, error) {
+    if !qrFile.IsStorageURL(dir) {
+        return qrFile.DirStorage(dir), nil
+    }
+    storage, err := qrFile.NewHTTPStorage(dir)
+    if err != nil {
+        return nil, err
+    }
+    if len(storage.Password) > 0 {
+        return nil, errors.New("The image directory URL must not contain a password; use $QRFILE_STORAGE_PASSWORD")
+    }
+    storage.Password = os.Getenv("QRFILE_STORAGE_PASSWORD")
+    return storage, nil
+}
+
+// inputName returns the name of an input file as shown on sheets & pages: its base name, or stdin for -
+func inputName(fname string) string {
+    if fname == "-" {
+        return "stdin"
+    }
+    return filepath.Base(fname)
+}
+
+// elementsFromFile splits a file, a directory or the data at an http(s) URL into elements using the given options (usually
+// the ones selected on the command line)
+func elementsFromFile(inFile string, options qrFile.EncodeOptions) (*qrFile.QrElements, error) {
+    qrf, options, err := dataFromFile(inFile, options)
+    if err != nil {
+        return nil, err
+    }
+    elements, err := qrFile.GetElementsWithOptions(qrf.ToHexString(), options)
+    if err != nil {
+        return nil, err
+    }
+    elements.Transcribe = root.transcribe
+    elements.Digest = root.printDigest
+    elements.Caption = root.captionFor(inFile)
+    elements.Rendering = root.rendering
+    elements.ContentNames = root.contentNames
+    elements.Checksums = root.writeChecksums
+    elements.ParameterChunk = root.parameterChunk
+    if len(root.pgpRecipients) > 0 || len(root.pgpSigner) > 0 {
+        // recorded for versions reading the data without transforms
+        elements.PGP = &qrFile.PGPInfo{Recipients: splitList(root.pgpRecipients), Signer: root.pgpSigner}
+    }
+    return elements, nil
+}
+
+// estimateFile prints what the set of a file, a directory or the data at an http(s) URL costs (see --estimate)
+func estimateFile(inFile string, options qrFile.EncodeOptions) error {
+    qrf, options, err := dataFromFile(inFile, options)
     if err != nil {
         return err
     }
-    qrf.ReadFile()
-    elements, err := qrFile.GetElements(qrf.ToHexString())
+    sheet, err := sheetOptions()
     if err != nil {
         return err
     }
-    log.Printf("Successfully converted file to %d QR codes", len(elements.Elements))
-    err = elements.WritePNGs(imgDir, imgPrefix)
+    estimate, err := qrFile.Estimate(uint64(len(qrf.Data)), options, sheet)
     if err != nil {
         return err
     }
-    log.Printf("Successfully wrote %d png files in %s.", len(elements.Elements), imgDir)
+    fmt.Printf("%s: %d bytes\n", inFile, len(qrf.Data))
+    fmt.Printf("Codes:      %d, %d bytes of data each\n", estimate.Codes, estimate.PayloadBytes)
+    fmt.Printf("Code size:  version %d, %dx%d modules (%d characters)\n", estimate.Version, estimate.Modules, estimate.Modules, estimate.TextLength)
+    fmt.Printf("Pages:      %d at %s codes per page (%s), modules of %.2f mm\n", estimate.Pages, root.sheetLayout, root.pageSize, estimate.ModuleSize)
     return nil
 }
 
-func restoreFileFromQRImages(fileList []string, outputFilename string) error {
-    log.Printf("Extracting data from input %s, writing to file %s.", strings.Join(fileList, ","), outputFilename)
-    var newElem = new(qrFile.QrElements)
-    err := newElem.FromPNGs(fileList)
+// dataFromFile reads a file, a directory, the data at an http(s) URL or stdin (-) & applies the transforms selected on
+// the command line; returns the data & the options completed for it
+func dataFromFile(inFile string, options qrFile.EncodeOptions) (*qrFile.QrFile, qrFile.EncodeOptions, error) {
+    var qrf *qrFile.QrFile
+    var err error
+    if info, statErr := os.Stat(inFile); root.archiveMode || (statErr == nil && info.IsDir()) {
+        var report *qrFile.ArchiveReport
+        settings := root.archiveOptions
+        settings.Include, settings.Exclude = splitList(root.includePatterns), splitList(root.excludePatterns)
+        settings.MaxSize = root.maxSize()
+        switch root.hiddenPolicy {
+        case "include":
+        case "exclude":
+            settings.ExcludeHidden = true
+        default:
+            return nil, options, errors.New(fmt.Sprintf("Invalid value %s for --hidden, expected include or exclude", root.hiddenPolicy))
+        }
+        if root.archiveMode {
+            paths := append([]string{inFile}, root.archiveInputs...)
+            qrf, report, err = qrFile.FromPaths(paths, settings)
+            if err == nil {
+                log.Printf("Archived %s: %s", strings.Join(paths, ", "), report)
+            }
+        } else {
+            qrf, report, err = qrFile.FromDirectory(inFile, settings)
+            if err == nil {
+                log.Printf("Archived %s: %s", inFile, report)
+            }
+        }
+    } else if inFile == "-" {
+        var sum string
+        qrf, sum, err = qrFile.FromReader(os.Stdin, "stdin", qrFile.SourceOptions{MaxSize: root.maxSize(), SHA256: root.sourceSHA256})
+        if err == nil {
+            log.Printf("Read %d bytes from stdin, SHA-256 %s", len(qrf.Data), sum)
+        } else if errors.Is(err, qrFile.ErrChecksumMismatch) {
+            err = errors.New(fmt.Sprintf("The SHA-256 of the data is %s, expected %s", sum, root.sourceSHA256))
+        }
+    } else if qrFile.IsStorageURL(inFile) {
+        var sum string
+        qrf, sum, err = qrFile.FromURL(inFile, qrFile.SourceOptions{MaxSize: root.maxSize(), SHA256: root.sourceSHA256})
+        if err == nil {
+            log.Printf("Fetched %s: %d bytes, SHA-256 %s", inFile, len(qrf.Data), sum)
+        } else if errors.Is(err, qrFile.ErrChecksumMismatch) {
+            err = errors.New(fmt.Sprintf("The SHA-256 of the data is %s, expected %s", sum, root.sourceSHA256))
+        }
+    } else {
+        qrf = &qrFile.QrFile{Fname: inFile, MaxSize: root.maxSize()}
+        err = qrf.ReadFile()
+        if err == nil && root.recordFileInfo && options.File == nil {
+            options.File = qrf.Info()
+        }
+    }
+    if err != nil {
+        return nil, options, err
+    }
+    if root.recordFileInfo && options.File == nil {
+        return nil, options, errors.New("--fileInfo requires a file as input")
+    }
+    transforms, err := dataTransforms()
+    if err != nil {
+        return nil, options, err
+    }
+    var applied []qrFile.TransformInfo
+    if len(transforms) > 0 {
+        qrf.Data, applied, err = qrFile.ApplyTransforms(qrf.Data, transforms)
+        if err != nil {
+            return nil, options, err
+        }
+        options.Transforms = applied
+    }
+    if root.alignFiles {
+        if len(qrf.Boundaries) == 0 {
+            return nil, options, errors.New("--align requires a directory as input")
+        }
+        if len(applied) > 0 {
+            return nil, options, errors.New("--align can not be combined with --compress, --encrypt or gpg, the archive is transformed as a whole")
+        }
+        options.Align = qrf.Boundaries
+    }
+    return qrf, options, nil
+}
+
+// dataTransforms returns the transforms of the data selected on the command line, in the order they are applied:
+// compression, encryption, encryption for recipients & gpg
+func dataTransforms() ([]qrFile.Transform, error) {
+    transforms := make([]qrFile.Transform, 0)
+    if root.compressData {
+        transform, err := qrFile.GetTransform(root.compression)
+        if err != nil || (root.compression != "gzip" && root.compression != "zstd") {
+            return nil, errors.New(fmt.Sprintf("Invalid value %s for --compression, expected gzip or zstd", root.compression))
+        }
+        transforms = append(transforms, transform)
+    }
+    if root.encrypt {
+        key, err := root.getKey()
+        if err != nil {
+            return nil, err
+        }
+        transform := qrFile.EncryptTransform{Key: key}
+        if key == nil {
+            transform.Passphrase, err = root.getPassphrase(true, true)
+            if err != nil {
+                return nil, err
+            }
+        }
+        transforms = append(transforms, transform)
+    }
+    if len(root.recipients) > 0 {
+        transform := qrFile.RecipientTransform{}
+        for _, recipient := range splitList(root.recipients) {
+            key, err := readRecipient(recipient)
+            if err != nil {
+                return nil, err
+            }
+            transform.Recipients = append(transform.Recipients, key)
+        }
+        transforms = append(transforms, transform)
+    }
+    if len(root.pgpRecipients) > 0 || len(root.pgpSigner) > 0 {
+        transforms = append(transforms, qrFile.PGPTransform{Recipients: splitList(root.pgpRecipients), Signer: root.pgpSigner})
+    }
+    return transforms, nil
+}
 
+// transformNames lists the names of the transforms applied, e.g. "gzip, encrypt"
+func transformNames(applied []qrFile.TransformInfo) string {
+    names := make([]string, len(applied))
+    for i, info := range applied {
+        names[i] = info.Name
+    }
+    return strings.Join(names, ", ")
+}
+
+// reverseTransforms restores data transformed as described by applied; the passphrase is asked for if the data is
+// encrypted & none is configured
+func reverseTransforms(data []byte, applied []qrFile.TransformInfo) ([]byte, error) {
+    key, err := root.getKey()
+    if err != nil {
+        return nil, err
+    }
+    passphrase, err := root.getPassphrase(false, false)
+    if err != nil {
+        return nil, err
+    }
+    identities, err := root.getIdentities()
+    if err != nil {
+        return nil, err
+    }
+    transform := qrFile.EncryptTransform{Passphrase: passphrase, Key: key}
+    result, err := qrFile.ReverseTransforms(data, applied, []qrFile.Transform{transform, identities})
+    if errors.Is(err, qrFile.ErrKeyRequired) {
+        return nil, errors.New("The data is encrypted with a raw key; use --keyFile or $QRFILE_KEY")
+    }
+    if errors.Is(err, qrFile.ErrIdentityRequired) {
+        return nil, errors.New("The data is encrypted for recipients; give a private key with --identity")
+    }
+    if errors.Is(err, qrFile.ErrPassphraseRequired) {
+        transform.Passphrase, err = root.getPassphrase(true, false)
+        if err != nil {
+            return nil, err
+        }
+        result, err = qrFile.ReverseTransforms(data, applied, []qrFile.Transform{transform, identities})
+    }
+    return result, err
+}
+
+// splitList splits a comma separated list given on the command line, nil if it is empty
+func splitList(list string) []string {
+    if len(list) == 0 {
+        return nil
+    }
+    return strings.Split(list, ",")
+}
+
+// parseLogLevel parses the level of the messages shown (see --logLevel), nil for off
+func parseLogLevel(name string) (*slog.Level, error) {
+    if strings.EqualFold(name, "off") {
+        return nil, nil
+    }
+    level := new(slog.Level)
+    err := level.UnmarshalText([]byte(name))
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("Invalid log level %s, expected debug, info, warn, error or off", name))
+    }
+    return level, nil
+}
+
+// logHandler prints the messages of the library like the ones of the application, without their attributes
+type logHandler struct {
+    level slog.Level
+}
+
+func (h logHandler) Enabled(_ context.Context, level slog.Level) bool {
+    return level >= h.level
+}
+
+func (h logHandler) Handle(_ context.Context, record slog.Record) error {
+    log.Print(record.Message)
+    return nil
+}
+
+func (h logHandler) WithAttrs([]slog.Attr) slog.Handler {
+    return h
+}
+
+func (h logHandler) WithGroup(string) slog.Handler {
+    return h
+}
+
+// parseLevels parses a list of error correction levels of single codes like "0:H,5:Q"
+func parseLevels(list string) (map[uint64]qrFile.Level, error) {
+    if len(list) == 0 {
+        return nil, nil
+    }
+    levels := make(map[uint64]qrFile.Level)
+    for _, entry := range strings.Split(list, ",") {
+        parts := strings.Split(strings.TrimSpace(entry), ":")
+        if len(parts) != 2 {
+            return nil, errors.New(fmt.Sprintf("Invalid level %s, expected <number>:<level>", entry))
+        }
+        index, err := strconv.ParseUint(parts[0], 10, 64)
+        if err != nil {
+            return nil, errors.New(fmt.Sprintf("Invalid code number %s", parts[0]))
+        }
+        levels[index], err = qrFile.ParseLevel(parts[1])
+        if err != nil {
+            return nil, err
+        }
+    }
+    return levels, nil
+}
+
+// rewriteQRFiles renders selected images of a set again, taking the set from the original file or a .qrf container
+func rewriteQRFiles(inFile string, imgDir string, imgPrefix string, indexList string) error {
+    indices := make([]uint64, 0)
+    for _, entry := range strings.Split(indexList, ",") {
+        index, err := strconv.ParseUint(strings.TrimSpace(entry), 10, 64)
+        if err != nil {
+            return errors.New(fmt.Sprintf("Invalid image number %s", entry))
+        }
+        indices = append(indices, index)
+    }
+    var elements *qrFile.QrElements
+    var err error
+    if strings.HasSuffix(strings.ToLower(inFile), ".qrf") {
+        elements, err = root.unpackContainer(inFile)
+        if err == nil {
+            // the levels are recorded in the container
+            elements.Encoder = symbolEncoder
+            elements.Transcribe = root.transcribe
+            elements.Digest = root.printDigest
+            elements.Caption = root.captionFor("")
+            elements.Rendering = root.rendering
+            elements.StructuredAppend = root.structuredAppend
+            elements.ContentNames = root.contentNames
+        }
+    } else if root.encrypt || len(root.recipients) > 0 || len(root.pgpRecipients) > 0 || len(root.pgpSigner) > 0 {
+        // encryption creates a different message every time, so the images would not match the printed ones
+        return errors.New("Images of an encrypted set can only be rendered again from its .qrf container")
+    } else {
+        elements, err = elementsFromFile(inFile, root.encodeOptions)
+    }
+    if err != nil {
+        return err
+    }
+    storage, err := imageStorage(imgDir)
+    if err != nil {
+        return err
+    }
+    err = elements.RewritePNGsTo(storage, imgPrefix, indices)
+    if err != nil {
+        return err
+    }
+    log.Printf("Successfully wrote %d png files in %s.", len(indices), imgDir)
+    return nil
+}
+
+func restoreFileFromQRImages(fileList []string, outputFilename string) (err error) {
+    log.Printf("Extracting data from input %s, writing to file %s.", strings.Join(fileList, ","), outputFilename)
+    mode, err := root.salvage()
+    if err != nil {
+        return err
+    }
+    var newElem *qrFile.QrElements
+    if root.watchInput {
+        newElem, err = watchDirectory(fileList)
+    } else if len(root.sessionPath) > 0 {
+        var session *qrFile.Session
+        session, err = addToSession(fileList)
+        if err != nil || session == nil {
+            return err
+        }
+        // the session is only done once the file was restored
+        defer func() {
+            if err == nil {
+                err = session.Remove()
+            }
+        }()
+        newElem, err = session.Elements()
+    } else {
+        newElem, err = root.readElements(fileList)
+    }
     if err != nil {
         return err
     }
     log.Printf("Successfully read input files, now storing data...")
-    newFile := new(qrFile.QrFile)
-    newFile.Fname = outputFilename
-    err = newElem.StoreData(newFile)
+    // the manifest has to describe this set, not another one in the same directory
+    manifest, _ := findManifest(fileList)
+    described := manifest != nil && manifest.SetID == newElem.Elements[0].SetID
+    if described && newElem.File == nil {
+        newElem.File = manifest.File
+    }
+    if described && newElem.Signature == nil {
+        newElem.Signature = manifest.Signature
+    }
+    newFile := root.resultFile(newElem, outputFilename)
+    newElem.Observer = progressObserver(root.showProgress)
+    newElem.Salvage = mode
+    err = newElem.StoreRawData(newFile)
     if err != nil {
         return err
     }
+    damaged := newElem.Damage != nil && !newElem.Damage.Complete()
+    if damaged {
+        log.Printf("The set is incomplete or damaged, restoring what is left:\n%s", newElem.Damage)
+        // the holes in the data are reported once it was written
+        defer func() {
+            if err == nil {
+                err = errors.New(fmt.Sprintf("%s was restored only in part: %d of %d codes missing or damaged (about %d bytes).", newFile.Fname, len(newElem.Damage.Gaps), newElem.Damage.Elements, newElem.Damage.Lost))
+            }
+        }()
+    }
+    if damaged && len(root.verifyKeyFile) > 0 {
+        log.Printf("The signature of the set can not be verified on damaged data.")
+    } else if err = root.verifySignature(newElem); err != nil {
+        return err
+    }
+    applied := newElem.Transforms
+    if len(applied) == 0 && described {
+        applied = manifest.Transforms
+    }
+    if len(applied) == 0 {
+        // no manifest: the first code names the transforms in plain format
+        applied = newElem.AppliedTransforms()
+    }
+    if len(applied) == 0 && !described && (root.compressData || root.encrypt) {
+        // otherwise, the options of the command line tell how the data was transformed
+        if root.compressData {
+            applied = append(applied, qrFile.TransformInfo{Name: root.compression})
+        }
+        if root.encrypt {
+            applied = append(applied, qrFile.TransformInfo{Name: "encrypt"})
+        }
+        if root.pgpDecode {
+            applied = append(applied, qrFile.TransformInfo{Name: "pgp"})
+        }
+    }
+    if len(applied) > 0 && damaged {
+        // compressed or encrypted data can not be reversed with holes in it
+        log.Printf("The data was transformed (%s); the damaged data is written as it was stored in the codes.", transformNames(applied))
+    } else if len(applied) > 0 {
+        log.Printf("Reversing the transforms of the data (%s)...", transformNames(applied))
+        newFile.Data, err = reverseTransforms(newFile.Data, applied)
+        if err != nil {
+            return err
+        }
+    } else if !damaged && (root.pgpDecode || newElem.PGP != nil || (described && manifest.PGP != nil)) {
+        log.Printf("Decrypting the data using gpg...")
+        newFile.Data, err = qrFile.UnprotectPGP(newFile.Data)
+        if err != nil {
+            return err
+        }
+    }
+    if root.unpackArchive {
+        log.Printf("...done. Unpacking the archive into %s.", root.outDir)
+        settings := root.archiveOptions
+        settings.Overwrite = root.overwriteOutput
+        written, err := newFile.ToDirectory(root.outDir, settings)
+        if err != nil {
+            return err
+        }
+        log.Printf("Done! Successfully restored %d entries in %s", len(written), root.outDir)
+        return nil
+    }
     log.Printf("...done. Writing result file.")
-    err = newFile.ToFile()
+    err = root.writeResult(newFile)
+    if err != nil {
+        return err
+    }
+    log.Printf("Done! Successfully wrote %s", newFile.Fname)
+    return nil
+}
+
+// watchDirectory collects the codes of the images appearing in the directory given (see --watch) until the set is
+// complete, reporting the codes found & still missing after every batch of new images
+func watchDirectory(fileList []string) (*qrFile.QrElements, error) {
+    if len(fileList) != 1 {
+        return nil, errors.New("--watch requires a single directory")
+    }
+    watcher := qrFile.DirectoryWatcher{Dir: fileList[0], Decoder: symbolDecoder}
+    if root.retryDecode {
+        watcher.Retry = qrFile.DefaultRetryPolicy
+        watcher.Retry.FallbackZbar = symbolDecoder != nil
+        watcher.Retry.Budget = root.retryBudget
+    }
+    log.Printf("Watching %s for scanned codes (Ctrl-C aborts)", fileList[0])
+    return watcher.Watch(context.Background(), func(update qrFile.WatchUpdate) {
+        if update.Report != nil && len(update.Report.Failures) > 0 {
+            log.Printf("Some images could not be read:\n%s", update.Report)
+        }
+        for _, err := range update.Rejected {
+            log.Printf("Skipping code: %s", err)
+        }
+        if update.Total == 0 {
+            log.Printf("Read %d new images, no code of the set found yet.", len(update.Files))
+            return
+        }
+        log.Printf("Read %d new images: %d new codes, %d of %d collected.", len(update.Files), update.Added, update.Received, update.Total)
+        if len(update.Missing) > 0 {
+            log.Printf("Missing codes %s.", indexList(update.Missing))
+        }
+    })
+}
+
+// addToSession adds the codes of the images to the session file (see --session) & returns the session if the set is
+// complete; otherwise the codes still missing are listed & nil is returned
+func addToSession(fileList []string) (*qrFile.Session, error) {
+    session, err := qrFile.OpenSession(root.sessionPath)
+    if err != nil {
+        return nil, err
+    }
+    session.Decoder = symbolDecoder
+    session.Observer = progressObserver(root.showProgress)
+    if root.retryDecode {
+        session.Retry = qrFile.DefaultRetryPolicy
+        session.Retry.FallbackZbar = symbolDecoder != nil
+        session.Retry.Budget = root.retryBudget
+    }
+    added, err := session.AddFiles(fileList)
+    if err != nil {
+        return nil, err
+    }
+    if session.Report != nil && len(session.Report.Failures) > 0 {
+        log.Printf("Some images could not be read:\n%s", session.Report)
+    }
+    log.Printf("Added %d new codes to %s, %d of %d collected.", added, root.sessionPath, session.Len(), session.Total())
+    if !session.Complete() {
+        log.Printf("Missing codes %s; scan them and add the images with --session %s.", indexList(session.Missing()), root.sessionPath)
+        return nil, nil
+    }
+    return session, nil
+}
+
+// streamFileFromQRImages restores a file like restoreFileFromQRImages, but decodes the images one after another & writes
+// the data while they are read, so it is never held in memory (see qrFile.Restorer)
+func streamFileFromQRImages(fileList []string, outputFilename string) error {
+    log.Printf("Streaming data from input %s to %s.", strings.Join(fileList, ","), outputFilename)
+    out := io.Writer(os.Stdout)
+    var file *qrFile.AtomicFile
+    if outputFilename != "-" {
+        var err error
+        // the file only replaces an existing one once all data is written
+        file, err = qrFile.CreateAtomic(outputFilename, root.overwriteOutput)
+        if err != nil {
+            return overwriteHint(err)
+        }
+        defer file.Abort()
+        out = file
+    }
+    buffered := bufio.NewWriter(out)
+    restorer := qrFile.NewRestorer(buffered)
+    err := restorer.ReadImages(fileList, symbolDecoder)
+    if err != nil {
+        return err
+    }
+    err = buffered.Flush()
+    if err != nil {
+        return err
+    }
+    if file != nil {
+        err = overwriteHint(file.Commit())
+        if err != nil {
+            return err
+        }
+    }
+    log.Printf("Done! Successfully wrote %d bytes to %s", restorer.Written(), outputFilename)
+    return nil
+}
+
+// overwriteHint adds how to replace the file to the error of an output file which exists already
+func overwriteHint(err error) error {
+    if err != nil && os.IsExist(err) {
+        return errors.New(fmt.Sprintf("%s (use --overwrite to replace it)", err))
+    }
+    return err
+}
+
+// extractFromQRImages restores the files at or below name of the tar archive encoded by a set into dir. If a manifest
+// listing the archive is among the inputs (or in an input directory), only the images holding these files are decoded;
+// otherwise the complete set is read.
+func extractFromQRImages(fileList []string, name string, dir string) error {
+    var elements *qrFile.QrElements
+    manifest, manifestDir := findManifest(fileList)
+    if manifest != nil && len(manifest.Files) > 0 && !root.textInput && !root.transcriptionInput {
+        indices := manifest.Indices(manifest.Select(name))
+        images := make([]string, 0, len(indices))
+        for _, index := range indices {
+            for _, chunk := range manifest.Chunks {
+                if chunk.Index == index && len(chunk.Image) > 0 {
+                    images = append(images, filepath.Join(manifestDir, chunk.Image))
+                }
+            }
+        }
+        if len(images) == 0 {
+            return errors.New(fmt.Sprintf("%s not found in the archive.", name))
+        }
+        log.Printf("Decoding %d of %d images for %s.", len(images), manifest.Count, name)
+        sets, err := qrFile.FindSets(images, symbolDecoder)
+        if err != nil {
+            return err
+        }
+        elements = new(qrFile.QrElements)
+        for _, set := range sets {
+            elements.Elements = append(elements.Elements, set.Elements...)
+        }
+    } else {
+        var err error
+        elements, err = root.readElements(fileList)
+        if err != nil {
+            return err
+        }
+        manifest = elements.Manifest()
+        if len(manifest.Files) == 0 {
+            return errors.New("The set does not encode a tar archive.")
+        }
+    }
+    written, err := elements.Extract(manifest, name, dir)
+    for _, fname := range written {
+        log.Printf("Extracted %s", fname)
+    }
+    return err
+}
+
+// findManifest returns the first manifest (see qrFile.ManifestName) among the input files or in an input directory &
+// the directory it is in
+func findManifest(fileList []string) (*qrFile.Manifest, string) {
+    for _, entry := range fileList {
+        candidates := []string{entry}
+        if info, err := os.Stat(entry); err == nil && info.IsDir() {
+            candidates, _ = filepath.Glob(filepath.Join(entry, "*"+qrFile.ManifestName))
+        }
+        for _, fname := range candidates {
+            if !strings.HasSuffix(fname, qrFile.ManifestName) {
+                continue
+            }
+            manifest, err := qrFile.ReadManifestFile(fname)
+            if err == nil {
+                return manifest, filepath.Dir(fname)
+            }
+        }
+    }
+    return nil, ""
+}
+
+// transcodeQRImages reads a complete set & writes it as new images, using the encode options of the command line
+func transcodeQRImages(fileList []string, imgDir string, imgPrefix string) error {
+    elements, err := root.readElements(fileList)
+    if err != nil {
+        return err
+    }
+    transcoded, err := elements.Transcode(root.encodeOptions)
+    if err != nil {
+        return err
+    }
+    transcoded.Transcribe = root.transcribe
+    transcoded.Digest = root.printDigest
+    transcoded.Caption = root.captionFor("")
+    transcoded.Rendering = root.rendering
+    transcoded.ContentNames = root.contentNames
+    transcoded.Checksums = root.writeChecksums
+    transcoded.ParameterChunk = root.parameterChunk
+    storage, err := imageStorage(imgDir)
+    if err != nil {
+        return err
+    }
+    err = transcoded.WritePNGsTo(storage, imgPrefix)
+    if err != nil {
+        return err
+    }
+    log.Printf("Successfully transcoded %d codes into %d png files in %s.", elements.Len(), transcoded.Len(), imgDir)
+    return nil
+}
+
+// progressObserver returns an Observer showing the progress on stderr if show is set (--progress), nil otherwise
+func progressObserver(show bool) qrFile.Observer {
+    if !show {
+        return nil
+    }
+    return qrFile.ObserverFuncs{Progress: func(progress qrFile.Progress) {
+        fmt.Fprintf(os.Stderr, "\r%s: %d/%d (%.0f%%), %d KiB", progress.Stage, progress.Done, progress.Total, 100*progress.Fraction(), progress.Bytes/1024)
+        if progress.Done == progress.Total {
+            fmt.Fprintln(os.Stderr)
+        }
+    }}
+}
+
+// readZip reads the images of a zip archive (e.g. as written with --zip) without extracting it
+func readZip(fname string, elements *qrFile.QrElements) error {
+    archive, err := zip.OpenReader(fname)
+    if err != nil {
+        return err
+    }
+    defer archive.Close()
+    return elements.FromFS(archive, ".")
+}
+
+// indexList formats indices as a list for --only, e.g. 3,7,12
+func indexList(indices []uint64) string {
+    numbers := make([]string, len(indices))
+    for i, index := range indices {
+        numbers[i] = strconv.FormatUint(index, 10)
+    }
+    return strings.Join(numbers, ",")
+}
+
+// selectSet reads all sets contained in the images & returns the one selected by set ID or number
+func selectSet(fileList []string, selection string) (*qrFile.QrElements, error) {
+    sets, err := qrFile.FindSets(fileList, symbolDecoder)
+    if err != nil {
+        return nil, err
+    }
+    for i, set := range sets {
+        log.Printf("Found set %d, %s", i+1, set.Describe())
+    }
+    for i, set := range sets {
+        if strings.TrimPrefix(selection, "#") == set.SetID() || selection == strconv.Itoa(i+1) {
+            return set, set.Validate()
+        }
+    }
+    return nil, errors.New(fmt.Sprintf("Set %s not found", selection))
+}
+
+// createPaperKey stores a small file in a single code on a printable page
+func createPaperKey(inFile string, imgDir string, imgPrefix string) error {
+    qrf := &qrFile.QrFile{Fname: inFile, MaxSize: root.maxSize()}
+    err := qrf.ReadFile()
+    if err != nil {
+        return err
+    }
+    key, err := root.getKey()
+    if err != nil {
+        return err
+    }
+    var passphrase, text string
+    if key != nil {
+        text, err = qrFile.EncodePaperKeyWithKey(qrf.Data, key)
+    } else {
+        passphrase, err = root.getPassphrase(root.encrypt, true)
+        if err != nil {
+            return err
+        }
+        text, err = qrFile.EncodePaperKey(qrf.Data, passphrase)
+    }
+    if err != nil {
+        return err
+    }
+    page, err := qrFile.PaperKeyPage(text, inputName(inFile), symbolEncoder)
+    if err != nil {
+        return err
+    }
+    fname := filepath.Join(imgDir, imgPrefix+"paperkey.png")
+    out, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    defer out.Close()
+    err = png.Encode(out, page)
+    if err != nil {
+        return err
+    }
+    log.Printf("Successfully wrote paper key %s (encrypted: %t).", fname, len(passphrase) > 0 || key != nil)
+    return root.storePassphrase(passphrase)
+}
+
+// restorePaperKey restores a file from a scanned paper key page, or from its text if --text is set
+func restorePaperKey(fileList []string, outputFilename string) error {
+    var text string
+    var err error
+    if root.textInput {
+        err = importTextFiles(func(r io.Reader) error {
+            data, err := ioutil.ReadAll(r)
+            text = string(data)
+            return err
+        }, fileList)
+    } else if len(fileList) == 1 {
+        text, err = qrFile.ScanPaperKey(fileList[0], symbolDecoder)
+    } else {
+        return errors.New("Restoring a paper key requires exactly one image file")
+    }
+    if err != nil {
+        return err
+    }
+    decode := func(passphrase string) ([]byte, error) {
+        return qrFile.DecodePaperKey(text, passphrase)
+    }
+    key, err := root.getKey()
+    if err != nil {
+        return err
+    }
+    if key != nil {
+        secret, err := qrFile.DecodePaperKeyWithKey(text, key)
+        if err != nil {
+            return err
+        }
+        err = ioutil.WriteFile(outputFilename, secret, 0600)
+        if err != nil {
+            return err
+        }
+        log.Printf("Done! Successfully wrote %s", outputFilename)
+        return nil
+    }
+    passphrase, err := root.getPassphrase(false, false)
+    if err != nil {
+        return err
+    }
+    secret, err := decode(passphrase)
+    if errors.Is(err, qrFile.ErrKeyRequired) {
+        return errors.New("The paper key is encrypted with a raw key; use --keyFile or $QRFILE_KEY")
+    }
+    if errors.Is(err, qrFile.ErrPassphraseRequired) && len(passphrase) == 0 {
+        // the key is encrypted, but no passphrase was configured
+        passphrase, err = root.getPassphrase(true, false)
+        if err != nil {
+            return err
+        }
+        secret, err = decode(passphrase)
+    }
+    if err != nil {
+        return err
+    }
+    err = root.storePassphrase(passphrase)
+    if err != nil {
+        return err
+    }
+    err = ioutil.WriteFile(outputFilename, secret, 0600)
     if err != nil {
         return err
     }
@@ -118,66 +1343,353 @@ func restoreFileFromQRImages(fileList []string, outputFilename string) error {
     return nil
 }
 
-// http handlers for interactive mode
-func httpHandler(w http.ResponseWriter, r *http.Request) {
-    t, _ := template.ParseFiles("template/index.html")
-    t.Execute(w, nil)
+// writeArmor writes the elements as armored set to fname, or to stdout for -
+func writeArmor(elements *qrFile.QrElements, fname string) error {
+    if fname == "-" {
+        return elements.WriteArmor(os.Stdout)
+    }
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elements.WriteArmor(file)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
 }
 
-func handleUploadedFile(w http.ResponseWriter, r *http.Request) {
-    file, header, err := r.FormFile("file")
-    log.Print("Handling request for uploaded file %s", file)
+// writeTextExport writes the text of the codes selected by --textExport: into the file fname (- for stdout) or, if fname
+// names a directory, into numbered files named with the image prefix (see QrElements.WriteTextFiles)
+func writeTextExport(elements *qrFile.QrElements, fname string, prefix string) error {
+    if fname == "-" {
+        return elements.WriteText(os.Stdout)
+    }
+    if info, err := os.Stat(fname); (err == nil && info.IsDir()) || strings.HasSuffix(fname, "/") || strings.HasSuffix(fname, string(os.PathSeparator)) {
+        err := os.MkdirAll(fname, 0755)
+        if err != nil {
+            return err
+        }
+        return elements.WriteTextFiles(fname, prefix)
+    }
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elements.WriteText(file)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// isTextExport reports whether all files are text files as written by --textExport (.txt), to be read without decoding
+// images; armored sets are read as such
+func isTextExport(fileList []string) bool {
+    if len(fileList) == 0 {
+        return false
+    }
+    for _, fname := range fileList {
+        if !strings.HasSuffix(strings.ToLower(fname), ".txt") || isArmored(fname) {
+            return false
+        }
+    }
+    return true
+}
 
+// isArmored reports whether the file holds an armored set, i.e. the armor starts within its first lines (text before
+// it, e.g. of a mail, is allowed)
+func isArmored(fname string) bool {
+    file, err := os.Open(fname)
     if err != nil {
-        log.Print(err)
-        fmt.Fprintln(w, "An error occurred, please check log file.")
-        return
+        return false
     }
+    defer file.Close()
+    start := make([]byte, 4096)
+    n, _ := io.ReadFull(file, start)
+    return strings.Contains(string(start[:n]), qrFile.ArmorBegin)
+}
 
+// readArmor reads the elements of the armored set in fname
+func readArmor(fname string) (*qrFile.QrElements, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
     defer file.Close()
-    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileTemp")
+    return qrFile.ReadArmor(file)
+}
 
+// readRecipient reads a public key given with --recipients: the name of a key file or the key itself as text
+func readRecipient(recipient string) (*ecdh.PublicKey, error) {
+    if _, err := os.Stat(recipient); err == nil {
+        return qrFile.ReadRecipient(recipient)
+    }
+    key, err := qrFile.ParseRecipient(recipient)
     if err != nil {
-        log.Print(w, "Unable to create the temporary file.")
-        fmt.Fprintln(w, "An error occurred, please check log file.")
-        return
+        return nil, errors.New(fmt.Sprintf("Invalid recipient %s: neither a key file nor a key (%s)", recipient, err))
     }
-    log.Print("Created temporary file %s", tempfile.Name())
+    return key, nil
+}
 
-    // make sure to delete the file when we are done
-    defer os.Remove(tempfile.Name())
+// writeSheets writes the codes of the set to the PDF file fname as selected by --sheetLayout & --pageSize, captioned
+// with the name of the input file
+func writeSheets(elements *qrFile.QrElements, fname string, caption string) error {
+    options, err := sheetOptions()
+    if err != nil {
+        return err
+    }
+    options.Caption = caption
+    return elements.WritePDFFile(fname, options)
+}
 
-    // copy POST data into the temporary file
-    _, err = io.Copy(tempfile, file)
+// writeGallery writes all codes to the HTML page fname, laid out like the sheets of --pdf
+func writeGallery(elements *qrFile.QrElements, fname string, caption string) error {
+    options, err := sheetOptions()
     if err != nil {
-        log.Print(err)
-        fmt.Fprintln(w, "An error occurred, please check log file.")
-        return
+        return err
     }
-    tempfile.Close()
+    options.Caption = caption
+    return elements.WriteHTMLFile(fname, options)
+}
+
+// sheetOptions returns the layout of the sheets selected by --sheetLayout & --pageSize
+func sheetOptions() (qrFile.SheetOptions, error) {
+    var options qrFile.SheetOptions
+    _, err := fmt.Sscanf(strings.ToLower(root.sheetLayout), "%dx%d", &options.Columns, &options.Rows)
+    if err != nil || options.Columns <= 0 || options.Rows <= 0 {
+        return options, errors.New(fmt.Sprintf("Invalid sheet layout %s, expected columns x rows, e.g. 2x3", root.sheetLayout))
+    }
+    options.Page, err = qrFile.ParsePageSize(root.pageSize)
+    return options, err
+}
 
-    // now process it, create qr images
-    err = createQRFilesFromFile(tempfile.Name(), globTempDir, header.Filename+"_qr_")
+// writeFountainGIF writes fountain frames of the data of the set (see --fountain) to the animated GIF fname
+func writeFountainGIF(elements *qrFile.QrElements, fname string) error {
+    data := qrFile.New()
+    err := elements.StoreRawData(data)
+    if err != nil {
+        return err
+    }
+    encoder, err := qrFile.NewFountainEncoder(data.Data, qrFile.DefaultFountainBlockSize)
+    if err != nil {
+        return err
+    }
+    encoder.Level, encoder.Encoder = elements.Level, symbolEncoder
+    if root.fountainFrames < encoder.Blocks() {
+        log.Printf("Warning: %d fountain frames can not restore %d blocks of data; a receiver needs slightly more frames than blocks.", root.fountainFrames, encoder.Blocks())
+    }
+    err = encoder.WriteGIFFile(fname, root.fountainFrames, root.streamOptions)
+    if err != nil {
+        return err
+    }
+    log.Printf("Successfully wrote animated GIF %s (%d fountain frames for %d blocks).", fname, root.fountainFrames, encoder.Blocks())
+    return nil
+}
+
+// receiveFromStream reads the text of scanned codes line by line as they arrive, reporting the progress of the transfer
+// for every new code, & restores the file once the set is complete. Fountain frames (see --fountain) are collected
+// until the data is restored instead.
+func receiveFromStream(r io.Reader, outputFilename string) error {
+    assembler := qrFile.NewAssembler()
+    var fountain *qrFile.FountainDecoder
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    for !assembler.Complete() && (fountain == nil || !fountain.Complete()) && scanner.Scan() {
+        text := scanner.Text()
+        if len(strings.TrimSpace(text)) == 0 {
+            continue
+        }
+        if qrFile.IsFountainText(text) {
+            if fountain == nil {
+                fountain = qrFile.NewFountainDecoder()
+                fountain.MaxSize = root.maxSize()
+            }
+            added, err := fountain.AddString(text)
+            if err != nil {
+                log.Printf("Skipping frame: %s", err)
+            } else if added {
+                known, total, received := fountain.Progress()
+                log.Printf("%d of %d blocks restored from %d frames", known, total, received)
+            }
+            continue
+        }
+        added, err := assembler.AddString(text)
+        if err != nil {
+            log.Printf("Skipping code: %s", err)
+            continue
+        }
+        if added {
+            log.Print(assembler.Stats())
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    if fountain != nil && !assembler.Complete() {
+        data, err := fountain.Data()
+        if err != nil {
+            return err
+        }
+        newFile := qrFile.New()
+        newFile.Fname, newFile.Data = outputFilename, data
+        err = root.writeResult(newFile)
+        if err != nil {
+            return err
+        }
+        _, _, received := fountain.Progress()
+        log.Printf("Done! Received the data in %d frames, wrote %s", received, newFile.Fname)
+        return nil
+    }
+    return root.storeReceived(assembler, outputFilename)
+}
 
+// receiveFromClipboard watches the clipboard for the text of scanned codes (one code per line, so several codes can be
+// pasted at once), reporting the progress for every new code, & restores the file once the set is complete
+func receiveFromClipboard(outputFilename string) error {
+    assembler := qrFile.NewAssembler()
+    log.Print("Watching the clipboard for codes, copy the text of each scanned code (Ctrl-C aborts)")
+    err := qrFile.ClipboardWatcher{}.Watch(nil, func(text string) bool {
+        for _, line := range strings.Split(text, "\n") {
+            line = strings.TrimRight(line, "\r")
+            if len(strings.TrimSpace(line)) == 0 {
+                continue
+            }
+            added, err := assembler.AddString(line)
+            if err != nil {
+                log.Printf("Skipping clipboard text: %s", err)
+                continue
+            }
+            if added {
+                log.Print(assembler.Stats())
+            }
+        }
+        return !assembler.Complete()
+    })
     if err != nil {
-        log.Print("Error parsing file: %s", err.Error())
-        fmt.Fprintln(w, "An error occurred, please check log file.")
-        return
+        return err
+    }
+    return root.storeReceived(assembler, outputFilename)
+}
+
+// importTextFiles hands the text read from a list of files (stdin if the list is empty) to an import method
+func importTextFiles(importText func(io.Reader) error, fileList []string) error {
+    if len(fileList) == 0 {
+        return importText(os.Stdin)
     }
-    // store the image paths...
-    images, _ := filepath.Glob(globTempDir + "/" + header.Filename + "_qr_*.png")
-    for i, v := range images {
-        images[i] = v[len(globTempDir+"/"):]
+    readers := make([]io.Reader, 0, 2*len(fileList))
+    for _, fname := range fileList {
+        file, err := os.Open(fname)
+        if err != nil {
+            return err
+        }
+        defer file.Close()
+        // make sure the last line of a file is not joined with the first line of the next one
+        readers = append(readers, file, strings.NewReader("\n"))
     }
-    // fill page data struct with the relevant file names...
-    pageData := struct {
-        Filename string
-        Images   []string
-    }{Filename: header.Filename, Images: images}
+    return importText(io.MultiReader(readers...))
+}
 
-    // and execute the template
-    t, _ := template.ParseFiles("template/show.html")
-    t.Execute(w, pageData)
+// rootOptions are the flags of the root command (input & output mode) & the settings run derives from them
+type rootOptions struct {
+    coderOptions
+    renderOptions
+    metadataOptions
+    inputOptions
+    serverOptions
+    outputOptions
+    imageDir         string
+    inFile           string
+    sourceURL        string
+    sourceSHA256     string
+    imagePrefix      string
+    containerFile    string
+    armorFile        string
+    estimateOnly     bool
+    tiffFile         string
+    zipFile          string
+    textExport       string
+    pdfFile          string
+    htmlFile         string
+    sheetLayout      string
+    pageSize         string
+    gifFile          string
+    fountainFrames   int
+    framesDir        string
+    framePrefix      string
+    frameRate        float64
+    coverFile        string
+    interactive      bool
+    plainFormat      bool
+    compactFormat    bool
+    integrityFields  bool
+    parityCodes      uint64
+    recordFileInfo   bool
+    signKeyFile      string
+    streamRestore    bool
+    receiveCodes     bool
+    watchClipboard   bool
+    watchInput       bool
+    transcribe       bool
+    printDigest      bool
+    structuredAppend bool
+    paperKey         bool
+    encrypt          bool
+    compressData     bool
+    compression      string
+    recipients       string
+    pgpRecipients    string
+    pgpSigner        string
+    pgpDecode        bool
+    unpackArchive    bool
+    archiveOptions   qrFile.ArchiveOptions
+    alignFiles       bool
+    contentNames     bool
+    writeChecksums   bool
+    parameterChunk   bool
+    hiddenPolicy     string
+    includePatterns  string
+    excludePatterns  string
+    onlyIndices      string
+    levelName        string
+    levelList        string
+    chunkSize        uint64
+    symbolVersion    int
+    padCodes         bool
+    codecName        string
+    transcodeSet     bool
+    archiveMode      bool
+    archiveInputs    []string
+    sessionPath      string
+    extractPath      string
+    codeCount        uint64
+    maxCodes         uint64
+    streamOptions    qrFile.StreamOptions
+    rendering        qrFile.RenderOptions
+    encodeOptions    qrFile.EncodeOptions
+    // persistent flags, which apply to the subcommands as well
+    maxFileSize   int64
+    debugMode     bool
+    logLevel      string
+    workerCount   int
+    toolDirectory string
+}
+
+// root holds the flags of the root command, set by rootCommand; the defaults of --maxSize & --maxUpload are given here
+var root = rootOptions{framePrefix: "frame_", maxFileSize: qrFile.DefaultMaxFileSize, serverOptions: serverOptions{maxUpload: 256 << 20}}
+
+// maxSize returns the limit of --maxSize for the options of the library, where 0 stands for its default & a negative
+// limit for none
+func (o rootOptions) maxSize() int64 {
+    if o.maxFileSize <= 0 {
+        return -1
+    }
+    return o.maxFileSize
 }
 
-var globTempDir string = ""
+// the coders selected by selectCoders, used by all commands & the web server
+var symbolEncoder qrFile.SymbolEncoder = qrFile.DefaultEncoder
+var symbology qrFile.Symbology = qrFile.SymbologyQR
+var symbolDecoder qrFile.Decoder = nil
@@ -0,0 +1,325 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "golang.org/x/term"
+    "io"
+    "log"
+    "os"
+    "os/exec"
+    "strings"
+    "time"
+)
+
+// defaultScanner is the command the transfer command reads codes from the webcam with, one text per line
+const defaultScanner = "zbarcam --raw --nodisplay"
+
+// transferCommand implements "qrFileApp transfer": a pair of commands transferring a file between two machines without
+// network, one showing the codes in the terminal, the other reading them with a webcam
+func transferCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "transfer",
+        Short: "Transfer a file between two machines without network, from a slideshow of codes to a webcam",
+        Long: `transfer automates an air-gapped transfer. "transfer send" shows the codes of a file as a slideshow in the terminal,
+including sync frames announcing the set; "transfer receive" reads the codes with a webcam (zbarcam of zbar by
+default), tracks the codes still missing and restores the file once all codes were seen. With --ack on both sides,
+the receiver shows an acknowledgement code listing the missing codes, the sender reads it with its own webcam and
+from then on only shows these codes, until the receiver acknowledges the complete set.`,
+    }
+    cmd.AddCommand(transferSendCommand(), transferReceiveCommand())
+    return cmd
+}
+
+// transferSendOptions are the flags of the transfer send command
+type transferSendOptions struct {
+    interval     time.Duration
+    level        string
+    chunkSize    uint64
+    plainFormat  bool
+    codec        string
+    syncInterval int
+    ack          bool
+    scanner      string
+}
+
+// transferSendCommand implements "qrFileApp transfer send"
+func transferSendCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "send [flags] file",
+        Short: "Show the codes of a file in the terminal until the receiver acknowledges them",
+        Args:  cobra.ExactArgs(1),
+    }
+    var options transferSendOptions
+    flags := cmd.Flags()
+    flags.DurationVar(&options.interval, "interval", time.Second, "Time each code is shown before the next one follows.")
+    flags.StringVar(&options.level, "level", "L", "Error correction level of the codes: L, M, Q or H.")
+    flags.Uint64Var(&options.chunkSize, "chunkSize", 0, "Payload characters per code; the default of the format if 0. Smaller codes fit smaller terminals.")
+    flags.BoolVar(&options.plainFormat, "plain", false, "Create short codes with a plain \"QRF v2 <number>/<count>\" header which can be read by any scanner app.")
+    flags.StringVar(&options.codec, "codec", "hex", "Encoding of the payload in the codes: hex, base64 or base45 (implies --plain).")
+    flags.IntVar(&options.syncInterval, "syncInterval", 10, "Show a sync frame announcing the set before every this many codes (0 disables sync frames).")
+    flags.BoolVar(&options.ack, "ack", false, "Read the acknowledgements of the receiver with the webcam and only show the codes still missing; stops once the receiver acknowledged the complete set.")
+    flags.StringVar(&options.scanner, "scanner", defaultScanner, "With --ack, command printing the text of the codes read by the webcam, one per line.")
+    cmd.RegisterFlagCompletionFunc("level", completeValues("L", "M", "Q", "H"))
+    cmd.RegisterFlagCompletionFunc("codec", completeValues("hex", "base64", "base45"))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        if options.interval <= 0 {
+            log.Fatalf("Invalid interval %s", options.interval)
+        }
+        level, err := qrFile.ParseLevel(options.level)
+        if err != nil {
+            log.Fatal(err)
+        }
+        encoding := qrFile.EncodeOptions{Version: qrFile.VersionLegacy, ChunkSize: options.chunkSize, Level: level}
+        encoding.Codec, err = qrFile.ParsePayloadCodec(options.codec)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if options.plainFormat || encoding.Codec != qrFile.CodecHex {
+            encoding.Version = qrFile.VersionPlain
+        }
+        elements, err := elementsFromFile(args[0], encoding)
+        if err != nil {
+            log.Fatalf("Error while handling input file %s: %s", args[0], err)
+        }
+        command := ""
+        if options.ack {
+            command = options.scanner
+        }
+        done, err := transferSend(elements, options.interval, options.syncInterval, command)
+        if err != nil {
+            log.Fatal(err)
+        }
+        if done {
+            log.Printf("The receiver acknowledged all %d codes.", elements.Len())
+        }
+    }
+    return cmd
+}
+
+// transferSend shows the codes of a set in the terminal, see transferCommand; acknowledgements are read with the scanner
+// command, unless it is empty. The result is true if the receiver acknowledged the complete set, false if q was pressed.
+func transferSend(elements *qrFile.QrElements, interval time.Duration, syncInterval int, scanner string) (bool, error) {
+    codes := make(map[int]*qrFile.TerminalCode)
+    for i := 0; i < elements.Len(); i++ {
+        code, err := elements.RenderTerminal(i)
+        if err != nil {
+            return false, errors.New(fmt.Sprintf("Error while rendering code %d: %s", i+1, err))
+        }
+        codes[i] = code
+    }
+    if syncInterval > 0 {
+        text, err := qrFile.SyncText(elements.Manifest())
+        if err != nil {
+            return false, err
+        }
+        codes[qrFile.TransferSync], err = qrFile.RenderTerminal(text, elements.Level)
+        if err != nil {
+            return false, errors.New(fmt.Sprintf("Error while rendering the sync frame: %s", err))
+        }
+    }
+    var acks <-chan string
+    if len(scanner) > 0 {
+        texts, stop, err := startScanner(scanner)
+        if err != nil {
+            return false, err
+        }
+        defer stop()
+        acks = texts
+    }
+    queue := qrFile.NewTransferQueue(elements, qrFile.StreamOptions{SyncInterval: syncInterval})
+    keys, restore, err := openTerminalScreen()
+    if err != nil {
+        return false, err
+    }
+    defer restore()
+    frame, paused, acknowledged := queue.Next(), false, ""
+    status := fmt.Sprintf("showing all %d codes", queue.Pending())
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        name, label := "the sync frame", "Sync frame"
+        if frame != qrFile.TransferSync {
+            name = fmt.Sprintf("code %d", elements.Elements[frame].Index+1)
+            label = fmt.Sprintf("Code %d/%d", elements.Elements[frame].Index+1, elements.Len())
+        }
+        if paused {
+            label += ", paused"
+        }
+        drawTerminalScreen(codes[frame], name, fmt.Sprintf("%s, %s. space: pause, q: quit", label, status))
+        select {
+        case <-ticker.C:
+            if !paused {
+                frame = queue.Next()
+            }
+        case key, ok := <-keys:
+            if !ok {
+                return false, nil
+            }
+            switch key {
+            case "q", "Q", "\x03":
+                return false, nil
+            case " ":
+                paused = !paused
+            }
+        case text, ok := <-acks:
+            if !ok {
+                acks, status = nil, "the scanner stopped, "+status
+                continue
+            }
+            // the webcam may see other codes than acknowledgements & reads the same one again & again
+            ack, err := qrFile.ParseAck(text)
+            if err != nil || text == acknowledged {
+                continue
+            }
+            err = queue.Acknowledge(ack)
+            if err != nil {
+                status = err.Error()
+                continue
+            }
+            acknowledged = text
+            if queue.Done() {
+                return true, nil
+            }
+            status = fmt.Sprintf("showing the %d codes the receiver misses", queue.Pending())
+            frame = queue.Next()
+        }
+    }
+}
+
+// transferReceiveOptions are the flags of the transfer receive command
+type transferReceiveOptions struct {
+    outputOptions
+    scanner string
+    ack     bool
+    linger  time.Duration
+}
+
+// transferReceiveCommand implements "qrFileApp transfer receive"
+func transferReceiveCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "receive [flags]",
+        Short: "Read the codes of a transfer with the webcam and restore the file",
+        Args:  cobra.NoArgs,
+    }
+    // the received file is stored in the output directory of output mode
+    options := transferReceiveOptions{outputOptions: outputOptions{outDir: "./output_dir"}}
+    flags := cmd.Flags()
+    flags.StringVar(&options.outFile, "out", "result", "File to store the received data to; - writes it to stdout.")
+    flags.BoolVar(&options.overwriteOutput, "overwrite", false, "Replace an existing output file.")
+    flags.StringVar(&options.scanner, "scanner", defaultScanner, "Command printing the text of the codes read by the webcam, one per line; - reads them from stdin.")
+    flags.BoolVar(&options.ack, "ack", false, "Show an acknowledgement code listing the codes still missing in the terminal, for a sender reading it with its webcam (transfer send --ack).")
+    flags.DurationVar(&options.linger, "linger", 10*time.Second, "With --ack, time the final acknowledgement is shown after all codes were received, so the sender sees it.")
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.outFileGiven = cmd.Flags().Changed("out")
+        err := transferReceive(options)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    return cmd
+}
+
+// transferReceive reads the codes of a transfer with the --scanner command & restores the file once the set is complete;
+// with --ack, the acknowledgement of the codes received so far is shown in the terminal instead of the progress log
+func transferReceive(options transferReceiveOptions) error {
+    if options.ack && !term.IsTerminal(int(os.Stdout.Fd())) {
+        return errors.New("--ack requires stdout to be a terminal")
+    }
+    texts, stop, err := startScanner(options.scanner)
+    if err != nil {
+        return err
+    }
+    defer stop()
+    assembler := qrFile.NewAssembler()
+    shown := ""
+    for text := range texts {
+        if len(strings.TrimSpace(text)) == 0 {
+            continue
+        }
+        added, err := assembler.AddString(text)
+        if err != nil {
+            log.Printf("Skipping code: %s", err)
+            continue
+        }
+        if options.ack {
+            shown, err = drawAck(assembler, shown)
+            if err != nil {
+                return err
+            }
+        } else if added {
+            log.Print(assembler.Stats())
+        }
+        if assembler.Complete() {
+            break
+        }
+    }
+    if options.ack && assembler.Complete() {
+        time.Sleep(options.linger)
+        fmt.Print("\x1b[H\x1b[2J")
+    }
+    return options.storeReceived(assembler, options.outputPath())
+}
+
+// drawAck shows the acknowledgement of the codes collected by assembler fullscreen, unless its text equals shown (the
+// text shown before); the text shown is returned
+func drawAck(assembler *qrFile.Assembler, shown string) (string, error) {
+    ack := assembler.Ack()
+    if ack == nil {
+        return shown, nil
+    }
+    text := qrFile.AckText(ack)
+    if text == shown {
+        return shown, nil
+    }
+    code, err := qrFile.RenderTerminal(text, qrFile.LevelL)
+    if err != nil {
+        return shown, errors.New(fmt.Sprintf("Error while rendering the acknowledgement: %s", err))
+    }
+    status := fmt.Sprintf("Acknowledgement: %s", assembler.Stats())
+    if ack.Complete() {
+        status = "Acknowledgement: all codes received."
+    }
+    drawTerminalScreen(code, "the acknowledgement", status)
+    return text, nil
+}
+
+// startScanner starts a command printing the text of scanned codes, one per line (e.g. zbarcam --raw), & returns the
+// channel receiving the lines; "-" reads them from stdin. stop ends the command.
+func startScanner(command string) (texts <-chan string, stop func(), err error) {
+    lines := make(chan string)
+    read := func(r io.Reader) {
+        scanner := bufio.NewScanner(r)
+        scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+        for scanner.Scan() {
+            lines <- scanner.Text()
+        }
+        close(lines)
+    }
+    if command == "-" {
+        go read(os.Stdin)
+        return lines, func() {}, nil
+    }
+    args := strings.Fields(command)
+    if len(args) == 0 {
+        return nil, nil, errors.New("No scanner command")
+    }
+    cmd := exec.Command(args[0], args[1:]...)
+    cmd.Stderr = os.Stderr
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return nil, nil, err
+    }
+    err = cmd.Start()
+    if err != nil {
+        return nil, nil, errors.New(fmt.Sprintf("Unable to start the scanner %s: %s", args[0], err))
+    }
+    go read(stdout)
+    return lines, func() {
+        cmd.Process.Kill()
+        cmd.Wait()
+    }, nil
+}
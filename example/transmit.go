@@ -0,0 +1,209 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "golang.org/x/term"
+    "log"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// transmitOptions are the flags of the transmit command
+type transmitOptions struct {
+    terminal    bool
+    pager       bool
+    interval    time.Duration
+    level       string
+    chunkSize   uint64
+    plainFormat bool
+}
+
+// transmitCommand implements "qrFileApp transmit": the codes of a file are shown one after another, e.g. fullscreen in
+// the terminal, to be captured by a phone camera
+func transmitCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "transmit --terminal [flags] file",
+        Short: "Show the codes of a file one after another in the terminal, e.g. to transfer it out of an SSH session",
+        Long: `transmit converts a file (or directory) into codes and shows them as a slideshow. With --terminal, the codes are
+drawn fullscreen in the terminal with ANSI colors and block characters, advancing every --interval and starting over
+after the last code, so a file can be transferred out of an environment only reachable by SSH. Keys: space pauses and
+resumes, right arrow / n / l shows the next code, left arrow / b / h the previous one, Home / End the first and last;
+typing a number and Enter jumps to that code; q quits. Consoles without raw mode (e.g. serial lines) page with
+--pager instead: Enter shows the next code, b and Enter the previous one, a number and Enter that code, q and Enter
+quits.`,
+        Args: cobra.ExactArgs(1),
+    }
+    var options transmitOptions
+    flags := cmd.Flags()
+    flags.BoolVar(&options.terminal, "terminal", false, "Show the codes fullscreen in the terminal.")
+    flags.BoolVar(&options.pager, "pager", false, "With --terminal, page through the codes with Enter instead of the timed slideshow; works without raw mode, e.g. on serial consoles.")
+    flags.DurationVar(&options.interval, "interval", 2*time.Second, "Time each code is shown before the next one follows.")
+    flags.StringVar(&options.level, "level", "L", "Error correction level of the codes: L, M, Q or H.")
+    flags.Uint64Var(&options.chunkSize, "chunkSize", 0, "Payload characters per code; the default of the format if 0. Smaller codes fit smaller terminals.")
+    flags.BoolVar(&options.plainFormat, "plain", false, "Create short codes with a plain \"QRF v2 <number>/<count>\" header which can be read by any scanner app.")
+    cmd.RegisterFlagCompletionFunc("level", completeValues("L", "M", "Q", "H"))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        if !options.terminal {
+            log.Fatal("transmit needs a target; only --terminal is supported (for an animated GIF, use --gif)")
+        }
+        if options.interval <= 0 {
+            log.Fatalf("Invalid interval %s", options.interval)
+        }
+        level, err := qrFile.ParseLevel(options.level)
+        if err != nil {
+            log.Fatal(err)
+        }
+        encoding := qrFile.EncodeOptions{Version: qrFile.VersionLegacy, ChunkSize: options.chunkSize, Level: level}
+        if options.plainFormat {
+            encoding.Version = qrFile.VersionPlain
+        }
+        elements, err := elementsFromFile(args[0], encoding)
+        if err != nil {
+            log.Fatalf("Error while handling input file %s: %s", args[0], err)
+        }
+        if options.pager {
+            err = elements.WriteTerminal(os.Stdout, os.Stdin)
+            if err != nil {
+                log.Fatal(err)
+            }
+            return
+        }
+        codes := make([]*qrFile.TerminalCode, elements.Len())
+        for i := range codes {
+            codes[i], err = elements.RenderTerminal(i)
+            if err != nil {
+                log.Fatalf("Error while rendering code %d: %s", i+1, err)
+            }
+        }
+        err = transmitTerminal(codes, options.interval)
+        if err != nil {
+            log.Fatal(err)
+        }
+    }
+    return cmd
+}
+
+// openTerminalScreen switches the terminal to raw mode & to the alternate screen without cursor, for a fullscreen
+// display of codes; the keys pressed are sent to the channel returned, restore switches back
+func openTerminalScreen() (keys <-chan string, restore func(), err error) {
+    fd := int(os.Stdin.Fd())
+    if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+        return nil, nil, errors.New("The slideshow requires stdin and stdout to be a terminal")
+    }
+    state, err := term.MakeRaw(fd)
+    if err != nil {
+        return nil, nil, err
+    }
+    fmt.Print("\x1b[?1049h\x1b[?25l")
+    pressed := make(chan string)
+    go func() {
+        buf := make([]byte, 16)
+        for {
+            n, err := os.Stdin.Read(buf)
+            if err != nil {
+                close(pressed)
+                return
+            }
+            pressed <- string(buf[:n])
+        }
+    }()
+    return pressed, func() {
+        fmt.Print("\x1b[?25h\x1b[?1049l")
+        term.Restore(fd, state)
+    }, nil
+}
+
+// transmitTerminal shows the codes fullscreen in the terminal until q is pressed (see transmitCommand for the keys)
+func transmitTerminal(codes []*qrFile.TerminalCode, interval time.Duration) error {
+    keys, restore, err := openTerminalScreen()
+    if err != nil {
+        return err
+    }
+    defer restore()
+    current, paused, jump := 0, false, ""
+    timer := time.NewTimer(interval)
+    for {
+        drawTerminalCode(codes, current, paused, jump)
+        select {
+        case <-timer.C:
+            if !paused {
+                current = (current + 1) % len(codes)
+            }
+            timer.Reset(interval)
+            continue
+        case key, ok := <-keys:
+            if !ok {
+                return nil
+            }
+            previous := current
+            switch key {
+            case "q", "Q", "\x03":
+                return nil
+            case " ":
+                paused = !paused
+            case "n", "l", "\x1b[C":
+                current = (current + 1) % len(codes)
+            case "b", "h", "\x1b[D":
+                current = (current + len(codes) - 1) % len(codes)
+            case "\x1b[H", "\x1b[1~", "g":
+                current = 0
+            case "\x1b[F", "\x1b[4~", "G":
+                current = len(codes) - 1
+            case "\r", "\n":
+                if number, err := strconv.Atoi(jump); err == nil && number >= 1 && number <= len(codes) {
+                    current = number - 1
+                }
+                jump = ""
+            case "\x1b", "\x7f":
+                jump = ""
+            default:
+                if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+                    jump += key
+                }
+            }
+            if current != previous {
+                // show the code selected for a full interval
+                if !timer.Stop() {
+                    <-timer.C
+                }
+                timer.Reset(interval)
+            }
+        }
+    }
+}
+
+// drawTerminalCode clears the terminal & draws the code at position current with a status line below it
+func drawTerminalCode(codes []*qrFile.TerminalCode, current int, paused bool, jump string) {
+    status := "playing"
+    if paused {
+        status = "paused"
+    }
+    status = fmt.Sprintf("Code %d/%d, %s. space: pause, arrows: previous/next, number+enter: jump, q: quit", current+1, len(codes), status)
+    if len(jump) > 0 {
+        status += "  jump to " + jump
+    }
+    drawTerminalScreen(codes[current], fmt.Sprintf("code %d", current+1), status)
+}
+
+// drawTerminalScreen clears the terminal & draws a code with a status line below it; name describes the code in the
+// message shown if the terminal is too small
+func drawTerminalScreen(code *qrFile.TerminalCode, name string, status string) {
+    width, height, err := term.GetSize(int(os.Stdout.Fd()))
+    var screen strings.Builder
+    screen.WriteString("\x1b[H\x1b[2J")
+    if err == nil && (code.Width > width || code.Height()+1 > height) {
+        fmt.Fprintf(&screen, "The terminal is too small for %s: %dx%d characters needed, %dx%d available.\r\n", name, code.Width, code.Height()+1, width, height)
+        screen.WriteString("Enlarge the window or reduce the font size, or use a smaller --chunkSize.\r\n")
+    } else {
+        // raw mode: line breaks need a carriage return
+        screen.WriteString(strings.Join(code.Lines, "\r\n"))
+        screen.WriteString("\r\n")
+    }
+    screen.WriteString(status)
+    os.Stdout.WriteString(screen.String())
+}
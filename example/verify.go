@@ -0,0 +1,138 @@
+package main
+
+import (
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/spf13/cobra"
+    "log"
+    "os"
+)
+
+// verifyOptions are the flags of the verify command
+type verifyOptions struct {
+    coderOptions
+    inputOptions
+    manifest string
+    original string
+    json     bool
+}
+
+// verifyCommand implements "qrFileApp verify": the codes of a set of images are checked without restoring the file (see
+// qrFile.VerifyFiles)
+func verifyCommand() *cobra.Command {
+    cmd := &cobra.Command{
+        Use:   "verify [flags] images...",
+        Short: "Check a set of QR code images for missing and damaged codes",
+        Long: `verify reads the images of a set (files or directories) and checks every code against the manifest of the set (taken
+from --manifest or found among the inputs) or, without manifest, against the other copies of the same code. The codes
+which are missing or damaged are listed; the exit status is 1 unless all codes are intact.
+
+With --original, every code is compared with the bytes of the original file it has to hold instead, and the file
+restored from the set with the original as a whole, e.g. as proof that the printouts restore the file before it is
+deleted. Codes which differ from the original are listed with their image.`,
+        Example: `  qrFileApp verify img_dir
+  qrFileApp verify --json --manifest img_manifest.json scans/*.png
+  qrFileApp verify --original ~/test.txt scans/*.png`,
+        Args: cobra.MinimumNArgs(1),
+    }
+    var options verifyOptions
+    flags := cmd.Flags()
+    flags.StringVar(&options.manifest, "manifest", "", "Manifest of the set to check the codes against; by default one found among the inputs.")
+    flags.StringVar(&options.original, "original", "", "Compare every code with the bytes of this original file instead of a manifest.")
+    flags.BoolVar(&options.json, "json", false, "Print the result as JSON.")
+    flags.StringVar(&options.passphraseFile, "passphraseFile", "", "With --original, read the passphrase of encrypted data from this file.")
+    flags.StringVar(&options.keyFile, "keyFile", "", "With --original, decrypt with a raw 32 byte key read from this file instead of a passphrase.")
+    flags.StringVar(&options.identityFile, "identity", "", "With --original, decrypt data encrypted for recipients with the X25519 private key in this file.")
+    flags.StringVar(&options.decoderName, "decoder", "", "Decoder used to read the images: native, zbar, another decoder registered in this build or exec:<command>.")
+    cmd.RegisterFlagCompletionFunc("decoder", completeValues(qrFile.DecoderNames()...))
+    cmd.Run = func(cmd *cobra.Command, args []string) {
+        options.selectCoders()
+        if len(options.original) > 0 {
+            verifyOriginal(options, args)
+            return
+        }
+        manifest, _ := findManifest(args)
+        if len(options.manifest) > 0 {
+            var err error
+            manifest, err = qrFile.ReadManifestFile(options.manifest)
+            if err != nil {
+                log.Fatal(err)
+            }
+        }
+        result, err := qrFile.VerifyFiles(args, manifest, symbolDecoder)
+        if err != nil {
+            log.Fatalf("Error while reading the set: %s", err)
+        }
+        if options.json {
+            printJSON(result)
+        } else {
+            fmt.Printf("%d of %d codes intact", len(result.Present), result.Total)
+            if len(result.Missing) > 0 {
+                fmt.Printf(", missing %s", indexList(result.Missing))
+            }
+            if len(result.Corrupt) > 0 {
+                fmt.Printf(", damaged %s", indexList(result.Corrupt))
+            }
+            fmt.Println()
+            for _, reason := range result.Unreadable {
+                fmt.Printf("unreadable: %s\n", reason)
+            }
+        }
+        if !result.Complete() {
+            os.Exit(1)
+        }
+    }
+    return cmd
+}
+
+// verifyOriginal checks the codes of the images against the original file of --original (see qrFile.Verify) & exits
+// with status 1 unless the set restores the original
+func verifyOriginal(options verifyOptions, args []string) {
+    decoding := options.decodeSettings()
+    key, err := options.getKey()
+    if err != nil {
+        log.Fatal(err)
+    }
+    passphrase, err := options.getPassphrase(false, false)
+    if err != nil {
+        log.Fatal(err)
+    }
+    identities, err := options.getIdentities()
+    if err != nil {
+        log.Fatal(err)
+    }
+    decoding.Transforms = []qrFile.Transform{qrFile.EncryptTransform{Passphrase: passphrase, Key: key}, identities}
+    original, err := os.Open(options.original)
+    if err != nil {
+        log.Fatal(err)
+    }
+    result, err := qrFile.Verify(original, args, decoding)
+    original.Close()
+    if err != nil {
+        log.Fatalf("Error while reading the set: %s", err)
+    }
+    if options.json {
+        printJSON(result)
+    } else {
+        fmt.Printf("%d of %d codes match %s", len(result.Matching), result.Total, options.original)
+        if len(result.Missing) > 0 {
+            fmt.Printf(", missing %s", indexList(result.Missing))
+        }
+        if len(result.Unchecked) > 0 {
+            fmt.Printf(", not compared %s", indexList(result.Unchecked))
+        }
+        fmt.Println()
+        for _, index := range result.Differing {
+            fmt.Printf("code %d differs from the original (%s)\n", index, result.Files[index])
+        }
+        for _, reason := range result.Unreadable {
+            fmt.Printf("unreadable: %s\n", reason)
+        }
+        if result.Comparison != nil {
+            fmt.Printf("restored file: %s\n", result.Comparison)
+        }
+    }
+    if !result.Passed() {
+        os.Exit(1)
+    }
+}
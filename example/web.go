@@ -0,0 +1,1717 @@
+package main
+
+import (
+    "archive/zip"
+    "context"
+    "crypto/rand"
+    "crypto/subtle"
+    "crypto/tls"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "github.com/Schokomuesl1/qrFile/grpcserver"
+    "golang.org/x/crypto/acme/autocert"
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/credentials"
+    "html/template"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "log"
+    "mime/multipart"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// serverOptions are the flags of the web server & the gRPC service started with --interactive
+type serverOptions struct {
+    port           int
+    tlsCert        string
+    tlsKey         string
+    autocertHosts  string
+    autocertCache  string
+    authUser       string
+    authPassword   string // from $QRFILE_WEB_PASSWORD
+    authToken      string
+    grpcPort       int
+    sessionTimeout time.Duration
+    retention      time.Duration
+    maxUpload      int64
+}
+
+// serverTLS is the TLS configuration of the web server & the gRPC service; nil if they use plain connections
+var serverTLS *tls.Config
+
+// setupServerSecurity prepares what the web server & the gRPC service share: the credentials required (--authUser with
+// $QRFILE_WEB_PASSWORD, --authToken or $QRFILE_WEB_TOKEN) & the TLS configuration, from a certificate given
+// (--tlsCert & --tlsKey) or obtained (--autocert)
+func setupServerSecurity() error {
+    if len(root.authToken) == 0 {
+        root.authToken = os.Getenv("QRFILE_WEB_TOKEN")
+    }
+    if len(root.authUser) > 0 {
+        root.authPassword = os.Getenv("QRFILE_WEB_PASSWORD")
+        if len(root.authPassword) == 0 {
+            return errors.New("--authUser requires the password in $QRFILE_WEB_PASSWORD")
+        }
+    }
+    switch {
+    case len(root.tlsCert) > 0 || len(root.tlsKey) > 0:
+        if len(root.tlsCert) == 0 || len(root.tlsKey) == 0 || len(root.autocertHosts) > 0 {
+            return errors.New("TLS needs both --tlsCert and --tlsKey, or --autocert instead")
+        }
+        certificate, err := tls.LoadX509KeyPair(root.tlsCert, root.tlsKey)
+        if err != nil {
+            return err
+        }
+        serverTLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+        log.Printf("Using TLS with the certificate %s", root.tlsCert)
+    case len(root.autocertHosts) > 0:
+        manager := &autocert.Manager{Prompt: autocert.AcceptTOS, HostPolicy: autocert.HostWhitelist(splitList(root.autocertHosts)...), Cache: autocert.DirCache(root.autocertCache)}
+        serverTLS = manager.TLSConfig()
+        // Let's Encrypt checks the host on port 80 or, if that is not possible, on the HTTPS port itself
+        go func() {
+            err := http.ListenAndServe(":80", manager.HTTPHandler(nil))
+            log.Printf("Not answering the challenges of Let's Encrypt on port 80: %s", err)
+        }()
+        log.Printf("Using TLS with certificates from Let's Encrypt for %s", root.autocertHosts)
+    }
+    if serverTLS == nil && (len(root.authUser) > 0 || len(root.authToken) > 0) {
+        log.Print("Warning: without TLS (--tlsCert or --autocert), the credentials are sent in plain text.")
+    }
+    return nil
+}
+
+// newGRPCServer creates the gRPC service, using the TLS configuration & requiring the credentials of the web server
+func newGRPCServer() *grpc.Server {
+    options := make([]grpc.ServerOption, 0, 2)
+    if serverTLS != nil {
+        options = append(options, grpc.Creds(credentials.NewTLS(serverTLS)))
+    }
+    if len(root.authUser) > 0 || len(root.authToken) > 0 {
+        options = append(options, grpcserver.Authenticate(func(header string) bool {
+            _, ok := authorization(header)
+            return ok
+        }))
+    }
+    return grpcserver.NewServer(options...)
+}
+
+// serveWeb runs the web server on --port: with HTTPS if TLS is configured (see setupServerSecurity), with plain HTTP
+// otherwise. Every request passes webHandler.
+func serveWeb() error {
+    server := &http.Server{Addr: ":" + strconv.Itoa(root.port), Handler: webHandler(http.DefaultServeMux), TLSConfig: serverTLS}
+    if serverTLS != nil {
+        return server.ListenAndServeTLS("", "")
+    }
+    return server.ListenAndServe()
+}
+
+// webSessionCookie names the cookie holding the ID of the session of a client of the web server
+const webSessionCookie = "qrfile_session"
+
+// webSession is what a single client of the web server sees: the sets, uploads, restored files & receiver sessions it
+// created are tagged with the ID of its session & not found by other clients, and its uploads are stored in a
+// temporary directory of its own. Every client keeps the random ID of its session in a cookie, also with the
+// credentials of --authUser or --authToken, which are shared by all clients; the credential a session was started with
+// is only checked against later requests. Sessions are started by the first request creating something & deleted
+// (with their temporary directory) after --sessionTimeout without requests.
+type webSession struct {
+    ID       string
+    identity string    // credential the session was started with, if any (see authorization)
+    dir      string    // temporary directory of the uploads, created with the first upload
+    updated  time.Time // time of the last request
+}
+
+// webSessions holds the sessions of the web server, by ID
+var webSessions = struct {
+    sync.Mutex
+    sessions map[string]*webSession
+}{sessions: make(map[string]*webSession)}
+
+// webClientKey is the key of the client in the context of a request (see clientSession)
+type webClientKey struct{}
+
+// webClient is the client of a request, as passed on by webHandler: its session is looked up or started when a
+// handler needs it
+type webClient struct {
+    w        http.ResponseWriter // response of the request, to set the cookie of a session started
+    identity string              // credential the request was authenticated with, if any (see authorization)
+}
+
+// webHandler wraps the handlers of the web server: requests without the credentials of --authUser or --authToken are
+// refused, the others are passed on with their client (see webClient) in their context
+func webHandler(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        identity, ok := authorization(r.Header.Get("Authorization"))
+        if !ok {
+            if len(root.authUser) > 0 {
+                w.Header().Set("WWW-Authenticate", `Basic realm="qrFile", charset="UTF-8"`)
+            }
+            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+            return
+        }
+        client := &webClient{w: w, identity: identity}
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webClientKey{}, client)))
+    })
+}
+
+// authorization checks the Authorization header of a request (or the authorization metadata of a gRPC call) against
+// the credentials required: the password of --authUser (basic authentication) or the token of --authToken (Bearer
+// <token>). It returns the identity of the credential given (empty if none is required) & whether it is accepted.
+func authorization(header string) (string, bool) {
+    if len(root.authUser) == 0 && len(root.authToken) == 0 {
+        return "", true
+    }
+    if encoded, ok := strings.CutPrefix(header, "Basic "); ok && len(root.authUser) > 0 {
+        decoded, err := base64.StdEncoding.DecodeString(encoded)
+        if err != nil {
+            return "", false
+        }
+        user, password, ok := strings.Cut(string(decoded), ":")
+        return "user:" + user, ok && secretEqual(user, root.authUser) && secretEqual(password, root.authPassword)
+    }
+    if token, ok := strings.CutPrefix(header, "Bearer "); ok && len(root.authToken) > 0 {
+        return "token", secretEqual(token, root.authToken)
+    }
+    return "", false
+}
+
+// secretEqual compares a credential in constant time, so the time taken does not tell how much of it matched
+func secretEqual(given string, expected string) bool {
+    return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// clientSession returns the session of the client of a request: the one named by its cookie, provided it was started
+// with the credential of the request. If there is none (or it expired), a new one is started if start is set (setting
+// its cookie); nil is returned otherwise.
+func clientSession(r *http.Request, start bool) (*webSession, error) {
+    client, _ := r.Context().Value(webClientKey{}).(*webClient)
+    if client == nil {
+        return nil, errors.New("Request without client")
+    }
+    webSessions.Lock()
+    defer webSessions.Unlock()
+    if cookie, err := r.Cookie(webSessionCookie); err == nil {
+        if session, ok := webSessions.sessions[cookie.Value]; ok && session.identity == client.identity {
+            session.updated = time.Now()
+            return session, nil
+        }
+    }
+    if !start {
+        return nil, nil
+    }
+    random := make([]byte, 16)
+    _, err := rand.Read(random)
+    if err != nil {
+        return nil, err
+    }
+    id := hex.EncodeToString(random)
+    http.SetCookie(client.w, &http.Cookie{Name: webSessionCookie, Value: id, Path: "/", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+    session := &webSession{ID: id, identity: client.identity, updated: time.Now()}
+    webSessions.sessions[id] = session
+    return session, nil
+}
+
+// startSession returns the session of the client of a request passed on by webHandler, starting one if it has none;
+// it has to be called before the response is written
+func startSession(r *http.Request) (*webSession, error) {
+    session, err := clientSession(r, true)
+    if err != nil {
+        return nil, fmt.Errorf("Unable to start the session: %w", err)
+    }
+    return session, nil
+}
+
+// sessionID returns the ID of the session of the client of a request passed on by webHandler; empty if it has none,
+// so it owns nothing
+func sessionID(r *http.Request) string {
+    session, _ := clientSession(r, false)
+    if session != nil {
+        return session.ID
+    }
+    return ""
+}
+
+// tempDir returns the temporary directory of the uploads of the session, creating it if needed
+func (session *webSession) tempDir() (string, error) {
+    webSessions.Lock()
+    defer webSessions.Unlock()
+    if len(session.dir) == 0 {
+        dir, err := ioutil.TempDir("", "qrFileSession")
+        if err != nil {
+            return "", err
+        }
+        session.dir = dir
+    }
+    return session.dir, nil
+}
+
+// expireSessions periodically deletes the sessions without requests for --sessionTimeout, along with their temporary
+// directories; it runs independently of --retention, so sessions do not pile up if the sets are kept
+func expireSessions() {
+    interval := root.sessionTimeout / 10
+    if interval > time.Minute {
+        interval = time.Minute
+    }
+    if interval < time.Second {
+        interval = time.Second
+    }
+    for range time.Tick(interval) {
+        limit := time.Now().Add(-root.sessionTimeout)
+        webSessions.Lock()
+        for id, session := range webSessions.sessions {
+            if session.updated.Before(limit) {
+                if len(session.dir) > 0 {
+                    os.RemoveAll(session.dir)
+                }
+                delete(webSessions.sessions, id)
+            }
+        }
+        webSessions.Unlock()
+    }
+}
+
+// http handlers for interactive mode
+func httpHandler(w http.ResponseWriter, r *http.Request) {
+    t, _ := template.ParseFiles("template/index.html")
+    t.Execute(w, nil)
+}
+
+// handleUploadedFile converts the files uploaded by the form of the start page (any number of them) to sets & shows the
+// set, or the list of the sets if there are several; errors are shown on an error page (see showWebError)
+func handleUploadedFile(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        showWebError(w, http.StatusMethodNotAllowed, errors.New("Files are uploaded with the form of the start page."))
+        return
+    }
+    sets, status, err := setsFromUpload(w, r, false)
+    if err != nil {
+        log.Printf("Upload failed: %s", err)
+        showWebError(w, status, err)
+        return
+    }
+    if len(sets) == 1 {
+        showWebSet(w, sets[0])
+        return
+    }
+    t, _ := template.ParseFiles("template/sets.html")
+    t.Execute(w, sets)
+}
+
+// setsFromUpload creates a set from each file uploaded in the form field file (only one if single is set), using the
+// options of the upload form (see webEncodeOptions); the status is the one to respond with if err is set. The files are
+// streamed to disk (see receiveUpload).
+func setsFromUpload(w http.ResponseWriter, r *http.Request, single bool) ([]*webSet, int, error) {
+    files, status, err := receiveUpload(w, r, "file")
+    defer removeUploads(files)
+    if err == nil && single && len(files) > 1 {
+        status, err = http.StatusBadRequest, errors.New(fmt.Sprintf("%d files uploaded, expected a single one", len(files)))
+    }
+    if err != nil {
+        return nil, status, err
+    }
+    options, err := webEncodeOptions(r)
+    if err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    session, err := startSession(r)
+    if err != nil {
+        return nil, http.StatusInternalServerError, err
+    }
+    sets := make([]*webSet, 0, len(files))
+    for _, file := range files {
+        log.Printf("Handling request for uploaded file %s (%d bytes)", file.Filename, file.Size)
+        // the images are rendered when they are requested
+        set, err := storeWebSet(session.ID, file.path, file.Filename, file.Size, options)
+        if err != nil {
+            return nil, http.StatusUnprocessableEntity, errors.New(fmt.Sprintf("Unable to convert %s: %s", file.Filename, err))
+        }
+        sets = append(sets, set)
+    }
+    return sets, http.StatusOK, nil
+}
+
+// uploadedFile is a file of a multipart upload, stored in a temporary file by receiveUpload
+type uploadedFile struct {
+    Filename string
+    Size     int64
+    path     string
+}
+
+// maxUploadFieldSize limits the size of the form fields of an upload which are no files, in bytes
+const maxUploadFieldSize = 64 << 10
+
+// receiveUpload streams the files of a multipart upload in the form field field to temporary files as they arrive,
+// without holding them in memory; the other fields are stored in r.Form, so FormValue works as usual. Each file is
+// limited to --maxSize, the whole request to --maxUpload. At least one file is required. The status is the one
+// to respond with if err is set; the files received so far are returned anyway, to be removed with removeUploads.
+func receiveUpload(w http.ResponseWriter, r *http.Request, field string) ([]uploadedFile, int, error) {
+    if root.maxUpload > 0 {
+        r.Body = http.MaxBytesReader(w, r.Body, root.maxUpload)
+    }
+    reader, err := r.MultipartReader()
+    if err != nil {
+        return nil, http.StatusBadRequest, errors.New(fmt.Sprintf("Expected a multipart form upload: %s", err))
+    }
+    files := make([]uploadedFile, 0)
+    values := r.URL.Query()
+    for {
+        part, err := reader.NextPart()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            status, err := uploadReadError(err, "read the upload")
+            return files, status, err
+        }
+        if part.FormName() != field || len(part.FileName()) == 0 {
+            value, err := ioutil.ReadAll(io.LimitReader(part, maxUploadFieldSize+1))
+            if err != nil {
+                status, err := uploadReadError(err, "read the upload")
+                return files, status, err
+            }
+            if len(value) > maxUploadFieldSize {
+                return files, http.StatusRequestEntityTooLarge, errors.New(fmt.Sprintf("The form field %s exceeds %d bytes", part.FormName(), maxUploadFieldSize))
+            }
+            values.Add(part.FormName(), string(value))
+            continue
+        }
+        file := uploadedFile{Filename: filepath.Base(part.FileName())}
+        session, err := startSession(r)
+        if err != nil {
+            return files, http.StatusInternalServerError, err
+        }
+        dir, err := session.tempDir()
+        if err != nil {
+            return files, http.StatusInternalServerError, errors.New(fmt.Sprintf("Unable to create the temporary directory: %s", err))
+        }
+        tempfile, err := ioutil.TempFile(dir, "qrFileTemp")
+        if err != nil {
+            return files, http.StatusInternalServerError, errors.New(fmt.Sprintf("Unable to create the temporary file: %s", err))
+        }
+        file.path = tempfile.Name()
+        files = append(files, file)
+        var in io.Reader = part
+        if root.maxFileSize > 0 {
+            in = io.LimitReader(part, root.maxFileSize+1)
+        }
+        file.Size, err = io.Copy(tempfile, in)
+        if closeErr := tempfile.Close(); err == nil {
+            err = closeErr
+        }
+        files[len(files)-1] = file
+        if err != nil {
+            status, err := uploadReadError(err, "receive "+file.Filename)
+            return files, status, err
+        }
+        if root.maxFileSize > 0 && file.Size > root.maxFileSize {
+            return files, http.StatusRequestEntityTooLarge, errors.New(fmt.Sprintf("%s is larger than the maximum of %d bytes", file.Filename, root.maxFileSize))
+        }
+    }
+    r.Form, r.PostForm = values, values
+    if len(files) == 0 {
+        return files, http.StatusBadRequest, errors.New("No file was uploaded.")
+    }
+    return files, http.StatusOK, nil
+}
+
+// uploadReadError describes an error reading an upload & returns the status to respond with: 413 if the request
+// exceeds --maxUpload, 400 otherwise
+func uploadReadError(err error, action string) (int, error) {
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        return http.StatusRequestEntityTooLarge, errors.New(fmt.Sprintf("The upload is larger than the maximum of %d bytes", tooLarge.Limit))
+    }
+    return http.StatusBadRequest, errors.New(fmt.Sprintf("Unable to %s: %s", action, err))
+}
+
+// removeUploads removes the temporary files of an upload
+func removeUploads(files []uploadedFile) {
+    for _, file := range files {
+        os.Remove(file.path)
+    }
+}
+
+// webError is shown by template/error.html
+type webError struct {
+    Status  int
+    Title   string // text of the status, e.g. Request Entity Too Large
+    Message string
+}
+
+// showWebError responds with an error page stating the status & err
+func showWebError(w http.ResponseWriter, status int, err error) {
+    message := err.Error()
+    if status >= http.StatusInternalServerError {
+        // details of the server stay in its log
+        message = "The server failed to handle the request; the details are in its log."
+    }
+    t, parseErr := template.ParseFiles("template/error.html")
+    if parseErr != nil {
+        http.Error(w, message, status)
+        return
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(status)
+    t.Execute(w, webError{Status: status, Title: http.StatusText(status), Message: message})
+}
+
+// storeWebSet splits an uploaded file (stored in fname) into elements & adds the set to the store, as set of the given
+// session
+func storeWebSet(session string, fname string, filename string, size int64, options qrFile.EncodeOptions) (*webSet, error) {
+    set, err := newWebSet(filename)
+    if err != nil {
+        return nil, err
+    }
+    set.session = session
+    if root.recordFileInfo {
+        // the data is read from a temporary file, the set is to name the uploaded one
+        options.File = &qrFile.FileInfo{Name: filepath.Base(filename), Size: uint64(size), ModTime: set.Created.Truncate(time.Second)}
+    }
+    set.elements, err = elementsFromFile(fname, options)
+    if err != nil {
+        return nil, err
+    }
+    set.Size = size
+    set.Count = set.elements.Len()
+    webSets.Lock()
+    webSets.sets[set.ID] = set
+    webSets.Unlock()
+    return set, nil
+}
+
+// uploadChunkLimit limits the data accepted by a single PATCH request of a resumable upload, in bytes
+const uploadChunkLimit = 16 << 20
+
+// webUpload is a resumable upload (see handleAPIUploads); the data received so far is kept in a temporary file
+type webUpload struct {
+    sync.Mutex
+    ID       string `json:"id"`
+    Filename string `json:"filename"`
+    Size     int64  `json:"size"`
+    Offset   int64  `json:"offset"`        // number of bytes received so far
+    Set      string `json:"set,omitempty"` // ID of the set, once the upload is complete
+    updated  time.Time
+    session  string // ID of the session which started the upload (see webSession)
+    path     string
+    options  qrFile.EncodeOptions
+}
+
+// webUploads holds the resumable uploads in progress, by ID
+var webUploads = struct {
+    sync.Mutex
+    uploads map[string]*webUpload
+}{uploads: make(map[string]*webUpload)}
+
+// handleAPIUploads implements resumable uploads of large files over unreliable connections, similar to the tus
+// protocol: POST /api/v1/uploads?filename=<name>&size=<bytes> starts an upload (with the options of the upload form,
+// see webEncodeOptions) & returns its URL in the Location header. PATCH /api/v1/uploads/<id> appends the request body,
+// its Upload-Offset header has to match the number of bytes received so far; HEAD or GET return this number, so an
+// interrupted upload is continued from there. Once all data is received, the set is created; its ID is returned in the
+// field set of the JSON status. DELETE cancels an upload.
+func handleAPIUploads(w http.ResponseWriter, r *http.Request) {
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/uploads"), "/")
+    if len(id) == 0 {
+        if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        createUpload(w, r)
+        return
+    }
+    webUploads.Lock()
+    upload := webUploads.uploads[id]
+    webUploads.Unlock()
+    if upload == nil || upload.session != sessionID(r) {
+        http.NotFound(w, r)
+        return
+    }
+    upload.Lock()
+    defer upload.Unlock()
+    switch r.Method {
+    case http.MethodGet, http.MethodHead:
+        writeUploadStatus(w, upload, http.StatusOK)
+    case http.MethodPatch:
+        appendUpload(w, r, upload)
+    case http.MethodDelete:
+        deleteUpload(id)
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        w.Header().Set("Allow", "GET, HEAD, PATCH, DELETE")
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// createUpload starts a resumable upload
+func createUpload(w http.ResponseWriter, r *http.Request) {
+    size, err := strconv.ParseInt(r.FormValue("size"), 10, 64)
+    if err != nil || size < 0 {
+        http.Error(w, "Invalid size", http.StatusBadRequest)
+        return
+    }
+    if root.maxFileSize > 0 && size > root.maxFileSize {
+        http.Error(w, fmt.Sprintf("The file is larger than the maximum of %d bytes", root.maxFileSize), http.StatusRequestEntityTooLarge)
+        return
+    }
+    options, err := webEncodeOptions(r)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    id, err := randomID()
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to create the upload", http.StatusInternalServerError)
+        return
+    }
+    session, err := startSession(r)
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to create the upload", http.StatusInternalServerError)
+        return
+    }
+    dir, err := session.tempDir()
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to create the upload", http.StatusInternalServerError)
+        return
+    }
+    tempfile, err := ioutil.TempFile(dir, "qrFileUpload")
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to create the upload", http.StatusInternalServerError)
+        return
+    }
+    tempfile.Close()
+    upload := &webUpload{ID: id, Filename: r.FormValue("filename"), Size: size, updated: time.Now(), session: session.ID, path: tempfile.Name(), options: options}
+    webUploads.Lock()
+    webUploads.uploads[id] = upload
+    webUploads.Unlock()
+    log.Printf("Started upload %s of %s (%d bytes)", id, upload.Filename, size)
+    w.Header().Set("Location", "/api/v1/uploads/"+id)
+    upload.Lock()
+    defer upload.Unlock()
+    if size == 0 {
+        // nothing to wait for
+        completeUpload(w, upload)
+        return
+    }
+    writeUploadStatus(w, upload, http.StatusCreated)
+}
+
+// appendUpload appends the body of a PATCH request to an upload & creates the set once the upload is complete
+func appendUpload(w http.ResponseWriter, r *http.Request, upload *webUpload) {
+    offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+    if err != nil || offset != upload.Offset || len(upload.Set) > 0 {
+        // the client has to ask for the offset & continue from there
+        writeUploadStatus(w, upload, http.StatusConflict)
+        return
+    }
+    file, err := os.OpenFile(upload.path, os.O_WRONLY, 0600)
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to store the data", http.StatusInternalServerError)
+        return
+    }
+    limit := upload.Size - upload.Offset
+    if limit > uploadChunkLimit {
+        limit = uploadChunkLimit
+    }
+    _, err = file.Seek(upload.Offset, io.SeekStart)
+    var written int64
+    if err == nil {
+        // keep what was received, even if the connection breaks
+        written, err = io.Copy(file, io.LimitReader(r.Body, limit))
+    }
+    upload.Offset += written
+    upload.updated = time.Now()
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        log.Printf("Upload %s interrupted at %d bytes: %s", upload.ID, upload.Offset, err)
+        writeUploadStatus(w, upload, http.StatusBadRequest)
+        return
+    }
+    if upload.Offset < upload.Size {
+        writeUploadStatus(w, upload, http.StatusOK)
+        return
+    }
+    completeUpload(w, upload)
+}
+
+// completeUpload creates the set of a complete upload; the data is deleted, the upload stays until it expires, so a
+// client which missed the response can still learn the set
+func completeUpload(w http.ResponseWriter, upload *webUpload) {
+    defer os.Remove(upload.path)
+    set, err := storeWebSet(upload.session, upload.path, upload.Filename, upload.Size, upload.options)
+    if err != nil {
+        log.Printf("Error parsing file: %s", err.Error())
+        deleteUpload(upload.ID)
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+    upload.Set = set.ID
+    log.Printf("Completed upload %s as set %s", upload.ID, set.ID)
+    writeUploadStatus(w, upload, http.StatusOK)
+}
+
+// writeUploadStatus writes the state of an upload as JSON & its offset in the Upload-Offset header
+func writeUploadStatus(w http.ResponseWriter, upload *webUpload, status int) {
+    w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(upload)
+}
+
+// deleteUpload removes an upload & its data
+func deleteUpload(id string) {
+    webUploads.Lock()
+    upload := webUploads.uploads[id]
+    delete(webUploads.uploads, id)
+    webUploads.Unlock()
+    if upload != nil {
+        os.Remove(upload.path)
+    }
+}
+
+// webEncodeOptions returns the encode options of the command line, changed by the options of the upload form: level
+// (L, M, Q or H) and plain (format of the codes)
+func webEncodeOptions(r *http.Request) (qrFile.EncodeOptions, error) {
+    options := root.encodeOptions
+    if levelName := r.FormValue("level"); len(levelName) > 0 {
+        level, err := qrFile.ParseLevel(levelName)
+        if err != nil {
+            return options, err
+        }
+        options.Level = level
+        options.Levels = nil
+    }
+    if len(r.FormValue("plain")) > 0 {
+        options.Version = qrFile.VersionPlain
+    }
+    return options, nil
+}
+
+// printCodesPerPage is the number of codes printed on a page (see template/print.html)
+const printCodesPerPage = 6
+
+// handleEstimate implements GET /api/v1/estimate?size=<bytes>: the number of codes & printed pages a file of this size
+// results in, using the options of the upload form (see webEncodeOptions)
+func handleEstimate(w http.ResponseWriter, r *http.Request) {
+    size, err := strconv.ParseUint(r.FormValue("size"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid size", http.StatusBadRequest)
+        return
+    }
+    estimate := struct {
+        Codes uint64 `json:"codes"`
+        Pages uint64 `json:"pages"`
+        Error string `json:"error,omitempty"`
+    }{}
+    options, err := webEncodeOptions(r)
+    if err == nil {
+        estimate.Codes, err = qrFile.EstimateCount(size, options)
+    }
+    if err == nil && options.MaxCount > 0 && estimate.Codes > options.MaxCount {
+        err = errors.New(fmt.Sprintf("The file needs more than the maximum of %d codes", options.MaxCount))
+    }
+    if err == nil && root.maxFileSize > 0 && size > uint64(root.maxFileSize) {
+        err = errors.New(fmt.Sprintf("The file is larger than the maximum of %d bytes", root.maxFileSize))
+    }
+    if err != nil {
+        estimate.Error = err.Error()
+    }
+    estimate.Pages = (estimate.Codes + printCodesPerPage - 1) / printCodesPerPage
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(estimate)
+}
+
+// webMetrics collects the measurements of the library (see qrFile.Metrics) for handleMetrics
+type webMetrics struct {
+    encoded, decoded       int64
+    writeCount, writeNanos int64
+    readCount, readNanos   int64
+    workers                int64
+}
+
+// serverMetrics holds the measurements of the web server
+var serverMetrics = new(webMetrics)
+
+func (m *webMetrics) ChunksEncoded(n int) {
+    atomic.AddInt64(&m.encoded, int64(n))
+}
+
+func (m *webMetrics) ChunksDecoded(n int) {
+    atomic.AddInt64(&m.decoded, int64(n))
+}
+
+func (m *webMetrics) ImageWritten(d time.Duration) {
+    atomic.AddInt64(&m.writeCount, 1)
+    atomic.AddInt64(&m.writeNanos, int64(d))
+}
+
+func (m *webMetrics) ImageDecoded(d time.Duration) {
+    atomic.AddInt64(&m.readCount, 1)
+    atomic.AddInt64(&m.readNanos, int64(d))
+}
+
+func (m *webMetrics) ActiveWorkers(delta int) {
+    atomic.AddInt64(&m.workers, int64(delta))
+}
+
+// handleMetrics returns the measurements of the web server in the text format of Prometheus
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+    m := serverMetrics
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+    fmt.Fprintf(w, "# HELP qrfile_chunks_encoded_total Chunks split off uploaded files.\n# TYPE qrfile_chunks_encoded_total counter\nqrfile_chunks_encoded_total %d\n", atomic.LoadInt64(&m.encoded))
+    fmt.Fprintf(w, "# HELP qrfile_chunks_decoded_total Chunks read from images or text.\n# TYPE qrfile_chunks_decoded_total counter\nqrfile_chunks_decoded_total %d\n", atomic.LoadInt64(&m.decoded))
+    for _, summary := range []struct {
+        name, help   string
+        count, nanos *int64
+    }{{"qrfile_image_write_seconds", "Time to render and write an image.", &m.writeCount, &m.writeNanos},
+        {"qrfile_image_decode_seconds", "Time to read an image file.", &m.readCount, &m.readNanos}} {
+        fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n%s_sum %g\n%s_count %d\n", summary.name, summary.help, summary.name,
+            summary.name, time.Duration(atomic.LoadInt64(summary.nanos)).Seconds(), summary.name, atomic.LoadInt64(summary.count))
+    }
+    fmt.Fprintf(w, "# HELP qrfile_active_workers Workers rendering or reading images.\n# TYPE qrfile_active_workers gauge\nqrfile_active_workers %d\n", atomic.LoadInt64(&m.workers))
+}
+
+// webSet is a set generated by the web interface; only the elements are stored, images are rendered on demand
+type webSet struct {
+    ID       string
+    Filename string // name of the uploaded file
+    Count    int
+    Size     int64 // size of the uploaded file in bytes
+    Created  time.Time
+    session  string // ID of the session which created the set (see webSession)
+    elements *qrFile.QrElements
+}
+
+// webSets is the session store of the sets generated by the web interface, by ID
+var webSets = struct {
+    sync.Mutex
+    sets map[string]*webSet
+}{sets: make(map[string]*webSet)}
+
+// newWebSet creates a set with a random ID
+func newWebSet(filename string) (*webSet, error) {
+    id, err := randomID()
+    if err != nil {
+        return nil, err
+    }
+    return &webSet{ID: id, Filename: filename, Created: time.Now()}, nil
+}
+
+// randomID returns a random ID for sets & uploads
+func randomID() (string, error) {
+    id := make([]byte, 8)
+    _, err := rand.Read(id)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(id), nil
+}
+
+// Images returns the URLs of the images of the set, ordered by their number
+func (set *webSet) Images() []string {
+    images := make([]string, set.Count)
+    for i := range images {
+        images[i] = fmt.Sprintf("/api/v1/sets/%s/chunks/%d.png", set.ID, i)
+    }
+    return images
+}
+
+// Pages returns the numbers of the images of the set, grouped by printed page
+func (set *webSet) Pages() [][]int {
+    pages := make([][]int, 0, (set.Count+printCodesPerPage-1)/printCodesPerPage)
+    for i := 0; i < set.Count; i++ {
+        if i%printCodesPerPage == 0 {
+            pages = append(pages, make([]int, 0, printCodesPerPage))
+        }
+        pages[len(pages)-1] = append(pages[len(pages)-1], i)
+    }
+    return pages
+}
+
+// getWebSet returns the set with the given ID, nil if it does not exist or belongs to another session than the one of
+// the request
+func getWebSet(r *http.Request, id string) *webSet {
+    webSets.Lock()
+    defer webSets.Unlock()
+    set := webSets.sets[id]
+    if set == nil || set.session != sessionID(r) {
+        return nil
+    }
+    return set
+}
+
+// deleteWebSet removes a set from the store
+func deleteWebSet(id string) bool {
+    webSets.Lock()
+    defer webSets.Unlock()
+    _, ok := webSets.sets[id]
+    delete(webSets.sets, id)
+    return ok
+}
+
+// maxChunkImageSize limits the size requested from the chunk image endpoint, in pixels
+const maxChunkImageSize = 4096
+
+// handleAPISets implements the set API: DELETE /api/v1/sets/<id> deletes a set, GET /api/v1/sets/<id>/chunks/<n>.png
+// renders the image of chunk n; the query parameters size (width in pixels) and level (error correction level, L, M, Q
+// or H) change the rendering. POST /api/v1/sets/<id>/verify checks scanned codes against the set (see
+// verifyUploadedCodes).
+func handleAPISets(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/sets/"), "/"), "/")
+    id := parts[0]
+    if len(id) == 0 {
+        http.NotFound(w, r)
+        return
+    }
+    if len(parts) == 3 && parts[1] == "chunks" && strings.HasSuffix(parts[2], ".png") {
+        handleChunkImage(w, r, id, strings.TrimSuffix(parts[2], ".png"))
+        return
+    }
+    if len(parts) == 2 && parts[1] == "verify" {
+        set := getWebSet(r, id)
+        if set == nil {
+            http.NotFound(w, r)
+            return
+        }
+        verifyUploadedCodes(w, r, set.elements.Manifest())
+        return
+    }
+    if len(parts) != 1 {
+        http.NotFound(w, r)
+        return
+    }
+    if r.Method != http.MethodDelete {
+        w.Header().Set("Allow", http.MethodDelete)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if getWebSet(r, id) == nil || !deleteWebSet(id) {
+        http.NotFound(w, r)
+        return
+    }
+    log.Printf("Deleted set %s", id)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIVerify checks scanned codes of any set on their own (see verifyUploadedCodes): copies of the same code are
+// compared, as no manifest is known
+func handleAPIVerify(w http.ResponseWriter, r *http.Request) {
+    verifyUploadedCodes(w, r, nil)
+}
+
+// verifyUploadedCodes checks the codes posted by a receiver against manifest (see qrFile.Verifier) & returns the
+// present, missing & corrupt indices as JSON. The codes are sent as plain request body (the text of one code per line)
+// or as form: the text in the field chunks and any number of images in the field file.
+func verifyUploadedCodes(w http.ResponseWriter, r *http.Request, manifest *qrFile.Manifest) {
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if root.maxFileSize > 0 {
+        r.Body = http.MaxBytesReader(w, r.Body, root.maxFileSize)
+    }
+    verifier := qrFile.NewVerifier(manifest)
+    if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+        err := verifier.ReadText(r.Body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    } else {
+        err := r.ParseMultipartForm(32 << 20)
+        if err != nil && err != http.ErrNotMultipart {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        verifier.ReadText(strings.NewReader(r.FormValue("chunks")))
+        if r.MultipartForm != nil {
+            defer r.MultipartForm.RemoveAll()
+            for _, header := range r.MultipartForm.File["file"] {
+                err = verifyUploadedImage(verifier, header)
+                if err != nil {
+                    log.Print(err)
+                    http.Error(w, "Unable to store the image: "+err.Error(), http.StatusInternalServerError)
+                    return
+                }
+            }
+        }
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(verifier.Result())
+}
+
+// verifyUploadedImage hands an uploaded image to the verifier; the decoders read files, so it is stored temporarily
+func verifyUploadedImage(verifier *qrFile.Verifier, header *multipart.FileHeader) error {
+    file, err := header.Open()
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    verifier.AddReader(file, header.Filename, symbolDecoder)
+    return nil
+}
+
+// apiError is the body of the error responses of the encode, decode & status API
+type apiError struct {
+    Error   string   `json:"error"`
+    Missing []uint64 `json:"missing,omitempty"` // chunks missing to restore a set (see qrFile.IncompleteError)
+}
+
+// writeAPIError sends err as JSON with the given status
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+    body := apiError{Error: err.Error()}
+    var incomplete *qrFile.IncompleteError
+    if errors.As(err, &incomplete) {
+        body.Missing = incomplete.Missing
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(body)
+}
+
+// apiChunk describes a chunk of a set in the JSON response of handleAPIEncode
+type apiChunk struct {
+    Index uint64 `json:"index"`
+    Text  string `json:"text"`  // text of the code (see qrFile.QrElement.AsString)
+    Image string `json:"image"` // URL of the image, see handleChunkImage
+}
+
+// handleAPIEncode implements POST /api/v1/encode: the file uploaded in the form field file is converted to a set with
+// the options of the upload form (see webEncodeOptions). The set is kept like one created by the upload form; the
+// response is its zip archive (a .qrf container with the images) or, with format=json, the set ID & the text & image
+// URL of every chunk.
+func handleAPIEncode(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    sets, status, err := setsFromUpload(w, r, true)
+    if err != nil {
+        log.Print(err)
+        writeAPIError(w, status, err)
+        return
+    }
+    set := sets[0]
+    if r.FormValue("format") != "json" {
+        w.Header().Set("Content-Type", "application/zip")
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", set.Filename+".zip"))
+        err = set.elements.Pack(w, true)
+        if err != nil {
+            log.Print(err)
+        }
+        return
+    }
+    response := struct {
+        Set      string     `json:"set"`
+        Filename string     `json:"filename"`
+        Size     int64      `json:"size"`
+        Chunks   []apiChunk `json:"chunks"`
+    }{Set: set.ID, Filename: set.Filename, Size: set.Size, Chunks: make([]apiChunk, set.Count)}
+    images := set.Images()
+    for i, v := range set.elements.Elements {
+        response.Chunks[i] = apiChunk{Index: v.Index, Text: v.AsString(), Image: images[i]}
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// handleAPIDecode implements POST /api/v1/decode: the images uploaded in the form field file (any number of them) are
+// read & the restored file is returned as download, under the name recorded in the set if there is one. Encrypted data
+// is decrypted with the form field passphrase. If chunks are missing, the JSON error lists them.
+func handleAPIDecode(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if root.maxFileSize > 0 {
+        r.Body = http.MaxBytesReader(w, r.Body, root.maxFileSize)
+    }
+    err := r.ParseMultipartForm(32 << 20)
+    if err != nil {
+        writeAPIError(w, http.StatusBadRequest, err)
+        return
+    }
+    defer r.MultipartForm.RemoveAll()
+    headers := r.MultipartForm.File["file"]
+    if len(headers) == 0 {
+        writeAPIError(w, http.StatusBadRequest, errors.New("No images uploaded in the field file"))
+        return
+    }
+    images := make([]image.Image, 0, len(headers))
+    for _, header := range headers {
+        read, err := readUploadedImages(header)
+        if err != nil {
+            // like images without a code, files which can not be read are skipped
+            log.Printf("%s: %s", header.Filename, err)
+            continue
+        }
+        images = append(images, read...)
+    }
+    options := qrFile.DecodeOptions{Decoder: symbolDecoder, MaxFileSize: root.maxSize()}
+    options.Transforms = []qrFile.Transform{qrFile.EncryptTransform{Passphrase: r.FormValue("passphrase")}}
+    result, _, err := qrFile.DecodeImages(images, options)
+    if err != nil {
+        writeAPIError(w, http.StatusUnprocessableEntity, err)
+        return
+    }
+    filename := result.Fname
+    if len(filename) == 0 {
+        filename = "result"
+    }
+    log.Printf("Restored %s (%d bytes) from %d uploaded images", filename, len(result.Data), len(headers))
+    w.Header().Set("Content-Type", "application/octet-stream")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+    w.Write(result.Data)
+}
+
+// readUploadedImages reads all images of an uploaded file in memory (see qrFile.ReadImages)
+func readUploadedImages(header *multipart.FileHeader) ([]image.Image, error) {
+    file, err := header.Open()
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return qrFile.ReadImages(file)
+}
+
+// apiJob is the state of a set or an upload, as returned by handleAPIStatus
+type apiJob struct {
+    ID       string    `json:"id"`
+    Kind     string    `json:"kind"`  // set or upload
+    State    string    `json:"state"` // uploading, or done once the set is created
+    Filename string    `json:"filename"`
+    Size     int64     `json:"size"`               // size of the file in bytes
+    Received int64     `json:"received,omitempty"` // bytes of an upload received so far
+    Codes    int       `json:"codes,omitempty"`    // number of codes of a set
+    Set      string    `json:"set,omitempty"`      // ID of the set created by a complete upload
+    Updated  time.Time `json:"updated"`            // time the set was created or the upload last received data
+}
+
+// job returns the state of the set
+func (set *webSet) job() apiJob {
+    return apiJob{ID: set.ID, Kind: "set", State: "done", Filename: set.Filename, Size: set.Size, Codes: set.Count, Updated: set.Created}
+}
+
+// job returns the state of the upload; the upload has to be locked
+func (upload *webUpload) job() apiJob {
+    job := apiJob{ID: upload.ID, Kind: "upload", State: "uploading", Filename: upload.Filename, Size: upload.Size, Received: upload.Offset, Set: upload.Set, Updated: upload.updated}
+    if len(upload.Set) > 0 {
+        job.State = "done"
+    }
+    return job
+}
+
+// handleAPIStatus implements GET /api/v1/status: the state of all sets & uploads as JSON list, newest first, and GET
+// /api/v1/status/<id> for a single set or upload
+func handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", http.MethodGet)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/status"), "/")
+    session := sessionID(r)
+    jobs := make([]apiJob, 0)
+    webSets.Lock()
+    for _, set := range webSets.sets {
+        if set.session == session && (len(id) == 0 || set.ID == id) {
+            jobs = append(jobs, set.job())
+        }
+    }
+    webSets.Unlock()
+    // handlers lock an upload before the store, so the store is not locked while reading an upload
+    webUploads.Lock()
+    uploads := make([]*webUpload, 0, len(webUploads.uploads))
+    for _, upload := range webUploads.uploads {
+        if upload.session == session && (len(id) == 0 || upload.ID == id) {
+            uploads = append(uploads, upload)
+        }
+    }
+    webUploads.Unlock()
+    for _, upload := range uploads {
+        upload.Lock()
+        jobs = append(jobs, upload.job())
+        upload.Unlock()
+    }
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("Content-Type", "application/json")
+    if len(id) > 0 {
+        if len(jobs) == 0 {
+            writeAPIError(w, http.StatusNotFound, errors.New(fmt.Sprintf("No set or upload %s", id)))
+            return
+        }
+        json.NewEncoder(w).Encode(jobs[0])
+        return
+    }
+    sort.Slice(jobs, func(i, j int) bool { return jobs[i].Updated.After(jobs[j].Updated) })
+    json.NewEncoder(w).Encode(jobs)
+}
+
+// handleChunkImage renders the image of a single chunk of a set as png
+func handleChunkImage(w http.ResponseWriter, r *http.Request, id string, number string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Set("Allow", http.MethodGet)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    set := getWebSet(r, id)
+    index, err := strconv.ParseUint(number, 10, 64)
+    if set == nil || err != nil || index >= uint64(set.Count) {
+        http.NotFound(w, r)
+        return
+    }
+    // render from a copy, so the options do not change the stored set
+    elements := *set.elements
+    if levelName := r.FormValue("level"); len(levelName) > 0 {
+        elements.Level, err = qrFile.ParseLevel(levelName)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        elements.Levels = nil
+    }
+    img, err := elements.Image(index)
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to render the image: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if size := r.FormValue("size"); len(size) > 0 {
+        width, err := strconv.Atoi(size)
+        if err != nil || width < 1 || width > maxChunkImageSize {
+            http.Error(w, fmt.Sprintf("Invalid size %s (1-%d)", size, maxChunkImageSize), http.StatusBadRequest)
+            return
+        }
+        img = scaleImage(img, width)
+    }
+    w.Header().Set("Content-Type", "image/png")
+    png.Encode(w, img)
+}
+
+// scaleImage scales an image to the given width keeping its aspect ratio; pixels are not interpolated, so the modules
+// of a code stay sharp
+func scaleImage(img image.Image, width int) image.Image {
+    bounds := img.Bounds()
+    height := bounds.Dy() * width / bounds.Dx()
+    if height < 1 {
+        height = 1
+    }
+    scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            scaled.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height))
+        }
+    }
+    return scaled
+}
+
+// purgeExpired periodically deletes the sets, uploads, receiver sessions & restored files older than the retention period
+func purgeExpired() {
+    interval := root.retention / 10
+    if interval > time.Minute {
+        interval = time.Minute
+    }
+    if interval < time.Second {
+        interval = time.Second
+    }
+    for range time.Tick(interval) {
+        limit := time.Now().Add(-root.retention)
+        expired := make([]string, 0)
+        webSets.Lock()
+        for id, set := range webSets.sets {
+            if set.Created.Before(limit) {
+                expired = append(expired, id)
+            }
+        }
+        webSets.Unlock()
+        for _, id := range expired {
+            deleteWebSet(id)
+            log.Printf("Deleted expired set %s", id)
+        }
+        // handlers lock an upload before the store, so the store is not locked while checking an upload
+        webUploads.Lock()
+        uploads := make([]*webUpload, 0, len(webUploads.uploads))
+        for _, upload := range webUploads.uploads {
+            uploads = append(uploads, upload)
+        }
+        webUploads.Unlock()
+        for _, upload := range uploads {
+            upload.Lock()
+            if upload.updated.Before(limit) {
+                deleteUpload(upload.ID)
+                log.Printf("Deleted expired upload %s", upload.ID)
+            }
+            upload.Unlock()
+        }
+        scanSessions.Lock()
+        for id, session := range scanSessions.sessions {
+            if session.updated.Before(limit) {
+                delete(scanSessions.sessions, id)
+            }
+        }
+        scanSessions.Unlock()
+        webDecodedFiles.Lock()
+        for id, decoded := range webDecodedFiles.files {
+            if decoded.Created.Before(limit) {
+                delete(webDecodedFiles.files, id)
+            }
+        }
+        webDecodedFiles.Unlock()
+    }
+}
+
+func showWebSet(w http.ResponseWriter, set *webSet) {
+    t, _ := template.ParseFiles("template/show.html")
+    t.Execute(w, set)
+}
+
+// handleSets serves the set browser: /sets/ lists the sets generated so far, /sets/<id>/ shows the images of a set,
+// /sets/<id>/print a printable page, /sets/<id>/cover its cover sheet, /sets/<id>/zip a zip archive of the set (a .qrf
+// container, encrypted if the password field of a POST is set), /sets/<id>/images a zip archive of the images & the
+// manifest (see qrFile.QrElements.WriteZip) and a POST to /sets/<id>/delete deletes the set
+func handleSets(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/sets/"), "/"), "/")
+    if len(parts[0]) == 0 {
+        session := sessionID(r)
+        webSets.Lock()
+        sets := make([]*webSet, 0, len(webSets.sets))
+        for _, set := range webSets.sets {
+            if set.session == session {
+                sets = append(sets, set)
+            }
+        }
+        webSets.Unlock()
+        sort.Slice(sets, func(i, j int) bool { return sets[i].Created.After(sets[j].Created) })
+        t, _ := template.ParseFiles("template/sets.html")
+        t.Execute(w, sets)
+        return
+    }
+    set := getWebSet(r, parts[0])
+    if set == nil {
+        http.NotFound(w, r)
+        return
+    }
+    action := ""
+    if len(parts) > 1 {
+        action = parts[1]
+    }
+    switch action {
+    case "":
+        showWebSet(w, set)
+    case "print":
+        t, _ := template.ParseFiles("template/print.html")
+        t.Execute(w, set)
+    case "cover":
+        sheet, err := set.elements.CoverSheet(set.Filename)
+        if err == nil {
+            err = sheet.WriteHTML(w)
+        }
+        if err != nil {
+            log.Print(err)
+            http.Error(w, "Unable to create the cover sheet", http.StatusInternalServerError)
+        }
+    case "images":
+        w.Header().Set("Content-Type", "application/zip")
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", set.Filename+".images.zip"))
+        err := set.elements.WriteZip(w)
+        if err != nil {
+            log.Print(err)
+        }
+    case "zip":
+        w.Header().Set("Content-Type", "application/zip")
+        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", set.Filename+".zip"))
+        err := set.elements.PackWithPassword(w, true, r.PostFormValue("password"))
+        if err != nil {
+            log.Print(err)
+        }
+    case "delete":
+        if r.Method != http.MethodPost {
+            http.Error(w, "Deleting a set requires a POST request", http.StatusMethodNotAllowed)
+            return
+        }
+        deleteWebSet(set.ID)
+        http.Redirect(w, r, "/sets/", http.StatusSeeOther)
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func handleTextPage(w http.ResponseWriter, r *http.Request) {
+    t, _ := template.ParseFiles("template/text.html")
+    t.Execute(w, nil)
+}
+
+// handleTextDecode restores a file from pasted chunk text and returns it as download. The text is either the chunks
+// field of the form or, for other clients, the plain request body (e.g. curl --data-binary @scanned.txt -H
+// "Content-Type: text/plain"), which is read while it is uploaded. The data is sent using chunked transfer as soon as the
+// codes are in order, instead of restoring the whole file first.
+func handleTextDecode(w http.ResponseWriter, r *http.Request) {
+    var text io.Reader = r.Body
+    if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+        text = strings.NewReader(r.FormValue("chunks"))
+    }
+    out := &downloadWriter{w: w, filename: "result"}
+    err := qrFile.NewRestorer(out).ReadText(text)
+    if err != nil {
+        log.Print(err)
+        if out.started {
+            // the client must not take the truncated data for the complete file
+            panic(http.ErrAbortHandler)
+        }
+        http.Error(w, "Unable to restore the file: "+err.Error(), http.StatusBadRequest)
+    }
+}
+
+// downloadWriter sends the headers of a file download with the first data written & flushes every write, so the client
+// receives the data while it is restored
+type downloadWriter struct {
+    w        http.ResponseWriter
+    filename string
+    started  bool
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+    if !d.started {
+        d.w.Header().Set("Content-Type", "application/octet-stream")
+        d.w.Header().Set("Content-Disposition", "attachment; filename=\""+d.filename+"\"")
+        d.started = true
+    }
+    n, err := d.w.Write(p)
+    if flusher, ok := d.w.(http.Flusher); ok {
+        flusher.Flush()
+    }
+    return n, err
+}
+
+// webDecoded is a file restored from uploaded images by the decode page, kept for download until it expires
+type webDecoded struct {
+    ID       string
+    Filename string
+    Data     []byte
+    Created  time.Time
+    session  string // ID of the session which uploaded the images (see webSession)
+}
+
+// webDecodedFiles is the session store of the files restored by the decode page, by ID
+var webDecodedFiles = struct {
+    sync.Mutex
+    files map[string]*webDecoded
+}{files: make(map[string]*webDecoded)}
+
+// decodePage is shown by the decode page after an upload: the state of the set read & the link to the restored file
+type decodePage struct {
+    Error    string
+    Info     *qrFile.SetInfo
+    Found    string // indices of the codes found
+    Report   string // images which could not be read, codes reconstructed from parity codes
+    Filename string
+    Size     int
+    Download string // URL of the restored file, empty unless the set is complete
+}
+
+// handleDecodePage serves the decode page: a POST with scanned images (any number in the field file, or zip archives
+// of them) reads the set, shows the codes found & missing and links the restored file (see handleDecodedFile)
+func handleDecodePage(w http.ResponseWriter, r *http.Request) {
+    t, _ := template.ParseFiles("template/decode.html")
+    if r.Method != http.MethodPost {
+        t.Execute(w, nil)
+        return
+    }
+    page := decodeUploadedImages(w, r)
+    if len(page.Error) > 0 {
+        w.WriteHeader(http.StatusUnprocessableEntity)
+    }
+    t.Execute(w, page)
+}
+
+// decodeUploadedImages stores the uploaded images in a temporary directory, reads the set (see qrFile.InspectFiles) &
+// restores the file if the set is complete
+func decodeUploadedImages(w http.ResponseWriter, r *http.Request) *decodePage {
+    page := new(decodePage)
+    if root.maxFileSize > 0 {
+        r.Body = http.MaxBytesReader(w, r.Body, root.maxFileSize)
+    }
+    err := r.ParseMultipartForm(32 << 20)
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    defer r.MultipartForm.RemoveAll()
+    session, err := startSession(r)
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    sessionDir, err := session.tempDir()
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    dir, err := ioutil.TempDir(sessionDir, "qrFileDecode")
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    defer os.RemoveAll(dir)
+    headers := r.MultipartForm.File["file"]
+    for i, header := range headers {
+        err = storeUpload(header, filepath.Join(dir, strconv.Itoa(i)+"_"))
+        if err != nil {
+            page.Error = fmt.Sprintf("%s: %s", header.Filename, err)
+            return page
+        }
+    }
+    if len(headers) == 0 {
+        page.Error = "No images uploaded"
+        return page
+    }
+    info, elements, err := qrFile.InspectFiles([]string{dir}, qrFile.DecodeOptions{Decoder: symbolDecoder, MaxFileSize: root.maxSize()})
+    if elements != nil && elements.Report != nil {
+        // the names of the temporary files mean nothing to the user
+        page.Report = strings.Replace(elements.Report.String(), dir+string(filepath.Separator), "", -1)
+    }
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    page.Info = info
+    found := make([]uint64, 0, elements.Len())
+    reconstructed := make(map[uint64]bool)
+    if elements.Report != nil {
+        for _, index := range elements.Report.Reconstructed {
+            reconstructed[index] = true
+        }
+    }
+    for _, v := range elements.Elements {
+        if !reconstructed[v.Index] {
+            found = append(found, v.Index)
+        }
+    }
+    page.Found = indexList(found)
+    if !info.Complete {
+        return page
+    }
+    result := qrFile.New()
+    err = elements.RestoreData(result, []qrFile.Transform{qrFile.EncryptTransform{Passphrase: r.FormValue("passphrase")}})
+    if errors.Is(err, qrFile.ErrPassphraseRequired) {
+        page.Error = "The data is encrypted; enter the passphrase and upload the images again."
+        return page
+    }
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    id, err := randomID()
+    if err != nil {
+        page.Error = err.Error()
+        return page
+    }
+    decoded := &webDecoded{ID: id, Filename: filepath.Base(result.Fname), Data: result.Data, Created: time.Now(), session: session.ID}
+    if len(result.Fname) == 0 {
+        decoded.Filename = "result"
+    }
+    webDecodedFiles.Lock()
+    webDecodedFiles.files[id] = decoded
+    webDecodedFiles.Unlock()
+    log.Printf("Restored %s (%d bytes) from %d uploaded files", decoded.Filename, len(decoded.Data), len(headers))
+    page.Filename, page.Size, page.Download = decoded.Filename, len(decoded.Data), "/decoded/"+id
+    return page
+}
+
+// storeUpload writes an uploaded file to the path prefix + its name; the files of a zip archive are extracted instead,
+// as long as they do not exceed --maxSize in total
+func storeUpload(header *multipart.FileHeader, prefix string) error {
+    file, err := header.Open()
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    if !strings.EqualFold(filepath.Ext(header.Filename), ".zip") {
+        _, err = writeUploadFile(prefix+filepath.Base(header.Filename), file, root.maxFileSize)
+        return err
+    }
+    archive, err := zip.NewReader(file, header.Size)
+    if err != nil {
+        return err
+    }
+    remaining := root.maxFileSize
+    for i, entry := range archive.File {
+        if entry.FileInfo().IsDir() {
+            continue
+        }
+        content, err := entry.Open()
+        if err != nil {
+            return err
+        }
+        written, err := writeUploadFile(fmt.Sprintf("%s%d_%s", prefix, i, filepath.Base(entry.Name)), content, remaining)
+        content.Close()
+        if err != nil {
+            return errors.New(fmt.Sprintf("%s: %s", entry.Name, err))
+        }
+        remaining -= written
+        if root.maxFileSize > 0 && remaining <= 0 {
+            return errors.New(fmt.Sprintf("The files of the archive exceed %d bytes", root.maxFileSize))
+        }
+    }
+    return nil
+}
+
+// writeUploadFile writes the data read from r to the file fname & returns its size, failing if it exceeds limit bytes
+// (unless limit is 0)
+func writeUploadFile(fname string, r io.Reader, limit int64) (int64, error) {
+    out, err := os.Create(fname)
+    if err != nil {
+        return 0, err
+    }
+    if limit > 0 {
+        r = io.LimitReader(r, limit+1)
+    }
+    written, err := io.Copy(out, r)
+    if err == nil && limit > 0 && written > limit {
+        err = errors.New(fmt.Sprintf("The file exceeds %d bytes", limit))
+    }
+    if err != nil {
+        out.Close()
+        return written, err
+    }
+    return written, out.Close()
+}
+
+// handleDecodedFile returns a file restored by the decode page (/decoded/<id>) as download
+func handleDecodedFile(w http.ResponseWriter, r *http.Request) {
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/decoded/"), "/")
+    webDecodedFiles.Lock()
+    decoded := webDecodedFiles.files[id]
+    webDecodedFiles.Unlock()
+    if decoded == nil || decoded.session != sessionID(r) {
+        http.NotFound(w, r)
+        return
+    }
+    w.Header().Set("Content-Type", "application/octet-stream")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", decoded.Filename))
+    w.Write(decoded.Data)
+}
+
+func handleScanPage(w http.ResponseWriter, r *http.Request) {
+    t, _ := template.ParseFiles("template/scan.html")
+    t.Execute(w, nil)
+}
+
+// scanSession collects the codes received by a receiver page
+type scanSession struct {
+    assembler *qrFile.Assembler
+    updated   time.Time // time the last code was received
+}
+
+// scanSessions holds the sessions of the receiver pages, by session ID
+var scanSessions = struct {
+    sync.Mutex
+    sessions map[string]*scanSession
+}{sessions: make(map[string]*scanSession)}
+
+// scanSessionID returns the key of the receiver page session with the given name in scanSessions: the name is chosen by
+// the page, so it is combined with the session of the client (see webSession)
+func scanSessionID(r *http.Request, name string) string {
+    return sessionID(r) + "/" + name
+}
+
+// scanAssembler returns the Assembler of a receiver page session, creating it if needed
+func scanAssembler(id string) *qrFile.Assembler {
+    scanSessions.Lock()
+    defer scanSessions.Unlock()
+    session, ok := scanSessions.sessions[id]
+    if !ok {
+        session = &scanSession{assembler: qrFile.NewAssembler()}
+        scanSessions.sessions[id] = session
+    }
+    session.updated = time.Now()
+    return session.assembler
+}
+
+// handleScanCode adds the text of a single scanned code to the session of a receiver page & returns the progress as JSON
+func handleScanCode(w http.ResponseWriter, r *http.Request) {
+    session := r.FormValue("session")
+    if len(session) == 0 {
+        http.Error(w, "Missing session", http.StatusBadRequest)
+        return
+    }
+    if _, err := startSession(r); err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to start the session", http.StatusInternalServerError)
+        return
+    }
+    assembler := scanAssembler(scanSessionID(r, session))
+    scanSessions.Lock()
+    _, err := assembler.AddString(r.FormValue("code"))
+    stats := assembler.Stats()
+    complete := assembler.Complete()
+    scanSessions.Unlock()
+    status := struct {
+        Error          string  `json:"error,omitempty"`
+        Received       int     `json:"received"`
+        Total          uint64  `json:"total"`
+        BytesPerSecond float64 `json:"bytesPerSecond"`
+        CodesPerSecond float64 `json:"codesPerSecond"`
+        Remaining      float64 `json:"remaining"` // seconds
+        Status         string  `json:"status"`
+        Complete       bool    `json:"complete"`
+    }{Received: stats.Received, Total: stats.Total, BytesPerSecond: stats.BytesPerSecond, CodesPerSecond: stats.ChunksPerSecond,
+        Remaining: stats.Remaining.Seconds(), Status: stats.String(), Complete: complete}
+    if err != nil {
+        status.Error = err.Error()
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(status)
+}
+
+// handleScanResult returns the file restored by a receiver page session as download & ends the session
+func handleScanResult(w http.ResponseWriter, r *http.Request) {
+    session := scanSessionID(r, r.FormValue("session"))
+    scanSessions.Lock()
+    received, ok := scanSessions.sessions[session]
+    delete(scanSessions.sessions, session)
+    scanSessions.Unlock()
+    if !ok {
+        http.Error(w, "Unknown session", http.StatusNotFound)
+        return
+    }
+    elements, err := received.assembler.Elements()
+    if err != nil {
+        http.Error(w, "Unable to restore the file: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    restored := qrFile.New()
+    err = elements.StoreRawData(restored)
+    if err != nil {
+        log.Print(err)
+        http.Error(w, "Unable to restore the file: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/octet-stream")
+    w.Header().Set("Content-Disposition", "attachment; filename=\"result\"")
+    w.Write(restored.Data)
+}
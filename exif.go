@@ -0,0 +1,121 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/binary"
+    "image"
+    "image/draw"
+    "image/jpeg"
+    "io/ioutil"
+)
+
+// Phones store photos as taken by the sensor and record the rotation of the camera in the EXIF orientation tag. The
+// image input layer applies this orientation, so the decoder always sees the code upright.
+
+// exifOrientationTag is the tag number of the orientation in IFD0 of the EXIF data
+const exifOrientationTag = 0x0112
+
+// readJPEGFile reads a JPEG file & applies the EXIF orientation, if any
+func readJPEGFile(fname string) ([]image.Image, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    return decodeJPEG(data)
+}
+
+// decodeJPEG reads JPEG data & applies the EXIF orientation, if any
+func decodeJPEG(data []byte) ([]image.Image, error) {
+    img, err := jpeg.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{applyOrientation(img, jpegOrientation(data))}, nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) stored in a JPEG file; 1 (no transformation) if none is found
+func jpegOrientation(data []byte) int {
+    if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+        return 1
+    }
+    for pos := 2; pos+4 <= len(data); {
+        if data[pos] != 0xFF {
+            return 1
+        }
+        marker := data[pos+1]
+        if marker == 0xD8 || marker >= 0xD0 && marker <= 0xD7 || marker == 0x01 || marker == 0xFF {
+            pos++
+            continue
+        }
+        if marker == 0xDA || marker == 0xD9 {
+            // start of scan data, no metadata follows
+            return 1
+        }
+        length := int(binary.BigEndian.Uint16(data[pos+2:]))
+        if length < 2 || pos+2+length > len(data) {
+            return 1
+        }
+        segment := data[pos+4 : pos+2+length]
+        if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+            return exifOrientation(segment[6:])
+        }
+        pos += 2 + length
+    }
+    return 1
+}
+
+// exifOrientation reads the orientation from EXIF data (a TIFF structure); 1 if not present
+func exifOrientation(data []byte) int {
+    order, err := tiffByteOrder(data)
+    if err != nil {
+        return 1
+    }
+    tags, _, err := readIFD(data, order, order.Uint32(data[4:8]))
+    if err != nil {
+        return 1
+    }
+    if v, ok := tags[exifOrientationTag]; ok && len(v) > 0 && v[0] >= 1 && v[0] <= 8 {
+        return int(v[0])
+    }
+    return 1
+}
+
+// applyOrientation transforms an image stored with the given EXIF/TIFF orientation so that it is displayed upright
+func applyOrientation(img image.Image, orientation int) image.Image {
+    if orientation < 2 || orientation > 8 {
+        return img
+    }
+    bounds := img.Bounds()
+    w, h := bounds.Dx(), bounds.Dy()
+    src := image.NewRGBA(image.Rect(0, 0, w, h))
+    draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+    ow, oh := w, h
+    if orientation >= 5 {
+        // orientations 5-8 swap width & height
+        ow, oh = h, w
+    }
+    out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+    for y := 0; y < oh; y++ {
+        for x := 0; x < ow; x++ {
+            var sx, sy int
+            switch orientation {
+            case 2: // mirrored horizontally
+                sx, sy = w-1-x, y
+            case 3: // rotated by 180 degrees
+                sx, sy = w-1-x, h-1-y
+            case 4: // mirrored vertically
+                sx, sy = x, h-1-y
+            case 5: // mirrored along the top-left/bottom-right diagonal
+                sx, sy = y, x
+            case 6: // needs rotation by 90 degrees clockwise
+                sx, sy = y, h-1-x
+            case 7: // mirrored along the top-right/bottom-left diagonal
+                sx, sy = w-1-y, h-1-x
+            case 8: // needs rotation by 90 degrees counter-clockwise
+                sx, sy = w-1-y, x
+            }
+            copy(out.Pix[y*out.Stride+x*4:y*out.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+        }
+    }
+    return out
+}
@@ -0,0 +1,110 @@
+package qrFile
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "path"
+    "strings"
+)
+
+// Data is read from any reader as well as from files, e.g. a published release artifact is fetched over HTTP & encoded
+// directly into a set for an air-gapped machine, without a copy on disk. The size is limited while reading (like
+// QrFile.MaxSize for files) & the SHA-256 of the data is checked against the published one, so a truncated or tampered
+// download never ends up on paper.
+
+// SourceOptions limits & checks data read by FromReader
+type SourceOptions struct {
+    MaxSize int64        // maximum size in bytes; DefaultMaxFileSize if 0, unlimited if negative
+    SHA256  string       // expected SHA-256 of the data (hex), checked if not empty
+    Client  *http.Client // used by FromURL; http.DefaultClient if nil
+}
+
+// maxSize returns the effective size limit, 0 if there is none
+func (options SourceOptions) maxSize() int64 {
+    return sizeLimit(options.MaxSize)
+}
+
+// FromReader reads the data of a QrFile called name from r, e.g. the body of an HTTP response, limited & checked as
+// given by options. The SHA-256 of the data is returned along with it (hex).
+func FromReader(r io.Reader, name string, options SourceOptions) (*QrFile, string, error) {
+    limit := options.maxSize()
+    data, err := readLimited(r, limit)
+    if err == errTooLarge {
+        return nil, "", sizeError(0, uint64(limit), "%s is too large (more than %d bytes)", name, limit)
+    }
+    if err != nil {
+        return nil, "", err
+    }
+    digest := sha256.Sum256(data)
+    sum := hex.EncodeToString(digest[:])
+    if len(options.SHA256) > 0 && !strings.EqualFold(strings.TrimSpace(options.SHA256), sum) {
+        return nil, sum, ErrChecksumMismatch
+    }
+    return &QrFile{Fname: name, Data: data}, sum, nil
+}
+
+// FromURL fetches the data of a QrFile from an http(s) URL, limited & checked as given by options (see FromReader).
+// The file is named after the last element of the URL path.
+func FromURL(rawURL string, options SourceOptions) (*QrFile, string, error) {
+    source, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, "", err
+    }
+    if source.Scheme != "http" && source.Scheme != "https" {
+        return nil, "", errors.New(fmt.Sprintf("Unsupported URL %s, expected http or https", rawURL))
+    }
+    client := options.Client
+    if client == nil {
+        client = http.DefaultClient
+    }
+    response, err := client.Get(rawURL)
+    if err != nil {
+        return nil, "", err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return nil, "", errors.New(fmt.Sprintf("GET %s failed: %s", rawURL, response.Status))
+    }
+    name := path.Base(source.Path)
+    if name == "/" || name == "." {
+        name = source.Host
+    }
+    // fail before the download if the server announces the size
+    if limit := options.maxSize(); limit > 0 && response.ContentLength > limit {
+        return nil, "", sizeError(uint64(response.ContentLength), uint64(limit), "%s is too large (%d bytes, maximum %d)", name, response.ContentLength, limit)
+    }
+    return FromReader(response.Body, name, options)
+}
+
+// EncodeFromReader reads data from r (see FromReader) & splits it into elements (see GetElementsWithOptions)
+func EncodeFromReader(r io.Reader, name string, source SourceOptions, options EncodeOptions) (*QrElements, error) {
+    qrf, _, err := FromReader(r, name, source)
+    if err != nil {
+        return nil, err
+    }
+    return GetElementsWithOptions(qrf.ToHexString(), options)
+}
+
+// errTooLarge is returned by readLimited if the data exceeds the limit
+var errTooLarge = errors.New("data too large")
+
+// readLimited reads all data from r, failing with errTooLarge after more than limit bytes (unlimited if 0)
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+    if limit <= 0 {
+        return ioutil.ReadAll(r)
+    }
+    data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+    if err != nil {
+        return nil, err
+    }
+    if int64(len(data)) > limit {
+        return nil, errTooLarge
+    }
+    return data, nil
+}
@@ -0,0 +1,141 @@
+package qrFile
+
+import (
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "sort"
+)
+
+// Custom fields carry small values of applications along with a set, e.g. a tag or a routing hint, in a region of the
+// header reserved for them: in the header of elements in plain format (a token "+<hex>" after the header checksum) &
+// in the manifest. They are encoded as TLV (type, length & value, the numbers as unsigned varints), so decoders skip
+// fields of types they do not know & new fields never break decoding of a set. Types below FieldTypeApplication are
+// reserved for future versions of this package; applications use the types from FieldTypeApplication on. Elements in
+// the legacy format have no room for fields.
+
+// FieldTypeApplication is the first type of custom fields available to applications
+const FieldTypeApplication uint64 = 128
+
+// Field types reserved by the package
+const (
+    FieldTypeCRC32      uint64 = 1 // CRC-32 (IEEE) of the payload of the element, 4 bytes big endian (see integrity.go)
+    FieldTypeDataSHA256 uint64 = 2 // SHA-256 of the data of the complete set, in the first element (see integrity.go)
+    FieldTypeFileInfo   uint64 = 3 // name, size, modification time & mode of the original file, in the first element (see FileInfo)
+    FieldTypeSignature  uint64 = 4 // Ed25519 signature of the data of the set, in the first element (see signature.go)
+    FieldTypeTransforms uint64 = 5 // names of the transforms applied to the data, comma separated, in the first element (see AppliedTransforms)
+    FieldTypeParity     uint64 = 6 // number & chunk size of the data elements & size of the data, in every element of a set with parity elements (see parity.go)
+    FieldTypeMetadata   uint64 = 7 // creator, creation time, content type & comment of the set, in the first element (see Metadata)
+)
+
+// fieldsPrefix marks the custom fields in the text of an element in plain format
+const fieldsPrefix = "+"
+
+// maxFieldsSize limits the encoded size of the fields of an element, in bytes
+const maxFieldsSize = 256
+
+// HeaderField is a custom field of an element or a set
+type HeaderField struct {
+    Type  uint64 `json:"type"`
+    Value []byte `json:"value"`
+}
+
+// HeaderFields is a list of custom fields; each type occurs once. Set keeps them ordered by type.
+type HeaderFields []HeaderField
+
+// Get returns the value of the field of the given type; ok is false if there is none
+func (f HeaderFields) Get(fieldType uint64) (value []byte, ok bool) {
+    for _, field := range f {
+        if field.Type == fieldType {
+            return field.Value, true
+        }
+    }
+    return nil, false
+}
+
+// Set sets the value of the field of the given type, replacing a field of the same type
+func (f *HeaderFields) Set(fieldType uint64, value []byte) {
+    for i, field := range *f {
+        if field.Type == fieldType {
+            (*f)[i].Value = value
+            return
+        }
+    }
+    *f = append(*f, HeaderField{Type: fieldType, Value: value})
+    sort.Slice(*f, func(i, j int) bool { return (*f)[i].Type < (*f)[j].Type })
+}
+
+// Delete removes the field of the given type, if any
+func (f *HeaderFields) Delete(fieldType uint64) {
+    for i, field := range *f {
+        if field.Type == fieldType {
+            *f = append((*f)[:i], (*f)[i+1:]...)
+            return
+        }
+    }
+}
+
+// Marshal returns the fields in TLV encoding
+func (f HeaderFields) Marshal() []byte {
+    data := make([]byte, 0)
+    number := make([]byte, binary.MaxVarintLen64)
+    for _, field := range f {
+        data = append(data, number[:binary.PutUvarint(number, field.Type)]...)
+        data = append(data, number[:binary.PutUvarint(number, uint64(len(field.Value)))]...)
+        data = append(data, field.Value...)
+    }
+    return data
+}
+
+// ParseHeaderFields decodes fields in TLV encoding (see HeaderFields.Marshal); their order is kept, so they are encoded
+// exactly as before
+func ParseHeaderFields(data []byte) (HeaderFields, error) {
+    fields := make(HeaderFields, 0)
+    for len(data) > 0 {
+        fieldType, n := binary.Uvarint(data)
+        if n <= 0 {
+            return nil, errors.New("Malformed field type")
+        }
+        data = data[n:]
+        length, n := binary.Uvarint(data)
+        if n <= 0 || length > uint64(len(data)-n) {
+            return nil, errors.New(fmt.Sprintf("Malformed length of field %d", fieldType))
+        }
+        data = data[n:]
+        if _, ok := fields.Get(fieldType); ok {
+            return nil, errors.New(fmt.Sprintf("Field %d occurs twice", fieldType))
+        }
+        fields = append(fields, HeaderField{Type: fieldType, Value: append([]byte(nil), data[:length]...)})
+        data = data[length:]
+    }
+    return fields, nil
+}
+
+// token returns the token holding the fields in the text of an element in plain format, "" if there are none
+func (f HeaderFields) token() string {
+    if len(f) == 0 {
+        return ""
+    }
+    return fieldsPrefix + hex.EncodeToString(f.Marshal())
+}
+
+// parseFieldsToken decodes the token holding the fields in the text of an element in plain format
+func parseFieldsToken(token string) (HeaderFields, error) {
+    data, err := hex.DecodeString(token[len(fieldsPrefix):])
+    if err != nil {
+        return nil, err
+    }
+    if len(data) > maxFieldsSize {
+        return nil, sizeError(uint64(len(data)), maxFieldsSize, "%d bytes exceed the maximum of %d", len(data), maxFieldsSize)
+    }
+    return ParseHeaderFields(data)
+}
+
+// SetElementFields sets a field in the header of every element, e.g. a tag every single code carries (plain format
+// only). The field takes room in every code, which is not accounted for by the chunk size; keep it short.
+func (elem *QrElements) SetElementFields(fieldType uint64, value []byte) {
+    for i := range elem.Elements {
+        elem.Elements[i].Fields.Set(fieldType, value)
+    }
+}
@@ -0,0 +1,139 @@
+package qrFile
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// A set can describe the file it was created from (see EncodeOptions.File), so the file is restored under its original
+// name, with its mode & modification time, without the name being given again: the description is recorded in the
+// manifest &, in plain format, in the header of the first element (a field of type FieldTypeFileInfo), so it survives
+// without the manifest. StoreData picks it up for a QrFile without name & ToFile applies mode & modification time.
+
+// maxFileNameLength limits the length of a file name recorded in the header of an element, in bytes
+const maxFileNameLength = 160
+
+// FileInfo describes the original file of a set
+type FileInfo struct {
+    Name    string      `json:"name"`            // base name of the file, without directory
+    Size    uint64      `json:"size"`            // size of the file in bytes, before any transform
+    ModTime time.Time   `json:"mtime,omitempty"` // modification time, to the second
+    Mode    os.FileMode `json:"mode,omitempty"`  // permission bits
+}
+
+// marshal returns the value of the header field describing the file: size, modification time (seconds since 1970,
+// signed) & mode as varints, followed by the name
+func (info *FileInfo) marshal() []byte {
+    data := make([]byte, 0, 3*binary.MaxVarintLen64+len(info.Name))
+    var buf [binary.MaxVarintLen64]byte
+    data = append(data, buf[:binary.PutUvarint(buf[:], info.Size)]...)
+    var seconds int64
+    if !info.ModTime.IsZero() {
+        seconds = info.ModTime.Unix()
+    }
+    data = append(data, buf[:binary.PutVarint(buf[:], seconds)]...)
+    data = append(data, buf[:binary.PutUvarint(buf[:], uint64(info.Mode.Perm()))]...)
+    return append(data, info.Name...)
+}
+
+// parseFileInfo decodes the value of the header field describing the file (see FileInfo.marshal)
+func parseFileInfo(data []byte) (*FileInfo, error) {
+    info := new(FileInfo)
+    size, n := binary.Uvarint(data)
+    if n <= 0 {
+        return nil, errors.New("Malformed file size")
+    }
+    data = data[n:]
+    seconds, n := binary.Varint(data)
+    if n <= 0 {
+        return nil, errors.New("Malformed modification time")
+    }
+    data = data[n:]
+    mode, n := binary.Uvarint(data)
+    if n <= 0 || mode > uint64(os.ModePerm) {
+        return nil, errors.New("Malformed file mode")
+    }
+    info.Size, info.Mode = size, os.FileMode(mode)
+    if seconds != 0 {
+        info.ModTime = time.Unix(seconds, 0)
+    }
+    name, err := cleanFileName(string(data[n:]))
+    if err != nil {
+        return nil, err
+    }
+    info.Name = name
+    return info, nil
+}
+
+// cleanFileName checks a file name recorded in a set, which is untrusted input: it has to be a plain name of printable
+// characters, without directory, which names a file in the output directory on this platform (not e.g. NUL or a:b on
+// Windows)
+func cleanFileName(name string) (string, error) {
+    if len(name) == 0 || len(name) > maxFileNameLength || name == "." || name == ".." || strings.ContainsAny(name, "/\\") || !filepath.IsLocal(name) {
+        return "", errors.New(fmt.Sprintf("Invalid file name %q", name))
+    }
+    for _, c := range name {
+        if c < ' ' || c == 0x7f {
+            return "", errors.New(fmt.Sprintf("Invalid file name %q", name))
+        }
+    }
+    return name, nil
+}
+
+// Info describes the file of the QrFile, for EncodeOptions.File; its name is the base name of Fname
+func (qrf *QrFile) Info() *FileInfo {
+    return &FileInfo{Name: filepath.Base(qrf.Fname), Size: uint64(len(qrf.Data)), ModTime: qrf.ModTime.Truncate(time.Second), Mode: qrf.Mode.Perm()}
+}
+
+// setFileInfo records the file in the set & in the header of the first element (plain format only)
+func (elem *QrElements) setFileInfo(info *FileInfo) error {
+    if _, err := cleanFileName(info.Name); err != nil {
+        return err
+    }
+    elem.File = info
+    for i := range elem.Elements {
+        if v := &elem.Elements[i]; v.Index == 0 && v.Version == VersionPlain {
+            v.Fields.Set(FieldTypeFileInfo, info.marshal())
+        }
+    }
+    return nil
+}
+
+// FileInfo returns the description of the original file of the set: File if set, otherwise the one in the header of
+// the first element; nil if there is none. File is usually taken from a manifest, which is untrusted input like the
+// header: a description whose name is not a plain file name (see cleanFileName) is ignored.
+func (elem *QrElements) FileInfo() *FileInfo {
+    if elem.File != nil {
+        if _, err := cleanFileName(elem.File.Name); err != nil {
+            elem.logf(slog.LevelWarn, "Ignoring the file description of the set: %s", err)
+            return nil
+        }
+        return elem.File
+    }
+    for _, v := range elem.Elements {
+        if value, ok := v.Fields.Get(FieldTypeFileInfo); ok && v.Index == 0 {
+            info, err := parseFileInfo(value)
+            if err != nil {
+                elem.trace("ignoring the file description of the set: %s", err)
+                return nil
+            }
+            return info
+        }
+    }
+    return nil
+}
+
+// fileInfoReserve returns the amount of characters the description of the file takes in the header of the first
+// element: the space before the token of the fields, its prefix & the field hex encoded
+func fileInfoReserve(info *FileInfo) uint64 {
+    if info == nil {
+        return 0
+    }
+    return 1 + uint64(len(fieldsPrefix)) + 2*uint64(3+len(info.marshal()))
+}
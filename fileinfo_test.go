@@ -0,0 +1,86 @@
+package qrFile
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/json"
+    "io"
+    "testing"
+)
+
+// traversalNames are file names a hostile set may record to write outside the output directory
+var traversalNames = []string{"../../.bashrc", "..", "/etc/passwd", "dir/file", "dir\\file", "C:\\file", ""}
+
+func TestFileInfoTraversal(t *testing.T) {
+    set, err := GetElementsWithOptions("00112233445566778899", EncodeOptions{Version: VersionPlain})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range traversalNames {
+        set.File = &FileInfo{Name: name, Size: 10}
+        if info := set.FileInfo(); info != nil {
+            t.Errorf("%q: file description accepted", name)
+        }
+        restored := New()
+        if err := set.StoreData(restored); err != nil {
+            t.Fatal(err)
+        }
+        if len(restored.Fname) > 0 {
+            t.Errorf("%q: restored as %q", name, restored.Fname)
+        }
+        if err := set.setFileInfo(&FileInfo{Name: name}); err == nil {
+            t.Errorf("%q: recorded in the header", name)
+        }
+        container := tamperedContainer(t, set, name)
+        if _, err := Unpack(bytes.NewReader(container), int64(len(container))); err == nil {
+            t.Errorf("%q: container accepted", name)
+        }
+    }
+    set.File = &FileInfo{Name: "report.pdf", Size: 10}
+    if info := set.FileInfo(); info == nil || info.Name != "report.pdf" {
+        t.Fatal(info)
+    }
+}
+
+// tamperedContainer returns a container of set whose manifest records the file name
+func tamperedContainer(t *testing.T, set *QrElements, name string) []byte {
+    set.File = nil
+    var packed bytes.Buffer
+    if err := set.Pack(&packed, false); err != nil {
+        t.Fatal(err)
+    }
+    archive, err := zip.NewReader(bytes.NewReader(packed.Bytes()), int64(packed.Len()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tampered bytes.Buffer
+    out := zip.NewWriter(&tampered)
+    for _, f := range archive.File {
+        in, err := f.Open()
+        if err != nil {
+            t.Fatal(err)
+        }
+        data, err := io.ReadAll(in)
+        in.Close()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if f.Name == containerManifest {
+            manifest := new(Manifest)
+            if err := json.Unmarshal(data, manifest); err != nil {
+                t.Fatal(err)
+            }
+            manifest.File = &FileInfo{Name: name, Size: 10}
+            data, _ = json.Marshal(manifest)
+        }
+        w, err := out.Create(f.Name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        w.Write(data)
+    }
+    if err := out.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return tampered.Bytes()
+}
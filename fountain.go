@@ -0,0 +1,444 @@
+package qrFile
+
+import (
+    "encoding/base64"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "image/gif"
+    "io"
+    "math"
+    "os"
+    "sort"
+    "strings"
+)
+
+// Fountain frames (an LT code) suit screen-to-camera transfer better than the elements of a set: instead of showing
+// a fixed sequence of elements until the receiver happened to capture each one, the sender emits new frames as long as
+// needed, each combining some blocks of the data (XOR), & the receiver restores the data from any frames, slightly
+// more than there are blocks, regardless of order & of which frames it missed. The text of a frame is fountainPrefix
+// followed by a binary record, base64 encoded (URL-safe alphabet, no padding):
+//
+//     uvarint seed | uvarint block count | uvarint data size (bytes) | CRC-32 of the data (4 bytes) | block
+//
+// The seed selects the blocks combined in the frame (see fountainBlocks); the first frames (seeds below the block
+// count) hold a single block each, so a receiver capturing all of them needs no decoding at all. The CRC-32 tells
+// frames of different data apart & verifies the restored data.
+
+// fountainPrefix starts the text of every fountain frame
+const fountainPrefix = "QRF LT:"
+
+// DefaultFountainBlockSize is the size of the blocks of fountain frames in bytes; the frames are smaller codes than
+// the ones of the legacy format, so they are captured from a screen more easily
+const DefaultFountainBlockSize = 512
+
+// fountainEncoding encodes the binary record of a fountain frame
+var fountainEncoding = base64.RawURLEncoding
+
+// IsFountainText reports whether text (e.g. the text of a scanned code) is a fountain frame
+func IsFountainText(text string) bool {
+    return strings.HasPrefix(strings.TrimSpace(text), fountainPrefix)
+}
+
+// fountainRandom is the pseudo random generator selecting the blocks of a frame (splitmix64), so sender & receiver
+// agree on them independent of the platform
+type fountainRandom uint64
+
+// next returns the next pseudo random number
+func (r *fountainRandom) next() uint64 {
+    *r += 0x9e3779b97f4a7c15
+    z := uint64(*r)
+    z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+    z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+    return z ^ (z >> 31)
+}
+
+// float returns the next pseudo random number in [0, 1)
+func (r *fountainRandom) float() float64 {
+    return float64(r.next()>>11) / (1 << 53)
+}
+
+// fountainDegrees returns the cumulative robust soliton distribution of the number of blocks combined in a frame for
+// count blocks (c = 0.1, delta = 0.5); entry d-1 is the probability of at most d blocks
+func fountainDegrees(count int) []float64 {
+    const c, delta = 0.1, 0.5
+    k := float64(count)
+    r := c * math.Log(k/delta) * math.Sqrt(k)
+    spike := count
+    if r > 0 {
+        spike = int(math.Round(k / r))
+    }
+    if spike < 1 || spike > count {
+        spike = count
+    }
+    weights := make([]float64, count)
+    total := 0.0
+    for d := 1; d <= count; d++ {
+        w := 1 / (float64(d) * float64(d-1))
+        if d == 1 {
+            w = 1 / k
+        }
+        if d < spike {
+            w += r / (float64(d) * k)
+        } else if d == spike && r > 0 {
+            w += r * math.Log(r/delta) / k
+        }
+        weights[d-1] = w
+        total += w
+    }
+    cumulative := 0.0
+    for i, w := range weights {
+        cumulative += w / total
+        weights[i] = cumulative
+    }
+    return weights
+}
+
+// fountainBlocks returns the blocks combined in the frame with the given seed, in ascending order
+func fountainBlocks(seed uint64, count int, degrees []float64) []int {
+    if seed < uint64(count) {
+        return []int{int(seed)}
+    }
+    random := fountainRandom(seed)
+    p := random.float()
+    degree := sort.SearchFloat64s(degrees, p) + 1
+    if degree > count {
+        degree = count
+    }
+    chosen := make(map[int]bool, degree)
+    blocks := make([]int, 0, degree)
+    for len(blocks) < degree {
+        block := int(random.next() % uint64(count))
+        if !chosen[block] {
+            chosen[block] = true
+            blocks = append(blocks, block)
+        }
+    }
+    sort.Ints(blocks)
+    return blocks
+}
+
+// xorInto combines src into dst
+func xorInto(dst []byte, src []byte) {
+    for i := range src {
+        dst[i] ^= src[i]
+    }
+}
+
+// FountainEncoder creates the fountain frames of some data
+type FountainEncoder struct {
+    Level   Level         // error correction level of the frames rendered
+    Encoder SymbolEncoder // renders the frames; DefaultEncoder if nil
+    blocks  [][]byte
+    size    uint64
+    sum     uint32
+    degrees []float64
+}
+
+// NewFountainEncoder splits the data into blocks of (at most) blockSize bytes for fountain frames
+func NewFountainEncoder(data []byte, blockSize int) (*FountainEncoder, error) {
+    if blockSize <= 0 {
+        return nil, errors.New(fmt.Sprintf("Invalid block size %d", blockSize))
+    }
+    count := (len(data) + blockSize - 1) / blockSize
+    if count == 0 {
+        count = 1
+    }
+    // blocks of equal size; the last one is padded with zeros
+    size := (len(data) + count - 1) / count
+    e := &FountainEncoder{blocks: make([][]byte, count), size: uint64(len(data)), sum: crc32.ChecksumIEEE(data), degrees: fountainDegrees(count)}
+    for i := range e.blocks {
+        e.blocks[i] = make([]byte, size)
+        if start := i * size; start < len(data) {
+            copy(e.blocks[i], data[start:])
+        }
+    }
+    return e, nil
+}
+
+// Blocks returns the number of blocks; a receiver needs slightly more frames than that
+func (e *FountainEncoder) Blocks() int {
+    return len(e.blocks)
+}
+
+// Frame returns the text of the frame with the given seed; frames are usually emitted with consecutive seeds from 0 on
+func (e *FountainEncoder) Frame(seed uint64) string {
+    block := make([]byte, len(e.blocks[0]))
+    for _, i := range fountainBlocks(seed, len(e.blocks), e.degrees) {
+        xorInto(block, e.blocks[i])
+    }
+    record := make([]byte, 0, 3*binary.MaxVarintLen64+crc32.Size+len(block))
+    var buf [binary.MaxVarintLen64]byte
+    for _, value := range []uint64{seed, uint64(len(e.blocks)), e.size} {
+        record = append(record, buf[:binary.PutUvarint(buf[:], value)]...)
+    }
+    binary.BigEndian.PutUint32(buf[:], e.sum)
+    record = append(record, buf[:crc32.Size]...)
+    return fountainPrefix + fountainEncoding.EncodeToString(append(record, block...))
+}
+
+// WriteGIF renders count frames (seeds 0 to count-1) as a looping animated GIF, with the frame delay selected in
+// options (interleaving & repetition do not apply, every frame is new)
+func (e *FountainEncoder) WriteGIF(w io.Writer, count int, options StreamOptions) error {
+    if count <= 0 {
+        return errors.New("No frames to write.")
+    }
+    encoder := e.Encoder
+    if encoder == nil {
+        encoder = DefaultEncoder
+    }
+    delay := options.resolve(count).FrameDelay
+    animation := new(gif.GIF)
+    for seed := 0; seed < count; seed++ {
+        img, err := encoder.Encode(e.Frame(uint64(seed)), e.Level)
+        if err != nil {
+            return err
+        }
+        frame := bilevelPaletted(img)
+        animation.Image = append(animation.Image, frame)
+        animation.Delay = append(animation.Delay, delay)
+        animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+        animation.Config.Width = maxInt(animation.Config.Width, frame.Rect.Dx())
+        animation.Config.Height = maxInt(animation.Config.Height, frame.Rect.Dy())
+    }
+    animation.Config.ColorModel = bilevelPalette
+    return gif.EncodeAll(w, animation)
+}
+
+// WriteGIFFile writes count frames to the animated GIF file fname, see WriteGIF
+func (e *FountainEncoder) WriteGIFFile(fname string, count int, options StreamOptions) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = e.WriteGIF(file, count, options)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// maxInt returns the larger of two numbers
+func maxInt(a int, b int) int {
+    if a > b {
+        return a
+    }
+    return b
+}
+
+// fountainFrame is a frame whose blocks are not all known yet
+type fountainFrame struct {
+    blocks []int
+    data   []byte
+}
+
+// FountainDecoder restores data from fountain frames received in any order (see FountainEncoder)
+type FountainDecoder struct {
+    // MaxSize limits the size of the data announced by the frames (in bytes); DefaultMaxFileSize if 0, unlimited if
+    // negative
+    MaxSize  int64
+    blocks   [][]byte // blocks restored so far, nil if unknown
+    known    int
+    size     uint64
+    sum      uint32
+    degrees  []float64
+    pending  []*fountainFrame
+    seen     map[uint64]bool
+    received int
+}
+
+// NewFountainDecoder creates a decoder for the frames of a single transfer
+func NewFountainDecoder() *FountainDecoder {
+    return &FountainDecoder{seen: make(map[uint64]bool)}
+}
+
+// AddString adds the text of a frame; the result is false if the frame was received before
+func (d *FountainDecoder) AddString(text string) (bool, error) {
+    record, err := fountainEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(text), fountainPrefix))
+    if err != nil || !IsFountainText(text) {
+        return false, errors.New("Not a fountain frame")
+    }
+    var values [3]uint64
+    for i := range values {
+        value, n := binary.Uvarint(record)
+        if n <= 0 {
+            return false, errors.New("Malformed fountain frame")
+        }
+        values[i], record = value, record[n:]
+    }
+    seed, count, size := values[0], values[1], values[2]
+    if len(record) < crc32.Size || count == 0 || count > size+1 || (sizeLimit(d.MaxSize) > 0 && size > uint64(sizeLimit(d.MaxSize))) {
+        return false, errors.New("Malformed fountain frame")
+    }
+    sum, block := binary.BigEndian.Uint32(record), record[crc32.Size:]
+    if uint64(len(block)) != (size+count-1)/count {
+        return false, errors.New(fmt.Sprintf("Malformed fountain frame: block of %d bytes, expected %d", len(block), (size+count-1)/count))
+    }
+    if d.blocks == nil {
+        d.blocks, d.size, d.sum, d.degrees = make([][]byte, count), size, sum, fountainDegrees(int(count))
+    } else if uint64(len(d.blocks)) != count || d.size != size || d.sum != sum {
+        return false, errors.New("The frame belongs to another transfer")
+    }
+    if d.seen[seed] {
+        return false, nil
+    }
+    d.seen[seed] = true
+    d.received++
+    d.add(&fountainFrame{blocks: fountainBlocks(seed, len(d.blocks), d.degrees), data: block})
+    if !d.Complete() {
+        d.solve()
+    }
+    return true, nil
+}
+
+// add reduces a frame by the known blocks & restores the blocks which become known (peeling decoder)
+func (d *FountainDecoder) add(frame *fountainFrame) {
+    queue := []*fountainFrame{frame}
+    for len(queue) > 0 {
+        frame, queue = queue[0], queue[1:]
+        unknown := frame.blocks[:0]
+        for _, i := range frame.blocks {
+            if d.blocks[i] != nil {
+                xorInto(frame.data, d.blocks[i])
+            } else {
+                unknown = append(unknown, i)
+            }
+        }
+        frame.blocks = unknown
+        if len(frame.blocks) > 1 {
+            d.pending = append(d.pending, frame)
+            continue
+        }
+        if len(frame.blocks) == 0 {
+            continue
+        }
+        d.blocks[frame.blocks[0]] = frame.data
+        d.known++
+        // frames waiting for this block may be reduced to a single block now
+        remaining := d.pending[:0]
+        for _, p := range d.pending {
+            if containsInt(p.blocks, frame.blocks[0]) {
+                queue = append(queue, p)
+            } else {
+                remaining = append(remaining, p)
+            }
+        }
+        d.pending = remaining
+    }
+}
+
+// solve restores the blocks the peeling decoder is stuck on by Gaussian elimination over the waiting frames: once there
+// are at least as many frames as unknown blocks, the frames usually determine them although none can be peeled
+func (d *FountainDecoder) solve() {
+    columns := make(map[int]int, len(d.blocks)-d.known)
+    var unknown []int
+    for i, block := range d.blocks {
+        if block == nil {
+            columns[i] = len(unknown)
+            unknown = append(unknown, i)
+        }
+    }
+    if len(unknown) == 0 || len(d.pending) < len(unknown) {
+        return
+    }
+    words := (len(unknown) + 63) / 64
+    rows := make([][]uint64, len(d.pending))
+    data := make([][]byte, len(d.pending))
+    for r, p := range d.pending {
+        rows[r] = make([]uint64, words)
+        for _, i := range p.blocks {
+            rows[r][columns[i]/64] |= 1 << uint(columns[i]%64)
+        }
+        data[r] = append([]byte(nil), p.data...)
+    }
+    rank := 0
+    pivots := make([]int, 0, len(unknown))
+    for col := range unknown {
+        word, bit := col/64, uint64(1)<<uint(col%64)
+        pivot := rank
+        for pivot < len(rows) && rows[pivot][word]&bit == 0 {
+            pivot++
+        }
+        if pivot == len(rows) {
+            continue
+        }
+        rows[rank], rows[pivot] = rows[pivot], rows[rank]
+        data[rank], data[pivot] = data[pivot], data[rank]
+        for r := range rows {
+            if r != rank && rows[r][word]&bit != 0 {
+                for w := range rows[r] {
+                    rows[r][w] ^= rows[rank][w]
+                }
+                xorInto(data[r], data[rank])
+            }
+        }
+        pivots = append(pivots, col)
+        rank++
+    }
+    // a reduced row with a single block left restores it, even if the frames do not determine all blocks yet
+    solved := false
+    for r, col := range pivots {
+        single := true
+        for w, bits := range rows[r] {
+            if w == col/64 {
+                bits &^= 1 << uint(col%64)
+            }
+            if bits != 0 {
+                single = false
+                break
+            }
+        }
+        if single {
+            d.blocks[unknown[col]] = data[r]
+            d.known++
+            solved = true
+        }
+    }
+    if solved {
+        pending := d.pending
+        d.pending = nil
+        for _, p := range pending {
+            d.add(p)
+        }
+    }
+}
+
+// containsInt reports whether list contains value
+func containsInt(list []int, value int) bool {
+    for _, v := range list {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// Complete reports whether all blocks of the data are known
+func (d *FountainDecoder) Complete() bool {
+    return d.blocks != nil && d.known == len(d.blocks)
+}
+
+// Progress returns the number of blocks restored, the number of blocks of the data & the number of distinct frames
+// received
+func (d *FountainDecoder) Progress() (known int, total int, received int) {
+    return d.known, len(d.blocks), d.received
+}
+
+// Data returns the restored data, verified against the CRC-32 of the frames
+func (d *FountainDecoder) Data() ([]byte, error) {
+    if !d.Complete() {
+        known, total, received := d.Progress()
+        return nil, errors.New(fmt.Sprintf("Incomplete transfer: %d of %d blocks restored from %d frames.", known, total, received))
+    }
+    data := make([]byte, 0, len(d.blocks)*len(d.blocks[0]))
+    for _, block := range d.blocks {
+        data = append(data, block...)
+    }
+    data = data[:d.size]
+    if crc32.ChecksumIEEE(data) != d.sum {
+        return nil, checksumError("The restored data is corrupted: the CRC-32 does not match the frames")
+    }
+    return data, nil
+}
@@ -0,0 +1,105 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/binary"
+    "hash/crc32"
+    "math/rand"
+    "testing"
+)
+
+// TestFountainLossyShuffled restores data from the frames of a transfer received in random order with a third of
+// them lost, the systematic frames included
+func TestFountainLossyShuffled(t *testing.T) {
+    rng := rand.New(rand.NewSource(7))
+    for _, size := range []int{1, 100, 5000, 60000} {
+        data := make([]byte, size)
+        rng.Read(data)
+        encoder, err := NewFountainEncoder(data, 256)
+        if err != nil {
+            t.Fatal(err)
+        }
+        frames := make([]string, 0, 4*encoder.Blocks()+20)
+        for seed := uint64(0); seed < uint64(cap(frames)); seed++ {
+            if rng.Float64() >= 1.0/3 {
+                frames = append(frames, encoder.Frame(seed))
+            }
+        }
+        rng.Shuffle(len(frames), func(i, j int) { frames[i], frames[j] = frames[j], frames[i] })
+        decoder := NewFountainDecoder()
+        for _, frame := range frames {
+            if decoder.Complete() {
+                break
+            }
+            if _, err := decoder.AddString(frame); err != nil {
+                t.Fatal(err)
+            }
+        }
+        if !decoder.Complete() {
+            known, total, received := decoder.Progress()
+            t.Fatalf("%d bytes: %d of %d blocks known after %d frames", size, known, total, received)
+        }
+        restored, err := decoder.Data()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !bytes.Equal(restored, data) {
+            t.Fatalf("%d bytes restored differently", size)
+        }
+    }
+}
+
+// fountainFrameText builds the text of a fountain frame from its fields, which need not be consistent
+func fountainFrameText(seed uint64, count uint64, size uint64, sum uint32, block []byte) string {
+    record := make([]byte, 3*binary.MaxVarintLen64+crc32.Size+len(block))
+    n := binary.PutUvarint(record, seed)
+    n += binary.PutUvarint(record[n:], count)
+    n += binary.PutUvarint(record[n:], size)
+    binary.BigEndian.PutUint32(record[n:], sum)
+    n += crc32.Size
+    n += copy(record[n:], block)
+    return fountainPrefix + fountainEncoding.EncodeToString(record[:n])
+}
+
+// TestFountainMalformedFrames rejects malformed frames with an error instead of a panic, & frames of another transfer
+func TestFountainMalformedFrames(t *testing.T) {
+    data := []byte("hello fountain")
+    sum := crc32.ChecksumIEEE(data)
+    cases := []struct {
+        name string
+        text string
+    }{
+        {"no prefix", "QRF 1/2 hello"},
+        {"invalid base64", fountainPrefix + "!!!"},
+        {"empty record", fountainPrefix},
+        {"truncated varint", fountainPrefix + fountainEncoding.EncodeToString([]byte{0x80})},
+        {"missing checksum", fountainPrefix + fountainEncoding.EncodeToString([]byte{0, 1, 14, 0})},
+        {"no blocks", fountainFrameText(0, 0, 14, sum, nil)},
+        {"more blocks than bytes", fountainFrameText(0, 100, 14, sum, nil)},
+        {"short block", fountainFrameText(0, 2, 14, sum, data[:3])},
+        {"long block", fountainFrameText(0, 1, 14, sum, append(data, 0))},
+        {"huge size", fountainFrameText(0, 1, 1<<62, sum, data)},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            if _, err := NewFountainDecoder().AddString(c.text); err == nil {
+                t.Fatalf("%q accepted", c.text)
+            }
+        })
+    }
+    encoder, err := NewFountainEncoder(data, 4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    other, err := NewFountainEncoder([]byte("other fountain"), 4)
+    if err != nil {
+        t.Fatal(err)
+    }
+    decoder := NewFountainDecoder()
+    if _, err := decoder.AddString(encoder.Frame(0)); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := decoder.AddString(other.Frame(1)); err == nil {
+        t.Fatal("frame of another transfer accepted")
+    }
+}
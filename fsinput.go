@@ -0,0 +1,154 @@
+package qrFile
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "io/ioutil"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// Sets are not only read from local directories: any io/fs.FS serves as input for FromFS, e.g. assets embedded with
+// go:embed, a zip archive (zip.Reader, as written by WriteZip), os.DirFS or the file system of a cloud storage client.
+// The decoders & most image formats work on files (zbarimg, heif-convert, ffmpeg), so the files are copied to a
+// temporary directory first & read like FromPNGs does; the report names them by their path in the FS. The writable
+// counterpart is Storage (see storage.go), which WritePNGsTo writes to; a DirStorage is an FS as well, so a set written
+// to it is read back with FromFS.
+
+// FromFS reads the elements of the files of fsys matching the patterns (see fs.Glob; "." stands for all files at the
+// root) & checks them like FromPNGs. Directories stand for all files they contain; files are named by their path in
+// fsys in the Report.
+func (elem *QrElements) FromFS(fsys fs.FS, patterns ...string) error {
+    return elem.FromFSContext(context.Background(), fsys, patterns...)
+}
+
+// FromFSContext works like FromFS, but can be aborted (see FromPNGsContext)
+func (elem *QrElements) FromFSContext(ctx context.Context, fsys fs.FS, patterns ...string) error {
+    names, err := expandFS(fsys, patterns)
+    if err != nil {
+        return err
+    }
+    dir, err := ioutil.TempDir(os.TempDir(), "qrFileFS")
+    if err != nil {
+        return err
+    }
+    defer os.RemoveAll(dir)
+    files := make([]string, 0, len(names))
+    for _, name := range names {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        fname := filepath.Join(dir, filepath.FromSlash(name))
+        err = copyFromFS(fsys, name, fname, sizeLimit(elem.MaxFileSize))
+        if err != nil {
+            return err
+        }
+        files = append(files, fname)
+    }
+    known := len(elem.Elements)
+    err = elem.readFiles(ctx, files)
+    elem.renameSources(known, dir+string(filepath.Separator))
+    if err != nil {
+        return err
+    }
+    return elem.Validate()
+}
+
+// expandFS returns the names of the files of fsys the patterns stand for (see FromFS), in lexical order per pattern
+func expandFS(fsys fs.FS, patterns []string) ([]string, error) {
+    if len(patterns) == 0 {
+        patterns = []string{"."}
+    }
+    names := make([]string, 0)
+    for _, pattern := range patterns {
+        matches, err := fs.Glob(fsys, pattern)
+        if err != nil {
+            return nil, err
+        }
+        for _, name := range matches {
+            info, err := fs.Stat(fsys, name)
+            if err != nil {
+                return nil, err
+            }
+            if !info.IsDir() {
+                names = append(names, name)
+                continue
+            }
+            // directories stand for all files they contain
+            entries, err := fs.ReadDir(fsys, name)
+            if err != nil {
+                return nil, err
+            }
+            for _, entry := range entries {
+                if !entry.IsDir() && entry.Name() != LockName {
+                    names = append(names, path.Join(name, entry.Name()))
+                }
+            }
+        }
+    }
+    if len(names) == 0 {
+        return nil, errors.New(fmt.Sprintf("No files found for input %s", strings.Join(patterns, ", ")))
+    }
+    return names, nil
+}
+
+// copyFromFS copies the file name of fsys to the local file fname; files larger than limit (unlimited if 0) are cut
+// after one more byte, so they are still refused as too large when they are read
+func copyFromFS(fsys fs.FS, name string, fname string, limit int64) error {
+    in, err := fsys.Open(name)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    err = os.MkdirAll(filepath.Dir(fname), 0700)
+    if err != nil {
+        return err
+    }
+    out, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    var r io.Reader = in
+    if limit > 0 {
+        r = io.LimitReader(in, limit+1)
+    }
+    _, err = io.Copy(out, r)
+    if closeErr := out.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return fmt.Errorf("Unable to copy %s: %w", name, err)
+    }
+    return nil
+}
+
+// renameSources names the files the elements from position known on were read from by their path in an FS instead of
+// the temporary copy below prefix, in the elements & the Report
+func (elem *QrElements) renameSources(known int, prefix string) {
+    rename := func(fname string) string {
+        if strings.HasPrefix(fname, prefix) {
+            return filepath.ToSlash(strings.TrimPrefix(fname, prefix))
+        }
+        return fname
+    }
+    for i := known; i < len(elem.Elements); i++ {
+        elem.Elements[i].source = rename(elem.Elements[i].source)
+    }
+    if elem.Report == nil {
+        return
+    }
+    for i, symbol := range elem.Report.Symbols {
+        elem.Report.Symbols[i].File = rename(symbol.File)
+    }
+    for i, failure := range elem.Report.Failures {
+        elem.Report.Failures[i].File = rename(failure.File)
+        if _, timeout := failure.Err.(*TimeoutError); !timeout && strings.Contains(failure.Err.Error(), prefix) {
+            elem.Report.Failures[i].Err = errors.New(strings.Replace(failure.Err.Error(), prefix, "", -1))
+        }
+    }
+}
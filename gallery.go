@@ -0,0 +1,87 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "html"
+    "io"
+    "os"
+)
+
+// An HTML gallery holds all codes of a set in a single self-contained page: the images are embedded as data URIs, so
+// the set is archived, viewed or printed from one file with any browser. The print style sheet arranges the codes like
+// the PDF sheets (see WritePDF), a grid of SheetOptions.Columns by Rows codes per page with the number & the caption
+// below each code.
+
+// WriteHTML renders all elements (see Render) & writes them as a self-contained HTML page, arranged for printing in a
+// grid of options.Columns by options.Rows codes per page. The images are the ones written by WritePNGs; below each
+// code, its number & count & the caption are printed, unless the image has a caption of its own (see Caption).
+func (elem *QrElements) WriteHTML(w io.Writer, options SheetOptions) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to write.")
+    }
+    options = options.resolve()
+    options.Caption = elem.sheetCaption(options.Caption)
+    _, _, codeSize, err := options.grid()
+    if err != nil {
+        return err
+    }
+    images, err := elem.RenderPNGs()
+    if err != nil {
+        return err
+    }
+    title := html.EscapeString(options.Caption)
+    if len(title) == 0 {
+        title = "QR codes"
+    }
+    perPage := options.Columns * options.Rows
+    pages := (elem.Len() + perPage - 1) / perPage
+    var page bytes.Buffer
+    fmt.Fprintf(&page, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n", title)
+    fmt.Fprintf(&page, "@page { size: %dpt %dpt; margin: %dpt; }\n", options.Page.Width, options.Page.Height, sheetMargin)
+    page.WriteString("body { font-family: sans-serif; margin: 0; }\n" +
+        "header { display: flex; justify-content: space-between; font-size: 10pt; margin-bottom: 10pt; }\n" +
+        "header .status { font-family: monospace; }\n" +
+        "section { break-after: page; margin-bottom: 36pt; }\nsection:last-child { break-after: auto; }\n")
+    fmt.Fprintf(&page, ".grid { display: grid; grid-template-columns: repeat(%d, 1fr); gap: %dpt; }\n", options.Columns, sheetGap)
+    fmt.Fprintf(&page, "figure { margin: 0; text-align: center; break-inside: avoid; }\nimg { width: %.0fpt; max-width: 100%%; image-rendering: pixelated; }\n", codeSize)
+    page.WriteString("figcaption { font-family: monospace; font-size: 9pt; }\n</style>\n</head>\n<body>\n")
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        if i%perPage == 0 {
+            if i > 0 {
+                page.WriteString("</div>\n</section>\n")
+            }
+            status := fmt.Sprintf("page %d of %d", i/perPage+1, pages)
+            if id := elem.SetID(); len(id) > 0 {
+                status = fmt.Sprintf("set #%s, %s", id, status)
+            }
+            fmt.Fprintf(&page, "<section>\n<header><b>%s</b><span class=\"status\">%s</span></header>\n<div class=\"grid\">\n", html.EscapeString(options.Caption), html.EscapeString(status))
+        }
+        label := fmt.Sprintf("%d/%d", v.Index+1, v.MaxIndex+1)
+        fmt.Fprintf(&page, "<figure><img src=\"data:image/png;base64,%s\" alt=\"code %s\">", base64.StdEncoding.EncodeToString(images[i]), label)
+        if elem.Caption == nil {
+            fmt.Fprintf(&page, "<figcaption><b>%s</b><br>%s</figcaption>", label, html.EscapeString(options.Caption))
+        }
+        page.WriteString("</figure>\n")
+    }
+    page.WriteString("</div>\n</section>\n</body>\n</html>\n")
+    _, err = w.Write(page.Bytes())
+    return err
+}
+
+// WriteHTMLFile writes all elements to the HTML file fname, see WriteHTML
+func (elem *QrElements) WriteHTMLFile(fname string, options SheetOptions) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.WriteHTML(file, options)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,27 @@
+package grpcserver
+
+import (
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+)
+
+// Authenticate returns a ServerOption refusing the calls whose credentials are not accepted by check, with
+// codes.Unauthenticated. check is given the "authorization" metadata of the call (e.g. "Bearer <token>", like the
+// Authorization header of HTTP), empty if there is none. All calls of the QrFile service are streams, so a stream
+// interceptor covers them.
+func Authenticate(check func(authorization string) bool) grpc.ServerOption {
+    return grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+        authorization := ""
+        if md, ok := metadata.FromIncomingContext(stream.Context()); ok {
+            if values := md.Get("authorization"); len(values) > 0 {
+                authorization = values[0]
+            }
+        }
+        if !check(authorization) {
+            return status.Error(codes.Unauthenticated, "Missing or invalid credentials")
+        }
+        return handler(srv, stream)
+    })
+}
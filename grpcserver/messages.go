@@ -0,0 +1,142 @@
+package grpcserver
+
+import (
+    "errors"
+    "fmt"
+    "google.golang.org/protobuf/encoding/protowire"
+)
+
+// message is implemented by all messages of the service (see qrfile.proto)
+type message interface {
+    marshal() []byte
+    unmarshal(b []byte) error
+}
+
+// DataPart corresponds to the DataPart message in qrfile.proto
+type DataPart struct {
+    Data      []byte
+    RenderPNG bool
+}
+
+// Chunk corresponds to the Chunk message in qrfile.proto
+type Chunk struct {
+    Index    uint64
+    MaxIndex uint64
+    Text     string
+    PNG      []byte
+}
+
+func (m *DataPart) marshal() (b []byte) {
+    if len(m.Data) > 0 {
+        b = protowire.AppendTag(b, 1, protowire.BytesType)
+        b = protowire.AppendBytes(b, m.Data)
+    }
+    if m.RenderPNG {
+        b = protowire.AppendTag(b, 2, protowire.VarintType)
+        b = protowire.AppendVarint(b, 1)
+    }
+    return
+}
+
+func (m *DataPart) unmarshal(b []byte) error {
+    *m = DataPart{}
+    return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+        switch {
+        case num == 1 && typ == protowire.BytesType:
+            v, n := protowire.ConsumeBytes(b)
+            m.Data = append([]byte(nil), v...)
+            return n, nil
+        case num == 2 && typ == protowire.VarintType:
+            v, n := protowire.ConsumeVarint(b)
+            m.RenderPNG = v != 0
+            return n, nil
+        }
+        return protowire.ConsumeFieldValue(num, typ, b), nil
+    })
+}
+
+func (m *Chunk) marshal() (b []byte) {
+    if m.Index != 0 {
+        b = protowire.AppendTag(b, 1, protowire.VarintType)
+        b = protowire.AppendVarint(b, m.Index)
+    }
+    if m.MaxIndex != 0 {
+        b = protowire.AppendTag(b, 2, protowire.VarintType)
+        b = protowire.AppendVarint(b, m.MaxIndex)
+    }
+    if len(m.Text) > 0 {
+        b = protowire.AppendTag(b, 3, protowire.BytesType)
+        b = protowire.AppendString(b, m.Text)
+    }
+    if len(m.PNG) > 0 {
+        b = protowire.AppendTag(b, 4, protowire.BytesType)
+        b = protowire.AppendBytes(b, m.PNG)
+    }
+    return
+}
+
+func (m *Chunk) unmarshal(b []byte) error {
+    *m = Chunk{}
+    return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+        switch {
+        case num == 1 && typ == protowire.VarintType:
+            v, n := protowire.ConsumeVarint(b)
+            m.Index = v
+            return n, nil
+        case num == 2 && typ == protowire.VarintType:
+            v, n := protowire.ConsumeVarint(b)
+            m.MaxIndex = v
+            return n, nil
+        case num == 3 && typ == protowire.BytesType:
+            v, n := protowire.ConsumeString(b)
+            m.Text = v
+            return n, nil
+        case num == 4 && typ == protowire.BytesType:
+            v, n := protowire.ConsumeBytes(b)
+            m.PNG = append([]byte(nil), v...)
+            return n, nil
+        }
+        return protowire.ConsumeFieldValue(num, typ, b), nil
+    })
+}
+
+// consumeFields walks over all fields of an encoded message; field consumes the value of a single field & returns its length
+func consumeFields(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+    for len(b) > 0 {
+        num, typ, n := protowire.ConsumeTag(b)
+        if n < 0 {
+            return protowire.ParseError(n)
+        }
+        b = b[n:]
+        n, err := field(num, typ, b)
+        if err != nil {
+            return err
+        }
+        if n < 0 {
+            return protowire.ParseError(n)
+        }
+        b = b[n:]
+    }
+    return nil
+}
+
+// codec implements the grpc encoding.Codec interface for the messages of this package
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+    m, ok := v.(message)
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unable to marshal message of type %T", v))
+    }
+    return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+    m, ok := v.(message)
+    if !ok {
+        return errors.New(fmt.Sprintf("Unable to unmarshal message of type %T", v))
+    }
+    return m.unmarshal(data)
+}
+
+func (codec) Name() string { return "proto" }
@@ -0,0 +1,160 @@
+// Package grpcserver provides a gRPC service (see qrfile.proto) to encode files to chunks and restore files from chunks.
+// Both directions are streamed, so large files are transferred in parts and flow control is left to gRPC.
+package grpcserver
+
+import (
+    "bytes"
+    "github.com/Schokomuesl1/qrFile"
+    "google.golang.org/grpc"
+    "image/png"
+    "io"
+)
+
+// partSize is the size of the data parts returned by Decode
+const partSize = 64 * 1024
+
+// QrFileServer is the server API of the QrFile service
+type QrFileServer interface {
+    Encode(stream grpc.ServerStream) error
+    Decode(stream grpc.ServerStream) error
+}
+
+// Server implements QrFileServer using the qrFile package
+type Server struct{}
+
+// NewServer creates a grpc.Server with the QrFile service registered. The message codec of this package is forced, all
+// other options are passed on to grpc.NewServer.
+func NewServer(opt ...grpc.ServerOption) *grpc.Server {
+    s := grpc.NewServer(append([]grpc.ServerOption{grpc.ForceServerCodec(codec{})}, opt...)...)
+    Register(s, new(Server))
+    return s
+}
+
+// Register registers a QrFileServer implementation with a grpc.Server. The server has to use the codec of this package
+// (see NewServer).
+func Register(s *grpc.Server, srv QrFileServer) {
+    s.RegisterService(&serviceDesc, srv)
+}
+
+// Encode collects all data parts sent by the client and returns the chunks of the resulting set
+func (srv *Server) Encode(stream grpc.ServerStream) error {
+    qrf := qrFile.New()
+    renderPNG := false
+    for first := true; ; first = false {
+        part := new(DataPart)
+        err := stream.RecvMsg(part)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        if first {
+            renderPNG = part.RenderPNG
+        }
+        qrf.Data = append(qrf.Data, part.Data...)
+    }
+    elements, err := qrFile.GetElements(qrf.ToHexString())
+    if err != nil {
+        return err
+    }
+    for _, v := range elements.Elements {
+        chunk := &Chunk{Index: v.Index, MaxIndex: v.MaxIndex, Text: v.AsString()}
+        if renderPNG {
+            // rendered with the encoder selected for the set (see qrFile.DefaultEncoder)
+            img, err := elements.Image(v.Index)
+            if err != nil {
+                return err
+            }
+            var encoded bytes.Buffer
+            err = png.Encode(&encoded, img)
+            if err != nil {
+                return err
+            }
+            chunk.PNG = encoded.Bytes()
+        }
+        err = stream.SendMsg(chunk)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Decode collects the chunks sent by the client and returns the restored data. Chunks are read from their text if
+// present, otherwise the png image is parsed.
+func (srv *Server) Decode(stream grpc.ServerStream) error {
+    assembler := qrFile.NewAssembler()
+    for {
+        chunk := new(Chunk)
+        err := stream.RecvMsg(chunk)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return err
+        }
+        if len(chunk.Text) > 0 {
+            _, err = assembler.AddString(chunk.Text)
+        } else {
+            err = addPNG(assembler, chunk.PNG)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    elements, err := assembler.Elements()
+    if err != nil {
+        return err
+    }
+    qrf := qrFile.New()
+    err = elements.StoreData(qrf)
+    if err != nil {
+        return err
+    }
+    for i := 0; i < len(qrf.Data); i += partSize {
+        end := i + partSize
+        if end > len(qrf.Data) {
+            end = len(qrf.Data)
+        }
+        err = stream.SendMsg(&DataPart{Data: qrf.Data[i:end]})
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// addPNG parses a png image and adds the element to the assembler
+func addPNG(assembler *qrFile.Assembler, data []byte) error {
+    img, err := png.Decode(bytes.NewReader(data))
+    if err != nil {
+        return err
+    }
+    newElement := new(qrFile.QrElement)
+    err = newElement.ParseImage(img)
+    if err != nil {
+        return err
+    }
+    _, err = assembler.Add(*newElement)
+    return err
+}
+
+func encodeHandler(srv interface{}, stream grpc.ServerStream) error {
+    return srv.(QrFileServer).Encode(stream)
+}
+
+func decodeHandler(srv interface{}, stream grpc.ServerStream) error {
+    return srv.(QrFileServer).Decode(stream)
+}
+
+// serviceDesc describes the QrFile service, see qrfile.proto
+var serviceDesc = grpc.ServiceDesc{
+    ServiceName: "qrfile.v1.QrFile",
+    HandlerType: (*QrFileServer)(nil),
+    Streams: []grpc.StreamDesc{
+        {StreamName: "Encode", Handler: encodeHandler, ServerStreams: true, ClientStreams: true},
+        {StreamName: "Decode", Handler: decodeHandler, ServerStreams: true, ClientStreams: true},
+    },
+    Metadata: "qrfile.proto",
+}
@@ -0,0 +1,65 @@
+//go:build heif && cgo
+// +build heif,cgo
+
+package qrFile
+
+/*
+#cgo pkg-config: libheif
+#include <stdlib.h>
+#include <libheif/heif.h>
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "image"
+    "unsafe"
+)
+
+// readHEIFFile decodes the primary image of a HEIC/HEIF file using libheif. Transformations stored in the file (e.g. the
+// rotation of the camera) are applied by libheif.
+func readHEIFFile(fname string) ([]image.Image, error) {
+    cname := C.CString(fname)
+    defer C.free(unsafe.Pointer(cname))
+    ctx := C.heif_context_alloc()
+    defer C.heif_context_free(ctx)
+    herr := C.heif_context_read_from_file(ctx, cname, nil)
+    if herr.code != C.heif_error_Ok {
+        return nil, heifError(fname, herr)
+    }
+    var handle *C.struct_heif_image_handle
+    herr = C.heif_context_get_primary_image_handle(ctx, &handle)
+    if herr.code != C.heif_error_Ok {
+        return nil, heifError(fname, herr)
+    }
+    defer C.heif_image_handle_release(handle)
+    var himg *C.struct_heif_image
+    herr = C.heif_decode_image(handle, &himg, C.heif_colorspace_RGB, C.heif_chroma_interleaved_RGB, nil)
+    if herr.code != C.heif_error_Ok {
+        return nil, heifError(fname, herr)
+    }
+    defer C.heif_image_release(himg)
+    width := int(C.heif_image_get_width(himg, C.heif_channel_interleaved))
+    height := int(C.heif_image_get_height(himg, C.heif_channel_interleaved))
+    var stride C.int
+    plane := C.heif_image_get_plane_readonly(himg, C.heif_channel_interleaved, &stride)
+    if plane == nil || width <= 0 || height <= 0 {
+        return nil, errors.New(fmt.Sprintf("No image data in %s", fname))
+    }
+    data := C.GoBytes(unsafe.Pointer(plane), stride*C.int(height))
+    img := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        for x := 0; x < width; x++ {
+            src := data[y*int(stride)+x*3:]
+            dst := img.Pix[y*img.Stride+x*4:]
+            dst[0], dst[1], dst[2], dst[3] = src[0], src[1], src[2], 255
+        }
+    }
+    return []image.Image{img}, nil
+}
+
+// heifError converts a libheif error
+func heifError(fname string, herr C.struct_heif_error) error {
+    return errors.New(fmt.Sprintf("Unable to decode %s: %s", fname, C.GoString(herr.message)))
+}
@@ -0,0 +1,63 @@
+//go:build !heif || !cgo
+// +build !heif !cgo
+
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+)
+
+// HeifConvertPath is the location of the heif-convert tool of libheif (https://github.com/strukturag/libheif), used to
+// read HEIC/HEIF photos (see ToolDirectory). Build with the tag heif (and cgo enabled) to link libheif directly instead.
+var HeifConvertPath = "heif-convert"
+
+// readHEIFFile converts a HEIC/HEIF file to png image(s) in a temporary directory using heif-convert & reads them
+func readHEIFFile(fname string) ([]image.Image, error) {
+    tempDir, err := ioutil.TempDir(os.TempDir(), "qrFileHeif")
+    if err != nil {
+        return nil, err
+    }
+    defer os.RemoveAll(tempDir)
+    var stderr bytes.Buffer
+    ctx, cancel := decodeContext(context.Background())
+    defer cancel()
+    cmd := exec.CommandContext(ctx, toolPath(HeifConvertPath), fname, filepath.Join(tempDir, "image.png"))
+    cmd.Stderr = &stderr
+    err = cmd.Run()
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, &TimeoutError{Command: "heif-convert", File: fname, Timeout: DecodeTimeout}
+    }
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("heif-convert failed for %s: %s %s", fname, err, bytes.TrimSpace(stderr.Bytes())))
+    }
+    // files with several top level images are converted to image-1.png, image-2.png, ...
+    files, _ := filepath.Glob(filepath.Join(tempDir, "*.png"))
+    sort.Strings(files)
+    images := make([]image.Image, 0, len(files))
+    for _, f := range files {
+        file, err := os.Open(f)
+        if err != nil {
+            return nil, err
+        }
+        img, err := png.Decode(file)
+        file.Close()
+        if err != nil {
+            return nil, err
+        }
+        images = append(images, img)
+    }
+    if len(images) == 0 {
+        return nil, errors.New(fmt.Sprintf("heif-convert created no image for %s", fname))
+    }
+    return images, nil
+}
@@ -0,0 +1,357 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// The image input layer: all supported formats are decoded into images (applying the orientation stored by the camera,
+// see exif.go), which are handed to the Decoder set or, if none is set, to the native decoder (see decoder_native.go).
+// Only ZbarDecoder reads png files directly; for other formats, each image is stored in a temporary png file for
+// zbarimg. Images held in memory (e.g. uploads) are read with ReadImages, without a file, except for HEIF photos, which
+// libheif & heif-convert read from a file.
+
+// The format of a file is detected from its content, so renamed files or files without extension are handled as well;
+// the extension is only used if the content is not recognized. Formats without built-in support are read by the
+// decoders registered with the image package, e.g. WebP once a program imports golang.org/x/image/webp. Video files are not read as a list of images, but frame
+// by frame (see video.go).
+
+// inputDecoders maps the supported formats to a function reading all images of such a file
+var inputDecoders = map[string]func(fname string) ([]image.Image, error){
+    "bmp":   readBMPFile,
+    "gif":   readGIFFile,
+    "heif":  readHEIFFile,
+    "image": readRegisteredFile,
+    "jpeg":  readJPEGFile,
+    "pdf":   readPDFFile,
+    "png":   readPNGFile,
+    "tiff":  readTIFFFile,
+}
+
+// dataDecoders maps the supported formats to a function reading all images of such data, see ReadImages
+var dataDecoders = map[string]func(data []byte) ([]image.Image, error){
+    "bmp":   decodeBMP,
+    "gif":   decodeGIF,
+    "heif":  decodeHEIF,
+    "image": decodeRegistered,
+    "jpeg":  decodeJPEG,
+    "pdf":   decodePDF,
+    "png":   decodePNG,
+    "tiff":  decodeTIFF,
+}
+
+// inputExtensions maps the file extensions (lower case) to the supported formats
+var inputExtensions = map[string]string{
+    ".bmp":  "bmp",
+    ".gif":  "gif",
+    ".heic": "heif",
+    ".heif": "heif",
+    ".jpeg": "jpeg",
+    ".jpg":  "jpeg",
+    ".pdf":  "pdf",
+    ".png":  "png",
+    ".tif":  "tiff",
+    ".tiff": "tiff",
+    // read with ffmpeg, see video.go
+    ".avi":  "video",
+    ".m4v":  "video",
+    ".mkv":  "video",
+    ".mov":  "video",
+    ".mp4":  "video",
+    ".webm": "video",
+}
+
+// inputFormat returns the format of an input file; empty if it is not supported
+func inputFormat(fname string) string {
+    file, err := os.Open(fname)
+    if err == nil {
+        header := make([]byte, 12)
+        n, _ := io.ReadFull(file, header)
+        file.Close()
+        if format := sniffFormat(header[:n]); len(format) > 0 {
+            return format
+        }
+        if isRegisteredImage(fname) {
+            return "image"
+        }
+    }
+    return inputExtensions[strings.ToLower(filepath.Ext(fname))]
+}
+
+// isRegisteredImage reports whether a decoder registered with the image package (see image.RegisterFormat) reads fname
+func isRegisteredImage(fname string) bool {
+    file, err := os.Open(fname)
+    if err != nil {
+        return false
+    }
+    defer file.Close()
+    _, _, err = image.DecodeConfig(file)
+    return err == nil
+}
+
+// sniffFormat detects the format from the first bytes of a file
+func sniffFormat(header []byte) string {
+    switch {
+    case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+        return "png"
+    case bytes.HasPrefix(header, []byte("GIF87a")) || bytes.HasPrefix(header, []byte("GIF89a")):
+        return "gif"
+    case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+        return "jpeg"
+    case bytes.HasPrefix(header, []byte("%PDF-")):
+        return "pdf"
+    case bytes.HasPrefix(header, []byte("II*\x00")) || bytes.HasPrefix(header, []byte("MM\x00*")):
+        return "tiff"
+    case len(header) >= 12 && bytes.HasPrefix(header, []byte("BM")):
+        return "bmp"
+    case len(header) >= 12 && string(header[4:8]) == "ftyp":
+        switch string(header[8:12]) {
+        case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+            return "heif"
+        case "isom", "iso2", "mp41", "mp42", "avc1", "M4V ", "qt  ", "3gp4", "3gp5":
+            return "video"
+        }
+    case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}): // Matroska & WebM
+        return "video"
+    case len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+        return "video"
+    }
+    return ""
+}
+
+// isInputFile reports whether the image input layer can handle fname
+func isInputFile(fname string) bool {
+    return len(inputFormat(fname)) > 0
+}
+
+// parseFile parses all elements contained in an input file (e.g. all pages of a multipage TIFF). If decoder is nil, the
+// default decoder is used (see ZbarFallback). Calibration & sync frames of a stream are skipped. If no valid element is found, the
+// images are decoded again as the policy says (see RetryPolicy); decoders which timed out are not retried. Codes which
+// are no elements are skipped in DecodeLenient mode. Returns the elements, the number of foreign codes skipped & the
+// number of decode attempts made. If ctx is canceled, ctx.Err() is returned without further attempts.
+func parseFile(ctx context.Context, fname string, decoder Decoder, policy RetryPolicy, mode DecodeMode) ([]QrElement, int, int, error) {
+    symbols, err := scanFile(ctx, fname, decoder)
+    return parseScanned(ctx, fname, symbols, err, decoder, policy, mode)
+}
+
+// parseScanned works like parseFile for an input file whose codes were scanned already (or failed to be scanned with
+// err), e.g. by zbarimg along with other files (see scanPNGs)
+func parseScanned(ctx context.Context, fname string, symbols []Symbol, err error, decoder Decoder, policy RetryPolicy, mode DecodeMode) ([]QrElement, int, int, error) {
+    if err == nil {
+        var result []QrElement
+        var foreign int
+        result, foreign, err = parseSymbols(fname, symbols, mode)
+        if err == nil {
+            return result, foreign, 1, nil
+        }
+    }
+    // the frames of a video are not decoded again; a recording shows every code in many frames anyway
+    if _, timeout := err.(*TimeoutError); timeout || len(policy.attempts(decoder)) == 0 || ctx.Err() != nil || inputFormat(fname) == "video" {
+        return nil, 0, 1, err
+    }
+    result, foreign, attempts, retryErr := policy.retryFile(ctx, fname, decoder, mode)
+    if retryErr != nil {
+        return nil, 0, 1 + attempts, errors.New(fmt.Sprintf("%s (retry: %s)", err, retryErr))
+    }
+    return result, foreign, 1 + attempts, nil
+}
+
+// parseSymbols parses the text of the codes found in an input file, skipping calibration & sync frames. Codes which are
+// no elements are an error in DecodeStrict mode; otherwise they are skipped & counted, unless no element is left.
+func parseSymbols(fname string, symbols []Symbol, mode DecodeMode) ([]QrElement, int, error) {
+    result := make([]QrElement, 0, len(symbols))
+    foreign := make([]string, 0)
+    for _, symbol := range symbols {
+        if IsControlText(symbol.Text) {
+            continue
+        }
+        newElement := new(QrElement)
+        err := newElement.ParseString(symbol.Text)
+        if err != nil {
+            if mode == DecodeStrict {
+                return nil, 0, fmt.Errorf("%s: %w", fname, err)
+            }
+            foreign = append(foreign, err.Error())
+            continue
+        }
+        newElement.symbol = symbol
+        result = append(result, *newElement)
+    }
+    if len(result) == 0 && len(foreign) > 0 {
+        return nil, 0, errors.New(fmt.Sprintf("%s: no element found, only codes which are no elements (%s)", fname, strings.Join(foreign, "; ")))
+    }
+    return result, len(foreign), nil
+}
+
+// scanFile returns all codes contained in an input file. If decoder is nil, the default decoder is used (see
+// ZbarFallback). Decoding stops with ctx.Err() once ctx is canceled.
+func scanFile(ctx context.Context, fname string, decoder Decoder) ([]Symbol, error) {
+    if inputFormat(fname) == "video" {
+        return scanVideo(ctx, fname, decoder)
+    }
+    if _, zbar := decoder.(ZbarDecoder); zbar && inputFormat(fname) == "png" {
+        return scanPNG(ctx, fname)
+    }
+    images, err := readImages(fname)
+    if err != nil {
+        return nil, err
+    }
+    return scanImages(ctx, fname, images, decoder)
+}
+
+// scanImages returns all codes contained in the images read from the input source name, like scanFile
+func scanImages(ctx context.Context, name string, images []image.Image, decoder Decoder) ([]Symbol, error) {
+    decoder = decoderOrDefault(decoder)
+    result := make([]Symbol, 0, len(images))
+    for i, img := range images {
+        symbols, err := decodeSymbols(ctx, decoder, img)
+        if err != nil && err == ctx.Err() {
+            return nil, err
+        }
+        if timeout, ok := err.(*TimeoutError); ok {
+            // the temporary file of zbarimg is of no interest
+            timeout.File = fmt.Sprintf("%s, image %d", name, i+1)
+            return nil, timeout
+        }
+        if err != nil {
+            return nil, fmt.Errorf("%s, image %d: %w", name, i+1, err)
+        }
+        if len(symbols) == 0 {
+            return nil, errors.New(fmt.Sprintf("%s, image %d: no code found", name, i+1))
+        }
+        result = append(result, symbols...)
+    }
+    return result, nil
+}
+
+// readImages reads all images contained in an input file
+func readImages(fname string) ([]image.Image, error) {
+    decode, ok := inputDecoders[inputFormat(fname)]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unsupported input file %s", fname))
+    }
+    return decode(fname)
+}
+
+// ReadImages reads all images of an input in any supported format (e.g. all pages of a multipage TIFF or PDF), which is
+// detected from its content; the EXIF orientation of JPEG photos is applied. Unlike the files read by FromPNGs, the
+// data is decoded in memory, so e.g. uploads are read without storing them in a file (HEIF photos excepted).
+func ReadImages(r io.Reader) ([]image.Image, error) {
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    format := sniffFormat(data)
+    if _, _, err := image.DecodeConfig(bytes.NewReader(data)); len(format) == 0 && err == nil {
+        format = "image"
+    }
+    decode, ok := dataDecoders[format]
+    if !ok {
+        return nil, errors.New("Unsupported image format")
+    }
+    return decode(data)
+}
+
+// readRegisteredFile reads an image file in a format registered with the image package
+func readRegisteredFile(fname string) ([]image.Image, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    img, _, err := image.Decode(file)
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
+
+// decodeRegistered reads image data in a format registered with the image package
+func decodeRegistered(data []byte) ([]image.Image, error) {
+    img, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
+
+// readPNGFile reads a png file
+func readPNGFile(fname string) ([]image.Image, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    img, err := png.Decode(file)
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
+
+// decodePNG reads png data
+func decodePNG(data []byte) ([]image.Image, error) {
+    img, err := png.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    return []image.Image{img}, nil
+}
+
+// scanImage returns the codes in an image by storing it in a temporary png file which is passed to zbarimg
+func scanImage(ctx context.Context, img image.Image) ([]Symbol, error) {
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileImage*.png")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    err = png.Encode(tempfile, img)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+    return scanPNG(ctx, tempfile.Name())
+}
+
+// readTIFFFile reads all pages of a TIFF file
+func readTIFFFile(fname string) ([]image.Image, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return DecodeTIFF(file)
+}
+
+// decodeTIFF reads all pages of TIFF data
+func decodeTIFF(data []byte) ([]image.Image, error) {
+    return DecodeTIFF(bytes.NewReader(data))
+}
+
+// decodeHEIF reads a HEIC/HEIF photo by storing it in a temporary file for readHEIFFile
+func decodeHEIF(data []byte) ([]image.Image, error) {
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileImage*.heic")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    _, err = tempfile.Write(data)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+    return readHEIFFile(tempfile.Name())
+}
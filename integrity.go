@@ -0,0 +1,75 @@
+package qrFile
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/binary"
+    "errors"
+    "hash/crc32"
+)
+
+// A set in plain format can carry checksums of its data in reserved header fields (see fields.go), so a misread
+// element & a corrupted reconstruction are detected (see EncodeOptions.Integrity): every element carries the CRC-32 of
+// its payload (FieldTypeCRC32), which ParseString checks, so a code misread despite its error correction is rejected
+// like an unreadable one, & the first element carries the SHA-256 of the complete data (FieldTypeDataSHA256). StoreData
+// verifies both & names the element or the data which does not match.
+
+// integrityReserve is the amount of characters the integrity fields take in the header of the first element: the
+// token of both fields & the space before it
+const integrityReserve uint64 = 1 + uint64(len(fieldsPrefix)) + 2*(2+crc32.Size+2+sha256.Size)
+
+// AddIntegrity adds the integrity fields to the headers of the elements of a complete set in plain format (see
+// EncodeOptions.Integrity)
+func (elem *QrElements) AddIntegrity() error {
+    digest := sha256.New()
+    dataCount := elem.dataCount()
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        if v.Version != VersionPlain {
+            return errors.New("Integrity fields require the plain format")
+        }
+        payload := v.Payload
+        if v.Index < dataCount {
+            // parity elements hold no data
+            digest.Write(payload)
+        }
+        var checksum [crc32.Size]byte
+        binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(payload))
+        v.Fields.Set(FieldTypeCRC32, checksum[:])
+    }
+    for i := range elem.Elements {
+        if elem.Elements[i].Index == 0 {
+            elem.Elements[i].Fields.Set(FieldTypeDataSHA256, digest.Sum(nil))
+        }
+    }
+    return nil
+}
+
+// checkPayloadCRC compares the payload with the CRC-32 in the header, if there is one
+func (elem *QrElement) checkPayloadCRC() error {
+    expected, ok := elem.Fields.Get(FieldTypeCRC32)
+    if !ok {
+        return nil
+    }
+    var checksum [crc32.Size]byte
+    binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(elem.Payload))
+    if !bytes.Equal(checksum[:], expected) {
+        return parseChecksumError("payload", "CRC-32 %x does not match the header (%x)", checksum, expected)
+    }
+    return nil
+}
+
+// checkDataSHA256 compares the SHA-256 of the data restored from the elements with the one in the header of the first
+// element, if there is one & the set is complete
+func (elem *QrElements) checkDataSHA256(sum []byte) error {
+    for _, v := range elem.Elements {
+        expected, ok := v.Fields.Get(FieldTypeDataSHA256)
+        if !ok || v.Index != 0 || uint64(elem.Len()) != v.MaxIndex+1 {
+            continue
+        }
+        if !bytes.Equal(sum, expected) {
+            return checksumError("The restored data is corrupted: SHA-256 %x, the set announces %x", sum, expected)
+        }
+    }
+    return nil
+}
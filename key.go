@@ -0,0 +1,49 @@
+package qrFile
+
+import (
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "strings"
+)
+
+// Besides passphrases, data can be encrypted with a raw AES-256 key, e.g. one generated once for an automated backup
+// pipeline (head -c 32 /dev/urandom > backup.key). No key derivation is involved, so the key has to be random.
+
+// KeySize is the size of a raw key in bytes (AES-256)
+const KeySize = 32
+
+// ParseKey decodes a raw key given as text: KeySize bytes hex or base64 encoded (standard or URL alphabet, padding
+// optional). Surrounding whitespace is ignored.
+func ParseKey(text string) ([]byte, error) {
+    text = strings.TrimSpace(text)
+    if len(text) == 2*KeySize {
+        if key, err := hex.DecodeString(text); err == nil {
+            return key, nil
+        }
+    }
+    for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+        if key, err := encoding.DecodeString(text); err == nil && len(key) == KeySize {
+            return key, nil
+        }
+    }
+    return nil, errors.New(fmt.Sprintf("Invalid key: expected %d bytes, hex or base64 encoded", KeySize))
+}
+
+// ReadKeyFile reads a raw key from a file: either exactly KeySize bytes of binary data or the key as text (see ParseKey)
+func ReadKeyFile(fname string) ([]byte, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) == KeySize {
+        return data, nil
+    }
+    key, err := ParseKey(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return key, nil
+}
@@ -0,0 +1,170 @@
+package qrFile
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// SetSummary describes a set found in a directory (see ListSets)
+type SetSummary struct {
+    SetID   string   // empty if the format carries none
+    Source  string   // the manifest describing the set, or the images it was decoded from
+    Present uint64   // number of distinct elements found
+    Total   uint64   // number of elements of the complete set
+    Missing []uint64 // indices of the elements not found
+    Size    uint64   // estimated size of the data restorable from the elements found, in bytes
+    // Verified is set if the files of the set were checked against a checksums file (see ChecksumsName); Damaged lists
+    // the files present which do not match it
+    Verified bool
+    Damaged  []string
+}
+
+// Complete reports whether all elements of the set were found
+func (s *SetSummary) Complete() bool {
+    return s.Present == s.Total
+}
+
+// MissingRanges formats the missing indices as ranges, e.g. "3-5, 9"
+func (s *SetSummary) MissingRanges() string {
+    return formatRanges(s.Missing)
+}
+
+// formatRanges formats ascending indices as ranges, e.g. "3-5, 9"
+func formatRanges(indices []uint64) string {
+    ranges := make([]string, 0)
+    for i := 0; i < len(indices); {
+        j := i
+        for j+1 < len(indices) && indices[j+1] == indices[j]+1 {
+            j++
+        }
+        if i == j {
+            ranges = append(ranges, fmt.Sprintf("%d", indices[i]))
+        } else {
+            ranges = append(ranges, fmt.Sprintf("%d-%d", indices[i], indices[j]))
+        }
+        i = j + 1
+    }
+    return strings.Join(ranges, ", ")
+}
+
+// ListSets summarizes the sets found in a directory. Images described by a manifest (see WritePNGs) are not decoded:
+// the set is summarized from the manifest & the image files present (the fast path). All other images are decoded
+// (using decoder, the default decoder if nil) & grouped into sets (see FindSets).
+func ListSets(dir string, decoder Decoder) ([]SetSummary, error) {
+    manifests, err := filepath.Glob(filepath.Join(dir, "*"+ManifestName))
+    if err != nil {
+        return nil, err
+    }
+    summaries := make([]SetSummary, 0)
+    described := make(map[string]bool)
+    for _, fname := range manifests {
+        manifest, err := ReadManifestFile(fname)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", fname, err)
+        }
+        summary := SetSummary{SetID: manifest.SetID, Source: filepath.Base(fname), Total: manifest.Count}
+        found := make(map[uint64]bool)
+        for _, chunk := range manifest.Chunks {
+            if len(chunk.Image) == 0 {
+                continue
+            }
+            image := filepath.Join(dir, chunk.Image)
+            described[image] = true
+            if _, err := os.Stat(image); err == nil {
+                found[chunk.Index] = true
+            }
+        }
+        summary.Present = uint64(len(found))
+        summary.Missing = missingIndices(found, summary.Total)
+        summary.Verified, summary.Damaged, err = verifySetChecksums(fname)
+        if err != nil {
+            return nil, err
+        }
+        if summary.Total > 0 {
+            // the manifest records the total length only
+            summary.Size = manifest.Length / 2 * summary.Present / summary.Total
+        }
+        summaries = append(summaries, summary)
+    }
+
+    files, err := filepath.Glob(filepath.Join(dir, "*"))
+    if err != nil {
+        return nil, err
+    }
+    others := make([]string, 0)
+    for _, fname := range files {
+        if info, err := os.Stat(fname); err == nil && !info.IsDir() && !described[fname] && isInputFile(fname) {
+            others = append(others, fname)
+        }
+    }
+    if len(others) == 0 {
+        return summaries, nil
+    }
+    sets, err := FindSets(others, decoder)
+    if err != nil {
+        // none of the images holds a code
+        return summaries, nil
+    }
+    for _, set := range sets {
+        summary := SetSummary{SetID: set.SetID(), Source: "decoded images", Total: set.Elements[0].MaxIndex + 1}
+        found := make(map[uint64]bool)
+        for _, v := range set.Elements {
+            if !found[v.Index] {
+                found[v.Index] = true
+                summary.Size += uint64(len(v.Payload))
+            }
+        }
+        summary.Present = uint64(len(found))
+        summary.Missing = missingIndices(found, summary.Total)
+        summaries = append(summaries, summary)
+    }
+    return summaries, nil
+}
+
+// verifySetChecksums checks the files of the set described by the manifest fname against the checksums file written
+// next to it, if any. Missing files are not reported, they are listed as missing codes already.
+func verifySetChecksums(manifest string) (bool, []string, error) {
+    fname := strings.TrimSuffix(manifest, ManifestName) + ChecksumsName
+    if _, err := os.Stat(fname); os.IsNotExist(err) {
+        return false, nil, nil
+    }
+    results, err := VerifyChecksums(fname)
+    if err != nil {
+        return false, nil, err
+    }
+    damaged := make([]string, 0)
+    for _, result := range results {
+        if result.Err != nil && !os.IsNotExist(result.Err) {
+            damaged = append(damaged, filepath.Base(result.File))
+        }
+    }
+    return true, damaged, nil
+}
+
+// maxMissingListed limits the indices listed by missingIndices: the count of a set is read from its codes, so a misread
+// count of billions of elements must not exhaust the memory
+const maxMissingListed = 1 << 20
+
+// missingIndices returns the indices below total which are not found, in ascending order; only the first
+// maxMissingListed are listed. The time taken depends on the elements found, not on total.
+func missingIndices(found map[uint64]bool, total uint64) []uint64 {
+    present := make([]uint64, 0, len(found)+1)
+    for index, ok := range found {
+        if ok && index < total {
+            present = append(present, index)
+        }
+    }
+    sort.Slice(present, func(i, j int) bool { return present[i] < present[j] })
+    missing := make([]uint64, 0)
+    next := uint64(0)
+    for _, index := range append(present, total) {
+        for ; next < index && len(missing) < maxMissingListed; next++ {
+            missing = append(missing, next)
+        }
+        next = index + 1
+    }
+    return missing
+}
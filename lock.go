@@ -0,0 +1,82 @@
+package qrFile
+
+import (
+    "log/slog"
+    "os"
+    "path/filepath"
+)
+
+// Several processes may write to the same directory, e.g. two runs writing sets with the same prefix or a restore
+// running while a set is written. Writers of images & manifests hold an exclusive advisory lock on the directory,
+// readers of manifests a shared one, so no process sees (or produces) a half written or interleaved set. The locks are
+// advisory: they are taken on a lock file (LockName) in the directory & only respected by this package.
+
+// LockName is the name of the lock file created in directories written to; it is ignored when reading images
+const LockName = ".qrfile.lock"
+
+// DirLock is an advisory lock on a directory, see LockDirectory
+type DirLock struct {
+    file *os.File // nil if the directory could not be locked, see RLockDirectory
+}
+
+// LockDirectory takes the exclusive lock of a directory, waiting for other processes holding it
+func LockDirectory(dir string) (*DirLock, error) {
+    file, err := os.OpenFile(filepath.Join(dir, LockName), os.O_RDWR|os.O_CREATE, 0666)
+    if err != nil {
+        return nil, err
+    }
+    return lockFile(file, dir, true)
+}
+
+// RLockDirectory takes a shared lock of a directory, waiting for a process holding the exclusive lock. Directories
+// which can not be written to are not locked, since no other process writes to them either.
+func RLockDirectory(dir string) (*DirLock, error) {
+    file, err := os.OpenFile(filepath.Join(dir, LockName), os.O_RDWR|os.O_CREATE, 0666)
+    if os.IsPermission(err) {
+        file, err = os.Open(filepath.Join(dir, LockName))
+        if os.IsNotExist(err) {
+            return &DirLock{}, nil
+        }
+    }
+    if err != nil {
+        return nil, err
+    }
+    return lockFile(file, dir, false)
+}
+
+// lockFile locks an open lock file, logging if the lock is held by another process
+func lockFile(file *os.File, dir string, exclusive bool) (*DirLock, error) {
+    locked, err := tryLock(file, exclusive)
+    if err == nil && !locked {
+        logf(slog.LevelInfo, "Waiting for another process writing to %s", dir)
+        err = waitLock(file, exclusive)
+    }
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    return &DirLock{file: file}, nil
+}
+
+// Unlock releases the lock; the lock file is kept, since removing it could let two processes lock different files
+func (l *DirLock) Unlock() error {
+    if l.file == nil {
+        return nil
+    }
+    // closing the file releases the lock
+    return l.file.Close()
+}
+
+// lockStorage takes the exclusive lock of a DirStorage; other storages are not locked. The returned function releases
+// the lock.
+func lockStorage(storage Storage) (func(), error) {
+    dir, ok := storage.(DirStorage)
+    if !ok {
+        return func() {}, nil
+    }
+    lock, err := LockDirectory(string(dir))
+    if err != nil {
+        return nil, err
+    }
+    return func() { lock.Unlock() }, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package qrFile
+
+import (
+    "os"
+)
+
+// tryLock locks the file; advisory locks are not supported on this platform, so directories are not locked
+func tryLock(file *os.File, exclusive bool) (bool, error) {
+    return true, nil
+}
+
+// waitLock locks the file; not supported on this platform
+func waitLock(file *os.File, exclusive bool) error {
+    return nil
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package qrFile
+
+import (
+    "os"
+    "syscall"
+)
+
+// tryLock locks the file if it is not locked by another process; locked is false if it is
+func tryLock(file *os.File, exclusive bool) (locked bool, err error) {
+    err = syscall.Flock(int(file.Fd()), lockMode(exclusive)|syscall.LOCK_NB)
+    if err == syscall.EWOULDBLOCK {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+// waitLock locks the file, waiting until other processes release it
+func waitLock(file *os.File, exclusive bool) error {
+    for {
+        err := syscall.Flock(int(file.Fd()), lockMode(exclusive))
+        if err != syscall.EINTR {
+            return err
+        }
+    }
+}
+
+func lockMode(exclusive bool) int {
+    if exclusive {
+        return syscall.LOCK_EX
+    }
+    return syscall.LOCK_SH
+}
@@ -0,0 +1,180 @@
+package qrFile
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// ManifestName is the name of the manifest written next to the images by WritePNGs (preceded by the file name prefix)
+const ManifestName = "manifest.json"
+
+// Manifest describes a set of elements, so a set can be checked or ordered without decoding any image
+type Manifest struct {
+    Version int             `json:"version"`       // format version of the elements
+    SetID   string          `json:"set,omitempty"` // set ID of the elements, if their format carries one
+    Count   uint64          `json:"count"`         // number of elements in the complete set
+    Length  uint64          `json:"length"`        // total payload length of all elements
+    Chunks  []ManifestChunk `json:"chunks,omitempty"`
+    Files   []ManifestFile  `json:"files,omitempty"` // files of the tar archive encoded by the set, if any (see ManifestFile)
+    PGP     *PGPInfo        `json:"pgp,omitempty"`   // set if the data needs to be decrypted using OpenPGP (see PGPInfo)
+    // transforms applied to the data before it was split, in order (see Transform)
+    Transforms []TransformInfo `json:"transforms,omitempty"`
+    Fields     HeaderFields    `json:"fields,omitempty"`    // custom fields of the set (see QrElements.Fields)
+    Codec      string          `json:"codec,omitempty"`     // encoding of the payload in the codes if not hex (see codec.go)
+    File       *FileInfo       `json:"file,omitempty"`      // original file of the set, if recorded (see FileInfo)
+    Metadata   *Metadata       `json:"metadata,omitempty"`  // creator, creation time, comment & content type, if recorded (see Metadata)
+    Signature  []byte          `json:"signature,omitempty"` // Ed25519 signature of the data of the set, if signed (see signature.go)
+    Parity     uint64          `json:"parity,omitempty"`    // number of parity elements among the elements (see parity.go)
+    // QR version the elements were sized for, if one was selected (see EncodeOptions.SymbolVersion)
+    SymbolVersion int `json:"symbolVersion,omitempty"`
+    // smallest QR version the codes are rendered in, if they are padded to the same size (see QrElements.PadVersion)
+    PadVersion int `json:"padVersion,omitempty"`
+}
+
+// ManifestChunk describes a single element of a set
+type ManifestChunk struct {
+    Index uint64 `json:"index"`
+    Hash  string `json:"sha256"`          // hex encoded SHA-256 of the element text (see QrElement.AsString)
+    Level string `json:"level,omitempty"` // error correction level of the image (L, M, Q or H)
+    File  string `json:"file,omitempty"`  // name of the file storing the element, if any
+    Image string `json:"image,omitempty"` // name of the image showing the element, if any
+}
+
+// Hash returns the hex encoded SHA-256 of the element text (see QrElement.AsString) with the payload hex encoded, so
+// it does not depend on the codec of the code (see codec.go)
+func (elem *QrElement) Hash() string {
+    canonical := *elem
+    canonical.Codec = CodecHex
+    sum := sha256.Sum256([]byte(canonical.AsString()))
+    return hex.EncodeToString(sum[:])
+}
+
+// Manifest creates a manifest describing all elements, including the error correction level of each image; file and
+// image names are left empty. If the complete set encodes a tar archive, its files are listed as well, as are the
+// OpenPGP protection of the data (see QrElements.PGP) & the transforms applied to it.
+func (elem *QrElements) Manifest() *Manifest {
+    manifest := new(Manifest)
+    manifest.Chunks = make([]ManifestChunk, 0, elem.Len())
+    for i, v := range elem.Elements {
+        if i == 0 {
+            manifest.Version = v.Version
+            manifest.SetID = v.SetID
+            manifest.Count = v.MaxIndex + 1
+            if v.Codec != CodecHex {
+                manifest.Codec = v.Codec.String()
+            }
+        }
+        manifest.Length += v.PayloadLength
+        manifest.Chunks = append(manifest.Chunks, ManifestChunk{Index: v.Index, Hash: v.Hash(), Level: elem.levelOf(v.Index).String()})
+    }
+    manifest.Files = elem.archiveFiles()
+    manifest.PGP = elem.PGP
+    manifest.Transforms = elem.AppliedTransforms()
+    manifest.Fields = elem.Fields
+    manifest.File = elem.FileInfo()
+    manifest.Metadata = elem.Metadata()
+    manifest.Signature = elem.signature()
+    manifest.SymbolVersion = elem.SymbolVersion
+    manifest.PadVersion = elem.PadVersion
+    if info, ok := elem.parity(); ok {
+        manifest.Parity = manifest.Count - info.data
+    }
+    return manifest
+}
+
+// Write writes the manifest as indented JSON
+func (m *Manifest) Write(w io.Writer) error {
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(m)
+}
+
+// WriteFile writes the manifest to the file fname, holding the lock of its directory (see LockDirectory)
+func (m *Manifest) WriteFile(fname string) error {
+    lock, err := LockDirectory(filepath.Dir(fname))
+    if err != nil {
+        return err
+    }
+    defer lock.Unlock()
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = m.Write(file)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// ReadManifestFile reads a manifest written by WritePNGs (or Manifest.WriteFile); a process writing to the directory
+// is waited for (see RLockDirectory)
+func ReadManifestFile(fname string) (*Manifest, error) {
+    lock, err := RLockDirectory(filepath.Dir(fname))
+    if err != nil {
+        return nil, err
+    }
+    defer lock.Unlock()
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    manifest := new(Manifest)
+    err = json.NewDecoder(file).Decode(manifest)
+    if err != nil {
+        return nil, err
+    }
+    return manifest, nil
+}
+
+// ManifestMismatch describes an element read whose hash differs from the one listed in the manifest it was checked
+// against (see QrElements.Expected), e.g. a misread which passed the checks of its format
+type ManifestMismatch struct {
+    File     string // image the element was read from; empty if not read from a file
+    Index    uint64
+    Hash     string // hash of the element read (see QrElement.Hash)
+    Expected string // hash listed in the manifest; empty if the manifest lists no element of this index
+}
+
+// String describes the mismatch, e.g. "element 3 (img_3.png) does not match the manifest (sha256 1a2b3c4d, expected 5e6f7a8b)"
+func (m ManifestMismatch) String() string {
+    name := fmt.Sprintf("element %d", m.Index)
+    if len(m.File) > 0 {
+        name += " (" + m.File + ")"
+    }
+    if len(m.Expected) == 0 {
+        return fmt.Sprintf("%s is not listed in the manifest", name)
+    }
+    return fmt.Sprintf("%s does not match the manifest (sha256 %s, expected %s)", name, coverDigest(m.Hash), coverDigest(m.Expected))
+}
+
+// checkExpected removes the elements which do not match the manifest set in Expected (see QrElements.Expected), so
+// another copy of the same element is used, & lists them in the report
+func (elem *QrElements) checkExpected() []ManifestMismatch {
+    mismatches := make([]ManifestMismatch, 0)
+    if elem.Expected == nil {
+        return mismatches
+    }
+    hashes := make(map[uint64]string, len(elem.Expected.Chunks))
+    for _, chunk := range elem.Expected.Chunks {
+        hashes[chunk.Index] = chunk.Hash
+    }
+    kept := elem.Elements[:0]
+    for _, v := range elem.Elements {
+        hash := v.Hash()
+        if expected := hashes[v.Index]; hash != expected {
+            mismatches = append(mismatches, ManifestMismatch{File: v.source, Index: v.Index, Hash: hash, Expected: expected})
+            continue
+        }
+        kept = append(kept, v)
+    }
+    elem.Elements = kept
+    return mismatches
+}
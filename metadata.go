@@ -0,0 +1,163 @@
+package qrFile
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// A set can carry a few words about itself (see EncodeOptions.Metadata): who created it & when, a comment & the media
+// type of the data, so a stack of printed pages found years later tells what it holds without restoring it. Like the
+// description of the file (see FileInfo), the metadata is recorded in the manifest &, in plain format, in the header
+// of the first element (a field of type FieldTypeMetadata), so InspectFiles & the info command show it even without
+// the manifest. The texts are untrusted input when read back: they are checked to be short, printable UTF-8.
+
+// maxMetadataSize limits the encoded size of the metadata in the header of the first element, in bytes; the texts
+// share the room with the other fields (see maxFieldsSize)
+const maxMetadataSize = 160
+
+// Metadata describes a set; all fields are optional
+type Metadata struct {
+    Creator     string    `json:"creator,omitempty"`     // person or program which created the set
+    Created     time.Time `json:"created,omitempty"`     // time the set was created, to the second
+    Comment     string    `json:"comment,omitempty"`     // free-form text, may span several lines
+    ContentType string    `json:"contentType,omitempty"` // media type of the data, e.g. text/plain
+}
+
+// IsEmpty reports whether none of the fields is set
+func (m *Metadata) IsEmpty() bool {
+    return m == nil || (len(m.Creator) == 0 && m.Created.IsZero() && len(m.Comment) == 0 && len(m.ContentType) == 0)
+}
+
+// check validates the texts of the metadata: printable UTF-8, line breaks only in the comment, not longer than
+// maxMetadataSize in the header of an element
+func (m *Metadata) check() error {
+    for _, text := range []struct{ name, value string }{{"creator", m.Creator}, {"content type", m.ContentType}, {"comment", m.Comment}} {
+        if !utf8.ValidString(text.value) {
+            return errors.New(fmt.Sprintf("Invalid %s: not UTF-8", text.name))
+        }
+        for _, c := range text.value {
+            if (c < ' ' && !(c == '\n' && text.name == "comment")) || c == 0x7f {
+                return errors.New(fmt.Sprintf("Invalid %s %q: control characters are not allowed", text.name, text.value))
+            }
+        }
+    }
+    if size := len(m.marshal()); size > maxMetadataSize {
+        return errors.New(fmt.Sprintf("The metadata takes %d bytes, at most %d fit into the header of a code; shorten the comment", size, maxMetadataSize))
+    }
+    return nil
+}
+
+// marshal returns the value of the header field holding the metadata: the creation time (seconds since 1970, signed)
+// as varint, followed by creator, content type & comment, each preceded by its length as uvarint
+func (m *Metadata) marshal() []byte {
+    data := make([]byte, 0, 4*binary.MaxVarintLen64+len(m.Creator)+len(m.ContentType)+len(m.Comment))
+    var buf [binary.MaxVarintLen64]byte
+    var seconds int64
+    if !m.Created.IsZero() {
+        seconds = m.Created.Unix()
+    }
+    data = append(data, buf[:binary.PutVarint(buf[:], seconds)]...)
+    for _, text := range []string{m.Creator, m.ContentType, m.Comment} {
+        data = append(data, buf[:binary.PutUvarint(buf[:], uint64(len(text)))]...)
+        data = append(data, text...)
+    }
+    return data
+}
+
+// parseMetadata decodes the value of the header field holding the metadata (see Metadata.marshal)
+func parseMetadata(data []byte) (*Metadata, error) {
+    m := new(Metadata)
+    seconds, n := binary.Varint(data)
+    if n <= 0 {
+        return nil, errors.New("Malformed creation time")
+    }
+    data = data[n:]
+    if seconds != 0 {
+        m.Created = time.Unix(seconds, 0)
+    }
+    texts := make([]string, 3)
+    for i := range texts {
+        length, n := binary.Uvarint(data)
+        if n <= 0 || length > uint64(len(data)-n) {
+            return nil, errors.New("Malformed metadata text")
+        }
+        texts[i] = string(data[n : n+int(length)])
+        data = data[n+int(length):]
+    }
+    m.Creator, m.ContentType, m.Comment = texts[0], texts[1], texts[2]
+    if err := m.check(); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// String formats the metadata on one line, e.g. `created by alice, 2024-05-01 12:00:00 UTC, text/plain: "notes"`
+func (m *Metadata) String() string {
+    parts := make([]string, 0, 4)
+    if len(m.Creator) > 0 {
+        parts = append(parts, "created by "+m.Creator)
+    }
+    if !m.Created.IsZero() {
+        parts = append(parts, m.Created.UTC().Format("2006-01-02 15:04:05 MST"))
+    }
+    if len(m.ContentType) > 0 {
+        parts = append(parts, m.ContentType)
+    }
+    description := strings.Join(parts, ", ")
+    if len(m.Comment) > 0 {
+        if len(description) > 0 {
+            description += ": "
+        }
+        description += fmt.Sprintf("%q", m.Comment)
+    }
+    return description
+}
+
+// setMetadata records the metadata in the set & in the header of the first element (plain format only)
+func (elem *QrElements) setMetadata(m *Metadata) error {
+    if err := m.check(); err != nil {
+        return err
+    }
+    elem.Meta = m
+    for i := range elem.Elements {
+        if v := &elem.Elements[i]; v.Index == 0 && v.Version == VersionPlain {
+            v.Fields.Set(FieldTypeMetadata, m.marshal())
+            if size := len(v.Fields.Marshal()); size > maxFieldsSize {
+                return sizeError(uint64(size), maxFieldsSize, "The fields of the first element take %d bytes with the metadata, the maximum is %d; shorten the comment", size, maxFieldsSize)
+            }
+        }
+    }
+    return nil
+}
+
+// Metadata returns the metadata of the set: Meta if set, otherwise the one in the header of the first element; nil if
+// there is none
+func (elem *QrElements) Metadata() *Metadata {
+    if elem.Meta != nil {
+        return elem.Meta
+    }
+    for _, v := range elem.Elements {
+        if value, ok := v.Fields.Get(FieldTypeMetadata); ok && v.Index == 0 {
+            m, err := parseMetadata(value)
+            if err != nil {
+                elem.trace("ignoring the metadata of the set: %s", err)
+                return nil
+            }
+            return m
+        }
+    }
+    return nil
+}
+
+// metadataReserve returns the amount of characters the metadata takes in the header of the first element: the space
+// before the token of the fields, its prefix & the field hex encoded
+func metadataReserve(m *Metadata) uint64 {
+    if m.IsEmpty() {
+        return 0
+    }
+    return 1 + uint64(len(fieldsPrefix)) + 2*uint64(3+len(m.marshal()))
+}
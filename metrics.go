@@ -0,0 +1,83 @@
+package qrFile
+
+import (
+    "sync/atomic"
+    "time"
+)
+
+// Applications embedding the package export its measurements to their own monitoring (Prometheus, OpenTelemetry, ...)
+// by implementing Metrics & installing it with SetMetrics: counters of the elements encoded & decoded, the time each
+// image took to write or read (for histograms) & the number of workers rendering or reading images (a gauge). The
+// package itself depends on no monitoring library. Unlike an Observer, the Metrics apply to all sets of the process.
+// The methods are called by several goroutines at once, so implementations have to be safe for concurrent use.
+
+// Metrics receives measurements of encoding & decoding
+type Metrics interface {
+    ChunksEncoded(n int)          // n elements were split off data (see GetElementsWithOptions)
+    ChunksDecoded(n int)          // n elements were read from images or text
+    ImageWritten(d time.Duration) // an image was rendered & written in d (see WritePNGsTo)
+    ImageDecoded(d time.Duration) // an image file was read in d, including retries (see FromPNGs)
+    ActiveWorkers(delta int)      // the number of workers rendering or reading images changed by delta
+}
+
+// MetricsFuncs implements Metrics by calling the functions set; measurements without a function are ignored
+type MetricsFuncs struct {
+    Encoded func(n int)
+    Decoded func(n int)
+    Written func(d time.Duration)
+    Read    func(d time.Duration)
+    Workers func(delta int)
+}
+
+func (m MetricsFuncs) ChunksEncoded(n int) {
+    if m.Encoded != nil {
+        m.Encoded(n)
+    }
+}
+
+func (m MetricsFuncs) ChunksDecoded(n int) {
+    if m.Decoded != nil {
+        m.Decoded(n)
+    }
+}
+
+func (m MetricsFuncs) ImageWritten(d time.Duration) {
+    if m.Written != nil {
+        m.Written(d)
+    }
+}
+
+func (m MetricsFuncs) ImageDecoded(d time.Duration) {
+    if m.Read != nil {
+        m.Read(d)
+    }
+}
+
+func (m MetricsFuncs) ActiveWorkers(delta int) {
+    if m.Workers != nil {
+        m.Workers(delta)
+    }
+}
+
+// installedMetrics wraps the Metrics set by SetMetrics, as atomic.Value does not store nil
+type installedMetrics struct {
+    Metrics
+}
+
+var metricsValue atomic.Value
+
+// SetMetrics installs the Metrics receiving the measurements of all sets; nil stops the measurements
+func SetMetrics(m Metrics) {
+    if m == nil {
+        m = MetricsFuncs{}
+    }
+    metricsValue.Store(installedMetrics{m})
+}
+
+// metrics returns the Metrics installed by SetMetrics, or one ignoring all measurements
+func metrics() Metrics {
+    if m, ok := metricsValue.Load().(installedMetrics); ok {
+        return m.Metrics
+    }
+    return MetricsFuncs{}
+}
@@ -0,0 +1,128 @@
+// Package mobile provides a reduced qrFile API which can be exported with gomobile bind (https://golang.org/x/mobile/cmd/gomobile)
+// for Android and iOS apps. Only types supported by gomobile are used ([]byte, string, int, bool, error); no external tools
+// and no file paths are needed. The app scans codes with the camera and passes the decoded text to a Receiver.
+//
+//     gomobile bind -target=android github.com/Schokomuesl1/qrFile/mobile
+package mobile
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "github.com/Schokomuesl1/qrFile"
+    "image/png"
+)
+
+// Encoder converts data to a set of codes which can be shown one by one
+type Encoder struct {
+    elements *qrFile.QrElements
+}
+
+// NewEncoder prepares the codes for the given data
+func NewEncoder(data []byte) (*Encoder, error) {
+    return NewEncoderWithOptions(data, "L", false, 0)
+}
+
+// NewEncoderWithOptions prepares the codes for the given data with an error correction level (L, M, Q or H) & in
+// plain format (short codes without padding, chunkSize payload characters each; the default size if 0) if plain is set
+func NewEncoderWithOptions(data []byte, level string, plain bool, chunkSize int) (*Encoder, error) {
+    options := qrFile.EncodeOptions{Version: qrFile.VersionLegacy}
+    var err error
+    options.Level, err = qrFile.ParseLevel(level)
+    if err != nil {
+        return nil, err
+    }
+    if plain {
+        options.Version = qrFile.VersionPlain
+    }
+    if chunkSize < 0 {
+        return nil, errors.New(fmt.Sprintf("Invalid chunk size %d", chunkSize))
+    }
+    options.ChunkSize = uint64(chunkSize)
+    qrf := qrFile.FromBytes(data)
+    elements, err := qrFile.GetElementsWithOptions(qrf.ToHexString(), options)
+    if err != nil {
+        return nil, err
+    }
+    return &Encoder{elements: elements}, nil
+}
+
+// Count returns the number of codes in the set
+func (e *Encoder) Count() int {
+    return e.elements.Len()
+}
+
+// Chunk returns the text content of code i
+func (e *Encoder) Chunk(i int) (string, error) {
+    if i < 0 || i >= e.elements.Len() {
+        return "", errors.New(fmt.Sprintf("Chunk %d out of range (0-%d)", i, e.elements.Len()-1))
+    }
+    return e.elements.Elements[i].AsString(), nil
+}
+
+// PNG returns code i rendered as a png image
+func (e *Encoder) PNG(i int) ([]byte, error) {
+    if i < 0 || i >= e.elements.Len() {
+        return nil, errors.New(fmt.Sprintf("Chunk %d out of range (0-%d)", i, e.elements.Len()-1))
+    }
+    img, err := e.elements.Image(e.elements.Elements[i].Index)
+    if err != nil {
+        return nil, err
+    }
+    var encoded bytes.Buffer
+    err = png.Encode(&encoded, img)
+    if err != nil {
+        return nil, err
+    }
+    return encoded.Bytes(), nil
+}
+
+// Receiver collects the text of scanned codes and restores the data once all codes were seen
+type Receiver struct {
+    assembler *qrFile.Assembler
+}
+
+// NewReceiver creates an empty Receiver
+func NewReceiver() *Receiver {
+    return &Receiver{assembler: qrFile.NewAssembler()}
+}
+
+// Add passes the text of a scanned code to the receiver. Returns true if the code was not seen before.
+func (r *Receiver) Add(text string) (bool, error) {
+    return r.assembler.AddString(text)
+}
+
+// Received returns the number of distinct codes seen so far
+func (r *Receiver) Received() int {
+    return r.assembler.Len()
+}
+
+// Total returns the number of codes in the set, or 0 if no code (or sync frame of a stream) was seen yet
+func (r *Receiver) Total() int {
+    return int(r.assembler.Total())
+}
+
+// Status describes the progress of the transfer for display: codes received, transfer rates & the estimated remaining
+// time
+func (r *Receiver) Status() string {
+    return r.assembler.Stats().String()
+}
+
+// Complete reports whether all codes have been seen
+func (r *Receiver) Complete() bool {
+    return r.assembler.Complete()
+}
+
+// Data returns the restored data; fails if the set is not complete yet
+func (r *Receiver) Data() ([]byte, error) {
+    elements, err := r.assembler.Elements()
+    if err != nil {
+        return nil, err
+    }
+    qrf := qrFile.New()
+    err = elements.StoreData(qrf)
+    if err != nil {
+        return nil, err
+    }
+    return qrf.Data, nil
+}
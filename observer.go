@@ -0,0 +1,137 @@
+package qrFile
+
+import (
+    "io"
+)
+
+// Embedding applications can follow encoding & decoding (to drive their own UI, collect metrics or trigger side
+// effects) by setting an Observer in QrElements.Observer or EncodeOptions.Observer instead of wrapping every call.
+// Images are written & read by several goroutines, so an Observer has to be safe for concurrent use.
+
+// Observer is notified of the progress of encoding & decoding a set
+type Observer interface {
+    OnChunkEncoded(element QrElement)                // an element was split off the data (see GetElementsWithOptions)
+    OnImageWritten(index uint64, name string)        // the image of an element was written (see WritePNGsTo)
+    OnChunkDecoded(element QrElement, source string) // an element was read; source is the file, empty for text input
+    OnSetComplete(elements *QrElements)              // the set was checked & is complete (see Validate)
+    OnError(err error)                               // an image could not be written or read
+}
+
+// ProgressStage names the operation a Progress event belongs to
+type ProgressStage int
+
+const (
+    StageWriting   ProgressStage = iota // the images of the set are written (see WritePNGs)
+    StageReading                        // input files are read (see FromPNGs)
+    StageRestoring                      // the data is restored from the elements (see StoreData & WriteData)
+)
+
+// String returns a description of the stage, e.g. "reading images"
+func (s ProgressStage) String() string {
+    switch s {
+    case StageWriting:
+        return "writing images"
+    case StageReading:
+        return "reading images"
+    case StageRestoring:
+        return "restoring data"
+    }
+    return "unknown stage"
+}
+
+// Progress describes how far an operation on a set got, e.g. for a progress bar
+type Progress struct {
+    Stage ProgressStage
+    Done  int    // images written, input files handled or elements restored so far
+    Total int    // images to write, input files to handle or elements to restore
+    Bytes uint64 // bytes processed so far: of the images written, the input files read or the data restored
+}
+
+// Fraction returns the part of the operation done, from 0 to 1
+func (p Progress) Fraction() float64 {
+    if p.Total <= 0 {
+        return 1
+    }
+    return float64(p.Done) / float64(p.Total)
+}
+
+// ProgressObserver is an Observer which is notified of the progress of writing images, reading input files &
+// restoring the data as well; the Observer set in QrElements.Observer receives the events if it implements it. The
+// events of an operation are sent one at a time, in order, after each step.
+type ProgressObserver interface {
+    Observer
+    OnProgress(progress Progress)
+}
+
+// ObserverFuncs implements ProgressObserver by calling the functions set; events without a function are ignored
+type ObserverFuncs struct {
+    ChunkEncoded func(element QrElement)
+    ImageWritten func(index uint64, name string)
+    ChunkDecoded func(element QrElement, source string)
+    SetComplete  func(elements *QrElements)
+    Error        func(err error)
+    Progress     func(progress Progress)
+}
+
+func (o ObserverFuncs) OnChunkEncoded(element QrElement) {
+    if o.ChunkEncoded != nil {
+        o.ChunkEncoded(element)
+    }
+}
+
+func (o ObserverFuncs) OnImageWritten(index uint64, name string) {
+    if o.ImageWritten != nil {
+        o.ImageWritten(index, name)
+    }
+}
+
+func (o ObserverFuncs) OnChunkDecoded(element QrElement, source string) {
+    if o.ChunkDecoded != nil {
+        o.ChunkDecoded(element, source)
+    }
+}
+
+func (o ObserverFuncs) OnSetComplete(elements *QrElements) {
+    if o.SetComplete != nil {
+        o.SetComplete(elements)
+    }
+}
+
+func (o ObserverFuncs) OnError(err error) {
+    if o.Error != nil {
+        o.Error(err)
+    }
+}
+
+func (o ObserverFuncs) OnProgress(progress Progress) {
+    if o.Progress != nil {
+        o.Progress(progress)
+    }
+}
+
+// observer returns the Observer set in Observer, or one ignoring all events
+func (elem *QrElements) observer() Observer {
+    if elem.Observer == nil {
+        return ObserverFuncs{}
+    }
+    return elem.Observer
+}
+
+// progress notifies the Observer set of the progress of an operation, if it is a ProgressObserver
+func (elem *QrElements) progress(progress Progress) {
+    if o, ok := elem.observer().(ProgressObserver); ok {
+        o.OnProgress(progress)
+    }
+}
+
+// countingWriter counts the bytes written
+type countingWriter struct {
+    w io.Writer
+    n uint64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += uint64(n)
+    return n, err
+}
@@ -0,0 +1,573 @@
+package qrFile
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "log"
+    "log/slog"
+    "strconv"
+)
+
+// EncodeOptions controls how data is split into elements & how their images are rendered
+type EncodeOptions struct {
+    Version   int              // format version of the elements; VersionLegacy if 0
+    ChunkSize uint64           // payload characters per element (even, counted hex encoded as in QrElement.PayloadLength); the default of the format if 0
+    Count     uint64           // if set, the data is split into exactly this many elements of (almost) equal size instead (plain format only; at most one per byte of the data)
+    MaxCount  uint64           // if set, splitting fails if more elements would be needed
+    Level     Level            // error correction level of the images
+    Levels    map[uint64]Level // overrides Level for single elements (by index), see QrElements.Levels
+    Encoder   SymbolEncoder    // renders the images; the encoder of the symbology (see SymbologyEncoder) if nil
+    // Symbology selects the kind of code the elements are printed as (see symbology.go); it limits the chunk size to
+    // the capacity of a code of the symbology
+    Symbology Symbology
+    // SymbolVersion limits the QR codes to this version (1-40, see SymbolVersionCapacity) instead of the largest
+    // one, e.g. for codes of a given print size; unless ChunkSize is set, the chunk size fills a code of the version at
+    // the error correction level. QR codes only; the legacy format keeps its fixed width, so it needs a large version.
+    SymbolVersion int
+    // Codec encodes the payload in the codes (plain format only, see codec.go); with CodecBase64 & CodecBase45, the
+    // default chunk size holds more data while the codes keep their size
+    Codec PayloadCodec
+    // Integrity adds the CRC-32 of its payload to the header of each element & the SHA-256 of the data to the first
+    // one, verified when the set is read & restored (plain format only, see integrity.go)
+    Integrity bool
+    // File describes the original file of the data (see QrFile.Info), to restore it under its name; recorded in the
+    // manifest & in the header of the first element (plain format), see FileInfo
+    File *FileInfo
+    // Metadata describes the set: creator, creation time, comment & content type of the data; recorded in the manifest
+    // & in the header of the first element (plain format), see metadata.go
+    Metadata *Metadata
+    // Signer signs the data of the set (see QrElements.Sign); the signature is recorded in the manifest & in the header
+    // of the first element (plain format), see signature.go
+    Signer ed25519.PrivateKey
+    // Parity adds this many parity elements to the set, so it can be restored if as many elements are lost (plain
+    // format only, not with Count or Align; at most 256 elements in total), see parity.go
+    Parity uint64
+    // Transforms lists the transforms applied to the payload (see ApplyTransforms); recorded in the manifest & by name
+    // in the header of the first element (plain format), so the data is restored without the manifest
+    Transforms []TransformInfo
+    // Align starts a new element at each of these byte offsets of the data, e.g. at the entries of an archive (see
+    // QrFile.Boundaries), so a lost element only damages the files it holds; not possible with Count. This costs
+    // codes, since the last element of each file is not filled up.
+    Align []uint64
+    // StructuredAppend renders the codes as a QR Structured Append sequence in addition to the header of the format, so
+    // standard QR readers join them (at most 16 codes, internal encoder only), see structured.go
+    StructuredAppend bool
+    // Pad renders all codes in the QR version of the largest one, filling the smaller ones up with padding, so all codes
+    // of the set have the same size, e.g. to fill a grid of equal slots on paper (see QrElements.PadVersion). QR codes
+    // of an encoder implementing VersionEncoder only; readers see no difference.
+    Pad bool
+    // Observer is notified of each element created & set in the resulting QrElements (see observer.go)
+    Observer Observer
+    // Workers, Sequential, Logger & Trace are set in the resulting QrElements: the number of images rendered at the
+    // same time & where the steps of writing the set are logged (see QrElements)
+    Workers    int
+    Sequential bool
+    Logger     *slog.Logger
+    Trace      *log.Logger
+    // MaxFileSize limits the size of the file read by EncodeFile & is set in the resulting QrElements (see
+    // QrElements.MaxFileSize); DefaultMaxFileSize if 0, unlimited if negative
+    MaxFileSize int64
+}
+
+// check validates the options & returns the chunk size to use
+func (options EncodeOptions) check() (version int, chunkSize uint64, err error) {
+    version, chunkSize = options.Version, options.ChunkSize
+    if version == 0 {
+        version = VersionLegacy
+    }
+    if options.Level < LevelL || options.Level > LevelH {
+        return 0, 0, errors.New(fmt.Sprintf("Invalid error correction level %s", options.Level))
+    }
+    for index, level := range options.Levels {
+        if level < LevelL || level > LevelH {
+            return 0, 0, errors.New(fmt.Sprintf("Invalid error correction level %s for element %d", level, index))
+        }
+    }
+    if options.Codec < CodecHex || options.Codec > CodecBase45 {
+        return 0, 0, errors.New(fmt.Sprintf("Invalid payload codec %s", options.Codec))
+    }
+    if options.Codec == CodecBase45 {
+        // only the internal encoder stores the payload in alphanumeric mode
+        if _, ok := options.Encoder.(RscEncoder); options.Symbology != SymbologyQR || options.Encoder != nil && !ok {
+            return 0, 0, errors.New("The base45 codec requires QR codes rendered by the internal encoder")
+        }
+    }
+    if options.SymbolVersion != 0 {
+        if options.Symbology != SymbologyQR {
+            return 0, 0, errors.New(fmt.Sprintf("Symbol versions only apply to QR codes, not to %s codes", options.Symbology))
+        }
+        if SymbolVersionCapacity(options.SymbolVersion, LevelL) == 0 {
+            return 0, 0, errors.New(fmt.Sprintf("Invalid symbol version %d (1 to 40)", options.SymbolVersion))
+        }
+    }
+    if options.capacity(LevelL) == 0 {
+        return 0, 0, errors.New(fmt.Sprintf("Unknown symbology %s", options.Symbology))
+    }
+    switch version {
+    case VersionLegacy:
+        // the legacy format has a fixed width
+        if options.Codec != CodecHex {
+            return 0, 0, errors.New("Payload codecs only apply to the plain format")
+        }
+        if options.Integrity {
+            return 0, 0, errors.New("Integrity fields require the plain format")
+        }
+        if chunkSize != 0 && chunkSize != qrDataSize {
+            return 0, 0, errors.New(fmt.Sprintf("The legacy format only supports a chunk size of %d", qrDataSize))
+        }
+        if capacity := options.capacity(options.maxLevel()); uint64(capacity) < qrSize {
+            return 0, 0, sizeError(qrSize, uint64(capacity), "An element in legacy format exceeds the capacity of a %s at level %s (%d)", options.codeName(), options.maxLevel(), capacity)
+        }
+        return version, qrDataSize, nil
+    case VersionPlain:
+        max := options.plainMaxChunkSize(options.maxLevel())
+        if max == 0 {
+            return 0, 0, errors.New(fmt.Sprintf("A %s at level %s does not hold an element", options.codeName(), options.maxLevel()))
+        }
+        if chunkSize == 0 {
+            chunkSize = options.Codec.maxPayload(plainDataSize)
+            // a code of the version selected is filled; the default is reduced if the level leaves less room
+            if options.SymbolVersion != 0 || chunkSize > max {
+                chunkSize = max
+            }
+        }
+        if chunkSize%2 != 0 {
+            return 0, 0, errors.New(fmt.Sprintf("Invalid chunk size %d, needs to be even", chunkSize))
+        }
+        if chunkSize > max {
+            return 0, 0, sizeError(chunkSize, max, "Chunk size %d exceeds the capacity of a %s at level %s (%d)", chunkSize, options.codeName(), options.maxLevel(), max)
+        }
+        return version, chunkSize, nil
+    case VersionCompact:
+        if options.Codec != CodecHex {
+            return 0, 0, errors.New("Payload codecs only apply to the plain format")
+        }
+        if options.Integrity {
+            return 0, 0, errors.New("Integrity fields require the plain format")
+        }
+        max := options.compactMaxChunkSize(options.maxLevel())
+        if max == 0 {
+            return 0, 0, errors.New(fmt.Sprintf("A %s at level %s does not hold an element", options.codeName(), options.maxLevel()))
+        }
+        if chunkSize == 0 {
+            // the default fills a code of a smaller symbology
+            chunkSize = compactDataSize
+            if options.SymbolVersion != 0 || chunkSize > max {
+                chunkSize = max
+            }
+        }
+        if chunkSize%2 != 0 {
+            return 0, 0, errors.New(fmt.Sprintf("Invalid chunk size %d, needs to be even", chunkSize))
+        }
+        if chunkSize > max {
+            return 0, 0, sizeError(chunkSize, max, "Chunk size %d exceeds the capacity of a %s at level %s (%d)", chunkSize, options.codeName(), options.maxLevel(), max)
+        }
+        return version, chunkSize, nil
+    }
+    return 0, 0, errors.New(fmt.Sprintf("Unknown format version %d", version))
+}
+
+// GetElementsWithOptions works like GetElements, but uses the format, chunk size & rendering options given
+func GetElementsWithOptions(payload string, options EncodeOptions) (*QrElements, error) {
+    elements, err := splitPayload(payload, options)
+    if err != nil {
+        return nil, err
+    }
+    for _, v := range elements.Elements {
+        elements.observer().OnChunkEncoded(v)
+    }
+    metrics().ChunksEncoded(elements.Len())
+    return elements, nil
+}
+
+// splitPayload splits the payload into elements like GetElementsWithOptions, without notifying the observer & the
+// Metrics
+func splitPayload(payload string, options EncodeOptions) (*QrElements, error) {
+    version, chunkSize, err := options.check()
+    if err != nil {
+        return nil, err
+    }
+    var elements *QrElements
+    if options.Count > 0 {
+        if version != VersionPlain || options.ChunkSize != 0 || len(options.Align) > 0 {
+            return nil, errors.New("A count of codes requires the plain format, no chunk size and no alignment")
+        }
+        err = options.checkCount(uint64(len(payload)) / 2)
+        if err != nil {
+            return nil, err
+        }
+        if options.MaxCount > 0 && options.Count > options.MaxCount {
+            return nil, tooManyElementsError(options.Count, version, options)
+        }
+        elements, err = getElementsCount(payload, options.Count, options.plainSetMaxChunkSize(options.maxLevel(), options.Count), options.maxLevel())
+    } else {
+        // the offsets of Align are given in bytes, the payload is hex encoded
+        align := make([]uint64, len(options.Align))
+        for i, offset := range options.Align {
+            align[i] = 2 * offset
+        }
+        count := uint64(len(chunkStarts(uint64(len(payload)), chunkSize, align))) + options.Parity
+        if options.MaxCount > 0 && count > options.MaxCount {
+            return nil, tooManyElementsError(count, version, options)
+        }
+        err = options.checkSetSize(count, version, chunkSize)
+        if err != nil {
+            return nil, err
+        }
+        elements, err = getElements(payload, version, chunkSize, align)
+    }
+    if err == nil && options.Parity > 0 {
+        if version != VersionPlain || options.Count > 0 || len(options.Align) > 0 {
+            return nil, errors.New("Parity elements require the plain format, no count and no alignment")
+        }
+        err = elements.addParity(options.Parity, chunkSize)
+    }
+    if err == nil && options.File != nil {
+        err = elements.setFileInfo(options.File)
+    }
+    if err == nil && !options.Metadata.IsEmpty() {
+        err = elements.setMetadata(options.Metadata)
+    }
+    if err == nil && len(options.Transforms) > 0 {
+        elements.setTransforms(options.Transforms)
+    }
+    if err == nil && options.Integrity {
+        err = elements.AddIntegrity()
+    }
+    if err == nil && options.Signer != nil {
+        err = elements.Sign(options.Signer)
+    }
+    if err != nil {
+        return nil, err
+    }
+    elements.Level = options.Level
+    elements.Levels = options.Levels
+    elements.SymbolVersion = options.SymbolVersion
+    elements.Encoder = options.Encoder
+    if elements.Encoder == nil && options.Symbology != SymbologyQR {
+        elements.Encoder, err = SymbologyEncoder(options.Symbology)
+        if err != nil {
+            return nil, err
+        }
+    }
+    elements.Observer = options.Observer
+    elements.Workers = options.Workers
+    elements.Sequential = options.Sequential
+    elements.Logger = options.Logger
+    elements.Trace = options.Trace
+    elements.MaxFileSize = options.MaxFileSize
+    if options.StructuredAppend {
+        if options.Symbology != SymbologyQR {
+            return nil, errors.New(fmt.Sprintf("Structured Append is a feature of QR codes, not of %s codes", options.Symbology))
+        }
+        err = checkStructuredAppend(uint64(elements.Len()), elements.Encoder)
+        if err != nil {
+            return nil, err
+        }
+        elements.StructuredAppend = true
+    }
+    for i := range elements.Elements {
+        elements.Elements[i].Codec = options.Codec
+    }
+    if options.Pad {
+        err = elements.setPadVersion()
+        if err != nil {
+            return nil, err
+        }
+    }
+    return elements, nil
+}
+
+// EstimateCount returns the number of elements data of the given size (in bytes) is split into with the options,
+// without splitting any data; Align is not considered
+func EstimateCount(size uint64, options EncodeOptions) (uint64, error) {
+    _, chunkSize, err := options.check()
+    if err != nil {
+        return 0, err
+    }
+    if options.Count > 0 {
+        return options.Count, nil
+    }
+    return elementCount(2*size, chunkSize), nil
+}
+
+// checkCount checks the count of codes (see Count) for data of the given size in bytes before any element is
+// allocated: each code holds at least a byte of the data, only empty data is stored in a single empty code
+func (options EncodeOptions) checkCount(size uint64) error {
+    err := checkElementCount(options.Count)
+    if err != nil {
+        return err
+    }
+    limit := size
+    if limit == 0 {
+        limit = 1
+    }
+    if options.Count > limit {
+        return sizeError(options.Count, limit, "A count of %d codes is too large for %d bytes of data, each code holds at least one byte", options.Count, size)
+    }
+    return nil
+}
+
+// elementCount returns the number of elements a payload of the given length is split into; there is at least one
+func elementCount(length uint64, chunkSize uint64) uint64 {
+    count := (length + chunkSize - 1) / chunkSize
+    if count == 0 {
+        count = 1
+    }
+    return count
+}
+
+// maxLevel returns the highest error correction level used for any element; it limits the size of the elements
+func (options EncodeOptions) maxLevel() Level {
+    max := options.Level
+    for _, level := range options.Levels {
+        if level > max {
+            max = level
+        }
+    }
+    return max
+}
+
+// tooManyElementsError explains how to reduce the number of elements
+func tooManyElementsError(count uint64, version int, options EncodeOptions) error {
+    level := options.Level
+    hint := "use the compact format or the plain format with a larger chunk size"
+    switch version {
+    case VersionPlain:
+        hint = fmt.Sprintf("raise the chunk size (up to %d at level %s) or lower the error correction level", options.plainMaxChunkSize(level), level)
+    case VersionCompact:
+        hint = fmt.Sprintf("raise the chunk size (up to %d at level %s) or lower the error correction level", options.compactMaxChunkSize(level), level)
+    }
+    return sizeError(count, options.MaxCount, "The data needs %d codes, more than the maximum of %d. Compress the data, %s, or raise the maximum.", count, options.MaxCount, hint)
+}
+
+// plainHeaderReserve is the amount of characters reserved for the header of an element in plain format
+const plainHeaderReserve = 40
+
+// plainMaxChunkSize returns the largest (even) chunk size of an element in plain format fitting a single code, with the
+// payload encoded by the codec
+func plainMaxChunkSize(level Level, codec PayloadCodec) uint64 {
+    return codec.maxPayload(uint64(SymbolCapacity(level)) - plainHeaderReserve)
+}
+
+// plainPositionReserve is the amount of characters of plainHeaderReserve left for the position of an element
+// ("<number>/<count>"), i.e. numbers of up to 7 digits; the position of the elements of larger sets takes up to 21
+// characters, the rest is taken from the payload (see plainSetMaxChunkSize)
+const plainPositionReserve = 15
+
+// plainMaxChunkSize returns the largest chunk size of an element in plain format fitting a single code with the codec
+// & the fields of the options
+func (options EncodeOptions) plainMaxChunkSize(level Level) uint64 {
+    return options.Codec.maxPayload(remaining(options.capacity(level), plainHeaderReserve+options.fieldsReserve()))
+}
+
+// compactMaxChunkSize returns the largest chunk size of an element in compact format fitting a single code of the
+// symbology
+func (options EncodeOptions) compactMaxChunkSize(level Level) uint64 {
+    return compactChunkSize(uint64(options.capacity(level)))
+}
+
+// capacity returns the characters a single code of the symbology (& the version, if set) of the options holds at the
+// given level, less the Structured Append header if it is added
+func (options EncodeOptions) capacity(level Level) int {
+    capacity := options.Symbology.Capacity(level)
+    if options.SymbolVersion != 0 && options.Symbology == SymbologyQR {
+        capacity = SymbolVersionCapacity(options.SymbolVersion, level)
+    }
+    if options.StructuredAppend && capacity > 0 {
+        capacity -= structuredAppendReserve
+    }
+    return capacity
+}
+
+// codeName describes a code of the options in messages, e.g. "qr code of version 10"
+func (options EncodeOptions) codeName() string {
+    if options.SymbolVersion != 0 {
+        return fmt.Sprintf("%s code of version %d", options.Symbology, options.SymbolVersion)
+    }
+    return fmt.Sprintf("%s code", options.Symbology)
+}
+
+// plainSetMaxChunkSize returns plainMaxChunkSize for a set of count elements, whose position may take more characters
+// than reserved (see plainPositionReserve)
+func (options EncodeOptions) plainSetMaxChunkSize(level Level, count uint64) uint64 {
+    position := uint64(2*len(strconv.FormatUint(count, 10)) + 1)
+    if position <= plainPositionReserve {
+        return options.plainMaxChunkSize(level)
+    }
+    return options.Codec.maxPayload(remaining(options.capacity(level), plainHeaderReserve+options.fieldsReserve()+position-plainPositionReserve))
+}
+
+// remaining returns the characters of a code of the given capacity left after the reserve, 0 if the reserve takes all
+// of them (e.g. in codes of the smallest versions)
+func remaining(capacity int, reserve uint64) uint64 {
+    if uint64(capacity) <= reserve {
+        return 0
+    }
+    return uint64(capacity) - reserve
+}
+
+// checkSetSize checks that a set of count elements of the given chunk size can be read back: the count is limited to
+// maxElementCount & the headers of the elements of large sets take more room (see plainSetMaxChunkSize &
+// compactSetMaxChunkSize)
+func (options EncodeOptions) checkSetSize(count uint64, version int, chunkSize uint64) error {
+    err := checkElementCount(count)
+    if err != nil {
+        return err
+    }
+    level := options.maxLevel()
+    max := chunkSize
+    switch version {
+    case VersionPlain:
+        max = options.plainSetMaxChunkSize(level, count)
+    case VersionCompact:
+        max = compactSetMaxChunkSize(options.compactMaxChunkSize(level), count)
+    }
+    if chunkSize > max {
+        return sizeError(chunkSize, max, "Chunk size %d exceeds the capacity of a code of a set of %d codes at level %s (%d)", chunkSize, count, level, max)
+    }
+    return nil
+}
+
+// fieldsReserve returns the amount of characters reserved for the fields the options add to the header of the first
+// element in plain format
+func (options EncodeOptions) fieldsReserve() uint64 {
+    reserve := fileInfoReserve(options.File) + metadataReserve(options.Metadata) + transformsReserve(options.Transforms)
+    if options.Parity > 0 {
+        reserve += parityReserve
+    }
+    if options.Integrity {
+        reserve += integrityReserve
+    }
+    if options.Signer != nil {
+        reserve += signatureReserve
+    }
+    return reserve
+}
+
+// getElementsCount splits the payload into exactly count elements in plain format. The elements differ in size by one
+// byte (two characters) at most; if the payload is shorter than count bytes, the last elements are empty.
+func getElementsCount(payload string, count uint64, maxChunkSize uint64, level Level) (*QrElements, error) {
+    err := validatePayload(payload)
+    if err != nil {
+        return nil, err
+    }
+    size := uint64(len(payload)) / 2
+    chunk, extra := size/count, size%count
+    if extra > 0 && 2*(chunk+1) > maxChunkSize || 2*chunk > maxChunkSize {
+        needed := (uint64(len(payload)) + maxChunkSize - 1) / maxChunkSize
+        return nil, sizeError(needed, count, "Data too large for %d codes at level %s, at least %d codes are needed", count, level, needed)
+    }
+    elements := MakeQrElements(count)
+    setID := makeSetID(payload)
+    var pos uint64
+    for i := uint64(0); i < count; i++ {
+        length := 2 * chunk
+        if i < extra {
+            length += 2
+        }
+        // validated above; the elements hold the data, not the hex characters
+        part, _ := hex.DecodeString(payload[pos : pos+length])
+        pos += length
+        elements.Elements[i] = QrElement{Version: VersionPlain, SetID: setID, Index: i, MaxIndex: count - 1, PayloadLength: length, Payload: part}
+    }
+    return elements, nil
+}
+
+// Transcode restores the data of a complete set & splits it again using different options (e.g. a smaller chunk size
+// or a higher error correction level), without the original file. The data is preserved bit for bit.
+func (elem *QrElements) Transcode(options EncodeOptions) (*QrElements, error) {
+    err := elem.Validate()
+    if err != nil {
+        return nil, err
+    }
+    data := New()
+    err = elem.StoreRawData(data)
+    if err != nil {
+        return nil, err
+    }
+    if options.File == nil {
+        options.File = elem.FileInfo()
+    }
+    if options.Metadata == nil {
+        options.Metadata = elem.Metadata()
+    }
+    if options.Transforms == nil {
+        options.Transforms = elem.AppliedTransforms()
+    }
+    transcoded, err := GetElementsWithOptions(data.ToHexString(), options)
+    if err == nil && options.Signer == nil && elem.signature() != nil {
+        // the data is preserved, so its signature stays valid
+        err = transcoded.setSignature(elem.signature())
+    }
+    if err != nil {
+        return nil, err
+    }
+    transcoded.Decoder = elem.Decoder
+    transcoded.Transcribe = elem.Transcribe
+    transcoded.Digest = elem.Digest
+    transcoded.Fields = elem.Fields
+    return transcoded, nil
+}
+
+// ConversionReport describes the changes made by Convert
+type ConversionReport struct {
+    FromVersion int
+    ToVersion   int
+    FromCount   uint64 // number of elements before & after the conversion
+    ToCount     uint64
+    FromSetID   string // set ID before & after the conversion; empty if the format carries none
+    ToSetID     string
+    DataLength  uint64 // length of the data in bytes
+    DataHash    string // hex encoded SHA-256 of the data, identical before & after the conversion
+}
+
+// String describes the changes, one per line
+func (r *ConversionReport) String() string {
+    lines := fmt.Sprintf("format version: %d -> %d\ncodes: %d -> %d\n", r.FromVersion, r.ToVersion, r.FromCount, r.ToCount)
+    if r.FromSetID != r.ToSetID {
+        lines += fmt.Sprintf("set ID: %q -> %q\n", r.FromSetID, r.ToSetID)
+    }
+    return lines + fmt.Sprintf("data: %d bytes, sha256 %s (unchanged)", r.DataLength, r.DataHash)
+}
+
+// Convert transcodes a complete set (of any supported format version) to VersionCurrent. The options select chunk
+// size & rendering; their Version is ignored. The converted set is restored again & compared to the original data, so
+// the conversion is guaranteed to preserve the data bit for bit.
+func (elem *QrElements) Convert(options EncodeOptions) (*QrElements, *ConversionReport, error) {
+    options.Version = VersionCurrent
+    converted, err := elem.Transcode(options)
+    if err != nil {
+        return nil, nil, err
+    }
+    before, after := New(), New()
+    err = elem.StoreRawData(before)
+    if err != nil {
+        return nil, nil, err
+    }
+    err = converted.StoreRawData(after)
+    if err != nil {
+        return nil, nil, err
+    }
+    hashBefore, hashAfter := sha256.Sum256(before.Data), sha256.Sum256(after.Data)
+    if hashBefore != hashAfter {
+        return nil, nil, errors.New("Conversion changed the data")
+    }
+    report := &ConversionReport{
+        FromVersion: elem.Elements[0].Version,
+        ToVersion:   VersionCurrent,
+        FromCount:   uint64(elem.Len()),
+        ToCount:     uint64(converted.Len()),
+        FromSetID:   elem.SetID(),
+        ToSetID:     converted.SetID(),
+        DataLength:  uint64(len(before.Data)),
+        DataHash:    hex.EncodeToString(hashBefore[:]),
+    }
+    if report.FromVersion == 0 {
+        report.FromVersion = VersionLegacy
+    }
+    return converted, report, nil
+}
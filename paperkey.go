@@ -0,0 +1,293 @@
+package qrFile
+
+import (
+    "context"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "golang.org/x/crypto/scrypt"
+    "hash/crc32"
+    "image"
+    "image/color"
+    "image/draw"
+    "io"
+    "strings"
+)
+
+// Paper keys store a small secret (an SSH key, recovery codes...) in a single code, without the multi-chunk machinery.
+// The text of the code is armored:
+//
+//     QRF KEY <mode> <base64 data> <checksum>
+//
+// mode is P for plain data, E for data encrypted with a passphrase or K for data encrypted with a raw key (see
+// ParseKey); checksum is the CRC32 (8 hex characters) of the base64 data. Data encrypted with a passphrase consists of a
+// random salt, a random nonce and the AES-256-GCM ciphertext; the key is derived from the passphrase using scrypt. Data
+// encrypted with a raw key consists of a random nonce and the ciphertext.
+
+// paperKeyPrefix starts the text of every paper key
+const paperKeyPrefix = "QRF KEY "
+
+// Modes of a paper key
+const (
+    paperKeyPlain     = "P"
+    paperKeyEncrypted = "E"
+    paperKeyRawKey    = "K"
+)
+
+// PaperKeyMaxSize is the maximum size of a secret stored in a paper key; the armored text fits a single code at
+// paperKeyLevel.
+const PaperKeyMaxSize = 1024
+
+// paperKeyLevel is the error correction level used for paper keys; higher than for chunks, since the data can not be
+// recovered from other codes
+const paperKeyLevel = LevelQ
+
+// scrypt parameters & sizes of the encryption
+const (
+    paperKeyScryptN = 1 << 15
+    paperKeyScryptR = 8
+    paperKeyScryptP = 1
+    paperKeySalt    = 16
+    paperKeyKeySize = 32
+)
+
+// ErrPassphraseRequired is returned by DecodePaperKey (& EncryptTransform) if the paper key (or the data) is encrypted,
+// but no passphrase was given
+var ErrPassphraseRequired = errors.New("The data is encrypted, a passphrase is required")
+
+// ErrKeyRequired is returned by DecodePaperKey (& EncryptTransform) if the paper key (or the data) is encrypted with a
+// raw key (see DecodePaperKeyWithKey)
+var ErrKeyRequired = errors.New("The data is encrypted with a key, a key file is required")
+
+// EncodePaperKey returns the armored text of a paper key holding the secret. If passphrase is not empty, the secret is
+// encrypted.
+func EncodePaperKey(secret []byte, passphrase string) (string, error) {
+    if len(secret) > PaperKeyMaxSize {
+        return "", sizeError(uint64(len(secret)), PaperKeyMaxSize, "Secret too large for a paper key (%d bytes, maximum %d)", len(secret), PaperKeyMaxSize)
+    }
+    mode, data := paperKeyPlain, secret
+    if len(passphrase) > 0 {
+        salt := make([]byte, paperKeySalt)
+        _, err := io.ReadFull(rand.Reader, salt)
+        if err != nil {
+            return "", err
+        }
+        aead, err := paperKeyCipher(passphrase, salt)
+        if err != nil {
+            return "", err
+        }
+        nonce := make([]byte, aead.NonceSize())
+        _, err = io.ReadFull(rand.Reader, nonce)
+        if err != nil {
+            return "", err
+        }
+        data = append(append(salt, nonce...), aead.Seal(nil, nonce, secret, []byte(paperKeyPrefix))...)
+        mode = paperKeyEncrypted
+    }
+    encoded := base64.StdEncoding.EncodeToString(data)
+    return fmt.Sprintf("%s%s %s %08x", paperKeyPrefix, mode, encoded, crc32.ChecksumIEEE([]byte(encoded))), nil
+}
+
+// EncodePaperKeyWithKey returns the armored text of a paper key holding the secret encrypted with a raw key of KeySize
+// bytes (see ParseKey)
+func EncodePaperKeyWithKey(secret []byte, key []byte) (string, error) {
+    if len(secret) > PaperKeyMaxSize {
+        return "", sizeError(uint64(len(secret)), PaperKeyMaxSize, "Secret too large for a paper key (%d bytes, maximum %d)", len(secret), PaperKeyMaxSize)
+    }
+    aead, err := rawKeyCipher(key)
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    _, err = io.ReadFull(rand.Reader, nonce)
+    if err != nil {
+        return "", err
+    }
+    encoded := base64.StdEncoding.EncodeToString(append(nonce, aead.Seal(nil, nonce, secret, []byte(paperKeyPrefix))...))
+    return fmt.Sprintf("%s%s %s %08x", paperKeyPrefix, paperKeyRawKey, encoded, crc32.ChecksumIEEE([]byte(encoded))), nil
+}
+
+// IsPaperKey reports whether text (e.g. the text of a scanned code) is a paper key
+func IsPaperKey(text string) bool {
+    return strings.HasPrefix(strings.TrimSpace(text), paperKeyPrefix)
+}
+
+// DecodePaperKey restores the secret from the armored text of a paper key. Whitespace inserted into the base64 data
+// (e.g. by line breaks of a printed transcription) is ignored.
+func DecodePaperKey(text string, passphrase string) ([]byte, error) {
+    return decodePaperKey(text, passphrase, nil)
+}
+
+// DecodePaperKeyWithKey works like DecodePaperKey for paper keys encrypted with a raw key (see EncodePaperKeyWithKey)
+func DecodePaperKeyWithKey(text string, key []byte) ([]byte, error) {
+    return decodePaperKey(text, "", key)
+}
+
+// decodePaperKey restores the secret of a paper key using the passphrase or the raw key, as needed by its mode
+func decodePaperKey(text string, passphrase string, key []byte) ([]byte, error) {
+    if !IsPaperKey(text) {
+        return nil, errors.New("Not a paper key")
+    }
+    fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(text), paperKeyPrefix))
+    if len(fields) < 3 {
+        return nil, errors.New("Malformed paper key")
+    }
+    mode, checksum := fields[0], fields[len(fields)-1]
+    encoded := strings.Join(fields[1:len(fields)-1], "")
+    if fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(encoded))) != strings.ToLower(checksum) {
+        return nil, checksumError("Checksum mismatch in paper key")
+    }
+    data, err := base64.StdEncoding.DecodeString(encoded)
+    if err != nil {
+        return nil, fmt.Errorf("Malformed paper key: %w", err)
+    }
+    switch mode {
+    case paperKeyPlain:
+        return data, nil
+    case paperKeyEncrypted:
+        if len(passphrase) == 0 {
+            return nil, ErrPassphraseRequired
+        }
+        if len(data) < paperKeySalt {
+            return nil, errors.New("Malformed paper key")
+        }
+        aead, err := paperKeyCipher(passphrase, data[:paperKeySalt])
+        if err != nil {
+            return nil, err
+        }
+        data = data[paperKeySalt:]
+        if len(data) < aead.NonceSize() {
+            return nil, errors.New("Malformed paper key")
+        }
+        secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(paperKeyPrefix))
+        if err != nil {
+            return nil, errors.New("Unable to decrypt the paper key (wrong passphrase?)")
+        }
+        return secret, nil
+    case paperKeyRawKey:
+        if key == nil {
+            return nil, ErrKeyRequired
+        }
+        aead, err := rawKeyCipher(key)
+        if err != nil {
+            return nil, err
+        }
+        if len(data) < aead.NonceSize() {
+            return nil, errors.New("Malformed paper key")
+        }
+        secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(paperKeyPrefix))
+        if err != nil {
+            return nil, errors.New("Unable to decrypt the paper key (wrong key?)")
+        }
+        return secret, nil
+    }
+    return nil, errors.New(fmt.Sprintf("Unknown paper key mode %s", mode))
+}
+
+// rawKeyCipher returns the AES-256-GCM cipher for a raw key
+func rawKeyCipher(key []byte) (cipher.AEAD, error) {
+    if len(key) != KeySize {
+        return nil, errors.New(fmt.Sprintf("Invalid key size %d, expected %d bytes", len(key), KeySize))
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// paperKeyCipher derives the key from the passphrase & returns the AES-256-GCM cipher
+func paperKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+    key, err := scrypt.Key([]byte(passphrase), salt, paperKeyScryptN, paperKeyScryptR, paperKeyScryptP, paperKeyKeySize)
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// paperKeyLineLength is the amount of characters of the armored text printed per line on a paper key page
+const paperKeyLineLength = 64
+
+// PaperKeyPage renders a printable page for the armored text of a paper key: the title, the code (rendered by encoder,
+// DefaultEncoder if nil) and the armored text below, so the key can be typed in if the code can not be read.
+func PaperKeyPage(text string, title string, encoder SymbolEncoder) (image.Image, error) {
+    if encoder == nil {
+        encoder = DefaultEncoder
+    }
+    code, err := encoder.Encode(text, paperKeyLevel)
+    if err != nil {
+        return nil, err
+    }
+    // print the mode, the data & the checksum on separate lines, so no field is broken
+    fields := strings.Fields(strings.TrimPrefix(text, paperKeyPrefix))
+    if len(fields) != 3 {
+        return nil, errors.New("Malformed paper key")
+    }
+    lines := []string{paperKeyPrefix + fields[0]}
+    for data := fields[1]; len(data) > 0; {
+        n := paperKeyLineLength
+        if n > len(data) {
+            n = len(data)
+        }
+        lines = append(lines, data[:n])
+        data = data[n:]
+    }
+    lines = append(lines, fields[2])
+
+    bounds := code.Bounds()
+    scale := bounds.Dx() / (textWidth(strings.Repeat(" ", paperKeyLineLength), 1) + 4)
+    if scale < 1 {
+        scale = 1
+    }
+    margin := 4 * scale
+    width := bounds.Dx()
+    if w := textWidth(strings.Repeat(" ", paperKeyLineLength), scale) + 2*margin; w > width {
+        width = w
+    }
+    titleScale := 2 * scale
+    if w := textWidth(title, titleScale) + 2*margin; w > width {
+        width = w
+    }
+    codeTop := margin + glyphAdvanceY*titleScale
+    textTop := codeTop + bounds.Dy() + margin
+    page := image.NewRGBA(image.Rect(0, 0, width, textTop+len(lines)*glyphAdvanceY*scale+margin))
+    draw.Draw(page, page.Bounds(), image.White, image.ZP, draw.Src)
+    drawText(page, margin, margin, title, titleScale, color.Black)
+    draw.Draw(page, image.Rect((width-bounds.Dx())/2, codeTop, width, codeTop+bounds.Dy()), code, bounds.Min, draw.Src)
+    for i, line := range lines {
+        drawText(page, margin, textTop+i*glyphAdvanceY*scale, line, scale, color.Black)
+    }
+    return page, nil
+}
+
+// ReadPaperKey scans the paper key contained in an image file (see FromPNGs for supported formats) & restores the
+// secret. If decoder is nil, the default decoder is used.
+func ReadPaperKey(fname string, passphrase string, decoder Decoder) ([]byte, error) {
+    text, err := ScanPaperKey(fname, decoder)
+    if err != nil {
+        return nil, err
+    }
+    return DecodePaperKey(text, passphrase)
+}
+
+// ScanPaperKey returns the armored text of the paper key contained in an image file, to be decoded by DecodePaperKey or
+// DecodePaperKeyWithKey. If decoder is nil, the default decoder is used.
+func ScanPaperKey(fname string, decoder Decoder) (string, error) {
+    symbols, err := scanFile(context.Background(), fname, decoder)
+    if err != nil {
+        return "", err
+    }
+    for _, symbol := range symbols {
+        if IsPaperKey(symbol.Text) {
+            return symbol.Text, nil
+        }
+    }
+    return "", errors.New(fmt.Sprintf("No paper key found in %s", fname))
+}
@@ -0,0 +1,186 @@
+package qrFile
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "image"
+    "strings"
+)
+
+// A set can be accompanied by a parameter code (see QrElements.ParameterChunk): a code describing how the set was
+// encoded, i.e. the format version of the elements, the payload codec, the chunk size & the transforms applied to the
+// data (compression, encryption) with their parameters. Decoders pick it up among the images read & configure
+// themselves from it before the elements are checked & restored, so a set is restored without knowing the options it
+// was written with, and a set written by a newer version is rejected with a clear message instead of a list of
+// unreadable codes. Unlike the manifest, it is printed along with the codes, so it survives when only the paper does.
+
+// ParametersName is the name of the image of the parameter code written by WritePNGs (preceded by the file name prefix)
+const ParametersName = "params.png"
+
+// parametersPrefix starts the text of a parameter code
+const parametersPrefix = "QRF PARAMS "
+
+// parametersFormat is the layout of the parameter code written by this version; codes of a later layout are rejected
+const parametersFormat = 1
+
+// parametersLevel is the error correction level of the parameter code
+const parametersLevel = LevelM
+
+// Parameters describes how a set was encoded, as recorded in its parameter code (see ParameterText)
+type Parameters struct {
+    Format    int    `json:"format"`        // layout of the parameter code (parametersFormat)
+    Version   int    `json:"version"`       // format version of the elements
+    SetID     string `json:"set,omitempty"` // set ID of the elements, if their format carries one
+    Count     uint64 `json:"count"`         // number of elements in the complete set
+    ChunkSize uint64 `json:"chunk"`         // payload bytes per element (the last one may hold less)
+    Length    uint64 `json:"length"`        // total payload length of all elements, as recorded in the manifest
+    Codec     string `json:"codec,omitempty"`
+    // transforms applied to the data before it was split, in order, e.g. compression & encryption (see Transform)
+    Transforms []TransformInfo `json:"transforms,omitempty"`
+    PGP        *PGPInfo        `json:"pgp,omitempty"`    // set if the data needs to be decrypted using OpenPGP
+    Parity     uint64          `json:"parity,omitempty"` // number of parity elements among the elements (see parity.go)
+    Level      string          `json:"level,omitempty"`  // error correction level of the codes (L, M, Q or H)
+    // QR version the elements were sized for, if one was selected (see EncodeOptions.SymbolVersion)
+    SymbolVersion int `json:"symbolVersion,omitempty"`
+    // smallest QR version the codes are rendered in, if they are padded to the same size (see QrElements.PadVersion)
+    PadVersion int `json:"padVersion,omitempty"`
+}
+
+// parameters returns the parameters of the set, as recorded in its parameter code
+func (elem *QrElements) parameters() *Parameters {
+    manifest := elem.Manifest()
+    params := &Parameters{Format: parametersFormat, Version: manifest.Version, SetID: manifest.SetID, Count: manifest.Count,
+        Length: manifest.Length, Codec: manifest.Codec, Transforms: manifest.Transforms, PGP: manifest.PGP, Parity: manifest.Parity,
+        Level: elem.Level.String(), SymbolVersion: manifest.SymbolVersion, PadVersion: manifest.PadVersion}
+    for _, v := range elem.Elements {
+        if size := uint64(len(v.Payload)); size > params.ChunkSize {
+            params.ChunkSize = size
+        }
+    }
+    return params
+}
+
+// ParameterText returns the text of the parameter code of the set: "QRF PARAMS " followed by its parameters as compact
+// JSON. It fails if they do not fit a single code, e.g. with many recipients.
+func (elem *QrElements) ParameterText() (string, error) {
+    if elem.Len() == 0 {
+        return "", errors.New("No elements to describe")
+    }
+    data, err := json.Marshal(elem.parameters())
+    if err != nil {
+        return "", err
+    }
+    text := parametersPrefix + string(data)
+    if len(text) > SymbolCapacity(parametersLevel) {
+        return "", sizeError(uint64(len(text)), uint64(SymbolCapacity(parametersLevel)), "The parameters of the set (%d bytes) do not fit a single code", len(text))
+    }
+    return text, nil
+}
+
+// ParseParameters returns the parameters recorded in the text of a parameter code (see ParameterText)
+func ParseParameters(text string) (*Parameters, error) {
+    text = strings.TrimSpace(text)
+    if !strings.HasPrefix(text, parametersPrefix) {
+        return nil, errors.New("Not a parameter code")
+    }
+    params := new(Parameters)
+    err := json.Unmarshal([]byte(strings.TrimPrefix(text, parametersPrefix)), params)
+    if err != nil {
+        return nil, fmt.Errorf("Malformed parameter code: %w", err)
+    }
+    return params, nil
+}
+
+// check reports whether the set described can be read by this version of qrFile; nil parameters pass
+func (p *Parameters) check() error {
+    if p == nil {
+        return nil
+    }
+    if p.Format > parametersFormat {
+        return errors.New(fmt.Sprintf("The parameter code of the set has format %d, it was created by a newer version of qrFile", p.Format))
+    }
+    if p.Version < VersionLegacy || p.Version > VersionCompact {
+        return errors.New(fmt.Sprintf("The set was encoded in format %s, which this version of qrFile does not read; a newer version is needed", FormatName(p.Version)))
+    }
+    if len(p.Codec) > 0 {
+        if _, err := ParsePayloadCodec(p.Codec); err != nil {
+            return errors.New(fmt.Sprintf("The set was encoded with the payload codec %s, which this version of qrFile does not read", p.Codec))
+        }
+    }
+    return nil
+}
+
+// findParameters returns the parameters recorded in the first parameter code among symbols, if any
+func findParameters(symbols []Symbol) *Parameters {
+    for _, symbol := range symbols {
+        if !strings.HasPrefix(strings.TrimSpace(symbol.Text), parametersPrefix) {
+            continue
+        }
+        params, err := ParseParameters(symbol.Text)
+        if err != nil {
+            trace("Skipping parameter code: %s", err)
+            continue
+        }
+        return params
+    }
+    return nil
+}
+
+// useParameters configures the set read from the parameters found among the images: the PGP protection recorded is
+// taken over unless known already, the transforms are returned by AppliedTransforms unless given
+func (elem *QrElements) useParameters(params *Parameters) {
+    if params == nil {
+        return
+    }
+    if elem.Parameters != nil {
+        if elem.Parameters.SetID != params.SetID {
+            elem.trace("Ignoring the parameter code of set %q, the one of set %q was read already", params.SetID, elem.Parameters.SetID)
+        }
+        return
+    }
+    elem.trace("Read the parameter code: format %s, %d elements of %d bytes, transforms %v", FormatName(params.Version), params.Count, params.ChunkSize, params.Transforms)
+    elem.Parameters = params
+    if elem.PGP == nil {
+        elem.PGP = params.PGP
+    }
+}
+
+// renderParameters renders the parameter code of the set using the Encoder & the render options set
+func (elem *QrElements) renderParameters(ctx context.Context) (image.Image, error) {
+    text, err := elem.ParameterText()
+    if err != nil {
+        return nil, err
+    }
+    if elem.Rendering.isZero() {
+        return encodeSymbol(ctx, elem.encoder(), text, parametersLevel)
+    }
+    encoder, ok := elem.encoder().(MatrixEncoder)
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("The encoder %T does not support render options", elem.encoder()))
+    }
+    modules, err := encoder.EncodeMatrix(text, parametersLevel)
+    if err != nil {
+        return nil, err
+    }
+    return drawModules(modules, elem.Rendering)
+}
+
+// writeParameters writes the image of the parameter code to storage (see ParametersName)
+func (elem *QrElements) writeParameters(ctx context.Context, storage Storage, fnamePrefix string) error {
+    img, err := elem.renderParameters(ctx)
+    if err != nil {
+        return err
+    }
+    out, err := storage.Create(fnamePrefix + ParametersName)
+    if err != nil {
+        return err
+    }
+    err = encodePNG(out, img, elem.Rendering.dpi())
+    if err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
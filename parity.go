@@ -0,0 +1,284 @@
+package qrFile
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "sort"
+)
+
+// Parity elements make a set in plain format survive lost pages (see EncodeOptions.Parity): besides the elements
+// holding the data, the set gets parity elements, computed with a Reed-Solomon code (a Cauchy matrix over GF(2^8))
+// across the payloads of the data elements, so any of its elements, as many as there are data elements, restore the
+// set. The count in the header includes the parity elements ("3/12"), so the pages of a set are numbered through; the
+// data elements come first. Every element carries a field of type FieldTypeParity with the number of data elements,
+// their chunk size & the size of the data, so the length of a lost element is known. Validate reconstructs missing
+// elements if enough are present. The header fields of a lost first element (file description, SHA-256 of the data,
+// signature) are not restored; the manifest holds them as well.
+
+// maxParityCount limits the number of data & parity elements of a set with parity elements, to the size of GF(2^8)
+const maxParityCount = 256
+
+// parityReserve is the amount of characters the parity field takes in the header of every element: the space before
+// the token of the fields, its prefix & the field hex encoded
+const parityReserve uint64 = 1 + uint64(len(fieldsPrefix)) + 2*(2+3*binary.MaxVarintLen32)
+
+// parityInfo is the value of the parity field of an element
+type parityInfo struct {
+    data  uint64 // number of data elements
+    chunk uint64 // payload of a data element in bytes; the last one may be shorter
+    size  uint64 // size of the data in bytes
+}
+
+// marshal returns the value of the parity field: the numbers as varints
+func (p parityInfo) marshal() []byte {
+    data := make([]byte, 0, 3*binary.MaxVarintLen64)
+    var buf [binary.MaxVarintLen64]byte
+    for _, value := range []uint64{p.data, p.chunk, p.size} {
+        data = append(data, buf[:binary.PutUvarint(buf[:], value)]...)
+    }
+    return data
+}
+
+// parseParityInfo decodes the value of the parity field (see parityInfo.marshal)
+func parseParityInfo(value []byte) (parityInfo, error) {
+    var values [3]uint64
+    for i := range values {
+        number, n := binary.Uvarint(value)
+        if n <= 0 {
+            return parityInfo{}, errors.New("Malformed parity field")
+        }
+        values[i], value = number, value[n:]
+    }
+    info := parityInfo{data: values[0], chunk: values[1], size: values[2]}
+    if info.data == 0 || info.data > maxParityCount || info.size > info.data*info.chunk {
+        return parityInfo{}, errors.New(fmt.Sprintf("Invalid parity field: %d data elements of %d bytes, %d bytes of data", info.data, info.chunk, info.size))
+    }
+    return info, nil
+}
+
+// length returns the payload length in bytes of the element with the given index
+func (p parityInfo) length(index uint64) uint64 {
+    if index >= p.data {
+        return p.chunk
+    }
+    if start := index * p.chunk; start < p.size {
+        return min64(p.chunk, p.size-start)
+    }
+    return 0
+}
+
+// row returns the coefficients of the element with the given index, applied to the data elements: a unit row for data
+// elements, a row of the Cauchy matrix for parity elements
+func (p parityInfo) row(index uint64) []byte {
+    row := make([]byte, p.data)
+    if index < p.data {
+        row[index] = 1
+        return row
+    }
+    for k := range row {
+        row[k] = gfInverse(byte(index) ^ byte(k))
+    }
+    return row
+}
+
+// min64 returns the smaller of two numbers
+func min64(a uint64, b uint64) uint64 {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// gfExp & gfLog are the exponent & logarithm tables of GF(2^8) with the polynomial 0x11d
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+    x := 1
+    for i := 0; i < 255; i++ {
+        gfExp[i], gfExp[i+255] = byte(x), byte(x)
+        gfLog[x] = byte(i)
+        x <<= 1
+        if x&0x100 != 0 {
+            x ^= 0x11d
+        }
+    }
+}
+
+// gfMultiply multiplies two elements of GF(2^8)
+func gfMultiply(a byte, b byte) byte {
+    if a == 0 || b == 0 {
+        return 0
+    }
+    return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInverse returns the multiplicative inverse of an element of GF(2^8) other than 0
+func gfInverse(a byte) byte {
+    return gfExp[255-int(gfLog[a])]
+}
+
+// gfCombine returns the sum of the chunks, each multiplied by its coefficient
+func gfCombine(coefficients []byte, chunks [][]byte, length uint64) []byte {
+    result := make([]byte, length)
+    for i, c := range coefficients {
+        if c == 0 {
+            continue
+        }
+        for j, b := range chunks[i] {
+            result[j] ^= gfMultiply(c, b)
+        }
+    }
+    return result
+}
+
+// gfInvertMatrix inverts a square matrix over GF(2^8) by Gauss-Jordan elimination
+func gfInvertMatrix(matrix [][]byte) ([][]byte, error) {
+    n := len(matrix)
+    rows := make([][]byte, n)
+    for i := range matrix {
+        rows[i] = make([]byte, 2*n)
+        copy(rows[i], matrix[i])
+        rows[i][n+i] = 1
+    }
+    for col := 0; col < n; col++ {
+        pivot := col
+        for pivot < n && rows[pivot][col] == 0 {
+            pivot++
+        }
+        if pivot == n {
+            return nil, errors.New("Singular parity matrix")
+        }
+        rows[col], rows[pivot] = rows[pivot], rows[col]
+        inverse := gfInverse(rows[col][col])
+        for j := range rows[col] {
+            rows[col][j] = gfMultiply(rows[col][j], inverse)
+        }
+        for r := range rows {
+            if factor := rows[r][col]; r != col && factor != 0 {
+                for j := range rows[r] {
+                    rows[r][j] ^= gfMultiply(factor, rows[col][j])
+                }
+            }
+        }
+    }
+    for i := range rows {
+        rows[i] = rows[i][n:]
+    }
+    return rows, nil
+}
+
+// addParity appends count parity elements to a complete set in plain format split into chunks of chunkSize characters
+func (elem *QrElements) addParity(count uint64, chunkSize uint64) error {
+    dataCount := uint64(elem.Len())
+    if dataCount+count > maxParityCount {
+        return sizeError(dataCount+count, maxParityCount, "Parity elements are limited to sets of %d codes, %d data and %d parity codes requested; raise the chunk size", maxParityCount, dataCount, count)
+    }
+    info := parityInfo{data: dataCount, chunk: chunkSize / 2}
+    chunks := make([][]byte, dataCount)
+    for i, v := range elem.Elements {
+        chunks[i] = v.Payload
+        info.size += uint64(len(v.Payload))
+    }
+    for i, chunk := range chunks {
+        if uint64(len(chunk)) != info.length(uint64(i)) {
+            return errors.New("Parity elements require chunks of equal size")
+        }
+    }
+    first := elem.Elements[0]
+    for index := dataCount; index < dataCount+count; index++ {
+        payload := gfCombine(info.row(index), chunks, info.chunk)
+        elem.Elements = append(elem.Elements, QrElement{Version: VersionPlain, SetID: first.SetID, Index: index, PayloadLength: 2 * uint64(len(payload)), Payload: payload})
+    }
+    for i := range elem.Elements {
+        elem.Elements[i].MaxIndex = dataCount + count - 1
+        elem.Elements[i].Fields.Set(FieldTypeParity, info.marshal())
+    }
+    return nil
+}
+
+// parity returns the value of the parity field of the set; ok is false if the set has no parity elements
+func (elem *QrElements) parity() (info parityInfo, ok bool) {
+    for _, v := range elem.Elements {
+        if value, found := v.Fields.Get(FieldTypeParity); found {
+            info, err := parseParityInfo(value)
+            if err != nil {
+                elem.trace("ignoring the parity field of element %d: %s", v.Index, err)
+                continue
+            }
+            return info, true
+        }
+    }
+    return parityInfo{}, false
+}
+
+// dataCount returns the number of elements of the set holding data: all but the parity elements
+func (elem *QrElements) dataCount() uint64 {
+    if info, ok := elem.parity(); ok {
+        return info.data
+    }
+    if elem.Len() == 0 {
+        return 0
+    }
+    return elem.Elements[0].MaxIndex + 1
+}
+
+// reconstruct restores the missing elements of a sorted set without duplicates from its parity elements, if enough
+// elements are present, & returns their indices
+func (elem *QrElements) reconstruct() ([]uint64, error) {
+    info, ok := elem.parity()
+    total := elem.Elements[0].MaxIndex + 1
+    if !ok || uint64(elem.Len()) >= total {
+        return nil, nil
+    }
+    if total > maxParityCount || info.data >= total {
+        return nil, errors.New(fmt.Sprintf("Invalid parity field: %d data elements in a set of %d", info.data, total))
+    }
+    if uint64(elem.Len()) < info.data {
+        return nil, &IncompleteError{Found: elem.Len(), Total: total, Needed: info.data, Missing: elem.Missing()}
+    }
+    // the data is a linear combination of any info.data elements
+    matrix := make([][]byte, info.data)
+    chunks := make([][]byte, info.data)
+    for i, v := range elem.Elements[:info.data] {
+        chunk := v.Payload
+        if uint64(len(chunk)) != info.length(v.Index) {
+            return nil, errors.New(fmt.Sprintf("Element %d does not match the parity field of the set", v.Index+1))
+        }
+        matrix[i], chunks[i] = info.row(v.Index), chunk
+    }
+    inverse, err := gfInvertMatrix(matrix)
+    if err != nil {
+        return nil, err
+    }
+    data := make([][]byte, info.data)
+    for k := range data {
+        data[k] = gfCombine(inverse[k], chunks, info.chunk)[:info.length(uint64(k))]
+    }
+    present := make(map[uint64]bool, elem.Len())
+    for _, v := range elem.Elements {
+        present[v.Index] = true
+    }
+    first := elem.Elements[0]
+    _, checksums := first.Fields.Get(FieldTypeCRC32)
+    restored := make([]uint64, 0, total-uint64(elem.Len()))
+    for index := uint64(0); index < total; index++ {
+        if present[index] {
+            continue
+        }
+        chunk := gfCombine(info.row(index), data, info.chunk)[:info.length(index)]
+        v := QrElement{Version: first.Version, SetID: first.SetID, Index: index, MaxIndex: first.MaxIndex, PayloadLength: 2 * uint64(len(chunk)), Payload: chunk, Codec: first.Codec}
+        v.Fields.Set(FieldTypeParity, info.marshal())
+        if checksums {
+            var checksum [crc32.Size]byte
+            binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(chunk))
+            v.Fields.Set(FieldTypeCRC32, checksum[:])
+        }
+        elem.Elements = append(elem.Elements, v)
+        restored = append(restored, index)
+    }
+    sort.Stable(elem)
+    return restored, nil
+}
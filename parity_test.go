@@ -0,0 +1,87 @@
+package qrFile
+
+import (
+    "bytes"
+    "errors"
+    "math/rand"
+    "testing"
+)
+
+// parityTexts splits random data into a set of 24 data & 4 parity elements & returns the data & the texts of the codes
+func parityTexts(t *testing.T) ([]byte, []string) {
+    data := make([]byte, 2345)
+    rand.New(rand.NewSource(1)).Read(data)
+    qrf := New()
+    qrf.Data = data
+    elements, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: VersionPlain, ChunkSize: 200, Parity: 4})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if elements.Len() != 24+4 {
+        t.Fatalf("%d elements, expected 28", elements.Len())
+    }
+    texts := make([]string, elements.Len())
+    for i, v := range elements.Elements {
+        texts[i] = v.AsString()
+    }
+    return data, texts
+}
+
+// withoutElements returns the texts without the ones of the given indices
+func withoutElements(texts []string, lost []int) []string {
+    drop := make(map[int]bool)
+    for _, i := range lost {
+        drop[i] = true
+    }
+    kept := make([]string, 0, len(texts))
+    for i, text := range texts {
+        if !drop[i] {
+            kept = append(kept, text)
+        }
+    }
+    return kept
+}
+
+// TestParityRecovery restores a set with up to Parity elements missing, data or parity elements
+func TestParityRecovery(t *testing.T) {
+    data, texts := parityTexts(t)
+    cases := []struct {
+        name string
+        lost []int
+    }{
+        {"complete", nil},
+        {"one data element", []int{23}},
+        {"data & parity elements", []int{0, 5, 23, 27}},
+        {"adjacent data elements", []int{1, 2, 3, 4}},
+        {"all parity elements", []int{24, 25, 26, 27}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            read := MakeQrElements(0)
+            read.Report = &DecodeReport{}
+            if err := read.ImportStrings(withoutElements(texts, c.lost)); err != nil {
+                t.Fatal(err)
+            }
+            if len(read.Report.Reconstructed) != len(c.lost) {
+                t.Fatalf("reconstructed %v, lost %v", read.Report.Reconstructed, c.lost)
+            }
+            restored := New()
+            if err := read.StoreData(restored); err != nil {
+                t.Fatal(err)
+            }
+            if !bytes.Equal(restored.Data, data) {
+                t.Fatalf("restored %d bytes differing from the %d bytes of the data", len(restored.Data), len(data))
+            }
+        })
+    }
+}
+
+// TestParityTooManyMissing fails cleanly if more elements are missing than there are parity elements
+func TestParityTooManyMissing(t *testing.T) {
+    _, texts := parityTexts(t)
+    read := MakeQrElements(0)
+    err := read.ImportStrings(withoutElements(texts, []int{0, 5, 10, 15, 20}))
+    if !errors.Is(err, ErrIncompleteSet) {
+        t.Fatalf("5 elements missing: %v", err)
+    }
+}
@@ -0,0 +1,37 @@
+package qrFile
+
+import (
+    "errors"
+    "testing"
+)
+
+// FuzzParseString feeds arbitrary text to ParseString: it must not panic, fail with a *ParseError only & accept only
+// elements which print as text parsed to the same element. The seeds in testdata/fuzz/FuzzParseString cover every
+// format: valid elements, truncated headers, multi-byte UTF-8 in header & payload, line breaks within the payload.
+func FuzzParseString(f *testing.F) {
+    for _, version := range []int{VersionLegacy, VersionPlain, VersionCompact} {
+        elements, err := GetElementsWithOptions("00ff10aa7f", EncodeOptions{Version: version})
+        if err != nil {
+            f.Fatal(err)
+        }
+        f.Add(elements.Elements[0].AsString())
+    }
+    f.Fuzz(func(t *testing.T, text string) {
+        var element QrElement
+        err := element.ParseString(text)
+        if err != nil {
+            var parseErr *ParseError
+            if !errors.As(err, &parseErr) {
+                t.Fatalf("%q: %T %v", text, err, err)
+            }
+            return
+        }
+        var again QrElement
+        if err := again.ParseString(element.AsString()); err != nil {
+            t.Fatalf("%q: parsed element does not parse again: %v", text, err)
+        }
+        if again.AsString() != element.AsString() {
+            t.Fatalf("%q: printed as %q, then as %q", text, element.AsString(), again.AsString())
+        }
+    })
+}
@@ -0,0 +1,97 @@
+package qrFile
+
+import (
+    "fmt"
+    "path"
+    "strings"
+)
+
+// Include & exclude patterns (see ArchiveOptions) follow the rules of .gitignore files: a pattern without a slash
+// matches the name of an entry at any depth (e.g. *.o or node_modules), a pattern containing a slash matches the path
+// relative to the archived directory (e.g. /build or docs/*.md), ** matches any number of directories (e.g. **/cache)
+// & a trailing slash matches directories only. A pattern matching a directory applies to everything below it. Later
+// patterns take precedence; a leading ! negates a pattern, e.g. *.log followed by !keep.log.
+
+// checkPatterns returns an error for the first malformed pattern
+func checkPatterns(patterns []string) error {
+    for _, pattern := range patterns {
+        for _, segment := range strings.Split(strings.TrimPrefix(pattern, "!"), "/") {
+            if _, err := path.Match(segment, ""); err != nil {
+                return fmt.Errorf("Invalid pattern %q: %w", pattern, err)
+            }
+        }
+    }
+    return nil
+}
+
+// matchPatterns reports whether the path name (slash separated, relative to the archived directory) or one of its
+// parent directories is matched by the patterns, & returns the pattern deciding so
+func matchPatterns(patterns []string, name string, dir bool) (string, bool) {
+    decisive, matched := "", false
+    for _, pattern := range patterns {
+        negated := strings.HasPrefix(pattern, "!")
+        if matchPath(strings.TrimPrefix(pattern, "!"), name, dir) {
+            decisive, matched = pattern, !negated
+        }
+    }
+    return decisive, matched
+}
+
+// namedBy reports whether one of the patterns (not negated) matches the path name itself, not only a parent directory
+func namedBy(patterns []string, name string, dir bool) bool {
+    for _, pattern := range patterns {
+        if !strings.HasPrefix(pattern, "!") && matchPattern(pattern, name, dir) {
+            return true
+        }
+    }
+    return false
+}
+
+// matchPath reports whether a single pattern matches the path name or one of its parent directories
+func matchPath(pattern string, name string, dir bool) bool {
+    for {
+        if matchPattern(pattern, name, dir) {
+            return true
+        }
+        i := strings.LastIndex(name, "/")
+        if i < 0 {
+            return false
+        }
+        name, dir = name[:i], true
+    }
+}
+
+// matchPattern reports whether a single pattern matches the path name itself
+func matchPattern(pattern string, name string, dir bool) bool {
+    if strings.HasSuffix(pattern, "/") {
+        if !dir {
+            return false
+        }
+        pattern = strings.TrimSuffix(pattern, "/")
+    }
+    if !strings.Contains(pattern, "/") {
+        ok, _ := path.Match(pattern, path.Base(name))
+        return ok
+    }
+    return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the segments of a pattern against the segments of a path; ** matches any number of segments
+func matchSegments(pattern []string, name []string) bool {
+    if len(pattern) == 0 {
+        return len(name) == 0
+    }
+    if pattern[0] == "**" {
+        for i := 0; i <= len(name); i++ {
+            if matchSegments(pattern[1:], name[i:]) {
+                return true
+            }
+        }
+        return false
+    }
+    if len(name) == 0 {
+        return false
+    }
+    ok, _ := path.Match(pattern[0], name[0])
+    return ok && matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,184 @@
+package qrFile
+
+import (
+    "bytes"
+    "compress/zlib"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "io"
+    "strings"
+)
+
+// A minimal PDF writer for printable pages: text in the standard fonts (which every PDF reader provides, so nothing is
+// embedded) & grayscale images. Pages are A4 portrait unless the document selects another PageSize; coordinates are in
+// points from the bottom left corner.
+
+// Size of an A4 page in points
+const (
+    pdfPageWidth  = 595
+    pdfPageHeight = 842
+)
+
+// PageSize is the size of a printed page in points (1/72 inch), portrait
+type PageSize struct {
+    Width  int
+    Height int
+}
+
+// Page sizes supported by name (see ParsePageSize)
+var (
+    PageA4     = PageSize{Width: pdfPageWidth, Height: pdfPageHeight}
+    PageLetter = PageSize{Width: 612, Height: 792}
+)
+
+// ParsePageSize returns the page size with the given name: "a4" or "letter" (case insensitive)
+func ParsePageSize(name string) (PageSize, error) {
+    switch strings.ToLower(name) {
+    case "a4":
+        return PageA4, nil
+    case "letter":
+        return PageLetter, nil
+    }
+    return PageSize{}, errors.New(fmt.Sprintf("Unknown page size %s, expected a4 or letter", name))
+}
+
+// Fonts available on every page, by resource name
+const (
+    pdfFontRegular = "F1" // Helvetica
+    pdfFontBold    = "F2" // Helvetica-Bold
+    pdfFontMono    = "F3" // Courier
+)
+
+// pdfMonoAdvance is the width of a Courier character in units of the font size
+const pdfMonoAdvance = 0.6
+
+// pdfDocument collects the pages of a document
+type pdfDocument struct {
+    size  PageSize // PageA4 if zero
+    pages []*pdfPage
+}
+
+// pdfPage holds the content stream & the images of a page
+type pdfPage struct {
+    content bytes.Buffer
+    images  []image.Image
+}
+
+// newPage adds an empty page to the document
+func (d *pdfDocument) newPage() *pdfPage {
+    page := new(pdfPage)
+    d.pages = append(d.pages, page)
+    return page
+}
+
+// text draws a line of text with its baseline starting at (x, y)
+func (p *pdfPage) text(x float64, y float64, font string, size float64, text string) {
+    fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+// image draws img scaled to a square of size points with its bottom left corner at (x, y)
+func (p *pdfPage) image(x float64, y float64, size float64, img image.Image) {
+    p.images = append(p.images, img)
+    fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", size, size, x, y, len(p.images))
+}
+
+// rect strokes a rectangle with its bottom left corner at (x, y)
+func (p *pdfPage) rect(x float64, y float64, width float64, height float64) {
+    fmt.Fprintf(&p.content, "0.5 w %.2f %.2f %.2f %.2f re S\n", x, y, width, height)
+}
+
+// pdfEscape escapes a string for a PDF string literal; characters outside of printable ASCII are replaced by '?'
+func pdfEscape(text string) string {
+    var escaped strings.Builder
+    for _, r := range text {
+        switch {
+        case r == '\\' || r == '(' || r == ')':
+            escaped.WriteByte('\\')
+            escaped.WriteRune(r)
+        case r < 0x20 || r > 0x7e:
+            escaped.WriteByte('?')
+        default:
+            escaped.WriteRune(r)
+        }
+    }
+    return escaped.String()
+}
+
+// pdfGrayImage returns the pixels of img as 8 bit gray values, row by row, compressed with zlib (FlateDecode)
+func pdfGrayImage(img image.Image) ([]byte, error) {
+    var compressed bytes.Buffer
+    writer := zlib.NewWriter(&compressed)
+    bounds := img.Bounds()
+    row := make([]byte, bounds.Dx())
+    for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+        for x := bounds.Min.X; x < bounds.Max.X; x++ {
+            row[x-bounds.Min.X] = color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+        }
+        writer.Write(row)
+    }
+    err := writer.Close()
+    if err != nil {
+        return nil, err
+    }
+    return compressed.Bytes(), nil
+}
+
+// pageSize returns the size of the pages of the document
+func (d *pdfDocument) pageSize() PageSize {
+    if d.size.Width <= 0 || d.size.Height <= 0 {
+        return PageA4
+    }
+    return d.size
+}
+
+// write writes the document as PDF
+func (d *pdfDocument) write(w io.Writer) error {
+    size := d.pageSize()
+    // objects are numbered from 1: the catalog, the page tree & the fonts come first
+    objects := [][]byte{nil, nil}
+    add := func(object string) int {
+        objects = append(objects, []byte(object))
+        return len(objects)
+    }
+    fonts := fmt.Sprintf("/%s %d 0 R /%s %d 0 R /%s %d 0 R", pdfFontRegular, add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"),
+        pdfFontBold, add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"),
+        pdfFontMono, add("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"))
+    kids := make([]string, 0, len(d.pages))
+    for _, page := range d.pages {
+        images := ""
+        for i, img := range page.images {
+            data, err := pdfGrayImage(img)
+            if err != nil {
+                return err
+            }
+            bounds := img.Bounds()
+            id := add(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Interpolate false /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+                bounds.Dx(), bounds.Dy(), len(data), data))
+            images += fmt.Sprintf("/Im%d %d 0 R ", i+1, id)
+        }
+        content := add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", page.content.Len(), page.content.Bytes()))
+        id := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> /XObject << %s>> >> /Contents %d 0 R >>",
+            size.Width, size.Height, fonts, images, content))
+        kids = append(kids, fmt.Sprintf("%d 0 R", id))
+    }
+    objects[0] = []byte("<< /Type /Catalog /Pages 2 0 R >>")
+    objects[1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+    var out bytes.Buffer
+    out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+    offsets := make([]int, len(objects))
+    for i, object := range objects {
+        offsets[i] = out.Len()
+        fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+    }
+    xref := out.Len()
+    fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+    _, err := w.Write(out.Bytes())
+    return err
+}
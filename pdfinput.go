@@ -0,0 +1,564 @@
+package qrFile
+
+import (
+    "bytes"
+    "compress/zlib"
+    "encoding/ascii85"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "io"
+    "io/ioutil"
+    "regexp"
+    "sort"
+    "strconv"
+)
+
+// Reading scanned PDF documents: scanners store each page as an image, so the images of a PDF are extracted & decoded
+// like the pages of a multipage TIFF; the codes on a page are found by the decoder, however many there are. Only what
+// scanners produce is supported: image XObjects compressed with DCTDecode (JPEG), FlateDecode (with or without PNG
+// predictors), LZWDecode or not at all, in gray, RGB or CMYK with 1 or 8 bits per component, as well as the sheets
+// written by WritePDF. Vector graphics & images encoded with CCITTFaxDecode or JBIG2Decode (black & white scans of some
+// scanners) are not read; scan in gray or color for such documents. Images referenced as masks of others are skipped.
+
+// pdfMaxImages limits the number of images read from a single file
+const pdfMaxImages = 65536
+
+// pdfObjectPattern matches the start of an indirect object
+var pdfObjectPattern = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+// pdfName is a name object, without the slash
+type pdfName string
+
+// pdfRef is a reference to an indirect object by number
+type pdfRef int
+
+// pdfFile holds a PDF document & the positions of its indirect objects
+type pdfFile struct {
+    data    []byte
+    objects map[int]int // object number to the position after "obj"
+}
+
+// pdfParser reads objects from a position of a PDF document
+type pdfParser struct {
+    data []byte
+    pos  int
+}
+
+// pdfDelimiter reports whether c ends a name or a keyword
+func pdfDelimiter(c byte) bool {
+    return bytes.IndexByte([]byte(" \t\r\n\f\x00()<>[]{}/%"), c) >= 0
+}
+
+// skipSpace skips white space & comments
+func (p *pdfParser) skipSpace() {
+    for p.pos < len(p.data) {
+        switch c := p.data[p.pos]; {
+        case c == '%':
+            for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+                p.pos++
+            }
+        case bytes.IndexByte([]byte(" \t\r\n\f\x00"), c) >= 0:
+            p.pos++
+        default:
+            return
+        }
+    }
+}
+
+// token returns the next name, number or keyword without consuming it
+func (p *pdfParser) token() string {
+    p.skipSpace()
+    end := p.pos
+    for end < len(p.data) && (end == p.pos || !pdfDelimiter(p.data[end])) {
+        end++
+    }
+    return string(p.data[p.pos:end])
+}
+
+// value parses the next object: a dictionary (map[string]interface{}), array ([]interface{}), name, number
+// (float64), reference, string ([]byte), bool or null (nil)
+func (p *pdfParser) value(depth int) (interface{}, error) {
+    if depth > 32 {
+        return nil, errors.New("PDF objects nested too deeply")
+    }
+    p.skipSpace()
+    if p.pos >= len(p.data) {
+        return nil, errors.New("Unexpected end of PDF data")
+    }
+    switch c := p.data[p.pos]; {
+    case bytes.HasPrefix(p.data[p.pos:], []byte("<<")):
+        p.pos += 2
+        dict := make(map[string]interface{})
+        for {
+            p.skipSpace()
+            if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+                p.pos += 2
+                return dict, nil
+            }
+            key, err := p.value(depth + 1)
+            if err != nil {
+                return nil, err
+            }
+            name, ok := key.(pdfName)
+            if !ok {
+                return nil, errors.New(fmt.Sprintf("Invalid PDF dictionary key at %d", p.pos))
+            }
+            dict[string(name)], err = p.value(depth + 1)
+            if err != nil {
+                return nil, err
+            }
+        }
+    case c == '[':
+        p.pos++
+        array := make([]interface{}, 0)
+        for {
+            p.skipSpace()
+            if p.pos < len(p.data) && p.data[p.pos] == ']' {
+                p.pos++
+                return array, nil
+            }
+            item, err := p.value(depth + 1)
+            if err != nil {
+                return nil, err
+            }
+            array = append(array, item)
+        }
+    case c == '/':
+        start := p.pos + 1
+        for p.pos++; p.pos < len(p.data) && !pdfDelimiter(p.data[p.pos]); p.pos++ {
+        }
+        return pdfName(p.data[start:p.pos]), nil
+    case c == '<':
+        end := bytes.IndexByte(p.data[p.pos:], '>')
+        if end < 0 {
+            return nil, errors.New("Unterminated PDF hex string")
+        }
+        text := bytes.Map(func(r rune) rune {
+            if bytes.ContainsRune([]byte(" \t\r\n\f\x00"), r) {
+                return -1
+            }
+            return r
+        }, p.data[p.pos+1:p.pos+end])
+        p.pos += end + 1
+        if len(text)%2 == 1 {
+            text = append(text, '0')
+        }
+        return hex.DecodeString(string(text))
+    case c == '(':
+        // the contents of literal strings are of no interest, only their end
+        level := 0
+        for ; p.pos < len(p.data); p.pos++ {
+            switch p.data[p.pos] {
+            case '\\':
+                p.pos++
+            case '(':
+                level++
+            case ')':
+                level--
+                if level == 0 {
+                    p.pos++
+                    return []byte{}, nil
+                }
+            }
+        }
+        return nil, errors.New("Unterminated PDF string")
+    }
+    word := p.token()
+    p.pos += len(word)
+    switch word {
+    case "true", "false":
+        return word == "true", nil
+    case "null":
+        return nil, nil
+    }
+    number, err := strconv.ParseFloat(word, 64)
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("Unexpected PDF token %q", word))
+    }
+    // a reference is an object number followed by a generation number & R
+    mark := p.pos
+    if generation := p.token(); len(generation) > 0 && generation[0] >= '0' && generation[0] <= '9' {
+        p.pos += len(generation)
+        if p.token() == "R" {
+            p.pos++
+            return pdfRef(number), nil
+        }
+    }
+    p.pos = mark
+    return number, nil
+}
+
+// parsePDF indexes the indirect objects of a PDF document; later definitions (incremental updates) replace earlier ones
+func parsePDF(data []byte) (*pdfFile, error) {
+    if !bytes.HasPrefix(data, []byte("%PDF-")) {
+        return nil, errors.New("Not a PDF document")
+    }
+    f := &pdfFile{data: data, objects: make(map[int]int)}
+    for _, match := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+        number, err := strconv.Atoi(string(data[match[2]:match[3]]))
+        if err == nil {
+            f.objects[number] = match[1]
+        }
+    }
+    return f, nil
+}
+
+// object returns the value of the indirect object with the given number & the position after it
+func (f *pdfFile) object(number int) (interface{}, int, error) {
+    pos, ok := f.objects[number]
+    if !ok {
+        return nil, 0, errors.New(fmt.Sprintf("PDF object %d not found", number))
+    }
+    p := &pdfParser{data: f.data, pos: pos}
+    value, err := p.value(0)
+    return value, p.pos, err
+}
+
+// resolve returns the object a reference points to, or the value itself if it is no reference
+func (f *pdfFile) resolve(value interface{}) interface{} {
+    for i := 0; i < 8; i++ {
+        ref, ok := value.(pdfRef)
+        if !ok {
+            return value
+        }
+        value, _, _ = f.object(int(ref))
+    }
+    return nil
+}
+
+// number returns a dictionary entry as integer, def if it is missing
+func (f *pdfFile) number(dict map[string]interface{}, key string, def int) int {
+    if value, ok := f.resolve(dict[key]).(float64); ok {
+        return int(value)
+    }
+    return def
+}
+
+// stream returns the raw data of the stream following the dictionary of an indirect object at pos
+func (f *pdfFile) stream(dict map[string]interface{}, pos int) ([]byte, error) {
+    p := &pdfParser{data: f.data, pos: pos}
+    if p.token() != "stream" {
+        return nil, errors.New("PDF stream not found")
+    }
+    start := p.pos + len("stream")
+    if bytes.HasPrefix(f.data[start:], []byte("\r\n")) {
+        start += 2
+    } else if start < len(f.data) && f.data[start] == '\n' {
+        start++
+    }
+    if length := f.number(dict, "Length", -1); length >= 0 && start+length <= len(f.data) {
+        rest := &pdfParser{data: f.data, pos: start + length}
+        if rest.token() == "endstream" {
+            return f.data[start : start+length], nil
+        }
+    }
+    // a wrong length is a common defect; the stream ends before endstream then
+    end := bytes.Index(f.data[start:], []byte("endstream"))
+    if end < 0 {
+        return nil, errors.New("Unterminated PDF stream")
+    }
+    return bytes.TrimRight(f.data[start:start+end], "\r\n"), nil
+}
+
+// images decodes all images of the document, in the order they are stored
+func (f *pdfFile) images() ([]image.Image, error) {
+    numbers := make([]int, 0, len(f.objects))
+    for number := range f.objects {
+        numbers = append(numbers, number)
+    }
+    // the order in the file, which usually is the order of the pages
+    sort.Slice(numbers, func(i, j int) bool { return f.objects[numbers[i]] < f.objects[numbers[j]] })
+    masks := make(map[int]bool)
+    type imageObject struct {
+        number int
+        dict   map[string]interface{}
+        pos    int
+    }
+    candidates := make([]imageObject, 0)
+    for _, number := range numbers {
+        value, pos, err := f.object(number)
+        dict, ok := value.(map[string]interface{})
+        if err != nil || !ok || dict["Subtype"] != pdfName("Image") {
+            continue
+        }
+        for _, key := range []string{"SMask", "Mask"} {
+            if ref, ok := dict[key].(pdfRef); ok {
+                masks[int(ref)] = true
+            }
+        }
+        candidates = append(candidates, imageObject{number: number, dict: dict, pos: pos})
+    }
+    images := make([]image.Image, 0, len(candidates))
+    for _, candidate := range candidates {
+        if masks[candidate.number] {
+            continue
+        }
+        if len(images) >= pdfMaxImages {
+            break
+        }
+        img, err := f.decodeImage(candidate.dict, candidate.pos)
+        if err != nil {
+            return nil, fmt.Errorf("image object %d: %w", candidate.number, err)
+        }
+        images = append(images, img)
+    }
+    if len(images) == 0 {
+        return nil, errors.New("No images found in the PDF document; only scanned pages can be read")
+    }
+    return images, nil
+}
+
+// decodeImage decodes an image XObject
+func (f *pdfFile) decodeImage(dict map[string]interface{}, pos int) (image.Image, error) {
+    data, err := f.stream(dict, pos)
+    if err != nil {
+        return nil, err
+    }
+    filters := make([]interface{}, 0)
+    switch filter := f.resolve(dict["Filter"]).(type) {
+    case pdfName:
+        filters = append(filters, filter)
+    case []interface{}:
+        filters = filter
+    }
+    params := make([]interface{}, len(filters))
+    switch value := f.resolve(dict["DecodeParms"]).(type) {
+    case map[string]interface{}:
+        if len(params) > 0 {
+            params[0] = value
+        }
+    case []interface{}:
+        copy(params, value)
+    }
+    for i, filter := range filters {
+        parameters, _ := f.resolve(params[i]).(map[string]interface{})
+        switch f.resolve(filter) {
+        case pdfName("DCTDecode"), pdfName("DCT"):
+            if i != len(filters)-1 {
+                return nil, errors.New("DCTDecode must be the last filter")
+            }
+            return jpeg.Decode(bytes.NewReader(data))
+        case pdfName("FlateDecode"), pdfName("Fl"):
+            var reader io.ReadCloser
+            reader, err = zlib.NewReader(bytes.NewReader(data))
+            if err != nil {
+                return nil, err
+            }
+            data, err = ioutil.ReadAll(reader)
+            if err != nil {
+                return nil, err
+            }
+            data, err = f.unpredict(data, parameters)
+        case pdfName("LZWDecode"), pdfName("LZW"):
+            if f.number(parameters, "EarlyChange", 1) != 1 {
+                return nil, errors.New("LZWDecode without early change is not supported")
+            }
+            data, err = unLZW(data)
+            if err == nil {
+                data, err = f.unpredict(data, parameters)
+            }
+        case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+            var p pdfParser
+            p.data = append(append([]byte{'<'}, bytes.TrimSpace(data)...), '>')
+            var value interface{}
+            value, err = p.value(0)
+            data, _ = value.([]byte)
+        case pdfName("ASCII85Decode"), pdfName("A85"):
+            data = bytes.TrimSuffix(bytes.TrimSpace(data), []byte("~>"))
+            decoded := make([]byte, 4*len(data)/5+4)
+            var n int
+            n, _, err = ascii85.Decode(decoded, data, true)
+            data = decoded[:n]
+        default:
+            return nil, errors.New(fmt.Sprintf("Unsupported PDF image filter %v; scan in gray or color", filter))
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return f.decodePixels(dict, data)
+}
+
+// unpredict reverses the PNG predictors given in the decode parameters of a stream (TIFF predictors are not supported)
+func (f *pdfFile) unpredict(data []byte, parameters map[string]interface{}) ([]byte, error) {
+    predictor := f.number(parameters, "Predictor", 1)
+    if predictor == 1 {
+        return data, nil
+    }
+    if predictor < 10 {
+        return nil, errors.New(fmt.Sprintf("Unsupported PDF predictor %d", predictor))
+    }
+    colors := f.number(parameters, "Colors", 1)
+    bits := f.number(parameters, "BitsPerComponent", 8)
+    columns := f.number(parameters, "Columns", 1)
+    if colors < 1 || colors > 4 || bits < 1 || bits > 16 || columns < 1 || columns > 1<<16 {
+        return nil, errors.New("Invalid PDF predictor parameters")
+    }
+    stride := (colors*bits*columns + 7) / 8
+    pixel := (colors*bits + 7) / 8
+    out := make([]byte, 0, len(data)/(stride+1)*stride)
+    previous := make([]byte, stride)
+    for len(data) >= stride+1 {
+        kind, row := data[0], append([]byte(nil), data[1:stride+1]...)
+        data = data[stride+1:]
+        for i := range row {
+            var left, up, upLeft byte
+            if i >= pixel {
+                left, upLeft = row[i-pixel], previous[i-pixel]
+            }
+            up = previous[i]
+            switch kind {
+            case 1:
+                row[i] += left
+            case 2:
+                row[i] += up
+            case 3:
+                row[i] += byte((int(left) + int(up)) / 2)
+            case 4:
+                row[i] += paeth(left, up, upLeft)
+            }
+        }
+        out = append(out, row...)
+        previous = row
+    }
+    return out, nil
+}
+
+// paeth is the Paeth predictor of PNG
+func paeth(a byte, b byte, c byte) byte {
+    p := int(a) + int(b) - int(c)
+    pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+    if pa <= pb && pa <= pc {
+        return a
+    }
+    if pb <= pc {
+        return b
+    }
+    return c
+}
+
+// abs returns the absolute value of a number
+func abs(x int) int {
+    if x < 0 {
+        return -x
+    }
+    return x
+}
+
+// colorComponents returns the number of components of a color space; 0 if it is not supported
+func (f *pdfFile) colorComponents(space interface{}) int {
+    switch value := f.resolve(space).(type) {
+    case pdfName:
+        switch value {
+        case "DeviceGray", "CalGray", "G":
+            return 1
+        case "DeviceRGB", "CalRGB", "RGB":
+            return 3
+        case "DeviceCMYK", "CMYK":
+            return 4
+        }
+    case []interface{}:
+        if len(value) == 2 && f.resolve(value[0]) == pdfName("ICCBased") {
+            // the number of components is given in the dictionary of the profile stream
+            if ref, ok := value[1].(pdfRef); ok {
+                if dict, _, err := f.object(int(ref)); err == nil {
+                    if profile, ok := dict.(map[string]interface{}); ok {
+                        return f.number(profile, "N", 0)
+                    }
+                }
+            }
+        }
+        if len(value) > 0 {
+            return f.colorComponents(value[0])
+        }
+    }
+    return 0
+}
+
+// decodePixels creates an image from decoded samples
+func (f *pdfFile) decodePixels(dict map[string]interface{}, data []byte) (image.Image, error) {
+    width := f.number(dict, "Width", 0)
+    height := f.number(dict, "Height", 0)
+    if width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 {
+        return nil, errors.New(fmt.Sprintf("Invalid image size %dx%d", width, height))
+    }
+    mask, _ := f.resolve(dict["ImageMask"]).(bool)
+    bits, components := f.number(dict, "BitsPerComponent", 8), f.colorComponents(dict["ColorSpace"])
+    if mask {
+        bits, components = 1, 1
+    }
+    // a Decode array of [1 0] inverts gray images
+    inverted := false
+    if decode, ok := f.resolve(dict["Decode"]).([]interface{}); ok && len(decode) >= 2 {
+        inverted = decode[0] == float64(1) && decode[1] == float64(0)
+    }
+    if mask {
+        // samples of 0 are painted, i.e. black on white paper
+        inverted = !inverted
+    }
+    img := image.NewGray(image.Rect(0, 0, width, height))
+    switch {
+    case bits == 1 && components == 1:
+        stride := (width + 7) / 8
+        if len(data) < stride*height {
+            return nil, errors.New("Image data too short")
+        }
+        for y := 0; y < height; y++ {
+            for x := 0; x < width; x++ {
+                if (data[y*stride+x/8]&(0x80>>uint(x%8)) != 0) != inverted {
+                    img.Pix[y*img.Stride+x] = 255
+                }
+            }
+        }
+    case bits == 8 && components >= 1 && components <= 4:
+        stride := width * components
+        if len(data) < stride*height {
+            return nil, errors.New("Image data too short")
+        }
+        for y := 0; y < height; y++ {
+            for x := 0; x < width; x++ {
+                p := data[y*stride+x*components:]
+                var gray uint8
+                switch components {
+                case 1:
+                    gray = p[0]
+                case 3:
+                    gray = color.GrayModel.Convert(color.RGBA{p[0], p[1], p[2], 255}).(color.Gray).Y
+                case 4:
+                    gray = color.GrayModel.Convert(color.CMYK{p[0], p[1], p[2], p[3]}).(color.Gray).Y
+                default:
+                    return nil, errors.New("Two component images are not supported")
+                }
+                if inverted && components == 1 {
+                    gray = 255 - gray
+                }
+                img.Pix[y*img.Stride+x] = gray
+            }
+        }
+    default:
+        return nil, errors.New(fmt.Sprintf("Unsupported PDF image format (%d bits, %d components)", bits, components))
+    }
+    return img, nil
+}
+
+// readPDFFile reads all images of a PDF document, e.g. the pages of a scan
+func readPDFFile(fname string) ([]image.Image, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    return decodePDF(data)
+}
+
+// decodePDF reads all images of PDF data
+func decodePDF(data []byte) ([]image.Image, error) {
+    f, err := parsePDF(data)
+    if err != nil {
+        return nil, err
+    }
+    return f.images()
+}
@@ -0,0 +1,81 @@
+package qrFile
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+// pdfWithImage returns a PDF document with a single image object of the given dictionary entries & stream data
+func pdfWithImage(entries string, data []byte) []byte {
+    var pdf bytes.Buffer
+    pdf.WriteString("%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n2 0 obj << /Type /Pages /Count 1 >> endobj\n")
+    fmt.Fprintf(&pdf, "3 0 obj << /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n", entries, len(data))
+    pdf.Write(data)
+    pdf.WriteString("\nendstream\nendobj\ntrailer << /Root 1 0 R >>\n%%EOF\n")
+    return pdf.Bytes()
+}
+
+// grayEntries describes an uncompressed 2x2 gray image
+const grayEntries = "/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8"
+
+// grayFlate are the pixels of the 2x2 gray image, compressed with FlateDecode
+var grayFlate = []byte{0x78, 0x9c, 0x63, 0xf8, 0xff, 0x9f, 0x01, 0x00, 0x04, 0xff, 0x01, 0xff}
+
+// TestPDFImage reads the pixels of an image, uncompressed & compressed
+func TestPDFImage(t *testing.T) {
+    for _, pdf := range [][]byte{pdfWithImage(grayEntries, []byte{0, 255, 255, 0}), pdfWithImage(grayEntries+" /Filter /FlateDecode", grayFlate)} {
+        images, err := decodePDF(pdf)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(images) != 1 {
+            t.Fatalf("%d images, expected 1", len(images))
+        }
+        if bounds := images[0].Bounds(); bounds.Dx() != 2 || bounds.Dy() != 2 {
+            t.Fatalf("image of %v", bounds)
+        }
+    }
+}
+
+// TestPDFMalformed returns errors for malformed & truncated documents instead of panicking
+func TestPDFMalformed(t *testing.T) {
+    cases := []struct {
+        name string
+        data []byte
+    }{
+        {"empty", nil},
+        {"no PDF", []byte("GIF89a")},
+        {"header only", []byte("%PDF-1.4\n")},
+        {"no images", []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n")},
+        {"unterminated dictionary", []byte("%PDF-1.4\n3 0 obj << /Subtype /Image /Width 2")},
+        {"unterminated string", []byte("%PDF-1.4\n3 0 obj << /Subtype /Image /Name (image")},
+        {"nested too deeply", []byte("%PDF-1.4\n3 0 obj << /Subtype /Image /Decode " + strings.Repeat("[", 100) + " >> endobj\n")},
+        {"unterminated stream", []byte("%PDF-1.4\n3 0 obj << /Subtype /Image " + grayEntries + " /Length 4 >>\nstream\n\x00\xff")},
+        {"image data too short", pdfWithImage(grayEntries, []byte{0, 255})},
+        {"no size", pdfWithImage("/ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0, 255, 255, 0})},
+        {"huge size", pdfWithImage("/Width 100000000 /Height 100000000 /ColorSpace /DeviceGray /BitsPerComponent 8", []byte{0})},
+        {"unsupported bits", pdfWithImage("/Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 4", []byte{0, 255})},
+        {"unsupported filter", pdfWithImage(grayEntries+" /Filter /JBIG2Decode", []byte{0, 255, 255, 0})},
+        {"damaged flate data", pdfWithImage(grayEntries+" /Filter /FlateDecode", []byte{0x78, 0x9c, 1, 2, 3})},
+        {"damaged JPEG", pdfWithImage(grayEntries+" /Filter /DCTDecode", []byte{0xff, 0xd8, 0xff})},
+        {"TIFF predictor", pdfWithImage(grayEntries+" /Filter /FlateDecode /DecodeParms << /Predictor 2 >>", grayFlate)},
+        {"invalid predictor parameters", pdfWithImage(grayEntries+" /Filter /FlateDecode /DecodeParms << /Predictor 15 /Colors 9 >>", grayFlate)},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            if images, err := decodePDF(c.data); err == nil {
+                t.Fatalf("%d images read", len(images))
+            }
+        })
+    }
+    // every truncation of a document ending within the stream
+    pdf := pdfWithImage(grayEntries, []byte{0, 255, 255, 0})
+    end := bytes.Index(pdf, []byte("endstream"))
+    for length := 0; length < end+len("endstream")-1; length++ {
+        if _, err := decodePDF(pdf[:length]); err == nil {
+            t.Fatalf("document truncated to %d of %d bytes read", length, len(pdf))
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package qrFile
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os/exec"
+)
+
+// Data can be encrypted (and signed) for OpenPGP recipients before it is split into elements, for users managing PGP
+// keys already. gpg (https://gnupg.org/) is called for this, so keys, trust & passphrases are handled by the keyring &
+// agent of the user. The recipients are recorded in the manifest of the set (see PGPInfo), so it is known on decoding
+// that the data needs to be passed to gpg again.
+
+// GPGPath is the gpg binary called to encrypt, sign & decrypt data (see ToolDirectory)
+var GPGPath = "gpg"
+
+// PGPInfo describes the OpenPGP protection of the data of a set
+type PGPInfo struct {
+    Recipients []string `json:"recipients,omitempty"` // key IDs or user IDs the data is encrypted for; empty if only signed
+    Signer     string   `json:"signer,omitempty"`     // key ID or user ID of the signing key, if the data is signed
+}
+
+// ProtectPGP encrypts data for the recipients & signs it with the key of signer (if not empty) using gpg. At least one
+// of them is required; without recipients the data is only signed. Returns the binary OpenPGP message & a description
+// for the manifest.
+func ProtectPGP(data []byte, recipients []string, signer string) ([]byte, *PGPInfo, error) {
+    if len(recipients) == 0 && len(signer) == 0 {
+        return nil, nil, errors.New("OpenPGP protection requires a recipient or a signing key")
+    }
+    args := []string{"--batch", "--yes", "--output", "-"}
+    for _, recipient := range recipients {
+        args = append(args, "--recipient", recipient)
+    }
+    if len(recipients) > 0 {
+        args = append(args, "--encrypt")
+    }
+    if len(signer) > 0 {
+        args = append(args, "--local-user", signer, "--sign")
+    }
+    result, err := runGPG(data, args...)
+    if err != nil {
+        return nil, nil, err
+    }
+    return result, &PGPInfo{Recipients: recipients, Signer: signer}, nil
+}
+
+// UnprotectPGP decrypts an OpenPGP message created by ProtectPGP using gpg & verifies its signature, if any. The secret
+// key needs to be in the keyring of the user; gpg asks for its passphrase, if needed.
+func UnprotectPGP(data []byte) ([]byte, error) {
+    return runGPG(data, "--batch", "--yes", "--output", "-", "--decrypt")
+}
+
+// runGPG calls gpg with the given arguments, passing data on stdin, & returns its output
+func runGPG(data []byte, args ...string) ([]byte, error) {
+    var result, stderr bytes.Buffer
+    cmd := exec.Command(toolPath(GPGPath), args...)
+    cmd.Stdin = bytes.NewReader(data)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if err != nil {
+        if message := bytes.TrimSpace(stderr.Bytes()); len(message) > 0 {
+            return nil, errors.New(fmt.Sprintf("gpg failed: %s (%s)", err, message))
+        }
+        return nil, fmt.Errorf("gpg failed: %w", err)
+    }
+    return result.Bytes(), nil
+}
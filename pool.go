@@ -0,0 +1,58 @@
+package qrFile
+
+import (
+    "context"
+    "runtime"
+)
+
+// Images are rendered & read concurrently, but by a bounded number of workers: a large set would otherwise start a
+// goroutine (& an external encoder or decoder process, e.g. qrencode or zbarimg) for every element at once, running out
+// of file descriptors & memory.
+
+// workerPool runs functions in at most a fixed number of goroutines at once, or one after the other if it is sequential
+type workerPool struct {
+    slots      chan struct{}
+    sequential bool
+}
+
+// newWorkerPool returns a pool of Workers workers of the set, sequential if Sequential is set
+func (elem *QrElements) newWorkerPool() *workerPool {
+    workers := elem.Workers
+    if workers <= 0 {
+        workers = runtime.GOMAXPROCS(0)
+    }
+    return &workerPool{slots: make(chan struct{}, workers), sequential: elem.Sequential}
+}
+
+// size returns the number of workers of the pool
+func (p *workerPool) size() int {
+    return cap(p.slots)
+}
+
+// spawn runs f in a goroutine once a worker of the pool is free or, if the pool is sequential, before returning; the worker
+// is counted in the Metrics while it runs. Returns false without running f if ctx is canceled first.
+func (p *workerPool) spawn(ctx context.Context, f func()) bool {
+    worker := func() {
+        m := metrics()
+        m.ActiveWorkers(1)
+        defer m.ActiveWorkers(-1)
+        f()
+    }
+    if ctx.Err() != nil {
+        return false
+    }
+    if p.sequential {
+        worker()
+        return true
+    }
+    select {
+    case p.slots <- struct{}{}:
+    case <-ctx.Done():
+        return false
+    }
+    go func() {
+        defer func() { <-p.slots }()
+        worker()
+    }()
+    return true
+}
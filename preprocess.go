@@ -0,0 +1,361 @@
+package qrFile
+
+import (
+    "image"
+    "image/color"
+    "math"
+)
+
+// Geometric & sharpness corrections for photos of printed pages, used as further attempts of a RetryPolicy (see
+// retry.go): the page is straightened if it was photographed at an angle (perspective), rotated back if it lies askew
+// on the scanner (deskew) & blurred photos are sharpened. All of them return gray images; a correction which finds
+// nothing to correct returns the gray image only.
+
+// preprocessSize is the longer side images are scaled down to for finding the page & the skew angle, so photos of many
+// megapixels are measured quickly; the correction itself is applied to the full image
+const preprocessSize = 512
+
+// maxSkew is the largest angle (in degrees) deskewImage corrects; larger rotations are left to the decoder & the
+// rotation attempts
+const maxSkew = 20
+
+// sharpenImage converts an image to gray & sharpens it with an unsharp mask: the difference to the mean of the 3x3
+// pixels around each pixel is added to it once more
+func sharpenImage(img image.Image) image.Image {
+    gray := grayImage(img)
+    w, h := gray.Rect.Dx(), gray.Rect.Dy()
+    result := image.NewGray(gray.Rect)
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            sum, n := 0, 0
+            for dy := -1; dy <= 1; dy++ {
+                for dx := -1; dx <= 1; dx++ {
+                    if xx, yy := x+dx, y+dy; xx >= 0 && xx < w && yy >= 0 && yy < h {
+                        sum += int(gray.Pix[yy*gray.Stride+xx])
+                        n++
+                    }
+                }
+            }
+            v := int(gray.Pix[y*gray.Stride+x])
+            result.Pix[y*result.Stride+x] = uint8(clampInt(2*v-sum/n, 0, 255))
+        }
+    }
+    return result
+}
+
+// deskewImage converts an image to gray & rotates it back by the angle its content is skewed by (see skewAngle), with a
+// white border around the rotated image
+func deskewImage(img image.Image) image.Image {
+    gray := grayImage(img)
+    angle := skewAngle(gray)
+    if angle == 0 {
+        return gray
+    }
+    return straightenGray(gray, angle*math.Pi/180)
+}
+
+// skewAngle estimates the angle (in degrees, clockwise on screen, up to maxSkew) the content of an image is rotated by:
+// the rows of modules of a code (or the lines of a text) line up best, i.e. the projection of the dark pixels onto the
+// vertical axis has the highest peaks, when the image is rotated back by this angle. Returns 0 if no angle stands out.
+func skewAngle(gray *image.Gray) float64 {
+    small := scaleGray(gray, preprocessSize)
+    level := otsuThreshold(small)
+    w, h := small.Rect.Dx(), small.Rect.Dy()
+    type point struct{ x, y float64 }
+    dark := make([]point, 0)
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            if small.Pix[y*small.Stride+x] < level {
+                dark = append(dark, point{float64(x), float64(y)})
+            }
+        }
+    }
+    if len(dark) == 0 {
+        return 0
+    }
+    diagonal := int(math.Hypot(float64(w), float64(h))) + 1
+    score := func(angle float64) float64 {
+        sin, cos := math.Sincos(angle * math.Pi / 180)
+        rows := make([]float64, 2*diagonal+1)
+        for _, p := range dark {
+            rows[int(p.y*cos-p.x*sin)+diagonal]++
+        }
+        total := 0.0
+        for _, v := range rows {
+            total += v * v
+        }
+        return total
+    }
+    best, bestScore, unrotated := 0.0, 0.0, score(0)
+    for angle := -maxSkew; angle <= maxSkew; angle++ {
+        if s := score(float64(angle)); s > bestScore {
+            best, bestScore = float64(angle), s
+        }
+    }
+    // refine around the best whole degree
+    for angle := best - 0.75; angle <= best+0.75; angle += 0.25 {
+        if s := score(angle); s > bestScore {
+            best, bestScore = angle, s
+        }
+    }
+    if math.Abs(best) < 0.5 || bestScore <= unrotated {
+        return 0
+    }
+    return best
+}
+
+// straightenGray rotates an image around its center, so lines falling by angle (in radians, clockwise on screen) become
+// horizontal; the result is large enough to hold the whole rotated image, the corners are white
+func straightenGray(gray *image.Gray, angle float64) *image.Gray {
+    w, h := float64(gray.Rect.Dx()), float64(gray.Rect.Dy())
+    sin, cos := math.Sincos(angle)
+    rw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+    rh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+    result := image.NewGray(image.Rect(0, 0, rw, rh))
+    for y := 0; y < rh; y++ {
+        for x := 0; x < rw; x++ {
+            // map the target pixel back into the source image
+            dx, dy := float64(x)-float64(rw)/2, float64(y)-float64(rh)/2
+            sx := dx*cos - dy*sin + w/2
+            sy := dx*sin + dy*cos + h/2
+            result.Pix[y*result.Stride+x] = sampleGray(gray, sx, sy)
+        }
+    }
+    return result
+}
+
+// perspectiveImage converts an image to gray & straightens the page shown in it: the corners of the largest bright
+// area (a printed page in front of a darker background, see pageCorners) are mapped to the corners of a rectangle of the
+// size of the page. If no page is found, the gray image is returned.
+func perspectiveImage(img image.Image) image.Image {
+    gray := grayImage(img)
+    corners, ok := pageCorners(gray)
+    if !ok {
+        return gray
+    }
+    width := math.Max(distance(corners[0], corners[1]), distance(corners[3], corners[2]))
+    height := math.Max(distance(corners[0], corners[3]), distance(corners[1], corners[2]))
+    w, h := int(math.Round(width)), int(math.Round(height))
+    target := [4][2]float64{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}
+    transform, ok := homography(target, corners)
+    if !ok {
+        return gray
+    }
+    result := image.NewGray(image.Rect(0, 0, w, h))
+    for y := 0; y < h; y++ {
+        for x := 0; x < w; x++ {
+            sx, sy := transform.apply(float64(x)+0.5, float64(y)+0.5)
+            result.Pix[y*result.Stride+x] = sampleGray(gray, sx-0.5, sy-0.5)
+        }
+    }
+    return result
+}
+
+// pageCorners finds the corners of the page shown in an image (top left, top right, bottom right & bottom left): the
+// largest connected area of pixels brighter than the Otsu threshold, if it takes up at least a fifth of the image & does
+// not touch all four sides of it. The corners are the points of the area closest to the corners of the image.
+func pageCorners(gray *image.Gray) ([4][2]float64, bool) {
+    var corners [4][2]float64
+    small := scaleGray(gray, preprocessSize)
+    level := otsuThreshold(small)
+    w, h := small.Rect.Dx(), small.Rect.Dy()
+    // label the bright areas, keeping the largest one
+    labels := make([]int, w*h)
+    largest, largestSize, largestBorders := 0, 0, 0
+    stack := make([]int, 0)
+    for start := range labels {
+        if labels[start] != 0 || small.Pix[(start/w)*small.Stride+start%w] < level {
+            continue
+        }
+        label, size, borders := start+1, 0, 0
+        labels[start] = label
+        stack = append(stack[:0], start)
+        for len(stack) > 0 {
+            p := stack[len(stack)-1]
+            stack = stack[:len(stack)-1]
+            size++
+            x, y := p%w, p/w
+            if x == 0 {
+                borders |= 1
+            }
+            if x == w-1 {
+                borders |= 2
+            }
+            if y == 0 {
+                borders |= 4
+            }
+            if y == h-1 {
+                borders |= 8
+            }
+            for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+                if n[0] < 0 || n[0] >= w || n[1] < 0 || n[1] >= h {
+                    continue
+                }
+                q := n[1]*w + n[0]
+                if labels[q] == 0 && small.Pix[n[1]*small.Stride+n[0]] >= level {
+                    labels[q] = label
+                    stack = append(stack, q)
+                }
+            }
+        }
+        if size > largestSize {
+            largest, largestSize, largestBorders = label, size, borders
+        }
+    }
+    // the margin of a scan surrounds the whole image
+    if largestSize < w*h/5 || largestBorders == 15 {
+        return corners, false
+    }
+    // the extremes of x+y & x-y are the corners of a quadrilateral which is not rotated by 45 degrees or more
+    extremes := [4]float64{math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1)}
+    for p, label := range labels {
+        if label != largest {
+            continue
+        }
+        x, y := float64(p%w), float64(p/w)
+        values := [4]float64{x + y, x - y, x + y, x - y}
+        for i, v := range values {
+            // top left & bottom left are minima, top right & bottom right maxima
+            if (i == 0 || i == 3) && v < extremes[i] || (i == 1 || i == 2) && v > extremes[i] {
+                extremes[i] = v
+                corners[i] = [2]float64{x, y}
+            }
+        }
+    }
+    scale := float64(gray.Rect.Dx()) / float64(w)
+    for i := range corners {
+        // the pixel centers of the small image stand for the middle of scale pixels of the image
+        corners[i][0] = (corners[i][0] + 0.5) * scale
+        corners[i][1] = (corners[i][1] + 0.5) * scale
+    }
+    return corners, true
+}
+
+// projective is a perspective transformation of the plane, as a 3x3 matrix with the last entry 1
+type projective [8]float64
+
+// apply maps a point
+func (m projective) apply(x float64, y float64) (float64, float64) {
+    d := m[6]*x + m[7]*y + 1
+    return (m[0]*x + m[1]*y + m[2]) / d, (m[3]*x + m[4]*y + m[5]) / d
+}
+
+// homography returns the perspective transformation mapping the four points from onto the four points to; false if the
+// points are degenerate (e.g. three of them on a line)
+func homography(from [4][2]float64, to [4][2]float64) (projective, bool) {
+    // each pair of points gives two linear equations for the eight unknowns
+    var a [8][9]float64
+    for i := 0; i < 4; i++ {
+        x, y, u, v := from[i][0], from[i][1], to[i][0], to[i][1]
+        a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+        a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+    }
+    // Gaussian elimination with partial pivoting
+    for col := 0; col < 8; col++ {
+        pivot := col
+        for row := col + 1; row < 8; row++ {
+            if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+                pivot = row
+            }
+        }
+        if math.Abs(a[pivot][col]) < 1e-9 {
+            return projective{}, false
+        }
+        a[col], a[pivot] = a[pivot], a[col]
+        for row := 0; row < 8; row++ {
+            if row == col {
+                continue
+            }
+            factor := a[row][col] / a[col][col]
+            for k := col; k < 9; k++ {
+                a[row][k] -= factor * a[col][k]
+            }
+        }
+    }
+    var m projective
+    for i := range m {
+        m[i] = a[i][8] / a[i][i]
+    }
+    return m, true
+}
+
+func distance(a [2]float64, b [2]float64) float64 {
+    return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// sampleGray returns the gray level at a point of an image, interpolated between the four pixels around it; points
+// outside of the image are white
+func sampleGray(gray *image.Gray, x float64, y float64) uint8 {
+    x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+    fx, fy := x-float64(x0), y-float64(y0)
+    at := func(x int, y int) float64 {
+        if x < 0 || y < 0 || x >= gray.Rect.Dx() || y >= gray.Rect.Dy() {
+            return 255
+        }
+        return float64(gray.Pix[y*gray.Stride+x])
+    }
+    top := at(x0, y0)*(1-fx) + at(x0+1, y0)*fx
+    bottom := at(x0, y0+1)*(1-fx) + at(x0+1, y0+1)*fx
+    return uint8(math.Round(top*(1-fy) + bottom*fy))
+}
+
+// scaleGray returns an image scaled down so its longer side is at most size pixels, each pixel the mean of the pixels it
+// covers; smaller images are returned as they are
+func scaleGray(gray *image.Gray, size int) *image.Gray {
+    w, h := gray.Rect.Dx(), gray.Rect.Dy()
+    factor := (w + size - 1) / size
+    if f := (h + size - 1) / size; f > factor {
+        factor = f
+    }
+    if factor <= 1 {
+        return gray
+    }
+    result := image.NewGray(image.Rect(0, 0, w/factor, h/factor))
+    for y := 0; y < h/factor; y++ {
+        for x := 0; x < w/factor; x++ {
+            sum := 0
+            for dy := 0; dy < factor; dy++ {
+                row := (y*factor + dy) * gray.Stride
+                for dx := 0; dx < factor; dx++ {
+                    sum += int(gray.Pix[row+x*factor+dx])
+                }
+            }
+            result.SetGray(x, y, color.Gray{uint8(sum / (factor * factor))})
+        }
+    }
+    return result
+}
+
+// otsuThreshold returns the gray level separating the dark from the bright pixels of an image best (Otsu's method:
+// the variance between both classes is largest)
+func otsuThreshold(gray *image.Gray) uint8 {
+    var histogram [256]int
+    w, h := gray.Rect.Dx(), gray.Rect.Dy()
+    for y := 0; y < h; y++ {
+        for _, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+            histogram[v]++
+        }
+    }
+    total, sum := w*h, 0.0
+    for i, n := range histogram {
+        sum += float64(i * n)
+    }
+    best, bestVariance := 128, 0.0
+    darkCount, darkSum := 0, 0.0
+    for level := 1; level < 256; level++ {
+        // pixels below level are dark
+        darkCount += histogram[level-1]
+        darkSum += float64((level - 1) * histogram[level-1])
+        brightCount := total - darkCount
+        if darkCount == 0 || brightCount == 0 {
+            continue
+        }
+        darkMean := darkSum / float64(darkCount)
+        brightMean := (sum - darkSum) / float64(brightCount)
+        variance := float64(darkCount) * float64(brightCount) * (darkMean - brightMean) * (darkMean - brightMean)
+        if variance > bestVariance {
+            best, bestVariance = level, variance
+        }
+    }
+    return uint8(best)
+}
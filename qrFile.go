@@ -1,31 +1,37 @@
 // Package qrFile provides operations to store files in QR-Codes and convert those images back to files.
-// zbar (http://zbar.sourceforge.net/) is used for reading QR images; it must be available in $PATH.
+// QR images are read in pure Go; zbar (http://zbar.sourceforge.net/) is used as fallback if it is available in $PATH.
 
 package qrFile
 
 import (
     "bufio"
     "bytes"
-    "code.google.com/p/rsc/qr"
+    "context"
+    "crypto/sha256"
     "encoding/hex"
     "errors"
     "fmt"
+    "hash/crc32"
     "image"
-    "image/png"
+    "io"
     "log"
+    "log/slog"
     "os"
-    "os/exec"
     "path/filepath"
+    "rsc.io/qr"
     "sort"
     "strconv"
     "strings"
+    "sync/atomic"
+    "time"
 )
 
 // constants
-// qrLevel defines the amount of redundancy used in the qr code
-const qrLevel = qr.L
+// qrLevel defines the amount of redundancy used in the qr code by default (see QrElements.Level)
+const qrLevel = LevelL
 
-// qrSize defines the amount of characters in each single image; this needs to be even, since we encode binary using 2 hex chars
+// qrSize is the amount of characters of the text of each code in the legacy format (VersionLegacy), whose header &
+// padded payload have a fixed size; the plain & compact formats size their codes by the chunk size instead
 const qrSize uint64 = 1608
 
 // qrHeaderSize defines the amount of space each header takes up
@@ -49,31 +55,177 @@ const payloadLengthPos = 40
 // payloadPos
 const payloadPos = 60
 
-// outputFormat used for conversion of QrElements to string for printing / logging
-const outputFormat = "%20d%20d%20d%s"
+// outputFormat used for conversion of QrElements to string for printing / logging. The hex encoded payload is padded
+// with spaces in front to the maximum length of qrDataSize characters.
+const outputFormat = "%20d%20d%20d%1548s"
 
-// payloadFormat used to store the payload. Will result in spaces as prefixes if payload is shorter than the maximum available amount
-const payloadFormat = "%1548s"
+// Format versions of the text stored in a single QR image
+const (
+    VersionLegacy  = 1 // fixed width header of 3x20 decimal characters, payload padded to qrDataSize characters
+    VersionPlain   = 2 // short "QRF v2 <number>/<count>" header readable by any scanner app, see GetElementsPlain
+    VersionCompact = 3 // binary header of four varints, base64 encoded along with the payload, see compact.go
+
+    VersionCurrent = VersionPlain // the version sets are converted to, see Convert
+)
+
+// plainPrefix starts the text of every element in plain format
+const plainPrefix = "QRF v2 "
+
+// versionPrefix starts the text of the elements of every textual format version from the plain format on ("QRF v<n> "),
+// so codes of versions added later are recognized & rejected with their version instead of being taken for legacy
+// codes
+const versionPrefix = "QRF v"
+
+// FormatName returns the name of a format version, e.g. "plain" for VersionPlain
+func FormatName(version int) string {
+    switch version {
+    case VersionLegacy, 0:
+        return "legacy"
+    case VersionPlain:
+        return "plain"
+    case VersionCompact:
+        return "compact"
+    }
+    return fmt.Sprintf("version %d", version)
+}
+
+// plainDataSize is the amount of payload characters in an element in plain format. Kept small so that the resulting
+// codes can be read by stock scanner apps and the text can still be handled manually.
+const plainDataSize uint64 = 400
+
+// DefaultMaxFileSize limits the size of files & data read (in bytes) where the options give no other limit, so huge
+// inputs fail early instead of generating tens of thousands of codes (see QrFile.MaxSize & QrElements.MaxFileSize)
+const DefaultMaxFileSize int64 = 16 << 20
+
+// sizeLimit returns the effective limit of a size option: DefaultMaxFileSize if 0, none (0) if negative
+func sizeLimit(max int64) int64 {
+    if max == 0 {
+        return DefaultMaxFileSize
+    }
+    if max < 0 {
+        return 0
+    }
+    return max
+}
 
 // Data types
 // QrFile provides means to read and write the input or output files (not the PNGs, though)
-// zbar (http://zbar.sourceforge.net/) ist used for reading/interpreting qr code images. zbarimg needs to be available in PATH
 type QrFile struct {
     Fname string
     Data  []byte
+    // Boundaries lists the offsets of the entries in Data if it is an archive created by FromDirectory, to align the
+    // elements to them (see EncodeOptions.Align)
+    Boundaries []uint64
+    // Mode & ModTime of the file, set by ReadFile & applied by ToFile if not zero (see FileInfo)
+    Mode    os.FileMode
+    ModTime time.Time
+    // Overwrite lets ToFile replace an existing file; by default, an existing file is never touched
+    Overwrite bool
+    // MaxSize limits the size of the file read by ReadFile (in bytes); DefaultMaxFileSize if 0, unlimited if negative
+    MaxSize int64
 }
 
 // QrElement describes the data stored inside a single QR image
 type QrElement struct {
+    Version       int    // format version of the text representation (VersionLegacy if 0)
+    SetID         string // identifies the set the element belongs to; empty if the format carries none (VersionLegacy, VersionCompact)
     Index         uint64
     MaxIndex      uint64
-    PayloadLength uint64 // nescessary to store this since we will pad up to max length
-    Payload       string
+    PayloadLength uint64       // length of the payload hex encoded, as recorded in the headers of the codes
+    Payload       []byte       // data of the element; only encoded (& padded, in legacy format) when rendered, see AsString
+    Fields        HeaderFields // custom fields in the header (plain format only, see fields.go)
+    Codec         PayloadCodec // encoding of the payload in the text of the code (plain format only, see codec.go)
+    source        string       // file the element was read from, if any (see FromPNGs)
+    symbol        Symbol       // the code the element was read from, with the details reported by the decoder
 }
 
 // QrElements is a collection of QrElement entries; provides global methods such as QR creation etc. Implements sort.Interface
 type QrElements struct {
     Elements []QrElement
+    Encoder  SymbolEncoder // used to render the images in WritePNGs; DefaultEncoder if nil
+    Decoder  Decoder       // used to read the images in FromPNGs; the default decoder if nil (see ZbarFallback)
+    Level    Level         // error correction level of the images; LevelL (the zero value) by default
+    // Levels overrides Level for single elements (by index), e.g. to protect the first element more than the others
+    Levels map[uint64]Level
+    // SymbolVersion is the QR version the elements were sized for when the data was split, if one was selected (see
+    // EncodeOptions.SymbolVersion); recorded in the manifest & the parameter code
+    SymbolVersion int
+    // PadVersion is the smallest QR version the codes are rendered in, so all codes of the set have the same size (see
+    // EncodeOptions.Pad); 0 renders each code in the smallest version it fits. Requires an encoder implementing
+    // VersionEncoder; recorded in the manifest & the parameter code.
+    PadVersion int
+    // Transcribe prints a transcription of each element below its code (see QrElement.Transcription), so the data
+    // can be restored by OCR or typing if the code is damaged
+    Transcribe bool
+    // Digest prints the number of each element & the start of its hash (as listed in the manifest, see QrElement.Hash)
+    // above its code, so printed pages can be sorted, matched & checked against the manifest by eye
+    Digest bool
+    // Caption prints a caption strip below each code (see Caption): the name of the file, the number of the element,
+    // the date & the start of its hash, so printed codes are told apart by eye
+    Caption *Caption
+    // StructuredAppend renders the codes of a set of up to 16 elements as a QR Structured Append sequence, so standard
+    // readers recognize them as one message (see structured.go); requires the internal encoder
+    StructuredAppend bool
+    // Rendering selects the size, quiet zone & colors of the images (see RenderOptions); the zero value leaves them to
+    // the Encoder, other values require a MatrixEncoder
+    Rendering RenderOptions
+    // ContentNames names the images written by WritePNGs after the hash of their element (<prefix><index>_<hash>.png,
+    // see imageName), so duplicates & images of different sets are told apart by their names
+    ContentNames bool
+    // Checksums writes a checksums file (<prefix>SHA256SUMS, see ChecksumsName) listing the SHA-256 of the images &
+    // the manifest written by WritePNGs, so copies of the set can be verified
+    Checksums bool
+    // ParameterChunk writes the parameter code of the set (<prefix>params.png, see ParametersName & ParameterText) next to
+    // the images written by WritePNGs, so decoders configure themselves from the images alone
+    ParameterChunk bool
+    // Mode selects whether images which can not be read are skipped (DecodeLenient, the default) or abort reading
+    Mode DecodeMode
+    // Retry selects further attempts for images which could not be read (see RetryPolicy); none by default
+    Retry RetryPolicy
+    // Report describes how the input files were read by FromPNGs (files which failed, timeouts); nil if the elements
+    // were not read from files
+    Report *DecodeReport
+    // Expected is the manifest of the set read, if known: Validate (& thus FromPNGs) drops the elements whose hash
+    // differs from the one listed in it, so a misread copy of an element does not hide an intact one (see
+    // DecodeReport.Mismatched)
+    Expected *Manifest
+    // Parameters holds the parameters of the set read from its parameter code (see ParameterText), if one was among the
+    // images read: the set is checked against them & the transforms recorded are reversed by RestoreData
+    Parameters *Parameters
+    // PGP describes the OpenPGP protection of the data (see ProtectPGP), if any; recorded in the manifest
+    PGP *PGPInfo
+    // Transforms lists the transforms applied to the data before it was split (see ApplyTransforms), in order;
+    // recorded in the manifest & reversed by RestoreData
+    Transforms []TransformInfo
+    // File describes the original file of the set (see FileInfo), if known; recorded in the manifest
+    File *FileInfo
+    // Meta holds the metadata of the set (see Metadata), if any; recorded in the manifest
+    Meta *Metadata
+    // Signature is the Ed25519 signature of the data of the set (see Sign), if any; recorded in the manifest
+    Signature []byte
+    // Fields holds custom fields of the set (see fields.go); recorded in the manifest. Fields of single elements are
+    // set in their header (see QrElement.Fields).
+    Fields HeaderFields
+    // Observer is notified of the progress of writing & reading the set (see observer.go); nil if not needed
+    Observer Observer
+    // Workers is the number of images rendered (WritePNGs) or input files read (FromPNGs) at the same time;
+    // runtime.GOMAXPROCS if 0 or less. Sequential overrides it.
+    Workers int
+    // Sequential disables the concurrent rendering & reading of images: elements & files are processed strictly one
+    // after the other, in order, so a failure can be reproduced (& bisected) exactly. Meant for debugging with Trace.
+    Sequential bool
+    // Logger receives the messages of the operations on the set, Trace a line for each step of writing & reading it;
+    // the defaults set by SetLogger & SetTrace if nil (see debug.go)
+    Logger *slog.Logger
+    Trace  *log.Logger
+    // MaxFileSize limits the size of the data restored by RestoreData after decompression & of the files read by
+    // FromFS (in bytes); DefaultMaxFileSize if 0, unlimited if negative
+    MaxFileSize int64
+    // Salvage makes StoreData restore an incomplete or damaged set as far as possible instead of failing: missing &
+    // damaged elements are filled with zero bytes or left out (see salvage.go)
+    Salvage SalvageMode
+    // Damage describes the holes in the data restored in salvage mode; set by StoreData & WriteData
+    Damage *DamageReport
 }
 
 // unbound methods (object creation etc...)
@@ -85,20 +237,52 @@ func MakeQrElements(elementCount uint64) *QrElements {
     return qrf
 }
 
-// GetElements creates a number of elements from a given string to be stored (usually a hex-encoded string containing the data of a given file)
+// GetElements creates a number of elements from a given string to be stored: a hex-encoded string containing the data of a given file
+// (see QrFile.ToHexString); other strings are rejected, naming the offset of the first invalid character.
+// There is always at least one element: an empty string results in a single element without payload.
 func GetElements(payload string) (elements *QrElements, err error) {
-    var maxCount uint64 = uint64(len(payload)) / qrDataSize
-    if uint64(len(payload))%qrDataSize != 0 {
-        maxCount++
+    return getElements(payload, VersionLegacy, qrDataSize, nil)
+}
+
+// GetElementsPlain works like GetElements, but creates elements in plain format: the text of each code is short and
+// starts with a header like "QRF v2 3/17 #1a2b3c4d *9c2e" (position, set ID & header checksum), so single codes can be
+// read by any scanner app and pasted into ImportStrings. The header carries a set ID derived from the payload, so
+// several sets can be told apart (see SplitSets).
+func GetElementsPlain(payload string) (elements *QrElements, err error) {
+    return getElements(payload, VersionPlain, plainDataSize, nil)
+}
+
+// getElements splits the payload into elements of the given format version, each holding up to dataSize characters
+func getElements(payload string, version int, dataSize uint64, align []uint64) (elements *QrElements, err error) {
+    err = validatePayload(payload)
+    if err != nil {
+        return nil, err
+    }
+    // an empty payload results in a single element without payload, which restores to an empty file
+    starts := chunkStarts(uint64(len(payload)), dataSize, align)
+    maxCount := uint64(len(starts))
+    err = checkElementCount(maxCount)
+    if err != nil {
+        return nil, err
     }
     elements = MakeQrElements(maxCount)
+    setID := makeSetID(payload)
     var i uint64
     for i = 0; i < maxCount; i++ {
         //log.Printf("Creating element: %d %d", i, maxCount)
-        if int((i+1)*qrDataSize) > len(payload) {
-            elements.Elements[i], err = GetElement(i, maxCount-1, payload[i*qrDataSize:])
-        } else {
-            elements.Elements[i], err = GetElement(i, maxCount-1, payload[i*qrDataSize:(i+1)*qrDataSize])
+        end := uint64(len(payload))
+        if i+1 < maxCount {
+            end = starts[i+1]
+        }
+        // validated above; the elements hold the data, not the hex characters
+        chunk, _ := hex.DecodeString(payload[starts[i]:end])
+        switch version {
+        case VersionPlain:
+            elements.Elements[i] = QrElement{Version: VersionPlain, SetID: setID, Index: i, MaxIndex: maxCount - 1, PayloadLength: 2 * uint64(len(chunk)), Payload: chunk}
+        case VersionCompact:
+            elements.Elements[i], err = compactElement(i, maxCount-1, chunk)
+        default:
+            elements.Elements[i], err = legacyElement(i, maxCount-1, chunk)
         }
         if err != nil {
             return
@@ -107,16 +291,51 @@ func GetElements(payload string) (elements *QrElements, err error) {
     return
 }
 
-// GetElement creates a single QrElement
+// chunkStarts returns the offsets at which the chunks of a payload of the given length start: every dataSize characters
+// & additionally at each offset in align (in characters). There is at least one chunk.
+func chunkStarts(length uint64, dataSize uint64, align []uint64) []uint64 {
+    sorted := append([]uint64(nil), align...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    starts := []uint64{0}
+    for pos := uint64(0); ; {
+        next := pos + dataSize
+        for _, offset := range sorted {
+            if offset > pos && offset < next {
+                next = offset
+                break
+            }
+        }
+        if next >= length {
+            return starts
+        }
+        starts = append(starts, next)
+        pos = next
+    }
+}
+
+// GetElement creates a single QrElement. The payload has to be hex encoded data.
 func GetElement(idx uint64, maxidx uint64, payload string) (elem QrElement, err error) {
+    elem.Version = VersionLegacy
     elem.Index = idx
     elem.MaxIndex = maxidx
     if len(payload) > int(qrDataSize) {
-        return elem, errors.New("Payload size exceeds maximum data size")
+        return elem, sizeError(uint64(len(payload)), qrDataSize, "Payload size exceeds maximum data size")
     }
-    elem.PayloadLength = uint64(len(payload))
-    elem.Payload = fmt.Sprintf(payloadFormat, payload)
-    return
+    err = validatePayload(payload)
+    if err != nil {
+        return elem, err
+    }
+    data, _ := hex.DecodeString(payload)
+    return legacyElement(idx, maxidx, data)
+}
+
+// legacyElement creates an element in legacy format holding data
+func legacyElement(index uint64, maxIndex uint64, data []byte) (QrElement, error) {
+    elem := QrElement{Version: VersionLegacy, Index: index, MaxIndex: maxIndex, PayloadLength: 2 * uint64(len(data)), Payload: data}
+    if elem.PayloadLength > qrDataSize {
+        return elem, sizeError(elem.PayloadLength, qrDataSize, "Payload size exceeds maximum data size")
+    }
+    return elem, nil
 }
 
 // bound methods
@@ -138,24 +357,58 @@ func FromFile(fname string) (*QrFile, error) {
     return qrf, err
 }
 
+// FromBytes creates a QrFile instance holding data, e.g. data created in memory by a server, without a file name. The
+// slice is used as it is, not copied. See FromReader for data read from an io.Reader.
+func FromBytes(data []byte) *QrFile {
+    if data == nil {
+        data = make([]byte, 0)
+    }
+    return &QrFile{Data: data}
+}
+
 // ToHexString provides the file contents encoded in a hex string
 func (qrf *QrFile) ToHexString() (str string) {
     str = hex.EncodeToString(qrf.Data)
     return
 }
 
-// ToFile stores the data contained in the QrFile instance to a file (filename stored in QrFile instance as well)
+// WriteTo writes the data of the QrFile instance to w, e.g. stdout (see io.WriterTo)
+func (qrf *QrFile) WriteTo(w io.Writer) (int64, error) {
+    n, err := w.Write(qrf.Data)
+    return int64(n), err
+}
+
+// ToFile stores the data contained in the QrFile instance to a file (filename stored in QrFile instance as well). The
+// data is written to a temporary file which replaces the file once complete (see AtomicFile), so a failed write leaves
+// any existing file untouched. An existing file is only replaced if Overwrite is set; otherwise an error satisfying
+// os.IsExist is returned. Mode & ModTime are applied if set; a replaced file keeps its permissions otherwise.
 func (qrf *QrFile) ToFile() (err error) {
-    file, err := os.Create(qrf.Fname)
+    file, err := CreateAtomic(qrf.Fname, qrf.Overwrite)
     if err != nil {
         return err
     }
-    defer file.Close()
+    defer file.Abort()
     _, err = file.Write(qrf.Data)
     if err != nil {
         return err
     }
-    return nil
+    mode, replaced := existingMode(qrf.Fname)
+    if qrf.Mode != 0 {
+        mode, replaced = qrf.Mode.Perm(), true
+    }
+    if replaced {
+        err = file.Chmod(mode)
+        if err != nil {
+            return err
+        }
+    }
+    if !qrf.ModTime.IsZero() {
+        err = os.Chtimes(file.File.Name(), qrf.ModTime, qrf.ModTime)
+        if err != nil {
+            return err
+        }
+    }
+    return file.Commit()
 }
 
 // ReadFile reads the file defined by Fname (in binary mode) and stores in in the internal buffer
@@ -169,178 +422,1279 @@ func (qrf *QrFile) ReadFile() (err error) {
     if err != nil {
         return
     }
+    qrf.Mode, qrf.ModTime = info.Mode().Perm(), info.ModTime()
     var size int64 = info.Size()
+    if limit := sizeLimit(qrf.MaxSize); limit > 0 && size > limit {
+        codes := (2*uint64(size) + qrDataSize - 1) / qrDataSize
+        return sizeError(uint64(size), uint64(limit), "File %s is too large (%d bytes, maximum %d); it would need about %d codes", qrf.Fname, size, limit, codes)
+    }
     qrf.Data = make([]byte, size)
     buffer := bufio.NewReader(file)
-    _, err = buffer.Read(qrf.Data)
+    _, err = io.ReadFull(buffer, qrf.Data)
     return
 }
 
 // methods for QrElement
 
-// ParsePNG parses a png image. This makes use of zbarimg from the zbar suite (http://zbar.sourceforge.net/) for parsing.
+// ParsePNG parses a png image, using the default decoder (see ZbarFallback). The image has to hold exactly one code; use
+// FromPNGs (or FromImages) for images holding several codes.
 func (elem *QrElement) ParsePNG(fname string) error {
-    var result bytes.Buffer
-    cmd := exec.Command("zbarimg", "--quiet", "-Sdisable", "-Sqrcode.enable", fname)
-    cmd.Stdout = &result
-    err := cmd.Run()
+    symbols, err := scanFile(context.Background(), fname, nil)
     if err != nil {
         return err
     }
-    if elem.ParseString(strings.TrimSuffix(strings.TrimPrefix(result.String(), "QR-Code:"), "\n")) != nil {
+    if len(symbols) != 1 {
+        return errors.New(fmt.Sprintf("%s holds %d codes, expected a single one", fname, len(symbols)))
+    }
+    return elem.ParseString(symbols[0].Text)
+}
+
+// ParseImage parses an image which was decoded already (e.g. a camera frame), using the default decoder (see
+// ZbarFallback). The image has to hold exactly one code; use FromImages for images holding several codes.
+func (elem *QrElement) ParseImage(img image.Image) error {
+    symbols, err := decodeSymbols(context.Background(), defaultDecoder{}, img)
+    if err != nil {
         return err
     }
-    return nil
+    if len(symbols) != 1 {
+        return errors.New(fmt.Sprintf("The image holds %d codes, expected a single one", len(symbols)))
+    }
+    return elem.ParseString(symbols[0].Text)
+}
+
+// ParseReader parses an image read from r in any supported format (see ReadImages), e.g. an upload, without storing it
+// in a file first. Like ParseImage, it uses the default decoder & the image has to hold exactly one code.
+func (elem *QrElement) ParseReader(r io.Reader) error {
+    images, err := ReadImages(r)
+    if err != nil {
+        return err
+    }
+    symbols, err := scanImages(context.Background(), "input", images, nil)
+    if err != nil {
+        return err
+    }
+    if len(symbols) != 1 {
+        return errors.New(fmt.Sprintf("The input holds %d codes, expected a single one", len(symbols)))
+    }
+    return elem.ParseString(symbols[0].Text)
 }
 
 // AsString formats a QrElement for printing
 func (elem *QrElement) AsString() string {
-    return fmt.Sprintf(outputFormat, elem.Index, elem.MaxIndex, elem.PayloadLength, elem.Payload)
+    if elem.Version == VersionPlain {
+        header := fmt.Sprintf("%s%d/%d", plainPrefix, elem.Index+1, elem.MaxIndex+1)
+        if len(elem.SetID) > 0 {
+            header += " " + setIDPrefix + elem.SetID
+        }
+        header += " " + headerChecksumPrefix + elem.headerChecksum()
+        if len(elem.Fields) > 0 {
+            header += " " + elem.Fields.token()
+        }
+        return header + " " + elem.Codec.encode(elem.Payload)
+    }
+    if elem.Version == VersionCompact {
+        return elem.compactString()
+    }
+    return fmt.Sprintf(outputFormat, elem.Index, elem.MaxIndex, elem.PayloadLength, hex.EncodeToString(elem.Payload))
+}
+
+// maxElementCount limits the number of elements of a set: indices are 32 bit numbers in all formats. Sets are checked
+// against it when they are split (see checkSetSize) & the count announced by a code when it is parsed.
+const maxElementCount uint64 = 1 << 32
+
+// checkElementCount returns an error if a set of count elements exceeds maxElementCount
+func checkElementCount(count uint64) error {
+    if count > maxElementCount {
+        return sizeError(count, maxElementCount, "The data needs %d codes, more than the maximum of %d codes of a set. Compress the data or raise the chunk size.", count, maxElementCount)
+    }
+    return nil
+}
+
+// ParseError describes why the text of a code is no valid element (see ParseString). The text of a code is untrusted
+// input; malformed text is always reported with a ParseError, never by a panic.
+type ParseError struct {
+    Field    string // part of the text which is malformed: "text", "version", "index", "count", "length", "set ID" or "payload"
+    Reason   string
+    checksum bool // set if the text failed a checksum, see Is
+}
+
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("Malformed element %s: %s", e.Field, e.Reason)
+}
+
+// parseError creates a ParseError
+func parseError(field string, format string, args ...interface{}) error {
+    return &ParseError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// parseChecksumError creates a ParseError for text failing a checksum, matched by ErrChecksumMismatch
+func parseChecksumError(field string, format string, args ...interface{}) error {
+    return &ParseError{Field: field, Reason: fmt.Sprintf(format, args...), checksum: true}
 }
 
 // ParseString is used during conversion from a parsed QR code. This parses the string contents & stores them in the QrElement.
-func (elem *QrElement) ParseString(str string) (err error) {
+// The format version is detected automatically & stored in Version: codes with a version header ("QRF v2 ...",
+// "QRF:..."), & the fixed width legacy codes without one; codes of an unknown version are rejected with a ParseError
+// of the field "version". The text is checked strictly: only printable ASCII characters, header
+// fields within their bounds & a payload of the announced length (hex encoded, or base64 in plain format, see codec.go)
+// are accepted; anything else results in a *ParseError. The element is only modified if the text is valid.
+func (elem *QrElement) ParseString(str string) error {
+    // surrounding white space (e.g. a line break added by a scanner) is fine, anything else has to be printable
+    trimmed := strings.TrimSpace(str)
+    for i := 0; i < len(trimmed); i++ {
+        if c := trimmed[i]; c < ' ' || c > '~' {
+            return parseError("text", "invalid character 0x%02x at position %d", c, i)
+        }
+    }
+    var parsed QrElement
+    var err error
+    if strings.HasPrefix(trimmed, plainPrefix) {
+        err = parsed.parsePlain(trimmed)
+    } else if strings.HasPrefix(trimmed, compactPrefix) {
+        err = parsed.parseCompact(trimmed)
+    } else if version, ok := textVersion(trimmed); ok {
+        return parseError("version", "unsupported version %s, the code was created by a newer version of qrFile", version)
+    } else {
+        err = parsed.parseLegacy(str)
+    }
+    if err != nil {
+        return err
+    }
+    *elem = parsed
+    return nil
+}
+
+// textVersion returns the version of a text starting with a version header other than the ones parsed by ParseString
+func textVersion(text string) (string, bool) {
+    if !strings.HasPrefix(text, versionPrefix) {
+        return "", false
+    }
+    version := strings.TrimPrefix(text, versionPrefix)
+    if end := strings.IndexByte(version, ' '); end >= 0 {
+        version = version[:end]
+    }
+    for _, c := range version {
+        if c < '0' || c > '9' {
+            return "", false
+        }
+    }
+    return version, len(version) > 0
+}
+
+// parseLegacy parses the text of an element in legacy format: three right aligned decimal numbers of uintStringLength
+// characters (index, maximum index & payload length), followed by the payload padded to qrDataSize characters
+func (elem *QrElement) parseLegacy(str string) (err error) {
     if uint64(len(str)) != qrSize {
-        return errors.New(fmt.Sprintf("Size mismatch. Expected %d, got %d!", qrSize, len(str)))
+        return parseError("text", "size mismatch, expected %d characters, got %d", qrSize, len(str))
     }
-    elem.Index, err = strconv.ParseUint(strings.Trim(str[indexPos:indexPos+uintStringLength], " "), 10, 16)
+    elem.Version = VersionLegacy
+    elem.Index, err = parseHeaderField(str[indexPos:indexPos+uintStringLength], "index")
     if err != nil {
         return err
     }
-    elem.MaxIndex, err = strconv.ParseUint(strings.Trim(str[maxIndexPos:maxIndexPos+uintStringLength], " "), 10, 16)
+    elem.MaxIndex, err = parseHeaderField(str[maxIndexPos:maxIndexPos+uintStringLength], "count")
     if err != nil {
         return err
     }
-    elem.PayloadLength, err = strconv.ParseUint(strings.Trim(str[payloadLengthPos:payloadLengthPos+uintStringLength], " "), 10, 16)
+    if elem.Index > elem.MaxIndex {
+        return parseError("index", "%d exceeds the maximum index %d", elem.Index, elem.MaxIndex)
+    }
+    elem.PayloadLength, err = parseHeaderField(str[payloadLengthPos:payloadLengthPos+uintStringLength], "length")
     if err != nil {
         return err
     }
-    elem.Payload = string(strings.Trim(str[payloadPos:], " "))
+    if elem.PayloadLength > qrDataSize {
+        return parseError("length", "%d exceeds the maximum of %d", elem.PayloadLength, qrDataSize)
+    }
+    payload := strings.TrimLeft(str[payloadPos:], " ")
+    if uint64(len(payload)) != elem.PayloadLength {
+        return parseError("payload", "expected %d characters, got %d", elem.PayloadLength, len(payload))
+    }
+    err = checkPayload(payload)
+    if err != nil {
+        return err
+    }
+    elem.Payload, _ = hex.DecodeString(payload)
+    return nil
+}
+
+// parseHeaderField parses a right aligned decimal number of the legacy header
+func parseHeaderField(field string, name string) (uint64, error) {
+    digits := strings.TrimLeft(field, " ")
+    if len(digits) == 0 {
+        return 0, parseError(name, "empty")
+    }
+    for _, c := range digits {
+        if c < '0' || c > '9' {
+            return 0, parseError(name, "%q is no decimal number", digits)
+        }
+    }
+    value, err := strconv.ParseUint(digits, 10, 32)
+    if err != nil {
+        return 0, parseError(name, "%s out of range", digits)
+    }
+    return value, nil
+}
+
+// checkPayload checks that a payload is hex encoded data: an even amount of hex digits
+func checkPayload(payload string) error {
+    if len(payload)%2 != 0 {
+        return parseError("payload", "odd length %d", len(payload))
+    }
+    if i := invalidHexOffset(payload); i >= 0 {
+        return parseError("payload", "invalid character %q at position %d", payload[i], i)
+    }
     return nil
 }
 
-// AsQR creates a qr instance containing the data stored in the QrElement
+// invalidHexOffset returns the offset of the first character of s which is no hex digit, -1 if there is none
+func invalidHexOffset(s string) int {
+    for i := 0; i < len(s); i++ {
+        if c := s[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+            return i
+        }
+    }
+    return -1
+}
+
+// validatePayload checks the payload of elements to be created: the data of a file hex encoded (see
+// QrFile.ToHexString), so it can be restored by StoreData
+func validatePayload(payload string) error {
+    if i := invalidHexOffset(payload); i >= 0 {
+        return errors.New(fmt.Sprintf("Payload is not hex encoded: invalid character %q at offset %d", payload[i], i))
+    }
+    if len(payload)%2 != 0 {
+        return errors.New(fmt.Sprintf("Payload is not hex encoded: odd length %d", len(payload)))
+    }
+    return nil
+}
+
+// headerChecksumPrefix marks the header checksum in the text of an element in plain format
+const headerChecksumPrefix = "*"
+
+// headerChecksumLength is the amount of hex characters of a header checksum
+const headerChecksumLength = 4
+
+// headerChecksum returns the checksum of the header of an element in plain format: the lower 16 bits of the CRC-32 of
+// position, set ID, payload length & custom fields (if any). It does not depend on the payload itself, so a misread
+// header is detected while parsing, before any data is restored.
+func (elem *QrElement) headerChecksum() string {
+    header := fmt.Sprintf("%d/%d%s%s:%d", elem.Index+1, elem.MaxIndex+1, setIDPrefix, elem.SetID, 2*len(elem.Payload))
+    if len(elem.Fields) > 0 {
+        header += elem.Fields.token()
+    }
+    sum := crc32.ChecksumIEEE([]byte(header))
+    return fmt.Sprintf("%04x", sum&0xffff)
+}
+
+// parsePlain parses the text of an element in plain format ("QRF v2 <number>/<count> [#<set ID>] [*<header checksum>]
+// <payload>"). Codes created before the header checksum was introduced are accepted without one. The payload is
+// decoded with the codec it was encoded with (see codec.go).
+func (elem *QrElement) parsePlain(str string) error {
+    body := strings.TrimPrefix(str, plainPrefix)
+    // a base45 payload may hold spaces, so it is cut off before the header is split into fields
+    enclosed := ""
+    if start := strings.Index(body, " "+base45Delimiter); start >= 0 {
+        enclosed = body[start+1:]
+        body = body[:start]
+    }
+    fields := strings.Fields(body)
+    elem.SetID = ""
+    if len(fields) > 1 && strings.HasPrefix(fields[1], setIDPrefix) {
+        elem.SetID = strings.TrimPrefix(fields[1], setIDPrefix)
+        fields = append(fields[:1], fields[2:]...)
+        if len(elem.SetID) != setIDLength {
+            return parseError("set ID", "expected %d characters, got %d", setIDLength, len(elem.SetID))
+        }
+        if _, err := hex.DecodeString(elem.SetID); err != nil {
+            return parseError("set ID", "%q is not hex encoded", elem.SetID)
+        }
+    }
+    checksum := ""
+    if len(fields) > 1 && strings.HasPrefix(fields[1], headerChecksumPrefix) {
+        checksum = strings.TrimPrefix(fields[1], headerChecksumPrefix)
+        fields = append(fields[:1], fields[2:]...)
+        if len(checksum) != headerChecksumLength || invalidHexOffset(checksum) >= 0 {
+            return parseError("checksum", "%q is no valid header checksum", checksum)
+        }
+    }
+    elem.Fields = nil
+    if len(fields) > 1 && strings.HasPrefix(fields[1], fieldsPrefix) {
+        custom, err := parseFieldsToken(fields[1])
+        if err != nil {
+            return parseError("fields", "%s", err)
+        }
+        elem.Fields = custom
+        fields = append(fields[:1], fields[2:]...)
+    }
+    if len(enclosed) > 0 {
+        fields = append(fields, enclosed)
+    }
+    if len(fields) < 1 || len(fields) > 2 {
+        return parseError("text", "expected a position and at most one payload, got %d fields", len(fields))
+    }
+    position := strings.Split(fields[0], "/")
+    if len(position) != 2 {
+        return parseError("index", "malformed position %q", fields[0])
+    }
+    number, err := parsePlainNumber(position[0], "index")
+    if err != nil {
+        return err
+    }
+    count, err := parsePlainNumber(position[1], "count")
+    if err != nil {
+        return err
+    }
+    if count < 1 || count > maxElementCount {
+        return parseError("count", "%d out of range (1 to %d)", count, maxElementCount)
+    }
+    if number < 1 || number > count {
+        return parseError("index", "invalid position %s", fields[0])
+    }
+    elem.Version = VersionPlain
+    elem.Index = number - 1
+    elem.MaxIndex = count - 1
+    elem.Payload = nil
+    elem.Codec = CodecHex
+    if len(fields) == 2 {
+        elem.Payload, elem.Codec, err = decodePayload(fields[1])
+        if err != nil {
+            return err
+        }
+    }
+    elem.PayloadLength = 2 * uint64(len(elem.Payload))
+    if len(checksum) > 0 && !strings.EqualFold(checksum, elem.headerChecksum()) {
+        return parseChecksumError("checksum", "header %s does not match its checksum %s", fields[0], checksum)
+    }
+    return elem.checkPayloadCRC()
+}
+
+// parsePlainNumber parses a decimal number of the plain header
+func parsePlainNumber(digits string, name string) (uint64, error) {
+    if len(digits) == 0 || len(digits) > uintStringLength {
+        return 0, parseError(name, "%q is no valid number", digits)
+    }
+    for _, c := range digits {
+        if c < '0' || c > '9' {
+            return 0, parseError(name, "%q is no decimal number", digits)
+        }
+    }
+    value, err := strconv.ParseUint(digits, 10, 64)
+    if err != nil {
+        return 0, parseError(name, "%s out of range", digits)
+    }
+    return value, nil
+}
+
+// AsQR creates a qr instance containing the data stored in the QrElement, at the default error correction level, as
+// RscEncoder renders it. Use AsImage to render the element with another encoder.
 func (elem *QrElement) AsQR() (*qr.Code, error) {
-    return qr.Encode(elem.AsString(), qrLevel)
+    return elem.AsQRWithLevel(qrLevel)
+}
+
+// AsQRWithLevel works like AsQR, but uses the given error correction level (see EncodeOptions.Level)
+func (elem *QrElement) AsQRWithLevel(level Level) (*qr.Code, error) {
+    return encodeRsc(elem.AsString(), level)
+}
+
+// AsImage renders the QrElement with an encoder (DefaultEncoder if nil) at the given error correction level
+func (elem *QrElement) AsImage(encoder SymbolEncoder, level Level) (image.Image, error) {
+    if encoder == nil {
+        encoder = DefaultEncoder
+    }
+    return encoder.Encode(elem.AsString(), level)
 }
 
 // methods for QrElements
 
-// WritePNGs creates a set of PNG images; one for each QrElement stored. Each element spawns a go routine.
+// WritePNGs creates a set of PNG images; one for each QrElement stored. Up to Workers images are rendered at the same
+// time, by the SymbolEncoder set in Encoder. A manifest (see Manifest) listing the images is written next to them as
+// <fnamePrefix>manifest.json.
+// The directory is locked while the set is written (see LockDirectory).
 func (elem *QrElements) WritePNGs(workPath string, fnamePrefix string) error {
-    control := make(chan error, len(elem.Elements))
-    for i, v := range elem.Elements {
-        v := v // we need to shadow v here so we work on copies
-        go func(i int, v *QrElement) {
-            //log.Printf("Creating png for: %d %d %d %d |%s...|", i, v.Index, v.MaxIndex, v.PayloadLength, v.Payload[0:10])
-            qr, err := v.AsQR()
-            if err != nil {
-                control <- err
+    return elem.WritePNGsContext(context.Background(), workPath, fnamePrefix)
+}
+
+// WritePNGsContext works like WritePNGs, but can be aborted: once ctx is canceled, no further images are rendered,
+// running encoders are stopped (see ContextEncoder) & ctx.Err() is returned without waiting for the images in progress.
+// The images written so far are left in place; the manifest is not written.
+func (elem *QrElements) WritePNGsContext(ctx context.Context, workPath string, fnamePrefix string) error {
+    return elem.WritePNGsToContext(ctx, DirStorage(workPath), fnamePrefix)
+}
+
+// WritePNGsTo works like WritePNGs, but writes the images & the manifest (& the checksums file, see Checksums) to a
+// Storage, e.g. a remote HTTPStorage
+func (elem *QrElements) WritePNGsTo(storage Storage, fnamePrefix string) error {
+    return elem.WritePNGsToContext(context.Background(), storage, fnamePrefix)
+}
+
+// WritePNGsToContext works like WritePNGsTo, but can be aborted (see WritePNGsContext)
+func (elem *QrElements) WritePNGsToContext(ctx context.Context, storage Storage, fnamePrefix string) error {
+    unlock, err := lockStorage(storage)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    var sums *checksumStorage
+    if elem.Checksums {
+        sums = newChecksumStorage(storage)
+        storage = sums
+    }
+    positions := make([]int, len(elem.Elements))
+    for i := range positions {
+        positions[i] = i
+    }
+    err = elem.writePNGs(ctx, storage, fnamePrefix, positions)
+    if err != nil {
+        return err
+    }
+    if elem.ParameterChunk {
+        err = elem.writeParameters(ctx, storage, fnamePrefix)
+        if err != nil {
+            return err
+        }
+    }
+    // describe the set in a manifest next to the images
+    manifest := elem.Manifest()
+    for i := range manifest.Chunks {
+        manifest.Chunks[i].Image = elem.imageName(fnamePrefix, i)
+    }
+    out, err := storage.Create(fnamePrefix + ManifestName)
+    if err != nil {
+        return err
+    }
+    err = manifest.Write(out)
+    if err != nil {
+        out.Close()
+        return err
+    }
+    err = out.Close()
+    if err != nil || sums == nil {
+        return err
+    }
+    return sums.write(fnamePrefix + ChecksumsName)
+}
+
+// ZipImagePrefix is the prefix of the images in the archives written by WriteZip
+const ZipImagePrefix = "img_"
+
+// WriteZip works like WritePNGs, but streams the images & the manifest (& the checksums file, see Checksums) into a
+// single zip archive written to w, named like in a directory written with the prefix ZipImagePrefix
+func (elem *QrElements) WriteZip(w io.Writer) error {
+    return elem.WriteZipContext(context.Background(), w)
+}
+
+// WriteZipContext works like WriteZip, but can be aborted (see WritePNGsContext); the archive is incomplete then
+func (elem *QrElements) WriteZipContext(ctx context.Context, w io.Writer) error {
+    storage := NewZipStorage(w)
+    err := elem.WritePNGsToContext(ctx, storage, ZipImagePrefix)
+    if err != nil {
+        return err
+    }
+    return storage.Close()
+}
+
+// WriteZipFile writes the images & the manifest to the zip archive fname, see WriteZip
+func (elem *QrElements) WriteZipFile(fname string) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.WriteZip(file)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// RewritePNGs renders the images of the elements with the given indices again, e.g. to replace pages which printed
+// badly. The images are named like the ones written by WritePNGs; with the same elements (from the original file or a
+// container, see Unpack) and the same Encoder, they are identical to the original images. The manifest is not written.
+func (elem *QrElements) RewritePNGs(workPath string, fnamePrefix string, indices []uint64) error {
+    return elem.RewritePNGsTo(DirStorage(workPath), fnamePrefix, indices)
+}
+
+// RewritePNGsTo works like RewritePNGs, but writes the images to a Storage
+func (elem *QrElements) RewritePNGsTo(storage Storage, fnamePrefix string, indices []uint64) error {
+    positions := make([]int, 0, len(indices))
+    for _, index := range indices {
+        found := false
+        for i, v := range elem.Elements {
+            if v.Index == index {
+                positions = append(positions, i)
+                found = true
+                break
             }
+        }
+        if !found {
+            return errors.New(fmt.Sprintf("Element %d is not part of the set", index))
+        }
+    }
+    unlock, err := lockStorage(storage)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    return elem.writePNGs(context.Background(), storage, fnamePrefix, positions)
+}
 
-            imgByte := qr.PNG()
-            img, _, _ := image.Decode(bytes.NewReader(imgByte))
-            var fname = fmt.Sprintf("%s/%s%d.png", workPath, fnamePrefix, i)
-            out, err := os.Create(fname)
-            /*if err != nil {
-                control <- err
-            }*/
-            err = png.Encode(out, img)
-            if err != nil {
+// imageNameHashLength is the number of hex characters of the element hash used in image names (see ContentNames)
+const imageNameHashLength = 8
+
+// imageName returns the name of the image of the element at position i: <prefix><i>.png or, if ContentNames is set,
+// <prefix><i>_<hash>.png with the start of the hash of the element (see QrElement.Hash)
+func (elem *QrElements) imageName(fnamePrefix string, i int) string {
+    if elem.ContentNames {
+        return fmt.Sprintf("%s%d_%s.png", fnamePrefix, i, elem.Elements[i].Hash()[:imageNameHashLength])
+    }
+    return fmt.Sprintf("%s%d.png", fnamePrefix, i)
+}
+
+// writePNGs writes the images of the elements at the given positions, named by position (see imageName), using a pool
+// of Workers workers. Once ctx is canceled, no further images are rendered & ctx.Err() is returned.
+func (elem *QrElements) writePNGs(ctx context.Context, storage Storage, fnamePrefix string, positions []int) error {
+    control := make(chan error, len(positions))
+    var written uint64 // bytes of the images written, for the progress
+    pool := elem.newWorkerPool()
+    // hand out the work while the results are collected, so the progress is reported as the images are written
+    go func() {
+        for _, i := range positions {
+            i, v := i, elem.Elements[i] // we need to copy v here so each go routine works on its own element
+            if !pool.spawn(ctx, func() {
+                //log.Printf("Creating png for: %d %d %d %d |%x...|", i, v.Index, v.MaxIndex, v.PayloadLength, v.Payload[0:10])
+                elem.trace("Rendering element %d", v.Index)
+                start := time.Now()
+                img, err := elem.renderContext(ctx, &v)
+                if err != nil {
+                    elem.trace("Rendering element %d failed: %s", v.Index, err)
+                    control <- err
+                    return
+                }
+
+                if ctx.Err() != nil {
+                    control <- ctx.Err()
+                    return
+                }
+                elem.trace("Writing element %d to %s", v.Index, elem.imageName(fnamePrefix, i))
+                out, err := storage.Create(elem.imageName(fnamePrefix, i))
+                if err != nil {
+                    control <- err
+                    return
+                }
+                counter := &countingWriter{w: out}
+                err = encodePNG(counter, img, elem.Rendering.dpi())
+                if err != nil {
+                    out.Close()
+                    control <- err
+                    return
+                }
+                err = out.Close()
+                if err == nil {
+                    atomic.AddUint64(&written, counter.n)
+                    metrics().ImageWritten(time.Since(start))
+                    elem.observer().OnImageWritten(v.Index, elem.imageName(fnamePrefix, i))
+                } else {
+                    elem.trace("Writing element %d failed: %s", v.Index, err)
+                }
                 control <- err
+            }) {
+                return
             }
-            control <- nil
-        }(i, &v)
-    }
-    errorList := make([]string, 0)
-    for i := 0; i < len(elem.Elements); i++ {
-        result := <-control
+        }
+    }()
+    errorList := make([]error, 0)
+    for i := 0; i < len(positions); i++ {
+        var result error
+        select {
+        case result = <-control:
+        case <-ctx.Done():
+            return ctx.Err()
+        }
         if result != nil {
-            errorList = append(errorList, result.Error())
+            elem.observer().OnError(result)
+            errorList = append(errorList, result)
         }
+        elem.progress(Progress{Stage: StageWriting, Done: i + 1, Total: len(positions), Bytes: atomic.LoadUint64(&written)})
+    }
+    if err := ctx.Err(); err != nil {
+        return err
     }
     if len(errorList) == 0 {
         return nil
     }
-    // concatenate the error messages & return
-    return errors.New(strings.Join(errorList, "; "))
+    return errors.Join(errorList...)
+}
+
+// encoder returns the SymbolEncoder used to render images
+func (elem *QrElements) encoder() SymbolEncoder {
+    if elem.Encoder == nil {
+        return DefaultEncoder
+    }
+    return elem.Encoder
+}
+
+// render creates the image of a single element using the SymbolEncoder set in Encoder, including its digest if Digest
+// is set, its caption if Caption is set & its transcription if Transcribe is set
+func (elem *QrElements) render(v *QrElement) (image.Image, error) {
+    return elem.renderContext(context.Background(), v)
+}
+
+// renderContext works like render, but stops with ctx.Err() once ctx is canceled (see ContextEncoder)
+func (elem *QrElements) renderContext(ctx context.Context, v *QrElement) (image.Image, error) {
+    img, err := elem.encodeContext(ctx, v)
+    if err != nil {
+        return nil, err
+    }
+    if elem.Digest {
+        img = v.addDigest(img)
+    }
+    if elem.Caption != nil {
+        img = elem.addCaption(v, img)
+    }
+    if !elem.Transcribe {
+        return img, nil
+    }
+    return v.addTranscription(img)
+}
+
+// encodeContext renders the code of a single element, drawn with Rendering if it is set, with a Structured Append
+// header if StructuredAppend is set & in PadVersion at least
+func (elem *QrElements) encodeContext(ctx context.Context, v *QrElement) (image.Image, error) {
+    if elem.StructuredAppend {
+        err := checkStructuredAppend(v.MaxIndex+1, elem.Encoder)
+        if err != nil {
+            return nil, err
+        }
+        header := structuredAppendHeader{index: int(v.Index), total: int(v.MaxIndex + 1), parity: elem.structuredAppendParity()}
+        modules, err := encodeStructuredAppend(v.AsString(), elem.levelOf(v.Index), elem.PadVersion, header)
+        if err != nil {
+            return nil, err
+        }
+        return drawModules(modules, elem.Rendering)
+    }
+    if elem.Rendering.isZero() {
+        return encodeVersion(ctx, elem.encoder(), v.AsString(), elem.levelOf(v.Index), elem.PadVersion)
+    }
+    encoder, ok := elem.encoder().(MatrixEncoder)
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("The encoder %T does not support render options", elem.encoder()))
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    modules, err := encodeMatrixVersion(encoder, v.AsString(), elem.levelOf(v.Index), elem.PadVersion)
+    if err != nil {
+        return nil, err
+    }
+    return drawModules(modules, elem.Rendering)
+}
+
+// Image renders the image of the element with the given index, as written by WritePNGs
+func (elem *QrElements) Image(index uint64) (image.Image, error) {
+    for i := range elem.Elements {
+        if elem.Elements[i].Index == index {
+            return elem.render(&elem.Elements[i])
+        }
+    }
+    return nil, errors.New(fmt.Sprintf("Element %d is not part of the set", index))
+}
+
+// Render renders the images of all elements in memory, in the order of Elements, as written by WritePNGs; up to
+// Workers images are rendered at the same time. Nothing is written, so e.g. a server sends the codes in its responses
+// without a work directory.
+func (elem *QrElements) Render() ([]image.Image, error) {
+    return elem.RenderContext(context.Background())
+}
+
+// RenderContext works like Render, but can be aborted: once ctx is canceled, no further images are rendered & ctx.Err()
+// is returned
+func (elem *QrElements) RenderContext(ctx context.Context) ([]image.Image, error) {
+    images := make([]image.Image, len(elem.Elements))
+    control := make(chan error, len(images))
+    pool := elem.newWorkerPool()
+    go func() {
+        for i := range elem.Elements {
+            i, v := i, elem.Elements[i]
+            if !pool.spawn(ctx, func() {
+                img, err := elem.renderContext(ctx, &v)
+                images[i] = img
+                control <- err
+            }) {
+                return
+            }
+        }
+    }()
+    errorList := make([]error, 0)
+    for i := range images {
+        var result error
+        select {
+        case result = <-control:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+        if result != nil {
+            elem.observer().OnError(result)
+            errorList = append(errorList, result)
+        }
+        elem.progress(Progress{Stage: StageWriting, Done: i + 1, Total: len(images)})
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if len(errorList) > 0 {
+        return nil, errors.Join(errorList...)
+    }
+    return images, nil
+}
+
+// RenderPNGs works like Render, but returns the images encoded as png, i.e. the content of the files written by
+// WritePNGs
+func (elem *QrElements) RenderPNGs() ([][]byte, error) {
+    images, err := elem.Render()
+    if err != nil {
+        return nil, err
+    }
+    result := make([][]byte, len(images))
+    for i, img := range images {
+        var data bytes.Buffer
+        err = encodePNG(&data, img, elem.Rendering.dpi())
+        if err != nil {
+            return nil, err
+        }
+        result[i] = data.Bytes()
+    }
+    return result, nil
+}
+
+// levelOf returns the error correction level of the element with the given index
+func (elem *QrElements) levelOf(index uint64) Level {
+    if level, ok := elem.Levels[index]; ok {
+        return level
+    }
+    return elem.Level
 }
 
 // FromPNGs reads a set of png files & stores their contents in a set of QrElement structs. Also provides basic sanity tests (complete set,
-// no duplicates etc...). The file list may contain wildcards (each entry is parsed using filepath.Glob)
+// no duplicates etc...). The file list may contain wildcards (each entry is parsed using filepath.Glob) and directories
+// (standing for all files they contain). Multipage TIFF files, animated GIFs & scanned PDF documents (see FromPDF) are
+// accepted as well, each page or frame holding one element or several, as are JPEG and HEIC/HEIF photos (see HeifConvertPath), BMP
+// images & the formats registered with the image package (e.g. WebP with golang.org/x/image/webp). The orientation recorded by the camera is applied before
+// decoding. Video files are read frame by frame (see FromVideo). File names do not matter: the format is detected from the content & the elements are ordered by the index
+// stored in their header, so renamed or renumbered images are fine. Files which are no images are skipped; images which
+// can not be read are skipped or abort reading, as selected by Mode, and listed in Report. Images read twice are
+// ignored. If the files contain several sets, an error listing them is returned; use FindSets to choose one of them.
+// The parameter code of the set (see ParameterChunk) is picked up among the files & configures the set (see Parameters).
+// Files of other file systems (zip archives, embedded assets) are read with FromFS.
 func (elem *QrElements) FromPNGs(files []string) error {
+    return elem.FromPNGsContext(context.Background(), files)
+}
+
+// FromPNGsContext works like FromPNGs, but can be aborted: once ctx is canceled, no further files are read, running
+// decoders are stopped (see ContextDecoder) & ctx.Err() is returned without waiting for the files in progress. No
+// elements are added to the set then.
+func (elem *QrElements) FromPNGsContext(ctx context.Context, files []string) error {
+    err := elem.readFiles(ctx, files)
+    if err != nil {
+        return err
+    }
+    return elem.Validate()
+}
+
+// FromPDF reads the codes of scanned PDF documents, as printed from WritePDF or from single images: the images of all
+// pages are extracted & every code on a page is read (see pdfinput.go for the image formats supported). It works like
+// FromPNGs, but only accepts PDF documents.
+func (elem *QrElements) FromPDF(files []string) error {
+    fileList, err := expandInputs(files)
+    if err != nil {
+        return err
+    }
+    for _, fname := range fileList {
+        if inputFormat(fname) != "pdf" {
+            return errors.New(fmt.Sprintf("%s is not a PDF document", fname))
+        }
+    }
+    return elem.FromPNGs(fileList)
+}
+
+// expandInputs returns the files a list of inputs stands for (see FromPNGs): wildcards are expanded & directories stand
+// for all files they contain
+func expandInputs(files []string) ([]string, error) {
     fileList := make([]string, 0)
     for _, entry := range files {
         files, _ := filepath.Glob(entry)
-        fileList = append(fileList, files...)
+        for _, fname := range files {
+            // directories stand for all files they contain
+            if info, err := os.Stat(fname); err == nil && info.IsDir() {
+                contents, _ := filepath.Glob(filepath.Join(fname, "*"))
+                for _, content := range contents {
+                    if info, err := os.Stat(content); err == nil && !info.IsDir() && info.Name() != LockName {
+                        fileList = append(fileList, content)
+                    }
+                }
+                continue
+            }
+            fileList = append(fileList, fname)
+        }
     }
     if len(fileList) == 0 {
-        return errors.New(fmt.Sprintf("No files found for input %s", strings.Join(files, ", ")))
+        return nil, errors.New(fmt.Sprintf("No files found for input %s", strings.Join(files, ", ")))
+    }
+    return fileList, nil
+}
+
+// readFiles reads all elements contained in a set of files (see FromPNGs) without checking them. Once ctx is canceled,
+// no further files are read & ctx.Err() is returned.
+func (elem *QrElements) readFiles(ctx context.Context, files []string) error {
+    fileList, err := expandInputs(files)
+    if err != nil {
+        return err
+    }
+    // fail once with a helpful message instead of once per file
+    err = CheckDecoder(elem.Decoder)
+    if err != nil {
+        return err
     }
 
     // spread this into goroutines, collect results afterwards
-    control := make(chan *QrElement, len(fileList))
-    for _, v := range fileList {
-        go func(fname string) {
-            // only handle png files
-            if strings.Index(strings.ToLower(fname), ".png") == len(fname)-4 {
-                newElement := new(QrElement)
-                err := newElement.ParsePNG(fmt.Sprintf(fname))
-                //log.Print("Handling file ", fname)
-                if err == nil {
-                    //log.Printf("Element created: %d %d %d |%s...|", newElement.Index, newElement.MaxIndex, newElement.PayloadLength, newElement.Payload[0:10])
-                    control <- newElement
-                } else {
-                    log.Print(err.Error())
-                    log.Print("No element created.")
-                    control <- nil
-                }
-            } else {
-                log.Print("Not handling file ", fname)
-                control <- nil // we have to notify also if we do not handle the file
+    type fileResult struct {
+        fname    string
+        elements []QrElement
+        err      error
+        skipped  bool
+        foreign  int
+        attempts int
+        size     uint64
+        params   *Parameters
+    }
+    control := make(chan fileResult, len(fileList))
+    pool := elem.newWorkerPool()
+    // reads a single file; scanned holds the codes of the files read by zbarimg in a batch, if any (see scanPNGs)
+    readFile := func(fname string, scanned map[string][]Symbol, elapsed time.Duration) {
+        // only handle supported image files (see imageinput.go)
+        if !isInputFile(fname) {
+            elem.logf(slog.LevelInfo, "Not handling file %s", fname)
+            control <- fileResult{fname: fname, skipped: true} // we have to notify also if we do not handle the file
+            return
+        }
+        elem.trace("Reading %s", fname)
+        start := time.Now()
+        var newElements []QrElement
+        var foreign, attempts int
+        var symbols []Symbol
+        var err error
+        if scanned != nil {
+            var found bool
+            symbols, found = scanned[fname]
+            if !found {
+                err = errors.New(fmt.Sprintf("%s: no code found", fname))
             }
-        }(v)
+        } else {
+            symbols, err = scanFile(ctx, fname, elem.Decoder)
+        }
+        newElements, foreign, attempts, err = parseScanned(ctx, fname, symbols, err, elem.Decoder, elem.Retry, elem.Mode)
+        metrics().ImageDecoded(elapsed + time.Since(start))
+        //log.Print("Handling file ", fname)
+        if err != nil {
+            elem.trace("Reading %s failed after %d attempts: %s", fname, attempts, err)
+            elem.logf(slog.LevelWarn, "%s No element created.", err)
+        } else {
+            elem.trace("Read %d elements (%d foreign codes skipped) from %s in %d attempts", len(newElements), foreign, fname, attempts)
+        }
+        result := fileResult{fname: fname, elements: newElements, err: err, foreign: foreign, attempts: attempts, params: findParameters(symbols)}
+        if info, err := os.Stat(fname); err == nil {
+            result.size = uint64(info.Size())
+        }
+        control <- result
     }
+    // hand out the work while the results are collected, so the progress is reported as the files are read
+    go func() {
+        for _, v := range batchInputs(fileList, elem.Decoder, pool.size()) {
+            batch := v
+            if !pool.spawn(ctx, func() {
+                var scanned map[string][]Symbol
+                var elapsed time.Duration
+                if len(batch) > 1 {
+                    start := time.Now()
+                    var err error
+                    scanned, err = scanPNGs(ctx, batch)
+                    if err != nil {
+                        elem.trace("Reading %d files with a single zbarimg process failed, reading them one by one: %s", len(batch), err)
+                        scanned = nil
+                    }
+                    // the time is shared by the files of the batch
+                    elapsed = time.Since(start) / time.Duration(len(batch))
+                }
+                for _, fname := range batch {
+                    readFile(fname, scanned, elapsed)
+                }
+            }) {
+                return
+            }
+        }
+    }()
 
     // wait for all goroutines to return before starting
+    report := &DecodeReport{Files: len(fileList), Failures: make([]DecodeFailure, 0)}
+    var read uint64 // bytes of the input files read, for the progress
+    known := len(elem.Elements)
     for i := 0; i < len(fileList); i++ {
         // consume the results
-        result := <-control
-        if result != nil {
-            elem.Elements = append(elem.Elements, *result)
-        }   // else {
-        // an error occurred, no appeding
-        //}
+        var result fileResult
+        select {
+        case result = <-control:
+        case <-ctx.Done():
+            elem.Elements = elem.Elements[:known]
+            return ctx.Err()
+        }
+        read += result.size
+        elem.useParameters(result.params)
+        for _, v := range result.elements {
+            v.source = result.fname
+            elem.Elements = append(elem.Elements, v)
+            elem.observer().OnChunkDecoded(v, result.fname)
+            report.Symbols = append(report.Symbols, DecodedSymbol{Index: v.Index, File: result.fname, Symbol: v.symbol, Attempts: result.attempts})
+        }
+        metrics().ChunksDecoded(len(result.elements))
+        report.Elements += len(result.elements)
+        report.Attempts += result.attempts
+        report.Foreign += result.foreign
+        if result.attempts > 1 {
+            report.Retried++
+            if result.err == nil {
+                report.Recovered++
+            }
+        }
+        if result.skipped {
+            report.Skipped++
+        } else if result.err != nil {
+            elem.observer().OnError(result.err)
+            _, timeout := result.err.(*TimeoutError)
+            report.Failures = append(report.Failures, DecodeFailure{File: result.fname, Err: result.err, Timeout: timeout, Attempts: result.attempts})
+        }
+        elem.progress(Progress{Stage: StageReading, Done: i + 1, Total: len(fileList), Bytes: read})
+    }
+    if err := ctx.Err(); err != nil {
+        elem.Elements = elem.Elements[:known]
+        return err
+    }
+    sort.Slice(report.Failures, func(i, j int) bool { return report.Failures[i].File < report.Failures[j].File })
+    sort.SliceStable(report.Symbols, func(i, j int) bool { return report.Symbols[i].Index < report.Symbols[j].Index })
+    elem.Report = report
+    // a set of a newer version is explained by its parameter code rather than by the codes which failed to parse
+    if err := elem.Parameters.check(); err != nil {
+        return err
+    }
+    if elem.Mode == DecodeStrict && len(report.Failures) > 0 {
+        return errors.New(fmt.Sprintf("%d images could not be read:\n%s", len(report.Failures), report))
     }
 
     //log.Printf("Extracted %d elements", elem.Len())
+    return nil
+}
+
+// FromImages reads the codes of images which were decoded already (e.g. scanned sheets or camera frames), any number of
+// codes per image, using the Decoder set (the default decoder if nil), & adds them to the set; the same sanity tests as
+// in FromPNGs are applied. Calibration & sync frames are skipped, a parameter code configures the set (see
+// Parameters). Images without an element are skipped or abort reading, as selected by Mode, and listed in Report, where
+// they are named by their position ("image 3").
+func (elem *QrElements) FromImages(images []image.Image) error {
+    decoder := decoderOrDefault(elem.Decoder)
+    report := &DecodeReport{Files: len(images), Failures: make([]DecodeFailure, 0)}
+    for i, img := range images {
+        name := fmt.Sprintf("image %d", i+1)
+        symbols, err := decodeSymbols(context.Background(), decoder, img)
+        var found []QrElement
+        var foreign int
+        if err == nil && len(symbols) == 0 {
+            err = errors.New(fmt.Sprintf("%s: no code found", name))
+        } else if err == nil {
+            elem.useParameters(findParameters(symbols))
+            found, foreign, err = parseSymbols(name, symbols, elem.Mode)
+        }
+        report.Attempts++
+        if err != nil {
+            elem.observer().OnError(err)
+            _, timeout := err.(*TimeoutError)
+            report.Failures = append(report.Failures, DecodeFailure{File: name, Err: err, Timeout: timeout, Attempts: 1})
+            elem.progress(Progress{Stage: StageReading, Done: i + 1, Total: len(images)})
+            continue
+        }
+        for _, v := range found {
+            v.source = name
+            elem.Elements = append(elem.Elements, v)
+            elem.observer().OnChunkDecoded(v, name)
+            report.Symbols = append(report.Symbols, DecodedSymbol{Index: v.Index, File: name, Symbol: v.symbol, Attempts: 1})
+        }
+        metrics().ChunksDecoded(len(found))
+        report.Elements += len(found)
+        report.Foreign += foreign
+        elem.progress(Progress{Stage: StageReading, Done: i + 1, Total: len(images)})
+    }
+    sort.SliceStable(report.Symbols, func(i, j int) bool { return report.Symbols[i].Index < report.Symbols[j].Index })
+    elem.Report = report
+    if err := elem.Parameters.check(); err != nil {
+        return err
+    }
+    if elem.Mode == DecodeStrict && len(report.Failures) > 0 {
+        return errors.New(fmt.Sprintf("%d images could not be read:\n%s", len(report.Failures), report))
+    }
+    return elem.Validate()
+}
+
+// ImportStrings parses a set of strings as produced by AsString (e.g. the text content of scanned codes) & stores them in
+// a set of QrElement structs. The same sanity tests as in FromPNGs are applied. No external tools are needed for this.
+// Texts of calibration & sync frames (see StreamOptions) are skipped; the text of a parameter code configures the set
+// (see Parameters).
+func (elem *QrElements) ImportStrings(strs []string) error {
+    for i, str := range strs {
+        if strings.HasPrefix(strings.TrimSpace(str), parametersPrefix) {
+            params, err := ParseParameters(str)
+            if err != nil {
+                return fmt.Errorf("Unable to parse string %d: %w", i, err)
+            }
+            elem.useParameters(params)
+            continue
+        }
+        if IsControlText(str) {
+            continue
+        }
+        newElement := new(QrElement)
+        err := newElement.ParseString(str)
+        if err != nil {
+            return fmt.Errorf("Unable to parse string %d: %w", i, err)
+        }
+        elem.Elements = append(elem.Elements, *newElement)
+        elem.observer().OnChunkDecoded(*newElement, "")
+        metrics().ChunksDecoded(1)
+    }
+    if err := elem.Parameters.check(); err != nil {
+        return err
+    }
+    return elem.Validate()
+}
+
+// ImportText reads the text of scanned codes, one code per line (e.g. pasted from a scanner app), & stores them in a set
+// of QrElement structs. Empty lines are skipped; leading whitespace lost while copying the fixed width format is
+// restored. The same sanity tests as in FromPNGs are applied.
+func (elem *QrElements) ImportText(r io.Reader) error {
+    strs := make([]string, 0)
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    for scanner.Scan() {
+        if line, ok := textLine(scanner.Text()); ok {
+            strs = append(strs, line)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    return elem.ImportStrings(strs)
+}
+
+// textLine prepares a line of text input (see ImportText) for parsing; the result is false for empty lines
+func textLine(line string) (string, bool) {
+    line = strings.TrimRight(line, " \t\r")
+    if len(strings.TrimSpace(line)) == 0 {
+        return "", false
+    }
+    if !strings.HasPrefix(strings.TrimSpace(line), plainPrefix) && !IsControlText(line) && uint64(len(line)) < qrSize {
+        line = fmt.Sprintf("%*s", int(qrSize), strings.TrimLeft(line, " \t"))
+    }
+    return line, true
+}
+
+// Validate sorts the elements and checks the set for completeness and duplicates. Elements of several sets (see
+// SplitSets) are rejected; elements read twice are removed. Elements whose count disagrees with the majority of their
+// set are corrected first (see Reconcile). Fails with ErrNoElements if there are no elements at all.
+func (elem *QrElements) Validate() error {
+    if len(elem.Elements) == 0 {
+        return ErrNoElements
+    }
+    elem.trace("Validating %d elements", len(elem.Elements))
+    // misreads are dropped before they are taken for the elements of the set
+    mismatches := elem.checkExpected()
+    if elem.Report != nil {
+        elem.Report.Mismatched = append(elem.Report.Mismatched, mismatches...)
+    }
     if len(elem.Elements) == 0 {
-        return errors.New("No elements extraced.")
+        return errors.New(fmt.Sprintf("None of the %d elements read matches the manifest.", len(mismatches)))
+    }
+    // elements whose count was misread would look like a set of their own
+    outvoted := elem.Reconcile()
+    if elem.Report != nil {
+        elem.Report.Outvoted = append(elem.Report.Outvoted, outvoted...)
+    }
+    if len(outvoted) > 0 {
+        elem.trace("%d elements outvoted", len(outvoted))
     }
-    if uint64(elem.Len()) < elem.Elements[0].MaxIndex {
-        return errors.New("Incomplete set extracted.")
+    if sets := elem.SplitSets(); len(sets) > 1 {
+        return multipleSetsError(sets)
     }
-    sort.Sort(elem)
-    // check that we have no duplicates
-    for i := 0; i < elem.Len()-1; i++ {
-        if !elem.Less(i, i+1) {
-            return errors.New("Duplicate element detected.")
+    sort.Stable(elem)
+    // remove elements read twice (e.g. a page scanned twice); different elements with the same index are an error
+    unique := elem.Elements[:1]
+    duplicates := 0
+    for _, v := range elem.Elements[1:] {
+        last := &unique[len(unique)-1]
+        if v.Index != last.Index {
+            unique = append(unique, v)
+        } else if v.Hash() != last.Hash() {
+            return &ConflictError{Index: v.Index, Sources: []string{last.source, v.source}}
+        } else {
+            duplicates++
         }
     }
+    elem.trace("%d distinct elements, %d duplicates dropped", len(unique), duplicates)
+    if elem.Report != nil {
+        elem.Report.Duplicates += duplicates
+    }
+    elem.Elements = unique
+    // check that we have all elements
+    maxIndex := elem.Elements[0].MaxIndex
+    if last := elem.Elements[elem.Len()-1]; last.Index > maxIndex {
+        return errors.New(fmt.Sprintf("Element %d exceeds the maximum index %d.", last.Index, maxIndex))
+    }
+    // lost elements of a set with parity elements are computed from the others
+    reconstructed, err := elem.reconstruct()
+    if err != nil {
+        return err
+    }
+    if len(reconstructed) > 0 {
+        elem.trace("%d elements reconstructed from parity elements", len(reconstructed))
+        if elem.Report != nil {
+            elem.Report.Reconstructed = append(elem.Report.Reconstructed, reconstructed...)
+        }
+    }
+    if uint64(elem.Len()) != maxIndex+1 {
+        return &IncompleteError{Found: elem.Len(), Total: maxIndex + 1, Missing: elem.Missing()}
+    }
+    elem.observer().OnSetComplete(elem)
     return nil
 }
 
+// ConflictError is returned by Validate & Assembler.Add if two elements with the same index differ in content, e.g. a code misread in
+// a way its checks did not catch (see EncodeOptions.Integrity) or codes of two sets sharing a set ID. Copies of the same
+// element are no conflict; they are dropped.
+type ConflictError struct {
+    Index   uint64   // index of the element
+    Sources []string // files the differing elements were read from; empty if not read from files
+}
+
+func (e *ConflictError) Error() string {
+    sources := make([]string, 0, len(e.Sources))
+    for _, source := range e.Sources {
+        if len(source) > 0 {
+            sources = append(sources, source)
+        }
+    }
+    if len(sources) == len(e.Sources) && len(sources) > 0 {
+        return fmt.Sprintf("Element %d read with different content from %s.", e.Index+1, strings.Join(sources, " and "))
+    }
+    return fmt.Sprintf("Element %d read twice with different content.", e.Index+1)
+}
+
+// IncompleteError is returned by Validate (& thus FromPNGs) if elements of the set were not found, listing them so only
+// the images of the missing elements have to be scanned again
+type IncompleteError struct {
+    Found   int      // number of distinct elements found
+    Total   uint64   // number of elements of the set
+    Needed  uint64   // elements needed to reconstruct the set from its parity elements (see parity.go); 0 without parity
+    Missing []uint64 // indices of the elements not found, in ascending order (see Missing; at most maxMissingListed)
+}
+
+func (e *IncompleteError) Error() string {
+    missing := formatRanges(e.Missing)
+    if unlisted := e.Total - uint64(e.Found) - uint64(len(e.Missing)); uint64(len(e.Missing)) < e.Total-uint64(e.Found) {
+        missing += fmt.Sprintf(" & %d more", unlisted)
+    }
+    if e.Needed > 0 {
+        return fmt.Sprintf("Incomplete set extracted: %d of %d elements found, at least %d are needed to reconstruct the set from its parity elements; missing %s.", e.Found, e.Total, e.Needed, missing)
+    }
+    return fmt.Sprintf("Incomplete set extracted: %d of %d elements found, missing %s.", e.Found, e.Total, missing)
+}
+
+// Missing returns the indices of the elements of the set which are not present, in ascending order; the size of the set
+// is taken from the first element. The images written by WritePNGs are numbered the same way (see imageName), unless
+// only some of them were written.
+func (elem *QrElements) Missing() []uint64 {
+    if elem.Len() == 0 {
+        return []uint64{}
+    }
+    found := make(map[uint64]bool, elem.Len())
+    for _, v := range elem.Elements {
+        found[v.Index] = true
+    }
+    return missingIndices(found, elem.Elements[0].MaxIndex+1)
+}
+
 // StoreData writes the data stored in all QrElement structs in a provided QrFile object. The QrFile object then is used to write the contents to disc.
+// The transforms applied to the data when the set was created (see AppliedTransforms), e.g. compression, are reversed,
+// so the QrFile holds the original data; encrypted data is decrypted by RestoreData, given the secret, & fails here
+// with ErrPassphraseRequired (or ErrKeyRequired, ErrIdentityRequired). StoreRawData keeps the data as it was split.
+// If the set carries integrity fields (see integrity.go), the payload of each element & the restored data are verified
+// against them. If the QrFile has no name yet & the set describes its original file (see FileInfo), name, mode &
+// modification time are taken from it. In salvage mode (see Salvage) missing or damaged elements do not fail it; the
+// holes in the data are described in Damage & the transforms are not reversed, as they can not be with holes in it.
 func (elem *QrElements) StoreData(fileObject *QrFile) error {
+    return elem.RestoreData(fileObject, nil)
+}
+
+// StoreRawData works like StoreData, but stores the data as it was split into the elements, without reversing the
+// transforms applied to it, e.g. to check or split it again
+func (elem *QrElements) StoreRawData(fileObject *QrFile) error {
+    if info := elem.FileInfo(); info != nil && len(fileObject.Fname) == 0 {
+        fileObject.Fname, fileObject.Mode, fileObject.ModTime = info.Name, info.Mode, info.ModTime
+    }
+    buffer := bytes.NewBuffer(fileObject.Data)
+    err := elem.WriteData(buffer)
+    fileObject.Data = buffer.Bytes()
+    return err
+}
+
+// WriteData writes the data stored in the elements to w, element by element in the order of the elements (see
+// Validate), without collecting it in memory first. The integrity fields of the set are verified like in StoreData;
+// as the data is written on the way, a mismatch of the SHA-256 is only reported after all of it was written.
+func (elem *QrElements) WriteData(w io.Writer) error {
+    return elem.writeData(w, true)
+}
+
+// writeData writes the data stored in the elements to w (see WriteData); report selects whether the progress is
+// reported to the Observer, which is not the case if the data is only hashed (see Sign)
+func (elem *QrElements) writeData(w io.Writer, report bool) error {
+    if report && elem.Salvage != SalvageOff {
+        return elem.salvageData(w)
+    }
+    digest := sha256.New()
+    dataCount := elem.dataCount()
+    total := 0
     for _, v := range elem.Elements {
-        //log.Printf("Storing data for %d %d %d |%s...|", v.Index, v.MaxIndex, v.PayloadLength, v.Payload[0:10])
-        buffer, err := hex.DecodeString(v.Payload)
+        if v.Index < dataCount {
+            total++
+        }
+    }
+    done, written := 0, uint64(0)
+    for _, v := range elem.Elements {
+        if v.Index >= dataCount {
+            // parity elements hold no data (see parity.go)
+            continue
+        }
+        //log.Printf("Storing data for %d %d %d |%x...|", v.Index, v.MaxIndex, v.PayloadLength, v.Payload[0:10])
+        elem.trace("Storing element %d (%d payload bytes)", v.Index, len(v.Payload))
+        if err := v.checkPayloadCRC(); err != nil {
+            return fmt.Errorf("Element %d is damaged: %w", v.Index+1, err)
+        }
+        _, err := w.Write(v.Payload)
         if err != nil {
             return err
         }
-        fileObject.Data = append(fileObject.Data, buffer...)
+        digest.Write(v.Payload)
+        done, written = done+1, written+uint64(len(v.Payload))
+        if report {
+            elem.progress(Progress{Stage: StageRestoring, Done: done, Total: total, Bytes: written})
+        }
     }
-    return nil
+    return elem.checkDataSHA256(digest.Sum(nil))
 }
 
 func (elements *QrElements) Len() int { return len(elements.Elements) }
@@ -0,0 +1,165 @@
+package qrFile
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "image/png"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// TestTinyInputs round-trips inputs smaller than a single chunk through every format: the elements are printed,
+// parsed again & their data restored
+func TestTinyInputs(t *testing.T) {
+    inputs := []struct {
+        name string
+        data []byte
+    }{
+        {"empty", []byte{}},
+        {"one byte", []byte{0x7f}},
+    }
+    versions := []struct {
+        name    string
+        version int
+    }{
+        {"legacy", VersionLegacy},
+        {"plain", VersionPlain},
+        {"compact", VersionCompact},
+    }
+    for _, input := range inputs {
+        for _, version := range versions {
+            t.Run(input.name+"/"+version.name, func(t *testing.T) {
+                qrf := New()
+                qrf.Data = input.data
+                elements, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: version.version})
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if elements.Len() != 1 {
+                    t.Fatalf("%d elements, expected 1", elements.Len())
+                }
+                parsed := MakeQrElements(0)
+                for _, v := range elements.Elements {
+                    var element QrElement
+                    if err := element.ParseString(v.AsString()); err != nil {
+                        t.Fatal(err)
+                    }
+                    if element.Version != version.version || element.Index != v.Index || element.MaxIndex != v.MaxIndex {
+                        t.Fatalf("parsed %+v from %q", element, v.AsString())
+                    }
+                    parsed.Elements = append(parsed.Elements, element)
+                }
+                if err := parsed.Validate(); err != nil {
+                    t.Fatal(err)
+                }
+                restored := New()
+                if err := parsed.StoreData(restored); err != nil {
+                    t.Fatal(err)
+                }
+                if !bytes.Equal(restored.Data, input.data) {
+                    t.Fatalf("restored %x, expected %x", restored.Data, input.data)
+                }
+            })
+        }
+    }
+}
+
+// TestPad renders the codes of a padded set at the same size, which read back as the elements
+func TestPad(t *testing.T) {
+    data := make([]byte, 700)
+    for i := range data {
+        data[i] = byte(i)
+    }
+    qrf := New()
+    qrf.Data = data
+    for _, version := range []int{VersionPlain, VersionCompact} {
+        elements, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: version, ChunkSize: 600, Pad: true})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if elements.Len() < 2 || elements.PadVersion == 0 {
+            t.Fatalf("version %d: %d elements padded to version %d", version, elements.Len(), elements.PadVersion)
+        }
+        images, err := elements.Render()
+        if err != nil {
+            t.Fatal(err)
+        }
+        for i, img := range images {
+            if img.Bounds().Size() != images[0].Bounds().Size() {
+                t.Fatalf("version %d: code %d has size %v, the first one %v", version, i, img.Bounds().Size(), images[0].Bounds().Size())
+            }
+            texts, err := NativeDecoder{}.DecodeImage(img)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(texts) != 1 || texts[0] != elements.Elements[i].AsString() {
+                t.Fatalf("version %d: code %d reads as %q", version, i, texts)
+            }
+        }
+        if params := elements.parameters(); params.PadVersion != elements.PadVersion {
+            t.Fatalf("version %d: parameter code records version %d, expected %d", version, params.PadVersion, elements.PadVersion)
+        }
+    }
+    unpadded, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: VersionPlain, ChunkSize: 600})
+    if err != nil {
+        t.Fatal(err)
+    }
+    images, err := unpadded.Render()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if images[0].Bounds().Size() == images[len(images)-1].Bounds().Size() {
+        t.Fatal("the codes of the unpadded set have the same size, the test does not cover padding")
+    }
+}
+
+// TestNoElements checks that input without any code fails with ErrNoElements
+func TestNoElements(t *testing.T) {
+    if err := MakeQrElements(0).Validate(); !errors.Is(err, ErrNoElements) {
+        t.Fatalf("empty set: %v", err)
+    }
+    blank := filepath.Join(t.TempDir(), "blank.png")
+    file, err := os.Create(blank)
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = png.Encode(file, image.NewGray(image.Rect(0, 0, 64, 64)))
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := FindSets([]string{blank}, NativeDecoder{}); !errors.Is(err, ErrNoElements) {
+        t.Fatalf("image without codes: %v", err)
+    }
+}
+
+// TestCountLimits rejects a count of codes larger than the data or than MaxCount before the elements are allocated
+func TestCountLimits(t *testing.T) {
+    qrf := New()
+    qrf.Data = []byte("0123456789")
+    cases := []struct {
+        name    string
+        options EncodeOptions
+    }{
+        {"more codes than bytes", EncodeOptions{Version: VersionPlain, Count: 100000000}},
+        {"above MaxCount", EncodeOptions{Version: VersionPlain, Count: 5, MaxCount: 4}},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            if _, err := GetElementsWithOptions(qrf.ToHexString(), c.options); !errors.Is(err, ErrPayloadTooLarge) {
+                t.Fatalf("count %d: %v", c.options.Count, err)
+            }
+        })
+    }
+    elements, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: VersionPlain, Count: 10})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if elements.Len() != 10 {
+        t.Fatalf("%d elements, expected 10", elements.Len())
+    }
+}
@@ -0,0 +1,259 @@
+package qrFile
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/ecdh"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "golang.org/x/crypto/hkdf"
+    "io"
+    "io/ioutil"
+    "strings"
+)
+
+// Besides a passphrase or a raw key shared with whoever restores the set, data can be encrypted for recipients: one or
+// more X25519 public keys, so only the holders of the matching private keys (identities) decrypt it, e.g. for a backup
+// printed & mailed to someone else. Like age, the data is encrypted with a random file key, which is wrapped for every
+// recipient with a key derived from an ephemeral X25519 exchange; the recipients are not identifiable from the
+// encrypted data. Key pairs are generated with GenerateIdentity or openssl genpkey -algorithm x25519.
+
+// recipientAdditionalData authenticates the purpose of the ciphertext, recipientWrapInfo the one of the wrapped file keys
+const (
+    recipientAdditionalData = "QRF RECIPIENTS"
+    recipientWrapInfo       = "QRF RECIPIENT KEY"
+)
+
+// recipientFormat is the first byte of the encrypted data, so the layout can change in later versions
+const recipientFormat = 1
+
+// recipientStanzaSize is the size of the file key wrapped for one recipient: the ephemeral public key & the sealed file
+// key with its tag
+const recipientStanzaSize = 32 + KeySize + 16
+
+// maxRecipients is the number of recipients the count byte in front of the wrapped keys holds
+const maxRecipients = 255
+
+// ErrIdentityRequired is returned by RecipientTransform if the data is encrypted for recipients & no identity is given
+var ErrIdentityRequired = errors.New("The data is encrypted for recipients, an identity (private key) is required")
+
+// RecipientTransform encrypts the data with AES-256-GCM for the public keys in Recipients & decrypts it with one of the
+// private keys in Identities. The encrypted data consists of the format byte, the number of recipients, the file key
+// wrapped for each of them, the nonce & the ciphertext; the manifest records the public keys (parameter "recipients"),
+// so it tells which key restores the set. The registered instance holds no identity, so reversing it returns
+// ErrIdentityRequired; pass a RecipientTransform with the identities to ReverseTransforms instead.
+type RecipientTransform struct {
+    Recipients []*ecdh.PublicKey
+    Identities []*ecdh.PrivateKey
+}
+
+// Name returns "recipients"
+func (t RecipientTransform) Name() string {
+    return "recipients"
+}
+
+// Apply encrypts data for the recipients
+func (t RecipientTransform) Apply(data []byte) ([]byte, map[string]string, error) {
+    if len(t.Recipients) == 0 {
+        return nil, nil, errors.New("Encryption for recipients requires at least one recipient")
+    }
+    if len(t.Recipients) > maxRecipients {
+        return nil, nil, errors.New(fmt.Sprintf("Data can be encrypted for at most %d recipients, not %d", maxRecipients, len(t.Recipients)))
+    }
+    fileKey := make([]byte, KeySize)
+    _, err := io.ReadFull(rand.Reader, fileKey)
+    if err != nil {
+        return nil, nil, err
+    }
+    header := []byte{recipientFormat, byte(len(t.Recipients))}
+    names := make([]string, len(t.Recipients))
+    for i, recipient := range t.Recipients {
+        if recipient.Curve() != ecdh.X25519() {
+            return nil, nil, errors.New("Recipients have to be X25519 keys")
+        }
+        ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+        if err != nil {
+            return nil, nil, err
+        }
+        wrap, err := recipientWrapCipher(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+        if err != nil {
+            return nil, nil, err
+        }
+        header = append(header, ephemeral.PublicKey().Bytes()...)
+        // every wrapping key is used once, so a fixed nonce is safe
+        header = wrap.Seal(header, make([]byte, wrap.NonceSize()), fileKey, []byte(recipientWrapInfo))
+        names[i] = FormatRecipient(recipient)
+    }
+    aead, err := rawKeyCipher(fileKey)
+    if err != nil {
+        return nil, nil, err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    _, err = io.ReadFull(rand.Reader, nonce)
+    if err != nil {
+        return nil, nil, err
+    }
+    params := map[string]string{"recipients": strings.Join(names, ",")}
+    prefix := append(append([]byte{}, header...), nonce...)
+    return aead.Seal(prefix, nonce, data, recipientAD(header)), params, nil
+}
+
+// Reverse decrypts data with the first identity the file key was wrapped for
+func (t RecipientTransform) Reverse(data []byte, params map[string]string) ([]byte, error) {
+    if len(t.Identities) == 0 {
+        return nil, ErrIdentityRequired
+    }
+    if len(data) < 2 {
+        return nil, errors.New("Encrypted data too short")
+    }
+    if data[0] != recipientFormat {
+        return nil, errors.New(fmt.Sprintf("Unknown format %d of the data encrypted for recipients", data[0]))
+    }
+    count := int(data[1])
+    headerSize := 2 + count*recipientStanzaSize
+    if len(data) < headerSize+12 {
+        return nil, errors.New("Encrypted data too short")
+    }
+    header := data[:headerSize]
+    var fileKey []byte
+    for i := 0; i < count && fileKey == nil; i++ {
+        stanza := header[2+i*recipientStanzaSize : 2+(i+1)*recipientStanzaSize]
+        ephemeral, err := ecdh.X25519().NewPublicKey(stanza[:32])
+        if err != nil {
+            continue
+        }
+        for _, identity := range t.Identities {
+            wrap, err := recipientWrapCipher(identity, ephemeral, ephemeral, identity.PublicKey())
+            if err != nil {
+                continue
+            }
+            key, err := wrap.Open(nil, make([]byte, wrap.NonceSize()), stanza[32:], []byte(recipientWrapInfo))
+            if err == nil {
+                fileKey = key
+                break
+            }
+        }
+    }
+    if fileKey == nil {
+        return nil, errors.New("None of the identities given is a recipient of the data")
+    }
+    aead, err := rawKeyCipher(fileKey)
+    if err != nil {
+        return nil, err
+    }
+    data = data[headerSize:]
+    result, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], recipientAD(header))
+    if err != nil {
+        return nil, errors.New("Unable to decrypt the data (damaged?)")
+    }
+    return result, nil
+}
+
+// recipientAD returns the additional data of the ciphertext, which covers the wrapped file keys as well
+func recipientAD(header []byte) []byte {
+    return append([]byte(recipientAdditionalData), header...)
+}
+
+// recipientWrapCipher returns the cipher wrapping the file key for a recipient: the key is derived from the X25519
+// exchange of private & peer, salted with the ephemeral public key & the one of the recipient
+func recipientWrapCipher(private *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral *ecdh.PublicKey, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+    shared, err := private.ECDH(peer)
+    if err != nil {
+        return nil, err
+    }
+    salt := append(append([]byte{}, ephemeral.Bytes()...), recipient.Bytes()...)
+    key := make([]byte, KeySize)
+    _, err = io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(recipientWrapInfo)), key)
+    if err != nil {
+        return nil, err
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// GenerateIdentity returns a new X25519 key pair for encryption for recipients; its public key is the recipient
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+    return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// FormatRecipient returns the public key as text (base64), as accepted by ParseRecipient & recorded in the manifest
+func FormatRecipient(key *ecdh.PublicKey) string {
+    return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+// ParseRecipient decodes a public key given as text: PEM encoded (PKIX, as written by openssl pkey -pubout) or its 32
+// bytes hex or base64 encoded (see ParseKey)
+func ParseRecipient(text string) (*ecdh.PublicKey, error) {
+    if block, _ := pem.Decode([]byte(text)); block != nil {
+        key, err := x509.ParsePKIXPublicKey(block.Bytes)
+        if err != nil {
+            return nil, err
+        }
+        if public, ok := key.(*ecdh.PublicKey); ok && public.Curve() == ecdh.X25519() {
+            return public, nil
+        }
+        return nil, errors.New("Not an X25519 key")
+    }
+    raw, err := ParseKey(text)
+    if err != nil {
+        return nil, err
+    }
+    return ecdh.X25519().NewPublicKey(raw)
+}
+
+// ReadRecipient reads a public key from a file: PEM encoded or its 32 bytes as binary, hex or base64 (see ParseRecipient)
+func ReadRecipient(fname string) (*ecdh.PublicKey, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) == KeySize {
+        return ecdh.X25519().NewPublicKey(data)
+    }
+    key, err := ParseRecipient(string(data))
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return key, nil
+}
+
+// ReadIdentity reads a private key from a file: PEM encoded (PKCS #8, as written by openssl genpkey -algorithm x25519
+// or MarshalIdentity) or its 32 bytes as binary, hex or base64 (see ParseKey)
+func ReadIdentity(fname string) (*ecdh.PrivateKey, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    if block, _ := pem.Decode(data); block != nil {
+        key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", fname, err)
+        }
+        if private, ok := key.(*ecdh.PrivateKey); ok && private.Curve() == ecdh.X25519() {
+            return private, nil
+        }
+        return nil, errors.New(fmt.Sprintf("%s: not an X25519 key", fname))
+    }
+    raw, err := readRawKey(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return ecdh.X25519().NewPrivateKey(raw)
+}
+
+// MarshalIdentity returns the private key PEM encoded (PKCS #8), as read by ReadIdentity
+func MarshalIdentity(key *ecdh.PrivateKey) ([]byte, error) {
+    der, err := x509.MarshalPKCS8PrivateKey(key)
+    if err != nil {
+        return nil, err
+    }
+    return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
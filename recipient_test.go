@@ -0,0 +1,94 @@
+package qrFile
+
+import (
+    "bytes"
+    "crypto/ecdh"
+    "errors"
+    "testing"
+)
+
+// recipientData encrypts data for two new identities, which are returned along with the encrypted data
+func recipientData(t *testing.T, data []byte) ([]byte, map[string]string, []*ecdh.PrivateKey) {
+    identities := make([]*ecdh.PrivateKey, 2)
+    recipients := make([]*ecdh.PublicKey, len(identities))
+    for i := range identities {
+        identity, err := GenerateIdentity()
+        if err != nil {
+            t.Fatal(err)
+        }
+        identities[i], recipients[i] = identity, identity.PublicKey()
+    }
+    encrypted, params, err := RecipientTransform{Recipients: recipients}.Apply(data)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return encrypted, params, identities
+}
+
+// TestRecipientRoundTrip decrypts data encrypted for two recipients with the identity of either
+func TestRecipientRoundTrip(t *testing.T) {
+    data := []byte("mailed backup")
+    encrypted, params, identities := recipientData(t, data)
+    if bytes.Contains(encrypted, data) {
+        t.Fatal("data not encrypted")
+    }
+    for i, identity := range identities {
+        decrypted, err := RecipientTransform{Identities: []*ecdh.PrivateKey{identity}}.Reverse(encrypted, params)
+        if err != nil {
+            t.Fatalf("identity %d: %s", i, err)
+        }
+        if !bytes.Equal(decrypted, data) {
+            t.Fatalf("identity %d decrypted %q", i, decrypted)
+        }
+    }
+    if _, err := ReverseTransforms(encrypted, []TransformInfo{{Name: "recipients", Params: params}}, nil); !errors.Is(err, ErrIdentityRequired) {
+        t.Fatalf("no identity: %v", err)
+    }
+}
+
+// TestRecipientWrongIdentity refuses to decrypt data with identities which are no recipients of it
+func TestRecipientWrongIdentity(t *testing.T) {
+    encrypted, params, _ := recipientData(t, []byte("mailed backup"))
+    other, err := GenerateIdentity()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := (RecipientTransform{Identities: []*ecdh.PrivateKey{other}}).Reverse(encrypted, params); err == nil {
+        t.Fatal("decrypted with another identity")
+    }
+}
+
+// TestRecipientDamagedData refuses truncated or tampered data with an error instead of a panic
+func TestRecipientDamagedData(t *testing.T) {
+    encrypted, params, identities := recipientData(t, []byte("mailed backup"))
+    headerSize := 2 + 2*recipientStanzaSize
+    tampered := func(i int) []byte {
+        data := append([]byte{}, encrypted...)
+        data[i] ^= 1
+        return data
+    }
+    cases := []struct {
+        name string
+        data []byte
+    }{
+        {"empty", nil},
+        {"format byte only", encrypted[:1]},
+        {"truncated header", encrypted[:headerSize-1]},
+        {"truncated nonce", encrypted[:headerSize+5]},
+        {"truncated ciphertext", encrypted[:len(encrypted)-1]},
+        {"unknown format", tampered(0)},
+        {"more recipients", tampered(1)},
+        {"ephemeral key", tampered(2 + 5)},
+        {"wrapped key", tampered(2 + 40)},
+        {"key of the other recipient", tampered(2 + recipientStanzaSize + 40)},
+        {"nonce", tampered(headerSize)},
+        {"ciphertext", tampered(len(encrypted) - 1)},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            if _, err := (RecipientTransform{Identities: identities[:1]}).Reverse(c.data, params); err == nil {
+                t.Fatal("damaged data decrypted")
+            }
+        })
+    }
+}
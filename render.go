@@ -0,0 +1,203 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+    "math"
+    "rsc.io/qr"
+    "strings"
+)
+
+// By default, the images are whatever the encoder renders (RscEncoder: 8 pixels per module & a quiet zone of 4 modules,
+// black on white). RenderOptions tune them for a printer or display: the size of a module, the quiet zone, the colors
+// & a cap on the size of the images. They are drawn from the modules of the code, so they only apply to encoders
+// implementing MatrixEncoder. For print, the png files can record their resolution (DPI, written as pHYs chunk), so
+// printing them at their actual size yields codes of the intended size; with PrintSize, the size of a module is chosen
+// for a printed size instead of in pixels, e.g. 50 mm at 300 DPI, & codes whose modules would come out too small to be
+// scanned reliably are refused.
+
+// Defaults of RenderOptions
+const (
+    DefaultModuleScale = 8   // pixels per module, as in the images of RscEncoder
+    DefaultQuietZone   = 4   // modules, as required by the QR standard
+    DefaultDPI         = 300 // resolution assumed for PrintSize if DPI is not set, common for printers
+)
+
+// MinModuleSize is the smallest printed size of a module in millimeters accepted with PrintSize; smaller modules are
+// hard to resolve for phone cameras & blur on many printers
+const MinModuleSize = 0.33
+
+// RenderOptions selects how the images of the codes are drawn, see QrElements.Rendering. The zero value leaves the
+// images to the encoder.
+type RenderOptions struct {
+    Scale      int         // size of a module in pixels; DefaultModuleScale if 0
+    QuietZone  int         // width of the margin around the code in modules; DefaultQuietZone if 0, none if negative
+    Foreground color.Color // color of the dark modules; black if nil
+    Background color.Color // color of the light modules & the quiet zone; white if nil
+    MaxSize    int         // if set, the scale is reduced so an image is at most this many pixels wide & high
+    // DPI is the resolution recorded in the png files, in pixels per inch; none if 0. It only changes the size the
+    // images are printed at, not their pixels, so it applies to all encoders.
+    DPI int
+    // PrintSize is the printed width & height of a code with its quiet zone in millimeters; if set, it replaces Scale:
+    // the module size is the largest one fitting at DPI (DefaultDPI if 0). Captions & the like are added outside.
+    PrintSize float64
+}
+
+// isZero returns whether no option changing the drawing of the codes is set (DPI is recorded in the files only)
+func (options RenderOptions) isZero() bool {
+    return options.Scale == 0 && options.QuietZone == 0 && options.Foreground == nil && options.Background == nil && options.MaxSize == 0 && options.PrintSize == 0
+}
+
+// dpi returns the resolution recorded in the png files: DPI, or DefaultDPI if a PrintSize is set without one
+func (options RenderOptions) dpi() int {
+    if options.DPI <= 0 && options.PrintSize > 0 {
+        return DefaultDPI
+    }
+    return options.DPI
+}
+
+// resolve returns the options with the defaults applied
+func (options RenderOptions) resolve() RenderOptions {
+    if options.Scale <= 0 {
+        options.Scale = DefaultModuleScale
+    }
+    if options.QuietZone == 0 {
+        options.QuietZone = DefaultQuietZone
+    } else if options.QuietZone < 0 {
+        options.QuietZone = 0
+    }
+    if options.Foreground == nil {
+        options.Foreground = color.Black
+    }
+    if options.Background == nil {
+        options.Background = color.White
+    }
+    return options
+}
+
+// MatrixEncoder is implemented by encoders which provide the modules of a code, so its image can be drawn with
+// RenderOptions
+type MatrixEncoder interface {
+    SymbolEncoder
+    // EncodeMatrix returns the rows of modules of the code holding text, true for a dark module, without quiet zone
+    EncodeMatrix(text string, level Level) ([][]bool, error)
+}
+
+// EncodeMatrix implements MatrixEncoder
+func (RscEncoder) EncodeMatrix(text string, level Level) ([][]bool, error) {
+    code, err := encodeRsc(text, level)
+    if err != nil {
+        return nil, err
+    }
+    return codeModules(code), nil
+}
+
+// codeModules returns the modules of a code of the internal encoder
+func codeModules(code *qr.Code) [][]bool {
+    modules := make([][]bool, code.Size)
+    for y := range modules {
+        modules[y] = make([]bool, code.Size)
+        for x := range modules[y] {
+            modules[y][x] = code.Black(x, y)
+        }
+    }
+    return modules
+}
+
+// drawModules draws the modules of a code (see MatrixEncoder) with the options
+func drawModules(modules [][]bool, options RenderOptions) (image.Image, error) {
+    options = options.resolve()
+    foreground, _ := color.GrayModel.Convert(options.Foreground).(color.Gray)
+    background, _ := color.GrayModel.Convert(options.Background).(color.Gray)
+    if foreground.Y >= background.Y {
+        return nil, errors.New("The foreground color has to be darker than the background color, or the codes can not be read")
+    }
+    height, width := len(modules), 0
+    if height > 0 {
+        width = len(modules[0])
+    }
+    scale := options.Scale
+    side := width
+    if height > side {
+        side = height
+    }
+    side += 2 * options.QuietZone
+    if options.PrintSize > 0 {
+        dpi := float64(options.dpi())
+        scale = int(options.PrintSize / 25.4 * dpi / float64(side))
+        // whole pixels per module, so the modules are printed alike
+        module := math.Max(float64(scale), 1) / dpi * 25.4
+        if scale < 1 || module < MinModuleSize {
+            return nil, errors.New(fmt.Sprintf("A code of %dx%d modules with a quiet zone of %d printed at %g mm & %d DPI has modules of %.2f mm, less than the %.2f mm needed to scan it reliably; print it larger or with fewer characters per code", width, height, options.QuietZone, options.PrintSize, options.dpi(), math.Min(module, options.PrintSize/float64(side)), MinModuleSize))
+        }
+    }
+    if options.MaxSize > 0 && side*scale > options.MaxSize {
+        scale = options.MaxSize / side
+        if scale < 1 {
+            return nil, errors.New(fmt.Sprintf("A code of %dx%d modules with a quiet zone of %d does not fit into %d pixels", width, height, options.QuietZone, options.MaxSize))
+        }
+    }
+    img := image.NewPaletted(image.Rect(0, 0, (width+2*options.QuietZone)*scale, (height+2*options.QuietZone)*scale), color.Palette{options.Background, options.Foreground})
+    for y, row := range modules {
+        for x, dark := range row {
+            if !dark {
+                continue
+            }
+            for dy := 0; dy < scale; dy++ {
+                for dx := 0; dx < scale; dx++ {
+                    img.SetColorIndex((x+options.QuietZone)*scale+dx, (y+options.QuietZone)*scale+dy, 1)
+                }
+            }
+        }
+    }
+    return img, nil
+}
+
+// encodePNG writes the image as png like png.Encode; if dpi is set, the resolution is recorded as pHYs chunk
+func encodePNG(w io.Writer, img image.Image, dpi int) error {
+    if dpi <= 0 {
+        return png.Encode(w, img)
+    }
+    var data bytes.Buffer
+    err := png.Encode(&data, img)
+    if err != nil {
+        return err
+    }
+    // pHYs has to precede the image data; the signature (8 bytes) & the IHDR chunk (25 bytes) come first
+    encoded := data.Bytes()
+    pixelsPerMeter := uint32(math.Round(float64(dpi) / 0.0254))
+    chunk := binary.BigEndian.AppendUint32(nil, 9)
+    chunk = append(chunk, "pHYs"...)
+    chunk = binary.BigEndian.AppendUint32(chunk, pixelsPerMeter)
+    chunk = binary.BigEndian.AppendUint32(chunk, pixelsPerMeter)
+    chunk = append(chunk, 1) // unit: meter
+    chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+    for _, part := range [][]byte{encoded[:33], chunk, encoded[33:]} {
+        _, err = w.Write(part)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ParseColor returns the color for a hex value as used in HTML, e.g. "#1a1a1a", "1a1a1a" or "#fff"
+func ParseColor(value string) (color.Color, error) {
+    digits := strings.TrimPrefix(value, "#")
+    if len(digits) == 3 {
+        digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+    }
+    rgb, err := hex.DecodeString(digits)
+    if err != nil || len(rgb) != 3 {
+        return nil, errors.New(fmt.Sprintf("Invalid color %s, expected a hex value like #1a1a1a", value))
+    }
+    return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, nil
+}
@@ -0,0 +1,230 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+)
+
+// DecodeTimeout limits the time an external decoder process (zbarimg, heif-convert) may take for a single image, so a
+// pathological image can not stall reading a whole set. 0 disables the limit.
+var DecodeTimeout = 30 * time.Second
+
+// TimeoutError is returned if an external decoder process did not finish within DecodeTimeout
+type TimeoutError struct {
+    Command string // name of the decoder process, e.g. "zbarimg"
+    File    string // image the process was started for
+    Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+    return fmt.Sprintf("%s: %s did not finish within %s", e.File, e.Command, e.Timeout)
+}
+
+// decodeContext returns the context external decoder processes are run in: canceled with parent & limited by
+// DecodeTimeout
+func decodeContext(parent context.Context) (context.Context, context.CancelFunc) {
+    if DecodeTimeout <= 0 {
+        return context.WithCancel(parent)
+    }
+    return context.WithTimeout(parent, DecodeTimeout)
+}
+
+// DecodeMode selects how FromPNGs handles input files which can not be read
+type DecodeMode int
+
+const (
+    // DecodeLenient skips images which can not be read & codes which are no elements (e.g. a URL printed on the same
+    // page); they are listed in the DecodeReport
+    DecodeLenient DecodeMode = iota
+    // DecodeStrict aborts reading if any image can not be read or holds a code which is no element, with the details of
+    // all such images
+    DecodeStrict
+)
+
+// DecodeFailure describes an input file no element could be read from
+type DecodeFailure struct {
+    File     string
+    Err      error
+    Timeout  bool // the decoder did not finish within DecodeTimeout (see TimeoutError)
+    Attempts int  // number of decode attempts made (see RetryPolicy)
+}
+
+// DecodeReport describes how the input files were read by FromPNGs (or FindSets): files which could not be read are
+// listed with the reason, instead of only being logged.
+type DecodeReport struct {
+    Files     int // number of input files handled
+    Skipped   int // files which are no supported images (see FromPNGs)
+    Elements  int // number of elements read
+    Foreign   int // codes skipped because they are no elements (DecodeLenient only)
+    Attempts  int // number of decode attempts made, including retries (see RetryPolicy)
+    Retried   int // files decoded again after the first attempt failed
+    Recovered int // retried files which could be read
+    Failures  []DecodeFailure
+    Outvoted  []OutvotedElement // elements whose count was outvoted by their set (see Reconcile)
+    // elements which were not read, but computed from the parity elements of the set (see parity.go)
+    Reconstructed []uint64
+    Symbols       []DecodedSymbol // the codes elements were read from, by index
+    // elements dropped since they do not match the manifest of the set (see QrElements.Expected)
+    Mismatched []ManifestMismatch
+    // copies of elements read before, e.g. from a page scanned twice; they are dropped (see Validate)
+    Duplicates int
+}
+
+// DecodedSymbol describes how the code of an element was read: the details reported by the decoder (see Symbol) & the
+// number of attempts needed. Codes read only after retries (or with a low quality, as far as the decoder reports it)
+// are borderline prints, which should be printed again before they fail in a real restore.
+type DecodedSymbol struct {
+    Index uint64
+    File  string
+    Symbol
+    Attempts int // decode attempts made for the file (see RetryPolicy); more than 1 if it was read after retries
+}
+
+// Borderline returns the codes which were read only after retries
+func (r *DecodeReport) Borderline() []DecodedSymbol {
+    borderline := make([]DecodedSymbol, 0)
+    for _, symbol := range r.Symbols {
+        if symbol.Attempts > 1 {
+            borderline = append(borderline, symbol)
+        }
+    }
+    return borderline
+}
+
+// SymbolTable formats the codes read as a table (index, file, quality, version, orientation, attempts), one line per
+// code; details not reported by the decoder are shown as "-"
+func (r *DecodeReport) SymbolTable() string {
+    var table bytes.Buffer
+    out := tabwriter.NewWriter(&table, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(out, "INDEX\tFILE\tQUALITY\tVERSION\tORIENTATION\tATTEMPTS")
+    for _, s := range r.Symbols {
+        quality, version, orientation := "-", "-", "-"
+        if s.Quality != 0 {
+            quality = strconv.Itoa(s.Quality)
+        }
+        if s.Version != 0 {
+            version = strconv.Itoa(s.Version)
+        }
+        if len(s.Orientation) > 0 {
+            orientation = s.Orientation
+        }
+        fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%d\n", s.Index, s.File, quality, version, orientation, s.Attempts)
+    }
+    out.Flush()
+    return table.String()
+}
+
+// Timeouts returns the number of files whose decoder did not finish in time
+func (r *DecodeReport) Timeouts() int {
+    count := 0
+    for _, failure := range r.Failures {
+        if failure.Timeout {
+            count++
+        }
+    }
+    return count
+}
+
+// String summarizes the report, listing each failed file on a line of its own
+func (r *DecodeReport) String() string {
+    lines := []string{fmt.Sprintf("%d files, %d elements read, %d skipped, %d foreign codes, %d failed (%d timeouts), %d decode attempts, %d of %d retried files recovered",
+        r.Files, r.Elements, r.Skipped, r.Foreign, len(r.Failures), r.Timeouts(), r.Attempts, r.Recovered, r.Retried)}
+    for _, failure := range r.Failures {
+        // most errors name the file already
+        message := failure.Err.Error()
+        if !strings.HasPrefix(message, failure.File) {
+            message = failure.File + ": " + message
+        }
+        lines = append(lines, fmt.Sprintf("failed after %d attempts: %s", failure.Attempts, message))
+    }
+    for _, v := range r.Outvoted {
+        lines = append(lines, "outvoted: "+v.String())
+    }
+    for _, m := range r.Mismatched {
+        lines = append(lines, "dropped: "+m.String())
+    }
+    if r.Duplicates > 0 {
+        lines = append(lines, fmt.Sprintf("duplicates dropped: %d", r.Duplicates))
+    }
+    if len(r.Reconstructed) > 0 {
+        numbers := make([]string, len(r.Reconstructed))
+        for i, index := range r.Reconstructed {
+            numbers[i] = strconv.FormatUint(index+1, 10)
+        }
+        lines = append(lines, "reconstructed from parity codes: "+strings.Join(numbers, ", "))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// QuarantineList is the name of the list of files written by Quarantine
+const QuarantineList = "quarantine.txt"
+
+// Quarantine moves (or copies, if move is false) the files which could not be read into the directory dir & writes a
+// list of them along with the reasons to dir/quarantine.txt, so the pages which need to be scanned again are easy to
+// find. Files of the same name are numbered. Returns the paths of the files in dir.
+func (r *DecodeReport) Quarantine(dir string, move bool) ([]string, error) {
+    if len(r.Failures) == 0 {
+        return nil, nil
+    }
+    err := os.MkdirAll(dir, 0755)
+    if err != nil {
+        return nil, err
+    }
+    paths := make([]string, 0, len(r.Failures))
+    var list bytes.Buffer
+    for _, failure := range r.Failures {
+        name := filepath.Base(failure.File)
+        target := filepath.Join(dir, name)
+        for i := 2; fileExists(target); i++ {
+            ext := filepath.Ext(name)
+            target = filepath.Join(dir, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), i, ext))
+        }
+        err = copyFile(failure.File, target)
+        if err != nil {
+            return paths, err
+        }
+        if move {
+            err = os.Remove(failure.File)
+            if err != nil {
+                return paths, err
+            }
+        }
+        paths = append(paths, target)
+        fmt.Fprintf(&list, "%s\t%s\t%s\n", filepath.Base(target), failure.File, failure.Err)
+    }
+    return paths, ioutil.WriteFile(filepath.Join(dir, QuarantineList), list.Bytes(), 0644)
+}
+
+// fileExists reports whether a file of the given name exists
+func fileExists(fname string) bool {
+    _, err := os.Stat(fname)
+    return err == nil
+}
+
+// copyFile copies the file src to dst
+func copyFile(src string, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    out, err := os.Create(dst)
+    if err != nil {
+        return err
+    }
+    _, err = io.Copy(out, in)
+    if err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
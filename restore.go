@@ -0,0 +1,180 @@
+package qrFile
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "log/slog"
+    "time"
+)
+
+// Restorer writes the data of a set while its elements are added (e.g. while a long list of scanned codes is uploaded):
+// an element is written as soon as all elements before it were added, so the beginning of the data is available long
+// before the set is complete, and only the elements received out of order are kept in memory. Even sets of hundreds
+// of megabytes are restored this way without the data ever being held in memory (see ReadImages). The integrity
+// fields of the set are verified like in StoreData (see integrity.go).
+type Restorer struct {
+    w         io.Writer
+    assembler *Assembler
+    next      uint64            // index of the next element to be written
+    written   uint64            // data bytes written
+    hashes    map[uint64]string // hashes of the elements written, whose payload is dropped
+    digest    hash.Hash         // SHA-256 of the data written
+    expected  []byte            // SHA-256 of the data announced by the first element, if any
+}
+
+// NewRestorer creates a Restorer writing the data to w
+func NewRestorer(w io.Writer) *Restorer {
+    return &Restorer{w: w, assembler: NewAssembler(), hashes: make(map[uint64]string), digest: sha256.New()}
+}
+
+// Add stores a single element (see Assembler.Add) & writes all elements which are in order now
+func (r *Restorer) Add(newElement QrElement) (bool, error) {
+    if hash, ok := r.hashes[newElement.Index]; ok && newElement.setKey() == r.assembler.setKey {
+        // written already, the assembler can not compare the payload anymore
+        if hash != newElement.Hash() {
+            return false, errors.New(fmt.Sprintf("Element %d conflicts with an element read before", newElement.Index))
+        }
+        return false, nil
+    }
+    added, err := r.assembler.Add(newElement)
+    if err != nil || !added {
+        return added, err
+    }
+    return true, r.flush()
+}
+
+// AddString parses a string as produced by QrElement.AsString and adds the resulting element; texts of calibration &
+// sync frames are skipped
+func (r *Restorer) AddString(str string) (bool, error) {
+    if IsControlText(str) {
+        return r.assembler.AddString(str)
+    }
+    newElement := new(QrElement)
+    err := newElement.ParseString(str)
+    if err != nil {
+        return false, err
+    }
+    return r.Add(*newElement)
+}
+
+// flush writes the elements following the data written so far
+func (r *Restorer) flush() error {
+    for {
+        v, ok := r.assembler.elements[r.next]
+        if !ok {
+            return nil
+        }
+        buffer := v.Payload
+        if err := v.checkPayloadCRC(); err != nil {
+            return fmt.Errorf("Element %d is damaged: %w", v.Index+1, err)
+        }
+        if sum, ok := v.Fields.Get(FieldTypeDataSHA256); ok && v.Index == 0 {
+            r.expected = sum
+        }
+        if value, ok := v.Fields.Get(FieldTypeParity); ok {
+            if info, err := parseParityInfo(value); err == nil && v.Index >= info.data {
+                // parity elements hold no data (see parity.go)
+                buffer = nil
+            }
+        }
+        _, err := r.w.Write(buffer)
+        if err != nil {
+            return err
+        }
+        r.digest.Write(buffer)
+        r.written += uint64(len(buffer))
+        // the payload is not needed anymore
+        r.hashes[v.Index] = v.Hash()
+        v.Payload = nil
+        r.assembler.elements[r.next] = v
+        r.next++
+        if r.expected != nil && r.next == v.MaxIndex+1 && !bytes.Equal(r.digest.Sum(nil), r.expected) {
+            return checksumError("The restored data is corrupted: SHA-256 %x, the set announces %x", r.digest.Sum(nil), r.expected)
+        }
+    }
+}
+
+// ReadText adds the text of scanned codes read from rd, one code per line (see QrElements.ImportText), until the set is
+// complete or rd ends. An error is returned if the set is incomplete at the end of the input.
+func (r *Restorer) ReadText(rd io.Reader) error {
+    scanner := bufio.NewScanner(rd)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    line := 0
+    for !r.Complete() && scanner.Scan() {
+        line++
+        text, ok := textLine(scanner.Text())
+        if !ok {
+            continue
+        }
+        _, err := r.AddString(text)
+        if err != nil {
+            return fmt.Errorf("Line %d: %w", line, err)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    if !r.Complete() {
+        return errors.New(fmt.Sprintf("Incomplete set: %d of %d elements received, %d bytes restored.", r.assembler.Len(), r.assembler.Total(), r.written))
+    }
+    return nil
+}
+
+// ReadImages decodes the input files one after another (see FromPNGs for the inputs accepted) & adds their elements,
+// until the set is complete or all files were read. Only one image is held in memory at a time. Files which are no
+// images are skipped; images which can not be read are logged & skipped, and images of another set are an error. An
+// error is returned if the set is incomplete after the last file.
+func (r *Restorer) ReadImages(files []string, decoder Decoder) error {
+    fileList, err := expandInputs(files)
+    if err != nil {
+        return err
+    }
+    err = CheckDecoder(decoder)
+    if err != nil {
+        return err
+    }
+    failed := 0
+    for _, fname := range fileList {
+        if r.Complete() {
+            break
+        }
+        if !isInputFile(fname) {
+            continue
+        }
+        start := time.Now()
+        elements, _, _, err := parseFile(context.Background(), fname, decoder, RetryPolicy{}, DecodeLenient)
+        metrics().ImageDecoded(time.Since(start))
+        if err != nil {
+            logf(slog.LevelWarn, "%s", err)
+            failed++
+            continue
+        }
+        metrics().ChunksDecoded(len(elements))
+        for _, v := range elements {
+            _, err = r.Add(v)
+            if err != nil {
+                return fmt.Errorf("%s: %w", fname, err)
+            }
+        }
+    }
+    if !r.Complete() {
+        return errors.New(fmt.Sprintf("Incomplete set: %d of %d elements read from %d files (%d unreadable), %d bytes restored.", r.assembler.Len(), r.assembler.Total(), len(fileList), failed, r.written))
+    }
+    return nil
+}
+
+// Complete reports whether all elements of the set were added & written
+func (r *Restorer) Complete() bool {
+    return r.assembler.Complete() && r.next == r.assembler.Total()
+}
+
+// Written returns the number of data bytes written so far
+func (r *Restorer) Written() uint64 {
+    return r.written
+}
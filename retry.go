@@ -0,0 +1,287 @@
+package qrFile
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/draw"
+    "log/slog"
+    "math"
+    "time"
+)
+
+// Marginal images (faded print, uneven lighting, skewed photos) often fail on the first attempt but can be read after
+// some preprocessing. If the first attempt finds no valid element, the images of a file are decoded again as the
+// RetryPolicy set in QrElements.Retry says, before the file is declared unreadable.
+
+// RetryPolicy selects the attempts made for images which could not be read on the first attempt. The zero value makes
+// no further attempts.
+type RetryPolicy struct {
+    Thresholds []uint8 // convert the image to black & white at these gray levels (0-255) & decode it again
+    // Adaptive converts the image to black & white comparing each pixel with the mean of its surroundings, which copes
+    // with uneven lighting (e.g. a shadow over part of a photographed page)
+    Adaptive bool
+    // Gammas & Contrasts span a grid of exposure corrections: the image is decoded again with each combination of gamma
+    // (< 1 brightens, > 1 darkens) & contrast factor (> 1 increases the contrast around mid gray), except the unchanged
+    // image. An empty list stands for no correction.
+    Gammas    []float64
+    Contrasts []float64
+    Rotate    bool    // decode the image rotated by 90, 180 & 270 degrees
+    Fallback  Decoder // decode the image with this decoder as a last resort, e.g. another decoder than QrElements.Decoder
+    // FallbackZbar decodes the image with zbarimg as a last resort, if another decoder than ZbarDecoder is set in
+    // QrElements.Decoder (the default decoder falls back to zbarimg anyway, see ZbarFallback)
+    FallbackZbar bool
+    // Perspective straightens a page photographed at an angle: the corners of the page in front of a darker background
+    // are found & mapped to a rectangle (see preprocess.go)
+    Perspective bool
+    // Deskew rotates the image back by the angle (up to 20 degrees) its content is skewed by
+    Deskew bool
+    // Sharpen sharpens blurred photos (unsharp mask)
+    Sharpen bool
+    // Budget limits the time spent on the further attempts for a single image; the remaining attempts are skipped once
+    // it is used up. 0 does not limit the time.
+    Budget time.Duration
+}
+
+// DefaultRetryPolicy re-thresholds the image at three gray levels & adaptively, sweeps a small grid of exposure
+// corrections, rotates it & applies the corrections for photos (perspective, deskew, sharpening), within 20 seconds per
+// image
+var DefaultRetryPolicy = RetryPolicy{
+    Thresholds:  []uint8{96, 128, 160},
+    Adaptive:    true,
+    Gammas:      []float64{0.5, 1, 2},
+    Contrasts:   []float64{1, 1.5, 2.5},
+    Rotate:      true,
+    Perspective: true,
+    Deskew:      true,
+    Sharpen:     true,
+    Budget:      20 * time.Second,
+}
+
+// retryAttempt describes a single further attempt to decode an image
+type retryAttempt struct {
+    name    string
+    prepare func(image.Image) image.Image
+    decoder Decoder
+}
+
+// attempts lists the further attempts made by the policy; decoder is the decoder of the first attempt (the default
+// decoder if nil)
+func (policy RetryPolicy) attempts(decoder Decoder) []retryAttempt {
+    fallbackZbar := policy.FallbackZbar && decoder != nil
+    if _, zbar := decoder.(ZbarDecoder); zbar {
+        fallbackZbar = false
+    }
+    decoder = decoderOrDefault(decoder)
+    attempts := make([]retryAttempt, 0)
+    for _, threshold := range policy.Thresholds {
+        level := threshold // each closure needs its own copy
+        attempts = append(attempts, retryAttempt{name: fmt.Sprintf("threshold %d", level), prepare: func(img image.Image) image.Image {
+            return thresholdImage(img, level)
+        }, decoder: decoder})
+    }
+    if policy.Adaptive {
+        attempts = append(attempts, retryAttempt{name: "adaptive threshold", prepare: adaptiveThresholdImage, decoder: decoder})
+    }
+    for _, gamma := range sweepValues(policy.Gammas) {
+        for _, contrast := range sweepValues(policy.Contrasts) {
+            if gamma == 1 && contrast == 1 {
+                continue
+            }
+            g, c := gamma, contrast // each closure needs its own copy
+            attempts = append(attempts, retryAttempt{name: fmt.Sprintf("gamma %g, contrast %g", g, c), prepare: func(img image.Image) image.Image {
+                return adjustImage(img, g, c)
+            }, decoder: decoder})
+        }
+    }
+    if policy.Rotate {
+        // the EXIF orientations 6, 3 & 8 rotate by 90, 180 & 270 degrees
+        for _, orientation := range []int{6, 3, 8} {
+            o := orientation
+            attempts = append(attempts, retryAttempt{name: "rotation", prepare: func(img image.Image) image.Image {
+                return applyOrientation(img, o)
+            }, decoder: decoder})
+        }
+    }
+    // the corrections for photos are tried one by one, then one after another on the same image (see preprocess.go)
+    photo := make([]retryAttempt, 0)
+    if policy.Perspective {
+        photo = append(photo, retryAttempt{name: "perspective", prepare: perspectiveImage, decoder: decoder})
+    }
+    if policy.Deskew {
+        photo = append(photo, retryAttempt{name: "deskew", prepare: deskewImage, decoder: decoder})
+    }
+    if policy.Sharpen {
+        photo = append(photo, retryAttempt{name: "sharpen", prepare: sharpenImage, decoder: decoder})
+    }
+    attempts = append(attempts, photo...)
+    if len(photo) > 1 || len(photo) == 1 && policy.Adaptive {
+        attempts = append(attempts, retryAttempt{name: "photo pipeline", prepare: func(img image.Image) image.Image {
+            for _, attempt := range photo {
+                img = attempt.prepare(img)
+            }
+            if policy.Adaptive {
+                img = adaptiveThresholdImage(img)
+            }
+            return img
+        }, decoder: decoder})
+    }
+    if policy.Fallback != nil {
+        attempts = append(attempts, retryAttempt{name: "fallback decoder", decoder: policy.Fallback})
+    }
+    if fallbackZbar {
+        attempts = append(attempts, retryAttempt{name: "zbarimg", decoder: ZbarDecoder{}})
+    }
+    return attempts
+}
+
+// retryFile decodes the images of a file again as the policy says. For each image, the attempts are made in order until
+// one of them results in valid elements (see parseSymbols). Returns the elements, the number of foreign codes skipped & the
+// number of attempts made.
+func (policy RetryPolicy) retryFile(ctx context.Context, fname string, decoder Decoder, mode DecodeMode) ([]QrElement, int, int, error) {
+    attempts := policy.attempts(decoder)
+    if len(attempts) == 0 {
+        return nil, 0, 0, errors.New("No retry configured")
+    }
+    images, err := readImages(fname)
+    if err != nil {
+        return nil, 0, 0, err
+    }
+    count, foreign := 0, 0
+    result := make([]QrElement, 0, len(images))
+    for i, img := range images {
+        read, exhausted, start := false, false, time.Now()
+        for _, attempt := range attempts {
+            if policy.Budget > 0 && time.Since(start) > policy.Budget {
+                trace("%s, image %d: retry budget of %s used up", fname, i+1, policy.Budget)
+                exhausted = true
+                break
+            }
+            if err := ctx.Err(); err != nil {
+                return nil, 0, count, err
+            }
+            count++
+            prepared := img
+            if attempt.prepare != nil {
+                prepared = attempt.prepare(img)
+            }
+            symbols, err := decodeSymbols(ctx, attempt.decoder, prepared)
+            if err != nil || len(symbols) == 0 {
+                trace("%s, image %d: attempt %s found no code", fname, i+1, attempt.name)
+                continue
+            }
+            elements, skipped, err := parseSymbols(fname, symbols, mode)
+            if err != nil {
+                trace("%s, image %d: attempt %s: %s", fname, i+1, attempt.name, err)
+                continue
+            }
+            foreign += skipped
+            logf(slog.LevelInfo, "%s, image %d: read after retry (%s)", fname, i+1, attempt.name)
+            result = append(result, elements...)
+            read = true
+            break
+        }
+        if exhausted {
+            return nil, 0, count, errors.New(fmt.Sprintf("%s, image %d: no valid code found after %d attempts within %s", fname, i+1, count, policy.Budget))
+        }
+        if !read {
+            return nil, 0, count, errors.New(fmt.Sprintf("%s, image %d: no valid code found after %d attempts", fname, i+1, count))
+        }
+    }
+    return result, foreign, count, nil
+}
+
+// thresholdImage converts an image to black & white: pixels darker than level turn black, all others white
+func thresholdImage(img image.Image, level uint8) image.Image {
+    gray := grayImage(img)
+    for i, v := range gray.Pix {
+        if v < level {
+            gray.Pix[i] = 0
+        } else {
+            gray.Pix[i] = 255
+        }
+    }
+    return gray
+}
+
+// sweepValues returns the values of a sweep; an empty list stands for the neutral value 1
+func sweepValues(values []float64) []float64 {
+    if len(values) == 0 {
+        return []float64{1}
+    }
+    return values
+}
+
+// adjustImage converts an image to gray & corrects its exposure: gamma is applied first, then the contrast is scaled
+// around mid gray by the factor contrast
+func adjustImage(img image.Image, gamma float64, contrast float64) image.Image {
+    var table [256]uint8
+    for i := range table {
+        v := 255 * math.Pow(float64(i)/255, gamma)
+        v = (v-128)*contrast + 128
+        table[i] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+    }
+    gray := grayImage(img)
+    for i, v := range gray.Pix {
+        gray.Pix[i] = table[v]
+    }
+    return gray
+}
+
+// adaptiveThresholdOffset is subtracted from the local mean, so noise in evenly lit areas does not turn black
+const adaptiveThresholdOffset = 8
+
+// adaptiveThresholdImage converts an image to black & white: pixels darker than the mean of the square around them (an
+// eighth of the smaller side of the image wide) turn black, all others white
+func adaptiveThresholdImage(img image.Image) image.Image {
+    gray := grayImage(img)
+    w, h := gray.Rect.Dx(), gray.Rect.Dy()
+    radius := w
+    if h < radius {
+        radius = h
+    }
+    radius = radius/16 + 1
+    // integral image: sum[y][x] is the sum of all pixels above & left of (x, y)
+    sum := make([]int, (w+1)*(h+1))
+    for y := 0; y < h; y++ {
+        row := 0
+        for x := 0; x < w; x++ {
+            row += int(gray.Pix[y*gray.Stride+x])
+            sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+        }
+    }
+    result := image.NewGray(gray.Rect)
+    for y := 0; y < h; y++ {
+        y0, y1 := clampInt(y-radius, 0, h), clampInt(y+radius+1, 0, h)
+        for x := 0; x < w; x++ {
+            x0, x1 := clampInt(x-radius, 0, w), clampInt(x+radius+1, 0, w)
+            total := sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+            mean := total / ((x1 - x0) * (y1 - y0))
+            if int(gray.Pix[y*gray.Stride+x]) < mean-adaptiveThresholdOffset {
+                result.Pix[y*result.Stride+x] = 0
+            } else {
+                result.Pix[y*result.Stride+x] = 255
+            }
+        }
+    }
+    return result
+}
+
+// grayImage returns a gray copy of an image, with its origin at (0, 0)
+func grayImage(img image.Image) *image.Gray {
+    bounds := img.Bounds()
+    gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+    draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+    return gray
+}
+
+func clampInt(v int, min int, max int) int {
+    if v < min {
+        return min
+    }
+    if v > max {
+        return max
+    }
+    return v
+}
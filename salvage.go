@@ -0,0 +1,263 @@
+package qrFile
+
+import (
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// A set with lost or damaged elements is usually still worth restoring in part: a text, a log or an uncompressed
+// archive stays readable around a hole. In salvage mode (see QrElements.Salvage) StoreData writes whatever is intact,
+// fills the place of the missing elements with zero bytes or leaves it out, & describes every hole in a DamageReport
+// instead of refusing to restore anything. The size of a hole is known exactly if the set has parity elements (the
+// parity field names the chunk size & the size of the data), or if the elements read are all of the same size (the
+// default split, see GetElementsWithOptions) & the size of the data is known where the last element is missing (see
+// FileInfo); otherwise it is estimated from the elements read.
+
+// SalvageMode selects how StoreData handles missing or damaged elements
+type SalvageMode int
+
+const (
+    // SalvageOff refuses damaged elements (see StoreData); the default
+    SalvageOff SalvageMode = iota
+    // SalvageZeros writes zero bytes in place of missing or damaged elements, so the intact data keeps its offsets
+    SalvageZeros
+    // SalvageSkip leaves missing or damaged elements out, so only intact data is written
+    SalvageSkip
+)
+
+// salvageModes names the modes for ParseSalvageMode
+var salvageModes = map[string]SalvageMode{"off": SalvageOff, "zeros": SalvageZeros, "skip": SalvageSkip}
+
+// ParseSalvageMode returns the mode named off, zeros or skip
+func ParseSalvageMode(name string) (SalvageMode, error) {
+    if mode, ok := salvageModes[strings.ToLower(name)]; ok {
+        return mode, nil
+    }
+    return SalvageOff, errors.New(fmt.Sprintf("Unknown salvage mode %q, use off, zeros or skip", name))
+}
+
+func (m SalvageMode) String() string {
+    for name, mode := range salvageModes {
+        if mode == m {
+            return name
+        }
+    }
+    return fmt.Sprintf("SalvageMode(%d)", int(m))
+}
+
+// DataGap describes a hole in the data restored in salvage mode: the place of an element which is missing or damaged
+type DataGap struct {
+    Index   uint64 // index of the element
+    Offset  uint64 // offset of the element in the original data
+    Length  uint64 // size of the element in bytes
+    Exact   bool   // offset & length are known exactly; otherwise they are estimated from the elements read
+    Damaged bool   // the element was read but its payload is damaged (fails its CRC-32, see integrity.go)
+}
+
+func (g DataGap) String() string {
+    what, about := "missing", ""
+    if g.Damaged {
+        what = "damaged"
+    }
+    if !g.Exact {
+        about = "about "
+    }
+    return fmt.Sprintf("element %d %s: %s%d bytes at offset %s%d", g.Index, what, about, g.Length, about, g.Offset)
+}
+
+// DamageReport describes the data restored from an incomplete or damaged set in salvage mode (see StoreData)
+type DamageReport struct {
+    Mode      SalvageMode
+    Elements  uint64    // number of data elements of the set
+    Gaps      []DataGap // holes in the data, in ascending order of their offsets
+    Written   uint64    // bytes written
+    Lost      uint64    // bytes missing (estimated if a gap is not Exact)
+    Unchecked bool      // the data was not checked against the SHA-256 of the set, as it is incomplete
+}
+
+// Complete reports whether no data is missing
+func (r *DamageReport) Complete() bool {
+    return len(r.Gaps) == 0
+}
+
+// Missing returns the indices of the elements which are missing, in ascending order
+func (r *DamageReport) Missing() []uint64 {
+    return r.gapIndices(false)
+}
+
+// Damaged returns the indices of the elements which were read but are damaged, in ascending order
+func (r *DamageReport) Damaged() []uint64 {
+    return r.gapIndices(true)
+}
+
+func (r *DamageReport) gapIndices(damaged bool) []uint64 {
+    indices := make([]uint64, 0)
+    for _, g := range r.Gaps {
+        if g.Damaged == damaged {
+            indices = append(indices, g.Index)
+        }
+    }
+    return indices
+}
+
+func (r *DamageReport) String() string {
+    if r.Complete() {
+        return fmt.Sprintf("%d of %d elements intact, %d bytes written", r.Elements, r.Elements, r.Written)
+    }
+    lines := []string{fmt.Sprintf("%d of %d elements intact, %d bytes written, %d bytes lost (%s)",
+        r.Elements-uint64(len(r.Gaps)), r.Elements, r.Written, r.Lost, r.fillDescription())}
+    if missing := r.Missing(); len(missing) > 0 {
+        lines = append(lines, "missing: "+formatRanges(missing))
+    }
+    if damaged := r.Damaged(); len(damaged) > 0 {
+        lines = append(lines, "damaged: "+formatRanges(damaged))
+    }
+    for _, g := range r.Gaps {
+        lines = append(lines, g.String())
+    }
+    return strings.Join(lines, "\n")
+}
+
+func (r *DamageReport) fillDescription() string {
+    if r.Mode == SalvageSkip {
+        return "left out"
+    }
+    return "filled with zero bytes"
+}
+
+// salvageData writes the intact data of the elements to w as selected by elem.Salvage & sets elem.Damage. The
+// elements have to be sorted without duplicates (see Validate); missing ones are allowed.
+func (elem *QrElements) salvageData(w io.Writer) error {
+    report := &DamageReport{Mode: elem.Salvage, Elements: elem.dataCount()}
+    elem.Damage = report
+    if elem.Len() == 0 {
+        return errors.New("No elements to restore data from.")
+    }
+    chunks := make(map[uint64][]byte, elem.Len())
+    damaged := make(map[uint64]uint64)
+    for _, v := range elem.Elements {
+        if v.Index >= report.Elements {
+            continue
+        }
+        if err := v.checkPayloadCRC(); err != nil {
+            elem.trace("Element %d is damaged: %s", v.Index+1, err)
+            damaged[v.Index] = uint64(len(v.Payload))
+            continue
+        }
+        chunks[v.Index] = v.Payload
+    }
+    chunk, size, exact := elem.salvageSizes(chunks)
+
+    offset, exactOffset := uint64(0), true
+    for index := uint64(0); index < report.Elements; index++ {
+        buffer, ok := chunks[index]
+        if ok {
+            if _, err := w.Write(buffer); err != nil {
+                return err
+            }
+            report.Written += uint64(len(buffer))
+            offset += uint64(len(buffer))
+            elem.progress(Progress{Stage: StageRestoring, Done: int(index + 1), Total: int(report.Elements), Bytes: report.Written})
+            continue
+        }
+        gap := DataGap{Index: index, Offset: offset, Length: chunk, Exact: exact && exactOffset}
+        if length, ok := damaged[index]; ok && length > 0 {
+            // a damaged payload has the right size, just not the right bytes
+            gap.Length, gap.Damaged, gap.Exact = length, true, exactOffset
+        } else if _, ok := damaged[index]; ok {
+            gap.Damaged = true
+        }
+        if index == report.Elements-1 && !gap.Damaged {
+            // the last element holds the rest of the data; without the size of the data, it is at most a chunk
+            if size > 0 {
+                gap.Length = 0
+                if size > offset {
+                    gap.Length = size - offset
+                }
+            }
+            gap.Exact = gap.Exact && size > 0
+        }
+        exactOffset = gap.Exact
+        report.Gaps = append(report.Gaps, gap)
+        report.Lost += gap.Length
+        offset += gap.Length
+        // a trailing hole of unknown size is not filled: there is no data after it to keep in place
+        if elem.Salvage == SalvageZeros && (index < report.Elements-1 || gap.Exact) {
+            if err := writeZeros(w, gap.Length); err != nil {
+                return err
+            }
+            report.Written += gap.Length
+        }
+    }
+    if !report.Complete() {
+        report.Unchecked = true
+        return nil
+    }
+    // nothing is missing: the data is checked like in StoreData
+    digest := sha256Of(chunks, report.Elements)
+    return elem.checkDataSHA256(digest)
+}
+
+// salvageSizes returns the size of the chunks of the data & the size of the data (0 if unknown) for salvageData; exact
+// is false if the chunk size is estimated
+func (elem *QrElements) salvageSizes(chunks map[uint64][]byte) (chunk uint64, size uint64, exact bool) {
+    if info, ok := elem.parity(); ok {
+        return info.chunk, info.size, true
+    }
+    // the size of the original file is the size of the data unless it was transformed
+    if info := elem.FileInfo(); info != nil && len(elem.AppliedTransforms()) == 0 {
+        size = info.Size
+    }
+    last := elem.dataCount() - 1
+    exact = true
+    for index, buffer := range chunks {
+        if index == last {
+            continue
+        }
+        if chunk != 0 && chunk != uint64(len(buffer)) {
+            exact = false
+        }
+        if uint64(len(buffer)) > chunk {
+            chunk = uint64(len(buffer))
+        }
+    }
+    if chunk == 0 {
+        // only the last element was read: the others are at least as large
+        exact = false
+        if buffer, ok := chunks[last]; ok {
+            chunk = uint64(len(buffer))
+        }
+    }
+    if chunk == 0 && size > 0 && last > 0 {
+        chunk = (size + last) / (last + 1)
+    }
+    return chunk, size, exact
+}
+
+// sha256Of returns the SHA-256 of the chunks with the indices below count, in order
+func sha256Of(chunks map[uint64][]byte, count uint64) []byte {
+    digest := sha256.New()
+    for index := uint64(0); index < count; index++ {
+        digest.Write(chunks[index])
+    }
+    return digest.Sum(nil)
+}
+
+// writeZeros writes n zero bytes to w
+func writeZeros(w io.Writer, n uint64) error {
+    zeros := make([]byte, 32*1024)
+    for n > 0 {
+        k := uint64(len(zeros))
+        if n < k {
+            k = n
+        }
+        if _, err := w.Write(zeros[:k]); err != nil {
+            return err
+        }
+        n -= k
+    }
+    return nil
+}
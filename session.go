@@ -0,0 +1,201 @@
+package qrFile
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+)
+
+// Large sets are often scanned in several sittings, e.g. a few hundred pages in batches over days. A Session keeps the
+// elements read so far in a session file, so each batch of images only adds the elements found in it, & restores the
+// file once the set is complete. The session file holds the text of each element along with its hash (see
+// QrElement.Hash), so a damaged session file is noticed when it is opened again.
+
+// sessionVersion is the format version of session files
+const sessionVersion = 1
+
+// sessionFile is the content of a session file
+type sessionFile struct {
+    Version  int              `json:"version"`
+    Elements []sessionElement `json:"elements"`
+}
+
+// sessionElement is an element stored in a session file
+type sessionElement struct {
+    Index uint64 `json:"index"`
+    Hash  string `json:"sha256"` // hex encoded SHA-256 of the element text
+    Text  string `json:"text"`   // the element as written by QrElement.AsString
+}
+
+// Session collects the elements of a set over several batches of images & keeps them in a session file (see
+// OpenSession). The decoding settings apply to the images added with AddFiles.
+type Session struct {
+    Path     string      // location of the session file
+    Decoder  Decoder     // used to read the images; the default decoder if nil (see ZbarFallback)
+    Retry    RetryPolicy // further attempts for images which could not be read (see RetryPolicy)
+    Observer Observer    // notified of the progress of reading the images, if set
+    // Report describes how the images of the last batch were read (see DecodeReport); nil before AddFiles
+    Report    *DecodeReport
+    assembler *Assembler
+}
+
+// OpenSession opens the session stored in the session file path, or starts a new one if the file does not exist yet.
+// The elements of the file are checked against their hashes.
+func OpenSession(path string) (*Session, error) {
+    s := &Session{Path: path, assembler: NewAssembler()}
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var stored sessionFile
+    err = json.Unmarshal(data, &stored)
+    if err != nil {
+        return nil, fmt.Errorf("%s is no session file: %w", path, err)
+    }
+    if stored.Version != sessionVersion {
+        return nil, errors.New(fmt.Sprintf("%s: unsupported session version %d", path, stored.Version))
+    }
+    for _, v := range stored.Elements {
+        element := new(QrElement)
+        err = element.ParseString(v.Text)
+        if err != nil {
+            return nil, fmt.Errorf("%s, element %d: %w", path, v.Index, err)
+        }
+        if element.Index != v.Index || element.Hash() != v.Hash {
+            return nil, checksumError("%s, element %d: hash mismatch, the session file is damaged", path, v.Index)
+        }
+        _, err = s.assembler.Add(*element)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+    }
+    return s, nil
+}
+
+// AddFiles reads the elements contained in a batch of image files (see FromPNGs; images which can not be read are
+// skipped & listed in Report), adds them to the session & saves the session file. Elements known already are ignored;
+// elements of another set are an error. Returns the number of new elements.
+func (s *Session) AddFiles(files []string) (int, error) {
+    return s.AddFilesContext(context.Background(), files)
+}
+
+// AddFilesContext works like AddFiles, but can be aborted (see FromPNGsContext); nothing is added then
+func (s *Session) AddFilesContext(ctx context.Context, files []string) (int, error) {
+    batch := &QrElements{Decoder: s.Decoder, Retry: s.Retry, Observer: s.Observer}
+    err := batch.readFiles(ctx, files)
+    s.Report = batch.Report
+    if err != nil {
+        return 0, err
+    }
+    added := 0
+    for _, v := range batch.Elements {
+        isNew, err := s.assembler.Add(v)
+        if err != nil {
+            return added, fmt.Errorf("%s: %w", v.source, err)
+        }
+        if isNew {
+            added++
+        }
+    }
+    return added, s.Save()
+}
+
+// AddString parses the text of a scanned code (see QrElement.AsString) & adds the element to the session; texts of
+// calibration & sync frames are skipped. The session file is not saved, see Save.
+func (s *Session) AddString(str string) (bool, error) {
+    return s.assembler.AddString(str)
+}
+
+// Save writes the session file. The file is replaced at once, so an interrupted run leaves the previous state.
+func (s *Session) Save() error {
+    stored := sessionFile{Version: sessionVersion, Elements: make([]sessionElement, 0, s.Len())}
+    for _, v := range s.assembler.sorted() {
+        stored.Elements = append(stored.Elements, sessionElement{Index: v.Index, Hash: v.Hash(), Text: v.AsString()})
+    }
+    data, err := json.MarshalIndent(stored, "", "  ")
+    if err != nil {
+        return err
+    }
+    temp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    _, err = temp.Write(append(data, '\n'))
+    if closeErr := temp.Close(); err == nil {
+        err = closeErr
+    }
+    if err == nil {
+        err = os.Rename(temp.Name(), s.Path)
+    }
+    if err != nil {
+        os.Remove(temp.Name())
+    }
+    return err
+}
+
+// Len returns the number of distinct elements collected so far
+func (s *Session) Len() int {
+    return s.assembler.Len()
+}
+
+// Total returns the number of elements of the complete set, or 0 if no element was added yet
+func (s *Session) Total() uint64 {
+    return s.assembler.Total()
+}
+
+// Missing returns the indices of the elements not collected yet, in ascending order
+func (s *Session) Missing() []uint64 {
+    return s.assembler.Missing()
+}
+
+// Complete reports whether the file can be restored: all elements were collected, or enough of them to reconstruct
+// the others from the parity elements (see parity.go)
+func (s *Session) Complete() bool {
+    _, err := s.Elements()
+    return err == nil
+}
+
+// Elements returns the elements collected as a sorted, validated set (see Validate); an IncompleteError if elements
+// are missing
+func (s *Session) Elements() (*QrElements, error) {
+    elements := MakeQrElements(0)
+    elements.Elements = s.assembler.sorted()
+    if elements.Len() == 0 {
+        return nil, errors.New(fmt.Sprintf("No elements collected in %s.", s.Path))
+    }
+    err := elements.Validate()
+    if err != nil {
+        return nil, err
+    }
+    return elements, nil
+}
+
+// Finish restores the data of the complete set into fileObject (see StoreData) & removes the session file
+func (s *Session) Finish(fileObject *QrFile) error {
+    elements, err := s.Elements()
+    if err != nil {
+        return err
+    }
+    elements.Observer = s.Observer
+    err = elements.StoreData(fileObject)
+    if err != nil {
+        return err
+    }
+    return s.Remove()
+}
+
+// Remove deletes the session file, e.g. once the file was restored
+func (s *Session) Remove() error {
+    err := os.Remove(s.Path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    return err
+}
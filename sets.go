@@ -0,0 +1,108 @@
+package qrFile
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// Several sets may end up in the same directory (or the same scan). Elements carrying a set ID (see GetElementsPlain)
+// are grouped by it; elements without one (VersionLegacy, VersionCompact) can only be told apart by their format & the
+// size of their set.
+
+// setIDPrefix marks the set ID in the text of an element in plain format
+const setIDPrefix = "#"
+
+// setIDLength is the amount of hex characters of a set ID
+const setIDLength = 8
+
+// makeSetID derives the set ID from the payload of the complete set, so encoding the same data always results in the
+// same set ID
+func makeSetID(payload string) string {
+    sum := sha256.Sum256([]byte(payload))
+    return hex.EncodeToString(sum[:])[:setIDLength]
+}
+
+// setKey identifies the set of an element
+func (elem *QrElement) setKey() string {
+    return fmt.Sprintf("%d/%s/%d", elem.Version, elem.SetID, elem.MaxIndex)
+}
+
+// SplitSets groups the elements by the set they belong to, in the order the sets were first seen. The sets are not
+// checked for completeness; see Validate.
+func (elem *QrElements) SplitSets() []*QrElements {
+    sets := make([]*QrElements, 0, 1)
+    index := make(map[string]int)
+    for _, v := range elem.Elements {
+        i, ok := index[v.setKey()]
+        if !ok {
+            i = len(sets)
+            index[v.setKey()] = i
+            sets = append(sets, &QrElements{Encoder: elem.Encoder, Decoder: elem.Decoder, Level: elem.Level, Levels: elem.Levels, Transcribe: elem.Transcribe, Digest: elem.Digest, ContentNames: elem.ContentNames, Checksums: elem.Checksums, Mode: elem.Mode, Retry: elem.Retry, Report: elem.Report, Observer: elem.Observer, Workers: elem.Workers, Sequential: elem.Sequential, Logger: elem.Logger, Trace: elem.Trace, MaxFileSize: elem.MaxFileSize})
+        }
+        sets[i].Elements = append(sets[i].Elements, v)
+    }
+    return sets
+}
+
+// FindSets reads a set of files like FromPNGs, but returns every set found separately, so the caller can choose which
+// one to restore. The sets are not checked for completeness; see Validate.
+func FindSets(files []string, decoder Decoder) ([]*QrElements, error) {
+    elements := &QrElements{Decoder: decoder}
+    err := elements.readFiles(context.Background(), files)
+    if err != nil {
+        return nil, err
+    }
+    if elements.Len() == 0 {
+        return nil, ErrNoElements
+    }
+    return elements.SplitSets(), nil
+}
+
+// SetID returns the set ID of the elements, empty if their format carries none
+func (elem *QrElements) SetID() string {
+    if elem.Len() == 0 {
+        return ""
+    }
+    return elem.Elements[0].SetID
+}
+
+// Version returns the format version of the elements (VersionLegacy, VersionPlain or VersionCompact, see FormatName),
+// as detected when they were read; 0 if there are none
+func (elem *QrElements) Version() int {
+    if elem.Len() == 0 {
+        return 0
+    }
+    if version := elem.Elements[0].Version; version != 0 {
+        return version
+    }
+    return VersionLegacy
+}
+
+// Describe returns a short description of the set, e.g. "set #1a2b3c4d: 12 of 17 codes"
+func (elem *QrElements) Describe() string {
+    if elem.Len() == 0 {
+        return "empty set"
+    }
+    distinct := make(map[uint64]bool)
+    for _, v := range elem.Elements {
+        distinct[v.Index] = true
+    }
+    name := "set without ID"
+    if len(elem.SetID()) > 0 {
+        name = "set " + setIDPrefix + elem.SetID()
+    }
+    return fmt.Sprintf("%s: %d of %d codes", name, len(distinct), elem.Elements[0].MaxIndex+1)
+}
+
+// multipleSetsError describes the sets found where a single one was expected
+func multipleSetsError(sets []*QrElements) error {
+    descriptions := make([]string, len(sets))
+    for i, set := range sets {
+        descriptions[i] = set.Describe()
+    }
+    return errors.New(fmt.Sprintf("Found %d different sets (%s).", len(sets), strings.Join(descriptions, "; ")))
+}
@@ -0,0 +1,168 @@
+package qrFile
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "os"
+)
+
+// Printable sheets: WritePDF arranges the codes of a set in a grid, several per page (N-up), each with its number
+// below it & a caption naming the file, so a paper backup is printed from a single document instead of loose images.
+// The page header repeats the caption & names the set ID & the page, so misplaced pages are put back easily.
+
+// Default grid of a sheet: 2 columns & 3 rows of codes per page
+const (
+    DefaultSheetColumns = 2
+    DefaultSheetRows    = 3
+)
+
+// Layout of the sheets, in points
+const (
+    sheetMargin      = 36
+    sheetGap         = 18
+    sheetHeaderSize  = 10
+    sheetLabelSize   = 9
+    sheetLineSpacing = 1.3
+    sheetMinCodeSize = 72 // codes are not printed smaller than an inch
+)
+
+// SheetOptions selects the layout of the pages written by WritePDF
+type SheetOptions struct {
+    Page    PageSize // PageA4 if zero
+    Columns int      // codes per row; DefaultSheetColumns if 0
+    Rows    int      // rows of codes per page; DefaultSheetRows if 0
+    Caption string   // printed below every code & in the page header; the name of the file (see FileInfo) if empty
+}
+
+// resolve returns the options with the defaults applied
+func (options SheetOptions) resolve() SheetOptions {
+    if options.Page.Width <= 0 || options.Page.Height <= 0 {
+        options.Page = PageA4
+    }
+    if options.Columns <= 0 {
+        options.Columns = DefaultSheetColumns
+    }
+    if options.Rows <= 0 {
+        options.Rows = DefaultSheetRows
+    }
+    return options
+}
+
+// grid returns the size of a cell of the grid & of the codes in it, in points, for resolved options; an error if the
+// codes would be too small
+func (options SheetOptions) grid() (cellWidth float64, cellHeight float64, codeSize float64, err error) {
+    gridTop := float64(options.Page.Height) - sheetMargin - 2*sheetHeaderSize
+    cellWidth = (float64(options.Page.Width) - 2*sheetMargin - float64(options.Columns-1)*sheetGap) / float64(options.Columns)
+    cellHeight = (gridTop - sheetMargin - float64(options.Rows-1)*sheetGap) / float64(options.Rows)
+    // the label & the caption take two lines below the code
+    codeSize = math.Min(cellWidth, cellHeight-2*sheetLabelSize*sheetLineSpacing)
+    if codeSize < sheetMinCodeSize {
+        return 0, 0, 0, errors.New(fmt.Sprintf("%d by %d codes do not fit on a page of %dx%d points; use fewer columns or rows", options.Columns, options.Rows, options.Page.Width, options.Page.Height))
+    }
+    return cellWidth, cellHeight, codeSize, nil
+}
+
+// sheetFit shortens text to at most width points in Courier of the given size, marking the cut with "..."
+func sheetFit(text string, width float64, size float64) string {
+    runes := []rune(text)
+    max := int(width / (pdfMonoAdvance * size))
+    if len(runes) <= max {
+        return text
+    }
+    if max < 3 {
+        return ""
+    }
+    return string(runes[:max-3]) + "..."
+}
+
+// sheetCenter draws a line of Courier text centered in a column of width points starting at x
+func (p *pdfPage) sheetCenter(x float64, y float64, width float64, font string, size float64, text string) {
+    text = sheetFit(text, width, size)
+    p.text(x+(width-float64(len([]rune(text)))*pdfMonoAdvance*size)/2, y, font, size, text)
+}
+
+// sheetCaption returns the caption printed on the sheets: the given one, the text of Caption or the name of the file
+// (see FileInfo); empty if there is none
+func (elem *QrElements) sheetCaption(caption string) string {
+    if len(caption) > 0 {
+        return caption
+    }
+    if elem.Caption != nil {
+        return elem.captionText()
+    }
+    if info := elem.FileInfo(); info != nil {
+        return info.Name
+    }
+    return ""
+}
+
+// WritePDF renders all elements (using the SymbolEncoder set in Encoder) & writes them as a printable PDF, arranged in
+// a grid of options.Columns by options.Rows codes per page. Below each code, its number & count (its digest if Digest
+// is set, see QrElement.Digest; the details of its caption if Caption is set) & the caption are printed;
+// transcriptions (see Transcribe) are not.
+func (elem *QrElements) WritePDF(w io.Writer, options SheetOptions) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to write.")
+    }
+    options = options.resolve()
+    options.Caption = elem.sheetCaption(options.Caption)
+    doc := &pdfDocument{size: options.Page}
+    width, height := float64(options.Page.Width), float64(options.Page.Height)
+    top := height - sheetMargin
+    gridTop := top - 2*sheetHeaderSize
+    cellWidth, cellHeight, codeSize, err := options.grid()
+    if err != nil {
+        return err
+    }
+    perPage := options.Columns * options.Rows
+    pages := (elem.Len() + perPage - 1) / perPage
+    var page *pdfPage
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        slot := i % perPage
+        if slot == 0 {
+            page = doc.newPage()
+            page.text(sheetMargin, top-sheetHeaderSize, pdfFontBold, sheetHeaderSize, options.Caption)
+            status := fmt.Sprintf("page %d of %d", i/perPage+1, pages)
+            if id := elem.SetID(); len(id) > 0 {
+                status = fmt.Sprintf("set #%s, %s", id, status)
+            }
+            page.text(width-sheetMargin-float64(len(status))*pdfMonoAdvance*sheetHeaderSize, top-sheetHeaderSize, pdfFontMono, sheetHeaderSize, status)
+        }
+        img, err := encodeVersion(context.Background(), elem.encoder(), v.AsString(), elem.levelOf(v.Index), elem.PadVersion)
+        if err != nil {
+            return err
+        }
+        x := sheetMargin + float64(slot%options.Columns)*(cellWidth+sheetGap)
+        y := gridTop - float64(slot/options.Columns)*(cellHeight+sheetGap) - codeSize
+        page.image(x+(cellWidth-codeSize)/2, y, codeSize, img)
+        label := fmt.Sprintf("%d/%d", v.Index+1, v.MaxIndex+1)
+        if elem.Caption != nil {
+            label = elem.captionDetails(v)
+        } else if elem.Digest {
+            label = v.Digest()
+        }
+        y -= sheetLabelSize * sheetLineSpacing
+        page.sheetCenter(x, y, cellWidth, pdfFontBold, sheetLabelSize, label)
+        y -= sheetLabelSize * sheetLineSpacing
+        page.sheetCenter(x, y, cellWidth, pdfFontMono, sheetLabelSize, options.Caption)
+    }
+    return doc.write(w)
+}
+
+// WritePDFFile writes all elements to the PDF file fname, see WritePDF
+func (elem *QrElements) WritePDFFile(fname string, options SheetOptions) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.WritePDF(file, options)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
@@ -0,0 +1,156 @@
+package qrFile
+
+import (
+    "crypto/ed25519"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "io/ioutil"
+)
+
+// A set can be signed with an Ed25519 key (see EncodeOptions.Signer), so whoever restores it confirms that the data is
+// the one the owner of the key encoded, not just a consistent set: the signature covers the SHA-256 of the data of the
+// set (as restored by StoreData, i.e. before transforms are reversed) & is recorded in the manifest &, in plain format,
+// in the header of the first element (a field of type FieldTypeSignature). VerifySignature checks it with the public
+// key after the set was read.
+
+// signatureContext is signed along with the SHA-256 of the data, so a signature of a set is not valid for other uses
+// of the key
+const signatureContext = "QRF SIGNATURE\x00"
+
+// signatureReserve is the amount of characters the signature takes in the header of the first element: the token of
+// the field & the space before it
+const signatureReserve uint64 = 1 + uint64(len(fieldsPrefix)) + 2*(2+ed25519.SignatureSize)
+
+// ErrNotSigned is returned by VerifySignature if the set carries no signature
+var ErrNotSigned = errors.New("The set is not signed")
+
+// signedMessage returns the message signed for data with the given SHA-256
+func signedMessage(sum []byte) []byte {
+    return append([]byte(signatureContext), sum...)
+}
+
+// Sign signs the data of a complete set with the key & records the signature in the set & in the header of the first
+// element (plain format only)
+func (elem *QrElements) Sign(key ed25519.PrivateKey) error {
+    if len(key) != ed25519.PrivateKeySize {
+        return errors.New(fmt.Sprintf("Invalid Ed25519 key size %d, expected %d bytes", len(key), ed25519.PrivateKeySize))
+    }
+    digest := sha256.New()
+    err := elem.writeData(digest, false)
+    if err != nil {
+        return err
+    }
+    return elem.setSignature(ed25519.Sign(key, signedMessage(digest.Sum(nil))))
+}
+
+// setSignature records the signature in the set & in the header of the first element (plain format only)
+func (elem *QrElements) setSignature(signature []byte) error {
+    elem.Signature = signature
+    for i := range elem.Elements {
+        if v := &elem.Elements[i]; v.Index == 0 && v.Version == VersionPlain {
+            v.Fields.Set(FieldTypeSignature, signature)
+            if size := len(v.Fields.Marshal()); size > maxFieldsSize {
+                return sizeError(uint64(size), maxFieldsSize, "The fields of the first element take %d bytes with the signature, the maximum is %d; record a shorter file name", size, maxFieldsSize)
+            }
+        }
+    }
+    return nil
+}
+
+// signature returns the signature of the set: Signature if set, otherwise the one in the header of the first element;
+// nil if there is none
+func (elem *QrElements) signature() []byte {
+    if elem.Signature != nil {
+        return elem.Signature
+    }
+    for _, v := range elem.Elements {
+        if value, ok := v.Fields.Get(FieldTypeSignature); ok && v.Index == 0 {
+            return value
+        }
+    }
+    return nil
+}
+
+// VerifySignature confirms that the data of the complete set was signed with the private key belonging to key; it
+// returns ErrNotSigned if the set carries no signature
+func (elem *QrElements) VerifySignature(key ed25519.PublicKey) error {
+    if len(key) != ed25519.PublicKeySize {
+        return errors.New(fmt.Sprintf("Invalid Ed25519 public key size %d, expected %d bytes", len(key), ed25519.PublicKeySize))
+    }
+    signature := elem.signature()
+    if signature == nil {
+        return ErrNotSigned
+    }
+    if elem.Len() == 0 || uint64(elem.Len()) != elem.Elements[0].MaxIndex+1 {
+        return errors.New("The signature can only be verified for a complete set")
+    }
+    digest := sha256.New()
+    err := elem.writeData(digest, false)
+    if err != nil {
+        return err
+    }
+    if !ed25519.Verify(key, signedMessage(digest.Sum(nil)), signature) {
+        return errors.New("The signature of the set is invalid: the data differs from the signed data or was signed with another key")
+    }
+    return nil
+}
+
+// ReadSigningKey reads an Ed25519 private key from a file: PEM encoded (PKCS #8, as written by openssl genpkey
+// -algorithm ed25519) or its seed of ed25519.SeedSize bytes, binary or as text (see ParseKey)
+func ReadSigningKey(fname string) (ed25519.PrivateKey, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    if block, _ := pem.Decode(data); block != nil {
+        key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", fname, err)
+        }
+        if private, ok := key.(ed25519.PrivateKey); ok {
+            return private, nil
+        }
+        return nil, errors.New(fmt.Sprintf("%s: not an Ed25519 key", fname))
+    }
+    seed, err := readRawKey(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ReadVerifyKey reads an Ed25519 public key from a file: PEM encoded (PKIX, as written by openssl pkey -pubout) or its
+// ed25519.PublicKeySize bytes, binary or as text (see ParseKey)
+func ReadVerifyKey(fname string) (ed25519.PublicKey, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    if block, _ := pem.Decode(data); block != nil {
+        key, err := x509.ParsePKIXPublicKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", fname, err)
+        }
+        if public, ok := key.(ed25519.PublicKey); ok {
+            return public, nil
+        }
+        return nil, errors.New(fmt.Sprintf("%s: not an Ed25519 key", fname))
+    }
+    public, err := readRawKey(data)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return ed25519.PublicKey(public), nil
+}
+
+// readRawKey decodes a key of KeySize bytes (the size of Ed25519 seeds & public keys as well) given as binary data or
+// text (see ParseKey)
+func readRawKey(data []byte) ([]byte, error) {
+    if len(data) == KeySize {
+        return data, nil
+    }
+    return ParseKey(string(data))
+}
@@ -0,0 +1,183 @@
+package qrFile
+
+import (
+    "archive/zip"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+)
+
+// The images & the manifest of a set are written to a Storage: a local directory (DirStorage), a remote target
+// accepting HTTP PUT requests, e.g. a WebDAV share of a NAS or an artifact store (HTTPStorage), so a headless encoder
+// can push a set directly to where it is kept, or a single zip archive (ZipStorage), e.g. for a download. Storage is
+// the writable counterpart of the io/fs.FS read by FromFS.
+
+// Storage stores the files written for a set by WritePNGsTo
+type Storage interface {
+    // Create returns a writer for the file of the given name; the file is complete once the writer is closed
+    Create(name string) (io.WriteCloser, error)
+}
+
+// DirStorage stores files in a local directory; as an fs.FS, it reads them back (see FromFS)
+type DirStorage string
+
+// Create creates the file in the directory
+func (dir DirStorage) Create(name string) (io.WriteCloser, error) {
+    return os.Create(filepath.Join(string(dir), name))
+}
+
+// Open opens a file of the directory, like os.DirFS does
+func (dir DirStorage) Open(name string) (fs.File, error) {
+    return os.DirFS(string(dir)).Open(name)
+}
+
+// httpStorageConnections limits the number of concurrent requests of an HTTPStorage
+const httpStorageConnections = 4
+
+// HTTPStorage uploads files with HTTP PUT requests to URL/<name>. The collection at URL is created with MKCOL first
+// (WebDAV), which is skipped silently by servers not supporting it.
+type HTTPStorage struct {
+    URL      string // base URL, e.g. https://nas.local/remote.php/dav/files/backup/qr
+    Username string // basic authentication, if not empty
+    Password string
+    Client   *http.Client // http.DefaultClient if nil
+
+    once  sync.Once
+    slots chan bool
+}
+
+// NewHTTPStorage creates an HTTPStorage for the URL; user & password given in the URL are used for basic
+// authentication
+func NewHTTPStorage(rawURL string) (*HTTPStorage, error) {
+    target, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, err
+    }
+    if target.Scheme != "http" && target.Scheme != "https" {
+        return nil, errors.New(fmt.Sprintf("Unsupported storage URL %s, expected http or https", rawURL))
+    }
+    storage := new(HTTPStorage)
+    if target.User != nil {
+        storage.Username = target.User.Username()
+        storage.Password, _ = target.User.Password()
+        target.User = nil
+    }
+    storage.URL = target.String()
+    return storage, nil
+}
+
+// IsStorageURL reports whether a target given by the user is an URL of an HTTPStorage rather than a local directory
+func IsStorageURL(target string) bool {
+    return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// Create returns a writer buffering the file; it is uploaded when the writer is closed
+func (storage *HTTPStorage) Create(name string) (io.WriteCloser, error) {
+    storage.once.Do(func() {
+        storage.slots = make(chan bool, httpStorageConnections)
+        // the collection may exist already or the server may not know WebDAV; PUT reports real problems
+        request, err := storage.request("MKCOL", strings.TrimSuffix(storage.URL, "/")+"/", nil)
+        if err == nil {
+            if response, err := storage.client().Do(request); err == nil {
+                response.Body.Close()
+            }
+        }
+    })
+    return &httpUpload{storage: storage, url: strings.TrimSuffix(storage.URL, "/") + "/" + url.PathEscape(name)}, nil
+}
+
+// client returns the http client used for requests
+func (storage *HTTPStorage) client() *http.Client {
+    if storage.Client == nil {
+        return http.DefaultClient
+    }
+    return storage.Client
+}
+
+// request creates a request, including the credentials
+func (storage *HTTPStorage) request(method string, target string, body []byte) (*http.Request, error) {
+    request, err := http.NewRequest(method, target, bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+    if len(storage.Username) > 0 {
+        request.SetBasicAuth(storage.Username, storage.Password)
+    }
+    return request, nil
+}
+
+// httpUpload buffers a file of an HTTPStorage until it is closed
+type httpUpload struct {
+    bytes.Buffer
+    storage *HTTPStorage
+    url     string
+}
+
+// Close uploads the file
+func (upload *httpUpload) Close() error {
+    upload.storage.slots <- true
+    defer func() { <-upload.storage.slots }()
+    request, err := upload.storage.request(http.MethodPut, upload.url, upload.Bytes())
+    if err != nil {
+        return err
+    }
+    response, err := upload.storage.client().Do(request)
+    if err != nil {
+        return err
+    }
+    response.Body.Close()
+    if response.StatusCode < 200 || response.StatusCode > 299 {
+        return errors.New(fmt.Sprintf("PUT %s failed: %s", upload.url, response.Status))
+    }
+    return nil
+}
+
+// ZipStorage stores files in a zip archive written to a stream, e.g. the response of a download. Each file is added
+// once its writer is closed; Close completes the archive.
+type ZipStorage struct {
+    mutex   sync.Mutex
+    archive *zip.Writer
+}
+
+// NewZipStorage creates a ZipStorage writing the archive to w
+func NewZipStorage(w io.Writer) *ZipStorage {
+    return &ZipStorage{archive: zip.NewWriter(w)}
+}
+
+// Create returns a writer buffering the file; it is added to the archive when the writer is closed
+func (storage *ZipStorage) Create(name string) (io.WriteCloser, error) {
+    return &zipEntry{storage: storage, name: name}, nil
+}
+
+// Close writes the end of the archive; the underlying writer is not closed
+func (storage *ZipStorage) Close() error {
+    storage.mutex.Lock()
+    defer storage.mutex.Unlock()
+    return storage.archive.Close()
+}
+
+// zipEntry buffers a file of a ZipStorage until it is closed
+type zipEntry struct {
+    bytes.Buffer
+    storage *ZipStorage
+    name    string
+}
+
+// Close adds the file to the archive; images are stored as they are, since png data is compressed already
+func (entry *zipEntry) Close() error {
+    method := zip.Deflate
+    if strings.HasSuffix(entry.name, ".png") {
+        method = zip.Store
+    }
+    entry.storage.mutex.Lock()
+    defer entry.storage.mutex.Unlock()
+    return writeZipFile(entry.storage.archive, entry.name, method, entry.Bytes(), "")
+}
@@ -0,0 +1,427 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/gif"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "math"
+    "os"
+    "strings"
+    "time"
+)
+
+// Stream output shows the elements one after another as frames of an animation, to be captured by a camera. A capture
+// glitch loses a few consecutive frames; since the frames are interleaved (elements far apart in the data are shown next
+// to each other), such a burst loss hits elements scattered over the set instead of a contiguous region, which are
+// picked up again in the next loop. The receiving side orders the elements by the index stored in them, so no
+// de-interleaving information is needed.
+//
+// Optionally, the stream starts with a calibration frame (a focus target and black & white areas, around a code reading
+// "QRF CAL") and sync frames are inserted periodically: their code holds the set parameters ("QRF SYNC" followed by the
+// Manifest without its chunk list), so a receiver joining late learns the size of the set before seeing any element.
+// Both are skipped when reading the elements.
+
+// DefaultFrameDelay is the time each frame is shown, in 1/100 s
+const DefaultFrameDelay = 50
+
+// Frame delays (in 1/100 s) used when deriving the timing from StreamOptions.Duration: frames shown shorter than
+// MinFrameDelay are not captured reliably; codes shown longer than MaxFrameDelay are repeated within the loop instead,
+// so a receiver joining late does not wait for long.
+const (
+    MinFrameDelay = 10
+    MaxFrameDelay = 100
+)
+
+// StreamOptions controls the frames of stream output (see WriteGIF)
+type StreamOptions struct {
+    FrameDelay int // time each frame is shown, in 1/100 s; DefaultFrameDelay if 0
+    Interleave int // distance (in elements) between consecutive frames; 1 keeps the order, 0 selects a distance of about the square root of the number of elements
+    // Repeat shows each element in Repeat frames (if > 1), to compensate for shutter & rolling artifacts of cameras. The
+    // frames follow each other, or, with RepeatSpread, the whole sequence is repeated within the loop.
+    Repeat       int
+    RepeatSpread bool
+    // Duration, if set, is the desired length of the loop. FrameDelay & the repetitions are derived from it, keeping the
+    // frame delay between MinFrameDelay and MaxFrameDelay; the resulting loop may thus be longer (see LoopDuration).
+    Duration time.Duration
+    // Calibration prepends a calibration frame; SyncInterval (if > 0) inserts a sync frame before every SyncInterval
+    // element frames.
+    Calibration  bool
+    SyncInterval int
+}
+
+// resolve returns the options with the defaults applied & the timing derived from Duration for count elements
+func (options StreamOptions) resolve(count int) StreamOptions {
+    if options.FrameDelay <= 0 {
+        options.FrameDelay = DefaultFrameDelay
+    }
+    if options.Repeat < 1 {
+        options.Repeat = 1
+    }
+    if options.Duration <= 0 || count == 0 {
+        return options
+    }
+    perElement := int(options.Duration/(10*time.Millisecond)) / count
+    options.Repeat = 1
+    if perElement > MaxFrameDelay {
+        options.Repeat = (perElement + MaxFrameDelay - 1) / MaxFrameDelay
+    }
+    options.RepeatSpread = options.Repeat > 1
+    options.FrameDelay = perElement / options.Repeat
+    if options.FrameDelay < MinFrameDelay {
+        options.FrameDelay = MinFrameDelay
+    }
+    return options
+}
+
+// LoopDuration returns the length of a loop of stream output with the given options
+func (elem *QrElements) LoopDuration(options StreamOptions) time.Duration {
+    return time.Duration(len(elem.streamFrames(options))*options.resolve(elem.Len()).FrameDelay) * 10 * time.Millisecond
+}
+
+// InterleaveOrder returns the order of count frames with the given stride: 0, stride, 2*stride, ..., 1, 1+stride, ...
+// A stride of 0 selects about the square root of count.
+func InterleaveOrder(count int, stride int) []int {
+    if stride <= 0 {
+        stride = int(math.Ceil(math.Sqrt(float64(count))))
+    }
+    if stride < 1 {
+        stride = 1
+    }
+    order := make([]int, 0, count)
+    for start := 0; start < stride && start < count; start++ {
+        for i := start; i < count; i += stride {
+            order = append(order, i)
+        }
+    }
+    return order
+}
+
+// frameOrder returns the positions of the elements in the order they are shown, including repetitions
+func (elem *QrElements) frameOrder(options StreamOptions) []int {
+    return countFrameOrder(elem.Len(), options.resolve(elem.Len()))
+}
+
+// countFrameOrder returns the order of count elements with the (resolved) options, including repetitions
+func countFrameOrder(count int, options StreamOptions) []int {
+    order := InterleaveOrder(count, options.Interleave)
+    if options.Repeat <= 1 {
+        return order
+    }
+    frames := make([]int, 0, len(order)*options.Repeat)
+    if options.RepeatSpread {
+        for k := 0; k < options.Repeat; k++ {
+            frames = append(frames, order...)
+        }
+        return frames
+    }
+    for _, i := range order {
+        for k := 0; k < options.Repeat; k++ {
+            frames = append(frames, i)
+        }
+    }
+    return frames
+}
+
+// Special positions in the frame list returned by streamFrames
+const (
+    frameCalibration = -1
+    frameSync        = -2
+)
+
+// streamFrames returns the frames of the stream: the positions of the elements as returned by frameOrder, with the
+// calibration & sync frames inserted as frameCalibration and frameSync
+func (elem *QrElements) streamFrames(options StreamOptions) []int {
+    order := elem.frameOrder(options)
+    if !options.Calibration && options.SyncInterval <= 0 {
+        return order
+    }
+    frames := make([]int, 0, len(order)+2)
+    if options.Calibration {
+        frames = append(frames, frameCalibration)
+    }
+    for i, position := range order {
+        if options.SyncInterval > 0 && i%options.SyncInterval == 0 {
+            frames = append(frames, frameSync)
+        }
+        frames = append(frames, position)
+    }
+    return frames
+}
+
+// Texts of the control frames
+const (
+    calibrationText = "QRF CAL"
+    syncPrefix      = "QRF SYNC "
+)
+
+// IsControlText reports whether text (e.g. the text of a scanned code) belongs to a calibration or sync frame of a
+// stream, to an acknowledgement of a receiver (see AckText) or to the parameter code of a set (see ParameterText),
+// instead of an element
+func IsControlText(text string) bool {
+    text = strings.TrimSpace(text)
+    return text == calibrationText || strings.HasPrefix(text, syncPrefix) || strings.HasPrefix(text, ackPrefix) || strings.HasPrefix(text, parametersPrefix)
+}
+
+// SyncText returns the text of a sync frame announcing the set described by the manifest (its chunk list is left out)
+func SyncText(manifest *Manifest) (string, error) {
+    header := *manifest
+    header.Chunks = nil
+    data, err := json.Marshal(&header)
+    if err != nil {
+        return "", err
+    }
+    return syncPrefix + string(data), nil
+}
+
+// ParseSync returns the set parameters announced by the text of a sync frame (see SyncText)
+func ParseSync(text string) (*Manifest, error) {
+    text = strings.TrimSpace(text)
+    if !strings.HasPrefix(text, syncPrefix) {
+        return nil, errors.New("Not a sync frame")
+    }
+    manifest := new(Manifest)
+    err := json.Unmarshal([]byte(strings.TrimPrefix(text, syncPrefix)), manifest)
+    if err != nil {
+        return nil, fmt.Errorf("Malformed sync frame: %w", err)
+    }
+    return manifest, nil
+}
+
+// calibrationFrame renders the calibration frame with (at least) the given size: a checkerboard border as focus target
+// and black & white reference, the code reading calibrationText in the center
+func (elem *QrElements) calibrationFrame(width int, height int) (image.Image, error) {
+    code, err := elem.encoder().Encode(calibrationText, LevelH)
+    if err != nil {
+        return nil, err
+    }
+    bounds := code.Bounds()
+    // the border takes an eighth of each side, the code needs the space in between
+    if w := bounds.Dx() * 4 / 3; w > width {
+        width = w
+    }
+    if h := bounds.Dy() * 4 / 3; h > height {
+        height = h
+    }
+    cell := width / 16
+    if h := height / 16; h < cell {
+        cell = h
+    }
+    if cell < 1 {
+        cell = 1
+    }
+    frame := image.NewRGBA(image.Rect(0, 0, width, height))
+    draw.Draw(frame, frame.Bounds(), image.White, image.ZP, draw.Src)
+    for y := 0; y < height; y += cell {
+        for x := 0; x < width; x += cell {
+            border := x < 2*cell || y < 2*cell || x >= width-2*cell || y >= height-2*cell
+            if border && (x/cell+y/cell)%2 == 0 {
+                draw.Draw(frame, image.Rect(x, y, x+cell, y+cell), image.Black, image.ZP, draw.Src)
+            }
+        }
+    }
+    left, top := (width-bounds.Dx())/2, (height-bounds.Dy())/2
+    draw.Draw(frame, image.Rect(left, top, left+bounds.Dx(), top+bounds.Dy()), code, bounds.Min, draw.Src)
+    return frame, nil
+}
+
+// streamImages renders the frames of the stream (see streamFrames) & returns them with the size of a screen fitting all
+// of them
+func (elem *QrElements) streamImages(options StreamOptions) ([]*image.Paletted, int, int, error) {
+    if elem.Len() == 0 {
+        return nil, 0, 0, errors.New("No elements to write.")
+    }
+    frames := elem.streamFrames(options)
+    rendered := make(map[int]*image.Paletted)
+    width, height := 0, 0
+    for _, i := range frames {
+        if _, ok := rendered[i]; ok || i < 0 {
+            continue
+        }
+        img, err := elem.render(&elem.Elements[i])
+        if err != nil {
+            return nil, 0, 0, err
+        }
+        rendered[i] = bilevelPaletted(img)
+        // frames may differ in size (e.g. with transcriptions of different length), the screen fits all of them
+        if rendered[i].Rect.Dx() > width {
+            width = rendered[i].Rect.Dx()
+        }
+        if rendered[i].Rect.Dy() > height {
+            height = rendered[i].Rect.Dy()
+        }
+    }
+    if options.SyncInterval > 0 {
+        text, err := SyncText(elem.Manifest())
+        if err != nil {
+            return nil, 0, 0, err
+        }
+        img, err := elem.encoder().Encode(text, elem.Level)
+        if err != nil {
+            return nil, 0, 0, err
+        }
+        rendered[frameSync] = bilevelPaletted(img)
+    }
+    if options.Calibration {
+        img, err := elem.calibrationFrame(width, height)
+        if err != nil {
+            return nil, 0, 0, err
+        }
+        rendered[frameCalibration] = bilevelPaletted(img)
+    }
+    images := make([]*image.Paletted, len(frames))
+    for n, i := range frames {
+        images[n] = rendered[i]
+        if images[n].Rect.Dx() > width {
+            width = images[n].Rect.Dx()
+        }
+        if images[n].Rect.Dy() > height {
+            height = images[n].Rect.Dy()
+        }
+    }
+    return images, width, height, nil
+}
+
+// WriteGIF renders all elements (using the SymbolEncoder set in Encoder) and writes them as frames of a looping animated
+// GIF, interleaved as selected in options, including the calibration & sync frames selected.
+func (elem *QrElements) WriteGIF(w io.Writer, options StreamOptions) error {
+    images, width, height, err := elem.streamImages(options)
+    if err != nil {
+        return err
+    }
+    delay := options.resolve(elem.Len()).FrameDelay
+    animation := new(gif.GIF)
+    for _, frame := range images {
+        animation.Image = append(animation.Image, frame)
+        animation.Delay = append(animation.Delay, delay)
+        animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+    }
+    animation.Config.Width, animation.Config.Height = width, height
+    animation.Config.ColorModel = bilevelPalette
+    return gif.EncodeAll(w, animation)
+}
+
+// WriteGIFFile writes all elements to the animated GIF file fname, see WriteGIF
+func (elem *QrElements) WriteGIFFile(fname string, options StreamOptions) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.WriteGIF(file, options)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// FrameRate returns the number of frames shown per second in stream output with the given options, e.g. to play a
+// frame sequence written by WriteFrames at the speed of the animated GIF
+func (elem *QrElements) FrameRate(options StreamOptions) float64 {
+    return 100 / float64(options.resolve(elem.Len()).FrameDelay)
+}
+
+// FrameName returns the name of the image of the given frame (counted from 0) written by WriteFrames:
+// <prefix><frame>.png, with 5 digits, so the names sort in order & match the pattern <prefix>%05d.png of video tools
+func FrameName(fnamePrefix string, frame int) string {
+    return fmt.Sprintf("%s%05d.png", fnamePrefix, frame)
+}
+
+// WriteFrames writes the frames of stream output as a numbered sequence of png images to the directory workPath, e.g.
+// to be shown by an image viewer or turned into a video (see FrameName & FrameRate). The frames are the ones of
+// WriteGIF, in the same order; all images have the size of the largest frame.
+// The directory is locked while the frames are written (see LockDirectory).
+func (elem *QrElements) WriteFrames(workPath string, fnamePrefix string, options StreamOptions) error {
+    return elem.WriteFramesTo(DirStorage(workPath), fnamePrefix, options)
+}
+
+// WriteFramesTo works like WriteFrames, but writes the images to a Storage
+func (elem *QrElements) WriteFramesTo(storage Storage, fnamePrefix string, options StreamOptions) error {
+    unlock, err := lockStorage(storage)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    images, width, height, err := elem.streamImages(options)
+    if err != nil {
+        return err
+    }
+    for n, frame := range images {
+        if frame.Rect.Dx() != width || frame.Rect.Dy() != height {
+            // the smaller frames are shown in the top left corner of the screen, as in the animated GIF
+            padded := image.NewPaletted(image.Rect(0, 0, width, height), bilevelPalette)
+            draw.Draw(padded, frame.Rect, frame, frame.Rect.Min, draw.Src)
+            frame = padded
+        }
+        out, err := storage.Create(FrameName(fnamePrefix, n))
+        if err != nil {
+            return err
+        }
+        err = png.Encode(out, frame)
+        if err != nil {
+            out.Close()
+            return err
+        }
+        err = out.Close()
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// bilevelPalette is the palette of the frames written
+var bilevelPalette = color.Palette{color.White, color.Black}
+
+// bilevelPaletted converts an image to a black & white paletted image; pixels darker than 50% gray become black
+func bilevelPaletted(img image.Image) *image.Paletted {
+    bounds := img.Bounds()
+    out := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), bilevelPalette)
+    for y := 0; y < bounds.Dy(); y++ {
+        for x := 0; x < bounds.Dx(); x++ {
+            if color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y < 0x80 {
+                out.Pix[y*out.Stride+x] = 1
+            }
+        }
+    }
+    return out
+}
+
+// readGIFFile reads all frames of a (possibly animated) GIF file. Frames only covering a part of the screen are drawn
+// over the previous frame, as a viewer would show them. Repeated frames (see StreamOptions.Repeat) are only returned
+// once.
+func readGIFFile(fname string) ([]image.Image, error) {
+    data, err := ioutil.ReadFile(fname)
+    if err != nil {
+        return nil, err
+    }
+    return decodeGIF(data)
+}
+
+// decodeGIF reads all frames of GIF data, see readGIFFile
+func decodeGIF(data []byte) ([]image.Image, error) {
+    animation, err := gif.DecodeAll(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    screen := image.NewRGBA(image.Rect(0, 0, animation.Config.Width, animation.Config.Height))
+    draw.Draw(screen, screen.Bounds(), image.White, image.ZP, draw.Src)
+    frames := make([]image.Image, 0, len(animation.Image))
+    for _, frame := range animation.Image {
+        draw.Draw(screen, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+        if len(frames) > 0 && bytes.Equal(frames[len(frames)-1].(*image.RGBA).Pix, screen.Pix) {
+            continue
+        }
+        current := image.NewRGBA(screen.Bounds())
+        copy(current.Pix, screen.Pix)
+        frames = append(frames, current)
+    }
+    return frames, nil
+}
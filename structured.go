@@ -0,0 +1,85 @@
+package qrFile
+
+import (
+    "errors"
+    "fmt"
+    "rsc.io/qr/coding"
+)
+
+// QR codes have a mode of their own to split a message across symbols: Structured Append. Each symbol starts with a
+// header holding its position in the sequence (up to 16 symbols) & the parity of the whole message, so standard QR
+// readers recognize the codes as one message & join their texts in order. The codes of a set keep the header of
+// their format as well, so qrFile reads them in any order like any other set; Structured Append only adds the
+// sequence for other readers. It is set with EncodeOptions.StructuredAppend (QrElements.StructuredAppend) & requires
+// the internal encoder (RscEncoder).
+
+// MaxStructuredAppend is the largest number of codes a Structured Append sequence holds
+const MaxStructuredAppend = 16
+
+// structuredAppendReserve is the amount of bytes of the capacity of a code taken by the Structured Append header
+const structuredAppendReserve = 3
+
+// structuredAppendMode is the mode indicator of the Structured Append header
+const structuredAppendMode = 3
+
+// structuredAppendHeader is the Structured Append header of a symbol, written by Plan.Encode before the text
+type structuredAppendHeader struct {
+    index  int  // position of the symbol in the sequence (0-15)
+    total  int  // number of symbols of the sequence (1-16)
+    parity byte // XOR of all bytes of the message, see structuredAppendParity
+}
+
+// Check implements coding.Encoding
+func (h structuredAppendHeader) Check() error {
+    if h.total < 1 || h.total > MaxStructuredAppend || h.index < 0 || h.index >= h.total {
+        return errors.New(fmt.Sprintf("Invalid Structured Append position %d of %d", h.index+1, h.total))
+    }
+    return nil
+}
+
+// Bits implements coding.Encoding: the mode indicator, the index, the count & the parity
+func (structuredAppendHeader) Bits(v coding.Version) int {
+    return 4 + 4 + 4 + 8
+}
+
+// Encode implements coding.Encoding
+func (h structuredAppendHeader) Encode(b *coding.Bits, v coding.Version) {
+    b.Write(structuredAppendMode, 4)
+    b.Write(uint(h.index), 4)
+    b.Write(uint(h.total-1), 4)
+    b.Write(uint(h.parity), 8)
+}
+
+// structuredAppendParity returns the parity of the Structured Append sequence of the elements: the XOR of all bytes of
+// their texts, which form the message joined by standard readers
+func (elem *QrElements) structuredAppendParity() byte {
+    var parity byte
+    for i := range elem.Elements {
+        text := elem.Elements[i].AsString()
+        for j := 0; j < len(text); j++ {
+            parity ^= text[j]
+        }
+    }
+    return parity
+}
+
+// checkStructuredAppend checks that the codes of a set of count elements can form a Structured Append sequence
+func checkStructuredAppend(count uint64, encoder SymbolEncoder) error {
+    if count > MaxStructuredAppend {
+        return errors.New(fmt.Sprintf("Structured Append links at most %d codes, the set has %d; raise the chunk size or use fewer codes", MaxStructuredAppend, count))
+    }
+    if _, ok := encoder.(RscEncoder); encoder != nil && !ok {
+        return errors.New(fmt.Sprintf("Structured Append requires the internal encoder, not %T", encoder))
+    }
+    return nil
+}
+
+// encodeStructuredAppend returns the modules of a QR code holding text preceded by a Structured Append header, in the
+// smallest version from minVersion on it fits like RscEncoder
+func encodeStructuredAppend(text string, level Level, minVersion int, header structuredAppendHeader) ([][]bool, error) {
+    code, err := encodeRscVersion(text, level, minVersion, header)
+    if err != nil {
+        return nil, err
+    }
+    return codeModules(code), nil
+}
@@ -0,0 +1,334 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "os/exec"
+    "rsc.io/qr"
+    "rsc.io/qr/coding"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Level defines the amount of redundancy (error correction) used in a symbol
+type Level int
+
+// Error correction levels as defined by the QR standard
+const (
+    LevelL Level = iota // recovers 7% of the data
+    LevelM              // recovers 15% of the data
+    LevelQ              // recovers 25% of the data
+    LevelH              // recovers 30% of the data
+)
+
+// String returns the letter of the level (L, M, Q or H)
+func (l Level) String() string {
+    if l < LevelL || l > LevelH {
+        return "Level(" + strconv.Itoa(int(l)) + ")"
+    }
+    return string("LMQH"[l])
+}
+
+// ParseLevel returns the level for its letter (L, M, Q or H)
+func ParseLevel(letter string) (Level, error) {
+    for l := LevelL; l <= LevelH; l++ {
+        if strings.EqualFold(letter, l.String()) {
+            return l, nil
+        }
+    }
+    return LevelL, errors.New(fmt.Sprintf("Unknown error correction level %s", letter))
+}
+
+// symbolCapacity holds the amount of bytes a QR code (version 40, byte mode) holds for each level
+var symbolCapacity = [...]int{2953, 2331, 1663, 1273}
+
+// SymbolCapacity returns the maximum amount of bytes a single QR code can hold at the given level
+func SymbolCapacity(level Level) int {
+    if level < LevelL || level > LevelH {
+        return 0
+    }
+    return symbolCapacity[level]
+}
+
+// SymbolEncoder renders the text of a single QrElement (see QrElement.AsString) as an image. WritePNGs uses the encoder
+// set in QrElements.Encoder, or DefaultEncoder if none is set.
+type SymbolEncoder interface {
+    Encode(text string, level Level) (image.Image, error)
+}
+
+// ContextEncoder is implemented by encoders running an external process (e.g. qrencode), so rendering an image can be
+// aborted (see WritePNGsContext)
+type ContextEncoder interface {
+    SymbolEncoder
+    EncodeContext(ctx context.Context, text string, level Level) (image.Image, error)
+}
+
+// encodeSymbol renders text with an encoder, returning ctx.Err() if ctx is canceled before or, for a ContextEncoder,
+// while the image is rendered
+func encodeSymbol(ctx context.Context, encoder SymbolEncoder, text string, level Level) (image.Image, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if cancelable, ok := encoder.(ContextEncoder); ok {
+        return cancelable.EncodeContext(ctx, text, level)
+    }
+    return encoder.Encode(text, level)
+}
+
+// VersionEncoder is implemented by QR encoders which render a code in a given version or a larger one, so all codes of a
+// set have the same size (see QrElements.PadVersion); the space left after the data is filled with padding
+type VersionEncoder interface {
+    MatrixEncoder
+    // EncodeVersion works like Encode, but renders a code of the given version (1-40) at least
+    EncodeVersion(text string, level Level, version int) (image.Image, error)
+    // EncodeMatrixVersion works like EncodeMatrix, but returns a code of the given version (1-40) at least
+    EncodeMatrixVersion(text string, level Level, version int) ([][]bool, error)
+}
+
+// encodeVersion renders text with an encoder like encodeSymbol, in a code of the given version at least unless it is 0
+func encodeVersion(ctx context.Context, encoder SymbolEncoder, text string, level Level, version int) (image.Image, error) {
+    if version == 0 {
+        return encodeSymbol(ctx, encoder, text, level)
+    }
+    padded, ok := encoder.(VersionEncoder)
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("The encoder %T can not pad codes to a QR version", encoder))
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    return padded.EncodeVersion(text, level, version)
+}
+
+// encodeMatrixVersion returns the modules of the code holding text, in the given version at least unless it is 0
+func encodeMatrixVersion(encoder MatrixEncoder, text string, level Level, version int) ([][]bool, error) {
+    if version == 0 {
+        return encoder.EncodeMatrix(text, level)
+    }
+    padded, ok := encoder.(VersionEncoder)
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("The encoder %T can not pad codes to a QR version", encoder))
+    }
+    return padded.EncodeMatrixVersion(text, level, version)
+}
+
+// setPadVersion sets PadVersion to the version of the largest code of the set, as the internal encoder renders it (with
+// a Structured Append header if StructuredAppend is set)
+func (elem *QrElements) setPadVersion() error {
+    if _, ok := elem.encoder().(VersionEncoder); !ok {
+        return errors.New(fmt.Sprintf("The encoder %T can not pad codes to a QR version", elem.encoder()))
+    }
+    for i := range elem.Elements {
+        v := &elem.Elements[i]
+        var header []coding.Encoding
+        if elem.StructuredAppend {
+            header = append(header, structuredAppendHeader{})
+        }
+        version, err := rscVersion(v.AsString(), elem.levelOf(v.Index), header...)
+        if err != nil {
+            return err
+        }
+        if int(version) > elem.PadVersion {
+            elem.PadVersion = int(version)
+        }
+    }
+    return nil
+}
+
+// DefaultEncoder is the encoder used if no other encoder is selected
+var DefaultEncoder SymbolEncoder = RscEncoder{}
+
+func init() {
+    RegisterEncoder("internal", RscEncoder{})
+    RegisterEncoder("qrencode", QrencodeEncoder{})
+}
+
+// registered encoders, see RegisterEncoder
+var encoders = make(map[string]SymbolEncoder)
+var encodersMutex sync.Mutex
+
+// RegisterEncoder makes an encoder available under the given name, so another QR backend can be selected by name (e.g.
+// from a command line flag) without changing the code creating the images
+func RegisterEncoder(name string, encoder SymbolEncoder) {
+    encodersMutex.Lock()
+    defer encodersMutex.Unlock()
+    encoders[name] = encoder
+}
+
+// GetEncoder returns the encoder registered under the given name
+func GetEncoder(name string) (SymbolEncoder, error) {
+    encodersMutex.Lock()
+    defer encodersMutex.Unlock()
+    encoder, ok := encoders[name]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unknown encoder %s", name))
+    }
+    return encoder, nil
+}
+
+// EncoderNames returns the names of all registered encoders in alphabetical order
+func EncoderNames() []string {
+    encodersMutex.Lock()
+    defer encodersMutex.Unlock()
+    names := make([]string, 0, len(encoders))
+    for name := range encoders {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// RscEncoder creates QR codes in-process using rsc.io/qr (the maintained home of the former code.google.com/p/rsc/qr),
+// registered as "internal"
+type RscEncoder struct{}
+
+// Encode implements SymbolEncoder
+func (RscEncoder) Encode(text string, level Level) (image.Image, error) {
+    code, err := encodeRsc(text, level)
+    if err != nil {
+        return nil, err
+    }
+    return png.Decode(bytes.NewReader(code.PNG()))
+}
+
+// EncodeVersion implements VersionEncoder
+func (RscEncoder) EncodeVersion(text string, level Level, version int) (image.Image, error) {
+    code, err := encodeRscVersion(text, level, version)
+    if err != nil {
+        return nil, err
+    }
+    return png.Decode(bytes.NewReader(code.PNG()))
+}
+
+// EncodeMatrixVersion implements VersionEncoder
+func (RscEncoder) EncodeMatrixVersion(text string, level Level, version int) ([][]bool, error) {
+    code, err := encodeRscVersion(text, level, version)
+    if err != nil {
+        return nil, err
+    }
+    return codeModules(code), nil
+}
+
+// encodeRsc returns the QR code holding text, preceded by the header segments, in the smallest version it fits. Unlike
+// qr.Encode, which stores all of the text in the densest mode all of its characters allow, a text in byte mode ending
+// in alphanumeric characters (e.g. a base45 payload, see codec.go) is stored in two segments if that takes fewer bits.
+func encodeRsc(text string, level Level, header ...coding.Encoding) (*qr.Code, error) {
+    return encodeRscVersion(text, level, 0, header...)
+}
+
+// encodeRscVersion works like encodeRsc, but returns a code of minVersion at least; the data is followed by padding
+func encodeRscVersion(text string, level Level, minVersion int, header ...coding.Encoding) (*qr.Code, error) {
+    v, err := rscVersion(text, level, header...)
+    if err != nil {
+        return nil, err
+    }
+    if minVersion > int(coding.MaxVersion) {
+        return nil, errors.New(fmt.Sprintf("Invalid QR version %d (1 to 40)", minVersion))
+    }
+    if v < coding.Version(minVersion) {
+        v = coding.Version(minVersion)
+    }
+    plan, err := coding.NewPlan(v, coding.Level(level), 0)
+    if err != nil {
+        return nil, err
+    }
+    code, err := plan.Encode(append(header, textSegments(text)...)...)
+    if err != nil {
+        return nil, err
+    }
+    return &qr.Code{Bitmap: code.Bitmap, Size: code.Size, Stride: code.Stride, Scale: DefaultModuleScale}, nil
+}
+
+// rscVersion returns the smallest version of a QR code holding text, preceded by the header segments, as encodeRsc
+// stores it
+func rscVersion(text string, level Level, header ...coding.Encoding) (coding.Version, error) {
+    if level < LevelL || level > LevelH {
+        return 0, errors.New(fmt.Sprintf("Invalid error correction level %d", level))
+    }
+    segments := append(header, textSegments(text)...)
+    l := coding.Level(level)
+    v := coding.Version(coding.MinVersion)
+    for ; segmentBits(segments, v) > v.DataBytes(l)*8; v++ {
+        if v == coding.MaxVersion {
+            return 0, errors.New("Text too long to encode as QR code")
+        }
+    }
+    return v, nil
+}
+
+// textSegments returns the segments storing text: a single one in the mode of qr.Encode, or a segment in byte mode
+// followed by one in alphanumeric mode for its alphanumeric end
+func textSegments(text string) []coding.Encoding {
+    switch {
+    case coding.Num(text).Check() == nil:
+        return []coding.Encoding{coding.Num(text)}
+    case coding.Alpha(text).Check() == nil:
+        return []coding.Encoding{coding.Alpha(text)}
+    }
+    split := len(text)
+    for split > 0 && strings.IndexByte(base45Alphabet, text[split-1]) >= 0 {
+        split--
+    }
+    whole := []coding.Encoding{coding.String(text)}
+    segments := []coding.Encoding{coding.String(text[:split]), coding.Alpha(text[split:])}
+    // the lengths of the segments take the most bits in the largest versions
+    if split == len(text) || segmentBits(segments, coding.MaxVersion) >= segmentBits(whole, coding.MaxVersion) {
+        return whole
+    }
+    return segments
+}
+
+// segmentBits returns the amount of bits the segments take in a code of version v
+func segmentBits(segments []coding.Encoding, v coding.Version) int {
+    bits := 0
+    for _, segment := range segments {
+        bits += segment.Bits(v)
+    }
+    return bits
+}
+
+// QrencodeEncoder creates QR codes using the qrencode command line tool of libqrencode (https://fukuchi.org/works/qrencode/)
+type QrencodeEncoder struct {
+    Path  string // location of the qrencode binary; if empty, qrencode is looked up (see ToolDirectory)
+    Scale int    // size of a module in pixels; if 0, the default of qrencode is used
+}
+
+// Encode implements SymbolEncoder. The text is passed to qrencode on stdin and always encoded in 8 bit mode.
+func (enc QrencodeEncoder) Encode(text string, level Level) (image.Image, error) {
+    return enc.EncodeContext(context.Background(), text, level)
+}
+
+// EncodeContext implements ContextEncoder: qrencode is killed if ctx is canceled
+func (enc QrencodeEncoder) EncodeContext(ctx context.Context, text string, level Level) (image.Image, error) {
+    if level < LevelL || level > LevelH {
+        return nil, errors.New(fmt.Sprintf("Invalid error correction level %d", level))
+    }
+    path := enc.Path
+    if len(path) == 0 {
+        path = "qrencode"
+    }
+    path = toolPath(path)
+    args := []string{"-8", "-t", "PNG", "-o", "-", "-l", level.String()}
+    if enc.Scale > 0 {
+        args = append(args, "-s", strconv.Itoa(enc.Scale))
+    }
+    var result, stderr bytes.Buffer
+    cmd := exec.CommandContext(ctx, path, args...)
+    cmd.Stdin = bytes.NewBufferString(text)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("qrencode failed: %s %s", err, bytes.TrimSpace(stderr.Bytes())))
+    }
+    return png.Decode(&result)
+}
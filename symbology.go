@@ -0,0 +1,170 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "github.com/makiuchi-d/gozxing"
+    "github.com/makiuchi-d/gozxing/datamatrix"
+    dmencoder "github.com/makiuchi-d/gozxing/datamatrix/encoder"
+    "image"
+    "image/png"
+    "os/exec"
+    "strconv"
+    "strings"
+)
+
+// QR codes are not the only symbology the elements can be printed as: Data Matrix codes print denser at small sizes &
+// PDF417 suits some scanners. The symbology only selects the encoder (see SymbologyEncoder) & the capacity of a code;
+// the text of the elements is the same in any symbology, so a set is read the same way. The native decoder reads QR,
+// Data Matrix & Aztec codes (see NativeDecoder); PDF417 codes are read by ZXingDecoder.
+
+// Symbology is a kind of 2D barcode
+type Symbology int
+
+// Supported symbologies
+const (
+    SymbologyQR         Symbology = iota // QR code, the default
+    SymbologyDataMatrix                  // Data Matrix (ECC 200), rendered in-process by DataMatrixEncoder
+    SymbologyAztec                       // Aztec code, rendered by ZintEncoder
+    SymbologyPDF417                      // PDF417, rendered by ZintEncoder
+)
+
+// symbologyNames holds the names of the symbologies, as accepted by ParseSymbology
+var symbologyNames = [...]string{"qr", "datamatrix", "aztec", "pdf417"}
+
+// String returns the name of the symbology, e.g. "datamatrix"
+func (s Symbology) String() string {
+    if s < SymbologyQR || int(s) >= len(symbologyNames) {
+        return "Symbology(" + strconv.Itoa(int(s)) + ")"
+    }
+    return symbologyNames[s]
+}
+
+// ParseSymbology returns the symbology for its name (qr, datamatrix, aztec or pdf417)
+func ParseSymbology(name string) (Symbology, error) {
+    for i, v := range symbologyNames {
+        if strings.EqualFold(name, v) {
+            return Symbology(i), nil
+        }
+    }
+    return SymbologyQR, errors.New(fmt.Sprintf("Unknown symbology %s", name))
+}
+
+// symbologyCapacity holds the characters of element text the largest code of each symbology other than QR holds, with
+// the default error correction of its encoder (Data Matrix 144x144, Aztec of 32 layers, PDF417 of 90 rows)
+var symbologyCapacity = [...]int{0, 1556, 1914, 1000}
+
+// Capacity returns the characters of element text a single code of the symbology holds. The level only applies to QR
+// codes (see SymbolCapacity); the others use the default error correction of their encoder.
+func (s Symbology) Capacity(level Level) int {
+    if s == SymbologyQR {
+        return SymbolCapacity(level)
+    }
+    if s < SymbologyQR || int(s) >= len(symbologyCapacity) {
+        return 0
+    }
+    return symbologyCapacity[s]
+}
+
+// SymbologyEncoder returns the encoder rendering codes of a symbology: DefaultEncoder for QR codes, DataMatrixEncoder
+// for Data Matrix codes & ZintEncoder for Aztec & PDF417 codes
+func SymbologyEncoder(s Symbology) (SymbolEncoder, error) {
+    switch s {
+    case SymbologyQR:
+        return DefaultEncoder, nil
+    case SymbologyDataMatrix:
+        return DataMatrixEncoder{}, nil
+    case SymbologyAztec, SymbologyPDF417:
+        return ZintEncoder{Symbology: s}, nil
+    }
+    return nil, errors.New(fmt.Sprintf("Unknown symbology %s", s))
+}
+
+// symbolQuietZone is the margin around a code rendered by DataMatrixEncoder, in modules
+const symbolQuietZone = 2
+
+// DataMatrixEncoder creates square Data Matrix codes (ECC 200) in-process using gozxing. Data Matrix has a fixed error
+// correction, so the level is ignored.
+type DataMatrixEncoder struct {
+    Scale int // size of a module in pixels; DefaultModuleScale if 0
+}
+
+// Encode implements SymbolEncoder
+func (enc DataMatrixEncoder) Encode(text string, level Level) (image.Image, error) {
+    modules, err := enc.EncodeMatrix(text, level)
+    if err != nil {
+        return nil, err
+    }
+    return drawModules(modules, RenderOptions{Scale: enc.Scale, QuietZone: symbolQuietZone})
+}
+
+// EncodeMatrix implements MatrixEncoder
+func (DataMatrixEncoder) EncodeMatrix(text string, level Level) ([][]bool, error) {
+    hints := map[gozxing.EncodeHintType]interface{}{gozxing.EncodeHintType_DATA_MATRIX_SHAPE: dmencoder.SymbolShapeHint_FORCE_SQUARE}
+    matrix, err := datamatrix.NewDataMatrixWriter().Encode(text, gozxing.BarcodeFormat_DATA_MATRIX, 0, 0, hints)
+    if err != nil {
+        return nil, fmt.Errorf("Unable to create a Data Matrix code: %w", err)
+    }
+    modules := make([][]bool, matrix.GetHeight())
+    for y := range modules {
+        modules[y] = make([]bool, matrix.GetWidth())
+        for x := range modules[y] {
+            modules[y][x] = matrix.Get(x, y)
+        }
+    }
+    return modules, nil
+}
+
+// zintBarcodes maps the symbologies to the barcode types of zint
+var zintBarcodes = map[Symbology]int{SymbologyQR: 58, SymbologyDataMatrix: 71, SymbologyAztec: 92, SymbologyPDF417: 55}
+
+// ZintEncoder creates codes of any symbology using the zint command line tool (https://zint.org.uk/). The level selects
+// the error correction of QR codes only; the other symbologies use the default of zint.
+type ZintEncoder struct {
+    Symbology Symbology
+    Path      string // location of the zint binary; if empty, zint is looked up (see ToolDirectory)
+    Scale     int    // scale factor passed to zint (--scale); if 0, the default of zint is used
+}
+
+// Encode implements SymbolEncoder. The text is passed to zint on stdin.
+func (enc ZintEncoder) Encode(text string, level Level) (image.Image, error) {
+    return enc.EncodeContext(context.Background(), text, level)
+}
+
+// EncodeContext implements ContextEncoder: zint is killed if ctx is canceled
+func (enc ZintEncoder) EncodeContext(ctx context.Context, text string, level Level) (image.Image, error) {
+    barcode, ok := zintBarcodes[enc.Symbology]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unknown symbology %s", enc.Symbology))
+    }
+    path := enc.Path
+    if len(path) == 0 {
+        path = "zint"
+    }
+    path = toolPath(path)
+    args := []string{"--barcode=" + strconv.Itoa(barcode), "--direct", "--filetype=PNG", "--input=-"}
+    if enc.Symbology == SymbologyQR {
+        if level < LevelL || level > LevelH {
+            return nil, errors.New(fmt.Sprintf("Invalid error correction level %d", level))
+        }
+        args = append(args, "--secure="+strconv.Itoa(int(level)+1))
+    }
+    if enc.Scale > 0 {
+        args = append(args, "--scale="+strconv.Itoa(enc.Scale))
+    }
+    var result, stderr bytes.Buffer
+    cmd := exec.CommandContext(ctx, path, args...)
+    cmd.Stdin = bytes.NewBufferString(text)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("zint failed: %s %s", err, bytes.TrimSpace(stderr.Bytes())))
+    }
+    return png.Decode(&result)
+}
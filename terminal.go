@@ -0,0 +1,147 @@
+package qrFile
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "rsc.io/qr"
+    "strconv"
+    "strings"
+)
+
+// Codes can be shown in a terminal, e.g. to transfer a file out of an environment only reachable by SSH: each character
+// cell holds two modules on top of each other (an upper half block with foreground & background color set), so the
+// modules are about square. Black & white are set explicitly, so the code reads the same with dark & light themes.
+
+// terminalQuietZone is the width of the light border around a code in modules; most readers accept a narrower border
+// than the 4 modules of the standard, & a terminal has little room
+const terminalQuietZone = 2
+
+// ANSI sequences selecting the colors of a cell: foreground (upper module) & background (lower module)
+const (
+    ansiForegroundDark  = "30"
+    ansiForegroundLight = "97"
+    ansiBackgroundDark  = "40"
+    ansiBackgroundLight = "107"
+    ansiReset           = "\x1b[0m"
+)
+
+// TerminalCode is a code rendered for a terminal, see RenderTerminal
+type TerminalCode struct {
+    Lines []string // lines of the code, including ANSI color sequences; each line ends with a reset
+    Width int      // width of the lines in character cells
+}
+
+// String returns the lines of the code, separated by line breaks
+func (c *TerminalCode) String() string {
+    return strings.Join(c.Lines, "\n")
+}
+
+// Height returns the number of lines of the code
+func (c *TerminalCode) Height() int {
+    return len(c.Lines)
+}
+
+// RenderTerminal renders text as QR code for a terminal supporting ANSI colors & Unicode block characters
+func RenderTerminal(text string, level Level) (*TerminalCode, error) {
+    if level < LevelL || level > LevelH {
+        return nil, errors.New(fmt.Sprintf("Invalid error correction level %d", level))
+    }
+    code, err := qr.Encode(text, qr.Level(level))
+    if err != nil {
+        return nil, err
+    }
+    size := code.Size + 2*terminalQuietZone
+    dark := func(x, y int) bool {
+        // Black is false outside of the code, i.e. in the quiet zone
+        return code.Black(x-terminalQuietZone, y-terminalQuietZone)
+    }
+    result := &TerminalCode{Width: size}
+    for y := 0; y < size; y += 2 {
+        var line strings.Builder
+        previous := ""
+        for x := 0; x < size; x++ {
+            foreground, background := ansiForegroundLight, ansiBackgroundLight
+            if dark(x, y) {
+                foreground = ansiForegroundDark
+            }
+            // the lower half of the last line is part of the quiet zone if the size is odd
+            if y+1 < size && dark(x, y+1) {
+                background = ansiBackgroundDark
+            }
+            colors := "\x1b[" + foreground + ";" + background + "m"
+            if colors != previous {
+                line.WriteString(colors)
+                previous = colors
+            }
+            line.WriteString("▀")
+        }
+        line.WriteString(ansiReset)
+        result.Lines = append(result.Lines, line.String())
+    }
+    return result, nil
+}
+
+// RenderTerminal renders the element at the given position for a terminal, at the error correction level of the element
+// (see Levels)
+func (elem *QrElements) RenderTerminal(position int) (*TerminalCode, error) {
+    if position < 0 || position >= elem.Len() {
+        return nil, errors.New(fmt.Sprintf("No element at position %d", position))
+    }
+    v := elem.Elements[position]
+    return RenderTerminal(v.AsString(), elem.levelOf(v.Index))
+}
+
+// terminalClear moves the cursor home & clears the screen
+const terminalClear = "\x1b[H\x1b[2J"
+
+// WriteTerminal writes the codes of all elements for a terminal (see RenderTerminal), each below a line naming its
+// number. Without input, the codes follow each other, e.g. for a serial console which is recorded. With input, the
+// codes are paged without raw mode, so it works on any console: the screen is cleared for every code & a line read from
+// input selects the next one: an empty line (Enter) or "n" the following code, "b" the previous one, a number that code
+// & "q" stops, as does the end of input.
+func (elem *QrElements) WriteTerminal(w io.Writer, input io.Reader) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to write.")
+    }
+    codes := make([]*TerminalCode, elem.Len())
+    for i := range codes {
+        code, err := elem.RenderTerminal(i)
+        if err != nil {
+            return err
+        }
+        codes[i] = code
+    }
+    if input == nil {
+        for i, code := range codes {
+            _, err := fmt.Fprintf(w, "Code %d/%d\n%s\n\n", i+1, len(codes), code)
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+    lines := bufio.NewScanner(input)
+    for current := 0; ; {
+        _, err := fmt.Fprintf(w, "%sCode %d/%d\n%s\nEnter: next, b: previous, number: jump, q: quit> ", terminalClear, current+1, len(codes), codes[current])
+        if err != nil {
+            return err
+        }
+        if !lines.Scan() {
+            return lines.Err()
+        }
+        switch answer := strings.TrimSpace(lines.Text()); answer {
+        case "q", "Q":
+            return nil
+        case "", "n":
+            current = (current + 1) % len(codes)
+        case "b":
+            current = (current + len(codes) - 1) % len(codes)
+        default:
+            if number, err := strconv.Atoi(answer); err == nil && number >= 1 && number <= len(codes) {
+                current = number - 1
+            }
+        }
+    }
+}
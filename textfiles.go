@@ -0,0 +1,159 @@
+package qrFile
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+// Where images can not be kept (media holding only text, a text-only channel, data typed from paper), a set is exported
+// as the text of its codes (see AsString) instead: all codes in a single text file, one code per line, or each code in
+// a numbered text file of its own. Such text is read back by ImportText & FromTextFiles, without any decoder; the
+// elements are checked like the ones read from images. If ParameterChunk is set, the text of the parameter code (see
+// ParameterText) is exported as well & configures the set when it is read.
+
+// writeText writes the text of the parameter code (if ParameterChunk is set) & of all elements to w, one per line
+func (elem *QrElements) writeText(w io.Writer) error {
+    out := bufio.NewWriter(w)
+    if elem.ParameterChunk {
+        text, err := elem.ParameterText()
+        if err != nil {
+            return err
+        }
+        fmt.Fprintln(out, text)
+    }
+    for i := range elem.Elements {
+        fmt.Fprintln(out, elem.Elements[i].AsString())
+    }
+    return out.Flush()
+}
+
+// WriteText writes the text of all elements to w, one element per line, as read by ImportText
+func (elem *QrElements) WriteText(w io.Writer) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to export")
+    }
+    return elem.writeText(w)
+}
+
+// textName returns the name of the text file of the element at position i, numbered like the images (see imageName)
+func (elem *QrElements) textName(fnamePrefix string, i int) string {
+    if elem.ContentNames {
+        return fmt.Sprintf("%s%d_%s.txt", fnamePrefix, i, elem.Elements[i].Hash()[:imageNameHashLength])
+    }
+    return fmt.Sprintf("%s%d.txt", fnamePrefix, i)
+}
+
+// ParametersTextName is the name of the text file of the parameter code written by WriteTextFiles (preceded by the file
+// name prefix)
+const ParametersTextName = "params.txt"
+
+// WriteTextFiles writes the text of each element to a file of its own in workPath, named <fnamePrefix><n>.txt like the
+// images written by WritePNGs, along with the manifest (& the checksums file, see Checksums) listing the files
+func (elem *QrElements) WriteTextFiles(workPath string, fnamePrefix string) error {
+    return elem.WriteTextFilesTo(DirStorage(workPath), fnamePrefix)
+}
+
+// WriteTextFilesTo works like WriteTextFiles, but writes the files to a Storage
+func (elem *QrElements) WriteTextFilesTo(storage Storage, fnamePrefix string) error {
+    if elem.Len() == 0 {
+        return errors.New("No elements to export")
+    }
+    unlock, err := lockStorage(storage)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    var sums *checksumStorage
+    if elem.Checksums {
+        sums = newChecksumStorage(storage)
+        storage = sums
+    }
+    manifest := elem.Manifest()
+    for i := range elem.Elements {
+        manifest.Chunks[i].File = elem.textName(fnamePrefix, i)
+        err = writeStorageFile(storage, manifest.Chunks[i].File, elem.Elements[i].AsString()+"\n")
+        if err != nil {
+            return err
+        }
+        elem.progress(Progress{Stage: StageWriting, Done: i + 1, Total: len(elem.Elements)})
+    }
+    if elem.ParameterChunk {
+        text, err := elem.ParameterText()
+        if err != nil {
+            return err
+        }
+        err = writeStorageFile(storage, fnamePrefix+ParametersTextName, text+"\n")
+        if err != nil {
+            return err
+        }
+    }
+    out, err := storage.Create(fnamePrefix + ManifestName)
+    if err != nil {
+        return err
+    }
+    err = manifest.Write(out)
+    if err != nil {
+        out.Close()
+        return err
+    }
+    err = out.Close()
+    if err != nil || sums == nil {
+        return err
+    }
+    return sums.write(fnamePrefix + ChecksumsName)
+}
+
+// writeStorageFile writes text to the file name of storage
+func writeStorageFile(storage Storage, name string, text string) error {
+    out, err := storage.Create(name)
+    if err != nil {
+        return err
+    }
+    _, err = io.WriteString(out, text)
+    if err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// FromTextFiles reads the text of the elements from the given files (e.g. written by WriteText or WriteTextFiles), any
+// number of elements per file, one per line, & stores them in a set of QrElement structs like ImportText
+func (elem *QrElements) FromTextFiles(files []string) error {
+    if len(files) == 0 {
+        return errors.New("No files to read")
+    }
+    strs := make([]string, 0, len(files))
+    for _, fname := range files {
+        lines, err := readTextLines(fname)
+        if err != nil {
+            return err
+        }
+        strs = append(strs, lines...)
+    }
+    return elem.ImportStrings(strs)
+}
+
+// readTextLines returns the non-empty lines of a text file, prepared for parsing (see textLine)
+func readTextLines(fname string) ([]string, error) {
+    file, err := os.Open(fname)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    lines := make([]string, 0)
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    for scanner.Scan() {
+        if line, ok := textLine(scanner.Text()); ok {
+            lines = append(lines, line)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    return lines, nil
+}
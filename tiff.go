@@ -0,0 +1,474 @@
+package qrFile
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "io"
+    "io/ioutil"
+    "os"
+)
+
+// Minimal multipage TIFF support. Pages are written as bilevel (1 bit) images using PackBits compression. Reading
+// supports the formats scanning and archival systems commonly produce: bilevel, 8 bit grayscale and RGB(A) images with
+// no, PackBits or LZW compression.
+
+// TIFF tags used
+const (
+    tiffImageWidth      = 256
+    tiffImageLength     = 257
+    tiffBitsPerSample   = 258
+    tiffCompression     = 259
+    tiffPhotometric     = 262
+    tiffStripOffsets    = 273
+    tiffOrientation     = 274
+    tiffSamplesPerPixel = 277
+    tiffRowsPerStrip    = 278
+    tiffStripByteCounts = 279
+    tiffXResolution     = 282
+    tiffYResolution     = 283
+    tiffPlanarConfig    = 284
+    tiffResolutionUnit  = 296
+    tiffPageNumber      = 297
+    tiffPredictor       = 317
+)
+
+// TIFF compression schemes supported
+const (
+    tiffCompressionNone     = 1
+    tiffCompressionLZW      = 5
+    tiffCompressionPackBits = 32773
+)
+
+// tiffMaxPages limits the number of pages read from a single file
+const tiffMaxPages = 65536
+
+// WriteTIFF renders all elements (using the SymbolEncoder set in Encoder) and writes them as a single multipage TIFF, one
+// page per element.
+func (elem *QrElements) WriteTIFF(w io.Writer) error {
+    images := make([]image.Image, elem.Len())
+    for i, v := range elem.Elements {
+        img, err := elem.render(&v)
+        if err != nil {
+            return err
+        }
+        images[i] = img
+    }
+    return EncodeTIFF(w, images)
+}
+
+// WriteTIFFFile writes all elements to the multipage TIFF file fname, see WriteTIFF
+func (elem *QrElements) WriteTIFFFile(fname string) error {
+    file, err := os.Create(fname)
+    if err != nil {
+        return err
+    }
+    err = elem.WriteTIFF(file)
+    if err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+// EncodeTIFF writes a set of images as bilevel pages of a multipage TIFF. Pixels darker than 50% gray become black.
+func EncodeTIFF(w io.Writer, images []image.Image) error {
+    // each page consists of the IFD, the resolution values it refers to & the image data
+    const entryCount = 13
+    const ifdSize = 2 + entryCount*12 + 4
+    const resolutionSize = 16
+    le := binary.LittleEndian
+    header := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+    _, err := w.Write(header)
+    if err != nil {
+        return err
+    }
+    offset := uint32(len(header))
+    for page, img := range images {
+        bounds := img.Bounds()
+        data := packBitsRows(img)
+        dataOffset := offset + ifdSize + resolutionSize
+        next := dataOffset + uint32(len(data))
+        next += next % 2 // IFDs start on a word boundary
+        if page == len(images)-1 {
+            next = 0
+        }
+        ifd := make([]byte, 0, ifdSize+resolutionSize)
+        ifd = le.AppendUint16(ifd, entryCount)
+        entry := func(tag uint16, typ uint16, count uint32, value uint32) {
+            ifd = le.AppendUint16(ifd, tag)
+            ifd = le.AppendUint16(ifd, typ)
+            ifd = le.AppendUint32(ifd, count)
+            if typ == 3 && count == 1 {
+                // short values are left-justified in the value field
+                ifd = le.AppendUint16(ifd, uint16(value))
+                ifd = le.AppendUint16(ifd, 0)
+            } else {
+                ifd = le.AppendUint32(ifd, value)
+            }
+        }
+        entry(tiffImageWidth, 4, 1, uint32(bounds.Dx()))
+        entry(tiffImageLength, 4, 1, uint32(bounds.Dy()))
+        entry(tiffBitsPerSample, 3, 1, 1)
+        entry(tiffCompression, 3, 1, tiffCompressionPackBits)
+        entry(tiffPhotometric, 3, 1, 0) // WhiteIsZero: a set bit is a black pixel
+        entry(tiffStripOffsets, 4, 1, dataOffset)
+        entry(tiffSamplesPerPixel, 3, 1, 1)
+        entry(tiffRowsPerStrip, 4, 1, uint32(bounds.Dy()))
+        entry(tiffStripByteCounts, 4, 1, uint32(len(data)))
+        entry(tiffXResolution, 5, 1, offset+ifdSize)
+        entry(tiffYResolution, 5, 1, offset+ifdSize+8)
+        entry(tiffResolutionUnit, 3, 1, 2)
+        entry(tiffPageNumber, 3, 2, uint32(page)|uint32(len(images))<<16)
+        ifd = le.AppendUint32(ifd, next)
+        for i := 0; i < 2; i++ {
+            ifd = le.AppendUint32(ifd, 72)
+            ifd = le.AppendUint32(ifd, 1)
+        }
+        if len(data)%2 == 1 && next != 0 {
+            data = append(data, 0)
+        }
+        _, err = w.Write(ifd)
+        if err != nil {
+            return err
+        }
+        _, err = w.Write(data)
+        if err != nil {
+            return err
+        }
+        offset = dataOffset + uint32(len(data))
+    }
+    return nil
+}
+
+// packBitsRows converts an image to bilevel rows (1 = black) and compresses each row using PackBits
+func packBitsRows(img image.Image) []byte {
+    bounds := img.Bounds()
+    row := make([]byte, (bounds.Dx()+7)/8)
+    out := make([]byte, 0)
+    for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+        for i := range row {
+            row[i] = 0
+        }
+        for x := bounds.Min.X; x < bounds.Max.X; x++ {
+            if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+                i := x - bounds.Min.X
+                row[i/8] |= 0x80 >> uint(i%8)
+            }
+        }
+        out = packBits(out, row)
+    }
+    return out
+}
+
+// packBits appends the PackBits compressed form of src to dst
+func packBits(dst []byte, src []byte) []byte {
+    for i := 0; i < len(src); {
+        // length of the run starting at i
+        run := 1
+        for i+run < len(src) && run < 128 && src[i+run] == src[i] {
+            run++
+        }
+        if run > 1 {
+            dst = append(dst, byte(1-run), src[i])
+            i += run
+            continue
+        }
+        // literal sequence up to the next run of at least 2 equal bytes
+        start := i
+        for i < len(src) && i-start < 128 && (i+1 >= len(src) || src[i] != src[i+1]) {
+            i++
+        }
+        dst = append(dst, byte(i-start-1))
+        dst = append(dst, src[start:i]...)
+    }
+    return dst
+}
+
+// unpackBits decompresses PackBits data
+func unpackBits(src []byte) ([]byte, error) {
+    out := make([]byte, 0, len(src)*2)
+    for i := 0; i < len(src); {
+        n := int(int8(src[i]))
+        i++
+        switch {
+        case n >= 0:
+            if i+n+1 > len(src) {
+                return nil, errors.New("Truncated PackBits data")
+            }
+            out = append(out, src[i:i+n+1]...)
+            i += n + 1
+        case n != -128:
+            if i >= len(src) {
+                return nil, errors.New("Truncated PackBits data")
+            }
+            for j := 0; j < 1-n; j++ {
+                out = append(out, src[i])
+            }
+            i++
+        }
+    }
+    return out, nil
+}
+
+// unLZW decompresses TIFF flavoured LZW data (MSB first, code width increased one code early)
+func unLZW(src []byte) ([]byte, error) {
+    const clearCode = 256
+    const eoiCode = 257
+    table := make([][]byte, 258, 4096)
+    for i := 0; i < 256; i++ {
+        table[i] = []byte{byte(i)}
+    }
+    out := make([]byte, 0, len(src)*3)
+    width := uint(9)
+    var bits uint64
+    var bitCount uint
+    pos := 0
+    var prev []byte
+    for {
+        for bitCount < width && pos < len(src) {
+            bits = bits<<8 | uint64(src[pos])
+            bitCount += 8
+            pos++
+        }
+        if bitCount < width {
+            break
+        }
+        code := int(bits>>(bitCount-width)) & (1<<width - 1)
+        bitCount -= width
+        bits &= 1<<bitCount - 1
+        if code == eoiCode {
+            break
+        }
+        if code == clearCode {
+            table = table[:258]
+            width = 9
+            prev = nil
+            continue
+        }
+        var entry []byte
+        switch {
+        case code < len(table):
+            entry = table[code]
+        case code == len(table) && prev != nil:
+            entry = append(append(make([]byte, 0, len(prev)+1), prev...), prev[0])
+        default:
+            return nil, errors.New(fmt.Sprintf("Invalid LZW code %d", code))
+        }
+        out = append(out, entry...)
+        if prev != nil && len(table) < 4096 {
+            table = append(table, append(append(make([]byte, 0, len(prev)+1), prev...), entry[0]))
+        }
+        prev = entry
+        if len(table)+1 >= 1<<width && width < 12 {
+            width++
+        }
+    }
+    return out, nil
+}
+
+// DecodeTIFF reads all pages of a (multipage) TIFF
+func DecodeTIFF(r io.Reader) ([]image.Image, error) {
+    data, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    order, err := tiffByteOrder(data)
+    if err != nil {
+        return nil, err
+    }
+    images := make([]image.Image, 0)
+    visited := make(map[uint32]bool)
+    for offset := order.Uint32(data[4:8]); offset != 0; {
+        if visited[offset] || len(images) >= tiffMaxPages {
+            return nil, errors.New("Invalid IFD chain in TIFF file")
+        }
+        visited[offset] = true
+        tags, next, err := readIFD(data, order, offset)
+        if err != nil {
+            return nil, err
+        }
+        img, err := decodeTIFFPage(data, tags)
+        if err != nil {
+            return nil, fmt.Errorf("Page %d: %w", len(images)+1, err)
+        }
+        images = append(images, img)
+        offset = next
+    }
+    return images, nil
+}
+
+// tiffByteOrder checks the TIFF header & returns the byte order used in the file
+func tiffByteOrder(data []byte) (binary.ByteOrder, error) {
+    if len(data) < 8 {
+        return nil, errors.New("Not a TIFF file")
+    }
+    var order binary.ByteOrder
+    switch string(data[0:2]) {
+    case "II":
+        order = binary.LittleEndian
+    case "MM":
+        order = binary.BigEndian
+    default:
+        return nil, errors.New("Not a TIFF file")
+    }
+    if order.Uint16(data[2:4]) != 42 {
+        return nil, errors.New("Not a TIFF file")
+    }
+    return order, nil
+}
+
+// readIFD reads the integer valued tags of an image file directory; returns the tags & the offset of the next IFD
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) (map[uint16][]uint32, uint32, error) {
+    if uint64(offset)+2 > uint64(len(data)) {
+        return nil, 0, errors.New("IFD offset out of range")
+    }
+    count := uint64(order.Uint16(data[offset:]))
+    end := uint64(offset) + 2 + count*12
+    if end+4 > uint64(len(data)) {
+        return nil, 0, errors.New("IFD exceeds file size")
+    }
+    sizes := map[uint16]uint64{1: 1, 3: 2, 4: 4}
+    tags := make(map[uint16][]uint32)
+    for i := uint64(0); i < count; i++ {
+        entry := data[uint64(offset)+2+i*12:]
+        tag := order.Uint16(entry[0:2])
+        typ := order.Uint16(entry[2:4])
+        n := uint64(order.Uint32(entry[4:8]))
+        size, ok := sizes[typ]
+        if !ok {
+            continue // not needed for decoding
+        }
+        values := entry[8:12]
+        if n*size > 4 {
+            start := uint64(order.Uint32(entry[8:12]))
+            if start+n*size > uint64(len(data)) {
+                return nil, 0, errors.New(fmt.Sprintf("Values of tag %d out of range", tag))
+            }
+            values = data[start : start+n*size]
+        }
+        for j := uint64(0); j < n; j++ {
+            switch size {
+            case 1:
+                tags[tag] = append(tags[tag], uint32(values[j]))
+            case 2:
+                tags[tag] = append(tags[tag], uint32(order.Uint16(values[j*2:])))
+            case 4:
+                tags[tag] = append(tags[tag], order.Uint32(values[j*4:]))
+            }
+        }
+    }
+    return tags, order.Uint32(data[end:]), nil
+}
+
+// decodeTIFFPage decodes the image described by the tags of a single IFD & applies the orientation stored with it
+func decodeTIFFPage(data []byte, tags map[uint16][]uint32) (image.Image, error) {
+    img, err := decodeTIFFPixels(data, tags)
+    if err != nil {
+        return nil, err
+    }
+    if v, ok := tags[tiffOrientation]; ok && len(v) > 0 {
+        img = applyOrientation(img, int(v[0]))
+    }
+    return img, nil
+}
+
+// decodeTIFFPixels decodes the image data described by the tags of a single IFD
+func decodeTIFFPixels(data []byte, tags map[uint16][]uint32) (image.Image, error) {
+    value := func(tag uint16, def uint32) uint32 {
+        if v, ok := tags[tag]; ok && len(v) > 0 {
+            return v[0]
+        }
+        return def
+    }
+    width := int(value(tiffImageWidth, 0))
+    height := int(value(tiffImageLength, 0))
+    if width <= 0 || height <= 0 || width > 1<<16 || height > 1<<16 {
+        return nil, errors.New(fmt.Sprintf("Invalid image size %dx%d", width, height))
+    }
+    bps := value(tiffBitsPerSample, 1)
+    spp := int(value(tiffSamplesPerPixel, 1))
+    photometric := value(tiffPhotometric, 0)
+    if value(tiffPlanarConfig, 1) != 1 {
+        return nil, errors.New("Planar TIFF images are not supported")
+    }
+    offsets := tags[tiffStripOffsets]
+    counts := tags[tiffStripByteCounts]
+    if len(offsets) == 0 || len(offsets) != len(counts) {
+        return nil, errors.New("Missing strip information")
+    }
+    raw := make([]byte, 0)
+    for i := range offsets {
+        if uint64(offsets[i])+uint64(counts[i]) > uint64(len(data)) {
+            return nil, errors.New("Strip out of range")
+        }
+        strip := data[offsets[i] : offsets[i]+counts[i]]
+        var err error
+        switch value(tiffCompression, tiffCompressionNone) {
+        case tiffCompressionNone:
+        case tiffCompressionPackBits:
+            strip, err = unpackBits(strip)
+        case tiffCompressionLZW:
+            strip, err = unLZW(strip)
+        default:
+            return nil, errors.New(fmt.Sprintf("Unsupported TIFF compression %d", value(tiffCompression, 0)))
+        }
+        if err != nil {
+            return nil, err
+        }
+        raw = append(raw, strip...)
+    }
+    switch {
+    case bps == 1 && spp == 1 && photometric <= 1:
+        stride := (width + 7) / 8
+        if len(raw) < stride*height {
+            return nil, errors.New("Image data too short")
+        }
+        img := image.NewGray(image.Rect(0, 0, width, height))
+        for y := 0; y < height; y++ {
+            for x := 0; x < width; x++ {
+                set := raw[y*stride+x/8]&(0x80>>uint(x%8)) != 0
+                if set == (photometric == 1) {
+                    img.Pix[y*img.Stride+x] = 255
+                }
+            }
+        }
+        return img, nil
+    case bps == 8 && (spp == 1 && photometric <= 1 || spp >= 3 && photometric == 2):
+        stride := width * spp
+        if len(raw) < stride*height {
+            return nil, errors.New("Image data too short")
+        }
+        if value(tiffPredictor, 1) == 2 {
+            for y := 0; y < height; y++ {
+                row := raw[y*stride : (y+1)*stride]
+                for i := spp; i < stride; i++ {
+                    row[i] += row[i-spp]
+                }
+            }
+        }
+        if spp == 1 {
+            img := image.NewGray(image.Rect(0, 0, width, height))
+            for y := 0; y < height; y++ {
+                copy(img.Pix[y*img.Stride:], raw[y*stride:(y+1)*stride])
+                if photometric == 0 {
+                    for x := 0; x < width; x++ {
+                        img.Pix[y*img.Stride+x] = 255 - img.Pix[y*img.Stride+x]
+                    }
+                }
+            }
+            return img, nil
+        }
+        img := image.NewRGBA(image.Rect(0, 0, width, height))
+        for y := 0; y < height; y++ {
+            for x := 0; x < width; x++ {
+                p := raw[y*stride+x*spp:]
+                img.SetRGBA(x, y, color.RGBA{p[0], p[1], p[2], 255})
+            }
+        }
+        return img, nil
+    }
+    return nil, errors.New(fmt.Sprintf("Unsupported TIFF image format (%d bits, %d samples, photometric %d)", bps, spp, photometric))
+}
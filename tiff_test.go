@@ -0,0 +1,61 @@
+package qrFile
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// TestTIFFRoundTrip writes a set to a multipage TIFF, one page per element, & reads the codes & the data back
+func TestTIFFRoundTrip(t *testing.T) {
+    data := make([]byte, 1500)
+    for i := range data {
+        data[i] = byte(i * 7)
+    }
+    qrf := New()
+    qrf.Data = data
+    elements, err := GetElementsWithOptions(qrf.ToHexString(), EncodeOptions{Version: VersionPlain, ChunkSize: 600})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if elements.Len() < 2 {
+        t.Fatalf("%d elements, the test needs several pages", elements.Len())
+    }
+    fname := filepath.Join(t.TempDir(), "set.tiff")
+    if err := elements.WriteTIFFFile(fname); err != nil {
+        t.Fatal(err)
+    }
+    file, err := os.Open(fname)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pages, err := DecodeTIFF(file)
+    file.Close()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(pages) != elements.Len() {
+        t.Fatalf("%d pages, expected %d", len(pages), elements.Len())
+    }
+    for i, page := range pages {
+        texts, err := NativeDecoder{}.DecodeImage(page)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(texts) != 1 || texts[0] != elements.Elements[i].AsString() {
+            t.Fatalf("page %d reads as %q", i, texts)
+        }
+    }
+    read := &QrElements{Decoder: NativeDecoder{}}
+    if err := read.FromPNGs([]string{fname}); err != nil {
+        t.Fatal(err)
+    }
+    restored := New()
+    if err := read.StoreData(restored); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(restored.Data, data) {
+        t.Fatalf("restored %d bytes differing from the %d bytes of the data", len(restored.Data), len(data))
+    }
+}
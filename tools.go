@@ -0,0 +1,39 @@
+package qrFile
+
+import (
+    "os/exec"
+    "path/filepath"
+)
+
+// External tools (zbarimg, ZXingReader, qrencode, zint, heif-convert, ffmpeg & gpg) are looked up in ToolDirectory, the
+// usual installation directories of the platform (e.g. the one of the ZBar installer on Windows) & $PATH, in this order,
+// so they are found where their installers put them without changing $PATH. Each tool can also be given by its location
+// (ZbarimgPath, ZXingReaderPath, QrencodeEncoder.Path, ...); a path, unlike a bare name, is used as is.
+
+// ToolDirectory is a directory searched for external tools before the installation directories & $PATH; set it before
+// the first image is read, as the decoders check for their tool only once
+var ToolDirectory string
+
+// toolPath returns the location of the tool given by its name or location (see ToolDirectory); the name itself if it is
+// in none of the directories, so it is looked up in $PATH
+func toolPath(command string) string {
+    if filepath.Base(command) != command {
+        return command
+    }
+    dirs := toolDirectories()
+    if len(ToolDirectory) > 0 {
+        dirs = append([]string{ToolDirectory}, dirs...)
+    }
+    for _, dir := range dirs {
+        // LookPath adds the extensions of executables, e.g. .exe on Windows
+        if path, err := exec.LookPath(filepath.Join(dir, command)); err == nil {
+            return path
+        }
+    }
+    return command
+}
+
+// lookTool returns the location of the tool like exec.LookPath, searching the directories of toolPath first
+func lookTool(command string) (string, error) {
+    return exec.LookPath(toolPath(command))
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package qrFile
+
+// toolDirectories returns the installation directories of the external tools outside of $PATH; the package managers of
+// other platforms install them in $PATH
+func toolDirectories() []string {
+    return nil
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package qrFile
+
+import (
+    "os"
+    "path/filepath"
+)
+
+// toolDirectories returns the directories the installers of the external tools use on Windows, which they do not
+// always add to %PATH%
+func toolDirectories() []string {
+    dirs := make([]string, 0)
+    for _, variable := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+        root := os.Getenv(variable)
+        if len(root) == 0 {
+            continue
+        }
+        for _, dir := range []string{`ZBar\bin`, `zxing-cpp\bin`, `libheif\bin`, `ffmpeg\bin`, `GnuPG\bin`, "zint"} {
+            dirs = append(dirs, filepath.Join(root, dir))
+        }
+    }
+    return dirs
+}
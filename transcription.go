@@ -0,0 +1,264 @@
+package qrFile
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/base32"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "image"
+    "image/color"
+    "image/draw"
+    "io"
+    "strings"
+)
+
+// A transcription is a human & OCR readable copy of a single element, printed below its code (see
+// QrElements.Transcribe). If the code is damaged, the element can be restored from the transcription using
+// ParseTranscription, either from OCR output or typed in manually.
+//
+// The element (format version, index, max index, set ID & the payload as binary) is followed by a CRC32 and encoded in base32.
+// The result is split into lines of transcriptionLineLength characters, printed in groups of transcriptionGroupSize.
+// Each line ends with two check characters covering the line & its position, so typos can be located. The lines are
+// preceded by a header line like "QRF 3/17" which is ignored when parsing.
+
+// transcriptionLineLength is the amount of base32 characters in a single line of a transcription (without check characters)
+const transcriptionLineLength = 40
+
+// transcriptionGroupSize is the amount of characters printed without separating space
+const transcriptionGroupSize = 8
+
+// transcriptionHeader starts the header line of a transcription
+const transcriptionHeader = "QRF "
+
+// transcriptionEncoding is base32 without padding; its alphabet does not contain the easily confused 0, 1 and 8
+var transcriptionEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Transcription returns the lines of the transcription of the element, starting with the header line
+func (elem *QrElement) Transcription() ([]string, error) {
+    payload := elem.Payload
+    version := elem.Version
+    if version == 0 {
+        version = VersionLegacy
+    }
+    data := make([]byte, 1, 1+3*binary.MaxVarintLen64+len(elem.SetID)+len(payload)+4)
+    data[0] = byte(version)
+    var buf [binary.MaxVarintLen64]byte
+    data = append(data, buf[:binary.PutUvarint(buf[:], elem.Index)]...)
+    data = append(data, buf[:binary.PutUvarint(buf[:], elem.MaxIndex)]...)
+    data = append(data, buf[:binary.PutUvarint(buf[:], uint64(len(elem.SetID)))]...)
+    data = append(data, elem.SetID...)
+    data = append(data, payload...)
+    var checksum [4]byte
+    binary.BigEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(data))
+    data = append(data, checksum[:]...)
+
+    text := transcriptionEncoding.EncodeToString(data)
+    lines := []string{fmt.Sprintf("%s%d/%d", transcriptionHeader, elem.Index+1, elem.MaxIndex+1)}
+    for i := 0; i*transcriptionLineLength < len(text); i++ {
+        end := (i + 1) * transcriptionLineLength
+        if end > len(text) {
+            end = len(text)
+        }
+        chars := text[i*transcriptionLineLength : end]
+        groups := make([]string, 0, transcriptionLineLength/transcriptionGroupSize+1)
+        for j := 0; j < len(chars); j += transcriptionGroupSize {
+            if j+transcriptionGroupSize > len(chars) {
+                groups = append(groups, chars[j:])
+            } else {
+                groups = append(groups, chars[j:j+transcriptionGroupSize])
+            }
+        }
+        groups = append(groups, transcriptionCheck(i, chars))
+        lines = append(lines, strings.Join(groups, " "))
+    }
+    return lines, nil
+}
+
+// transcriptionCheck computes the two check characters of a line of a transcription
+func transcriptionCheck(line int, chars string) string {
+    check := crc32.ChecksumIEEE(append([]byte{byte(line)}, chars...)) & 0x3FF
+    alphabet := "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+    return string([]byte{alphabet[check>>5], alphabet[check&0x1F]})
+}
+
+// normalizeTranscription removes whitespace from a line & fixes common OCR confusions
+func normalizeTranscription(line string) string {
+    return strings.Map(func(r rune) rune {
+        switch r {
+        case ' ', '\t', '\r', '\n':
+            return -1
+        case '0':
+            return 'O'
+        case '1':
+            return 'I'
+        case '8':
+            return 'B'
+        }
+        if r >= 'a' && r <= 'z' {
+            return r - 'a' + 'A'
+        }
+        return r
+    }, line)
+}
+
+// ParseTranscription restores an element from its transcription (see Transcription). Lines starting with the header,
+// empty lines, whitespace & lower case letters are accepted; so are 0, 1 and 8 misread instead of O, I and B.
+func ParseTranscription(text string) (elem QrElement, err error) {
+    var encoded bytes.Buffer
+    line := 0
+    for _, raw := range strings.Split(text, "\n") {
+        if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(raw)), transcriptionHeader) {
+            continue
+        }
+        chars := normalizeTranscription(raw)
+        if len(chars) == 0 {
+            continue
+        }
+        if len(chars) < 3 {
+            return elem, errors.New(fmt.Sprintf("Transcription line %d is too short", line+1))
+        }
+        data, check := chars[:len(chars)-2], chars[len(chars)-2:]
+        if transcriptionCheck(line, data) != check {
+            return elem, checksumError("Checksum mismatch in transcription line %d (%s)", line+1, strings.TrimSpace(raw))
+        }
+        encoded.WriteString(data)
+        line++
+    }
+    data, err := transcriptionEncoding.DecodeString(encoded.String())
+    if err != nil {
+        return elem, fmt.Errorf("Malformed transcription: %w", err)
+    }
+    if len(data) < 7 {
+        return elem, errors.New("Transcription is too short")
+    }
+    content, checksum := data[:len(data)-4], data[len(data)-4:]
+    if crc32.ChecksumIEEE(content) != binary.BigEndian.Uint32(checksum) {
+        return elem, checksumError("Checksum mismatch in transcription")
+    }
+    version := int(content[0])
+    reader := bytes.NewReader(content[1:])
+    index, err := binary.ReadUvarint(reader)
+    if err != nil {
+        return elem, errors.New("Malformed transcription header")
+    }
+    maxIndex, err := binary.ReadUvarint(reader)
+    if err != nil || index > maxIndex {
+        return elem, errors.New("Malformed transcription header")
+    }
+    setIDLength, err := binary.ReadUvarint(reader)
+    if err != nil || setIDLength > uint64(reader.Len()) {
+        return elem, errors.New("Malformed transcription header")
+    }
+    setID := make([]byte, setIDLength)
+    reader.Read(setID)
+    payload := content[len(content)-reader.Len():]
+    switch version {
+    case VersionLegacy:
+        return legacyElement(index, maxIndex, payload)
+    case VersionPlain:
+        return QrElement{Version: VersionPlain, SetID: string(setID), Index: index, MaxIndex: maxIndex, PayloadLength: 2 * uint64(len(payload)), Payload: payload}, nil
+    case VersionCompact:
+        return compactElement(index, maxIndex, payload)
+    }
+    return elem, errors.New(fmt.Sprintf("Unknown format version %d in transcription", version))
+}
+
+// ImportTranscriptions reads the transcriptions of a set of elements (e.g. the OCR output of the printed pages) & stores
+// the restored elements. Transcriptions are separated by their header lines or empty lines. The same sanity tests as
+// in FromPNGs are applied.
+func (elem *QrElements) ImportTranscriptions(r io.Reader) error {
+    blocks := make([]string, 0)
+    var current []string
+    flush := func() {
+        if len(current) > 0 {
+            blocks = append(blocks, strings.Join(current, "\n"))
+            current = nil
+        }
+    }
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if len(line) == 0 || strings.HasPrefix(strings.ToUpper(line), transcriptionHeader) {
+            flush()
+            continue
+        }
+        current = append(current, line)
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+    flush()
+    for i, block := range blocks {
+        newElement, err := ParseTranscription(block)
+        if err != nil {
+            return fmt.Errorf("Unable to parse transcription %d: %w", i+1, err)
+        }
+        elem.Elements = append(elem.Elements, newElement)
+        elem.observer().OnChunkDecoded(newElement, "")
+        metrics().ChunksDecoded(1)
+    }
+    return elem.Validate()
+}
+
+// addTranscription returns a copy of the image of the element's code with its transcription printed below
+func (elem *QrElement) addTranscription(img image.Image) (image.Image, error) {
+    lines, err := elem.Transcription()
+    if err != nil {
+        return nil, err
+    }
+    width := 0
+    for _, line := range lines {
+        if w := textWidth(line, 1); w > width {
+            width = w
+        }
+    }
+    bounds := img.Bounds()
+    // scale the text to the width of the code, leaving a margin of two font pixels on each side
+    scale := bounds.Dx() / (width + 4)
+    if scale < 1 {
+        scale = 1
+    }
+    margin := 2 * scale
+    outWidth := bounds.Dx()
+    if w := width*scale + 2*margin; w > outWidth {
+        outWidth = w
+    }
+    outHeight := bounds.Dy() + len(lines)*glyphAdvanceY*scale + margin
+    out := image.NewRGBA(image.Rect(0, 0, outWidth, outHeight))
+    draw.Draw(out, out.Bounds(), image.White, image.ZP, draw.Src)
+    draw.Draw(out, image.Rect((outWidth-bounds.Dx())/2, 0, outWidth, bounds.Dy()), img, bounds.Min, draw.Src)
+    for i, line := range lines {
+        drawText(out, margin, bounds.Dy()+i*glyphAdvanceY*scale, line, scale, color.Black)
+    }
+    return out, nil
+}
+
+// digestLength is the number of hex characters of the element hash printed by addDigest; the same as in image names
+const digestLength = imageNameHashLength
+
+// Digest returns the label printed above the code of the element if QrElements.Digest is set: its number & count and
+// the start of its hash, e.g. "3/20 1a2b3c4d"
+func (elem *QrElement) Digest() string {
+    return fmt.Sprintf("%d/%d %s", elem.Index+1, elem.MaxIndex+1, elem.Hash()[:digestLength])
+}
+
+// addDigest returns a copy of the image of the element's code with its digest printed above, in a margin of its own
+// so the quiet zone of the code stays empty
+func (elem *QrElement) addDigest(img image.Image) image.Image {
+    label := elem.Digest()
+    bounds := img.Bounds()
+    // the label takes up to half of the width of the code
+    scale := bounds.Dx() / (2 * textWidth(label, 1))
+    if scale < 1 {
+        scale = 1
+    }
+    margin := 2 * scale
+    out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()+glyphHeight*scale+2*margin))
+    draw.Draw(out, out.Bounds(), image.White, image.ZP, draw.Src)
+    drawText(out, (bounds.Dx()-textWidth(label, scale))/2, margin, label, scale, color.Black)
+    draw.Draw(out, image.Rect(0, glyphHeight*scale+2*margin, bounds.Dx(), out.Bounds().Dy()), img, bounds.Min, draw.Src)
+    return out
+}
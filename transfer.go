@@ -0,0 +1,221 @@
+package qrFile
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// An automated transfer needs no network between sender & receiver: the sender shows the codes of a set in a loop, with
+// sync frames announcing the set (see StreamOptions), while the receiver captures them with a camera. In return, the
+// receiver shows an acknowledgement: a code listing the elements still missing ("QRF ACK" followed by the set ID, the
+// number of elements & the numbers of the missing ones as ranges, e.g. "QRF ACK 5d41402a 40 3-5,9"). A sender with a
+// camera of its own reads it & from then on only shows these elements (see TransferQueue), until the receiver
+// acknowledges the complete set ("QRF ACK 5d41402a 40 -"). Acks are control frames like sync frames, so they are skipped
+// by a receiver capturing its own screen.
+
+const ackPrefix = "QRF ACK "
+
+// maxAckLength limits the text of an ack, so its code stays small & is read quickly from a screen; further missing
+// elements are left out & acknowledged once the ones listed were received
+const maxAckLength = 240
+
+// Ack is the acknowledgement of a receiver, see AckText
+type Ack struct {
+    SetID   string   // set acknowledged, empty for sets without ID
+    Total   uint64   // number of elements of the set
+    Missing []uint64 // indices (from 0) of the elements still missing; possibly only the first ones (see maxAckLength)
+}
+
+// Complete reports whether the receiver acknowledged the complete set
+func (a *Ack) Complete() bool {
+    return len(a.Missing) == 0
+}
+
+// AckText returns the text of the code acknowledging the elements received; the missing elements are listed by number
+// (from 1, as in the header of the plain format) & consecutive numbers are joined to ranges
+func AckText(ack *Ack) string {
+    setID := ack.SetID
+    if len(setID) == 0 {
+        setID = "-"
+    }
+    text := fmt.Sprintf("%s%s %d ", ackPrefix, setID, ack.Total)
+    if ack.Complete() {
+        return text + "-"
+    }
+    ranges := make([]string, 0)
+    length := len(text)
+    for i := 0; i < len(ack.Missing); {
+        first, last := ack.Missing[i], ack.Missing[i]
+        for i++; i < len(ack.Missing) && ack.Missing[i] == last+1; i++ {
+            last++
+        }
+        item := strconv.FormatUint(first+1, 10)
+        if last > first {
+            item += "-" + strconv.FormatUint(last+1, 10)
+        }
+        if len(ranges) > 0 && length+len(item)+1 > maxAckLength {
+            break
+        }
+        ranges = append(ranges, item)
+        length += len(item) + 1
+    }
+    return text + strings.Join(ranges, ",")
+}
+
+// ParseAck reads the text of an acknowledgement (see AckText)
+func ParseAck(text string) (*Ack, error) {
+    text = strings.TrimSpace(text)
+    if !strings.HasPrefix(text, ackPrefix) {
+        return nil, errors.New("Not an acknowledgement")
+    }
+    fields := strings.Fields(strings.TrimPrefix(text, ackPrefix))
+    if len(fields) != 3 {
+        return nil, errors.New(fmt.Sprintf("Malformed acknowledgement %q", text))
+    }
+    ack := &Ack{Missing: make([]uint64, 0)}
+    if fields[0] != "-" {
+        ack.SetID = fields[0]
+    }
+    total, err := strconv.ParseUint(fields[1], 10, 64)
+    if err != nil || total == 0 || total > maxElementCount {
+        return nil, errors.New(fmt.Sprintf("Invalid number of elements %q in acknowledgement", fields[1]))
+    }
+    ack.Total = total
+    if fields[2] == "-" {
+        return ack, nil
+    }
+    for _, item := range strings.Split(fields[2], ",") {
+        bounds := strings.SplitN(item, "-", 2)
+        first, err := strconv.ParseUint(bounds[0], 10, 64)
+        last := first
+        if err == nil && len(bounds) == 2 {
+            last, err = strconv.ParseUint(bounds[1], 10, 64)
+        }
+        if err != nil || first < 1 || last < first || last > total {
+            return nil, errors.New(fmt.Sprintf("Invalid range %q in acknowledgement", item))
+        }
+        if uint64(len(ack.Missing))+last-first >= maxMissingListed {
+            return nil, errors.New(fmt.Sprintf("Too many missing elements in acknowledgement (more than %d)", maxMissingListed))
+        }
+        for number := first; number <= last; number++ {
+            ack.Missing = append(ack.Missing, number-1)
+        }
+    }
+    return ack, nil
+}
+
+// Ack returns the acknowledgement of the elements collected so far, or nil if the size of the set is not known yet
+func (a *Assembler) Ack() *Ack {
+    total := a.Total()
+    if total == 0 {
+        return nil
+    }
+    ack := &Ack{SetID: a.setID, Total: total, Missing: make([]uint64, 0)}
+    if len(a.elements) == 0 && a.sync != nil {
+        ack.SetID = a.sync.SetID
+    }
+    ack.Missing = a.Missing()
+    return ack
+}
+
+// TransferSync is the position TransferQueue.Next returns for a sync frame
+const TransferSync = -1
+
+// TransferQueue selects the codes a sender shows during an automated transfer: all elements in a loop, interleaved &
+// with sync frames as selected in the StreamOptions, & after an acknowledgement only the elements still missing
+type TransferQueue struct {
+    elements *QrElements
+    options  StreamOptions
+    frames   []int // positions of the elements of the loop; TransferSync for a sync frame
+    next     int
+    done     bool
+}
+
+// NewTransferQueue creates a TransferQueue showing all elements; of the options, Interleave, Repeat, RepeatSpread &
+// SyncInterval apply
+func NewTransferQueue(elem *QrElements, options StreamOptions) *TransferQueue {
+    q := &TransferQueue{elements: elem, options: options}
+    positions := make([]int, elem.Len())
+    for i := range positions {
+        positions[i] = i
+    }
+    q.loop(positions)
+    return q
+}
+
+// loop replaces the loop by the elements at the given positions
+func (q *TransferQueue) loop(positions []int) {
+    options := q.options.resolve(len(positions))
+    q.frames = make([]int, 0, len(positions)*options.Repeat)
+    for k, i := range countFrameOrder(len(positions), options) {
+        if options.SyncInterval > 0 && k%options.SyncInterval == 0 {
+            q.frames = append(q.frames, TransferSync)
+        }
+        q.frames = append(q.frames, positions[i])
+    }
+    q.next = 0
+}
+
+// Next returns the position of the element to show next, or TransferSync for a sync frame (see SyncText); after the
+// last frame of the loop, the loop starts over
+func (q *TransferQueue) Next() int {
+    if len(q.frames) == 0 {
+        return TransferSync
+    }
+    frame := q.frames[q.next]
+    q.next = (q.next + 1) % len(q.frames)
+    return frame
+}
+
+// Pending returns the number of elements shown in the loop
+func (q *TransferQueue) Pending() int {
+    if q.done {
+        return 0
+    }
+    count := 0
+    seen := make(map[int]bool)
+    for _, frame := range q.frames {
+        if frame != TransferSync && !seen[frame] {
+            seen[frame] = true
+            count++
+        }
+    }
+    return count
+}
+
+// Done reports whether the receiver acknowledged the complete set
+func (q *TransferQueue) Done() bool {
+    return q.done
+}
+
+// Acknowledge restricts the loop to the elements missing according to an acknowledgement of the receiver. An error is
+// returned if the acknowledgement belongs to another set or lists elements the set does not have.
+func (q *TransferQueue) Acknowledge(ack *Ack) error {
+    if q.elements.Len() == 0 {
+        return errors.New("No elements to transfer")
+    }
+    manifest := q.elements.Manifest()
+    if ack.Total != manifest.Count || (len(ack.SetID) > 0 && len(manifest.SetID) > 0 && ack.SetID != manifest.SetID) {
+        return errors.New(fmt.Sprintf("The acknowledgement belongs to another set (%d elements, set %q)", ack.Total, ack.SetID))
+    }
+    if ack.Complete() {
+        q.done = true
+        return nil
+    }
+    positions := make(map[uint64]int)
+    for i, v := range q.elements.Elements {
+        positions[v.Index] = i
+    }
+    missing := make([]int, 0, len(ack.Missing))
+    for _, index := range ack.Missing {
+        position, ok := positions[index]
+        if !ok {
+            return errors.New(fmt.Sprintf("The acknowledgement lists element %d, which is not part of the set", index+1))
+        }
+        missing = append(missing, position)
+    }
+    q.loop(missing)
+    return nil
+}
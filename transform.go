@@ -0,0 +1,514 @@
+package qrFile
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "github.com/klauspost/compress/zstd"
+    "golang.org/x/crypto/argon2"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Transforms change the data of a set before it is split into elements & restore it after the set was read, e.g. to
+// compress or encrypt it, without changes to the chunker. They are applied in order when encoding & reversed in the
+// opposite order when decoding. Each transform returns the parameters needed to reverse it; the names & parameters are
+// recorded in the manifest (see TransformInfo), so the set describes how its data is restored. Besides the built-in
+// transforms (gzip, zstd, encrypt, recipients & pgp), applications register their own ones, e.g. for a custom container format, with
+// RegisterTransform.
+
+// Transform changes the data of a set in a reversible way
+type Transform interface {
+    // Name identifies the transform in the manifest; it has to be unique among the registered transforms
+    Name() string
+    // Apply returns the transformed data & the parameters needed to reverse the transformation (nil if there are none)
+    Apply(data []byte) ([]byte, map[string]string, error)
+    // Reverse restores the data given the parameters returned by Apply
+    Reverse(data []byte, params map[string]string) ([]byte, error)
+}
+
+// TransformInfo records a transform applied to the data of a set
+type TransformInfo struct {
+    Name   string            `json:"name"`
+    Params map[string]string `json:"params,omitempty"`
+}
+
+// registered transforms, see RegisterTransform
+var transforms = make(map[string]Transform)
+var transformsMutex sync.Mutex
+
+func init() {
+    RegisterTransform(GzipTransform{})
+    RegisterTransform(ZstdTransform{})
+    RegisterTransform(EncryptTransform{})
+    RegisterTransform(PGPTransform{})
+    RegisterTransform(RecipientTransform{})
+}
+
+// RegisterTransform makes a transform available under its name, so data transformed by it can be restored from the
+// manifest. A transform registered under the name of a built-in one replaces it.
+func RegisterTransform(transform Transform) {
+    transformsMutex.Lock()
+    defer transformsMutex.Unlock()
+    transforms[transform.Name()] = transform
+}
+
+// GetTransform returns the transform registered under the given name
+func GetTransform(name string) (Transform, error) {
+    transformsMutex.Lock()
+    defer transformsMutex.Unlock()
+    transform, ok := transforms[name]
+    if !ok {
+        return nil, errors.New(fmt.Sprintf("Unknown transform %s", name))
+    }
+    return transform, nil
+}
+
+// TransformNames returns the names of all registered transforms in alphabetical order
+func TransformNames() []string {
+    transformsMutex.Lock()
+    defer transformsMutex.Unlock()
+    names := make([]string, 0, len(transforms))
+    for name := range transforms {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// ApplyTransforms applies the transforms to data in order & returns the result along with the description to record in
+// the manifest (see QrElements.Transforms)
+func ApplyTransforms(data []byte, transforms []Transform) ([]byte, []TransformInfo, error) {
+    applied := make([]TransformInfo, 0, len(transforms))
+    for _, transform := range transforms {
+        var params map[string]string
+        var err error
+        data, params, err = transform.Apply(data)
+        if err != nil {
+            return nil, nil, fmt.Errorf("Transform %s failed: %w", transform.Name(), err)
+        }
+        applied = append(applied, TransformInfo{Name: transform.Name(), Params: params})
+    }
+    return data, applied, nil
+}
+
+// ReverseTransforms restores data transformed as described by applied, in the opposite order. The transforms given
+// take precedence over the registered ones of the same name, e.g. an EncryptTransform holding the passphrase or a
+// RecipientTransform holding the identities.
+func ReverseTransforms(data []byte, applied []TransformInfo, given []Transform) ([]byte, error) {
+    return reverseTransforms(data, applied, given, 0)
+}
+
+// sizeLimited is implemented by the transforms limiting the size of the data they restore (see GzipTransform.MaxSize)
+type sizeLimited interface {
+    // withMaxSize returns the transform limited to max (see sizeLimit) unless it sets a limit of its own
+    withMaxSize(max int64) Transform
+}
+
+// reverseTransforms works like ReverseTransforms, limiting the data restored by transforms without a limit of their own
+// to max (see sizeLimited)
+func reverseTransforms(data []byte, applied []TransformInfo, given []Transform, max int64) ([]byte, error) {
+    for i := len(applied) - 1; i >= 0; i-- {
+        transform, err := findTransform(applied[i].Name, given)
+        if err != nil {
+            return nil, err
+        }
+        if limited, ok := transform.(sizeLimited); ok {
+            transform = limited.withMaxSize(max)
+        }
+        data, err = transform.Reverse(data, applied[i].Params)
+        if errors.Is(err, ErrPassphraseRequired) || errors.Is(err, ErrKeyRequired) || errors.Is(err, ErrIdentityRequired) {
+            // callers check for these to ask for the secret
+            return nil, err
+        }
+        if err != nil {
+            return nil, fmt.Errorf("Reversing transform %s failed: %w", applied[i].Name, err)
+        }
+    }
+    return data, nil
+}
+
+// findTransform returns the transform called name among given or, if there is none, the registered one
+func findTransform(name string, given []Transform) (Transform, error) {
+    for _, transform := range given {
+        if transform.Name() == name {
+            return transform, nil
+        }
+    }
+    return GetTransform(name)
+}
+
+// setTransforms records the transforms applied to the data in the set &, by name, in the header of the first element
+// (plain format only), so the data is restored without the manifest (see AppliedTransforms)
+func (elem *QrElements) setTransforms(applied []TransformInfo) {
+    elem.Transforms = applied
+    names := make([]string, len(applied))
+    for i, info := range applied {
+        names[i] = info.Name
+    }
+    for i := range elem.Elements {
+        if v := &elem.Elements[i]; v.Index == 0 && v.Version == VersionPlain {
+            v.Fields.Set(FieldTypeTransforms, []byte(strings.Join(names, ",")))
+        }
+    }
+}
+
+// transformsReserve returns the amount of characters the names of the transforms take in the header of the first
+// element: the space before the token of the fields, its prefix & the field hex encoded
+func transformsReserve(applied []TransformInfo) uint64 {
+    if len(applied) == 0 {
+        return 0
+    }
+    length := len(applied) - 1
+    for _, info := range applied {
+        length += len(info.Name)
+    }
+    return 1 + uint64(len(fieldsPrefix)) + 2*uint64(2+length)
+}
+
+// AppliedTransforms returns the transforms applied to the data of the set: Transforms if set, otherwise the ones recorded
+// in the parameter code read (see Parameters) or else the ones named in the header of the first element, without
+// parameters (the registered transforms reverse them without, see
+// EncryptTransform); nil if there are none
+func (elem *QrElements) AppliedTransforms() []TransformInfo {
+    if len(elem.Transforms) > 0 {
+        return elem.Transforms
+    }
+    if elem.Parameters != nil && len(elem.Parameters.Transforms) > 0 {
+        return elem.Parameters.Transforms
+    }
+    for _, v := range elem.Elements {
+        if value, ok := v.Fields.Get(FieldTypeTransforms); ok && v.Index == 0 && len(value) > 0 {
+            names := strings.Split(string(value), ",")
+            applied := make([]TransformInfo, len(names))
+            for i, name := range names {
+                applied[i] = TransformInfo{Name: name}
+            }
+            return applied
+        }
+    }
+    return nil
+}
+
+// RestoreData stores the data of a complete set in fileObject like StoreRawData & reverses the transforms applied to it
+// (see AppliedTransforms), using the given transforms where they are needed (see ReverseTransforms), e.g. an
+// EncryptTransform with the passphrase. Data with holes (see Salvage) is left as it is.
+// The size of the data restored is limited by MaxFileSize, unless the transforms given set a limit of their own.
+func (elem *QrElements) RestoreData(fileObject *QrFile, given []Transform) error {
+    err := elem.StoreRawData(fileObject)
+    if err != nil {
+        return err
+    }
+    applied := elem.AppliedTransforms()
+    if len(applied) == 0 || (elem.Damage != nil && !elem.Damage.Complete()) {
+        return nil
+    }
+    data, err := reverseTransforms(fileObject.Data, applied, given, elem.MaxFileSize)
+    if err != nil {
+        return err
+    }
+    fileObject.Data = data
+    return nil
+}
+
+// GzipTransform compresses the data with gzip; most useful for text, since the hex encoding of the elements doubles
+// the size of the data anyway
+type GzipTransform struct {
+    Level   int   // compression level (see compress/gzip); gzip.DefaultCompression if 0
+    MaxSize int64 // limit of the decompressed data in bytes; DefaultMaxFileSize if 0, unlimited if negative
+}
+
+// Name returns "gzip"
+func (t GzipTransform) Name() string {
+    return "gzip"
+}
+
+// Apply compresses data
+func (t GzipTransform) Apply(data []byte) ([]byte, map[string]string, error) {
+    level := t.Level
+    if level == 0 {
+        level = gzip.DefaultCompression
+    }
+    var compressed bytes.Buffer
+    writer, err := gzip.NewWriterLevel(&compressed, level)
+    if err != nil {
+        return nil, nil, err
+    }
+    writer.Write(data)
+    err = writer.Close()
+    if err != nil {
+        return nil, nil, err
+    }
+    return compressed.Bytes(), nil, nil
+}
+
+// Reverse decompresses data, limited to MaxSize
+func (t GzipTransform) Reverse(data []byte, params map[string]string) ([]byte, error) {
+    reader, err := gzip.NewReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    return decompressLimited(reader, t.MaxSize)
+}
+
+// withMaxSize implements sizeLimited
+func (t GzipTransform) withMaxSize(max int64) Transform {
+    if t.MaxSize == 0 {
+        t.MaxSize = max
+    }
+    return t
+}
+
+// decompressLimited reads the decompressed data from r, failing with a SizeError after more than max bytes (see
+// sizeLimit)
+func decompressLimited(r io.Reader, max int64) ([]byte, error) {
+    limit := sizeLimit(max)
+    result, err := readLimited(r, limit)
+    if err == errTooLarge {
+        return nil, sizeError(0, uint64(limit), "Decompressed data exceeds the maximum size of %d bytes", limit)
+    }
+    return result, err
+}
+
+// ZstdTransform compresses the data with zstd, which is faster than gzip & usually compresses better
+type ZstdTransform struct {
+    MaxSize int64 // limit of the decompressed data in bytes; DefaultMaxFileSize if 0, unlimited if negative
+}
+
+// Name returns "zstd"
+func (t ZstdTransform) Name() string {
+    return "zstd"
+}
+
+// Apply compresses data
+func (t ZstdTransform) Apply(data []byte) ([]byte, map[string]string, error) {
+    encoder, err := zstd.NewWriter(nil)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer encoder.Close()
+    return encoder.EncodeAll(data, nil), nil, nil
+}
+
+// Reverse decompresses data, limited to MaxSize
+func (t ZstdTransform) Reverse(data []byte, params map[string]string) ([]byte, error) {
+    decoder, err := zstd.NewReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    defer decoder.Close()
+    return decompressLimited(decoder, t.MaxSize)
+}
+
+// withMaxSize implements sizeLimited
+func (t ZstdTransform) withMaxSize(max int64) Transform {
+    if t.MaxSize == 0 {
+        t.MaxSize = max
+    }
+    return t
+}
+
+// encryptAdditionalData authenticates the purpose of the ciphertext, so it can not be mixed up with a paper key
+const encryptAdditionalData = "QRF DATA"
+
+// Key derivations of EncryptTransform, recorded as parameter "kdf"
+const (
+    encryptKDFArgon2id = "argon2id"
+    encryptKDFKey      = "key"
+)
+
+// argon2id parameters of EncryptTransform (RFC 9106, second recommended option); they are recorded in the manifest,
+// so they can be raised without breaking older sets
+const (
+    encryptArgon2Time    = 3
+    encryptArgon2Memory  = 64 * 1024 // KiB
+    encryptArgon2Threads = 4
+)
+
+// limits of the argon2id parameters accepted when decrypting: a small multiple of the defaults, so the parameters of an
+// untrusted manifest or parameter code do not exhaust the memory or stall the decoder
+const (
+    maxArgon2Time    = 8
+    maxArgon2Memory  = 256 * 1024 // KiB
+    maxArgon2Threads = 16
+)
+
+// EncryptTransform encrypts the data with AES-256-GCM using a passphrase (the key is derived with argon2id) or a raw
+// key of KeySize bytes (see ParseKey). The encrypted data consists of the salt (passphrase only), the nonce & the
+// ciphertext; the manifest records the key derivation with its parameters, the salt & the nonce as well, so the set
+// describes how it is decrypted. The registered instance holds no secret, so reversing it returns
+// ErrPassphraseRequired or ErrKeyRequired; pass an EncryptTransform with the secret to ReverseTransforms instead.
+type EncryptTransform struct {
+    Passphrase string
+    Key        []byte // used instead of Passphrase if set
+}
+
+// Name returns "encrypt"
+func (t EncryptTransform) Name() string {
+    return "encrypt"
+}
+
+// Apply encrypts data
+func (t EncryptTransform) Apply(data []byte) ([]byte, map[string]string, error) {
+    if t.Key == nil && len(t.Passphrase) == 0 {
+        return nil, nil, errors.New("Encryption requires a passphrase or a key")
+    }
+    var salt []byte
+    params := map[string]string{"kdf": encryptKDFKey}
+    if t.Key == nil {
+        salt = make([]byte, paperKeySalt)
+        _, err := io.ReadFull(rand.Reader, salt)
+        if err != nil {
+            return nil, nil, err
+        }
+        params = map[string]string{
+            "kdf":     encryptKDFArgon2id,
+            "time":    strconv.Itoa(encryptArgon2Time),
+            "memory":  strconv.Itoa(encryptArgon2Memory),
+            "threads": strconv.Itoa(encryptArgon2Threads),
+            "salt":    hex.EncodeToString(salt),
+        }
+    }
+    aead, err := t.cipher(salt, params)
+    if err != nil {
+        return nil, nil, err
+    }
+    nonce := make([]byte, aead.NonceSize())
+    _, err = io.ReadFull(rand.Reader, nonce)
+    if err != nil {
+        return nil, nil, err
+    }
+    params["nonce"] = hex.EncodeToString(nonce)
+    prefix := append(salt, nonce...)
+    return aead.Seal(prefix, nonce, data, []byte(encryptAdditionalData)), params, nil
+}
+
+// Reverse decrypts data
+func (t EncryptTransform) Reverse(data []byte, params map[string]string) ([]byte, error) {
+    kdf := params["kdf"]
+    if len(kdf) == 0 {
+        // no manifest: the secret given tells how the data was encrypted
+        kdf = encryptKDFArgon2id
+        if t.Key != nil {
+            kdf = encryptKDFKey
+        }
+    }
+    var salt []byte
+    secret := "key"
+    switch kdf {
+    case encryptKDFKey:
+        if t.Key == nil {
+            return nil, ErrKeyRequired
+        }
+    case encryptKDFArgon2id:
+        if len(t.Passphrase) == 0 {
+            return nil, ErrPassphraseRequired
+        }
+        if len(data) < paperKeySalt {
+            return nil, errors.New("Encrypted data too short")
+        }
+        salt, data, secret = data[:paperKeySalt], data[paperKeySalt:], "passphrase"
+        // the passphrase is used even if a key is given as well
+        t.Key = nil
+    default:
+        return nil, errors.New(fmt.Sprintf("Unknown key derivation %s", kdf))
+    }
+    aead, err := t.cipher(salt, params)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < aead.NonceSize() {
+        return nil, errors.New("Encrypted data too short")
+    }
+    if err := checkEncryptParam(params, "salt", salt); err != nil {
+        return nil, err
+    }
+    if err := checkEncryptParam(params, "nonce", data[:aead.NonceSize()]); err != nil {
+        return nil, err
+    }
+    result, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(encryptAdditionalData))
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("Unable to decrypt the data (wrong %s?)", secret))
+    }
+    return result, nil
+}
+
+// cipher returns the AES-256-GCM cipher for the raw key or, if no key is set, for the passphrase & salt, using the key
+// derivation given by params
+func (t EncryptTransform) cipher(salt []byte, params map[string]string) (cipher.AEAD, error) {
+    if t.Key != nil {
+        return rawKeyCipher(t.Key)
+    }
+    // without manifest, the current parameters are assumed
+    costs := [3]uint64{encryptArgon2Time, encryptArgon2Memory, encryptArgon2Threads}
+    for i, name := range []string{"time", "memory", "threads"} {
+        if _, ok := params[name]; !ok {
+            continue
+        }
+        value, err := strconv.ParseUint(params[name], 10, 32)
+        if err != nil || value == 0 {
+            return nil, errors.New(fmt.Sprintf("Invalid argon2id parameter %s %q", name, params[name]))
+        }
+        costs[i] = value
+    }
+    if costs[0] > maxArgon2Time || costs[1] > maxArgon2Memory || costs[2] > maxArgon2Threads {
+        return nil, errors.New(fmt.Sprintf("argon2id parameters out of range (time %d, memory %d KiB, threads %d)", costs[0], costs[1], costs[2]))
+    }
+    key := argon2.IDKey([]byte(t.Passphrase), salt, uint32(costs[0]), uint32(costs[1]), uint8(costs[2]), paperKeyKeySize)
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// checkEncryptParam compares a value in front of the encrypted data with the one recorded in the manifest, if any, so a
+// manifest of another set is noticed
+func checkEncryptParam(params map[string]string, name string, value []byte) error {
+    recorded, ok := params[name]
+    if ok && recorded != hex.EncodeToString(value) {
+        return errors.New(fmt.Sprintf("The %s of the encrypted data does not match the manifest", name))
+    }
+    return nil
+}
+
+// PGPTransform encrypts (and signs) the data for OpenPGP recipients using gpg (see ProtectPGP). Reversing it decrypts
+// the data with the keyring of the user, so the registered instance is all that is needed.
+type PGPTransform struct {
+    Recipients []string
+    Signer     string
+}
+
+// Name returns "pgp"
+func (t PGPTransform) Name() string {
+    return "pgp"
+}
+
+// Apply encrypts & signs data with gpg
+func (t PGPTransform) Apply(data []byte) ([]byte, map[string]string, error) {
+    result, info, err := ProtectPGP(data, t.Recipients, t.Signer)
+    if err != nil {
+        return nil, nil, err
+    }
+    params := make(map[string]string)
+    if len(info.Recipients) > 0 {
+        params["recipients"] = strings.Join(info.Recipients, ",")
+    }
+    if len(info.Signer) > 0 {
+        params["signer"] = info.Signer
+    }
+    return result, params, nil
+}
+
+// Reverse decrypts data with gpg & verifies its signature, if any
+func (t PGPTransform) Reverse(data []byte, params map[string]string) ([]byte, error) {
+    return UnprotectPGP(data)
+}
@@ -0,0 +1,116 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// TestStoreDataDecompresses restores compressed sets through the documented path FromPNGs, StoreData & ToFile: the
+// compression named in the header of the first code is reversed without the manifest
+func TestStoreDataDecompresses(t *testing.T) {
+    data := bytes.Repeat([]byte("compressible text "), 100)
+    for _, transform := range []Transform{GzipTransform{}, ZstdTransform{}} {
+        t.Run(transform.Name(), func(t *testing.T) {
+            stored, applied, err := ApplyTransforms(data, []Transform{transform})
+            if err != nil {
+                t.Fatal(err)
+            }
+            elements, err := GetElementsWithOptions(hex.EncodeToString(stored), EncodeOptions{Version: VersionPlain, Transforms: applied})
+            if err != nil {
+                t.Fatal(err)
+            }
+            dir := t.TempDir()
+            if err := elements.WritePNGs(dir, "img_"); err != nil {
+                t.Fatal(err)
+            }
+            images, err := filepath.Glob(filepath.Join(dir, "img_*.png"))
+            if err != nil {
+                t.Fatal(err)
+            }
+            read := MakeQrElements(0)
+            if err := read.FromPNGs(images); err != nil {
+                t.Fatal(err)
+            }
+            restored := New()
+            if err := read.StoreData(restored); err != nil {
+                t.Fatal(err)
+            }
+            restored.Fname = filepath.Join(dir, "restored")
+            if err := restored.ToFile(); err != nil {
+                t.Fatal(err)
+            }
+            written, err := os.ReadFile(restored.Fname)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !bytes.Equal(written, data) {
+                t.Fatalf("restored %d bytes, expected the %d bytes of the original data", len(written), len(data))
+            }
+        })
+    }
+}
+
+// TestEncryptParameterLimits refuses argon2id parameters beyond the limits, as found in a crafted manifest, before any
+// key is derived
+func TestEncryptParameterLimits(t *testing.T) {
+    transform := EncryptTransform{Passphrase: "secret"}
+    encrypted, params, err := transform.Apply([]byte("data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, value := range map[string]string{"time": "9", "memory": "262145", "threads": "17"} {
+        t.Run(name, func(t *testing.T) {
+            crafted := make(map[string]string)
+            for k, v := range params {
+                crafted[k] = v
+            }
+            crafted[name] = value
+            if _, err := transform.Reverse(encrypted, crafted); err == nil || !strings.Contains(err.Error(), "out of range") {
+                t.Fatalf("%s %s: %v", name, value, err)
+            }
+        })
+    }
+    decrypted, err := transform.Reverse(encrypted, params)
+    if err != nil || string(decrypted) != "data" {
+        t.Fatalf("default parameters: %q, %v", decrypted, err)
+    }
+}
+
+// TestDecompressionLimit refuses decompressed data beyond the MaxFileSize of the set, unless the transform given sets a
+// limit of its own
+func TestDecompressionLimit(t *testing.T) {
+    data := bytes.Repeat([]byte("compressible text "), 100)
+    for _, transform := range []Transform{GzipTransform{}, ZstdTransform{}} {
+        t.Run(transform.Name(), func(t *testing.T) {
+            stored, applied, err := ApplyTransforms(data, []Transform{transform})
+            if err != nil {
+                t.Fatal(err)
+            }
+            elements, err := GetElementsWithOptions(hex.EncodeToString(stored), EncodeOptions{Version: VersionPlain, Transforms: applied, MaxFileSize: 1000})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := elements.StoreData(New()); !errors.Is(err, ErrPayloadTooLarge) {
+                t.Fatalf("%d bytes restored with a limit of 1000: %v", len(data), err)
+            }
+            elements.MaxFileSize = -1
+            restored := New()
+            if err := elements.StoreData(restored); err != nil {
+                t.Fatal(err)
+            }
+            if !bytes.Equal(restored.Data, data) {
+                t.Fatalf("restored %d bytes, expected %d", len(restored.Data), len(data))
+            }
+            elements.MaxFileSize = 0
+            limited := []Transform{GzipTransform{MaxSize: 100}, ZstdTransform{MaxSize: 100}}
+            if err := elements.RestoreData(New(), limited); !errors.Is(err, ErrPayloadTooLarge) {
+                t.Fatalf("%d bytes restored with a transform limited to 100: %v", len(data), err)
+            }
+        })
+    }
+}
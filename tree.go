@@ -0,0 +1,510 @@
+package qrFile
+
+import (
+    "archive/tar"
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// A directory is encoded as a tar archive (archive mode), so its files can be listed in the manifest & restored singly
+// (see Manifest.Select). By default only names, contents, permissions & modification times are stored; ArchiveOptions
+// add the metadata needed to restore system configuration faithfully. The same options select which of the recorded
+// metadata is applied when the archive is unpacked again, so a backup made as root can be restored by another user.
+//
+// Entries other than regular files are handled as follows: directories are stored (empty ones as well) & restored.
+// Symlinks are stored with their target if ArchiveOptions.Symlinks is set; otherwise a symlink to a regular file is
+// replaced by that file, while symlinks to directories (which might form loops) & dangling symlinks are excluded.
+// Named pipes, sockets & device files have no content worth storing on paper & are excluded. Hard links are stored
+// as separate copies. Excluded entries are listed in the ArchiveReport with the reason.
+
+// xattrPAXPrefix prefixes extended attributes stored in the PAX records of a tar header
+const xattrPAXPrefix = "SCHILY.xattr."
+
+// ArchiveOptions selects the metadata recorded by FromDirectory (or FromPaths) & applied by ToDirectory
+type ArchiveOptions struct {
+    Ownership bool // owner & group of each entry (numeric IDs & names); applying them usually requires root
+    Xattrs    bool // extended attributes of files & directories (Linux only)
+    Symlinks  bool // store symlinks as such with their target; otherwise the file they point to is stored
+    // ExcludeHidden skips hidden entries (dotfiles & dot-directories) below the archived directory, unless an include
+    // pattern matches the entry itself (not just a directory above it); by default they are stored, since config
+    // directories consist of them
+    ExcludeHidden bool
+    // Include limits the files stored to the ones matching these patterns (see pattern.go), if any; directories are
+    // only stored if they match or hold a file stored. Exclude skips entries matching these patterns. Both are only
+    // used by FromDirectory & FromPaths.
+    Include []string
+    Exclude []string
+    // Overwrite lets ToDirectory replace existing files; by default, unpacking fails before anything is written if an
+    // entry exists already (see QrFile.Overwrite)
+    Overwrite bool
+    // MaxSize limits the size of the archive created by FromDirectory & FromPaths (in bytes); DefaultMaxFileSize if 0,
+    // unlimited if negative
+    MaxSize int64
+}
+
+// ArchiveReport describes the entries stored by FromDirectory (or FromPaths) & the ones excluded
+type ArchiveReport struct {
+    Files       int // regular files stored, including files stored in place of a symlink
+    Directories int
+    Symlinks    int // symlinks stored with their target (see ArchiveOptions.Symlinks)
+    NotIncluded int // files not matching ArchiveOptions.Include
+    Excluded    []ExcludedEntry
+}
+
+// ExcludedEntry describes an entry of a directory which was not stored
+type ExcludedEntry struct {
+    Path   string
+    Reason string // e.g. "named pipe" or "symlink to a directory"
+}
+
+// String summarizes the report, listing each excluded entry on a line of its own
+func (r *ArchiveReport) String() string {
+    summary := fmt.Sprintf("%d files, %d directories, %d symlinks stored, %d entries excluded", r.Files, r.Directories, r.Symlinks, len(r.Excluded))
+    if r.NotIncluded > 0 {
+        summary += fmt.Sprintf(", %d files not included", r.NotIncluded)
+    }
+    lines := []string{summary}
+    for _, entry := range r.Excluded {
+        lines = append(lines, fmt.Sprintf("excluded %s: %s", entry.Path, entry.Reason))
+    }
+    return strings.Join(lines, "\n")
+}
+
+// exclude records an entry which is not stored
+func (r *ArchiveReport) exclude(fname string, reason string) {
+    r.Excluded = append(r.Excluded, ExcludedEntry{Path: fname, Reason: reason})
+}
+
+// FromDirectory creates a QrFile instance holding a tar archive of the directory dir; the names inside the archive are
+// relative to dir. Returns a report of the entries stored & excluded (see above). The size of the archive is limited by
+// options.MaxSize.
+func FromDirectory(dir string, options ArchiveOptions) (*QrFile, *ArchiveReport, error) {
+    qrf, report, err := archiveTrees([]archiveRoot{{path: dir}}, options)
+    if err != nil {
+        return nil, report, err
+    }
+    qrf.Fname = dir
+    return qrf, report, nil
+}
+
+// FromPaths creates a QrFile instance holding a tar archive of several files & directories (a multi-file archive, e.g.
+// a few documents & a key directory backed up as one set): each path is stored under its base name, directories with
+// all entries below them, so ToDirectory restores them side by side. The paths themselves are always stored; the
+// patterns of options apply to the entries below directories, matched against their names in the archive (e.g.
+// "docs/*.txt" for the directory docs). Paths with the same base name are an error. Returns a report of the entries
+// stored & excluded; the size of the archive is limited by options.MaxSize.
+func FromPaths(paths []string, options ArchiveOptions) (*QrFile, *ArchiveReport, error) {
+    if len(paths) == 0 {
+        return nil, new(ArchiveReport), errors.New("No files to archive")
+    }
+    roots := make([]archiveRoot, len(paths))
+    named := make(map[string]string)
+    for i, fname := range paths {
+        name := filepath.Base(filepath.Clean(fname))
+        if name == "." || name == ".." || name == string(filepath.Separator) {
+            return nil, new(ArchiveReport), errors.New(fmt.Sprintf("%s has no name to be stored under; give the directory by its name", fname))
+        }
+        if other, ok := named[name]; ok {
+            return nil, new(ArchiveReport), errors.New(fmt.Sprintf("%s and %s would both be stored as %s", other, fname, name))
+        }
+        named[name] = fname
+        roots[i] = archiveRoot{path: fname, name: name}
+    }
+    return archiveTrees(roots, options)
+}
+
+// archiveRoot is a file or directory stored in an archive by archiveTrees
+type archiveRoot struct {
+    path string
+    name string // name of the root in the archive; if empty, only the entries below the directory are stored
+}
+
+// archiveTrees creates a QrFile instance holding a tar archive of the roots & all entries below them
+func archiveTrees(roots []archiveRoot, options ArchiveOptions) (*QrFile, *ArchiveReport, error) {
+    report := new(ArchiveReport)
+    for _, patterns := range [][]string{options.Include, options.Exclude} {
+        if err := checkPatterns(patterns); err != nil {
+            return nil, report, err
+        }
+    }
+    limit := sizeLimit(options.MaxSize)
+    var data bytes.Buffer
+    archive := tar.NewWriter(&data)
+    // directories not matching the include patterns wait for an entry below them to be stored
+    type pendingDir struct {
+        fname, name string
+        info        os.FileInfo
+    }
+    pending := make([]pendingDir, 0)
+    boundaries := make([]uint64, 0)
+    add := func(fname string, name string, info os.FileInfo) error {
+        // pad the previous entry, so the offset of this one is known
+        err := archive.Flush()
+        if err != nil {
+            return err
+        }
+        boundaries = append(boundaries, uint64(data.Len()))
+        return addArchiveEntry(archive, fname, name, info, options, report)
+    }
+    for _, root := range roots {
+        err := filepath.Walk(root.path, func(fname string, info os.FileInfo, err error) error {
+            if err != nil {
+                return err
+            }
+            rel, err := filepath.Rel(root.path, fname)
+            if err != nil {
+                return err
+            }
+            if rel == "." {
+                if len(root.name) == 0 {
+                    return nil
+                }
+                // roots are stored as given
+                return add(fname, root.name, info)
+            }
+            name := path.Join(root.name, filepath.ToSlash(rel))
+            reason := ""
+            if pattern, excluded := matchPatterns(options.Exclude, name, info.IsDir()); excluded {
+                reason = fmt.Sprintf("matches %q", pattern)
+            } else if options.ExcludeHidden && strings.HasPrefix(info.Name(), ".") && !namedBy(options.Include, name, info.IsDir()) {
+                reason = "hidden"
+            }
+            if len(reason) > 0 {
+                report.exclude(fname, reason)
+                if info.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            ancestors := pending[:0]
+            for _, p := range pending {
+                if strings.HasPrefix(name, p.name+"/") {
+                    ancestors = append(ancestors, p)
+                }
+            }
+            pending = ancestors
+            if _, included := matchPatterns(options.Include, name, info.IsDir()); len(options.Include) > 0 && !included {
+                if info.IsDir() {
+                    pending = append(pending, pendingDir{fname, name, info})
+                } else {
+                    report.NotIncluded++
+                }
+                return nil
+            }
+            for _, p := range pending {
+                err = add(p.fname, p.name, p.info)
+                if err != nil {
+                    return err
+                }
+            }
+            pending = pending[:0]
+            err = add(fname, name, info)
+            if err != nil {
+                return err
+            }
+            if limit > 0 && int64(data.Len()) > limit {
+                return sizeError(uint64(data.Len()), uint64(limit), "%s is too large to be archived (more than %d bytes)", root.path, limit)
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, report, err
+        }
+        pending = pending[:0]
+    }
+    if limit > 0 && int64(data.Len()) > limit {
+        return nil, report, sizeError(uint64(data.Len()), uint64(limit), "The archive is too large (more than %d bytes)", limit)
+    }
+    err := archive.Close()
+    if err != nil {
+        return nil, report, err
+    }
+    return &QrFile{Data: data.Bytes(), Boundaries: boundaries}, report, nil
+}
+
+// addArchiveEntry writes the header (& content) of a single directory entry to the archive, or records in the report
+// why it is excluded
+func addArchiveEntry(archive *tar.Writer, fname string, name string, info os.FileInfo, options ArchiveOptions, report *ArchiveReport) error {
+    link := ""
+    mode := info.Mode()
+    switch {
+    case mode&os.ModeSymlink != 0 && options.Symlinks:
+        target, err := os.Readlink(fname)
+        if err != nil {
+            return err
+        }
+        link = target
+        report.Symlinks++
+    case mode&os.ModeSymlink != 0:
+        // store the file the link points to
+        target, err := os.Stat(fname)
+        if err != nil {
+            report.exclude(fname, "dangling symlink")
+            return nil
+        }
+        if !target.Mode().IsRegular() {
+            report.exclude(fname, "symlink to a "+entryKind(target.Mode()))
+            return nil
+        }
+        info = target
+        report.Files++
+    case mode.IsDir():
+        report.Directories++
+    case mode.IsRegular():
+        report.Files++
+    default:
+        report.exclude(fname, entryKind(mode))
+        return nil
+    }
+    header, err := tar.FileInfoHeader(info, link)
+    if err != nil {
+        return err
+    }
+    header.Name = name
+    if info.IsDir() {
+        header.Name += "/"
+    }
+    if !options.Ownership {
+        header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+    }
+    if options.Xattrs && header.Typeflag != tar.TypeSymlink {
+        attrs, err := readXattrs(fname)
+        if err != nil {
+            return fmt.Errorf("Unable to read the extended attributes of %s: %w", fname, err)
+        }
+        for attr, value := range attrs {
+            if header.PAXRecords == nil {
+                header.PAXRecords = make(map[string]string)
+            }
+            header.PAXRecords[xattrPAXPrefix+attr] = value
+        }
+    }
+    err = archive.WriteHeader(header)
+    if err != nil || header.Typeflag != tar.TypeReg {
+        return err
+    }
+    file, err := os.Open(fname)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    _, err = io.Copy(archive, file)
+    return err
+}
+
+// entryKind names the type of a directory entry, e.g. "named pipe"
+func entryKind(mode os.FileMode) string {
+    switch {
+    case mode.IsDir():
+        return "directory"
+    case mode&os.ModeNamedPipe != 0:
+        return "named pipe"
+    case mode&os.ModeSocket != 0:
+        return "socket"
+    case mode&os.ModeDevice != 0:
+        return "device file"
+    case mode&os.ModeSymlink != 0:
+        return "symlink"
+    case mode.IsRegular():
+        return "regular file"
+    }
+    return "special file"
+}
+
+// ToDirectory unpacks the tar archive held by the QrFile instance (see FromDirectory) into the directory dir, applying
+// the metadata selected by options; symlinks are only restored if options.Symlinks is set. Files are written under a
+// temporary name & renamed once complete (see CreateAtomic). Unless options.Overwrite is set, an error satisfying
+// os.IsExist is returned before anything is written if a file or symlink of the archive exists already. Returns the
+// paths of the entries written.
+func (qrf *QrFile) ToDirectory(dir string, options ArchiveOptions) ([]string, error) {
+    written := make([]string, 0)
+    if !options.Overwrite {
+        err := qrf.checkArchiveTargets(dir, options)
+        if err != nil {
+            return written, err
+        }
+    }
+    archive := tar.NewReader(bytes.NewReader(qrf.Data))
+    // directories get their permissions once their content is written, links are created last so no entry is
+    // written through them
+    dirs := make([]*tar.Header, 0)
+    links := make([]*tar.Header, 0)
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return written, err
+        }
+        fname, err := archivePath(dir, header.Name)
+        if err != nil {
+            return written, err
+        }
+        switch header.Typeflag {
+        case tar.TypeDir:
+            err = os.MkdirAll(fname, 0755)
+            dirs = append(dirs, header)
+        case tar.TypeReg:
+            err = writeArchiveFile(fname, header, archive, options.Overwrite)
+        case tar.TypeSymlink:
+            if options.Symlinks {
+                links = append(links, header)
+            }
+            continue
+        default:
+            logf(slog.LevelWarn, "Skipping %s, unsupported entry type %c", header.Name, header.Typeflag)
+            continue
+        }
+        if err == nil && header.Typeflag == tar.TypeReg {
+            err = applyMetadata(fname, header, options)
+        }
+        if err != nil {
+            return written, err
+        }
+        written = append(written, fname)
+    }
+    // deepest first, so a read only directory does not block its subdirectories
+    sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name > dirs[j].Name })
+    for _, header := range dirs {
+        fname, _ := archivePath(dir, header.Name)
+        err := os.Chmod(fname, os.FileMode(header.Mode).Perm())
+        if err == nil {
+            err = applyMetadata(fname, header, options)
+        }
+        if err == nil {
+            err = os.Chtimes(fname, header.ModTime, header.ModTime)
+        }
+        if err != nil {
+            return written, err
+        }
+    }
+    for _, header := range links {
+        fname, _ := archivePath(dir, header.Name)
+        err := checkNoLinks(dir, fname)
+        if err != nil {
+            return written, err
+        }
+        err = os.MkdirAll(filepath.Dir(fname), 0755)
+        if err == nil && options.Overwrite {
+            // a symlink replaces an existing file or symlink, but never a directory
+            if info, statErr := os.Lstat(fname); statErr == nil && !info.IsDir() {
+                err = os.Remove(fname)
+            }
+        }
+        if err == nil {
+            err = os.Symlink(header.Linkname, fname)
+        }
+        if err == nil && options.Ownership {
+            err = os.Lchown(fname, header.Uid, header.Gid)
+        }
+        if err != nil {
+            return written, err
+        }
+        written = append(written, fname)
+    }
+    return written, nil
+}
+
+// checkArchiveTargets returns an error satisfying os.IsExist if a file or symlink of the tar archive held by the QrFile
+// instance exists in dir already; existing directories are fine
+func (qrf *QrFile) checkArchiveTargets(dir string, options ArchiveOptions) error {
+    archive := tar.NewReader(bytes.NewReader(qrf.Data))
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if header.Typeflag != tar.TypeReg && (header.Typeflag != tar.TypeSymlink || !options.Symlinks) {
+            continue
+        }
+        fname, err := archivePath(dir, header.Name)
+        if err != nil {
+            return err
+        }
+        err = checkNotExists(fname)
+        if err != nil {
+            return err
+        }
+    }
+}
+
+// archivePath returns the path an archive entry is restored to inside dir, refusing entries escaping it
+func archivePath(dir string, name string) (string, error) {
+    entry := path.Clean(name)
+    // on Windows, backslashes & drive letters in the name would escape dir as well
+    local := filepath.FromSlash(entry)
+    if path.IsAbs(entry) || entry == ".." || strings.HasPrefix(entry, "../") || !filepath.IsLocal(local) {
+        return "", errors.New(fmt.Sprintf("Refusing to extract %s outside of %s.", name, dir))
+    }
+    return filepath.Join(dir, local), nil
+}
+
+// checkNoLinks fails if a parent directory of fname below dir is a symlink, so no link is created through another one
+func checkNoLinks(dir string, fname string) error {
+    for parent := filepath.Dir(fname); len(parent) > len(dir); parent = filepath.Dir(parent) {
+        if info, err := os.Lstat(parent); err == nil && info.Mode()&os.ModeSymlink != 0 {
+            return errors.New(fmt.Sprintf("Refusing to create %s below the symlink %s.", fname, parent))
+        }
+    }
+    return nil
+}
+
+// writeArchiveFile writes a regular file of an archive with its permissions & modification time, replacing an existing
+// file only if overwrite is set (see CreateAtomic)
+func writeArchiveFile(fname string, header *tar.Header, r io.Reader, overwrite bool) error {
+    err := os.MkdirAll(filepath.Dir(fname), 0755)
+    if err != nil {
+        return err
+    }
+    out, err := CreateAtomic(fname, overwrite)
+    if err != nil {
+        return err
+    }
+    defer out.Abort()
+    err = out.Chmod(os.FileMode(header.Mode).Perm())
+    if err == nil {
+        _, err = io.Copy(out, r)
+    }
+    if err == nil {
+        err = out.Commit()
+    }
+    if err != nil {
+        return err
+    }
+    return os.Chtimes(fname, header.ModTime, header.ModTime)
+}
+
+// applyMetadata applies the ownership & extended attributes recorded in the header, as far as selected by options
+func applyMetadata(fname string, header *tar.Header, options ArchiveOptions) error {
+    if options.Xattrs {
+        attrs := make(map[string]string)
+        for key, value := range header.PAXRecords {
+            if strings.HasPrefix(key, xattrPAXPrefix) {
+                attrs[strings.TrimPrefix(key, xattrPAXPrefix)] = value
+            }
+        }
+        err := writeXattrs(fname, attrs)
+        if err != nil {
+            return fmt.Errorf("Unable to set the extended attributes of %s: %w", fname, err)
+        }
+    }
+    if options.Ownership {
+        err := os.Lchown(fname, header.Uid, header.Gid)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,55 @@
+package qrFile
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// TestToDirectoryOverwrite unpacks an archive over existing files: refused without Overwrite before anything is
+// written, replacing the files with it
+func TestToDirectoryOverwrite(t *testing.T) {
+    src := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range map[string]string{"a.txt": "archived a", "sub/b.txt": "archived b"} {
+        if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    qrf, _, err := FromDirectory(src, ArchiveOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    dst := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(dst, "sub"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(filepath.Join(dst, "sub", "b.txt"), []byte("existing b"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    written, err := qrf.ToDirectory(dst, ArchiveOptions{})
+    if !os.IsExist(err) {
+        t.Fatalf("unpacked over an existing file: %v", err)
+    }
+    if len(written) != 0 {
+        t.Fatalf("wrote %v before failing", written)
+    }
+    if content, _ := os.ReadFile(filepath.Join(dst, "sub", "b.txt")); string(content) != "existing b" {
+        t.Fatalf("existing file changed to %q", content)
+    }
+    if _, err := os.Lstat(filepath.Join(dst, "a.txt")); !os.IsNotExist(err) {
+        t.Fatalf("a.txt written before failing: %v", err)
+    }
+    if _, err := qrf.ToDirectory(dst, ArchiveOptions{Overwrite: true}); err != nil {
+        t.Fatal(err)
+    }
+    if content, _ := os.ReadFile(filepath.Join(dst, "sub", "b.txt")); string(content) != "archived b" {
+        t.Fatalf("existing file not replaced, holds %q", content)
+    }
+    entries, err := os.ReadDir(filepath.Join(dst, "sub"))
+    if err != nil || len(entries) != 1 {
+        t.Fatalf("temporary files left behind: %v %v", entries, err)
+    }
+}
@@ -0,0 +1,237 @@
+package qrFile
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+)
+
+// A receiver checks the codes captured so far before the transfer ends, to learn exactly which codes to capture again.
+// A Verifier reads the header of every code (its position & set) & validates the whole text against the hash in the
+// manifest of the set or, without manifest, against the other copies of the same element. Codes whose header is
+// readable but whose text is damaged are reported as corrupt, so they are captured again like the missing ones.
+
+// Verification lists the state of every element of a set; indices count from 0, as in the manifest
+type Verification struct {
+    SetID   string   `json:"set,omitempty"`
+    Total   uint64   `json:"total"`   // number of elements of the complete set, 0 if no element was recognized
+    Present []uint64 `json:"present"` // elements received intact
+    Missing []uint64 `json:"missing"` // elements not received at all
+    Corrupt []uint64 `json:"corrupt"` // elements only received damaged
+    Foreign int      `json:"foreign"` // codes of other sets
+    // Unreadable describes the codes & images which held no recognizable element, e.g. "line 3: ..."
+    Unreadable []string `json:"unreadable,omitempty"`
+}
+
+// Complete reports whether all elements were received intact
+func (v *Verification) Complete() bool {
+    return v.Total > 0 && uint64(len(v.Present)) == v.Total
+}
+
+// Verifier collects the codes captured by a receiver & checks them, see Verification
+type Verifier struct {
+    manifest   *Manifest
+    hashes     map[uint64]string // expected hashes (from the manifest) or the hashes of the first copies received
+    present    map[uint64]bool
+    corrupt    map[uint64]bool
+    setID      string
+    total      uint64
+    foreign    int
+    unreadable []string
+}
+
+// NewVerifier creates a Verifier checking codes against manifest; without manifest (nil), the first element received
+// selects the set & copies of the same element are checked against each other
+func NewVerifier(manifest *Manifest) *Verifier {
+    v := &Verifier{manifest: manifest, hashes: make(map[uint64]string), present: make(map[uint64]bool), corrupt: make(map[uint64]bool)}
+    if manifest != nil {
+        v.setID, v.total = manifest.SetID, manifest.Count
+        for _, chunk := range manifest.Chunks {
+            v.hashes[chunk.Index] = chunk.Hash
+        }
+    }
+    return v
+}
+
+// AddText checks the text of a scanned code; source describes where it was found for the list of unreadable codes
+// (e.g. "line 3"). Texts of calibration & sync frames are ignored.
+func (v *Verifier) AddText(source string, text string) {
+    if IsControlText(text) {
+        return
+    }
+    var element QrElement
+    err := element.ParseString(text)
+    if err != nil {
+        header, ok := parseElementHeader(text)
+        if !ok {
+            v.unreadable = append(v.unreadable, fmt.Sprintf("%s: %s", source, err))
+            return
+        }
+        if v.belongs(&header) {
+            v.corrupt[header.Index] = true
+        }
+        return
+    }
+    if !v.belongs(&element) {
+        return
+    }
+    hash := element.Hash()
+    expected, known := v.hashes[element.Index]
+    switch {
+    case !known:
+        // without manifest, the first copy is taken as reference
+        v.hashes[element.Index] = hash
+        v.present[element.Index] = true
+    case hash == expected && (v.manifest != nil || v.present[element.Index]):
+        v.present[element.Index] = true
+    case v.manifest == nil:
+        // two copies disagree & it is unknown which one is intact
+        v.present[element.Index] = false
+        v.corrupt[element.Index] = true
+    default:
+        v.corrupt[element.Index] = true
+    }
+}
+
+// ReadText checks the text of scanned codes, one code per line (see QrElements.ImportText)
+func (v *Verifier) ReadText(r io.Reader) error {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+    for number := 1; scanner.Scan(); number++ {
+        if line, ok := textLine(scanner.Text()); ok {
+            v.AddText(fmt.Sprintf("line %d", number), line)
+        }
+    }
+    return scanner.Err()
+}
+
+// AddFile scans an image file (using decoder, the default decoder if nil) & checks the codes found; name describes the file for the
+// list of unreadable codes
+func (v *Verifier) AddFile(fname string, name string, decoder Decoder) {
+    symbols, err := scanFile(context.Background(), fname, decoder)
+    if err != nil {
+        v.unreadable = append(v.unreadable, fmt.Sprintf("%s: %s", name, err))
+        return
+    }
+    v.addSymbols(name, symbols)
+}
+
+// AddReader checks the codes of an image read from r (see ReadImages), e.g. an upload, like AddFile; name identifies the
+// image in the result
+func (v *Verifier) AddReader(r io.Reader, name string, decoder Decoder) {
+    images, err := ReadImages(r)
+    if err == nil {
+        var symbols []Symbol
+        symbols, err = scanImages(context.Background(), name, images, decoder)
+        if err == nil {
+            v.addSymbols(name, symbols)
+            return
+        }
+    }
+    v.unreadable = append(v.unreadable, fmt.Sprintf("%s: %s", name, err))
+}
+
+// addSymbols checks the codes read from the image name
+func (v *Verifier) addSymbols(name string, symbols []Symbol) {
+    for i, symbol := range symbols {
+        v.AddText(fmt.Sprintf("%s, code %d", name, i+1), symbol.Text)
+    }
+}
+
+// belongs reports whether an element belongs to the set verified, selecting the set if none is selected yet; codes of
+// other sets are counted
+func (v *Verifier) belongs(element *QrElement) bool {
+    if v.total == 0 {
+        v.setID, v.total = element.SetID, element.MaxIndex+1
+        return true
+    }
+    if element.MaxIndex+1 != v.total || (len(v.setID) > 0 && len(element.SetID) > 0 && element.SetID != v.setID) {
+        v.foreign++
+        return false
+    }
+    return true
+}
+
+// Result returns the state of the elements checked so far
+func (v *Verifier) Result() *Verification {
+    result := &Verification{SetID: v.setID, Total: v.total, Present: make([]uint64, 0), Missing: make([]uint64, 0),
+        Corrupt: make([]uint64, 0), Foreign: v.foreign, Unreadable: v.unreadable}
+    // the count is read from the codes, so the lists are built from the elements seen instead of all indices
+    seen := make(map[uint64]bool, len(v.present)+len(v.corrupt))
+    for index, ok := range v.present {
+        if ok {
+            result.Present = append(result.Present, index)
+            seen[index] = true
+        }
+    }
+    for index, ok := range v.corrupt {
+        if ok && !v.present[index] {
+            result.Corrupt = append(result.Corrupt, index)
+            seen[index] = true
+        }
+    }
+    sort.Slice(result.Present, func(i, j int) bool { return result.Present[i] < result.Present[j] })
+    sort.Slice(result.Corrupt, func(i, j int) bool { return result.Corrupt[i] < result.Corrupt[j] })
+    result.Missing = missingIndices(seen, v.total)
+    return result
+}
+
+// parseElementHeader reads only the position & set of an element from its text, for texts which can not be parsed as a
+// whole (see QrElement.ParseString)
+func parseElementHeader(text string) (QrElement, bool) {
+    var header QrElement
+    trimmed := strings.TrimSpace(text)
+    if strings.HasPrefix(trimmed, plainPrefix) {
+        fields := strings.Fields(strings.TrimPrefix(trimmed, plainPrefix))
+        if len(fields) == 0 {
+            return header, false
+        }
+        position := strings.Split(fields[0], "/")
+        if len(position) != 2 {
+            return header, false
+        }
+        number, err := parsePlainNumber(position[0], "index")
+        if err != nil {
+            return header, false
+        }
+        count, err := parsePlainNumber(position[1], "count")
+        if err != nil || number < 1 || number > count || count > maxElementCount {
+            return header, false
+        }
+        header.Version, header.Index, header.MaxIndex = VersionPlain, number-1, count-1
+        if len(fields) > 1 && strings.HasPrefix(fields[1], setIDPrefix) && len(fields[1]) == len(setIDPrefix)+setIDLength {
+            header.SetID = strings.TrimPrefix(fields[1], setIDPrefix)
+        }
+        return header, true
+    }
+    if strings.HasPrefix(trimmed, compactPrefix) {
+        // the header is at the start of the record, so a damaged payload does not matter
+        encoded := strings.TrimPrefix(trimmed, compactPrefix)
+        if length := compactEncoding.EncodedLen(compactHeaderReserve); len(encoded) > length {
+            encoded = encoded[:length]
+        }
+        data, err := compactEncoding.DecodeString(encoded)
+        if err != nil {
+            return header, false
+        }
+        header, _, err = compactHeader(data)
+        return header, err == nil
+    }
+    if len(text) < payloadLengthPos {
+        return header, false
+    }
+    var err error
+    header.Index, err = parseHeaderField(text[indexPos:indexPos+uintStringLength], "index")
+    if err != nil {
+        return header, false
+    }
+    header.MaxIndex, err = parseHeaderField(text[maxIndexPos:maxIndexPos+uintStringLength], "count")
+    if err != nil || header.Index > header.MaxIndex {
+        return header, false
+    }
+    header.Version = VersionLegacy
+    return header, true
+}
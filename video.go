@@ -0,0 +1,209 @@
+package qrFile
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Video input: screen recordings of a stream (see WriteGIF) or of codes shown one after another are read with ffmpeg
+// (https://ffmpeg.org), which decodes the frames & pipes them as png images. Each frame is decoded as it arrives, so
+// long recordings are read without holding their frames in memory. Frames without a code (e.g. the transition between
+// two codes) are normal in a recording & thus not counted as failures; the codes shown over many frames are only kept
+// once. Reading stops as soon as all elements of the set were seen.
+
+// FFmpegPath is the location of the ffmpeg tool used to read the frames of video files (see ToolDirectory)
+var FFmpegPath = "ffmpeg"
+
+// VideoFrameRate is the number of frames per second read from a video file; 0 reads every frame. Lower rates read long
+// recordings faster, but have to stay above twice the rate at which the codes change, so no code is missed.
+var VideoFrameRate float64
+
+// ffmpegProbe remembers the result of probeFFmpeg
+var ffmpegProbe struct {
+    once sync.Once
+    err  error
+}
+
+// probeFFmpeg checks once that ffmpeg is installed, so a missing tool is reported with a hint how to fix it
+func probeFFmpeg() error {
+    ffmpegProbe.once.Do(func() {
+        if _, err := lookTool(FFmpegPath); err != nil {
+            ffmpegProbe.err = errors.New(fmt.Sprintf("%s not found in $PATH. Install ffmpeg (e.g. apt install ffmpeg) to read video files.", FFmpegPath))
+        }
+    })
+    return ffmpegProbe.err
+}
+
+// FromVideo reads the codes shown in a video file (e.g. a screen recording of a stream, see WriteGIF) & stores them in
+// the set, with the same sanity tests as FromPNGs. The frames are extracted by ffmpeg (see FFmpegPath), at the rate set
+// by VideoFrameRate. Codes repeated over several frames are only stored once.
+func (elem *QrElements) FromVideo(fname string) error {
+    return elem.FromVideoContext(context.Background(), fname)
+}
+
+// FromVideoContext works like FromVideo, but stops ffmpeg & returns ctx.Err() once ctx is canceled
+func (elem *QrElements) FromVideoContext(ctx context.Context, fname string) error {
+    if inputFormat(fname) != "video" {
+        return errors.New(fmt.Sprintf("%s is not a video file", fname))
+    }
+    return elem.FromPNGsContext(ctx, []string{fname})
+}
+
+// scanVideo returns the distinct codes shown in a video file, in the order they were first read. Frames without a code
+// are skipped, as are frames equal to the one before. Once the codes read hold all elements of a set, ffmpeg is stopped.
+// Decoding stops with ctx.Err() once ctx is canceled.
+func scanVideo(ctx context.Context, fname string, decoder Decoder) ([]Symbol, error) {
+    err := probeFFmpeg()
+    if err != nil {
+        return nil, err
+    }
+    decoder = decoderOrDefault(decoder)
+    videoCtx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    args := []string{"-nostdin", "-loglevel", "error", "-i", fname}
+    if VideoFrameRate > 0 {
+        args = append(args, "-vf", "fps="+strconv.FormatFloat(VideoFrameRate, 'f', -1, 64))
+    }
+    args = append(args, "-f", "image2pipe", "-vcodec", "png", "-")
+    var stderr bytes.Buffer
+    cmd := exec.CommandContext(videoCtx, toolPath(FFmpegPath), args...)
+    cmd.Stderr = &stderr
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return nil, err
+    }
+    err = cmd.Start()
+    if err != nil {
+        return nil, err
+    }
+
+    frames := bufio.NewReader(stdout)
+    progress := newVideoProgress()
+    result := make([]Symbol, 0)
+    var last image.Image
+    var frameErr error
+    for frame := 1; ; frame++ {
+        img, err := png.Decode(frames)
+        // a frame cut short means ffmpeg failed, which is reported by Wait
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+            break
+        }
+        if err != nil {
+            frameErr = fmt.Errorf("%s, frame %d: %w", fname, frame, err)
+            break
+        }
+        if sameFrame(last, img) {
+            continue
+        }
+        last = img
+        symbols, err := decodeSymbols(ctx, decoder, img)
+        if err != nil && err == ctx.Err() {
+            frameErr = err
+            break
+        }
+        if timeout, ok := err.(*TimeoutError); ok {
+            timeout.File = fmt.Sprintf("%s, frame %d", fname, frame)
+            frameErr = timeout
+            break
+        }
+        for _, symbol := range symbols {
+            if progress.add(symbol.Text) {
+                trace("Read a new code in frame %d of %s", frame, fname)
+                result = append(result, symbol)
+            }
+        }
+        if progress.complete() {
+            trace("All elements of %s read by frame %d", fname, frame)
+            break
+        }
+    }
+    // stop ffmpeg if reading ended before the end of the video
+    cancel()
+    io.Copy(ioutil.Discard, frames)
+    waitErr := cmd.Wait()
+    if frameErr != nil {
+        return nil, frameErr
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if waitErr != nil && !progress.complete() {
+        return nil, errors.New(fmt.Sprintf("ffmpeg failed for %s: %s %s", fname, waitErr, bytes.TrimSpace(stderr.Bytes())))
+    }
+    if len(result) == 0 {
+        return nil, errors.New(fmt.Sprintf("%s: no code found in any frame", fname))
+    }
+    return result, nil
+}
+
+// sameFrame reports whether two frames show the same picture, as a recording of a still screen does
+func sameFrame(a image.Image, b image.Image) bool {
+    if a == nil || a.Bounds() != b.Bounds() {
+        return false
+    }
+    switch a := a.(type) {
+    case *image.RGBA:
+        b, ok := b.(*image.RGBA)
+        return ok && bytes.Equal(a.Pix, b.Pix)
+    case *image.NRGBA:
+        b, ok := b.(*image.NRGBA)
+        return ok && bytes.Equal(a.Pix, b.Pix)
+    case *image.Gray:
+        b, ok := b.(*image.Gray)
+        return ok && bytes.Equal(a.Pix, b.Pix)
+    }
+    return false
+}
+
+// videoProgress keeps track of the codes read from a video, so repeated codes are dropped & reading stops once a set is
+// complete
+type videoProgress struct {
+    texts    map[string]bool
+    indices  map[uint64]bool
+    maxIndex uint64
+    setKey   string
+    mixed    bool // codes of several sets were read; reading continues until the end of the video
+}
+
+func newVideoProgress() *videoProgress {
+    return &videoProgress{texts: make(map[string]bool), indices: make(map[uint64]bool)}
+}
+
+// add records the text of a code; the result is false if the code was read before
+func (p *videoProgress) add(text string) bool {
+    text = strings.TrimSpace(text)
+    if p.texts[text] {
+        return false
+    }
+    p.texts[text] = true
+    if IsControlText(text) {
+        return true
+    }
+    element := new(QrElement)
+    if element.ParseString(text) != nil {
+        return true
+    }
+    if len(p.indices) > 0 && (element.MaxIndex != p.maxIndex || element.setKey() != p.setKey) {
+        p.mixed = true
+    }
+    p.maxIndex = element.MaxIndex
+    p.setKey = element.setKey()
+    p.indices[element.Index] = true
+    return true
+}
+
+// complete reports whether all elements of a single set were read
+func (p *videoProgress) complete() bool {
+    return !p.mixed && len(p.indices) > 0 && uint64(len(p.indices)) == p.maxIndex+1
+}
@@ -0,0 +1,141 @@
+package qrFile
+
+import (
+    "bytes"
+    "encoding/hex"
+    "fmt"
+    "log/slog"
+    "strings"
+)
+
+// A misread digit in the header of a code makes an element claim a different count than the rest of its set, which
+// would otherwise split the set in two. The elements of a set vote on the count instead: elements disagreeing with a
+// clear majority are outvoted & corrected, if their index fits the set & is not taken by an element of other content.
+// Sets carrying a set ID (plain format) are checked against it once complete, since the set ID is a checksum of the
+// data; corrections not passing this check are undone.
+
+// OutvotedElement describes an element whose count disagreed with the majority of its set
+type OutvotedElement struct {
+    File     string // image the element was read from; empty if not read from a file
+    Index    uint64
+    MaxIndex uint64 // maximum index read from the element
+    Majority uint64 // maximum index agreed on by the majority of the set
+}
+
+// String describes the outvoted element, e.g. "element 3 (img_3.png) claims 18 codes, the set has 17"
+func (v OutvotedElement) String() string {
+    name := fmt.Sprintf("element %d", v.Index)
+    if len(v.File) > 0 {
+        name += " (" + v.File + ")"
+    }
+    return fmt.Sprintf("%s claims %d codes, the set has %d", name, v.MaxIndex+1, v.Majority+1)
+}
+
+// Reconcile resolves disagreeing counts within the sets of the elements by majority vote (see above) & returns the
+// elements which were outvoted. Outvoted elements are corrected, or removed if the same element was read correctly
+// as well. Validate calls Reconcile before checking the set.
+func (elem *QrElements) Reconcile() []OutvotedElement {
+    outvoted := make([]OutvotedElement, 0)
+    groups := make(map[string][]int)
+    order := make([]string, 0)
+    for i, v := range elem.Elements {
+        key := fmt.Sprintf("%d/%s", v.Version, v.SetID)
+        if _, ok := groups[key]; !ok {
+            order = append(order, key)
+        }
+        groups[key] = append(groups[key], i)
+    }
+    remove := make(map[int]bool)
+    for _, key := range order {
+        votes, removed := elem.reconcileGroup(groups[key])
+        outvoted = append(outvoted, votes...)
+        for _, i := range removed {
+            remove[i] = true
+        }
+    }
+    if len(remove) > 0 {
+        kept := make([]QrElement, 0, len(elem.Elements)-len(remove))
+        for i, v := range elem.Elements {
+            if !remove[i] {
+                kept = append(kept, v)
+            }
+        }
+        elem.Elements = kept
+    }
+    for _, v := range outvoted {
+        elem.logf(slog.LevelWarn, "Outvoted %s", v)
+    }
+    return outvoted
+}
+
+// reconcileGroup votes on the maximum index of the elements at the given positions (all of the same version & set ID).
+// Returns the outvoted elements & the positions of elements to remove.
+func (elem *QrElements) reconcileGroup(positions []int) ([]OutvotedElement, []int) {
+    // every index counts once per maximum index, so elements read twice do not tip the vote
+    votes := make(map[uint64]map[uint64]bool)
+    for _, i := range positions {
+        v := elem.Elements[i]
+        if votes[v.MaxIndex] == nil {
+            votes[v.MaxIndex] = make(map[uint64]bool)
+        }
+        votes[v.MaxIndex][v.Index] = true
+    }
+    if len(votes) < 2 {
+        return nil, nil
+    }
+    var majority uint64
+    best, total := 0, 0
+    for maxIndex, indices := range votes {
+        total += len(indices)
+        if len(indices) > best {
+            best, majority = len(indices), maxIndex
+        }
+    }
+    // a clear majority only: more votes than all others together
+    if best < 2 || best <= total-best {
+        return nil, nil
+    }
+    payloads := make(map[uint64][]byte)
+    for _, i := range positions {
+        if v := elem.Elements[i]; v.MaxIndex == majority {
+            payloads[v.Index] = v.Payload
+        }
+    }
+    outvoted := make([]OutvotedElement, 0)
+    corrected := make(map[int]uint64) // position -> original maximum index
+    removed := make([]int, 0)
+    for _, i := range positions {
+        v := &elem.Elements[i]
+        if v.MaxIndex == majority || v.Index > majority {
+            continue
+        }
+        payload, taken := payloads[v.Index]
+        if taken && !bytes.Equal(payload, v.Payload) {
+            // an element of other content; not a misread count
+            continue
+        }
+        outvoted = append(outvoted, OutvotedElement{File: v.source, Index: v.Index, MaxIndex: v.MaxIndex, Majority: majority})
+        if taken {
+            removed = append(removed, i)
+            continue
+        }
+        corrected[i] = v.MaxIndex
+        v.MaxIndex = majority
+        payloads[v.Index] = v.Payload
+    }
+    // the set ID is a checksum of the data: a complete set has to match it, otherwise the vote is undone
+    setID := elem.Elements[positions[0]].SetID
+    if len(setID) > 0 && uint64(len(payloads)) == majority+1 {
+        var data strings.Builder
+        for index := uint64(0); index <= majority; index++ {
+            data.WriteString(hex.EncodeToString(payloads[index]))
+        }
+        if makeSetID(data.String()) != setID {
+            for i, maxIndex := range corrected {
+                elem.Elements[i].MaxIndex = maxIndex
+            }
+            return nil, nil
+        }
+    }
+    return outvoted, removed
+}
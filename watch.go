@@ -0,0 +1,161 @@
+package qrFile
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Scanners often deliver the pages of a set one at a time, e.g. a network scanner saving to a shared folder while the
+// pages are fed. A DirectoryWatcher polls such a folder, reads the images added since the last poll & collects their
+// elements until the set is complete, so the file is restored as soon as the last page arrived. Files are read once
+// their size & modification time did not change for an interval, so images still being written are not taken for
+// damaged ones; a file which is replaced (e.g. a page scanned again under the same name) is read again.
+
+// DefaultWatchInterval is the time between two polls of a DirectoryWatcher
+const DefaultWatchInterval = 2 * time.Second
+
+// DirectoryWatcher collects the elements of a set from the images appearing in a directory (see Watch). The decoding
+// settings apply to every image read.
+type DirectoryWatcher struct {
+    Dir      string        // directory to watch; files in subdirectories are not read
+    Interval time.Duration // time between two polls; DefaultWatchInterval if 0
+    Decoder  Decoder       // used to read the images; the default decoder if nil (see ZbarFallback)
+    Retry    RetryPolicy   // further attempts for images which could not be read (see RetryPolicy)
+    Observer Observer      // notified of the progress of reading the images, if set
+}
+
+// WatchUpdate describes the images read by a DirectoryWatcher in a single poll & the state of the set afterwards
+type WatchUpdate struct {
+    Files    []string      // images read in this poll
+    Added    int           // new elements found in them
+    Report   *DecodeReport // how the images were read (images which failed, foreign codes)
+    Rejected []error       // elements which were not added, e.g. of another set or conflicting with one added before
+    Received int           // distinct elements collected so far
+    Total    uint64        // elements of the complete set; 0 if not known yet
+    Missing  []uint64      // indices of the elements still missing, ascending
+}
+
+// watchedFile is the state of a file in a watched directory
+type watchedFile struct {
+    size    int64
+    modTime time.Time
+}
+
+// Watch polls the directory every Interval, reads the images which appeared or changed since & calls update (if not
+// nil) after every poll which read images. Once the set is complete (or can be completed from its parity elements,
+// see parity.go), the elements are returned as a sorted, validated set, configured by the parameter code of the set if
+// one was read (see Parameters). Images present when Watch is called are read by the second poll. Watch returns
+// ctx.Err() once ctx is canceled, or an error if the directory can not be read.
+func (w DirectoryWatcher) Watch(ctx context.Context, update func(WatchUpdate)) (*QrElements, error) {
+    interval := w.Interval
+    if interval <= 0 {
+        interval = DefaultWatchInterval
+    }
+    info, err := os.Stat(w.Dir)
+    if err != nil {
+        return nil, err
+    }
+    if !info.IsDir() {
+        return nil, errors.New(fmt.Sprintf("%s is no directory", w.Dir))
+    }
+    err = CheckDecoder(w.Decoder)
+    if err != nil {
+        return nil, err
+    }
+    assembler := NewAssembler()
+    var params *Parameters
+    pending := make(map[string]watchedFile) // state of the files at the previous poll
+    read := make(map[string]watchedFile)    // state of the files when they were read
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        ready, err := w.poll(pending, read)
+        if err != nil {
+            return nil, err
+        }
+        if len(ready) > 0 {
+            trace("Watching %s: reading %d new images", w.Dir, len(ready))
+            batch := &QrElements{Decoder: w.Decoder, Retry: w.Retry, Observer: w.Observer}
+            err = batch.readFiles(ctx, ready)
+            if err != nil {
+                return nil, err
+            }
+            if params == nil {
+                params = batch.Parameters
+            }
+            result := WatchUpdate{Files: ready, Report: batch.Report}
+            for _, v := range batch.Elements {
+                isNew, err := assembler.Add(v)
+                if err != nil {
+                    result.Rejected = append(result.Rejected, fmt.Errorf("%s: %w", v.source, err))
+                } else if isNew {
+                    result.Added++
+                }
+            }
+            result.Received, result.Total, result.Missing = assembler.Len(), assembler.Total(), assembler.Missing()
+            if update != nil {
+                update(result)
+            }
+            if result.Added > 0 {
+                if elements := watchedSet(assembler); elements != nil {
+                    elements.useParameters(params)
+                    return elements, nil
+                }
+            }
+        }
+        select {
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        case <-ticker.C:
+        }
+    }
+}
+
+// poll lists the files of the directory & returns the ones to read: files whose size & modification time did not
+// change since the previous poll (recorded in pending) & differ from the state they were read in (recorded in read)
+func (w DirectoryWatcher) poll(pending map[string]watchedFile, read map[string]watchedFile) ([]string, error) {
+    entries, err := os.ReadDir(w.Dir)
+    if err != nil {
+        return nil, err
+    }
+    ready := make([]string, 0)
+    for _, entry := range entries {
+        // hidden files are usually temporary files of the program writing the scans
+        if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil {
+            // removed since the directory was listed
+            continue
+        }
+        fname := filepath.Join(w.Dir, entry.Name())
+        state := watchedFile{size: info.Size(), modTime: info.ModTime()}
+        if known, ok := read[fname]; ok && known == state {
+            continue
+        }
+        if previous, ok := pending[fname]; ok && previous == state && state.size > 0 {
+            delete(pending, fname)
+            read[fname] = state
+            ready = append(ready, fname)
+            continue
+        }
+        pending[fname] = state
+    }
+    return ready, nil
+}
+
+// watchedSet returns the elements collected as a validated set if the set is complete, nil otherwise
+func watchedSet(assembler *Assembler) *QrElements {
+    elements := MakeQrElements(0)
+    elements.Elements = assembler.sorted()
+    if elements.Validate() != nil {
+        return nil
+    }
+    return elements
+}
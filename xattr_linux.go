@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package qrFile
+
+import (
+    "bytes"
+    "syscall"
+)
+
+// readXattrs returns the extended attributes of a file; symlinks are followed, so callers skip them
+func readXattrs(fname string) (map[string]string, error) {
+    size, err := syscall.Listxattr(fname, nil)
+    if err != nil || size == 0 {
+        return nil, err
+    }
+    list := make([]byte, size)
+    size, err = syscall.Listxattr(fname, list)
+    if err != nil {
+        return nil, err
+    }
+    attrs := make(map[string]string)
+    for _, name := range bytes.Split(bytes.TrimRight(list[:size], "\x00"), []byte{0}) {
+        valueSize, err := syscall.Getxattr(fname, string(name), nil)
+        if err != nil {
+            return nil, err
+        }
+        value := make([]byte, valueSize)
+        valueSize, err = syscall.Getxattr(fname, string(name), value)
+        if err != nil {
+            return nil, err
+        }
+        attrs[string(name)] = string(value[:valueSize])
+    }
+    return attrs, nil
+}
+
+// writeXattrs sets the extended attributes of a file
+func writeXattrs(fname string, attrs map[string]string) error {
+    for name, value := range attrs {
+        err := syscall.Setxattr(fname, name, []byte(value), 0)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package qrFile
+
+import (
+    "errors"
+)
+
+// readXattrs returns the extended attributes of a file; not supported on this platform, so none are recorded
+func readXattrs(fname string) (map[string]string, error) {
+    return nil, nil
+}
+
+// writeXattrs sets the extended attributes of a file; not supported on this platform
+func writeXattrs(fname string, attrs map[string]string) error {
+    if len(attrs) == 0 {
+        return nil
+    }
+    return errors.New("Extended attributes are only supported on Linux")
+}
@@ -0,0 +1,297 @@
+package qrFile
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "strings"
+    "sync"
+    "time"
+)
+
+// zbarimg (http://zbar.sourceforge.net/) is called with --xml: every symbol found is reported separately, so images
+// holding several codes are read completely & the text of a code may contain line breaks. Binary content (e.g. if zbar
+// could not convert the text to UTF-8) is reported base64 encoded and decoded here. Starting a process per image
+// dominates the time to read a large set, so FromPNGs hands zbarimg the png files in batches (see scanPNGs) & takes the
+// codes of each file from the source element of the output naming it.
+
+// ZbarimgPath is the zbarimg binary called to read images (see ToolDirectory)
+var ZbarimgPath = "zbarimg"
+
+// zbarExitNoSymbols is the exit status of zbarimg if no symbol was found (in at least one of the images)
+const zbarExitNoSymbols = 4
+
+// zbarBatchSize is the maximum number of png files read by a single zbarimg process, see batchInputs
+const zbarBatchSize = 32
+
+// zbarResult is the xml output of zbarimg --xml
+type zbarResult struct {
+    Sources []struct {
+        Href    string `xml:"href,attr"`
+        Indices []struct {
+            Symbols []struct {
+                Type        string `xml:"type,attr"`
+                Quality     int    `xml:"quality,attr"`
+                Orientation string `xml:"orientation,attr"` // zbar 0.11 & later
+                Data        struct {
+                    Format string `xml:"format,attr"`
+                    Text   string `xml:",chardata"`
+                } `xml:"data"`
+            } `xml:"symbol"`
+        } `xml:"index"`
+    } `xml:"source"`
+}
+
+// zbarProbe remembers the result of probeZbar
+var zbarProbe struct {
+    once sync.Once
+    err  error
+}
+
+// zbarProbeText is the text of the code read by probeZbar
+const zbarProbeText = "QRF probe"
+
+// probeZbar checks once that zbarimg is installed & reads QR codes, so a missing or unsuitable zbar is reported with a
+// hint how to fix it instead of a bare exec error for every image
+func probeZbar() error {
+    zbarProbe.once.Do(func() {
+        alternatives := "none available in this build"
+        if names := DecoderNames(); len(names) > 0 {
+            alternatives = "available in this build: " + strings.Join(names, ", ")
+        }
+        path, err := lookTool(ZbarimgPath)
+        if err != nil {
+            zbarProbe.err = errors.New(fmt.Sprintf("%s not found in $PATH. Install zbar (e.g. apt install zbar-tools, brew install zbar or the ZBar installer on Windows), give its location (see ToolDirectory) or select another decoder (%s).", ZbarimgPath, alternatives))
+            return
+        }
+        symbols, err := probeZbarImage()
+        found := false
+        for _, symbol := range symbols {
+            found = found || symbol.Text == zbarProbeText
+        }
+        if err != nil || !found {
+            if err == nil {
+                err = errors.New("the test code was not recognized")
+            }
+            zbarProbe.err = errors.New(fmt.Sprintf("%s is installed, but does not read QR codes (%s). Install zbar 0.10 or later with QR code support or select another decoder (%s).", path, err, alternatives))
+        }
+    })
+    return zbarProbe.err
+}
+
+// probeZbarImage reads a code rendered by RscEncoder using zbarimg
+func probeZbarImage() ([]Symbol, error) {
+    img, err := RscEncoder{}.Encode(zbarProbeText, LevelL)
+    if err != nil {
+        return nil, err
+    }
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileProbe*.png")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    err = png.Encode(tempfile, img)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+    return runZbarimg(context.Background(), tempfile.Name())
+}
+
+// ZbarDecoder reads codes with zbarimg, which has to be installed (see CheckDecoder)
+type ZbarDecoder struct{}
+
+func (d ZbarDecoder) DecodeImage(img image.Image) ([]string, error) {
+    symbols, err := d.DecodeSymbols(img)
+    return symbolTexts(symbols), err
+}
+
+func (d ZbarDecoder) DecodeSymbols(img image.Image) ([]Symbol, error) {
+    return d.DecodeSymbolsContext(context.Background(), img)
+}
+
+func (ZbarDecoder) DecodeSymbolsContext(ctx context.Context, img image.Image) ([]Symbol, error) {
+    return scanImage(ctx, img)
+}
+
+func (ZbarDecoder) Probe() error {
+    return probeZbar()
+}
+
+// scanPNG returns all codes in a png image, using zbarimg; zbarimg is killed if ctx is canceled
+func scanPNG(ctx context.Context, fname string) ([]Symbol, error) {
+    err := probeZbar()
+    if err != nil {
+        return nil, err
+    }
+    return runZbarimg(ctx, fname)
+}
+
+// runZbarimg calls zbarimg for a png image & returns all codes found, or parent.Err() if parent is canceled first
+func runZbarimg(parent context.Context, fname string) ([]Symbol, error) {
+    var result, stderr bytes.Buffer
+    ctx, cancel := decodeContext(parent)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, toolPath(ZbarimgPath), "--quiet", "--xml", "-Sdisable", "-Sqrcode.enable", fname)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if parent.Err() != nil {
+        return nil, parent.Err()
+    }
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, &TimeoutError{Command: "zbarimg", File: fname, Timeout: DecodeTimeout}
+    }
+    if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == zbarExitNoSymbols {
+        return nil, errors.New(fmt.Sprintf("%s: no code found", fname))
+    }
+    if err != nil {
+        if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+            return nil, errors.New(fmt.Sprintf("%s: %s (%s)", fname, err, message))
+        }
+        return nil, err
+    }
+    symbols, err := parseZbarXML(&result)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", fname, err)
+    }
+    if len(symbols) == 0 {
+        return nil, errors.New(fmt.Sprintf("%s: no code found", fname))
+    }
+    return symbols, nil
+}
+
+// scanPNGs reads the png files with a single zbarimg process & returns the codes found in each file (by name); files
+// without codes are missing. An error is returned if zbarimg fails for another reason than a file without codes, or
+// takes longer than DecodeTimeout for each file; the files have to be read one by one then (see scanPNG).
+func scanPNGs(parent context.Context, files []string) (map[string][]Symbol, error) {
+    var result, stderr bytes.Buffer
+    timeout := DecodeTimeout * time.Duration(len(files))
+    var ctx context.Context
+    var cancel context.CancelFunc
+    if timeout > 0 {
+        ctx, cancel = context.WithTimeout(parent, timeout)
+    } else {
+        ctx, cancel = context.WithCancel(parent)
+    }
+    defer cancel()
+    args := append([]string{"--quiet", "--xml", "-Sdisable", "-Sqrcode.enable"}, files...)
+    cmd := exec.CommandContext(ctx, toolPath(ZbarimgPath), args...)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if parent.Err() != nil {
+        return nil, parent.Err()
+    }
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, &TimeoutError{Command: "zbarimg", File: strings.Join(files, ", "), Timeout: timeout}
+    }
+    if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == zbarExitNoSymbols) {
+        if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+            return nil, errors.New(fmt.Sprintf("%s (%s)", err, message))
+        }
+        return nil, err
+    }
+    sources, err := parseZbarSources(&result)
+    if err != nil {
+        return nil, err
+    }
+    scanned := make(map[string][]Symbol, len(files))
+    for _, source := range sources {
+        if len(source.symbols) > 0 {
+            scanned[source.href] = append(scanned[source.href], source.symbols...)
+        }
+    }
+    return scanned, nil
+}
+
+// batchInputs groups the input files into the batches handed to a worker of FromPNGs: png files read by ZbarDecoder
+// are read by a single zbarimg process per batch (see scanPNGs), so batches of up to zbarBatchSize of them are formed,
+// spread over the workers; all other files are read one by one
+func batchInputs(files []string, decoder Decoder, workers int) [][]string {
+    batches := make([][]string, 0, len(files))
+    pngs := make([]string, 0)
+    for _, fname := range files {
+        if _, zbar := decoder.(ZbarDecoder); zbar && isInputFile(fname) && inputFormat(fname) == "png" {
+            pngs = append(pngs, fname)
+            continue
+        }
+        batches = append(batches, []string{fname})
+    }
+    size := zbarBatchSize
+    if workers > 0 && (len(pngs)+workers-1)/workers < size {
+        size = (len(pngs) + workers - 1) / workers
+    }
+    for len(pngs) > 0 {
+        n := size
+        if n > len(pngs) {
+            n = len(pngs)
+        }
+        batches = append(batches, pngs[:n])
+        pngs = pngs[n:]
+    }
+    return batches
+}
+
+// zbarSource holds the QR codes zbarimg reported for one of its input files, named by href
+type zbarSource struct {
+    href    string
+    symbols []Symbol
+}
+
+// parseZbarXML returns all QR codes in the xml output of zbarimg (with their quality & orientation), in the order they
+// were reported
+func parseZbarXML(r io.Reader) ([]Symbol, error) {
+    sources, err := parseZbarSources(r)
+    if err != nil {
+        return nil, err
+    }
+    symbols := make([]Symbol, 0)
+    for _, source := range sources {
+        symbols = append(symbols, source.symbols...)
+    }
+    return symbols, nil
+}
+
+// parseZbarSources returns the QR codes in the xml output of zbarimg for each input file, in the order they were
+// reported
+func parseZbarSources(r io.Reader) ([]zbarSource, error) {
+    var result zbarResult
+    err := xml.NewDecoder(r).Decode(&result)
+    if err != nil {
+        return nil, fmt.Errorf("Unable to parse the output of zbarimg: %w", err)
+    }
+    sources := make([]zbarSource, 0, len(result.Sources))
+    for _, source := range result.Sources {
+        symbols := make([]Symbol, 0)
+        for _, index := range source.Indices {
+            for _, symbol := range index.Symbols {
+                if symbol.Type != "QR-Code" {
+                    continue
+                }
+                text := symbol.Data.Text
+                if symbol.Data.Format == "base64" {
+                    data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+                    if err != nil {
+                        return nil, fmt.Errorf("Invalid base64 data in the output of zbarimg: %w", err)
+                    }
+                    text = string(data)
+                }
+                symbols = append(symbols, Symbol{Text: text, Quality: symbol.Quality, Orientation: symbol.Orientation})
+            }
+        }
+        sources = append(sources, zbarSource{href: source.Href, symbols: symbols})
+    }
+    return sources, nil
+}
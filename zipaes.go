@@ -0,0 +1,196 @@
+package qrFile
+
+import (
+    "archive/zip"
+    "bytes"
+    "compress/flate"
+    "crypto/aes"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "golang.org/x/crypto/pbkdf2"
+    "io/ioutil"
+)
+
+// Containers can be protected with a password using the AES encryption of WinZip (AE-2, AES-256), which is read by
+// 7-Zip, WinZip, macOS Archive Utility (via 7-Zip/Keka), libarchive & most other zip tools: each file is encrypted
+// with a key derived from the password & a random salt (PBKDF2-HMAC-SHA1, 1000 iterations), in CTR mode, and
+// authenticated with HMAC-SHA1. File names & the list of files stay visible, as with any zip encryption.
+
+// ErrPasswordRequired is returned when reading an encrypted container without a password
+var ErrPasswordRequired = errors.New("The container is encrypted, a password is required")
+
+// ErrWrongPassword is returned when reading an encrypted container with a wrong password
+var ErrWrongPassword = errors.New("Wrong password for the container")
+
+// Constants of the WinZip AES format
+const (
+    zipMethodAES       = 99
+    zipExtraAES        = 0x9901
+    zipAESVersion      = 2 // AE-2: no CRC, the data is authenticated instead
+    zipAESStrength256  = 3
+    zipAESSaltSize     = 16 // for AES-256
+    zipAESKeySize      = 32
+    zipAESVerifierSize = 2
+    zipAESMACSize      = 10
+    zipAESIterations   = 1000
+    zipFlagEncrypted   = 0x1
+)
+
+// zipAESKeys derives the encryption key, the authentication key & the password verifier from password & salt
+func zipAESKeys(password string, salt []byte) (encKey []byte, authKey []byte, verifier []byte) {
+    keys := pbkdf2.Key([]byte(password), salt, zipAESIterations, 2*zipAESKeySize+zipAESVerifierSize, sha1.New)
+    return keys[:zipAESKeySize], keys[zipAESKeySize : 2*zipAESKeySize], keys[2*zipAESKeySize:]
+}
+
+// zipAESCrypt en- or decrypts data in place with AES in the CTR mode of WinZip: the counter is little endian &
+// starts at 1
+func zipAESCrypt(key []byte, data []byte) error {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return err
+    }
+    var counter, stream [aes.BlockSize]byte
+    for offset, n := 0, uint64(1); offset < len(data); offset, n = offset+aes.BlockSize, n+1 {
+        binary.LittleEndian.PutUint64(counter[:8], n)
+        block.Encrypt(stream[:], counter[:])
+        for i := 0; i < aes.BlockSize && offset+i < len(data); i++ {
+            data[offset+i] ^= stream[i]
+        }
+    }
+    return nil
+}
+
+// writeZipFile adds a file to a zip archive, compressed with method (zip.Store or zip.Deflate) &, if password is not
+// empty, encrypted
+func writeZipFile(archive *zip.Writer, name string, method uint16, data []byte, password string) error {
+    if len(password) == 0 {
+        out, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+        if err != nil {
+            return err
+        }
+        _, err = out.Write(data)
+        return err
+    }
+    size := len(data)
+    if method == zip.Deflate {
+        var compressed bytes.Buffer
+        writer, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+        if err != nil {
+            return err
+        }
+        writer.Write(data)
+        err = writer.Close()
+        if err != nil {
+            return err
+        }
+        data = compressed.Bytes()
+    } else {
+        data = append([]byte(nil), data...)
+    }
+    salt := make([]byte, zipAESSaltSize)
+    _, err := rand.Read(salt)
+    if err != nil {
+        return err
+    }
+    encKey, authKey, verifier := zipAESKeys(password, salt)
+    err = zipAESCrypt(encKey, data)
+    if err != nil {
+        return err
+    }
+    mac := hmac.New(sha1.New, authKey)
+    mac.Write(data)
+
+    extra := make([]byte, 11)
+    binary.LittleEndian.PutUint16(extra[0:], zipExtraAES)
+    binary.LittleEndian.PutUint16(extra[2:], 7)
+    binary.LittleEndian.PutUint16(extra[4:], zipAESVersion)
+    copy(extra[6:], "AE")
+    extra[8] = zipAESStrength256
+    binary.LittleEndian.PutUint16(extra[9:], method)
+    header := &zip.FileHeader{Name: name, Method: zipMethodAES, Flags: zipFlagEncrypted, Extra: extra}
+    header.CompressedSize64 = uint64(len(salt) + len(verifier) + len(data) + zipAESMACSize)
+    header.UncompressedSize64 = uint64(size)
+    out, err := archive.CreateRaw(header)
+    if err != nil {
+        return err
+    }
+    for _, part := range [][]byte{salt, verifier, data, mac.Sum(nil)[:zipAESMACSize]} {
+        _, err = out.Write(part)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// readZipAES returns the contents of a file encrypted by writeZipFile (or another tool writing WinZip AES)
+func readZipAES(f *zip.File, password string) ([]byte, error) {
+    if len(password) == 0 {
+        return nil, ErrPasswordRequired
+    }
+    method, saltSize := uint16(0), 0
+    for extra := f.Extra; len(extra) >= 4; {
+        id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+        if len(extra) < 4+size {
+            break
+        }
+        if id == zipExtraAES && size >= 7 {
+            // strength 1, 2 & 3 select AES-128, -192 & -256 with a salt of 8, 12 & 16 bytes
+            if strength := int(extra[8]); strength >= 1 && strength <= 3 {
+                saltSize = 4 + 4*strength
+            }
+            method = binary.LittleEndian.Uint16(extra[9:])
+        }
+        extra = extra[4+size:]
+    }
+    if saltSize == 0 {
+        return nil, errors.New(fmt.Sprintf("%s: unsupported zip encryption", f.Name))
+    }
+    raw, err := f.OpenRaw()
+    if err != nil {
+        return nil, err
+    }
+    data, err := ioutil.ReadAll(raw)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < saltSize+zipAESVerifierSize+zipAESMACSize {
+        return nil, errors.New(fmt.Sprintf("%s: truncated encrypted file", f.Name))
+    }
+    salt, data := data[:saltSize], data[saltSize:]
+    // the key size follows the salt size: 16, 24 or 32 bytes
+    keys := pbkdf2.Key([]byte(password), salt, zipAESIterations, 4*saltSize+zipAESVerifierSize, sha1.New)
+    encKey, authKey, verifier := keys[:2*saltSize], keys[2*saltSize:4*saltSize], keys[4*saltSize:]
+    if !bytes.Equal(verifier, data[:zipAESVerifierSize]) {
+        return nil, ErrWrongPassword
+    }
+    data, sum := data[zipAESVerifierSize:len(data)-zipAESMACSize], data[len(data)-zipAESMACSize:]
+    mac := hmac.New(sha1.New, authKey)
+    mac.Write(data)
+    if !hmac.Equal(mac.Sum(nil)[:zipAESMACSize], sum) {
+        return nil, errors.New(fmt.Sprintf("%s: the encrypted data is damaged", f.Name))
+    }
+    err = zipAESCrypt(encKey, data)
+    if err != nil {
+        return nil, err
+    }
+    switch method {
+    case zip.Store:
+        return data, nil
+    case zip.Deflate:
+        return ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+    }
+    return nil, errors.New(fmt.Sprintf("%s: unsupported compression method %d", f.Name, method))
+}
+
+// readZipFileWithPassword returns the contents of a file inside a zip archive, decrypting it if needed
+func readZipFileWithPassword(f *zip.File, password string) ([]byte, error) {
+    if f != nil && f.Method == zipMethodAES {
+        return readZipAES(f, password)
+    }
+    return readZipFile(f)
+}
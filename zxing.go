@@ -0,0 +1,141 @@
+package qrFile
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "image"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// ZXingReader, the command line reader of zxing-cpp (https://github.com/zxing-cpp/zxing-cpp), reads every symbology
+// supported by qrFile, including PDF417 which the native decoder does not read. It reports each code found as a block
+// of "Key: value" lines; the text is quoted & escaped like a Go string literal.
+
+// ZXingReaderPath is the ZXingReader binary called to read images (see ToolDirectory)
+var ZXingReaderPath = "ZXingReader"
+
+// zxingFormats maps the formats reported by ZXingReader to the symbologies; codes of other formats are ignored
+var zxingFormats = map[string]Symbology{"QRCode": SymbologyQR, "DataMatrix": SymbologyDataMatrix, "Aztec": SymbologyAztec, "PDF417": SymbologyPDF417}
+
+// zxingProbe remembers the result of probeZXing
+var zxingProbe struct {
+    once sync.Once
+    err  error
+}
+
+// probeZXing checks once that ZXingReader is installed, so a missing reader is reported with a hint how to fix it
+func probeZXing() error {
+    zxingProbe.once.Do(func() {
+        if _, err := lookTool(ZXingReaderPath); err != nil {
+            zxingProbe.err = errors.New(fmt.Sprintf("%s not found in $PATH. Install zxing-cpp (e.g. apt install zxing-cpp-tools) or select another decoder (available in this build: %s).", ZXingReaderPath, strings.Join(DecoderNames(), ", ")))
+        }
+    })
+    return zxingProbe.err
+}
+
+// ZXingDecoder reads codes of all symbologies with ZXingReader, which has to be installed (see CheckDecoder)
+type ZXingDecoder struct{}
+
+func (d ZXingDecoder) DecodeImage(img image.Image) ([]string, error) {
+    symbols, err := d.DecodeSymbols(img)
+    return symbolTexts(symbols), err
+}
+
+func (d ZXingDecoder) DecodeSymbols(img image.Image) ([]Symbol, error) {
+    return d.DecodeSymbolsContext(context.Background(), img)
+}
+
+func (ZXingDecoder) DecodeSymbolsContext(ctx context.Context, img image.Image) ([]Symbol, error) {
+    err := probeZXing()
+    if err != nil {
+        return nil, err
+    }
+    tempfile, err := ioutil.TempFile(os.TempDir(), "qrFileImage*.png")
+    if err != nil {
+        return nil, err
+    }
+    defer os.Remove(tempfile.Name())
+    err = png.Encode(tempfile, img)
+    if closeErr := tempfile.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return nil, err
+    }
+    return runZXingReader(ctx, tempfile.Name())
+}
+
+func (ZXingDecoder) Probe() error {
+    return probeZXing()
+}
+
+// runZXingReader calls ZXingReader for a png image & returns all codes found, or parent.Err() if parent is canceled
+// first; an image without any code results in an empty list
+func runZXingReader(parent context.Context, fname string) ([]Symbol, error) {
+    var result, stderr bytes.Buffer
+    ctx, cancel := decodeContext(parent)
+    defer cancel()
+    cmd := exec.CommandContext(ctx, toolPath(ZXingReaderPath), fname)
+    cmd.Stdout = &result
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    if parent.Err() != nil {
+        return nil, parent.Err()
+    }
+    if ctx.Err() == context.DeadlineExceeded {
+        return nil, &TimeoutError{Command: "ZXingReader", File: fname, Timeout: DecodeTimeout}
+    }
+    symbols, parseErr := parseZXingOutput(&result)
+    if err != nil && len(symbols) == 0 {
+        if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+            return nil, errors.New(fmt.Sprintf("%s: %s (%s)", fname, err, message))
+        }
+        return nil, err
+    }
+    return symbols, parseErr
+}
+
+// parseZXingOutput returns the codes of supported symbologies in the output of ZXingReader, in the order they were
+// reported
+func parseZXingOutput(r io.Reader) ([]Symbol, error) {
+    symbols := make([]Symbol, 0)
+    var text string
+    hasText := false
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(nil, 1<<20)
+    for scanner.Scan() {
+        parts := strings.SplitN(scanner.Text(), ":", 2)
+        if len(parts) != 2 {
+            continue
+        }
+        value := strings.TrimSpace(parts[1])
+        switch strings.TrimSpace(parts[0]) {
+        case "Text":
+            unquoted, err := strconv.Unquote(value)
+            if err != nil {
+                unquoted = strings.Trim(value, "\"")
+            }
+            text, hasText = unquoted, true
+        case "Format":
+            // the format follows the text of the same code
+            if symbology, ok := zxingFormats[value]; ok && hasText {
+                symbols = append(symbols, Symbol{Text: text, Symbology: symbology})
+            }
+            hasText = false
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("Unable to parse the output of ZXingReader: %w", err)
+    }
+    return symbols, nil
+}